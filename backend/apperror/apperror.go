@@ -0,0 +1,240 @@
+package apperror
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Code is a stable, machine-readable error identifier that clients can branch on
+type Code string
+
+const (
+	CodeInvalidRequest       Code = "INVALID_REQUEST"
+	CodeValidationFailed     Code = "VALIDATION_FAILED"
+	CodeUnauthorized         Code = "UNAUTHORIZED"
+	CodeInvalidToken         Code = "INVALID_TOKEN"
+	CodeInvalidSignature     Code = "INVALID_SIGNATURE"
+	CodeInvalidCredentials   Code = "INVALID_CREDENTIALS"
+	CodeAccountInactive      Code = "ACCOUNT_INACTIVE"
+	CodeForbidden            Code = "FORBIDDEN"
+	CodeNotFound             Code = "NOT_FOUND"
+	CodeProductNotFound      Code = "PRODUCT_NOT_FOUND"
+	CodeCategoryNotFound     Code = "CATEGORY_NOT_FOUND"
+	CodeUserNotFound         Code = "USER_NOT_FOUND"
+	CodeOrderNotFound        Code = "ORDER_NOT_FOUND"
+	CodeShipmentNotFound     Code = "SHIPMENT_NOT_FOUND"
+	CodeReturnNotFound       Code = "RETURN_NOT_FOUND"
+	CodeRefundNotFound       Code = "REFUND_NOT_FOUND"
+	CodeJobNotFound          Code = "JOB_NOT_FOUND"
+	CodeCartNotFound         Code = "CART_NOT_FOUND"
+	CodeCartItemNotFound     Code = "CART_ITEM_NOT_FOUND"
+	CodeCartEmpty            Code = "CART_EMPTY"
+	CodeInsufficientStock    Code = "INSUFFICIENT_STOCK"
+	CodeOrderNotCancellable  Code = "ORDER_NOT_CANCELLABLE"
+	CodeOrderNotEditable     Code = "ORDER_NOT_EDITABLE"
+	CodeInvalidTransition    Code = "INVALID_STATUS_TRANSITION"
+	CodeShipmentExceedsOrder Code = "SHIPMENT_EXCEEDS_ORDER"
+	CodeReturnNotAllowed     Code = "RETURN_NOT_ALLOWED"
+	CodeReturnWindowClosed   Code = "RETURN_WINDOW_CLOSED"
+	CodeReturnExceedsOrder   Code = "RETURN_EXCEEDS_ORDER"
+	CodeRefundExceedsReturn  Code = "REFUND_EXCEEDS_RETURN"
+	CodeRefundExceedsItems   Code = "REFUND_EXCEEDS_ITEMS"
+	CodeRefundExceedsPaid    Code = "REFUND_EXCEEDS_PAID"
+	CodeRefundNotAllowed     Code = "REFUND_NOT_ALLOWED"
+	CodeRefundFailed         Code = "REFUND_FAILED"
+	CodeInvoiceNotIssued     Code = "INVOICE_NOT_ISSUED"
+	CodeEmailTaken           Code = "EMAIL_TAKEN"
+	CodeCategoryExists       Code = "CATEGORY_EXISTS"
+	CodeSKUTaken             Code = "SKU_TAKEN"
+	CodeVersionConflict      Code = "VERSION_CONFLICT"
+	CodePreconditionRequired Code = "PRECONDITION_REQUIRED"
+	CodePreconditionFailed   Code = "PRECONDITION_FAILED"
+	CodeRateLimited          Code = "RATE_LIMITED"
+	CodeMethodNotAllowed     Code = "METHOD_NOT_ALLOWED"
+	CodeInternal             Code = "INTERNAL_ERROR"
+)
+
+// Error is an application error that renders as an RFC 7807 problem
+// document. Err holds the underlying cause for logs and is never sent to
+// clients.
+type Error struct {
+	Status int
+	Code   Code
+	Detail string
+	Fields map[string]string
+	Extra  map[string]interface{}
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// reserved are the members every problem document has; extension members
+// must not replace them
+var reserved = map[string]bool{
+	"type":       true,
+	"title":      true,
+	"status":     true,
+	"detail":     true,
+	"instance":   true,
+	"code":       true,
+	"request_id": true,
+	"errors":     true,
+}
+
+// renamedKeys holds the reserved keys With has already warned about
+var renamedKeys sync.Map
+
+// With attaches an extension member to the problem document. A key that is
+// one of the standard members, such as "status", is sent as "x_status"
+// instead, and logged the first time so the caller can be fixed.
+func (e *Error) With(key string, value interface{}) *Error {
+	if reserved[key] {
+		if _, warned := renamedKeys.LoadOrStore(key, true); !warned {
+			slog.Warn("Problem extension uses a reserved member name", "key", key, "sent_as", "x_"+key, "code", e.Code)
+		}
+		key = "x_" + key
+	}
+	if e.Extra == nil {
+		e.Extra = make(map[string]interface{})
+	}
+	e.Extra[key] = value
+	return e
+}
+
+// Wrap records the internal cause of the error
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// New creates an error with the given HTTP status, code and client-facing detail
+func New(status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(code Code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+func Unauthorized(code Code, detail string) *Error {
+	return New(http.StatusUnauthorized, code, detail)
+}
+
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(code Code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+func Conflict(code Code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+// Validation reports invalid request fields, keyed by JSON path
+func Validation(fields map[string]string) *Error {
+	e := New(http.StatusUnprocessableEntity, CodeValidationFailed, "One or more fields are invalid")
+	e.Fields = fields
+	return e
+}
+
+// Internal hides err behind a generic message so database and driver
+// errors never reach clients
+func Internal(detail string, err error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, detail).Wrap(err)
+}
+
+// FromStatus converts a bare HTTP status, such as a routing 404, into an Error
+func FromStatus(status int, detail string) *Error {
+	code := CodeInternal
+	switch {
+	case status == http.StatusNotFound:
+		code = CodeNotFound
+	case status == http.StatusMethodNotAllowed:
+		code = CodeMethodNotAllowed
+	case status == http.StatusUnauthorized:
+		code = CodeUnauthorized
+	case status == http.StatusForbidden:
+		code = CodeForbidden
+	case status == http.StatusTooManyRequests:
+		code = CodeRateLimited
+	case status < http.StatusInternalServerError:
+		code = CodeInvalidRequest
+	}
+	if status >= http.StatusInternalServerError {
+		detail = http.StatusText(status)
+	}
+	return New(status, code, detail)
+}
+
+// Problem is the RFC 7807 body sent for an Error
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      Code              `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// Problem returns the standard members of e's problem document
+func (e *Error) Problem(instance, requestID string) Problem {
+	return Problem{
+		Type:      "/problems/" + strings.ReplaceAll(strings.ToLower(string(e.Code)), "_", "-"),
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  instance,
+		Code:      e.Code,
+		RequestID: requestID,
+		Errors:    e.Fields,
+	}
+}
+
+// ProblemBody returns the full problem document, including the extension
+// members from Extra
+func (e *Error) ProblemBody(instance, requestID string) map[string]interface{} {
+	p := e.Problem(instance, requestID)
+	body := map[string]interface{}{
+		"type":   p.Type,
+		"title":  p.Title,
+		"status": p.Status,
+		"code":   p.Code,
+	}
+	for key, value := range e.Extra {
+		if !reserved[key] {
+			body[key] = value
+		}
+	}
+	if p.Detail != "" {
+		body["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		body["instance"] = p.Instance
+	}
+	if p.RequestID != "" {
+		body["request_id"] = p.RequestID
+	}
+	if len(p.Errors) > 0 {
+		body["errors"] = p.Errors
+	}
+	return body
+}
+
+// ContentType is the media type of problem documents
+const ContentType = "application/problem+json"
@@ -0,0 +1,123 @@
+package apperror
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestWith(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantKey string
+	}{
+		{name: "extension", key: "product_id", wantKey: "product_id"},
+		{name: "reserved status", key: "status", wantKey: "x_status"},
+		{name: "reserved type", key: "type", wantKey: "x_type"},
+		{name: "reserved errors", key: "errors", wantKey: "x_errors"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Twice, as only the first use of a reserved key is logged
+			for i := 0; i < 2; i++ {
+				e := Conflict(CodeInvalidTransition, "Nope").With(tt.key, "shipped")
+				if len(e.Extra) != 1 || e.Extra[tt.wantKey] != "shipped" {
+					t.Fatalf("Extra = %v, want only %s", e.Extra, tt.wantKey)
+				}
+				body := e.ProblemBody("/api/v1/orders/1", "req-1")
+				if body["status"] != http.StatusConflict || body["type"] != "/problems/invalid-status-transition" {
+					t.Errorf("standard members replaced: %v", body)
+				}
+				if body[tt.wantKey] != "shipped" {
+					t.Errorf("body[%s] = %v, want shipped", tt.wantKey, body[tt.wantKey])
+				}
+			}
+		})
+	}
+}
+
+func TestProblemBody(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		want map[string]interface{}
+	}{
+		{
+			name: "not found",
+			err:  NotFound(CodeOrderNotFound, "Order not found"),
+			want: map[string]interface{}{
+				"type": "/problems/order-not-found", "title": "Not Found", "status": 404,
+				"code": CodeOrderNotFound, "detail": "Order not found",
+				"instance": "/api/v1/orders/9", "request_id": "req-1",
+			},
+		},
+		{
+			name: "validation",
+			err:  Validation(map[string]string{"email": "is required"}),
+			want: map[string]interface{}{
+				"type": "/problems/validation-failed", "title": "Unprocessable Entity", "status": 422,
+				"code": CodeValidationFailed, "detail": "One or more fields are invalid",
+				"instance": "/api/v1/orders/9", "request_id": "req-1",
+				"errors": map[string]string{"email": "is required"},
+			},
+		},
+		{
+			name: "extension members",
+			err:  BadRequest(CodeInsufficientStock, "Not enough stock").With("available", 2),
+			want: map[string]interface{}{
+				"type": "/problems/insufficient-stock", "title": "Bad Request", "status": 400,
+				"code": CodeInsufficientStock, "detail": "Not enough stock",
+				"instance": "/api/v1/orders/9", "request_id": "req-1", "available": 2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.err.ProblemBody("/api/v1/orders/9", "req-1")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProblemBody() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInternalHidesCause(t *testing.T) {
+	cause := errors.New("pq: connection refused")
+	e := Internal("Failed to get order", cause)
+	if !errors.Is(e, cause) {
+		t.Error("Internal does not wrap its cause")
+	}
+	body := e.ProblemBody("/api/v1/orders/1", "")
+	if body["detail"] != "Failed to get order" || body["code"] != CodeInternal {
+		t.Errorf("body = %v, want the generic detail only", body)
+	}
+	if _, ok := body["request_id"]; ok {
+		t.Error("empty request ID was sent")
+	}
+}
+
+func TestFromStatus(t *testing.T) {
+	tests := []struct {
+		status     int
+		wantCode   Code
+		wantDetail string
+	}{
+		{http.StatusNotFound, CodeNotFound, "Cannot GET /nope"},
+		{http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Cannot GET /nope"},
+		{http.StatusUnauthorized, CodeUnauthorized, "Cannot GET /nope"},
+		{http.StatusForbidden, CodeForbidden, "Cannot GET /nope"},
+		{http.StatusTooManyRequests, CodeRateLimited, "Cannot GET /nope"},
+		{http.StatusRequestEntityTooLarge, CodeInvalidRequest, "Cannot GET /nope"},
+		{http.StatusBadGateway, CodeInternal, "Bad Gateway"},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			e := FromStatus(tt.status, "Cannot GET /nope")
+			if e.Status != tt.status || e.Code != tt.wantCode || e.Detail != tt.wantDetail {
+				t.Errorf("FromStatus(%d) = %d %s %q, want %s %q", tt.status, e.Status, e.Code, e.Detail, tt.wantCode, tt.wantDetail)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ecommerce-backend/database"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+const keyPrefix = "cache:"
+
+// redisTimeout bounds every cache round trip so a slow Redis degrades to a miss
+const redisTimeout = 100 * time.Millisecond
+
+var group singleflight.Group
+
+// Stats holds hit/miss counters for a cache namespace
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+type counters struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+var namespaces sync.Map // namespace -> *counters
+
+func countersFor(namespace string) *counters {
+	if c, ok := namespaces.Load(namespace); ok {
+		return c.(*counters)
+	}
+	c, _ := namespaces.LoadOrStore(namespace, &counters{})
+	return c.(*counters)
+}
+
+// Snapshot returns the current counters for every namespace that has been used
+func Snapshot() map[string]Stats {
+	result := make(map[string]Stats)
+	namespaces.Range(func(key, value any) bool {
+		c := value.(*counters)
+		result[key.(string)] = Stats{
+			Hits:   c.hits.Load(),
+			Misses: c.misses.Load(),
+			Errors: c.errors.Load(),
+		}
+		return true
+	})
+	return result
+}
+
+var enabled atomic.Bool
+
+// SetEnabled switches the read-through cache on or off
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+// Enabled reports whether the read-through cache is switched on
+func Enabled() bool {
+	return enabled.Load() && database.RedisClient != nil
+}
+
+// Remember returns the cached value for key, or calls load and stores its
+// result for ttl. Concurrent misses for the same key within this process
+// share a single load, and TTLs are jittered so entries written together do
+// not all expire together. The boolean result reports a cache hit.
+func Remember(ctx context.Context, namespace, key string, ttl time.Duration, load func() ([]byte, error)) ([]byte, bool, error) {
+	stats := countersFor(namespace)
+	if !Enabled() {
+		stats.misses.Add(1)
+		data, err := load()
+		return data, false, err
+	}
+
+	fullKey := keyPrefix + key
+	if data, err := get(ctx, fullKey); err == nil {
+		stats.hits.Add(1)
+		return data, true, nil
+	} else if !errors.Is(err, redis.Nil) {
+		stats.errors.Add(1)
+	}
+
+	stats.misses.Add(1)
+	value, err, _ := group.Do(fullKey, func() (interface{}, error) {
+		data, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if err := set(context.WithoutCancel(ctx), fullKey, data, jitter(ttl)); err != nil {
+			stats.errors.Add(1)
+			slog.WarnContext(ctx, "Cache write failed", "key", fullKey, "error", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value.([]byte), false, nil
+}
+
+// Delete removes the given keys
+func Delete(ctx context.Context, keys ...string) {
+	if !Enabled() || len(keys) == 0 {
+		return
+	}
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = keyPrefix + key
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	if err := database.RedisClient.Del(ctx, fullKeys...).Err(); err != nil {
+		slog.WarnContext(ctx, "Cache delete failed", "keys", keys, "error", err)
+	}
+}
+
+// Generation returns the current generation of a key group. Including it in
+// cache keys lets a whole group be invalidated by bumping it.
+func Generation(ctx context.Context, group string) int64 {
+	if !Enabled() {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	gen, err := database.RedisClient.Get(ctx, keyPrefix+"gen:"+group).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		countersFor(group).errors.Add(1)
+	}
+	return gen
+}
+
+// BumpGeneration invalidates every key built from the group's generation
+func BumpGeneration(ctx context.Context, group string) {
+	if !Enabled() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	if err := database.RedisClient.Incr(ctx, keyPrefix+"gen:"+group).Err(); err != nil {
+		slog.WarnContext(ctx, "Cache generation bump failed", "group", group, "error", err)
+	}
+}
+
+func get(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	return database.RedisClient.Get(ctx, key).Bytes()
+}
+
+func set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	return database.RedisClient.Set(ctx, key, data, ttl).Err()
+}
+
+func jitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(int64(ttl)/10+1))
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ecommerce-backend/database"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// useRedis switches the cache on against a fresh miniredis for the test
+func useRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previous := database.RedisClient
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	SetEnabled(true)
+	t.Cleanup(func() {
+		SetEnabled(false)
+		database.RedisClient.Close()
+		database.RedisClient = previous
+	})
+	return mr
+}
+
+// loader counts its calls and returns value, or err when set
+type loader struct {
+	calls atomic.Int32
+	value string
+	err   error
+}
+
+func (l *loader) load() ([]byte, error) {
+	l.calls.Add(1)
+	if l.err != nil {
+		return nil, l.err
+	}
+	return []byte(l.value), nil
+}
+
+func TestRemember(t *testing.T) {
+	tests := []struct {
+		name      string
+		disabled  bool
+		redisDown bool
+		loadErr   error
+		// wantHit and wantCalls describe the second of two calls
+		wantHit   bool
+		wantCalls int32
+		wantCache bool
+	}{
+		{name: "miss then hit", wantHit: true, wantCalls: 1, wantCache: true},
+		{name: "errors are not cached", loadErr: errors.New("db down"), wantCalls: 2},
+		{name: "disabled", disabled: true, wantCalls: 2},
+		{name: "redis unreachable loads every time", redisDown: true, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := useRedis(t)
+			SetEnabled(!tt.disabled)
+			if tt.redisDown {
+				mr.Close()
+			}
+			ctx := context.Background()
+			l := &loader{value: "products", err: tt.loadErr}
+
+			var data []byte
+			var hit bool
+			var err error
+			for i := 0; i < 2; i++ {
+				data, hit, err = Remember(ctx, "test-"+tt.name, "products:page=1", time.Minute, l.load)
+			}
+			if !errors.Is(err, tt.loadErr) {
+				t.Fatalf("err = %v, want %v", err, tt.loadErr)
+			}
+			if hit != tt.wantHit || l.calls.Load() != tt.wantCalls {
+				t.Errorf("second call: hit %v after %d loads, want %v after %d", hit, l.calls.Load(), tt.wantHit, tt.wantCalls)
+			}
+			if tt.loadErr == nil && string(data) != "products" {
+				t.Errorf("data = %q, want products", data)
+			}
+			if !tt.redisDown && mr.Exists(keyPrefix+"products:page=1") != tt.wantCache {
+				t.Errorf("stored in Redis = %v, want %v", !tt.wantCache, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestRememberTTL(t *testing.T) {
+	mr := useRedis(t)
+	l := &loader{value: "categories"}
+	if _, _, err := Remember(context.Background(), "test", "categories", time.Minute, l.load); err != nil {
+		t.Fatal(err)
+	}
+
+	// Jitter adds up to a tenth
+	if ttl := mr.TTL(keyPrefix + "categories"); ttl < time.Minute || ttl > 66*time.Second {
+		t.Errorf("TTL = %v, want between 1m and 1m6s", ttl)
+	}
+	mr.FastForward(67 * time.Second)
+	if _, hit, _ := Remember(context.Background(), "test", "categories", time.Minute, l.load); hit || l.calls.Load() != 2 {
+		t.Errorf("expired entry: hit %v after %d loads, want a miss and a second load", hit, l.calls.Load())
+	}
+}
+
+func TestRememberSharesConcurrentLoads(t *testing.T) {
+	useRedis(t)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	load := func() ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("products"), nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if data, _, err := Remember(context.Background(), "test", "stampede", time.Minute, load); err != nil || string(data) != "products" {
+				t.Errorf("Remember = %q, %v", data, err)
+			}
+		}()
+	}
+	// Give every caller time to miss before the first load finishes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("%d loads for %d concurrent misses, want 1", calls.Load(), callers)
+	}
+}
+
+func TestGenerations(t *testing.T) {
+	mr := useRedis(t)
+	ctx := context.Background()
+
+	if gen := Generation(ctx, "catalog:products"); gen != 0 {
+		t.Fatalf("new group generation = %d, want 0", gen)
+	}
+	BumpGeneration(ctx, "catalog:products")
+	BumpGeneration(ctx, "catalog:products")
+	if gen := Generation(ctx, "catalog:products"); gen != 2 {
+		t.Errorf("generation after two bumps = %d, want 2", gen)
+	}
+	if gen := Generation(ctx, "catalog:categories"); gen != 0 {
+		t.Errorf("other group generation = %d, want it untouched", gen)
+	}
+
+	// With Redis gone every group reads as generation 0
+	mr.Close()
+	if gen := Generation(ctx, "catalog:products"); gen != 0 {
+		t.Errorf("generation without Redis = %d, want 0", gen)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	mr := useRedis(t)
+	ctx := context.Background()
+	l := &loader{value: "product"}
+	for _, key := range []string{"product:1", "product:2", "product:3"} {
+		if _, _, err := Remember(ctx, "test", key, time.Minute, l.load); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Delete(ctx, "product:1", "product:3")
+	for key, want := range map[string]bool{"product:1": false, "product:2": true, "product:3": false} {
+		if mr.Exists(keyPrefix+key) != want {
+			t.Errorf("%s cached = %v, want %v", key, !want, want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	useRedis(t)
+	before := Snapshot()["snapshot-test"]
+	l := &loader{value: "x"}
+	for i := 0; i < 3; i++ {
+		_, _, _ = Remember(context.Background(), "snapshot-test", "x", time.Minute, l.load)
+	}
+	got := Snapshot()["snapshot-test"]
+	if got.Hits-before.Hits != 2 || got.Misses-before.Misses != 1 || got.Errors != before.Errors {
+		t.Errorf("stats went from %+v to %+v, want 2 more hits and 1 more miss", before, got)
+	}
+}
@@ -0,0 +1,153 @@
+// Package carriers fetches tracking events from courier APIs. Each courier
+// has an adapter implementing CarrierTracker that turns its own statuses into
+// the handful this application understands; a Registry holds the adapters
+// that are configured, keyed by the carrier code stored on shipments.
+package carriers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"ecommerce-backend/config"
+)
+
+// Normalised tracking statuses
+const (
+	StatusPickedUp       = "picked_up"
+	StatusInTransit      = "in_transit"
+	StatusOutForDelivery = "out_for_delivery"
+	StatusDelivered      = "delivered"
+	StatusException      = "exception"
+)
+
+// Codes of the carriers shipments can be sent with, whether or not their
+// tracking is configured
+const (
+	CodeJNE     = "jne"
+	CodeJNT     = "jnt"
+	CodeSiCepat = "sicepat"
+)
+
+// Codes lists every known carrier code
+var Codes = []string{CodeJNE, CodeJNT, CodeSiCepat}
+
+// Normalize returns the code of a carrier as typed, e.g. " JNE " for jne, and
+// whether it is a known carrier
+func Normalize(carrier string) (string, bool) {
+	code := strings.ToLower(strings.TrimSpace(carrier))
+	for _, known := range Codes {
+		if code == known {
+			return code, true
+		}
+	}
+	return code, false
+}
+
+// ErrUnknownTrackingNumber is returned when the carrier has no parcel with
+// the tracking number
+var ErrUnknownTrackingNumber = errors.New("unknown tracking number")
+
+// Event is one scan or status change of a parcel
+type Event struct {
+	Status      string
+	Description string
+	Location    string
+	OccurredAt  time.Time
+}
+
+// CarrierTracker fetches the tracking history of parcels from one carrier
+type CarrierTracker interface {
+	// Code is the carrier code shipments are stored with, e.g. "jne"
+	Code() string
+	// Track returns every event the carrier has for the tracking number,
+	// oldest first
+	Track(ctx context.Context, trackingNumber string) ([]Event, error)
+}
+
+// Registry holds the trackers of the carriers that can be tracked, by code
+type Registry map[string]CarrierTracker
+
+// NewRegistry returns a Registry holding the given trackers
+func NewRegistry(trackers ...CarrierTracker) Registry {
+	r := make(Registry, len(trackers))
+	for _, t := range trackers {
+		r[t.Code()] = t
+	}
+	return r
+}
+
+// New returns a Registry of every carrier whose credentials are configured
+func New(cfg config.CarriersConfig) Registry {
+	client := &http.Client{Timeout: cfg.Timeout}
+	var trackers []CarrierTracker
+	if cfg.JNE.Username != "" && cfg.JNE.APIKey != "" {
+		trackers = append(trackers, NewJNE(cfg.JNE, client))
+	}
+	if cfg.JNT.CompanyID != "" && cfg.JNT.Key != "" {
+		trackers = append(trackers, NewJNT(cfg.JNT, client))
+	}
+	if cfg.SiCepat.APIKey != "" {
+		trackers = append(trackers, NewSiCepat(cfg.SiCepat, client))
+	}
+	return NewRegistry(trackers...)
+}
+
+// Codes returns the codes of the carriers in the registry, sorted
+func (r Registry) Codes() []string {
+	codes := make([]string, 0, len(r))
+	for code := range r {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// do sends req and returns the body of a 2xx response
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrUnknownTrackingNumber
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: HTTP %d", req.Method, req.URL.Path, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// parseTime reads a carrier timestamp in one of layouts. Carriers report
+// local time without a zone, which for these couriers is Jakarta time.
+func parseTime(value string, layouts ...string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, jakarta); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised time %q", value)
+}
+
+// jakarta is Western Indonesian Time, which has no daylight saving
+var jakarta = time.FixedZone("WIB", 7*60*60)
+
+// sortEvents orders events oldest first, keeping the carrier's order for
+// events at the same time
+func sortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].OccurredAt.Before(events[j].OccurredAt)
+	})
+}
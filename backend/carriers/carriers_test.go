@@ -0,0 +1,212 @@
+package carriers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ecommerce-backend/config"
+)
+
+// serve answers every request with body after check has looked at it
+func serve(t *testing.T, status int, body string, check func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func wib(s string) time.Time {
+	at, err := time.ParseInLocation("2006-01-02 15:04", s, jakarta)
+	if err != nil {
+		panic(err)
+	}
+	return at
+}
+
+func checkEvents(t *testing.T, got, want []Event) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Status != want[i].Status || got[i].Location != want[i].Location || !got[i].OccurredAt.Equal(want[i].OccurredAt) {
+			t.Errorf("event %d = %s at %s in %q, want %s at %s in %q", i,
+				got[i].Status, got[i].OccurredAt, got[i].Location,
+				want[i].Status, want[i].OccurredAt, want[i].Location)
+		}
+	}
+}
+
+func TestJNE(t *testing.T) {
+	srv := serve(t, http.StatusOK, `{
+		"cnote": {"cnote_no": "JNE0012345678", "pod_status": "DELIVERED"},
+		"history": [
+			{"date": "14-03-2024 10:20", "desc": "DELIVERED TO [BUDI | 14-03-2024 10:20 | BANDUNG]"},
+			{"date": "12-03-2024 17:05", "desc": "SHIPMENT RECEIVED BY JNE COUNTER OFFICER AT [JAKARTA]"},
+			{"date": "13-03-2024 08:00", "desc": "SHIPMENT FORWARDED FROM TRANSIT CITY TO DESTINATION CITY [BANDUNG]"},
+			{"date": "14-03-2024 07:45", "desc": "WITH DELIVERY COURIER [BANDUNG]"}
+		]
+	}`, func(r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/tracing/api/list/v1/cnote/JNE0012345678" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if r.FormValue("username") != "TOKO" || r.FormValue("api_key") != "rahasia" {
+			t.Errorf("credentials = %q / %q", r.FormValue("username"), r.FormValue("api_key"))
+		}
+	})
+	jne := NewJNE(config.JNEConfig{BaseURL: srv.URL, Username: "TOKO", APIKey: "rahasia"}, srv.Client())
+
+	events, err := jne.Track(context.Background(), "JNE0012345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, events, []Event{
+		{Status: StatusPickedUp, Location: "JAKARTA", OccurredAt: wib("2024-03-12 17:05")},
+		{Status: StatusInTransit, Location: "BANDUNG", OccurredAt: wib("2024-03-13 08:00")},
+		{Status: StatusOutForDelivery, Location: "BANDUNG", OccurredAt: wib("2024-03-14 07:45")},
+		{Status: StatusDelivered, Location: "BANDUNG", OccurredAt: wib("2024-03-14 10:20")},
+	})
+}
+
+func TestJNT(t *testing.T) {
+	srv := serve(t, http.StatusOK, `{
+		"awb": "JP1234567890",
+		"history": [
+			{"date_time": "2024-03-12 17:05:00", "city_name": "JAKARTA", "status": "Paket telah diterima oleh J&T", "status_code": 100},
+			{"date_time": "2024-03-13 08:00:00", "city_name": "BANDUNG", "status": "Paket telah sampai di gateway", "status_code": 101},
+			{"date_time": "2024-03-14 07:45:00", "city_name": "BANDUNG", "status": "Paket akan dikirimkan ke alamat penerima", "status_code": 162},
+			{"date_time": "2024-03-14 10:20:00", "city_name": "BANDUNG", "status": "Paket telah diterima", "status_code": 200}
+		]
+	}`, func(r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "TOKO" || pass != "rahasia" {
+			t.Errorf("basic auth = %q / %q", user, pass)
+		}
+	})
+	jnt := NewJNT(config.JNTConfig{BaseURL: srv.URL, CompanyID: "TOKO", Key: "rahasia"}, srv.Client())
+
+	events, err := jnt.Track(context.Background(), "JP1234567890")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, events, []Event{
+		{Status: StatusPickedUp, Location: "JAKARTA", OccurredAt: wib("2024-03-12 17:05")},
+		{Status: StatusInTransit, Location: "BANDUNG", OccurredAt: wib("2024-03-13 08:00")},
+		{Status: StatusOutForDelivery, Location: "BANDUNG", OccurredAt: wib("2024-03-14 07:45")},
+		{Status: StatusDelivered, Location: "BANDUNG", OccurredAt: wib("2024-03-14 10:20")},
+	})
+}
+
+func TestSiCepat(t *testing.T) {
+	srv := serve(t, http.StatusOK, `{"sicepat": {
+		"status": {"code": 200, "description": "OK"},
+		"result": {"waybill_number": "000987654321", "track_history": [
+			{"date_time": "2024-03-12 17:05", "status": "PICKREQ", "city": "Terima permintaan pick up dari [Toko Kopi]"},
+			{"date_time": "2024-03-13 08:00", "status": "IN", "city": "Paket telah di terima di [Bandung]"},
+			{"date_time": "2024-03-14 07:45", "status": "ANT", "city": "Paket dibawa [SIGESIT - Asep]"},
+			{"date_time": "2024-03-14 10:20", "status": "DELIVERED", "receiver_name": "Budi - (Yang bersangkutan)"}
+		]}
+	}}`, func(r *http.Request) {
+		if r.URL.Query().Get("waybill") != "000987654321" || r.Header.Get("api-key") != "rahasia" {
+			t.Errorf("waybill %q api-key %q", r.URL.Query().Get("waybill"), r.Header.Get("api-key"))
+		}
+	})
+	sicepat := NewSiCepat(config.SiCepatConfig{BaseURL: srv.URL, APIKey: "rahasia"}, srv.Client())
+
+	events, err := sicepat.Track(context.Background(), "000987654321")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, events, []Event{
+		{Status: StatusPickedUp, Location: "Toko Kopi", OccurredAt: wib("2024-03-12 17:05")},
+		{Status: StatusInTransit, Location: "Bandung", OccurredAt: wib("2024-03-13 08:00")},
+		{Status: StatusOutForDelivery, Location: "SIGESIT - Asep", OccurredAt: wib("2024-03-14 07:45")},
+		{Status: StatusDelivered, Location: "", OccurredAt: wib("2024-03-14 10:20")},
+	})
+	if events[3].Description != "Budi - (Yang bersangkutan)" {
+		t.Errorf("delivery description = %q, want the receiver", events[3].Description)
+	}
+}
+
+func TestUnknownTrackingNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		tracker func(url string, client *http.Client) CarrierTracker
+		status  int
+		body    string
+	}{
+		{
+			name: "jne",
+			tracker: func(url string, client *http.Client) CarrierTracker {
+				return NewJNE(config.JNEConfig{BaseURL: url}, client)
+			},
+			status: http.StatusOK,
+			body:   `{"status": false, "error": "Cnote No. Tidak Ditemukan."}`,
+		},
+		{
+			name: "jnt",
+			tracker: func(url string, client *http.Client) CarrierTracker {
+				return NewJNT(config.JNTConfig{BaseURL: url}, client)
+			},
+			status: http.StatusOK,
+			body:   `{"error_id": "404", "history": []}`,
+		},
+		{
+			name: "sicepat",
+			tracker: func(url string, client *http.Client) CarrierTracker {
+				return NewSiCepat(config.SiCepatConfig{BaseURL: url}, client)
+			},
+			status: http.StatusOK,
+			body:   `{"sicepat": {"status": {"code": 400, "description": "Data tidak ditemukan"}}}`,
+		},
+		{
+			name: "not found status",
+			tracker: func(url string, client *http.Client) CarrierTracker {
+				return NewSiCepat(config.SiCepatConfig{BaseURL: url}, client)
+			},
+			status: http.StatusNotFound,
+			body:   `{}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serve(t, tt.status, tt.body, nil)
+			_, err := tt.tracker(srv.URL, srv.Client()).Track(context.Background(), "X")
+			if !errors.Is(err, ErrUnknownTrackingNumber) {
+				t.Errorf("error = %v, want ErrUnknownTrackingNumber", err)
+			}
+		})
+	}
+}
+
+func TestServerErrorIsNotUnknown(t *testing.T) {
+	srv := serve(t, http.StatusBadGateway, `upstream timeout`, nil)
+	_, err := NewJNE(config.JNEConfig{BaseURL: srv.URL}, srv.Client()).Track(context.Background(), "X")
+	if err == nil || errors.Is(err, ErrUnknownTrackingNumber) {
+		t.Errorf("error = %v, want a failure other than ErrUnknownTrackingNumber", err)
+	}
+}
+
+func TestNewRegistersConfiguredCarriers(t *testing.T) {
+	cfg := config.Default().Carriers
+	if got := New(cfg).Codes(); len(got) != 0 {
+		t.Errorf("without credentials registered %v, want none", got)
+	}
+
+	cfg.JNE.Username, cfg.JNE.APIKey = "TOKO", "rahasia"
+	cfg.SiCepat.APIKey = "rahasia"
+	got := New(cfg).Codes()
+	if len(got) != 2 || got[0] != "jne" || got[1] != "sicepat" {
+		t.Errorf("registered %v, want [jne sicepat]", got)
+	}
+}
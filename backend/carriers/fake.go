@@ -0,0 +1,53 @@
+package carriers
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake is an in-memory carrier for tests. It answers Track with whatever
+// events were set for the tracking number.
+type Fake struct {
+	mu     sync.Mutex
+	code   string
+	events map[string][]Event
+	calls  map[string]int
+	// Err, when set, is returned by every Track call
+	Err error
+}
+
+// NewFake returns a Fake tracking shipments stored with the given carrier
+// code
+func NewFake(code string) *Fake {
+	return &Fake{code: code, events: make(map[string][]Event), calls: make(map[string]int)}
+}
+
+func (f *Fake) Code() string { return f.code }
+
+// Set replaces the events of a tracking number
+func (f *Fake) Set(trackingNumber string, events ...Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events[trackingNumber] = events
+}
+
+func (f *Fake) Track(ctx context.Context, trackingNumber string) ([]Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[trackingNumber]++
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	events, ok := f.events[trackingNumber]
+	if !ok {
+		return nil, ErrUnknownTrackingNumber
+	}
+	return append([]Event(nil), events...), nil
+}
+
+// Calls returns how many times the tracking number was tracked
+func (f *Fake) Calls(trackingNumber string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[trackingNumber]
+}
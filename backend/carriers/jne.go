@@ -0,0 +1,105 @@
+package carriers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ecommerce-backend/config"
+)
+
+// JNE tracks parcels through JNE's tracing API, which takes the account's
+// username and API key as form fields and returns the history as free text
+type JNE struct {
+	cfg    config.JNEConfig
+	client *http.Client
+}
+
+// NewJNE returns a JNE tracker
+func NewJNE(cfg config.JNEConfig, client *http.Client) *JNE {
+	return &JNE{cfg: cfg, client: client}
+}
+
+func (j *JNE) Code() string { return CodeJNE }
+
+type jneResponse struct {
+	Status  *bool  `json:"status"`
+	Error   string `json:"error"`
+	History []struct {
+		Date string `json:"date"`
+		Desc string `json:"desc"`
+	} `json:"history"`
+}
+
+func (j *JNE) Track(ctx context.Context, trackingNumber string) ([]Event, error) {
+	form := url.Values{"username": {j.cfg.Username}, "api_key": {j.cfg.APIKey}}
+	endpoint := strings.TrimRight(j.cfg.BaseURL, "/") + "/tracing/api/list/v1/cnote/" + url.PathEscape(trackingNumber)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	body, err := do(j.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("jne: %w", err)
+	}
+	var res jneResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("jne: decode response: %w", err)
+	}
+	// Unknown airway bills come back as 200 with status false
+	if res.Status != nil && !*res.Status {
+		return nil, fmt.Errorf("jne: %s: %w", res.Error, ErrUnknownTrackingNumber)
+	}
+
+	events := make([]Event, 0, len(res.History))
+	for _, h := range res.History {
+		at, err := parseTime(h.Date, "02-01-2006 15:04", "02-01-2006 15:04:05")
+		if err != nil {
+			return nil, fmt.Errorf("jne: %w", err)
+		}
+		events = append(events, Event{
+			Status:      jneStatus(h.Desc),
+			Description: strings.TrimSpace(h.Desc),
+			Location:    bracketed(h.Desc),
+			OccurredAt:  at,
+		})
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// jneStatus reads the status from the wording of a JNE history line, e.g.
+// "DELIVERED TO [BUDI | 14-03-2024 10:20 | JAKARTA]"
+func jneStatus(desc string) string {
+	desc = strings.ToUpper(desc)
+	switch {
+	case strings.HasPrefix(desc, "DELIVERED TO"):
+		return StatusDelivered
+	case strings.Contains(desc, "WITH DELIVERY COURIER"):
+		return StatusOutForDelivery
+	case strings.Contains(desc, "UNDELIVERED"), strings.Contains(desc, "RETURN"):
+		return StatusException
+	case strings.Contains(desc, "SHIPMENT RECEIVED BY"), strings.Contains(desc, "PICKED UP"):
+		return StatusPickedUp
+	default:
+		return StatusInTransit
+	}
+}
+
+// bracketed returns the last "|"-separated field inside the square brackets,
+// which JNE uses for the place of a scan, or "" without brackets
+func bracketed(desc string) string {
+	start := strings.IndexByte(desc, '[')
+	end := strings.IndexByte(desc, ']')
+	if start < 0 || end < start {
+		return ""
+	}
+	fields := strings.Split(desc[start+1:end], "|")
+	return strings.TrimSpace(fields[len(fields)-1])
+}
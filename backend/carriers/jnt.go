@@ -0,0 +1,96 @@
+package carriers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ecommerce-backend/config"
+)
+
+// JNT tracks parcels through the J&T Express Indonesia open API, which
+// authenticates with the e-commerce company ID and key and reports numeric
+// status codes
+type JNT struct {
+	cfg    config.JNTConfig
+	client *http.Client
+}
+
+// NewJNT returns a J&T tracker
+func NewJNT(cfg config.JNTConfig, client *http.Client) *JNT {
+	return &JNT{cfg: cfg, client: client}
+}
+
+func (j *JNT) Code() string { return CodeJNT }
+
+type jntResponse struct {
+	ErrorID string `json:"error_id"`
+	History []struct {
+		DateTime   string `json:"date_time"`
+		CityName   string `json:"city_name"`
+		Status     string `json:"status"`
+		StatusCode int    `json:"status_code"`
+	} `json:"history"`
+}
+
+func (j *JNT) Track(ctx context.Context, trackingNumber string) ([]Event, error) {
+	payload, err := json.Marshal(map[string]string{"awb": trackingNumber, "eccompanyid": j.cfg.CompanyID})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := strings.TrimRight(j.cfg.BaseURL, "/") + "/api/tracking"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(j.cfg.CompanyID, j.cfg.Key)
+
+	body, err := do(j.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("jnt: %w", err)
+	}
+	var res jntResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("jnt: decode response: %w", err)
+	}
+	if res.ErrorID != "" && len(res.History) == 0 {
+		return nil, fmt.Errorf("jnt: error %s: %w", res.ErrorID, ErrUnknownTrackingNumber)
+	}
+
+	events := make([]Event, 0, len(res.History))
+	for _, h := range res.History {
+		at, err := parseTime(h.DateTime, "2006-01-02 15:04:05", "2006-01-02 15:04")
+		if err != nil {
+			return nil, fmt.Errorf("jnt: %w", err)
+		}
+		events = append(events, Event{
+			Status:      jntStatus(h.StatusCode),
+			Description: strings.TrimSpace(h.Status),
+			Location:    strings.TrimSpace(h.CityName),
+			OccurredAt:  at,
+		})
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// jntStatus maps J&T status codes: 100 is the pickup, 162 the last mile and
+// 200 the delivery; 400 and up are failed deliveries and returns
+func jntStatus(code int) string {
+	switch {
+	case code == 100:
+		return StatusPickedUp
+	case code == 162:
+		return StatusOutForDelivery
+	case code == 200:
+		return StatusDelivered
+	case code >= 400:
+		return StatusException
+	default:
+		return StatusInTransit
+	}
+}
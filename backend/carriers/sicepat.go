@@ -0,0 +1,108 @@
+package carriers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ecommerce-backend/config"
+)
+
+// SiCepat tracks parcels through SiCepat's waybill API, which takes the API
+// key as a header and reports short status codes such as ANT and DELIVERED
+type SiCepat struct {
+	cfg    config.SiCepatConfig
+	client *http.Client
+}
+
+// NewSiCepat returns a SiCepat tracker
+func NewSiCepat(cfg config.SiCepatConfig, client *http.Client) *SiCepat {
+	return &SiCepat{cfg: cfg, client: client}
+}
+
+func (s *SiCepat) Code() string { return CodeSiCepat }
+
+type sicepatResponse struct {
+	SiCepat struct {
+		Status struct {
+			Code        int    `json:"code"`
+			Description string `json:"description"`
+		} `json:"status"`
+		Result struct {
+			TrackHistory []struct {
+				DateTime     string `json:"date_time"`
+				Status       string `json:"status"`
+				City         string `json:"city"`
+				ReceiverName string `json:"receiver_name"`
+			} `json:"track_history"`
+		} `json:"result"`
+	} `json:"sicepat"`
+}
+
+func (s *SiCepat) Track(ctx context.Context, trackingNumber string) ([]Event, error) {
+	endpoint := strings.TrimRight(s.cfg.BaseURL, "/") + "/customer/waybill?waybill=" + url.QueryEscape(trackingNumber)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("api-key", s.cfg.APIKey)
+	req.Header.Set("Accept", "application/json")
+
+	body, err := do(s.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("sicepat: %w", err)
+	}
+	var res sicepatResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("sicepat: decode response: %w", err)
+	}
+	// The HTTP status is always 200; the envelope carries the real one
+	switch res.SiCepat.Status.Code {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusNotFound:
+		return nil, fmt.Errorf("sicepat: %s: %w", res.SiCepat.Status.Description, ErrUnknownTrackingNumber)
+	default:
+		return nil, fmt.Errorf("sicepat: status %d: %s", res.SiCepat.Status.Code, res.SiCepat.Status.Description)
+	}
+
+	history := res.SiCepat.Result.TrackHistory
+	events := make([]Event, 0, len(history))
+	for _, h := range history {
+		at, err := parseTime(h.DateTime, "2006-01-02 15:04", "2006-01-02 15:04:05")
+		if err != nil {
+			return nil, fmt.Errorf("sicepat: %w", err)
+		}
+		desc := strings.TrimSpace(h.City)
+		if h.ReceiverName != "" {
+			desc = strings.TrimSpace(desc + " " + h.ReceiverName)
+		}
+		events = append(events, Event{
+			Status:      sicepatStatus(h.Status),
+			Description: desc,
+			Location:    bracketed(h.City),
+			OccurredAt:  at,
+		})
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// sicepatStatus maps SiCepat status codes; ANT ("antar") is the last mile
+func sicepatStatus(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	switch {
+	case code == "PICKREQ", code == "PICK":
+		return StatusPickedUp
+	case code == "ANT":
+		return StatusOutForDelivery
+	case code == "DELIVERED":
+		return StatusDelivered
+	case strings.HasPrefix(code, "RETUR"), code == "CANCEL", code == "LOST", code == "BROKE", code == "THP":
+		return StatusException
+	default:
+		return StatusInTransit
+	}
+}
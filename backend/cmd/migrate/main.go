@@ -1,17 +1,150 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
 
+	"ecommerce-backend/config"
 	"ecommerce-backend/database"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/migrations"
+
+	"github.com/joho/godotenv"
 )
 
+const usage = `Usage: migrate [-dir migrations] <command> [args]
+
+Commands:
+  up [N]          apply all pending migrations, or only the next N
+  down [N]        roll back the last N applied migrations (default 1)
+  status          list migrations and whether they are applied
+  create <name>   write a new, blank up/down pair into -dir
+  force <version> record the schema as being at version without running SQL
+`
+
 func main() {
-	log.Println("Starting database migration...")
-	
-	// Connect to database
-	database.ConnectDB()
-	
-	log.Println("Migration completed successfully!")
-	log.Println("Database tables created and sample data seeded!")
+	dir := flag.String("dir", "migrations", "directory new migrations are created in")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command, args := flag.Arg(0), flag.Args()[1:]
+
+	// create only touches the filesystem
+	if command == "create" {
+		if len(args) != 1 {
+			log.Fatal("usage: migrate create <name>")
+		}
+		up, down, err := database.CreateMigration(*dir, args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Created", up)
+		fmt.Println("Created", down)
+		return
+	}
+
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logging.Setup(cfg.Log)
+
+	database.ConnectDB(cfg.Database)
+	defer database.CloseDB()
+
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := database.NewMigrator(sqlDB, migrations.FS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx, intArg(args, 0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		} else {
+			fmt.Printf("Applied %d migration(s): %v\n", len(applied), applied)
+		}
+	case "down":
+		rolledBack, err := migrator.Down(ctx, intArg(args, 1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Rolled back %d migration(s): %v\n", len(rolledBack), rolledBack)
+	case "status":
+		list, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printStatus(list)
+	case "force":
+		if len(args) != 1 {
+			log.Fatal("usage: migrate force <version>")
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || version < 0 {
+			log.Fatalf("invalid version %q", args[0])
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Schema version forced to %d\n", version)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// intArg parses the optional count argument, returning def when it is absent
+func intArg(args []string, def int) int {
+	if len(args) == 0 {
+		return def
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		log.Fatalf("invalid count %q", args[0])
+	}
+	return n
+}
+
+func printStatus(list []database.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range list {
+		status := "pending"
+		switch {
+		case s.Dirty:
+			status = "dirty"
+		case s.Missing:
+			status = "applied (file missing)"
+		case s.Applied:
+			status = "applied"
+		}
+		appliedAt := ""
+		if s.AppliedAt != nil && !s.Dirty {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%06d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+	w.Flush()
 }
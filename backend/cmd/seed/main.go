@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/seed"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Loaded first so SEED_ADMIN_* in .env can provide flag defaults
+	_ = godotenv.Load()
+
+	profile := flag.String("profile", "demo", "profile to seed: minimal, demo or load-test")
+	randomSeed := flag.Int64("seed", 1, "random seed; the same seed and options produce the same data")
+	until := flag.String("until", "", "end of the generated order history as YYYY-MM-DD (default today, UTC)")
+	users := flag.Int("users", 0, "number of customers for load-test (default 5000)")
+	products := flag.Int("products", 0, "number of products for load-test (default 1000)")
+	orders := flag.Int("orders", 0, "number of orders for load-test (default 50000)")
+	months := flag.Int("months", 0, "months of order history for load-test (default 6)")
+	adminEmail := flag.String("admin-email", os.Getenv("SEED_ADMIN_EMAIL"), "admin account email (default SEED_ADMIN_EMAIL)")
+	adminPassword := flag.String("admin-password", os.Getenv("SEED_ADMIN_PASSWORD"), "admin account password (default SEED_ADMIN_PASSWORD); required in production")
+	list := flag.Bool("list", false, "list the available profiles and exit")
+	flag.Parse()
+
+	if *list {
+		descriptions := seed.Profiles()
+		for _, name := range seed.ProfileNames() {
+			fmt.Printf("%-10s %s\n", name, descriptions[name])
+		}
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logging.Setup(cfg.Log)
+
+	opts := seed.Options{
+		Profile:       *profile,
+		Seed:          *randomSeed,
+		Env:           cfg.Env,
+		AdminEmail:    *adminEmail,
+		AdminPassword: *adminPassword,
+		Users:         *users,
+		Products:      *products,
+		Orders:        *orders,
+		Months:        *months,
+	}
+	if *until != "" {
+		opts.Until, err = time.Parse("2006-01-02", *until)
+		if err != nil {
+			log.Fatalf("invalid -until %q: %v", *until, err)
+		}
+	}
+
+	if err := opts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	database.ConnectDB(cfg.Database)
+	err = seed.Run(context.Background(), database.DB, opts)
+	database.CloseDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,537 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce-backend/validation"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Environments the application knows about. Production refuses to start with
+// default secrets.
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// Config is the complete application configuration. Each setting can come
+// from the YAML file (by its yaml key) or the environment (by its env tag);
+// the environment wins. Fields tagged secret are redacted when printed.
+type Config struct {
+	Env       string          `json:"env" yaml:"env" env:"ENV" validate:"oneof=development test staging production"`
+	Server    ServerConfig    `json:"server" yaml:"server"`
+	Database  DatabaseConfig  `json:"database" yaml:"database"`
+	Redis     RedisConfig     `json:"redis" yaml:"redis"`
+	JWT       JWTConfig       `json:"jwt" yaml:"jwt"`
+	Log       LogConfig       `json:"log" yaml:"log"`
+	Cache     CacheConfig     `json:"cache" yaml:"cache"`
+	RateLimit RateLimitConfig `json:"rate_limit" yaml:"rate_limit"`
+	Metrics   MetricsConfig   `json:"metrics" yaml:"metrics"`
+	Tracing   TracingConfig   `json:"tracing" yaml:"tracing"`
+	Carriers  CarriersConfig  `json:"carriers" yaml:"carriers"`
+	Returns   ReturnsConfig   `json:"returns" yaml:"returns"`
+	Jobs      JobsConfig      `json:"jobs" yaml:"jobs"`
+	Payments  PaymentsConfig  `json:"payments" yaml:"payments"`
+	Store     StoreConfig     `json:"store" yaml:"store"`
+	Mail      MailConfig      `json:"mail" yaml:"mail"`
+}
+
+type ServerConfig struct {
+	Port               string        `json:"port" yaml:"port" env:"PORT" validate:"required,numeric"`
+	CORSOrigins        string        `json:"cors_origins" yaml:"cors_origins" env:"CORS_ORIGINS" validate:"required"`
+	HealthCheckTimeout time.Duration `json:"health_check_timeout" yaml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT" validate:"gt=0"`
+	ShutdownTimeout    time.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" validate:"gt=0"`
+	ShutdownDrainDelay time.Duration `json:"shutdown_drain_delay" yaml:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY" validate:"gte=0"`
+}
+
+type DatabaseConfig struct {
+	Host               string        `json:"host" yaml:"host" env:"DB_HOST" validate:"required"`
+	Port               string        `json:"port" yaml:"port" env:"DB_PORT" validate:"required,numeric"`
+	User               string        `json:"user" yaml:"user" env:"DB_USER" validate:"required"`
+	Password           string        `json:"password" yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name               string        `json:"name" yaml:"name" env:"DB_NAME" validate:"required"`
+	SSLMode            string        `json:"sslmode" yaml:"sslmode" env:"DB_SSLMODE" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	SlowQueryThreshold time.Duration `json:"slow_query_threshold" yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD" validate:"gte=0"`
+	LogParams          bool          `json:"log_params" yaml:"log_params" env:"DB_LOG_PARAMS"`
+	// AutoMigrate applies pending migrations on boot, and seeds demo data
+	// outside production
+	AutoMigrate bool `json:"auto_migrate" yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+	// Schema, when set, replaces the server's default search_path, e.g. to
+	// give each integration test run its own tables
+	Schema string `json:"schema" yaml:"schema" env:"DB_SCHEMA"`
+}
+
+// DSN returns the Postgres connection string
+func (d DatabaseConfig) DSN() string {
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode)
+	if d.Schema != "" {
+		dsn += " search_path=" + d.Schema
+	}
+	return dsn
+}
+
+type RedisConfig struct {
+	Host     string `json:"host" yaml:"host" env:"REDIS_HOST" validate:"required"`
+	Port     string `json:"port" yaml:"port" env:"REDIS_PORT" validate:"required,numeric"`
+	Password string `json:"password" yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `json:"db" yaml:"db" env:"REDIS_DB" validate:"gte=0"`
+}
+
+// Addr returns the host:port Redis listens on
+func (r RedisConfig) Addr() string {
+	return r.Host + ":" + r.Port
+}
+
+type JWTConfig struct {
+	Secret string        `json:"secret" yaml:"secret" env:"JWT_SECRET" secret:"true" validate:"required"`
+	TTL    time.Duration `json:"ttl" yaml:"ttl" env:"JWT_EXPIRES_IN" validate:"gt=0"`
+}
+
+type LogConfig struct {
+	Level      string   `json:"level" yaml:"level" env:"LOG_LEVEL" validate:"oneof=debug info warn warning error"`
+	Format     string   `json:"format" yaml:"format" env:"LOG_FORMAT" validate:"oneof=json text"`
+	RedactKeys []string `json:"redact_keys" yaml:"redact_keys" env:"LOG_REDACT_KEYS"`
+}
+
+type CacheConfig struct {
+	Enabled    bool          `json:"enabled" yaml:"enabled" env:"CACHE_ENABLED"`
+	CatalogTTL time.Duration `json:"catalog_ttl" yaml:"catalog_ttl" env:"CACHE_CATALOG_TTL" validate:"gt=0"`
+}
+
+// RateLimitConfig holds one "<limit>/<window>" rate, e.g. "10/1m", and the
+// identity it is counted per (ip, user or api_key) for each route group.
+// Only the APIKeys count as keys; requests with any other key count per IP.
+// The client IP is read from ProxyHeader only on requests whose peer is one
+// of the TrustedProxies; otherwise it is the peer address.
+type RateLimitConfig struct {
+	Enabled     bool     `json:"enabled" yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	Auth        string   `json:"auth" yaml:"auth" env:"RATE_LIMIT_AUTH" validate:"required"`
+	AuthBy      string   `json:"auth_by" yaml:"auth_by" env:"RATE_LIMIT_AUTH_BY" validate:"oneof=ip user api_key"`
+	Catalog     string   `json:"catalog" yaml:"catalog" env:"RATE_LIMIT_CATALOG" validate:"required"`
+	CatalogBy   string   `json:"catalog_by" yaml:"catalog_by" env:"RATE_LIMIT_CATALOG_BY" validate:"oneof=ip user api_key"`
+	Guest       string   `json:"guest" yaml:"guest" env:"RATE_LIMIT_GUEST" validate:"required"`
+	GuestBy     string   `json:"guest_by" yaml:"guest_by" env:"RATE_LIMIT_GUEST_BY" validate:"oneof=ip user api_key"`
+	Protected   string   `json:"protected" yaml:"protected" env:"RATE_LIMIT_PROTECTED" validate:"required"`
+	ProtectedBy string   `json:"protected_by" yaml:"protected_by" env:"RATE_LIMIT_PROTECTED_BY" validate:"oneof=ip user api_key"`
+	APIKeys     []string `json:"api_keys" yaml:"api_keys" env:"RATE_LIMIT_API_KEYS" secret:"true"`
+	// TrustedProxies are the IPs or CIDR ranges of the reverse proxies in
+	// front of the API, e.g. the nginx container
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies" env:"RATE_LIMIT_TRUSTED_PROXIES" validate:"dive,ip|cidr"`
+	ProxyHeader    string   `json:"proxy_header" yaml:"proxy_header" env:"RATE_LIMIT_PROXY_HEADER" validate:"required"`
+}
+
+type MetricsConfig struct {
+	// Token, when set, must be sent as a bearer token to scrape /metrics.
+	// Production requires one.
+	Token string `json:"token" yaml:"token" env:"METRICS_TOKEN" secret:"true"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `json:"exporter" yaml:"exporter" env:"TRACING_EXPORTER" validate:"oneof=stdout otlp none"`
+	SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" validate:"gte=0,lte=1"`
+	ServiceName string  `json:"service_name" yaml:"service_name" env:"OTEL_SERVICE_NAME" validate:"required"`
+}
+
+// CarriersConfig controls shipment tracking. A carrier is only tracked when
+// its credentials are set.
+type CarriersConfig struct {
+	// PollInterval is how often open shipments are checked with their
+	// carrier; 0 turns polling off
+	PollInterval time.Duration `json:"poll_interval" yaml:"poll_interval" env:"CARRIERS_POLL_INTERVAL" validate:"gte=0"`
+	PollBatch    int           `json:"poll_batch" yaml:"poll_batch" env:"CARRIERS_POLL_BATCH" validate:"gt=0"`
+	Timeout      time.Duration `json:"timeout" yaml:"timeout" env:"CARRIERS_TIMEOUT" validate:"gt=0"`
+	// WebhookSecret signs pushed tracking updates; empty turns the webhook off
+	WebhookSecret string        `json:"webhook_secret" yaml:"webhook_secret" env:"CARRIERS_WEBHOOK_SECRET" secret:"true"`
+	JNE           JNEConfig     `json:"jne" yaml:"jne"`
+	JNT           JNTConfig     `json:"jnt" yaml:"jnt"`
+	SiCepat       SiCepatConfig `json:"sicepat" yaml:"sicepat"`
+}
+
+type JNEConfig struct {
+	BaseURL  string `json:"base_url" yaml:"base_url" env:"JNE_BASE_URL" validate:"required,url"`
+	Username string `json:"username" yaml:"username" env:"JNE_USERNAME"`
+	APIKey   string `json:"api_key" yaml:"api_key" env:"JNE_API_KEY" secret:"true"`
+}
+
+type JNTConfig struct {
+	BaseURL   string `json:"base_url" yaml:"base_url" env:"JNT_BASE_URL" validate:"required,url"`
+	CompanyID string `json:"company_id" yaml:"company_id" env:"JNT_COMPANY_ID"`
+	Key       string `json:"key" yaml:"key" env:"JNT_KEY" secret:"true"`
+}
+
+type SiCepatConfig struct {
+	BaseURL string `json:"base_url" yaml:"base_url" env:"SICEPAT_BASE_URL" validate:"required,url"`
+	APIKey  string `json:"api_key" yaml:"api_key" env:"SICEPAT_API_KEY" secret:"true"`
+}
+
+type ReturnsConfig struct {
+	// Window is how long after delivery customers can request a return
+	Window    time.Duration `json:"window" yaml:"window" env:"RETURN_WINDOW" validate:"gt=0"`
+	MaxPhotos int           `json:"max_photos" yaml:"max_photos" env:"RETURN_MAX_PHOTOS" validate:"gte=0"`
+}
+
+// JobsConfig controls the background worker that runs bulk admin jobs
+type JobsConfig struct {
+	// PollInterval is how often the worker looks for queued jobs; 0 turns
+	// the worker off
+	PollInterval time.Duration `json:"poll_interval" yaml:"poll_interval" env:"JOBS_POLL_INTERVAL" validate:"gte=0"`
+	// MaxOrders is the most orders one bulk job can cover
+	MaxOrders int `json:"max_orders" yaml:"max_orders" env:"JOBS_MAX_ORDERS" validate:"gt=0"`
+}
+
+// PaymentsConfig connects the payment provider refunds are sent through. It
+// is only used once its credentials are set; until then refunds are recorded
+// as manual bank transfers.
+type PaymentsConfig struct {
+	Timeout  time.Duration  `json:"timeout" yaml:"timeout" env:"PAYMENTS_TIMEOUT" validate:"gt=0"`
+	Midtrans MidtransConfig `json:"midtrans" yaml:"midtrans"`
+}
+
+type MidtransConfig struct {
+	BaseURL   string `json:"base_url" yaml:"base_url" env:"MIDTRANS_BASE_URL" validate:"required,url"`
+	ServerKey string `json:"server_key" yaml:"server_key" env:"MIDTRANS_SERVER_KEY" secret:"true"`
+}
+
+// StoreConfig is the seller's details printed on invoices and packing slips,
+// and the storefront address links in customer emails point to
+type StoreConfig struct {
+	Name    string `json:"name" yaml:"name" env:"STORE_NAME" validate:"required"`
+	URL     string `json:"url" yaml:"url" env:"STORE_URL" validate:"required,url"`
+	Address string `json:"address" yaml:"address" env:"STORE_ADDRESS"`
+	Email   string `json:"email" yaml:"email" env:"STORE_EMAIL" validate:"omitempty,email"`
+	Phone   string `json:"phone" yaml:"phone" env:"STORE_PHONE"`
+	// TaxID is the seller's NPWP, printed on invoices when set
+	TaxID string `json:"tax_id" yaml:"tax_id" env:"STORE_TAX_ID"`
+}
+
+// MailConfig is the SMTP server customer emails are sent through. Until a
+// host is set, emails are written to the log instead.
+type MailConfig struct {
+	Host     string        `json:"host" yaml:"host" env:"SMTP_HOST"`
+	Port     string        `json:"port" yaml:"port" env:"SMTP_PORT" validate:"required,numeric"`
+	Username string        `json:"username" yaml:"username" env:"SMTP_USERNAME"`
+	Password string        `json:"password" yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string        `json:"from" yaml:"from" env:"MAIL_FROM" validate:"required,email"`
+	FromName string        `json:"from_name" yaml:"from_name" env:"MAIL_FROM_NAME"`
+	Timeout  time.Duration `json:"timeout" yaml:"timeout" env:"SMTP_TIMEOUT" validate:"gt=0"`
+}
+
+// Default returns the configuration used for local development
+func Default() Config {
+	return Config{
+		Env: EnvDevelopment,
+		Server: ServerConfig{
+			Port:               "8080",
+			CORSOrigins:        "http://localhost:5173,http://localhost:5174",
+			HealthCheckTimeout: 2 * time.Second,
+			ShutdownTimeout:    30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:               "localhost",
+			Port:               "5432",
+			User:               "postgres",
+			Password:           "1337",
+			Name:               "db_ecommerce",
+			SSLMode:            "disable",
+			SlowQueryThreshold: 200 * time.Millisecond,
+		},
+		Redis: RedisConfig{
+			Host: "localhost",
+			Port: "6379",
+		},
+		JWT: JWTConfig{
+			Secret: "your-super-secret-jwt-key",
+			TTL:    7 * 24 * time.Hour,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Cache: CacheConfig{
+			Enabled:    true,
+			CatalogTTL: 5 * time.Minute,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:     true,
+			Auth:        "10/1m",
+			AuthBy:      "ip",
+			Catalog:     "120/1m",
+			CatalogBy:   "ip",
+			Guest:       "30/1m",
+			GuestBy:     "ip",
+			Protected:   "300/1m",
+			ProtectedBy: "user",
+			ProxyHeader: "X-Forwarded-For",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "stdout",
+			SampleRatio: 1,
+			ServiceName: "ecommerce-backend",
+		},
+		Carriers: CarriersConfig{
+			PollInterval: 15 * time.Minute,
+			PollBatch:    50,
+			Timeout:      10 * time.Second,
+			JNE:          JNEConfig{BaseURL: "https://apiv2.jne.co.id:10205"},
+			JNT:          JNTConfig{BaseURL: "https://openapi.jet.co.id"},
+			SiCepat:      SiCepatConfig{BaseURL: "https://api.sicepat.com"},
+		},
+		Returns: ReturnsConfig{
+			Window:    7 * 24 * time.Hour,
+			MaxPhotos: 5,
+		},
+		Jobs: JobsConfig{
+			PollInterval: 5 * time.Second,
+			MaxOrders:    2000,
+		},
+		Payments: PaymentsConfig{
+			Timeout:  15 * time.Second,
+			Midtrans: MidtransConfig{BaseURL: "https://api.sandbox.midtrans.com"},
+		},
+		Store: StoreConfig{
+			Name: "E-Commerce Store",
+			URL:  "http://localhost:5173",
+		},
+		Mail: MailConfig{
+			Port:     "587",
+			From:     "no-reply@example.com",
+			FromName: "E-Commerce Store",
+			Timeout:  10 * time.Second,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, then the YAML file named
+// by CONFIG_FILE (or ./config.yaml when present), then the environment, and
+// validates the result. Call it after .env has been loaded into the
+// environment.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat("config.yaml"); err == nil {
+			path = "config.yaml"
+		}
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// applyEnv overrides every field that has an env tag and a non-empty value
+// in the environment
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		raw := os.Getenv(key)
+		if key == "" || raw == "" {
+			continue
+		}
+
+		switch {
+		case field.Type == reflect.TypeOf(time.Duration(0)):
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("%s: invalid duration %q", key, raw)
+			}
+			value.SetInt(int64(d))
+		case field.Type.Kind() == reflect.String:
+			value.SetString(raw)
+		case field.Type.Kind() == reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("%s: invalid integer %q", key, raw)
+			}
+			value.SetInt(int64(n))
+		case field.Type.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("%s: invalid boolean %q", key, raw)
+			}
+			value.SetBool(b)
+		case field.Type.Kind() == reflect.Float64:
+			f, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("%s: invalid number %q", key, raw)
+			}
+			value.SetFloat(f)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String:
+			var items []string
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			value.Set(reflect.ValueOf(items))
+		default:
+			return fmt.Errorf("%s: unsupported config type %s", key, field.Type)
+		}
+	}
+	return nil
+}
+
+// insecureSecrets are the well-known development values that must never be
+// used in production
+var insecureSecrets = map[string]bool{
+	"":                          true,
+	"1337":                      true,
+	"postgres":                  true,
+	"password":                  true,
+	"secret":                    true,
+	"changeme":                  true,
+	"admin123":                  true,
+	"your-super-secret-jwt-key": true,
+	"your-super-secret-jwt-key-change-this-in-production": true,
+}
+
+// minJWTSecretLength is the shortest JWT secret accepted in production
+const minJWTSecretLength = 32
+
+// Validate checks field rules and, in production, rejects default secrets
+func (c *Config) Validate() error {
+	fields := validation.FieldErrors{}
+	if err := validation.Struct(c); err != nil {
+		var invalid validation.FieldErrors
+		if !errors.As(err, &invalid) {
+			return err
+		}
+		fields = invalid
+	}
+
+	rates := map[string]string{
+		"rate_limit.auth":      c.RateLimit.Auth,
+		"rate_limit.catalog":   c.RateLimit.Catalog,
+		"rate_limit.guest":     c.RateLimit.Guest,
+		"rate_limit.protected": c.RateLimit.Protected,
+	}
+	for field, spec := range rates {
+		if _, _, err := ParseRate(spec); err != nil && fields[field] == "" {
+			fields[field] = err.Error()
+		}
+	}
+
+	if c.Env == EnvProduction {
+		if insecureSecrets[c.JWT.Secret] || len(c.JWT.Secret) < minJWTSecretLength {
+			fields["jwt.secret"] = fmt.Sprintf("must be set to a random value of at least %d characters in production", minJWTSecretLength)
+		}
+		if insecureSecrets[c.Database.Password] {
+			fields["database.password"] = "must not be empty or a default password in production"
+		}
+		if insecureSecrets[c.Metrics.Token] {
+			fields["metrics.token"] = "must be set in production so that /metrics is not public"
+		}
+	}
+
+	if len(fields) > 0 {
+		return fmt.Errorf("invalid configuration: %w", fields)
+	}
+	return nil
+}
+
+// UsesDefaultSecrets reports whether any secret still has a development value
+func (c *Config) UsesDefaultSecrets() bool {
+	return insecureSecrets[c.JWT.Secret] || insecureSecrets[c.Database.Password]
+}
+
+// ParseRate parses a "<limit>/<window>" rate such as "10/1m"
+func ParseRate(spec string) (int, time.Duration, error) {
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected <limit>/<window>, got %q", spec)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || limit <= 0 {
+		return 0, 0, fmt.Errorf("invalid limit %q", parts[0])
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || window <= 0 {
+		return 0, 0, fmt.Errorf("invalid window %q", parts[1])
+	}
+	return limit, window, nil
+}
+
+// Redacted flattens the configuration into dotted yaml keys with every
+// non-empty secret replaced, for logging the effective configuration
+func (c *Config) Redacted() map[string]string {
+	out := make(map[string]string)
+	flatten(reflect.ValueOf(*c), "", out)
+	return out
+}
+
+func flatten(v reflect.Value, prefix string, out map[string]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		key := strings.SplitN(field.Tag.Get("yaml"), ",", 2)[0]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			flatten(value, key, out)
+		case field.Tag.Get("secret") == "true" && !value.IsZero():
+			out[key] = "[REDACTED]"
+		case field.Type.Kind() == reflect.Slice:
+			parts := make([]string, value.Len())
+			for j := range parts {
+				parts[j] = fmt.Sprint(value.Index(j).Interface())
+			}
+			out[key] = strings.Join(parts, ",")
+		default:
+			out[key] = fmt.Sprint(value.Interface())
+		}
+	}
+}
+
+// String renders the redacted configuration as sorted key = value lines
+func (c *Config) String() string {
+	values := c.Redacted()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s = %s\n", key, values[key])
+	}
+	return b.String()
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/validation"
+)
+
+// production returns the defaults for production with real secrets
+func production() Config {
+	cfg := Default()
+	cfg.Env = EnvProduction
+	cfg.JWT.Secret = strings.Repeat("k", minJWTSecretLength)
+	cfg.Database.Password = "s3cure-db-passw0rd"
+	cfg.Metrics.Token = "scrape-token"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        func() Config
+		wantFields []string
+	}{
+		{name: "defaults", cfg: Default},
+		{name: "production with secrets", cfg: production},
+		{
+			name:       "unknown environment",
+			cfg:        func() Config { cfg := Default(); cfg.Env = "prod"; return cfg },
+			wantFields: []string{"env"},
+		},
+		{
+			name: "bad rates",
+			cfg: func() Config {
+				cfg := Default()
+				cfg.RateLimit.Auth = "ten per minute"
+				cfg.RateLimit.Guest = "0/1m"
+				cfg.RateLimit.Catalog = ""
+				return cfg
+			},
+			wantFields: []string{"rate_limit.auth", "rate_limit.catalog", "rate_limit.guest"},
+		},
+		{
+			name:       "bad limit identity",
+			cfg:        func() Config { cfg := Default(); cfg.RateLimit.GuestBy = "email"; return cfg },
+			wantFields: []string{"rate_limit.guest_by"},
+		},
+		{
+			name: "trusted proxies",
+			cfg: func() Config {
+				cfg := Default()
+				cfg.RateLimit.TrustedProxies = []string{"172.16.0.0/12", "10.0.0.5", "nginx"}
+				return cfg
+			},
+			wantFields: []string{"rate_limit.trusted_proxies[2]"},
+		},
+		{
+			name: "default secrets in production",
+			cfg: func() Config {
+				cfg := production()
+				cfg.JWT.Secret = "your-super-secret-jwt-key"
+				cfg.Database.Password = "1337"
+				cfg.Metrics.Token = ""
+				return cfg
+			},
+			wantFields: []string{"database.password", "jwt.secret", "metrics.token"},
+		},
+		{
+			name:       "short jwt secret in production",
+			cfg:        func() Config { cfg := production(); cfg.JWT.Secret = "k9Xq2"; return cfg },
+			wantFields: []string{"jwt.secret"},
+		},
+		{
+			// Only production rejects the well-known values
+			name:       "default secrets in staging",
+			cfg:        func() Config { cfg := Default(); cfg.Env = EnvStaging; return cfg },
+			wantFields: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg()
+			err := cfg.Validate()
+
+			var fields validation.FieldErrors
+			if err != nil && !errors.As(err, &fields) {
+				t.Fatalf("Validate() = %v, want field errors", err)
+			}
+			var got []string
+			for field := range fields {
+				got = append(got, field)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("invalid fields = %v, want %v (%v)", got, tt.wantFields, err)
+			}
+		})
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantLimit  int
+		wantWindow time.Duration
+		wantErr    bool
+	}{
+		{spec: "10/1m", wantLimit: 10, wantWindow: time.Minute},
+		{spec: " 300 / 1h ", wantLimit: 300, wantWindow: time.Hour},
+		{spec: "5/30s", wantLimit: 5, wantWindow: 30 * time.Second},
+		{spec: "10", wantErr: true},
+		{spec: "", wantErr: true},
+		{spec: "ten/1m", wantErr: true},
+		{spec: "0/1m", wantErr: true},
+		{spec: "-1/1m", wantErr: true},
+		{spec: "10/minute", wantErr: true},
+		{spec: "10/0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			limit, window, err := ParseRate(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRate(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			}
+			if limit != tt.wantLimit || window != tt.wantWindow {
+				t.Errorf("ParseRate(%q) = %d, %v, want %d, %v", tt.spec, limit, window, tt.wantLimit, tt.wantWindow)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		env     map[string]string
+		check   func(t *testing.T, cfg *Config)
+		wantErr string
+	}{
+		{
+			name: "file over defaults",
+			yaml: "server:\n  port: \"9000\"\nrate_limit:\n  trusted_proxies: [\"10.0.0.0/8\"]\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != "9000" || cfg.Database.Host != "localhost" {
+					t.Errorf("port %s and database host %s, want 9000 and the default", cfg.Server.Port, cfg.Database.Host)
+				}
+				if !reflect.DeepEqual(cfg.RateLimit.TrustedProxies, []string{"10.0.0.0/8"}) {
+					t.Errorf("trusted proxies = %v", cfg.RateLimit.TrustedProxies)
+				}
+			},
+		},
+		{
+			name: "environment over file",
+			yaml: "server:\n  port: \"9000\"\n",
+			env: map[string]string{
+				"PORT":                       "9100",
+				"CACHE_ENABLED":              "false",
+				"CACHE_CATALOG_TTL":          "90s",
+				"CARRIERS_POLL_BATCH":        "25",
+				"TRACING_SAMPLE_RATIO":       "0.25",
+				"RATE_LIMIT_TRUSTED_PROXIES": " 10.0.0.1, ,172.16.0.0/12 ",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != "9100" || cfg.Cache.Enabled || cfg.Cache.CatalogTTL != 90*time.Second {
+					t.Errorf("server %+v, cache %+v", cfg.Server, cfg.Cache)
+				}
+				if cfg.Carriers.PollBatch != 25 || cfg.Tracing.SampleRatio != 0.25 {
+					t.Errorf("poll batch %d, sample ratio %v", cfg.Carriers.PollBatch, cfg.Tracing.SampleRatio)
+				}
+				if !reflect.DeepEqual(cfg.RateLimit.TrustedProxies, []string{"10.0.0.1", "172.16.0.0/12"}) {
+					t.Errorf("trusted proxies = %v", cfg.RateLimit.TrustedProxies)
+				}
+			},
+		},
+		{name: "unknown file key", yaml: "server:\n  prot: \"9000\"\n", wantErr: "parse config file"},
+		{name: "bad duration", env: map[string]string{"CACHE_CATALOG_TTL": "5"}, wantErr: "CACHE_CATALOG_TTL: invalid duration"},
+		{name: "bad boolean", env: map[string]string{"CACHE_ENABLED": "sometimes"}, wantErr: "CACHE_ENABLED: invalid boolean"},
+		{name: "invalid result", env: map[string]string{"RATE_LIMIT_AUTH": "10"}, wantErr: "rate_limit.auth"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("CONFIG_FILE", path)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := production()
+	cfg.RateLimit.APIKeys = []string{"partner-key"}
+	cfg.Payments.Midtrans.ServerKey = ""
+	cfg.RateLimit.TrustedProxies = []string{"10.0.0.1", "10.0.0.2"}
+
+	got := cfg.Redacted()
+	want := map[string]string{
+		"jwt.secret":                   "[REDACTED]",
+		"database.password":            "[REDACTED]",
+		"metrics.token":                "[REDACTED]",
+		"rate_limit.api_keys":          "[REDACTED]",
+		"payments.midtrans.server_key": "",
+		"rate_limit.trusted_proxies":   "10.0.0.1,10.0.0.2",
+		"server.port":                  "8080",
+		"cache.catalog_ttl":            "5m0s",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q", key, got[key], value)
+		}
+	}
+	if s := cfg.String(); strings.Contains(s, cfg.JWT.Secret) || !strings.Contains(s, "server.port = 8080\n") {
+		t.Errorf("String() leaks a secret or misses a setting:\n%s", s)
+	}
+}
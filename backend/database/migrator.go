@@ -0,0 +1,448 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce-backend/migrations"
+)
+
+// migrationsTable records every applied version. A dirty row means the
+// migration started but was not confirmed as finished, and has to be
+// resolved by hand before anything else runs.
+const migrationsTable = "schema_migrations"
+
+// migrationLockID is the Postgres advisory lock held while migrating, so two
+// instances started with -migrate do not race each other
+const migrationLockID = 7262013
+
+// noTransaction is a directive that may appear on the first line of a
+// migration file to run it outside a transaction, which statements such as
+// CREATE INDEX CONCURRENTLY require
+const noTransaction = "-- migrate:no-transaction"
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one schema version with its up and down SQL
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+
+	hasUp bool
+}
+
+// MigrationStatus describes one version for the status command. A version
+// recorded in the database without a matching file is reported as missing.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	Dirty     bool
+	Missing   bool
+	AppliedAt *time.Time
+}
+
+// ErrDirty is returned when a migration was left half applied
+var ErrDirty = errors.New("database is dirty; fix the schema by hand, then run migrate force <version>")
+
+// Migrator applies and rolls back versioned SQL migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator reads the migrations in fsys and returns a migrator for db
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	list, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: list}, nil
+}
+
+// Migrate applies every pending embedded migration using the connection
+// opened by ConnectDB
+func Migrate(ctx context.Context) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	m, err := NewMigrator(sqlDB, migrations.FS)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(ctx, 0)
+	return err
+}
+
+// LoadMigrations parses the migration files in fsys, sorted by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 000001_add_things.up.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up, m.hasUp = string(body), true
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if !m.hasUp {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Up applies pending migrations in version order, at most limit of them when
+// limit is positive. It returns the versions it applied.
+func (m *Migrator) Up(ctx context.Context, limit int) ([]int64, error) {
+	var done []int64
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if limit > 0 && len(done) >= limit {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			start := time.Now()
+			if err := run(ctx, conn, mig.Up, func(exec execer) error {
+				_, err := exec.ExecContext(ctx,
+					"INSERT INTO "+migrationsTable+" (version, name, dirty, applied_at) VALUES ($1, $2, FALSE, NOW()) "+
+						"ON CONFLICT (version) DO UPDATE SET dirty = FALSE, applied_at = NOW()",
+					mig.Version, mig.Name)
+				return err
+			}, mig); err != nil {
+				return fmt.Errorf("apply migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			slog.InfoContext(ctx, "Migration applied", "version", mig.Version, "name", mig.Name,
+				"duration_ms", time.Since(start).Milliseconds())
+			done = append(done, mig.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the n most recently applied migrations and returns their
+// versions
+func (m *Migrator) Down(ctx context.Context, n int) ([]int64, error) {
+	if n <= 0 {
+		return nil, errors.New("number of migrations to roll back must be positive")
+	}
+
+	byVersion := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		byVersion[mig.Version] = mig
+	}
+
+	var done []int64
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if len(done) >= n {
+				break
+			}
+			mig, ok := byVersion[version]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but its files are missing", version)
+			}
+			if strings.TrimSpace(mig.Down) == "" {
+				return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+			}
+			start := time.Now()
+			if err := run(ctx, conn, mig.Down, func(exec execer) error {
+				_, err := exec.ExecContext(ctx, "DELETE FROM "+migrationsTable+" WHERE version = $1", mig.Version)
+				return err
+			}, mig); err != nil {
+				return fmt.Errorf("roll back migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			slog.InfoContext(ctx, "Migration rolled back", "version", mig.Version, "name", mig.Name,
+				"duration_ms", time.Since(start).Milliseconds())
+			done = append(done, mig.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Force records the schema as being exactly at version, without running any
+// SQL: known versions up to it are marked applied and clean, later ones are
+// forgotten. Use it to clear a dirty state once the schema has been repaired,
+// or to baseline a database created before versioned migrations. Version 0
+// forgets everything.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && !m.known(version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+migrationsTable+" WHERE version > $1", version); err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO "+migrationsTable+" (version, name, dirty, applied_at) VALUES ($1, $2, FALSE, NOW()) "+
+					"ON CONFLICT (version) DO UPDATE SET dirty = FALSE",
+				mig.Version, mig.Name); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+// Status lists every known migration and whether it has been applied, plus
+// any applied version whose files no longer exist
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, dirty, applied_at FROM "+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recorded := make(map[int64]MigrationStatus)
+	for rows.Next() {
+		var s MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&s.Version, &s.Name, &s.Dirty, &appliedAt); err != nil {
+			return nil, err
+		}
+		s.Applied = !s.Dirty
+		s.AppliedAt = &appliedAt
+		recorded[s.Version] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	list := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s, ok := recorded[mig.Version]
+		if !ok {
+			s = MigrationStatus{Version: mig.Version}
+		}
+		s.Name = mig.Name
+		list = append(list, s)
+		delete(recorded, mig.Version)
+	}
+	for _, s := range recorded {
+		s.Missing = true
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func (m *Migrator) known(version int64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// locked runs fn on a single connection holding the migration advisory lock,
+// after making sure the schema table exists and nothing is dirty
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+migrationsTable+` (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		dirty      BOOLEAN NOT NULL DEFAULT FALSE,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", migrationsTable, err)
+	}
+	return nil
+}
+
+// appliedVersions returns the recorded versions, failing with ErrDirty when
+// any of them is dirty
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]struct{}, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, dirty FROM "+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]struct{})
+	for rows.Next() {
+		var version int64
+		var dirty bool
+		if err := rows.Scan(&version, &dirty); err != nil {
+			return nil, err
+		}
+		if dirty {
+			return nil, fmt.Errorf("version %d: %w", version, ErrDirty)
+		}
+		applied[version] = struct{}{}
+	}
+	return applied, rows.Err()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// run executes body and then record. Normally both happen in one
+// transaction, so a failure leaves nothing behind. Migrations marked
+// no-transaction are first recorded as dirty and only marked clean once the
+// body succeeds.
+func run(ctx context.Context, conn *sql.Conn, body string, record func(execer) error, mig Migration) error {
+	if !strings.HasPrefix(strings.TrimSpace(body), noTransaction) {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, body); err != nil {
+			return err
+		}
+		if err := record(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if _, err := conn.ExecContext(ctx,
+		"INSERT INTO "+migrationsTable+" (version, name, dirty, applied_at) VALUES ($1, $2, TRUE, NOW()) "+
+			"ON CONFLICT (version) DO UPDATE SET dirty = TRUE",
+		mig.Version, mig.Name); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, body); err != nil {
+		return fmt.Errorf("%w (version %d left dirty)", err, mig.Version)
+	}
+	return record(conn)
+}
+
+// CreateMigration writes a blank up/down pair for name into dir, numbered
+// one past the highest existing version, and returns the two paths
+func CreateMigration(dir, name string) (string, string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("migration name must contain letters or digits")
+	}
+
+	existing, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	var version int64 = 1
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%06d_%s", version, name))
+	up, down := base+".up.sql", base+".down.sql"
+	for path, direction := range map[string]string{up: "up", down: "down"} {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+		_, err = fmt.Fprintf(f, "-- %s migration for %06d_%s\n", direction, version, name)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return up, down, nil
+}
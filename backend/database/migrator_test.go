@@ -0,0 +1,299 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"ecommerce-backend/migrations"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func file(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name         string
+		fsys         fstest.MapFS
+		wantVersions []int64
+		wantErr      string
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"000010_later.up.sql":    file("SELECT 10"),
+				"000002_second.up.sql":   file("SELECT 2"),
+				"000002_second.down.sql": file("SELECT -2"),
+				"000001_first.up.sql":    file("SELECT 1"),
+				"README.md":              file("not a migration"),
+			},
+			wantVersions: []int64{1, 2, 10},
+		},
+		{name: "empty", fsys: fstest.MapFS{}, wantVersions: []int64{}},
+		{
+			name:    "bad name",
+			fsys:    fstest.MapFS{"1-first.up.sql": file("SELECT 1")},
+			wantErr: "name must look like",
+		},
+		{
+			name:    "down without up",
+			fsys:    fstest.MapFS{"000001_first.down.sql": file("SELECT 1")},
+			wantErr: "has no up file",
+		},
+		{
+			name: "version used twice",
+			fsys: fstest.MapFS{
+				"000001_first.up.sql": file("SELECT 1"),
+				"000001_other.up.sql": file("SELECT 1"),
+			},
+			wantErr: "is used by both",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := LoadMigrations(tt.fsys)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadMigrations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			versions := make([]int64, 0, len(list))
+			for _, m := range list {
+				versions = append(versions, m.Version)
+			}
+			if !reflect.DeepEqual(versions, tt.wantVersions) {
+				t.Errorf("versions = %v, want %v", versions, tt.wantVersions)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range list {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d_%s follows version %d, want versions without gaps", m.Version, m.Name, i)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d_%s cannot be rolled back", m.Version, m.Name)
+		}
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "000007_orders.up.sql"), []byte("SELECT 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	up, down, err := CreateMigration(dir, "Add Gift-Wrap options!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(up) != "000008_add_gift_wrap_options.up.sql" || filepath.Base(down) != "000008_add_gift_wrap_options.down.sql" {
+		t.Errorf("created %s and %s", up, down)
+	}
+	if _, _, err := CreateMigration(dir, "!!!"); err == nil {
+		t.Error("created a migration without a name")
+	}
+}
+
+// testDB returns a connection to TEST_DATABASE_URL whose search path is a
+// fresh schema, dropped when the test ends
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	if testing.Short() {
+		t.Skip("integration test skipped in short mode")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := "mig_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("create schema %s: %v", schema, err)
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	db, err := sql.Open("pgx", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		defer admin.Close()
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Logf("drop schema %s: %v", schema, err)
+		}
+	})
+	return db
+}
+
+// applied returns the clean versions recorded, and the dirty ones
+func applied(t *testing.T, m *Migrator) (clean, dirty []int64) {
+	t.Helper()
+	list, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, dirty = []int64{}, []int64{}
+	for _, s := range list {
+		switch {
+		case s.Dirty:
+			dirty = append(dirty, s.Version)
+		case s.Applied:
+			clean = append(clean, s.Version)
+		}
+	}
+	return clean, dirty
+}
+
+var widgetMigrations = fstest.MapFS{
+	"000001_widgets.up.sql":        file("CREATE TABLE widgets (id SERIAL PRIMARY KEY)"),
+	"000001_widgets.down.sql":      file("DROP TABLE widgets"),
+	"000002_widget_names.up.sql":   file("ALTER TABLE widgets ADD COLUMN name TEXT"),
+	"000002_widget_names.down.sql": file("ALTER TABLE widgets DROP COLUMN name"),
+	"000003_widget_index.up.sql":   file(noTransaction + "\nCREATE INDEX CONCURRENTLY widgets_name ON widgets (name)"),
+	"000003_widget_index.down.sql": file("DROP INDEX widgets_name"),
+}
+
+func TestMigrator(t *testing.T) {
+	db := testDB(t)
+	m, err := NewMigrator(db, widgetMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Each step runs against the state the previous one left
+	steps := []struct {
+		name        string
+		run         func() ([]int64, error)
+		wantDone    []int64
+		wantApplied []int64
+	}{
+		{name: "up one", run: func() ([]int64, error) { return m.Up(ctx, 1) }, wantDone: []int64{1}, wantApplied: []int64{1}},
+		{name: "up the rest", run: func() ([]int64, error) { return m.Up(ctx, 0) }, wantDone: []int64{2, 3}, wantApplied: []int64{1, 2, 3}},
+		{name: "up to date", run: func() ([]int64, error) { return m.Up(ctx, 0) }, wantApplied: []int64{1, 2, 3}},
+		{name: "down two", run: func() ([]int64, error) { return m.Down(ctx, 2) }, wantDone: []int64{3, 2}, wantApplied: []int64{1}},
+		{name: "up again", run: func() ([]int64, error) { return m.Up(ctx, 0) }, wantDone: []int64{2, 3}, wantApplied: []int64{1, 2, 3}},
+		{name: "force back", run: func() ([]int64, error) { return nil, m.Force(ctx, 1) }, wantApplied: []int64{1}},
+		{name: "force forward", run: func() ([]int64, error) { return nil, m.Force(ctx, 3) }, wantApplied: []int64{1, 2, 3}},
+		{name: "down all", run: func() ([]int64, error) { return m.Down(ctx, 5) }, wantDone: []int64{3, 2, 1}, wantApplied: []int64{}},
+	}
+	for _, step := range steps {
+		done, err := step.run()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if len(done) != len(step.wantDone) || (len(done) > 0 && !reflect.DeepEqual(done, step.wantDone)) {
+			t.Errorf("%s: ran %v, want %v", step.name, done, step.wantDone)
+		}
+		if clean, _ := applied(t, m); !reflect.DeepEqual(clean, step.wantApplied) {
+			t.Errorf("%s: applied %v, want %v", step.name, clean, step.wantApplied)
+		}
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass('widgets') IS NOT NULL").Scan(&exists); err != nil || exists {
+		t.Errorf("widgets table exists = %v (%v) after rolling everything back", exists, err)
+	}
+	if err := m.Force(ctx, 9); err == nil {
+		t.Error("forced an unknown version")
+	}
+	if _, err := m.Down(ctx, 0); err == nil {
+		t.Error("rolled back zero migrations")
+	}
+}
+
+func TestMigratorFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		second    string
+		wantDirty []int64
+	}{
+		{
+			// The failed body and its record are rolled back together
+			name:      "in a transaction",
+			second:    "CREATE TABLE gadgets (id INT); SELECT 1/0",
+			wantDirty: []int64{},
+		},
+		{
+			name:      "outside a transaction",
+			second:    noTransaction + "\nSELECT 1/0",
+			wantDirty: []int64{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			m, err := NewMigrator(db, fstest.MapFS{
+				"000001_widgets.up.sql":   file("CREATE TABLE widgets (id SERIAL PRIMARY KEY)"),
+				"000001_widgets.down.sql": file("DROP TABLE widgets"),
+				"000002_broken.up.sql":    file(tt.second),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+
+			done, err := m.Up(ctx, 0)
+			if err == nil || !reflect.DeepEqual(done, []int64{1}) {
+				t.Fatalf("Up() = %v, %v, want only 1 applied and an error", done, err)
+			}
+			clean, dirty := applied(t, m)
+			if !reflect.DeepEqual(clean, []int64{1}) || !reflect.DeepEqual(dirty, tt.wantDirty) {
+				t.Errorf("applied %v and dirty %v, want [1] and %v", clean, dirty, tt.wantDirty)
+			}
+			var gadgets bool
+			if err := db.QueryRow("SELECT to_regclass('gadgets') IS NOT NULL").Scan(&gadgets); err != nil || gadgets {
+				t.Errorf("gadgets table exists = %v (%v) after the failed migration", gadgets, err)
+			}
+			if len(tt.wantDirty) == 0 {
+				return
+			}
+
+			// Nothing runs until the dirty version is forced
+			if _, err := m.Up(ctx, 0); !errors.Is(err, ErrDirty) {
+				t.Errorf("Up() on a dirty database = %v, want ErrDirty", err)
+			}
+			if _, err := m.Down(ctx, 1); !errors.Is(err, ErrDirty) {
+				t.Errorf("Down() on a dirty database = %v, want ErrDirty", err)
+			}
+			if err := m.Force(ctx, 1); err != nil {
+				t.Fatal(err)
+			}
+			if clean, dirty := applied(t, m); !reflect.DeepEqual(clean, []int64{1}) || len(dirty) != 0 {
+				t.Errorf("after force: applied %v and dirty %v, want [1] and none", clean, dirty)
+			}
+		})
+	}
+}
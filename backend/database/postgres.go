@@ -1,11 +1,13 @@
 package database
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
 
 	"ecommerce-backend/config"
-	"ecommerce-backend/models"
+	"ecommerce-backend/metrics"
+	"ecommerce-backend/tracing"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -13,45 +15,49 @@ import (
 
 var DB *gorm.DB
 
-// ConnectDB initializes the database connection
-func ConnectDB() {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		config.GetString("DB_HOST", "localhost"),
-		config.GetString("DB_PORT", "5432"),
-		config.GetString("DB_USER", "postgres"),
-		config.GetString("DB_PASSWORD", "1337"),
-		config.GetString("DB_NAME", "db_ecommerce"),
-		config.GetString("DB_SSLMODE", "disable"),
-	)
-
+// ConnectDB initializes the database connection. It does not touch the
+// schema; run cmd/migrate or start the server with -migrate for that.
+func ConnectDB(cfg config.DatabaseConfig) {
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+		Logger: newSlogLogger(cfg),
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
 	log.Println("Database connected successfully")
 
-	// Auto migrate all models
-	err = DB.AutoMigrate(
-		&models.User{},
-		&models.Category{},
-		&models.Product{},
-		&models.Cart{},
-		&models.CartItem{},
-		&models.Order{},
-		&models.OrderItem{},
-		&models.Review{},
-		&models.Address{},
-	)
-	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+	if err := DB.Use(metrics.GormPlugin{DBName: cfg.Name}); err != nil {
+		log.Printf("Failed to register database metrics: %v", err)
+	}
+	if err := DB.Use(tracing.GormPlugin{}); err != nil {
+		log.Printf("Failed to register database tracing: %v", err)
 	}
+}
 
-	log.Println("Database migration completed")
+// PingDB checks that Postgres answers within ctx's deadline
+func PingDB(ctx context.Context) error {
+	if DB == nil {
+		return errors.New("database not initialized")
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
 
-	// Seed initial data
-	seedData()
+// CloseDB closes the database connection pool
+func CloseDB() error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
 
 // GetDB returns the database connection
@@ -2,10 +2,12 @@ package database
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
 
 	"ecommerce-backend/config"
+	"ecommerce-backend/metrics"
+	"ecommerce-backend/tracing"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -13,13 +15,18 @@ import (
 var RedisClient *redis.Client
 
 // ConnectRedis initializes the Redis connection
-func ConnectRedis() {
+func ConnectRedis(cfg config.RedisConfig) {
 	RedisClient = redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", config.GetString("REDIS_HOST", "localhost"), config.GetString("REDIS_PORT", "6379")),
-		Password: config.GetString("REDIS_PASSWORD", ""),
-		DB:       config.GetInt("REDIS_DB", 0),
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
 	})
 
+	if err := metrics.RegisterRedis(RedisClient); err != nil {
+		log.Printf("Failed to register Redis metrics: %v", err)
+	}
+	RedisClient.AddHook(tracing.RedisHook{})
+
 	// Test the connection
 	ctx := context.Background()
 	_, err := RedisClient.Ping(ctx).Result()
@@ -30,3 +37,19 @@ func ConnectRedis() {
 
 	log.Println("Redis connected successfully")
 }
+
+// PingRedis checks that Redis answers within ctx's deadline
+func PingRedis(ctx context.Context) error {
+	if RedisClient == nil {
+		return errors.New("redis client not initialized")
+	}
+	return RedisClient.Ping(ctx).Err()
+}
+
+// CloseRedis closes the Redis connection pool
+func CloseRedis() error {
+	if RedisClient == nil {
+		return nil
+	}
+	return RedisClient.Close()
+}
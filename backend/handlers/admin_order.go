@@ -1,79 +1,201 @@
 package handlers
 
 import (
-	"strconv"
-
-	"ecommerce-backend/database"
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/metrics"
 	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/spreadsheet"
+	"ecommerce-backend/tracing"
 
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // GetAdminOrders returns all orders for admin
 // @Summary Get all orders (admin)
-// @Description Get all orders with pagination and filtering (admin only)
+// @Description Get all orders with pagination and filtering (admin only). With export set, every matching order is streamed as a CSV or XLSX download instead, oldest first, with a row per order or per order item.
 // @Tags orders
 // @Accept json
 // @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
 // @Security BearerAuth
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page" default(10)
 // @Param search query string false "Search orders by order number or customer name"
 // @Param status query string false "Filter by status"
 // @Param payment_status query string false "Filter by payment status"
+// @Param from query string false "Placed on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Placed on or before this date (YYYY-MM-DD)"
+// @Param export query string false "Download every matching order as csv or xlsx"
+// @Param rows query string false "Export a row per order or per item" Enums(orders, items) default(orders)
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
 // @Router /admin/orders [get]
-func GetAdminOrders(c *fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	search := c.Query("search")
-	status := c.Query("status")
-	paymentStatus := c.Query("payment_status")
+func (h *Handler) GetAdminOrders(c *fiber.Ctx) error {
+	filter, err := orderFilter(c)
+	if err != nil {
+		return err
+	}
+	if format := c.Query("export"); format != "" {
+		return h.exportOrders(c, orders.ExportQuery{
+			Filter: filter,
+			Format: spreadsheet.Format(format),
+			Layout: c.Query("rows", orders.ExportOrders),
+		})
+	}
 
-	offset := (page - 1) * limit
+	q := orders.Query{Page: pageQuery(c), Filter: filter}
+	list, total, err := h.svc.Orders.List(c.UserContext(), q)
+	if err != nil {
+		return err
+	}
 
-	var orders []models.Order
-	var total int64
+	return c.JSON(fiber.Map{
+		"orders":     list,
+		"pagination": pagination(q.Page, total),
+	})
+}
 
-	query := database.DB.Model(&models.Order{}).
-		Preload("User").
-		Preload("OrderItems.Product")
+// exportOrders streams the orders q selects as a download. The response
+// starts before the orders are read, so a failure part way through can only
+// be logged and leaves the file cut short.
+func (h *Handler) exportOrders(c *fiber.Ctx, q orders.ExportQuery) error {
+	if err := q.Check(); err != nil {
+		return err
+	}
 
-	// Apply filters
-	if search != "" {
-		query = query.Where("order_number ILIKE ? OR shipping_address ILIKE ?",
-			"%"+search+"%", "%"+search+"%")
+	name := "orders"
+	if q.Layout == orders.ExportItems {
+		name = "order-items"
 	}
+	c.Attachment(fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), q.Format))
+	c.Set(fiber.HeaderContentType, q.Format.ContentType())
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+
+	requestCtx := c.UserContext()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		h.streamExport(requestCtx, q, w)
+	})
+	return nil
+}
+
+// Outcomes of a streamed export
+const (
+	exportOK        = "ok"
+	exportCancelled = "cancelled"
+	exportFailed    = "failed"
+)
 
-	if status != "" {
-		query = query.Where("status = ?", status)
+// streamExport writes the export to w. It runs after the request has been
+// logged as a 200 and its span has ended, so it reports how the download
+// really went in a span and a log line of its own, which carry the request
+// ID and link back to the request's trace.
+func (h *Handler) streamExport(requestCtx context.Context, q orders.ExportQuery, w *bufio.Writer) {
+	ctx := logging.WithRequestID(context.Background(), logging.RequestID(requestCtx))
+	ctx, span := tracing.Tracer().Start(ctx, "orders.export",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(requestCtx)),
+		trace.WithAttributes(
+			attribute.String("export.format", string(q.Format)),
+			attribute.String("export.rows", q.Layout),
+		))
+	defer span.End()
+
+	// The request context outlives a client that disconnects, so the export
+	// gets its own, cancelled once writing to the client fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	err := h.svc.Orders.Export(ctx, q, &streamWriter{w: w, cancel: cancel})
+	duration := time.Since(start)
+
+	outcome, level := exportOK, slog.LevelInfo
+	switch {
+	case err != nil && ctx.Err() != nil:
+		outcome, level = exportCancelled, slog.LevelWarn
+	case err != nil:
+		outcome, level = exportFailed, slog.LevelError
 	}
+	metrics.OrderExports.WithLabelValues(string(q.Format), outcome).Inc()
+	metrics.OrderExportDuration.WithLabelValues(outcome).Observe(duration.Seconds())
 
-	if paymentStatus != "" {
-		query = query.Where("payment_status = ?", paymentStatus)
+	span.SetAttributes(attribute.String("export.outcome", outcome))
+	attrs := []any{"format", q.Format, "rows", q.Layout, "outcome", outcome, "duration_ms", duration.Milliseconds()}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, outcome)
+		attrs = append(attrs, "error", err)
 	}
+	slog.Log(ctx, level, "Order export finished", attrs...)
+}
 
-	// Count total records
-	query.Count(&total)
+// streamWriter writes a streamed response and cancels its context at the
+// first failed write or flush, which is how a disconnected client shows
+type streamWriter struct {
+	w      *bufio.Writer
+	cancel context.CancelFunc
+}
 
-	// Get orders with pagination
-	if err := query.Offset(offset).Limit(limit).
-		Order("created_at DESC").
-		Find(&orders).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch orders",
-		})
+func (s *streamWriter) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	if err != nil {
+		s.cancel()
 	}
+	return n, err
+}
 
-	return c.JSON(fiber.Map{
-		"orders": orders,
-		"pagination": fiber.Map{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": (total + int64(limit) - 1) / int64(limit),
-		},
-	})
+func (s *streamWriter) Flush() error {
+	err := s.w.Flush()
+	if err != nil {
+		s.cancel()
+	}
+	return err
+}
+
+// orderFilter reads the admin order list's filters from the query string
+func orderFilter(c *fiber.Ctx) (repository.OrderFilter, error) {
+	filter := repository.OrderFilter{
+		Search:        c.Query("search"),
+		Status:        c.Query("status"),
+		PaymentStatus: c.Query("payment_status"),
+	}
+	var err error
+	filter.From, filter.To, err = dateRange(c.Query("from"), c.Query("to"))
+	return filter, err
+}
+
+// dateRange parses the dates from and to, either of which may be empty, as
+// the start of from and the end of to in server time
+func dateRange(from, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	if from != "" {
+		day, err := time.ParseInLocation(time.DateOnly, from, time.Local)
+		if err != nil {
+			return start, end, apperror.BadRequest(apperror.CodeInvalidRequest, "from must be a date like 2024-03-09")
+		}
+		start = day
+	}
+	if to != "" {
+		day, err := time.ParseInLocation(time.DateOnly, to, time.Local)
+		if err != nil {
+			return start, end, apperror.BadRequest(apperror.CodeInvalidRequest, "to must be a date like 2024-03-09")
+		}
+		end = day.AddDate(0, 0, 1)
+	}
+	return start, end, nil
 }
 
 // GetAdminOrder returns a single order by ID for admin
@@ -85,90 +207,90 @@ func GetAdminOrders(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path int true "Order ID"
 // @Success 200 {object} models.Order
-// @Failure 404 {object} map[string]interface{}
+// @Success 304
+// @Failure 404 {object} apperror.Problem
 // @Router /admin/orders/{id} [get]
-func GetAdminOrder(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+func (h *Handler) GetAdminOrder(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid order ID")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid order ID",
-		})
+		return err
 	}
 
-	var order models.Order
-	if err := database.DB.Preload("User").
-		Preload("OrderItems.Product").
-		Preload("OrderItems.Product.Category").
-		First(&order, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Order not found",
-		})
+	order, err := h.svc.Orders.Get(c.UserContext(), id)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(order)
+	return sendWithValidators(c, entityValidators("order", order.ID, order.Version, order.UpdatedAt), order)
 }
 
 // UpdateOrderStatus updates order status (admin only)
 // @Summary Update order status (admin)
-// @Description Update order status and optionally add tracking number (admin only)
+// @Description Update order status and optionally add tracking number (admin only). Cancelling returns the items to stock; a cancelled order cannot be reopened. Once an order has shipments its status follows them and can no longer be changed here.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Order ID"
+// @Param If-Match header string true "ETag from GET /admin/orders/{id}"
 // @Param request body UpdateOrderStatusRequest true "Status update data"
 // @Success 200 {object} models.Order
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 412 {object} apperror.Problem
+// @Failure 428 {object} apperror.Problem
 // @Router /admin/orders/{id}/status [put]
-func UpdateOrderStatus(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+func (h *Handler) UpdateOrderStatus(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid order ID")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid order ID",
-		})
+		return err
 	}
 
-	var order models.Order
-	if err := database.DB.First(&order, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Order not found",
-		})
+	ctx := c.UserContext()
+	order, err := h.svc.Orders.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := checkIfMatch(c, entityValidators("order", order.ID, order.Version, order.UpdatedAt), order); err != nil {
+		return err
 	}
 
 	var req UpdateOrderStatusRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	// Update order
-	order.Status = req.Status
-	order.TrackingNumber = req.TrackingNumber
-	order.Notes = req.Notes
+	previous := order.Status
+	order, updated, err := h.svc.Orders.UpdateStatus(ctx, order.ID, expectedVersion(req.Version, order.Version), orders.StatusUpdate{
+		Status:         req.Status,
+		TrackingNumber: req.TrackingNumber,
+		Notes:          req.Notes,
+	})
+	if err != nil {
+		return err
+	}
 
-	if err := database.DB.Save(&order).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update order",
-		})
+	v := entityValidators("order", order.ID, order.Version, order.UpdatedAt)
+	if !updated {
+		return versionConflict(c, v, order)
 	}
 
-	// Return updated order with relationships
-	if err := database.DB.Preload("User").
-		Preload("OrderItems.Product").
-		First(&order, id).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch updated order",
-		})
+	// Cancelling put the items back in stock
+	if order.Status == orders.StatusCancelled && previous != orders.StatusCancelled {
+		for _, item := range order.OrderItems {
+			onProductChanged(ctx, item.ProductID)
+		}
 	}
 
+	c.Set(fiber.HeaderETag, v.ETag)
 	return c.JSON(order)
 }
 
 // UpdatePaymentStatus updates payment status (admin only)
 // @Summary Update payment status (admin)
-// @Description Update payment status for an order (admin only)
+// @Description Update payment status for an order (admin only). The refunded statuses are set by refunds, and orders with refunds can only change through them. Drafts and cancelled orders cannot be paid.
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -176,52 +298,196 @@ func UpdateOrderStatus(c *fiber.Ctx) error {
 // @Param id path int true "Order ID"
 // @Param request body UpdatePaymentStatusRequest true "Payment status data"
 // @Success 200 {object} models.Order
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
 // @Router /admin/orders/{id}/payment [put]
-func UpdatePaymentStatus(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+func (h *Handler) UpdatePaymentStatus(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid order ID")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid order ID",
-		})
+		return err
 	}
 
-	var order models.Order
-	if err := database.DB.First(&order, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Order not found",
-		})
+	var req UpdatePaymentStatusRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	var req UpdatePaymentStatusRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	order, err := h.svc.Orders.UpdatePaymentStatus(c.UserContext(), id, req.PaymentStatus)
+	if err != nil {
+		return err
 	}
 
-	// Update payment status
-	order.PaymentStatus = req.PaymentStatus
+	return c.JSON(order)
+}
 
-	if err := database.DB.Save(&order).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update payment status",
-		})
+// CreateDraftOrder starts an order for a customer (admin only)
+// @Summary Create draft order (admin)
+// @Description Create a draft order for a customer, e.g. one taken over the phone. It is priced like a checkout at the products' current prices, holds no stock and stays hidden from the customer until it is placed.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateDraftOrderRequest true "Draft order data"
+// @Success 201 {object} models.Order
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Router /admin/orders [post]
+func (h *Handler) CreateDraftOrder(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var req CreateDraftOrderRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	// Return updated order with relationships
-	if err := database.DB.Preload("User").
-		Preload("OrderItems.Product").
-		First(&order, id).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch updated order",
-		})
+	order, err := h.svc.Orders.CreateDraft(c.UserContext(), user.ID, orders.Draft{
+		UserID:          req.UserID,
+		Lines:           orderLines(req.Items),
+		ShippingAddress: req.ShippingAddress,
+		PaymentMethod:   req.PaymentMethod,
+		Notes:           req.Notes,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, entityValidators("order", order.ID, order.Version, order.UpdatedAt).ETag)
+	return c.Status(fiber.StatusCreated).JSON(order)
+}
+
+// EditOrder changes a draft or pending order (admin only)
+// @Summary Edit order (admin)
+// @Description Change the items, shipping address, payment method or notes of a draft or pending order (admin only). Items replace the order's lines: products already on the order keep the price they were ordered at and new ones are added at their current price. The order is repriced like a checkout, a pending order takes or returns the difference in stock, and the change is recorded in the order's history. Items cannot change once the order is paid.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param If-Match header string true "ETag from GET /admin/orders/{id}"
+// @Param request body EditOrderRequest true "Order changes"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 412 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Failure 428 {object} apperror.Problem
+// @Router /admin/orders/{id} [put]
+func (h *Handler) EditOrder(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	id, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	order, err := h.svc.Orders.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := checkIfMatch(c, entityValidators("order", order.ID, order.Version, order.UpdatedAt), order); err != nil {
+		return err
+	}
+
+	var req EditOrderRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	edit := orders.Edit{
+		ShippingAddress: req.ShippingAddress,
+		PaymentMethod:   req.PaymentMethod,
+		Notes:           req.Notes,
+	}
+	if req.Items != nil {
+		edit.Lines = orderLines(req.Items)
+	}
+	before := order.OrderItems
+	order, updated, err := h.svc.Orders.Edit(ctx, user.ID, order.ID, expectedVersion(req.Version, order.Version), edit)
+	if err != nil {
+		return err
+	}
+
+	v := entityValidators("order", order.ID, order.Version, order.UpdatedAt)
+	if !updated {
+		return versionConflict(c, v, order)
+	}
+
+	// A pending order's stock followed its items
+	if order.Status == orders.StatusPending {
+		for _, item := range append(before, order.OrderItems...) {
+			onProductChanged(ctx, item.ProductID)
+		}
+	}
+
+	c.Set(fiber.HeaderETag, v.ETag)
+	return c.JSON(order)
+}
+
+// PlaceDraftOrder places a draft order (admin only)
+// @Summary Place draft order (admin)
+// @Description Take a draft order's items out of stock and make it a pending order the customer can see (admin only)
+// @Tags orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param If-Match header string true "ETag from GET /admin/orders/{id}"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 412 {object} apperror.Problem
+// @Failure 428 {object} apperror.Problem
+// @Router /admin/orders/{id}/place [put]
+func (h *Handler) PlaceDraftOrder(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	id, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	ctx := c.UserContext()
+	order, err := h.svc.Orders.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := checkIfMatch(c, entityValidators("order", order.ID, order.Version, order.UpdatedAt), order); err != nil {
+		return err
+	}
+
+	order, updated, err := h.svc.Orders.PlaceDraft(ctx, user.ID, order.ID, order.Version)
+	if err != nil {
+		return err
 	}
 
+	v := entityValidators("order", order.ID, order.Version, order.UpdatedAt)
+	if !updated {
+		return versionConflict(c, v, order)
+	}
+
+	for _, item := range order.OrderItems {
+		onProductChanged(ctx, item.ProductID)
+	}
+
+	c.Set(fiber.HeaderETag, v.ETag)
 	return c.JSON(order)
 }
 
+// orderLines converts requested items to the service's lines
+func orderLines(items []OrderLineRequest) []orders.Line {
+	lines := make([]orders.Line, len(items))
+	for i, item := range items {
+		lines[i] = orders.Line{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+	return lines
+}
+
 // GetOrderStats returns order statistics for admin dashboard
 // @Summary Get order statistics (admin)
 // @Description Get order statistics for dashboard (admin only)
@@ -231,48 +497,44 @@ func UpdatePaymentStatus(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Success 200 {object} map[string]interface{}
 // @Router /admin/orders/stats [get]
-func GetOrderStats(c *fiber.Ctx) error {
-	var stats struct {
-		TotalOrders      int64 `json:"total_orders"`
-		PendingOrders    int64 `json:"pending_orders"`
-		ProcessingOrders int64 `json:"processing_orders"`
-		ShippedOrders    int64 `json:"shipped_orders"`
-		DeliveredOrders  int64 `json:"delivered_orders"`
-		CancelledOrders  int64 `json:"cancelled_orders"`
-		TotalRevenue     int64 `json:"total_revenue"`
-		TodayOrders      int64 `json:"today_orders"`
-		TodayRevenue     int64 `json:"today_revenue"`
-	}
-
-	// Total orders
-	database.DB.Model(&models.Order{}).Count(&stats.TotalOrders)
-
-	// Orders by status
-	database.DB.Model(&models.Order{}).Where("status = ?", "pending").Count(&stats.PendingOrders)
-	database.DB.Model(&models.Order{}).Where("status = ?", "processing").Count(&stats.ProcessingOrders)
-	database.DB.Model(&models.Order{}).Where("status = ?", "shipped").Count(&stats.ShippedOrders)
-	database.DB.Model(&models.Order{}).Where("status = ?", "delivered").Count(&stats.DeliveredOrders)
-	database.DB.Model(&models.Order{}).Where("status = ?", "cancelled").Count(&stats.CancelledOrders)
-
-	// Total revenue (only delivered orders)
-	database.DB.Model(&models.Order{}).Where("status = ?", "delivered").Select("COALESCE(SUM(total_amount), 0)").Scan(&stats.TotalRevenue)
-
-	// Today's orders and revenue
-	today := "2006-01-02" // Format for today's date
-	database.DB.Model(&models.Order{}).Where("DATE(created_at) = ?", today).Count(&stats.TodayOrders)
-	database.DB.Model(&models.Order{}).Where("DATE(created_at) = ? AND status = ?", today, "delivered").
-		Select("COALESCE(SUM(total_amount), 0)").Scan(&stats.TodayRevenue)
+func (h *Handler) GetOrderStats(c *fiber.Ctx) error {
+	stats, err := h.svc.Orders.Stats(c.UserContext())
+	if err != nil {
+		return err
+	}
 
 	return c.JSON(stats)
 }
 
 // Request/Response types
 type UpdateOrderStatusRequest struct {
-	Status         string `json:"status" validate:"required,oneof=pending processing shipped delivered cancelled"`
-	TrackingNumber string `json:"tracking_number"`
-	Notes          string `json:"notes"`
+	Status         string  `json:"status" validate:"required,oneof=pending processing partially_shipped shipped delivered cancelled"`
+	TrackingNumber *string `json:"tracking_number"`
+	Notes          *string `json:"notes"`
+	Version        *uint   `json:"version"`
 }
 
 type UpdatePaymentStatusRequest struct {
-	PaymentStatus string `json:"payment_status" validate:"required,oneof=unpaid paid failed refunded"`
+	PaymentStatus string `json:"payment_status" validate:"required,oneof=unpaid paid failed"`
+}
+
+type OrderLineRequest struct {
+	ProductID uint `json:"product_id" validate:"required"`
+	Quantity  int  `json:"quantity" validate:"required,min=1"`
+}
+
+type CreateDraftOrderRequest struct {
+	UserID          uint               `json:"user_id" validate:"required"`
+	Items           []OrderLineRequest `json:"items" validate:"required,min=1,dive"`
+	ShippingAddress models.Address     `json:"shipping_address" validate:"required"`
+	PaymentMethod   string             `json:"payment_method" validate:"required,oneof=cod bank_transfer"`
+	Notes           string             `json:"notes"`
+}
+
+type EditOrderRequest struct {
+	Items           []OrderLineRequest `json:"items" validate:"omitempty,min=1,dive"`
+	ShippingAddress *models.Address    `json:"shipping_address"`
+	PaymentMethod   *string            `json:"payment_method" validate:"omitempty,oneof=cod bank_transfer"`
+	Notes           *string            `json:"notes"`
+	Version         *uint              `json:"version"`
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/metrics"
+	"ecommerce-backend/middleware"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository/repotest"
+	"ecommerce-backend/services"
+	"ecommerce-backend/services/orders"
+
+	"github.com/gofiber/fiber/v2"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// exports returns how many csv exports ended with outcome
+func exports(t *testing.T, outcome string) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.OrderExports.WithLabelValues("csv", outcome).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestExportOrdersOutcome(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantOutcome string
+	}{
+		{name: "complete", wantOutcome: exportOK},
+		// The 200 has been sent by the time the orders are read
+		{name: "database failure", err: errors.New("pq: connection reset"), wantOutcome: exportFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			repo := repotest.NewOrders(models.Order{OrderNumber: "ORD-1", Status: "placed"})
+			repo.Err = tt.err
+			h := New(&cfg, services.Services{Orders: orders.NewService(repotest.Transactor{}, repo, repotest.NewProducts(), repotest.NewUsers())})
+			app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler})
+			app.Get("/admin/orders", h.GetAdminOrders)
+
+			before := map[string]float64{}
+			for _, outcome := range []string{exportOK, exportFailed, exportCancelled} {
+				before[outcome] = exports(t, outcome)
+			}
+			res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/admin/orders?export=csv", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, want 200", res.StatusCode)
+			}
+
+			for outcome, count := range before {
+				want := count
+				if outcome == tt.wantOutcome {
+					want++
+				}
+				if got := exports(t, outcome); got != want {
+					t.Errorf("%s exports went from %v to %v, want %v", outcome, count, got, want)
+				}
+			}
+		})
+	}
+}
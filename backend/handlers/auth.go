@@ -1,16 +1,19 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"time"
 
+	"ecommerce-backend/apperror"
 	"ecommerce-backend/config"
-	"ecommerce-backend/database"
+	"ecommerce-backend/metrics"
 	"ecommerce-backend/models"
+	"ecommerce-backend/services/cart"
+	"ecommerce-backend/services/users"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type RegisterRequest struct {
@@ -27,6 +30,18 @@ type LoginRequest struct {
 	GuestCart []map[string]interface{} `json:"guest_cart"` // Array of guest cart items
 }
 
+type ClaimRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ClaimAccountRequest struct {
+	Token     string `json:"token" validate:"required"`
+	FirstName string `json:"first_name" validate:"required"`
+	LastName  string `json:"last_name" validate:"required"`
+	Password  string `json:"password" validate:"required,min=6"`
+	Phone     string `json:"phone"`
+}
+
 type AuthResponse struct {
 	User  models.User `json:"user"`
 	Token string      `json:"token"`
@@ -34,66 +49,100 @@ type AuthResponse struct {
 
 // Register handles user registration
 // @Summary Register a new user
-// @Description Register a new user account
+// @Description Register a new user account. The email of a guest who checked out is taken, with "guest": true in the problem, until the guest claims it through /auth/claim.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body RegisterRequest true "Registration data"
 // @Success 201 {object} AuthResponse
-// @Failure 400 {object} map[string]interface{}
-// @Failure 409 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
 // @Router /auth/register [post]
-func Register(c *fiber.Ctx) error {
+func (h *Handler) Register(c *fiber.Ctx) error {
 	var req RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	// Check if user already exists
-	var existingUser models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-			"error": "Email already registered",
-		})
+	user, err := h.svc.Users.Register(c.UserContext(), users.Account{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     req.Email,
+		Password:  req.Password,
+		Phone:     req.Phone,
+	})
+	if err != nil {
+		return err
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	// Generate JWT token
+	token, err := generateJWT(h.cfg.JWT, user.ID, user.Role)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to hash password",
-		})
+		return apperror.Internal("Failed to generate token", err)
 	}
 
-	// Create user
-	user := models.User{
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Email:     req.Email,
-		Password:  string(hashedPassword),
-		Phone:     req.Phone,
-		Role:      "user",
-		IsActive:  true,
+	return c.Status(fiber.StatusCreated).JSON(AuthResponse{
+		User:  user,
+		Token: token,
+	})
+}
+
+// RequestClaim emails a guest the link to turn their orders into an account
+// @Summary Request a guest claim link
+// @Description Email the guest customer with this email a single-use link, valid for 24 hours, to create an account that keeps their guest orders. Answers the same whether or not the email has guest orders.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ClaimRequest true "Guest email"
+// @Success 202 {object} map[string]interface{}
+// @Failure 422 {object} apperror.Problem
+// @Router /auth/claim/request [post]
+func (h *Handler) RequestClaim(c *fiber.Ctx) error {
+	var req ClaimRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create user",
-		})
+	if err := h.svc.Users.RequestClaim(c.UserContext(), req.Email); err != nil {
+		return err
 	}
 
-	// Generate JWT token
-	token, err := generateJWT(user.ID, user.Role)
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "If this email has guest orders, a link to create your account is on its way",
+	})
+}
+
+// ClaimAccount turns a guest into an account with the token from their link
+// @Summary Claim guest orders with a new account
+// @Description Create an account from the guest customer whose emailed claim link carries the token, keeping their email and orders. The link works once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ClaimAccountRequest true "Claim token and account details"
+// @Success 201 {object} AuthResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Router /auth/claim [post]
+func (h *Handler) ClaimAccount(c *fiber.Ctx) error {
+	var req ClaimAccountRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.svc.Users.Claim(c.UserContext(), req.Token, users.Account{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Password:  req.Password,
+		Phone:     req.Phone,
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to generate token",
-		})
+		return err
 	}
 
-	// Remove password from response
-	user.Password = ""
+	token, err := generateJWT(h.cfg.JWT, user.ID, user.Role)
+	if err != nil {
+		return apperror.Internal("Failed to generate token", err)
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(AuthResponse{
 		User:  user,
@@ -109,64 +158,35 @@ func Register(c *fiber.Ctx) error {
 // @Produce json
 // @Param request body LoginRequest true "Login credentials"
 // @Success 200 {object} AuthResponse
-// @Failure 400 {object} map[string]interface{}
-// @Failure 401 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
 // @Router /auth/login [post]
-func Login(c *fiber.Ctx) error {
+func (h *Handler) Login(c *fiber.Ctx) error {
 	var req LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	// Find user
-	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid credentials",
-		})
-	}
-
-	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid credentials",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	// Check if user is active
-	if !user.IsActive {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Account is inactive",
-		})
+	ctx := c.UserContext()
+	user, err := h.svc.Users.Authenticate(ctx, req.Email, req.Password)
+	if err != nil {
+		return err
 	}
 
 	// Generate JWT token
-	token, err := generateJWT(user.ID, user.Role)
+	token, err := generateJWT(h.cfg.JWT, user.ID, user.Role)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to generate token",
-		})
+		return apperror.Internal("Failed to generate token", err)
 	}
 
 	// Merge guest cart if provided
 	if len(req.GuestCart) > 0 {
-		fmt.Printf("Login: Guest cart found with %d items\n", len(req.GuestCart))
-		fmt.Printf("Login: Guest cart data: %+v\n", req.GuestCart)
-		if err := mergeGuestCart(user.ID, req.GuestCart); err != nil {
+		if err := h.mergeGuestCart(ctx, user.ID, req.GuestCart); err != nil {
 			// Log error but don't fail login
-			fmt.Printf("Failed to merge guest cart: %v\n", err)
-		} else {
-			fmt.Printf("Guest cart merged successfully for user %d\n", user.ID)
+			slog.ErrorContext(ctx, "Failed to merge guest cart", "user_id", user.ID, "error", err)
 		}
-	} else {
-		fmt.Printf("Login: No guest cart provided for user %d\n", user.ID)
 	}
 
-	// Remove password from response
-	user.Password = ""
-
 	return c.JSON(AuthResponse{
 		User:  user,
 		Token: token,
@@ -181,105 +201,51 @@ func Login(c *fiber.Ctx) error {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} models.User
-// @Failure 401 {object} map[string]interface{}
+// @Failure 401 {object} apperror.Problem
 // @Router /auth/profile [get]
-func GetProfile(c *fiber.Ctx) error {
+func (h *Handler) GetProfile(c *fiber.Ctx) error {
 	user := c.Locals("user").(models.User)
 	user.Password = ""
 	return c.JSON(user)
 }
 
 // generateJWT creates a new JWT token
-func generateJWT(userID uint, role string) (string, error) {
+func generateJWT(cfg config.JWTConfig, userID uint, role string) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
+		"exp":     time.Now().Add(cfg.TTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.GetString("JWT_SECRET", "your-super-secret-jwt-key")))
+	return token.SignedString([]byte(cfg.Secret))
 }
 
-// mergeGuestCart merges guest cart items to user cart
-func mergeGuestCart(userID uint, guestCart []map[string]interface{}) error {
-	db := database.GetDB()
-
-	fmt.Printf("Merging guest cart for user %d with %d items\n", userID, len(guestCart))
-
-	// Get or create user cart
-	var cart models.Cart
-	if err := db.Where("user_id = ? AND is_active = ?", userID, true).First(&cart).Error; err != nil {
-		// Create new cart if not exists
-		cart = models.Cart{
-			UserID:   userID,
-			IsActive: true,
-		}
-		if err := db.Create(&cart).Error; err != nil {
-			return fmt.Errorf("failed to create cart: %w", err)
-		}
-		fmt.Printf("Created new cart with ID: %d\n", cart.ID)
-	} else {
-		fmt.Printf("Found existing cart with ID: %d\n", cart.ID)
-	}
-
-	// Add guest cart items to user cart
+// mergeGuestCart merges guest cart items to user cart. Items without a
+// numeric product_id and quantity are skipped.
+func (h *Handler) mergeGuestCart(ctx context.Context, userID uint, guestCart []map[string]interface{}) error {
+	items := make([]cart.GuestItem, 0, len(guestCart))
 	for i, item := range guestCart {
-		fmt.Printf("Processing guest cart item %d: %+v\n", i, item)
-
-		productIDFloat, ok := item["product_id"].(float64)
+		productID, ok := item["product_id"].(float64)
 		if !ok {
-			fmt.Printf("Invalid product_id in item %d\n", i)
+			slog.DebugContext(ctx, "Skipping guest cart item without product_id", "index", i)
 			continue
 		}
-		productID := uint(productIDFloat)
-
-		quantityFloat, ok := item["quantity"].(float64)
+		quantity, ok := item["quantity"].(float64)
 		if !ok {
-			fmt.Printf("Invalid quantity in item %d\n", i)
+			slog.DebugContext(ctx, "Skipping guest cart item without quantity", "index", i)
 			continue
 		}
-		quantity := int(quantityFloat)
-
-		fmt.Printf("Processing: ProductID=%d, Quantity=%d\n", productID, quantity)
-
-		// Check if product exists
-		var product models.Product
-		if err := db.First(&product, productID).Error; err != nil {
-			fmt.Printf("Product %d not found, skipping\n", productID)
-			continue // Skip if product doesn't exist
-		}
+		items = append(items, cart.GuestItem{ProductID: uint(productID), Quantity: int(quantity)})
+	}
 
-		// Check if item already exists in cart
-		var existingItem models.CartItem
-		if err := db.Joins("JOIN carts ON cart_items.cart_id = carts.id").
-			Where("cart_items.product_id = ? AND carts.user_id = ? AND carts.is_active = ?", productID, userID, true).
-			First(&existingItem).Error; err == nil {
-			// Update quantity if item exists
-			newQuantity := existingItem.Quantity + quantity
-			if newQuantity <= product.Stock {
-				if err := db.Model(&existingItem).Where("id = ?", existingItem.ID).Update("quantity", newQuantity).Error; err != nil {
-					return fmt.Errorf("failed to update cart item: %w", err)
-				}
-				fmt.Printf("Updated existing cart item: New quantity=%d\n", newQuantity)
-			} else {
-				fmt.Printf("Quantity exceeds stock, keeping existing quantity\n")
-			}
-		} else {
-			// Create new cart item if not exists
-			cartItem := models.CartItem{
-				CartID:    cart.ID,
-				ProductID: productID,
-				Quantity:  quantity,
-			}
-			if err := db.Create(&cartItem).Error; err != nil {
-				return fmt.Errorf("failed to create cart item: %w", err)
-			}
-			fmt.Printf("Created new cart item: CartID=%d, ProductID=%d, Quantity=%d\n",
-				cartItem.CartID, cartItem.ProductID, cartItem.Quantity)
-		}
+	merged, err := h.svc.Cart.MergeGuest(ctx, userID, items)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Guest cart merge completed for user %d\n", userID)
+	metrics.CartAdditions.WithLabelValues("guest_merge").Add(float64(merged))
+	slog.InfoContext(ctx, "Guest cart merged", "user_id", userID,
+		"items", len(guestCart), "merged", merged)
 	return nil
 }
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/bulk"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StartBulkOrderJob queues an action on many orders (admin only)
+// @Summary Run bulk order action (admin)
+// @Description Queue a background job that marks orders processing, ships them with one carrier, prints their packing slips or cancels them (admin only). Give order_ids, or a filter matching orders like the admin order list does. Follow the job's progress and per-order results at /admin/jobs/{id}.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BulkOrderRequest true "Bulk action"
+// @Success 202 {object} models.BulkJob
+// @Failure 400 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Router /admin/orders/bulk [post]
+func (h *Handler) StartBulkOrderJob(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	var req BulkOrderRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	r := bulk.Request{
+		Action:   req.Action,
+		OrderIDs: req.OrderIDs,
+		Carrier:  req.Carrier,
+		Service:  req.Service,
+	}
+	if req.Filter != nil {
+		from, to, err := dateRange(req.Filter.From, req.Filter.To)
+		if err != nil {
+			return err
+		}
+		r.Filter = &repository.OrderFilter{
+			Search:        req.Filter.Search,
+			Status:        req.Filter.Status,
+			PaymentStatus: req.Filter.PaymentStatus,
+			From:          from,
+			To:            to,
+		}
+	}
+
+	job, err := h.svc.Bulk.Start(c.UserContext(), user.ID, r)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// GetBulkJobs lists bulk jobs (admin only)
+// @Summary List bulk jobs (admin)
+// @Description List bulk order jobs with their progress, newest first (admin only)
+// @Tags jobs
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/jobs [get]
+func (h *Handler) GetBulkJobs(c *fiber.Ctx) error {
+	page := pageQuery(c)
+
+	list, total, err := h.svc.Bulk.List(c.UserContext(), page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"jobs":       list,
+		"pagination": pagination(page, total),
+	})
+}
+
+// GetBulkJob returns a bulk job by ID (admin only)
+// @Summary Get bulk job (admin)
+// @Description Get a bulk order job with its progress and a result for each order (admin only)
+// @Tags jobs
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.BulkJob
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/jobs/{id} [get]
+func (h *Handler) GetBulkJob(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid job ID")
+	if err != nil {
+		return err
+	}
+
+	job, err := h.svc.Bulk.Get(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(job)
+}
+
+// GetBulkJobDocument downloads the document a bulk job produced (admin only)
+// @Summary Download bulk job document (admin)
+// @Description Download the PDF a finished packing_slips job printed, with a slip for each order it succeeded on (admin only)
+// @Tags jobs
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {file} file
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/jobs/{id}/document [get]
+func (h *Handler) GetBulkJobDocument(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid job ID")
+	if err != nil {
+		return err
+	}
+
+	doc, err := h.svc.Bulk.Document(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	return sendDocument(c, doc)
+}
+
+// Request/Response types
+type BulkOrderRequest struct {
+	Action   string           `json:"action" validate:"required,oneof=process ship packing_slips cancel"`
+	OrderIDs []uint           `json:"order_ids"`
+	Filter   *BulkOrderFilter `json:"filter"`
+	Carrier  string           `json:"carrier" validate:"required_if=Action ship"`
+	Service  string           `json:"service"`
+}
+
+type BulkOrderFilter struct {
+	Search        string `json:"search"`
+	Status        string `json:"status"`
+	PaymentStatus string `json:"payment_status"`
+	From          string `json:"from"` // YYYY-MM-DD
+	To            string `json:"to"`   // YYYY-MM-DD, included
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/cache"
+	"ecommerce-backend/services/catalog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Cache key groups for the public catalog. Lists and product details embed
+// their group generation so an event can invalidate them all at once.
+const (
+	cacheGroupProductLists   = "catalog:products"
+	cacheGroupProductDetails = "catalog:product"
+	cacheGroupCategories     = "catalog:categories"
+)
+
+// productListCacheKey is built from the parsed query only, so that unknown
+// parameters cannot make every request miss the cache
+func productListCacheKey(ctx context.Context, q catalog.ProductQuery) string {
+	params := url.Values{}
+	params.Set("page", strconv.Itoa(q.Page.Page))
+	params.Set("limit", strconv.Itoa(q.Page.Limit))
+	params.Set("category", q.Category)
+	params.Set("search", q.Search)
+	if q.MinPrice != nil {
+		params.Set("min_price", strconv.FormatInt(*q.MinPrice, 10))
+	}
+	if q.MaxPrice != nil {
+		params.Set("max_price", strconv.FormatInt(*q.MaxPrice, 10))
+	}
+	return fmt.Sprintf("%s:g%d:%s", cacheGroupProductLists,
+		cache.Generation(ctx, cacheGroupProductLists), params.Encode())
+}
+
+func productDetailCacheKey(ctx context.Context, id int) string {
+	return fmt.Sprintf("%s:g%d:%d", cacheGroupProductDetails,
+		cache.Generation(ctx, cacheGroupProductDetails), id)
+}
+
+func categoriesCacheKey(ctx context.Context) string {
+	return fmt.Sprintf("%s:g%d", cacheGroupCategories, cache.Generation(ctx, cacheGroupCategories))
+}
+
+// cachedResponse is what the catalog cache stores: the body together with its
+// validators so conditional requests can be answered from a cache hit
+type cachedResponse struct {
+	Validators validators      `json:"validators"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// sendCachedJSON serves a catalog response through the read-through cache.
+// load returns the body and its validators; errors are returned to every
+// waiting caller and never cached.
+func (h *Handler) sendCachedJSON(c *fiber.Ctx, namespace, key string, load func() (interface{}, validators, error)) error {
+	data, hit, err := cache.Remember(c.UserContext(), namespace, key, h.cfg.Cache.CatalogTTL, func() ([]byte, error) {
+		body, v, err := load()
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(cachedResponse{Validators: v, Body: encoded})
+	})
+	if err != nil {
+		return err
+	}
+
+	var entry cachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return apperror.Internal("Failed to decode cached response", err)
+	}
+
+	if hit {
+		c.Set("X-Cache", "HIT")
+	} else {
+		c.Set("X-Cache", "MISS")
+	}
+
+	if notModified(c, entry.Validators) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Status(fiber.StatusOK).Send(entry.Body)
+}
+
+// onProductChanged invalidates a product's detail entry and every product list
+func onProductChanged(ctx context.Context, productID uint) {
+	cache.Delete(ctx, productDetailCacheKey(ctx, int(productID)))
+	cache.BumpGeneration(ctx, cacheGroupProductLists)
+}
+
+// InvalidateProduct invalidates a product's cached entries for code outside
+// the handlers, such as background jobs that move stock
+func InvalidateProduct(ctx context.Context, productID uint) {
+	onProductChanged(ctx, productID)
+}
+
+// onCategoryChanged invalidates categories and everything that embeds them
+func onCategoryChanged(ctx context.Context) {
+	cache.BumpGeneration(ctx, cacheGroupCategories)
+	cache.BumpGeneration(ctx, cacheGroupProductLists)
+	cache.BumpGeneration(ctx, cacheGroupProductDetails)
+}
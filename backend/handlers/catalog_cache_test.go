@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ecommerce-backend/cache"
+	"ecommerce-backend/database"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/catalog"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// listCacheKey returns the product list cache key for a query string
+func listCacheKey(t *testing.T, query string) string {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/products", func(c *fiber.Ctx) error {
+		q, err := productQuery(c)
+		if err != nil {
+			return err
+		}
+		return c.SendString(productListCacheKey(c.UserContext(), q))
+	})
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/products"+query, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	return string(body)
+}
+
+func TestProductListCacheKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		sameAs   string
+		wantSame bool
+	}{
+		{name: "unknown parameters", query: "?x=8f3a&utm_source=mail", sameAs: "", wantSame: true},
+		{name: "defaults spelled out", query: "?page=1&limit=10", sameAs: "", wantSame: true},
+		{name: "limit above the maximum", query: "?limit=5000", sameAs: "?limit=100", wantSame: true},
+		{name: "search case and spaces", query: "?search=%20Kopi%20", sameAs: "?search=kopi", wantSame: true},
+		{name: "category case", query: "?category=Beverages", sameAs: "?category=beverages", wantSame: true},
+		{name: "parameter order", query: "?min_price=10&search=kopi", sameAs: "?search=kopi&min_price=10", wantSame: true},
+		{name: "different search", query: "?search=kopi", sameAs: "?search=teh", wantSame: false},
+		{name: "different page", query: "?page=2", sameAs: "", wantSame: false},
+		{name: "price range", query: "?max_price=50000", sameAs: "?min_price=50000", wantSame: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, other := listCacheKey(t, tt.query), listCacheKey(t, tt.sameAs)
+			if (key == other) != tt.wantSame {
+				t.Errorf("keys %q and %q: same = %v, want %v", key, other, key == other, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestCatalogInvalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(ctx context.Context)
+		// want* report whether each entry must be gone after the change
+		wantList, wantDetail, wantOtherDetail, wantCategories bool
+	}{
+		{
+			name:       "product changed",
+			change:     func(ctx context.Context) { onProductChanged(ctx, 1) },
+			wantList:   true,
+			wantDetail: true,
+		},
+		{
+			name:            "category changed",
+			change:          onCategoryChanged,
+			wantList:        true,
+			wantDetail:      true,
+			wantOtherDetail: true,
+			wantCategories:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			database.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			cache.SetEnabled(true)
+			t.Cleanup(func() {
+				cache.SetEnabled(false)
+				database.RedisClient.Close()
+				database.RedisClient = nil
+			})
+			ctx := context.Background()
+			q := catalog.ProductQuery{Page: repository.NewPage(1, 10)}
+
+			keys := func() map[string]string {
+				return map[string]string{
+					"list":         productListCacheKey(ctx, q),
+					"detail":       productDetailCacheKey(ctx, 1),
+					"other detail": productDetailCacheKey(ctx, 2),
+					"categories":   categoriesCacheKey(ctx),
+				}
+			}
+			before := keys()
+			for name, key := range before {
+				if _, _, err := cache.Remember(ctx, "test", key, time.Minute, func() ([]byte, error) { return []byte(name), nil }); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tt.change(ctx)
+
+			// An entry is gone when its key moved to a new generation or was deleted
+			after := keys()
+			want := map[string]bool{"list": tt.wantList, "detail": tt.wantDetail, "other detail": tt.wantOtherDetail, "categories": tt.wantCategories}
+			for name, key := range before {
+				gone := after[name] != key || !mr.Exists("cache:"+key)
+				if gone != want[name] {
+					t.Errorf("%s invalidated = %v, want %v", name, gone, want[name])
+				}
+			}
+		})
+	}
+}
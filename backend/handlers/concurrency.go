@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// expectedVersion picks the version an update is based on: the one the client
+// sent in the body, or the one whose ETag it presented in If-Match
+func expectedVersion(sent *uint, current uint) uint {
+	if sent != nil {
+		return *sent
+	}
+	return current
+}
+
+// versionConflict fails with 409 carrying the row as it is now, so the
+// client can merge its edit and retry against the current version
+func versionConflict(c *fiber.Ctx, v validators, current interface{}) error {
+	c.Set(fiber.HeaderETag, v.ETag)
+	return apperror.Conflict(apperror.CodeVersionConflict,
+		"Resource has been modified by someone else").With("current", current)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"testing"
+
+	"ecommerce-backend/apperror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestVersionConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		// body is the second edit, made after a first one moved the user to
+		// version 2
+		body string
+		// ifMatch defaults to the ETag the first edit returned
+		ifMatch    string
+		wantStatus int
+	}{
+		{name: "current version in the body", body: `{"first_name":"Sri","version":2}`, ifMatch: "*", wantStatus: fiber.StatusOK},
+		{name: "stale version in the body", body: `{"first_name":"Sri","version":1}`, ifMatch: "*", wantStatus: fiber.StatusConflict},
+		{name: "version from If-Match", body: `{"first_name":"Sri"}`, ifMatch: "*", wantStatus: fiber.StatusOK},
+		{
+			// If-Match vouches for the current row, but the body was based on
+			// an older one
+			name:       "stale body under a current ETag",
+			body:       `{"first_name":"Sri","version":1}`,
+			wantStatus: fiber.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, etag := usersApp(t)
+			res, body := send(t, app, fiber.MethodPatch, "/admin/users/1", `{"last_name":"Rahma"}`, map[string]string{fiber.HeaderIfMatch: etag})
+			if res.StatusCode != fiber.StatusOK {
+				t.Fatalf("first edit: status %d: %v", res.StatusCode, body)
+			}
+			ifMatch := tt.ifMatch
+			if ifMatch == "" {
+				ifMatch = res.Header.Get(fiber.HeaderETag)
+			}
+			current := res.Header.Get(fiber.HeaderETag)
+
+			res, body = send(t, app, fiber.MethodPatch, "/admin/users/1", tt.body, map[string]string{fiber.HeaderIfMatch: ifMatch})
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", res.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantStatus != fiber.StatusConflict {
+				if body["first_name"] != "Sri" || body["version"] != float64(3) {
+					t.Errorf("body = %v, want the edit at version 3", body)
+				}
+				return
+			}
+
+			// The conflict carries the row as it is now, so the client can
+			// merge and retry, and leaves it untouched
+			if body["code"] != string(apperror.CodeVersionConflict) {
+				t.Errorf("code = %v, want %s", body["code"], apperror.CodeVersionConflict)
+			}
+			state, _ := body["current"].(map[string]interface{})
+			if state["first_name"] != "Siti" || state["last_name"] != "Rahma" || state["version"] != float64(2) {
+				t.Errorf("current = %v, want the user after the first edit", state)
+			}
+			if res.Header.Get(fiber.HeaderETag) != current {
+				t.Errorf("ETag = %s, want the current %s", res.Header.Get(fiber.HeaderETag), current)
+			}
+		})
+	}
+}
+
+func TestExpectedVersion(t *testing.T) {
+	sent := uint(3)
+	if got := expectedVersion(&sent, 5); got != 3 {
+		t.Errorf("expectedVersion(3, 5) = %d, want the sent 3", got)
+	}
+	if got := expectedVersion(nil, 5); got != 5 {
+		t.Errorf("expectedVersion(nil, 5) = %d, want the current 5", got)
+	}
+}
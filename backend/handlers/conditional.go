@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// representationVersion is mixed into every ETag so that changing the shape
+// of a response invalidates validators held by clients
+const representationVersion = "1"
+
+// validators are the HTTP cache validators for a response
+type validators struct {
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// versionStamp identifies one row, its optimistic-lock version (zero for
+// unversioned tables) and the moment it last changed
+type versionStamp struct {
+	ID        uint
+	Version   uint
+	UpdatedAt time.Time
+}
+
+// newValidators derives a strong ETag from the representation version, the
+// resource kind and the stamps of every row that contributes to the response.
+// extra carries anything else that shapes the body, such as pagination totals.
+func newValidators(kind string, stamps []versionStamp, extra ...string) validators {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%s|%s", representationVersion, kind)
+
+	var lastModified time.Time
+	for _, stamp := range stamps {
+		fmt.Fprintf(h, "|%d:%d:%d", stamp.ID, stamp.Version, stamp.UpdatedAt.UnixMicro())
+		if stamp.UpdatedAt.After(lastModified) {
+			lastModified = stamp.UpdatedAt
+		}
+	}
+	for _, e := range extra {
+		fmt.Fprintf(h, "|%s", e)
+	}
+
+	return validators{
+		ETag:         `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`,
+		LastModified: lastModified.UTC().Truncate(time.Second),
+	}
+}
+
+// entityValidators returns the validators for a single versioned row
+func entityValidators(kind string, id, version uint, updatedAt time.Time) validators {
+	return newValidators(kind, []versionStamp{{ID: id, Version: version, UpdatedAt: updatedAt}})
+}
+
+// notModified sets ETag and Last-Modified on the response and reports whether
+// the request's If-None-Match or If-Modified-Since allow a 304
+func notModified(c *fiber.Ctx, v validators) bool {
+	c.Set(fiber.HeaderETag, v.ETag)
+	if !v.LastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, v.LastModified.Format(http.TimeFormat))
+	}
+
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return false
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since (RFC 9110 13.2.2)
+	if header := c.Get(fiber.HeaderIfNoneMatch); header != "" {
+		return etagListMatches(header, v.ETag, true)
+	}
+
+	if header := c.Get(fiber.HeaderIfModifiedSince); header != "" && !v.LastModified.IsZero() {
+		if since, err := http.ParseTime(header); err == nil {
+			return !v.LastModified.After(since)
+		}
+	}
+
+	return false
+}
+
+// sendWithValidators answers 304 when the client copy is current, and sends
+// body as JSON otherwise
+func sendWithValidators(c *fiber.Ctx, v validators, body interface{}) error {
+	if notModified(c, v) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.JSON(body)
+}
+
+// checkIfMatch guards an update against lost writes. It fails with 428 when
+// If-Match is missing, or with 412 carrying the current state when it is stale.
+func checkIfMatch(c *fiber.Ctx, v validators, current interface{}) error {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return apperror.New(fiber.StatusPreconditionRequired, apperror.CodePreconditionRequired,
+			"If-Match header is required")
+	}
+
+	if !etagListMatches(header, v.ETag, false) {
+		c.Set(fiber.HeaderETag, v.ETag)
+		return apperror.New(fiber.StatusPreconditionFailed, apperror.CodePreconditionFailed,
+			"Resource has been modified by someone else").With("current", current)
+	}
+
+	return nil
+}
+
+// etagListMatches compares a comma-separated If-Match/If-None-Match value
+// with an ETag. Weak comparison ignores the W/ prefix.
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		} else if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/mail"
+	"ecommerce-backend/middleware"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository/repotest"
+	"ecommerce-backend/services"
+	"ecommerce-backend/services/users"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var updatedAt = time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+
+// usersApp serves the admin user routes over a fake repository holding one
+// user, and returns that user's current ETag
+func usersApp(t *testing.T) (*fiber.App, string) {
+	t.Helper()
+	cfg := config.Default()
+	repo := repotest.NewUsers(models.User{FirstName: "Siti", Email: "siti@example.com", Role: users.RoleUser, IsActive: true, UpdatedAt: updatedAt})
+	h := New(&cfg, services.Services{Users: users.NewService(repo, mail.NewFake(), cfg.Store)})
+
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler})
+	app.Get("/admin/users/:id", h.GetAdminUser)
+	app.Patch("/admin/users/:id", h.UpdateUser)
+	return app, entityValidators("user", 1, 1, updatedAt).ETag
+}
+
+// send makes a request with headers and returns the response and its body
+func send(t *testing.T, app *fiber.App, method, path, body string, headers map[string]string) (*http.Response, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(res.Body)
+	var decoded map[string]interface{}
+	_ = json.Unmarshal(raw, &decoded)
+	return res, decoded
+}
+
+func TestConditionalGet(t *testing.T) {
+	app, etag := usersApp(t)
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{name: "no validators", wantStatus: fiber.StatusOK},
+		{name: "matching etag", headers: map[string]string{"If-None-Match": etag}, wantStatus: fiber.StatusNotModified},
+		{name: "weak match", headers: map[string]string{"If-None-Match": "W/" + etag}, wantStatus: fiber.StatusNotModified},
+		{name: "one of a list", headers: map[string]string{"If-None-Match": `"stale", ` + etag}, wantStatus: fiber.StatusNotModified},
+		{name: "any", headers: map[string]string{"If-None-Match": "*"}, wantStatus: fiber.StatusNotModified},
+		{name: "stale etag", headers: map[string]string{"If-None-Match": `"stale"`}, wantStatus: fiber.StatusOK},
+		{
+			name:       "unchanged since",
+			headers:    map[string]string{"If-Modified-Since": updatedAt.Format(http.TimeFormat)},
+			wantStatus: fiber.StatusNotModified,
+		},
+		{
+			name:       "changed since",
+			headers:    map[string]string{"If-Modified-Since": updatedAt.Add(-time.Hour).Format(http.TimeFormat)},
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			// If-None-Match wins over If-Modified-Since
+			name:       "stale etag but unchanged since",
+			headers:    map[string]string{"If-None-Match": `"stale"`, "If-Modified-Since": updatedAt.Format(http.TimeFormat)},
+			wantStatus: fiber.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := send(t, app, fiber.MethodGet, "/admin/users/1", "", tt.headers)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if res.Header.Get(fiber.HeaderETag) != etag {
+				t.Errorf("ETag = %s, want %s", res.Header.Get(fiber.HeaderETag), etag)
+			}
+			if res.Header.Get(fiber.HeaderLastModified) != updatedAt.Format(http.TimeFormat) {
+				t.Errorf("Last-Modified = %s", res.Header.Get(fiber.HeaderLastModified))
+			}
+			if tt.wantStatus == fiber.StatusOK && body["email"] != "siti@example.com" {
+				t.Errorf("body = %v, want the user", body)
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		ifMatch    func(etag string) string
+		wantStatus int
+		wantCode   apperror.Code
+	}{
+		{name: "current etag", ifMatch: func(etag string) string { return etag }, wantStatus: fiber.StatusOK},
+		{name: "any", ifMatch: func(string) string { return "*" }, wantStatus: fiber.StatusOK},
+		{name: "one of a list", ifMatch: func(etag string) string { return `"stale", ` + etag }, wantStatus: fiber.StatusOK},
+		{
+			name:       "missing",
+			ifMatch:    func(string) string { return "" },
+			wantStatus: fiber.StatusPreconditionRequired,
+			wantCode:   apperror.CodePreconditionRequired,
+		},
+		{
+			name:       "stale etag",
+			ifMatch:    func(string) string { return `"stale"` },
+			wantStatus: fiber.StatusPreconditionFailed,
+			wantCode:   apperror.CodePreconditionFailed,
+		},
+		{
+			// If-Match uses strong comparison
+			name:       "weak etag",
+			ifMatch:    func(etag string) string { return "W/" + etag },
+			wantStatus: fiber.StatusPreconditionFailed,
+			wantCode:   apperror.CodePreconditionFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, etag := usersApp(t)
+			headers := map[string]string{}
+			if ifMatch := tt.ifMatch(etag); ifMatch != "" {
+				headers[fiber.HeaderIfMatch] = ifMatch
+			}
+
+			res, body := send(t, app, fiber.MethodPatch, "/admin/users/1", `{"first_name":"Sri"}`, headers)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", res.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantCode != "" && body["code"] != string(tt.wantCode) {
+				t.Errorf("code = %v, want %s", body["code"], tt.wantCode)
+			}
+
+			switch tt.wantStatus {
+			case fiber.StatusOK:
+				// The write moved the user to a new version, and a new ETag
+				if body["first_name"] != "Sri" || res.Header.Get(fiber.HeaderETag) == etag {
+					t.Errorf("updated user %v with ETag %s, want the edit under a new ETag", body, res.Header.Get(fiber.HeaderETag))
+				}
+			case fiber.StatusPreconditionFailed:
+				current, _ := body["current"].(map[string]interface{})
+				if current["first_name"] != "Siti" || res.Header.Get(fiber.HeaderETag) != etag {
+					t.Errorf("current = %v with ETag %s, want the unchanged user under %s", current, res.Header.Get(fiber.HeaderETag), etag)
+				}
+			}
+		})
+	}
+}
+
+func TestNewValidators(t *testing.T) {
+	stamp := versionStamp{ID: 1, Version: 1, UpdatedAt: updatedAt}
+	base := newValidators("product", []versionStamp{stamp}, "total=1")
+
+	tests := []struct {
+		name     string
+		other    validators
+		wantSame bool
+	}{
+		{name: "same inputs", other: newValidators("product", []versionStamp{stamp}, "total=1"), wantSame: true},
+		{name: "other kind", other: newValidators("user", []versionStamp{stamp}, "total=1")},
+		{name: "new version", other: newValidators("product", []versionStamp{{ID: 1, Version: 2, UpdatedAt: updatedAt}}, "total=1")},
+		{name: "touched", other: newValidators("product", []versionStamp{{ID: 1, Version: 1, UpdatedAt: updatedAt.Add(time.Millisecond)}}, "total=1")},
+		{name: "other extra", other: newValidators("product", []versionStamp{stamp}, "total=2")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.other.ETag == base.ETag) != tt.wantSame {
+				t.Errorf("ETags %s and %s: same = %v, want %v", base.ETag, tt.other.ETag, !tt.wantSame, tt.wantSame)
+			}
+		})
+	}
+
+	// Last-Modified is the newest stamp, to the second
+	v := newValidators("products", []versionStamp{stamp, {ID: 2, UpdatedAt: updatedAt.Add(90 * time.Minute).Add(400 * time.Millisecond)}})
+	if want := updatedAt.Add(90 * time.Minute); !v.LastModified.Equal(want) {
+		t.Errorf("LastModified = %v, want %v", v.LastModified, want)
+	}
+}
@@ -1,10 +1,11 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"strconv"
 
-	"ecommerce-backend/database"
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/cache"
 	"ecommerce-backend/models"
 
 	"time"
@@ -28,7 +29,7 @@ type SalesData struct {
 // @Security BearerAuth
 // @Success 200 {object} map[string]interface{}
 // @Router /admin/dashboard/stats [get]
-func GetDashboardStats(c *fiber.Ctx) error {
+func (h *Handler) GetDashboardStats(c *fiber.Ctx) error {
 	var stats struct {
 		TotalRevenue       int64 `json:"total_revenue"`
 		TotalOrders        int64 `json:"total_orders"`
@@ -45,38 +46,38 @@ func GetDashboardStats(c *fiber.Ctx) error {
 		OutOfStockProducts int64 `json:"out_of_stock_products"`
 	}
 
-	// Total revenue (from delivered orders only)
-	database.DB.Model(&models.Order{}).Where("status = ?", "delivered").
-		Select("COALESCE(SUM(total_amount), 0)").Scan(&stats.TotalRevenue)
+	// Total revenue (from delivered orders only, net of refunds)
+	dbFor(c).Model(&models.Order{}).Where("status = ?", "delivered").
+		Select("COALESCE(SUM(total_amount - refunded_amount), 0)").Scan(&stats.TotalRevenue)
 
 	// Total orders
-	database.DB.Model(&models.Order{}).Count(&stats.TotalOrders)
+	dbFor(c).Model(&models.Order{}).Count(&stats.TotalOrders)
 
 	// Total customers
-	database.DB.Model(&models.User{}).Where("role = ?", "user").Count(&stats.TotalCustomers)
+	dbFor(c).Model(&models.User{}).Where("role = ?", "user").Count(&stats.TotalCustomers)
 
 	// Total products
-	database.DB.Model(&models.Product{}).Count(&stats.TotalProducts)
+	dbFor(c).Model(&models.Product{}).Count(&stats.TotalProducts)
 
 	// Orders by status
-	database.DB.Model(&models.Order{}).Where("status = ?", "pending").Count(&stats.PendingOrders)
-	database.DB.Model(&models.Order{}).Where("status = ?", "processing").Count(&stats.ProcessingOrders)
-	database.DB.Model(&models.Order{}).Where("status = ?", "delivered").Count(&stats.DeliveredOrders)
+	dbFor(c).Model(&models.Order{}).Where("status = ?", "pending").Count(&stats.PendingOrders)
+	dbFor(c).Model(&models.Order{}).Where("status = ?", "processing").Count(&stats.ProcessingOrders)
+	dbFor(c).Model(&models.Order{}).Where("status = ?", "delivered").Count(&stats.DeliveredOrders)
 
 	// Active users
-	database.DB.Model(&models.User{}).Where("is_active = ? AND role = ?", true, "user").Count(&stats.ActiveUsers)
+	dbFor(c).Model(&models.User{}).Where("is_active = ? AND role = ?", true, "user").Count(&stats.ActiveUsers)
 
 	// Today's stats (only today, not 2 days)
 	today := time.Now().Format("2006-01-02")
 
-	database.DB.Model(&models.Order{}).Where("DATE(created_at) = ? AND status = ?", today, "delivered").
-		Select("COALESCE(SUM(total_amount), 0)").Scan(&stats.TodayRevenue)
-	database.DB.Model(&models.Order{}).Where("DATE(created_at) = ?", today).Count(&stats.TodayOrders)
-	database.DB.Model(&models.User{}).Where("DATE(created_at) = ? AND role = ?", today, "user").Count(&stats.TodayCustomers)
+	dbFor(c).Model(&models.Order{}).Where("DATE(created_at) = ? AND status = ?", today, "delivered").
+		Select("COALESCE(SUM(total_amount - refunded_amount), 0)").Scan(&stats.TodayRevenue)
+	dbFor(c).Model(&models.Order{}).Where("DATE(created_at) = ?", today).Count(&stats.TodayOrders)
+	dbFor(c).Model(&models.User{}).Where("DATE(created_at) = ? AND role = ?", today, "user").Count(&stats.TodayCustomers)
 
 	// Stock stats
-	database.DB.Model(&models.Product{}).Where("stock > 0 AND stock < 20 AND is_active = ?", true).Count(&stats.LowStockProducts)
-	database.DB.Model(&models.Product{}).Where("stock = 0 AND is_active = ?", true).Count(&stats.OutOfStockProducts)
+	dbFor(c).Model(&models.Product{}).Where("stock > 0 AND stock < 20 AND is_active = ?", true).Count(&stats.LowStockProducts)
+	dbFor(c).Model(&models.Product{}).Where("stock = 0 AND is_active = ?", true).Count(&stats.OutOfStockProducts)
 
 	return c.JSON(stats)
 }
@@ -91,20 +92,18 @@ func GetDashboardStats(c *fiber.Ctx) error {
 // @Param limit query int false "Number of orders to return" default(5)
 // @Success 200 {object} map[string]interface{}
 // @Router /admin/dashboard/recent-orders [get]
-func GetRecentOrders(c *fiber.Ctx) error {
+func (h *Handler) GetRecentOrders(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "5"))
 	if limit > 20 {
 		limit = 20
 	}
 
 	var orders []models.Order
-	if err := database.DB.Preload("User").
+	if err := dbFor(c).Preload("User").
 		Order("created_at DESC").
 		Limit(limit).
 		Find(&orders).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch recent orders",
-		})
+		return apperror.Internal("Failed to fetch recent orders", err)
 	}
 
 	return c.JSON(orders)
@@ -120,7 +119,7 @@ func GetRecentOrders(c *fiber.Ctx) error {
 // @Param limit query int false "Number of products to return" default(5)
 // @Success 200 {object} map[string]interface{}
 // @Router /admin/dashboard/top-products [get]
-func GetTopProducts(c *fiber.Ctx) error {
+func (h *Handler) GetTopProducts(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "5"))
 	if limit > 20 {
 		limit = 20
@@ -134,7 +133,7 @@ func GetTopProducts(c *fiber.Ctx) error {
 	}
 
 	var topProducts []TopProduct
-	if err := database.DB.Table("order_items").
+	if err := dbFor(c).Table("order_items").
 		Select("products.id as product_id, products.name, SUM(order_items.quantity) as total_sales, SUM(order_items.total_price) as total_revenue").
 		Joins("JOIN products ON order_items.product_id = products.id").
 		Joins("JOIN orders ON order_items.order_id = orders.id").
@@ -143,9 +142,7 @@ func GetTopProducts(c *fiber.Ctx) error {
 		Order("total_revenue DESC").
 		Limit(limit).
 		Scan(&topProducts).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch top products",
-		})
+		return apperror.Internal("Failed to fetch top products", err)
 	}
 
 	return c.JSON(topProducts)
@@ -160,9 +157,9 @@ func GetTopProducts(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param days query int false "Number of days" default(7)
 // @Success 200 {array} SalesData
-// @Failure 500 {object} map[string]interface{}
+// @Failure 500 {object} apperror.Problem
 // @Router /protected/admin/dashboard/sales-chart [get]
-func GetSalesChart(c *fiber.Ctx) error {
+func (h *Handler) GetSalesChart(c *fiber.Ctx) error {
 	days := 7
 	if c.Query("days") != "" {
 		if d, err := strconv.Atoi(c.Query("days")); err == nil && d > 0 && d <= 365 {
@@ -185,43 +182,20 @@ func GetSalesChart(c *fiber.Ctx) error {
 	var actualData []SalesData
 	startDate := time.Now().AddDate(0, 0, -6).Format("2006-01-02") // 6 days ago to include today
 
-	log.Printf("Chart query - startDate: %s", startDate)
-
-	// First, let's debug by looking at raw orders data
-	var rawOrders []struct {
-		ID          int64  `json:"id"`
-		OrderNumber string `json:"order_number"`
-		Status      string `json:"status"`
-		TotalAmount int64  `json:"total_amount"`
-		CreatedAt   string `json:"created_at"`
-		UpdatedAt   string `json:"updated_at"`
-	}
-
-	if err := database.DB.Raw(`
-		SELECT id, order_number, status, total_amount, created_at, updated_at
-		FROM orders 
-		ORDER BY created_at DESC
-		LIMIT 10
-	`).Scan(&rawOrders).Error; err != nil {
-		log.Printf("Raw orders query failed: %v", err)
-	} else {
-		log.Printf("Raw orders data: %+v", rawOrders)
-	}
+	ctx := c.UserContext()
 
 	// Try the chart query with all orders
-	if err := database.DB.Raw(`
+	if err := dbFor(c).Raw(`
 		SELECT 
 			DATE(created_at) as date,
-			COALESCE(SUM(total_amount), 0) as revenue,
+			COALESCE(SUM(total_amount - refunded_amount), 0) as revenue,
 			COUNT(*) as orders
 		FROM orders 
 		WHERE DATE(created_at) >= ?
 		GROUP BY DATE(created_at)
 		ORDER BY date ASC
 	`, startDate).Scan(&actualData).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch sales chart data",
-		})
+		return apperror.Internal("Failed to fetch sales chart data", err)
 	}
 
 	// Convert date format to YYYY-MM-DD only
@@ -231,25 +205,24 @@ func GetSalesChart(c *fiber.Ctx) error {
 		}
 	}
 
-	log.Printf("Chart query result (all orders): %+v", actualData)
+	slog.DebugContext(ctx, "Sales chart query", "start_date", startDate, "days_with_sales", len(actualData))
 
 	// If still no data, try without DATE function
 	if len(actualData) == 0 {
-		log.Printf("No data found, trying without DATE function...")
+		slog.DebugContext(ctx, "No sales chart data, retrying with DATE_TRUNC")
 		var altData []SalesData
-		if err := database.DB.Raw(`
+		if err := dbFor(c).Raw(`
 			SELECT 
 				DATE_TRUNC('day', created_at)::date as date,
-				COALESCE(SUM(total_amount), 0) as revenue,
+				COALESCE(SUM(total_amount - refunded_amount), 0) as revenue,
 				COUNT(*) as orders
 			FROM orders 
 			WHERE created_at >= ?
 			GROUP BY DATE_TRUNC('day', created_at)::date
 			ORDER BY date ASC
 		`, time.Now().AddDate(0, 0, -6)).Scan(&altData).Error; err != nil {
-			log.Printf("Alternative query failed: %v", err)
+			slog.WarnContext(ctx, "Sales chart fallback query failed", "error", err)
 		} else {
-			log.Printf("Alternative query result: %+v", altData)
 			actualData = altData
 		}
 	}
@@ -268,3 +241,16 @@ func GetSalesChart(c *fiber.Ctx) error {
 
 	return c.JSON(salesData)
 }
+
+// GetCacheStats returns read-through cache hit/miss counters
+// @Summary Get cache statistics
+// @Description Get hit, miss and error counters for each cache namespace since startup
+// @Tags dashboard
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]cache.Stats
+// @Router /admin/dashboard/cache-stats [get]
+func (h *Handler) GetCacheStats(c *fiber.Ctx) error {
+	return c.JSON(cache.Snapshot())
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"ecommerce-backend/database"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// dbFor returns the database handle bound to the request's user context, so
+// GORM's logger can tag every query with the request ID
+func dbFor(c *fiber.Ctx) *gorm.DB {
+	return database.DB.WithContext(c.UserContext())
+}
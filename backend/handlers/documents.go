@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/services/documents"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetOrderInvoice downloads the invoice of one of the user's orders
+// @Summary Download order invoice
+// @Description Download the order's invoice as a PDF. Invoices are issued when the order is paid.
+// @Tags checkout
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {file} file
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Router /protected/checkout/orders/{id}/invoice [get]
+func (h *Handler) GetOrderInvoice(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	id, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	doc, err := h.svc.Documents.InvoiceForUser(c.UserContext(), user.ID, id)
+	if err != nil {
+		return err
+	}
+	return sendDocument(c, doc)
+}
+
+// GetAdminOrderInvoice downloads an order's invoice for admin
+// @Summary Download order invoice (admin)
+// @Description Download the order's invoice as a PDF (admin only)
+// @Tags orders
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {file} file
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Router /admin/orders/{id}/invoice [get]
+func (h *Handler) GetAdminOrderInvoice(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	doc, err := h.svc.Documents.Invoices(c.UserContext(), []uint{id})
+	if err != nil {
+		return err
+	}
+	return sendDocument(c, doc)
+}
+
+// GetOrderPackingSlip downloads an order's packing slip for admin
+// @Summary Download packing slip (admin)
+// @Description Download the order's packing slip as a PDF (admin only)
+// @Tags orders
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {file} file
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/orders/{id}/packing-slip [get]
+func (h *Handler) GetOrderPackingSlip(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	doc, err := h.svc.Documents.PackingSlips(c.UserContext(), []uint{id})
+	if err != nil {
+		return err
+	}
+	return sendDocument(c, doc)
+}
+
+// PrintInvoices downloads the invoices of several orders as one PDF
+// @Summary Print invoices in bulk (admin)
+// @Description Download the invoices of up to 100 orders as one PDF, one order per page, in the order given (admin only). Every order must have been paid.
+// @Tags orders
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param ids query string true "Comma-separated order IDs"
+// @Success 200 {file} file
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Router /admin/orders/invoices [get]
+func (h *Handler) PrintInvoices(c *fiber.Ctx) error {
+	ids, err := idsQuery(c, "ids")
+	if err != nil {
+		return err
+	}
+
+	doc, err := h.svc.Documents.Invoices(c.UserContext(), ids)
+	if err != nil {
+		return err
+	}
+	return sendDocument(c, doc)
+}
+
+// PrintPackingSlips downloads the packing slips of several orders as one PDF
+// @Summary Print packing slips in bulk (admin)
+// @Description Download the packing slips of up to 100 orders as one PDF, one order per page, in the order given (admin only)
+// @Tags orders
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param ids query string true "Comma-separated order IDs"
+// @Success 200 {file} file
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/orders/packing-slips [get]
+func (h *Handler) PrintPackingSlips(c *fiber.Ctx) error {
+	ids, err := idsQuery(c, "ids")
+	if err != nil {
+		return err
+	}
+
+	doc, err := h.svc.Documents.PackingSlips(c.UserContext(), ids)
+	if err != nil {
+		return err
+	}
+	return sendDocument(c, doc)
+}
+
+// sendDocument sends doc as a download. Documents carry customer details, so
+// they are never cached.
+func sendDocument(c *fiber.Ctx, doc documents.Document) error {
+	c.Attachment(doc.Filename)
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.Send(doc.Content)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"ecommerce-backend/models"
+	"ecommerce-backend/services/cart"
+	"ecommerce-backend/services/checkout"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GuestCheckout places an order for a shopper without an account
+// @Summary Check out as a guest
+// @Description Place an order for the items of a cart kept in the browser, without signing in. The order belongs to a guest customer with the given email, who is emailed a link to look it up; registering later with the same email turns the guest into an account that keeps these orders. Emails of existing accounts have to sign in instead.
+// @Tags checkout
+// @Accept json
+// @Produce json
+// @Param request body GuestCheckoutRequest true "Guest checkout data"
+// @Success 201 {object} GuestCheckoutResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /guest/checkout [post]
+func (h *Handler) GuestCheckout(c *fiber.Ctx) error {
+	return countCheckoutFailure(h.placeGuestOrder(c))
+}
+
+// placeGuestOrder places the order; GuestCheckout wraps it to count
+// failures by reason
+func (h *Handler) placeGuestOrder(c *fiber.Ctx) error {
+	var req GuestCheckoutRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	items := make([]cart.GuestItem, len(req.Items))
+	for i, item := range req.Items {
+		items[i] = cart.GuestItem{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+
+	ctx := c.UserContext()
+	slog.DebugContext(ctx, "Guest checkout started", "items", len(items))
+
+	order, token, err := h.svc.Checkout.PlaceGuestOrder(ctx, checkout.GuestRequest{
+		Email:           req.Email,
+		FirstName:       req.FirstName,
+		LastName:        req.LastName,
+		Phone:           req.Phone,
+		Items:           items,
+		ShippingAddress: req.ShippingAddress,
+		PaymentMethod:   req.PaymentMethod,
+		Notes:           req.Notes,
+	})
+	if err != nil {
+		return err
+	}
+
+	orderPlaced(ctx, order)
+	return c.Status(fiber.StatusCreated).JSON(GuestCheckoutResponse{
+		CheckoutResponse: checkoutResponse(order),
+		LookupToken:      token,
+	})
+}
+
+// LookupGuestOrder returns the order a guest's lookup token opens
+// @Summary Look up a guest order
+// @Description Get a guest order with its items, shipments and returns, using the token from the link emailed when it was placed
+// @Tags checkout
+// @Produce json
+// @Param token query string true "Lookup token"
+// @Success 200 {object} models.Order
+// @Failure 404 {object} apperror.Problem
+// @Router /guest/orders [get]
+func (h *Handler) LookupGuestOrder(c *fiber.Ctx) error {
+	order, err := h.svc.Orders.Lookup(c.UserContext(), c.Query("token"))
+	if err != nil {
+		return err
+	}
+
+	// The link is as good as a password, so keep the page out of caches
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(order)
+}
+
+// Request/Response types
+type GuestCheckoutRequest struct {
+	Email           string          `json:"email" validate:"required,email"`
+	FirstName       string          `json:"first_name" validate:"required"`
+	LastName        string          `json:"last_name"`
+	Phone           string          `json:"phone"`
+	Items           []GuestCartItem `json:"items" validate:"required,min=1,dive"`
+	ShippingAddress models.Address  `json:"shipping_address" validate:"required"`
+	PaymentMethod   string          `json:"payment_method" validate:"required,oneof=cod bank_transfer"`
+	Notes           string          `json:"notes"`
+}
+
+type GuestCartItem struct {
+	ProductID uint `json:"product_id" validate:"required"`
+	Quantity  int  `json:"quantity" validate:"required,min=1"`
+}
+
+type GuestCheckoutResponse struct {
+	CheckoutResponse
+	// LookupToken opens the order at /guest/orders, like the emailed link
+	LookupToken string `json:"lookup_token"`
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Handler serves the HTTP API. Its methods are the route handlers; it holds
+// the configuration and the services they need, so handlers deal with HTTP
+// and leave business rules and persistence to the services.
+type Handler struct {
+	cfg *config.Config
+	svc services.Services
+}
+
+// New creates a Handler using cfg and svc
+func New(cfg *config.Config, svc services.Services) *Handler {
+	return &Handler{cfg: cfg, svc: svc}
+}
+
+// pageQuery reads the page and limit query parameters
+func pageQuery(c *fiber.Ctx) repository.Page {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", strconv.Itoa(repository.DefaultPageSize)))
+	return repository.NewPage(page, limit)
+}
+
+// pagination describes a page of a list in responses
+func pagination(p repository.Page, total int64) fiber.Map {
+	return fiber.Map{
+		"page":       p.Page,
+		"limit":      p.Limit,
+		"total":      total,
+		"totalPages": p.TotalPages(total),
+	}
+}
+
+// idParam parses a numeric ID route parameter, failing with 400 and the
+// given detail when it is not one
+func idParam(c *fiber.Ctx, name, detail string) (uint, error) {
+	id, err := strconv.ParseUint(c.Params(name), 10, 32)
+	if err != nil || id == 0 {
+		return 0, apperror.BadRequest(apperror.CodeInvalidRequest, detail)
+	}
+	return uint(id), nil
+}
+
+// idsQuery parses a comma-separated list of numeric IDs from a query
+// parameter, failing with 400 when any of them is not one
+func idsQuery(c *fiber.Ctx, name string) ([]uint, error) {
+	var ids []uint
+	for _, part := range strings.Split(c.Query(name), ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || id == 0 {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Invalid ID in "+name).With("value", part)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ecommerce-backend/database"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// shuttingDown flips once the server has been asked to stop, so readiness
+// fails and load balancers drain traffic before the listener closes
+var shuttingDown atomic.Bool
+
+// MarkShuttingDown makes the readiness probe report the server as unavailable
+func MarkShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// DependencyStatus is the readiness result for one dependency. Why a check
+// failed is only logged, since driver errors can name hosts and users.
+type DependencyStatus struct {
+	Status string `json:"status"`
+}
+
+// ReadinessResponse reports overall readiness and each dependency
+type ReadinessResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// dependency is something readiness depends on. A critical dependency being
+// down makes the service unready; Redis is not critical because the cache and
+// rate limiter fall back when it is unavailable.
+type dependency struct {
+	name     string
+	critical bool
+	ping     func(ctx context.Context) error
+}
+
+var dependencies = []dependency{
+	{name: "database", critical: true, ping: database.PingDB},
+	{name: "redis", critical: false, ping: database.PingRedis},
+}
+
+// Liveness reports that the process is up and serving requests
+// @Summary Liveness probe
+// @Description Returns 200 while the process is running. It does not check dependencies.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /health/live [get]
+func (h *Handler) Liveness(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Readiness checks every dependency in parallel, each with its own timeout
+// @Summary Readiness probe
+// @Description Pings Postgres and Redis. Returns 503 when a critical dependency is down or the server is shutting down; Redis being down only degrades the service.
+// @Tags health
+// @Produce json
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /health/ready [get]
+func (h *Handler) Readiness(c *fiber.Ctx) error {
+	timeout := h.cfg.Server.HealthCheckTimeout
+
+	parent := c.UserContext()
+	results := make(map[string]DependencyStatus, len(dependencies))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, dep := range dependencies {
+		wg.Add(1)
+		go func(dep dependency) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(parent, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := dep.ping(ctx)
+			result := DependencyStatus{Status: "up"}
+			if err != nil {
+				result.Status = "down"
+				slog.WarnContext(parent, "Readiness check failed", "dependency", dep.name,
+					"critical", dep.critical, "duration", time.Since(start), "error", err)
+			}
+
+			mu.Lock()
+			results[dep.name] = result
+			mu.Unlock()
+		}(dep)
+	}
+	wg.Wait()
+
+	response := ReadinessResponse{Status: "ok", Dependencies: results}
+	for _, dep := range dependencies {
+		if results[dep.name].Status == "up" {
+			continue
+		}
+		if dep.critical {
+			response.Status = "unavailable"
+			break
+		}
+		response.Status = "degraded"
+	}
+	if shuttingDown.Load() {
+		response.Status = "shutting_down"
+	}
+
+	if response.Status == "unavailable" || response.Status == "shutting_down" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(response)
+	}
+	return c.JSON(response)
+}
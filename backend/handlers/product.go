@@ -2,12 +2,13 @@ package handlers
 
 import (
 	"strconv"
+	"strings"
 
-	"ecommerce-backend/database"
+	"ecommerce-backend/apperror"
 	"ecommerce-backend/models"
+	"ecommerce-backend/services/catalog"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 )
 
 // GetProducts returns all products with pagination and filtering
@@ -24,61 +25,31 @@ import (
 // @Param max_price query int false "Maximum price filter"
 // @Success 200 {object} map[string]interface{}
 // @Router /products [get]
-func GetProducts(c *fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	category := c.Query("category")
-	search := c.Query("search")
-	minPrice := c.Query("min_price")
-	maxPrice := c.Query("max_price")
-
-	offset := (page - 1) * limit
-
-	var products []models.Product
-	var total int64
-
-	query := database.DB.Model(&models.Product{}).Preload("Category")
-
-	// Apply filters
-	if category != "" {
-		query = query.Joins("JOIN categories ON products.category_id = categories.id").
-			Where("categories.name ILIKE ?", "%"+category+"%")
-	}
-
-	if search != "" {
-		query = query.Where("products.name ILIKE ? OR products.description ILIKE ?",
-			"%"+search+"%", "%"+search+"%")
-	}
-
-	if minPrice != "" {
-		query = query.Where("products.price >= ?", minPrice)
-	}
-
-	if maxPrice != "" {
-		query = query.Where("products.price <= ?", maxPrice)
+func (h *Handler) GetProducts(c *fiber.Ctx) error {
+	q, err := productQuery(c)
+	if err != nil {
+		return err
 	}
 
-	// Only active products
-	query = query.Where("products.is_active = ?", true)
-
-	// Count total records
-	query.Count(&total)
+	ctx := c.UserContext()
+	return h.sendCachedJSON(c, cacheGroupProductLists, productListCacheKey(ctx, q), func() (interface{}, validators, error) {
+		products, total, err := h.svc.Catalog.ListProducts(ctx, q)
+		if err != nil {
+			return nil, validators{}, err
+		}
 
-	// Get products with pagination
-	if err := query.Offset(offset).Limit(limit).Find(&products).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch products",
-		})
-	}
+		stamps := make([]versionStamp, 0, len(products)*2)
+		for _, product := range products {
+			stamps = append(stamps,
+				versionStamp{ID: product.ID, Version: product.Version, UpdatedAt: product.UpdatedAt},
+				versionStamp{ID: product.Category.ID, UpdatedAt: product.Category.UpdatedAt})
+		}
+		v := newValidators("products", stamps, strconv.Itoa(q.Page.Page), strconv.Itoa(q.Page.Limit), strconv.FormatInt(total, 10))
 
-	return c.JSON(fiber.Map{
-		"products": products,
-		"pagination": fiber.Map{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": (total + int64(limit) - 1) / int64(limit),
-		},
+		return fiber.Map{
+			"products":   products,
+			"pagination": pagination(q.Page, total),
+		}, v, nil
 	})
 }
 
@@ -90,29 +61,33 @@ func GetProducts(c *fiber.Ctx) error {
 // @Produce json
 // @Param id path int true "Product ID"
 // @Success 200 {object} models.Product
-// @Failure 404 {object} map[string]interface{}
+// @Success 304
+// @Failure 404 {object} apperror.Problem
 // @Router /products/{id} [get]
-func GetProduct(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+func (h *Handler) GetProduct(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid product ID")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid product ID",
-		})
+		return err
 	}
 
-	var product models.Product
-	if err := database.DB.Preload("Category").Preload("Reviews.User").First(&product, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Product not found",
-			})
+	ctx := c.UserContext()
+	return h.sendCachedJSON(c, cacheGroupProductDetails, productDetailCacheKey(ctx, int(id)), func() (interface{}, validators, error) {
+		product, err := h.svc.Catalog.GetProduct(ctx, id)
+		if err != nil {
+			return nil, validators{}, err
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch product",
-		})
-	}
 
-	return c.JSON(product)
+		// The detail embeds the category and reviews, so their changes count too
+		stamps := []versionStamp{
+			{ID: product.ID, Version: product.Version, UpdatedAt: product.UpdatedAt},
+			{ID: product.Category.ID, UpdatedAt: product.Category.UpdatedAt},
+		}
+		for _, review := range product.Reviews {
+			stamps = append(stamps, versionStamp{ID: review.ID, UpdatedAt: review.UpdatedAt})
+		}
+
+		return product, newValidators("product", stamps), nil
+	})
 }
 
 // GetCategories returns all categories
@@ -122,16 +97,23 @@ func GetProduct(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Success 200 {array} models.Category
+// @Success 304
 // @Router /categories [get]
-func GetCategories(c *fiber.Ctx) error {
-	var categories []models.Category
-	if err := database.DB.Where("is_active = ?", true).Find(&categories).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch categories",
-		})
-	}
+func (h *Handler) GetCategories(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	return h.sendCachedJSON(c, cacheGroupCategories, categoriesCacheKey(ctx), func() (interface{}, validators, error) {
+		categories, err := h.svc.Catalog.ListCategories(ctx)
+		if err != nil {
+			return nil, validators{}, err
+		}
 
-	return c.JSON(categories)
+		stamps := make([]versionStamp, len(categories))
+		for i, category := range categories {
+			stamps[i] = versionStamp{ID: category.ID, UpdatedAt: category.UpdatedAt}
+		}
+
+		return categories, newValidators("categories", stamps), nil
+	})
 }
 
 // GetAdminProducts returns all products (including inactive ones) for admin
@@ -149,59 +131,48 @@ func GetCategories(c *fiber.Ctx) error {
 // @Param max_price query int false "Maximum price filter"
 // @Success 200 {object} map[string]interface{}
 // @Router /admin/products [get]
-func GetAdminProducts(c *fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	category := c.Query("category")
-	search := c.Query("search")
-	minPrice := c.Query("min_price")
-	maxPrice := c.Query("max_price")
-
-	offset := (page - 1) * limit
-
-	var products []models.Product
-	var total int64
-
-	query := database.DB.Model(&models.Product{}).Preload("Category").Unscoped()
-
-	// Apply filters
-	if category != "" {
-		query = query.Joins("JOIN categories ON products.category_id = categories.id").
-			Where("categories.name ILIKE ?", "%"+category+"%")
+func (h *Handler) GetAdminProducts(c *fiber.Ctx) error {
+	q, err := productQuery(c)
+	if err != nil {
+		return err
 	}
+	q.IncludeInactive = true
 
-	if search != "" {
-		query = query.Where("products.name ILIKE ? OR products.description ILIKE ?",
-			"%"+search+"%", "%"+search+"%")
+	products, total, err := h.svc.Catalog.ListProducts(c.UserContext(), q)
+	if err != nil {
+		return err
 	}
 
-	if minPrice != "" {
-		query = query.Where("products.price >= ?", minPrice)
-	}
+	return c.JSON(fiber.Map{
+		"products":   products,
+		"pagination": pagination(q.Page, total),
+	})
+}
 
-	if maxPrice != "" {
-		query = query.Where("products.price <= ?", maxPrice)
+// GetAdminProduct returns a single product (including inactive ones) for admin
+// @Summary Get product by ID (admin)
+// @Description Get a single product by ID, with the ETag to send as If-Match when updating it (admin only)
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Success 200 {object} models.Product
+// @Success 304
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/products/{id} [get]
+func (h *Handler) GetAdminProduct(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid product ID")
+	if err != nil {
+		return err
 	}
 
-	// Count total records
-	query.Count(&total)
-
-	// Get products with pagination
-	if err := query.Offset(offset).Limit(limit).Find(&products).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch products",
-		})
+	product, err := h.svc.Catalog.GetAdminProduct(c.UserContext(), id)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"products": products,
-		"pagination": fiber.Map{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": (total + int64(limit) - 1) / int64(limit),
-		},
-	})
+	return sendWithValidators(c, entityValidators("product", product.ID, product.Version, product.UpdatedAt), product)
 }
 
 // CreateProduct creates a new product (admin only)
@@ -213,67 +184,77 @@ func GetAdminProducts(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param product body models.Product true "Product data"
 // @Success 201 {object} models.Product
-// @Failure 400 {object} map[string]interface{}
-// @Failure 403 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
 // @Router /admin/products [post]
-func CreateProduct(c *fiber.Ctx) error {
+func (h *Handler) CreateProduct(c *fiber.Ctx) error {
 	var product models.Product
-	if err := c.BodyParser(&product); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &product); err != nil {
+		return err
 	}
-
-	if err := database.DB.Create(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create product",
-		})
+	if err := h.svc.Catalog.CreateProduct(c.UserContext(), &product); err != nil {
+		return err
 	}
 
+	onProductChanged(c.UserContext(), product.ID)
+
 	return c.Status(fiber.StatusCreated).JSON(product)
 }
 
 // UpdateProduct updates an existing product (admin only)
 // @Summary Update product
-// @Description Update an existing product (admin only)
+// @Description Update only the fields present in the body of an existing product (admin only)
 // @Tags products
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Product ID"
-// @Param product body models.Product true "Product data"
+// @Param If-Match header string true "ETag from GET /admin/products/{id}"
+// @Param product body UpdateProductRequest true "Fields to change"
 // @Success 200 {object} models.Product
-// @Failure 400 {object} map[string]interface{}
-// @Failure 403 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 412 {object} apperror.Problem
+// @Failure 428 {object} apperror.Problem
 // @Router /admin/products/{id} [put]
-func UpdateProduct(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+// @Router /admin/products/{id} [patch]
+func (h *Handler) UpdateProduct(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid product ID")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid product ID",
-		})
+		return err
 	}
 
-	var product models.Product
-	if err := database.DB.First(&product, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
-		})
+	ctx := c.UserContext()
+	product, err := h.svc.Catalog.GetAdminProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := checkIfMatch(c, entityValidators("product", product.ID, product.Version, product.UpdatedAt), product); err != nil {
+		return err
+	}
+
+	var req UpdateProductRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	if err := c.BodyParser(&product); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	product, updated, err := h.svc.Catalog.UpdateProduct(ctx, product.ID,
+		expectedVersion(req.Version, product.Version), req.changes())
+	if err != nil {
+		return err
 	}
 
-	if err := database.DB.Save(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update product",
-		})
+	v := entityValidators("product", product.ID, product.Version, product.UpdatedAt)
+	if !updated {
+		return versionConflict(c, v, product)
 	}
 
+	onProductChanged(ctx, product.ID)
+
+	c.Set(fiber.HeaderETag, v.ETag)
 	return c.JSON(product)
 }
 
@@ -286,32 +267,204 @@ func UpdateProduct(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path int true "Product ID"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 403 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
 // @Router /admin/products/{id} [delete]
-func DeleteProduct(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+func (h *Handler) DeleteProduct(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid product ID")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid product ID",
-		})
+		return err
 	}
 
-	var product models.Product
-	if err := database.DB.First(&product, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
-		})
+	if err := h.svc.Catalog.DeleteProduct(c.UserContext(), id); err != nil {
+		return err
 	}
 
-	if err := database.DB.Delete(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete product",
-		})
-	}
+	onProductChanged(c.UserContext(), id)
 
 	return c.JSON(fiber.Map{
 		"message": "Product deleted successfully",
 	})
 }
+
+// CreateCategory creates a new category (admin only)
+// @Summary Create new category
+// @Description Create a new product category (admin only)
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param category body models.Category true "Category data"
+// @Success 201 {object} models.Category
+// @Failure 400 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Router /admin/categories [post]
+func (h *Handler) CreateCategory(c *fiber.Ctx) error {
+	var category models.Category
+	if err := parseBody(c, &category); err != nil {
+		return err
+	}
+
+	if err := h.svc.Catalog.CreateCategory(c.UserContext(), &category); err != nil {
+		return err
+	}
+
+	onCategoryChanged(c.UserContext())
+
+	return c.Status(fiber.StatusCreated).JSON(category)
+}
+
+// UpdateCategory updates an existing category (admin only)
+// @Summary Update category
+// @Description Update an existing product category (admin only)
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Param category body models.Category true "Category data"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/categories/{id} [put]
+func (h *Handler) UpdateCategory(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid category ID")
+	if err != nil {
+		return err
+	}
+
+	category, err := h.svc.Catalog.GetCategory(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	if err := parseBody(c, &category); err != nil {
+		return err
+	}
+	category.ID = id
+
+	if err := h.svc.Catalog.UpdateCategory(c.UserContext(), &category); err != nil {
+		return err
+	}
+
+	onCategoryChanged(c.UserContext())
+
+	return c.JSON(category)
+}
+
+// DeleteCategory soft deletes a category (admin only)
+// @Summary Delete category
+// @Description Soft delete a product category (admin only)
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/categories/{id} [delete]
+func (h *Handler) DeleteCategory(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid category ID")
+	if err != nil {
+		return err
+	}
+
+	if err := h.svc.Catalog.DeleteCategory(c.UserContext(), id); err != nil {
+		return err
+	}
+
+	onCategoryChanged(c.UserContext())
+
+	return c.JSON(fiber.Map{
+		"message": "Category deleted successfully",
+	})
+}
+
+// productQuery reads the paging and filter query parameters of a product list.
+// The category and search match ignoring case, so they are trimmed and
+// lowercased to give lists that match the same products one cache key.
+func productQuery(c *fiber.Ctx) (catalog.ProductQuery, error) {
+	q := catalog.ProductQuery{
+		Page:     pageQuery(c),
+		Category: strings.ToLower(strings.TrimSpace(c.Query("category"))),
+		Search:   strings.ToLower(strings.TrimSpace(c.Query("search"))),
+	}
+	var err error
+	if q.MinPrice, err = priceQuery(c, "min_price"); err != nil {
+		return q, err
+	}
+	if q.MaxPrice, err = priceQuery(c, "max_price"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// priceQuery parses an optional price query parameter
+func priceQuery(c *fiber.Ctx, name string) (*int64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || price < 0 {
+		return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Invalid "+name)
+	}
+	return &price, nil
+}
+
+// Request/Response types
+type UpdateProductRequest struct {
+	Name        *string   `json:"name" validate:"omitempty,min=1"`
+	Description *string   `json:"description"`
+	Price       *int64    `json:"price" validate:"omitempty,gt=0"`
+	Stock       *int      `json:"stock" validate:"omitempty,gte=0"`
+	CategoryID  *uint     `json:"category_id" validate:"omitempty,gt=0"`
+	Image       *string   `json:"image"`
+	Images      *[]string `json:"images"`
+	SKU         *string   `json:"sku"`
+	Weight      *float64  `json:"weight"`
+	Dimensions  *string   `json:"dimensions"`
+	IsActive    *bool     `json:"is_active"`
+	Version     *uint     `json:"version"`
+}
+
+// changes returns the columns to update, limited to the fields that were sent
+func (r UpdateProductRequest) changes() map[string]interface{} {
+	changes := make(map[string]interface{})
+	if r.Name != nil {
+		changes["name"] = *r.Name
+	}
+	if r.Description != nil {
+		changes["description"] = *r.Description
+	}
+	if r.Price != nil {
+		changes["price"] = *r.Price
+	}
+	if r.Stock != nil {
+		changes["stock"] = *r.Stock
+	}
+	if r.CategoryID != nil {
+		changes["category_id"] = *r.CategoryID
+	}
+	if r.Image != nil {
+		changes["image"] = *r.Image
+	}
+	if r.Images != nil {
+		changes["images"] = *r.Images
+	}
+	if r.SKU != nil {
+		changes["sku"] = *r.SKU
+	}
+	if r.Weight != nil {
+		changes["weight"] = *r.Weight
+	}
+	if r.Dimensions != nil {
+		changes["dimensions"] = *r.Dimensions
+	}
+	if r.IsActive != nil {
+		changes["is_active"] = *r.IsActive
+	}
+	return changes
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/refunds"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateRefund pays back part or all of an order (admin only)
+// @Summary Refund order (admin)
+// @Description Refund a paid order through the payment provider or as a manual bank transfer. Give items to refund them with their share of shipping and tax, an amount to refund that much, or neither to refund everything not yet refunded. The order becomes partially_refunded, or refunded once its refunds cover its total.
+// @Tags refunds
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param request body CreateRefundRequest true "Refund data"
+// @Success 201 {object} models.Refund
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Failure 502 {object} apperror.Problem
+// @Router /admin/orders/{id}/refunds [post]
+func (h *Handler) CreateRefund(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	orderID, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	var req CreateRefundRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	r := refunds.Request{
+		Amount:        req.Amount,
+		Reason:        req.Reason,
+		Method:        req.Method,
+		BankReference: req.BankReference,
+		CreatedBy:     &user.ID,
+	}
+	for _, item := range req.Items {
+		r.Lines = append(r.Lines, refunds.Line{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+
+	refund, err := h.svc.Refunds.Create(c.UserContext(), orderID, r)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(refund)
+}
+
+// GetOrderRefunds lists an order's refunds (admin only)
+// @Summary List order refunds (admin)
+// @Description List an order's refunds, oldest first, including pending and failed ones (admin only)
+// @Tags refunds
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} models.Refund
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/orders/{id}/refunds [get]
+func (h *Handler) GetOrderRefunds(c *fiber.Ctx) error {
+	orderID, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	list, err := h.svc.Refunds.ListByOrder(c.UserContext(), orderID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
+
+// GetAdminRefunds lists refunds (admin only)
+// @Summary List refunds (admin)
+// @Description List refunds, newest first, optionally by status, method or order (admin only)
+// @Tags refunds
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Param status query string false "Filter by status"
+// @Param method query string false "Filter by method"
+// @Param order_id query int false "Filter by order"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/refunds [get]
+func (h *Handler) GetAdminRefunds(c *fiber.Ctx) error {
+	q := refunds.Query{
+		Page: pageQuery(c),
+		Filter: repository.RefundFilter{
+			Status:  c.Query("status"),
+			Method:  c.Query("method"),
+			OrderID: uint(c.QueryInt("order_id")),
+		},
+	}
+
+	list, total, err := h.svc.Refunds.List(c.UserContext(), q)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"refunds":    list,
+		"pagination": pagination(q.Page, total),
+	})
+}
+
+// GetAdminRefund returns a refund by ID (admin only)
+// @Summary Get refund (admin)
+// @Description Get a refund with its items (admin only)
+// @Tags refunds
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Refund ID"
+// @Success 200 {object} models.Refund
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/refunds/{id} [get]
+func (h *Handler) GetAdminRefund(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid refund ID")
+	if err != nil {
+		return err
+	}
+
+	refund, err := h.svc.Refunds.Get(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(refund)
+}
+
+// Request/Response types
+type CreateRefundRequest struct {
+	Items         []RefundItemRequest `json:"items" validate:"dive"`
+	Amount        *int64              `json:"amount" validate:"omitempty,min=1"`
+	Reason        string              `json:"reason" validate:"required"`
+	Method        string              `json:"method" validate:"omitempty,oneof=provider manual_bank"`
+	BankReference string              `json:"bank_reference"`
+}
+
+type RefundItemRequest struct {
+	OrderItemID uint `json:"order_item_id" validate:"required"`
+	Quantity    int  `json:"quantity" validate:"required,min=1"`
+}
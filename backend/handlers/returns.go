@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"context"
+
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/services/refunds"
+	"ecommerce-backend/services/returns"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestReturn opens a return of some of a delivered order's items
+// @Summary Request a return
+// @Description Ask to return some of a delivered order's items, with a reason and photo URLs. Returns can be requested within the configured window after delivery, for no more than was ordered and not already returned.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param request body ReturnRequest true "Return data"
+// @Success 201 {object} models.Return
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Router /protected/checkout/orders/{id}/returns [post]
+func (h *Handler) RequestReturn(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	orderID, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	var req ReturnRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	r := returns.Request{Reason: req.Reason, Photos: req.Photos}
+	for _, item := range req.Items {
+		r.Lines = append(r.Lines, returns.Line{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+
+	ret, err := h.svc.Returns.Request(c.UserContext(), user.ID, orderID, r)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(ret)
+}
+
+// GetMyReturns lists the user's returns
+// @Summary List my returns
+// @Description List the authenticated user's returns, newest first
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Return
+// @Failure 401 {object} apperror.Problem
+// @Router /protected/checkout/returns [get]
+func (h *Handler) GetMyReturns(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	list, err := h.svc.Returns.ListForUser(c.UserContext(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
+
+// GetMyReturn returns one of the user's returns
+// @Summary Get my return
+// @Description Get one of the authenticated user's returns with its items and photos
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Success 200 {object} models.Return
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /protected/checkout/returns/{id} [get]
+func (h *Handler) GetMyReturn(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	id, err := idParam(c, "id", "Invalid return ID")
+	if err != nil {
+		return err
+	}
+
+	ret, err := h.svc.Returns.GetForUser(c.UserContext(), user.ID, id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(ret)
+}
+
+// GetOrderTimeline lists what happened to one of the user's orders
+// @Summary Get order timeline
+// @Description List the order's history, oldest first: placement or cancellation, shipments leaving and arriving, returns and refunds
+// @Tags checkout
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} orders.TimelineEntry
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /protected/checkout/orders/{id}/timeline [get]
+func (h *Handler) GetOrderTimeline(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	orderID, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	order, err := h.svc.Orders.GetForUser(c.UserContext(), user.ID, orderID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(orders.Timeline(order))
+}
+
+// GetAdminOrderTimeline lists what happened to an order (admin only)
+// @Summary Get order timeline (admin)
+// @Description List the order's history, oldest first: placement or cancellation, shipments leaving and arriving, returns and refunds (admin only)
+// @Tags orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} orders.TimelineEntry
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/orders/{id}/timeline [get]
+func (h *Handler) GetAdminOrderTimeline(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	order, err := h.svc.Orders.Get(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(orders.Timeline(order))
+}
+
+// GetAdminReturns lists returns (admin only)
+// @Summary List returns (admin)
+// @Description List returns, newest first, optionally by status or order (admin only)
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Param status query string false "Filter by status"
+// @Param order_id query int false "Filter by order"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/returns [get]
+func (h *Handler) GetAdminReturns(c *fiber.Ctx) error {
+	q := returns.Query{
+		Page: pageQuery(c),
+		Filter: repository.ReturnFilter{
+			Status:  c.Query("status"),
+			OrderID: uint(c.QueryInt("order_id")),
+		},
+	}
+
+	list, total, err := h.svc.Returns.List(c.UserContext(), q)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"returns":    list,
+		"pagination": pagination(q.Page, total),
+	})
+}
+
+// GetAdminReturn returns a return by ID (admin only)
+// @Summary Get return (admin)
+// @Description Get a return with its items and photos (admin only)
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Success 200 {object} models.Return
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/returns/{id} [get]
+func (h *Handler) GetAdminReturn(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid return ID")
+	if err != nil {
+		return err
+	}
+
+	ret, err := h.svc.Returns.Get(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(ret)
+}
+
+// ApproveReturn accepts a requested return (admin only)
+// @Summary Approve return (admin)
+// @Description Accept a requested return so the customer can send the goods back (admin only)
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param request body ReturnDecisionRequest false "Note to the customer"
+// @Success 200 {object} models.Return
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Router /admin/returns/{id}/approve [put]
+func (h *Handler) ApproveReturn(c *fiber.Ctx) error {
+	return h.decideReturn(c, h.svc.Returns.Approve)
+}
+
+// RejectReturn turns down a requested return (admin only)
+// @Summary Reject return (admin)
+// @Description Turn down a requested return (admin only)
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param request body ReturnDecisionRequest false "Note to the customer"
+// @Success 200 {object} models.Return
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Router /admin/returns/{id}/reject [put]
+func (h *Handler) RejectReturn(c *fiber.Ctx) error {
+	return h.decideReturn(c, h.svc.Returns.Reject)
+}
+
+// decideReturn approves or rejects a return with decide
+func (h *Handler) decideReturn(c *fiber.Ctx, decide func(ctx context.Context, id uint, note string) (models.Return, error)) error {
+	id, err := idParam(c, "id", "Invalid return ID")
+	if err != nil {
+		return err
+	}
+
+	// The body is optional
+	var req ReturnDecisionRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	ret, err := decide(c.UserContext(), id, req.Note)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(ret)
+}
+
+// ReceiveReturn records that a return's goods arrived (admin only)
+// @Summary Receive return (admin)
+// @Description Record that an approved return's goods arrived, and whether each item goes back in stock or is written off. Items left out are restocked.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param request body ReceiveReturnRequest false "Dispositions"
+// @Success 200 {object} models.Return
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Router /admin/returns/{id}/receive [put]
+func (h *Handler) ReceiveReturn(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid return ID")
+	if err != nil {
+		return err
+	}
+
+	// The body is optional
+	var req ReceiveReturnRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+	dispositions := make(map[uint]string, len(req.Items))
+	for _, item := range req.Items {
+		dispositions[item.ReturnItemID] = item.Disposition
+	}
+
+	ctx := c.UserContext()
+	ret, err := h.svc.Returns.Receive(ctx, id, dispositions)
+	if err != nil {
+		return err
+	}
+
+	// Restocked items change stock levels, so cached catalog pages are stale
+	if order, err := h.svc.Orders.Get(ctx, ret.OrderID); err == nil {
+		for _, item := range order.OrderItems {
+			onProductChanged(ctx, item.ProductID)
+		}
+	}
+
+	return c.JSON(ret)
+}
+
+// RefundReturn pays back a received return (admin only)
+// @Summary Refund return (admin)
+// @Description Refund a received return, by default the price of its items with their share of shipping and tax, through the payment provider or as a manual bank transfer. Only paid orders can be refunded.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param request body RefundReturnRequest false "Amount and method"
+// @Success 200 {object} models.Return
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Failure 502 {object} apperror.Problem
+// @Router /admin/returns/{id}/refund [put]
+func (h *Handler) RefundReturn(c *fiber.Ctx) error {
+	user := c.Locals("user").(models.User)
+
+	id, err := idParam(c, "id", "Invalid return ID")
+	if err != nil {
+		return err
+	}
+
+	// The body is optional
+	var req RefundReturnRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	ret, err := h.svc.Returns.Refund(c.UserContext(), id, refunds.Request{
+		Amount:        req.Amount,
+		Reason:        req.Reason,
+		Method:        req.Method,
+		BankReference: req.BankReference,
+		CreatedBy:     &user.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(ret)
+}
+
+// Request/Response types
+type ReturnRequest struct {
+	Reason string              `json:"reason" validate:"required"`
+	Photos []string            `json:"photos" validate:"dive,url"`
+	Items  []ReturnItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+type ReturnItemRequest struct {
+	OrderItemID uint `json:"order_item_id" validate:"required"`
+	Quantity    int  `json:"quantity" validate:"required,min=1"`
+}
+
+type ReturnDecisionRequest struct {
+	Note string `json:"note"`
+}
+
+type ReceiveReturnRequest struct {
+	Items []ReturnDispositionRequest `json:"items" validate:"dive"`
+}
+
+type ReturnDispositionRequest struct {
+	ReturnItemID uint   `json:"return_item_id" validate:"required"`
+	Disposition  string `json:"disposition" validate:"required,oneof=restock write_off"`
+}
+
+type RefundReturnRequest struct {
+	Amount        *int64 `json:"amount" validate:"omitempty,min=1"`
+	Reason        string `json:"reason"`
+	Method        string `json:"method" validate:"omitempty,oneof=provider manual_bank"`
+	BankReference string `json:"bank_reference"`
+}
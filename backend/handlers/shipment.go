@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"time"
+
+	"ecommerce-backend/services/shipping"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetOrderShipments lists an order's shipments (admin only)
+// @Summary List order shipments (admin)
+// @Description List the shipments an order has left in, oldest first (admin only)
+// @Tags shipments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} models.Shipment
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/orders/{id}/shipments [get]
+func (h *Handler) GetOrderShipments(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	shipments, err := h.svc.Shipping.List(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(shipments)
+}
+
+// CreateShipment ships some or all of an order's items (admin only)
+// @Summary Create shipment (admin)
+// @Description Hand some quantity of an order's items to a carrier. Without items, everything not yet shipped goes in the shipment. The order becomes partially_shipped or shipped accordingly.
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param request body CreateShipmentRequest true "Shipment data"
+// @Success 201 {object} shipping.Result
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Router /admin/orders/{id}/shipments [post]
+func (h *Handler) CreateShipment(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+
+	var req CreateShipmentRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	r := shipping.Request{
+		Carrier:        req.Carrier,
+		Service:        req.Service,
+		TrackingNumber: req.TrackingNumber,
+	}
+	if req.ShippedAt != nil {
+		r.ShippedAt = *req.ShippedAt
+	}
+	for _, item := range req.Items {
+		r.Lines = append(r.Lines, shipping.Line{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+
+	result, err := h.svc.Shipping.Create(c.UserContext(), id, r)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+// MarkShipmentDelivered records that a shipment arrived (admin only)
+// @Summary Mark shipment delivered (admin)
+// @Description Record a shipment's delivery, now or at delivered_at. The order becomes delivered once every item has shipped and every shipment has arrived.
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param shipmentId path int true "Shipment ID"
+// @Param request body DeliverShipmentRequest false "Delivery time"
+// @Success 200 {object} shipping.Result
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /admin/orders/{id}/shipments/{shipmentId}/delivered [put]
+func (h *Handler) MarkShipmentDelivered(c *fiber.Ctx) error {
+	orderID, err := idParam(c, "id", "Invalid order ID")
+	if err != nil {
+		return err
+	}
+	shipmentID, err := idParam(c, "shipmentId", "Invalid shipment ID")
+	if err != nil {
+		return err
+	}
+
+	// The body is optional
+	var req DeliverShipmentRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+	}
+	var at time.Time
+	if req.DeliveredAt != nil {
+		at = *req.DeliveredAt
+	}
+
+	result, err := h.svc.Shipping.MarkDelivered(c.UserContext(), orderID, shipmentID, at)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(result)
+}
+
+// Request/Response types
+type CreateShipmentRequest struct {
+	Carrier        string                `json:"carrier" validate:"required"`
+	Service        string                `json:"service"`
+	TrackingNumber string                `json:"tracking_number"`
+	ShippedAt      *time.Time            `json:"shipped_at"`
+	Items          []ShipmentItemRequest `json:"items" validate:"dive"`
+}
+
+type ShipmentItemRequest struct {
+	OrderItemID uint `json:"order_item_id" validate:"required"`
+	Quantity    int  `json:"quantity" validate:"required,min=1"`
+}
+
+type DeliverShipmentRequest struct {
+	DeliveredAt *time.Time `json:"delivered_at"`
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/carriers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Webhook requests are signed with the webhook secret: signatureHeader
+// carries "sha256=" and the hex HMAC-SHA256 of "<carrier>.<timestamp>.<body>",
+// where carrier is the path's and timestamp is timestampHeader, in Unix
+// seconds. Binding both stops a captured request being replayed later or
+// against another carrier.
+const (
+	signatureHeader = "X-Signature"
+	timestampHeader = "X-Signature-Timestamp"
+	// signatureTolerance is how far a timestamp may be from the server's clock
+	signatureTolerance = 5 * time.Minute
+)
+
+// CarrierWebhook records tracking events a carrier pushes
+// @Summary Receive carrier tracking events
+// @Description Record tracking events for a shipment sent with the carrier. The request must be signed: X-Signature is "sha256=" followed by the hex HMAC-SHA256 of "<carrier>.<X-Signature-Timestamp>.<raw body>", keyed with the configured webhook secret, and the timestamp must be within 5 minutes of the server's clock. A delivered event marks the shipment delivered.
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Param carrier path string true "Carrier code, e.g. jne"
+// @Param X-Signature header string true "sha256=<hex HMAC-SHA256 of carrier, timestamp and body>"
+// @Param X-Signature-Timestamp header string true "Unix time the request was signed at"
+// @Param request body TrackingWebhookRequest true "Tracking events"
+// @Success 200 {object} TrackingWebhookResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Router /webhooks/carriers/{carrier} [post]
+func (h *Handler) CarrierWebhook(c *fiber.Ctx) error {
+	secret := h.cfg.Carriers.WebhookSecret
+	if secret == "" {
+		return apperror.NotFound(apperror.CodeNotFound, "Carrier webhooks are not enabled")
+	}
+	if !validSignature(secret, c.Params("carrier"), c.Get(timestampHeader), c.Body(), c.Get(signatureHeader), time.Now()) {
+		return apperror.Unauthorized(apperror.CodeInvalidSignature,
+			"Missing, invalid or expired "+signatureHeader+" or "+timestampHeader)
+	}
+
+	var req TrackingWebhookRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	events := make([]carriers.Event, 0, len(req.Events))
+	for _, e := range req.Events {
+		events = append(events, carriers.Event{
+			Status:      e.Status,
+			Description: strings.TrimSpace(e.Description),
+			Location:    strings.TrimSpace(e.Location),
+			OccurredAt:  e.OccurredAt,
+		})
+	}
+
+	recorded, err := h.svc.Tracking.Receive(c.UserContext(), c.Params("carrier"), req.TrackingNumber, events)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(TrackingWebhookResponse{Recorded: recorded})
+}
+
+// validSignature checks a "sha256=<hex>" signature of carrier, timestamp and
+// body in constant time, and that timestamp is within signatureTolerance of now
+func validSignature(secret, carrier, timestamp string, body []byte, signature string, now time.Time) bool {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return false
+	}
+
+	sum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(carrier + "." + timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Request/Response types
+type TrackingWebhookRequest struct {
+	TrackingNumber string                 `json:"tracking_number" validate:"required"`
+	Events         []TrackingEventRequest `json:"events" validate:"required,min=1,dive"`
+}
+
+type TrackingEventRequest struct {
+	Status      string    `json:"status" validate:"required,oneof=picked_up in_transit out_for_delivery delivered exception"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	OccurredAt  time.Time `json:"occurred_at" validate:"required"`
+}
+
+type TrackingWebhookResponse struct {
+	// Recorded is how many of the events were new
+	Recorded int `json:"recorded"`
+}
@@ -1,13 +1,10 @@
 package handlers
 
 import (
-	"strconv"
-
-	"ecommerce-backend/database"
-	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/users"
 
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // GetAdminUsers returns all users for admin
@@ -24,53 +21,27 @@ import (
 // @Param is_active query bool false "Filter by active status"
 // @Success 200 {object} map[string]interface{}
 // @Router /admin/users [get]
-func GetAdminUsers(c *fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "10"))
-	search := c.Query("search")
-	role := c.Query("role")
-	isActiveStr := c.Query("is_active")
-
-	offset := (page - 1) * limit
-
-	var users []models.User
-	var total int64
-
-	query := database.DB.Model(&models.User{}).Unscoped()
-
-	// Apply filters
-	if search != "" {
-		query = query.Where("first_name ILIKE ? OR last_name ILIKE ? OR email ILIKE ?", 
-			"%"+search+"%", "%"+search+"%", "%"+search+"%")
-	}
-
-	if role != "" {
-		query = query.Where("role = ?", role)
+func (h *Handler) GetAdminUsers(c *fiber.Ctx) error {
+	q := users.Query{
+		Page: pageQuery(c),
+		Filter: repository.UserFilter{
+			Search: c.Query("search"),
+			Role:   c.Query("role"),
+		},
 	}
-
-	if isActiveStr != "" {
-		isActive := isActiveStr == "true"
-		query = query.Where("is_active = ?", isActive)
+	if isActive := c.Query("is_active"); isActive != "" {
+		active := isActive == "true"
+		q.Filter.IsActive = &active
 	}
 
-	// Count total records
-	query.Count(&total)
-
-	// Get users with pagination
-	if err := query.Offset(offset).Limit(limit).Find(&users).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch users",
-		})
+	list, total, err := h.svc.Users.List(c.UserContext(), q)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(fiber.Map{
-		"users": users,
-		"pagination": fiber.Map{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": (total + int64(limit) - 1) / int64(limit),
-		},
+		"users":      list,
+		"pagination": pagination(q.Page, total),
 	})
 }
 
@@ -83,24 +54,21 @@ func GetAdminUsers(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path int true "User ID"
 // @Success 200 {object} models.User
-// @Failure 404 {object} map[string]interface{}
+// @Success 304
+// @Failure 404 {object} apperror.Problem
 // @Router /admin/users/{id} [get]
-func GetAdminUser(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+func (h *Handler) GetAdminUser(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid user ID")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid user ID",
-		})
+		return err
 	}
 
-	var user models.User
-	if err := database.DB.Unscoped().First(&user, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "User not found",
-		})
+	user, err := h.svc.Users.Get(c.UserContext(), id)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(user)
+	return sendWithValidators(c, entityValidators("user", user.ID, user.Version, user.UpdatedAt), user)
 }
 
 // CreateUser creates a new user (admin only)
@@ -112,110 +80,82 @@ func GetAdminUser(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param user body CreateUserRequest true "User data"
 // @Success 201 {object} models.User
-// @Failure 400 {object} map[string]interface{}
-// @Failure 409 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
 // @Router /admin/users [post]
-func CreateUser(c *fiber.Ctx) error {
+func (h *Handler) CreateUser(c *fiber.Ctx) error {
 	var req CreateUserRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to hash password",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	user := models.User{
+	user, err := h.svc.Users.Create(c.UserContext(), users.Account{
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
 		Email:     req.Email,
-		Password:  string(hashedPassword),
+		Password:  req.Password,
 		Phone:     req.Phone,
 		Role:      req.Role,
 		IsActive:  req.IsActive,
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-			"error": "User with this email already exists",
-		})
-	}
-
-	// Remove password from response
-	user.Password = ""
-
 	return c.Status(fiber.StatusCreated).JSON(user)
 }
 
 // UpdateUser updates an existing user (admin only)
 // @Summary Update user (admin)
-// @Description Update an existing user (admin only)
+// @Description Update only the fields present in the body of an existing user (admin only)
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "User ID"
+// @Param If-Match header string true "ETag from GET /admin/users/{id}"
 // @Param user body UpdateUserRequest true "User data"
 // @Success 200 {object} models.User
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 412 {object} apperror.Problem
+// @Failure 428 {object} apperror.Problem
 // @Router /admin/users/{id} [put]
-func UpdateUser(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+// @Router /admin/users/{id} [patch]
+func (h *Handler) UpdateUser(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid user ID")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid user ID",
-		})
+		return err
 	}
 
-	var user models.User
-	if err := database.DB.Unscoped().First(&user, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "User not found",
-		})
+	ctx := c.UserContext()
+	user, err := h.svc.Users.Get(ctx, id)
+	if err != nil {
+		return err
 	}
 
-	var req UpdateUserRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := checkIfMatch(c, entityValidators("user", user.ID, user.Version, user.UpdatedAt), user); err != nil {
+		return err
 	}
 
-	// Update fields
-	user.FirstName = req.FirstName
-	user.LastName = req.LastName
-	user.Email = req.Email
-	user.Phone = req.Phone
-	user.Role = req.Role
-	user.IsActive = req.IsActive
-
-	// Update password if provided
-	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to hash password",
-			})
-		}
-		user.Password = string(hashedPassword)
+	var req UpdateUserRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	if err := database.DB.Save(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update user",
-		})
+	user, updated, err := h.svc.Users.Update(ctx, user.ID, expectedVersion(req.Version, user.Version),
+		req.changes(), req.Password)
+	if err != nil {
+		return err
 	}
 
-	// Remove password from response
-	user.Password = ""
+	v := entityValidators("user", user.ID, user.Version, user.UpdatedAt)
+	if !updated {
+		return versionConflict(c, v, user)
+	}
 
+	c.Set(fiber.HeaderETag, v.ETag)
 	return c.JSON(user)
 }
 
@@ -228,28 +168,17 @@ func UpdateUser(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path int true "User ID"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 404 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
 // @Router /admin/users/{id} [delete]
-func DeleteUser(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+func (h *Handler) DeleteUser(c *fiber.Ctx) error {
+	id, err := idParam(c, "id", "Invalid user ID")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid user ID",
-		})
+		return err
 	}
 
-	var user models.User
-	if err := database.DB.Unscoped().First(&user, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "User not found",
-		})
-	}
-
-	if err := database.DB.Delete(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete user",
-		})
+	if err := h.svc.Users.Delete(c.UserContext(), id); err != nil {
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -264,16 +193,41 @@ type CreateUserRequest struct {
 	Email     string `json:"email" validate:"required,email"`
 	Password  string `json:"password" validate:"required,min=6"`
 	Phone     string `json:"phone"`
-	Role      string `json:"role" validate:"required"`
+	Role      string `json:"role" validate:"required,oneof=user admin"`
 	IsActive  bool   `json:"is_active"`
 }
 
 type UpdateUserRequest struct {
-	FirstName string `json:"first_name" validate:"required"`
-	LastName  string `json:"last_name" validate:"required"`
-	Email     string `json:"email" validate:"required,email"`
-	Password  string `json:"password"`
-	Phone     string `json:"phone"`
-	Role      string `json:"role" validate:"required"`
-	IsActive  bool   `json:"is_active"`
+	FirstName *string `json:"first_name" validate:"omitempty,min=1"`
+	LastName  *string `json:"last_name" validate:"omitempty,min=1"`
+	Email     *string `json:"email" validate:"omitempty,email"`
+	Password  string  `json:"password" validate:"omitempty,min=6"`
+	Phone     *string `json:"phone"`
+	Role      *string `json:"role" validate:"omitempty,oneof=user admin"`
+	IsActive  *bool   `json:"is_active"`
+	Version   *uint   `json:"version"`
+}
+
+// changes returns the columns to update, limited to the fields that were sent
+func (r UpdateUserRequest) changes() map[string]interface{} {
+	changes := make(map[string]interface{})
+	if r.FirstName != nil {
+		changes["first_name"] = *r.FirstName
+	}
+	if r.LastName != nil {
+		changes["last_name"] = *r.LastName
+	}
+	if r.Email != nil {
+		changes["email"] = *r.Email
+	}
+	if r.Phone != nil {
+		changes["phone"] = *r.Phone
+	}
+	if r.Role != nil {
+		changes["role"] = *r.Role
+	}
+	if r.IsActive != nil {
+		changes["is_active"] = *r.IsActive
+	}
+	return changes
 }
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseBody decodes the request body into dst and enforces its `validate`
+// tags. It fails with 400 for a malformed body, or with 422 listing every
+// invalid field.
+func parseBody(c *fiber.Ctx, dst interface{}) error {
+	if err := c.BodyParser(dst); err != nil {
+		return apperror.BadRequest(apperror.CodeInvalidRequest, "Invalid request body")
+	}
+
+	if err := validation.Struct(dst); err != nil {
+		var fields validation.FieldErrors
+		if errors.As(err, &fields) {
+			return apperror.Validation(fields)
+		}
+		return apperror.Internal("Failed to validate request", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"ecommerce-backend/apperror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestParseBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   apperror.Code
+		wantErrors map[string]interface{}
+	}{
+		{name: "valid", body: `{"first_name":"Sri"}`, wantStatus: fiber.StatusOK},
+		{name: "malformed JSON", body: `{"first_name":`, wantStatus: fiber.StatusBadRequest, wantCode: apperror.CodeInvalidRequest},
+		{name: "wrong type", body: `{"first_name":7}`, wantStatus: fiber.StatusBadRequest, wantCode: apperror.CodeInvalidRequest},
+		{
+			name:       "invalid fields",
+			body:       `{"email":"siti","role":"owner","password":"abc"}`,
+			wantStatus: fiber.StatusUnprocessableEntity,
+			wantCode:   apperror.CodeValidationFailed,
+			wantErrors: map[string]interface{}{
+				"email":    "must be a valid email address",
+				"role":     "must be one of: user, admin",
+				"password": "must be at least 6 characters long",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, etag := usersApp(t)
+			res, body := send(t, app, fiber.MethodPatch, "/admin/users/1", tt.body, map[string]string{fiber.HeaderIfMatch: etag})
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", res.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantCode != "" && body["code"] != string(tt.wantCode) {
+				t.Errorf("code = %v, want %s", body["code"], tt.wantCode)
+			}
+			if tt.wantErrors != nil && !reflect.DeepEqual(body["errors"], tt.wantErrors) {
+				t.Errorf("errors = %v, want %v", body["errors"], tt.wantErrors)
+			}
+		})
+	}
+}
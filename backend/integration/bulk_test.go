@@ -0,0 +1,87 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/models"
+	"ecommerce-backend/services/bulk"
+	"ecommerce-backend/services/orders"
+)
+
+// TestBulkJobs marks every pending order processing, then ships and prints
+// the packing slips of a list of orders, and follows each job's results
+func TestBulkJobs(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	product := h.createProduct(admin, "Kopi Arabika", 85000, 10)
+	customer := h.loginAsCustomer("Sari")
+	first := h.placeOrder(customer, product, 1)
+	second := h.placeOrder(customer, product, 2)
+
+	run := func(req handlers.BulkOrderRequest) models.BulkJob {
+		t.Helper()
+		var job models.BulkJob
+		h.do(admin, http.MethodPost, "/protected/admin/orders/bulk", req).expect(t, http.StatusAccepted, &job)
+		if job.Status != bulk.StatusQueued {
+			t.Fatalf("new job is %s, want queued", job.Status)
+		}
+		if _, err := h.svc.Bulk.Work(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		h.do(admin, http.MethodGet, fmt.Sprintf("/protected/admin/jobs/%d", job.ID), nil).
+			expect(t, http.StatusOK, &job)
+		if job.Status != bulk.StatusCompleted || job.Processed != job.Total {
+			t.Fatalf("job = %s with %d of %d processed, want completed", job.Status, job.Processed, job.Total)
+		}
+		return job
+	}
+
+	job := run(handlers.BulkOrderRequest{
+		Action: bulk.ActionProcess,
+		Filter: &handlers.BulkOrderFilter{Status: orders.StatusPending},
+	})
+	if job.Total != 2 || job.Succeeded != 2 {
+		t.Errorf("processing job = %+v, want both orders processed", job)
+	}
+
+	job = run(handlers.BulkOrderRequest{
+		Action:   bulk.ActionShip,
+		OrderIDs: []uint{first.OrderID, second.OrderID},
+		Carrier:  "jne",
+		Service:  "REG",
+	})
+	for _, result := range job.Results {
+		if result.Status != bulk.ResultSucceeded || result.ShipmentID == nil || result.OrderNumber == "" {
+			t.Errorf("shipping result = %+v, want a shipment", result)
+		}
+	}
+	var order models.Order
+	h.do(admin, http.MethodGet, fmt.Sprintf("/protected/admin/orders/%d", second.OrderID), nil).
+		expect(t, http.StatusOK, &order)
+	if order.Status != orders.StatusShipped {
+		t.Errorf("order %d is %s, want shipped", order.ID, order.Status)
+	}
+
+	job = run(handlers.BulkOrderRequest{Action: bulk.ActionPackingSlips, OrderIDs: []uint{second.OrderID, 999999}})
+	if job.Succeeded != 1 || job.Failed != 1 {
+		t.Errorf("packing slip job = %+v, want one order printed and one missing", job)
+	}
+	res := h.do(admin, http.MethodGet, fmt.Sprintf("/protected/admin/jobs/%d/document", job.ID), nil).
+		expect(t, http.StatusOK, nil)
+	if !bytes.HasPrefix(res.body, []byte("%PDF-")) {
+		t.Errorf("job document is not a PDF: %.20q", res.body)
+	}
+
+	res = h.do(admin, http.MethodPost, "/protected/admin/orders/bulk", handlers.BulkOrderRequest{
+		Action:   bulk.ActionShip,
+		OrderIDs: []uint{first.OrderID},
+	})
+	if res.status != http.StatusUnprocessableEntity {
+		t.Errorf("shipping without a carrier: status %d, want 422", res.status)
+	}
+}
@@ -0,0 +1,210 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/models"
+	"ecommerce-backend/services/cart"
+	"ecommerce-backend/services/shipping"
+)
+
+var jakarta = models.Address{
+	Address:    "Jl. Sudirman No. 1",
+	City:       "Jakarta",
+	Province:   "DKI Jakarta",
+	PostalCode: "10220",
+}
+
+// TestOrderLifecycle walks one order from sign-up to delivery: register, add
+// to cart, check out, admin ships in two parcels, customer views, delivery
+func TestOrderLifecycle(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	product := h.createProduct(admin, "Kopi Arabika", 85000, 5)
+
+	customer := h.loginAsCustomer("Siti")
+	if customer.user.Role != "user" {
+		t.Fatalf("registered role = %q, want user", customer.user.Role)
+	}
+
+	h.do(customer, http.MethodPost, "/protected/cart/add", map[string]interface{}{
+		"product_id": product.ID,
+		"quantity":   2,
+	}).expect(t, http.StatusCreated, nil)
+
+	// 2 x 85000, plus 10% tax and flat shipping
+	var summary cart.Summary
+	h.do(customer, http.MethodGet, "/protected/cart/summary", nil).expect(t, http.StatusOK, &summary)
+	if summary.TotalItems != 2 || summary.Total != 197000 {
+		t.Fatalf("cart summary = %+v, want 2 items totalling 197000", summary.Totals)
+	}
+
+	var placed handlers.CheckoutResponse
+	h.do(customer, http.MethodPost, "/protected/checkout", handlers.CheckoutRequest{
+		ShippingAddress: jakarta,
+		PaymentMethod:   "bank_transfer",
+	}).expect(t, http.StatusCreated, &placed)
+	if placed.Status != "pending" || placed.TotalAmount != summary.Total {
+		t.Fatalf("checkout = %+v, want a pending order of %d", placed, summary.Total)
+	}
+
+	// Checkout took the items out of stock and emptied the cart
+	var stocked models.Product
+	h.do(admin, http.MethodGet, fmt.Sprintf("/protected/admin/products/%d", product.ID), nil).
+		expect(t, http.StatusOK, &stocked)
+	if stocked.Stock != 3 {
+		t.Errorf("stock after checkout = %d, want 3", stocked.Stock)
+	}
+	h.do(customer, http.MethodGet, "/protected/cart/summary", nil).expect(t, http.StatusOK, &summary)
+	if summary.TotalItems != 0 {
+		t.Errorf("cart still holds %d items after checkout", summary.TotalItems)
+	}
+
+	// The admin starts on the order, then ships it in two parcels
+	orderPath := fmt.Sprintf("/protected/admin/orders/%d", placed.OrderID)
+	etag := h.do(admin, http.MethodGet, orderPath, nil).expect(t, http.StatusOK, nil).header.Get("ETag")
+	h.do(admin, http.MethodPut, orderPath+"/status", map[string]interface{}{"status": "processing"}, "If-Match", etag).
+		expect(t, http.StatusOK, nil)
+
+	var detail models.Order
+	h.do(admin, http.MethodGet, orderPath, nil).expect(t, http.StatusOK, &detail)
+	itemID := detail.OrderItems[0].ID
+
+	var first, second shipping.Result
+	h.do(admin, http.MethodPost, orderPath+"/shipments", handlers.CreateShipmentRequest{
+		Carrier:        "jne",
+		Service:        "REG",
+		TrackingNumber: "JNE0012345678",
+		Items:          []handlers.ShipmentItemRequest{{OrderItemID: itemID, Quantity: 1}},
+	}).expect(t, http.StatusCreated, &first)
+	if first.OrderStatus != "partially_shipped" {
+		t.Errorf("after first parcel: order status %q, want partially_shipped", first.OrderStatus)
+	}
+
+	res := h.do(admin, http.MethodPost, orderPath+"/shipments", handlers.CreateShipmentRequest{
+		Carrier: "jne",
+		Items:   []handlers.ShipmentItemRequest{{OrderItemID: itemID, Quantity: 2}},
+	})
+	if res.status != http.StatusConflict || res.code() != apperror.CodeShipmentExceedsOrder {
+		t.Errorf("ship more than ordered: status %d code %q, want 409 %s", res.status, res.code(), apperror.CodeShipmentExceedsOrder)
+	}
+
+	h.do(admin, http.MethodPost, orderPath+"/shipments", handlers.CreateShipmentRequest{
+		Carrier:        "sicepat",
+		TrackingNumber: "SCP000987654",
+	}).expect(t, http.StatusCreated, &second)
+	if second.OrderStatus != "shipped" || len(second.Shipment.Items) != 1 || second.Shipment.Items[0].Quantity != 1 {
+		t.Errorf("second parcel = %+v, want the remaining 1 item and a shipped order", second)
+	}
+
+	// With shipments, the status is theirs to set
+	etag = h.do(admin, http.MethodGet, orderPath, nil).expect(t, http.StatusOK, nil).header.Get("ETag")
+	res = h.do(admin, http.MethodPut, orderPath+"/status", map[string]interface{}{"status": "delivered"}, "If-Match", etag)
+	if res.code() != apperror.CodeInvalidTransition {
+		t.Errorf("manual status change: status %d code %q, want %s", res.status, res.code(), apperror.CodeInvalidTransition)
+	}
+
+	var order models.Order
+	h.do(customer, http.MethodGet, fmt.Sprintf("/protected/checkout/orders/%d", placed.OrderID), nil).
+		expect(t, http.StatusOK, &order)
+	if order.Status != "shipped" || order.TrackingNumber != "SCP000987654" || len(order.Shipments) != 2 {
+		t.Errorf("customer sees status %q tracking %q with %d shipments, want shipped SCP000987654 with 2",
+			order.Status, order.TrackingNumber, len(order.Shipments))
+	}
+	if len(order.OrderItems) != 1 || order.OrderItems[0].Quantity != 2 {
+		t.Errorf("order items = %+v, want 2 x product %d", order.OrderItems, product.ID)
+	}
+
+	// The order is delivered once both parcels arrive
+	var delivered shipping.Result
+	for _, shipment := range []models.Shipment{first.Shipment, second.Shipment} {
+		h.do(admin, http.MethodPut, fmt.Sprintf("%s/shipments/%d/delivered", orderPath, shipment.ID), nil).
+			expect(t, http.StatusOK, &delivered)
+	}
+	if delivered.OrderStatus != "delivered" {
+		t.Errorf("after both parcels arrived: order status %q, want delivered", delivered.OrderStatus)
+	}
+
+	var history []models.Order
+	h.do(customer, http.MethodGet, "/protected/checkout/history", nil).expect(t, http.StatusOK, &history)
+	if len(history) != 1 || history[0].ID != placed.OrderID {
+		t.Errorf("history = %d orders, want only order %d", len(history), placed.OrderID)
+	}
+
+	// A delivered order can no longer be cancelled by the customer
+	res = h.do(customer, http.MethodPut, fmt.Sprintf("/protected/checkout/orders/%d/cancel", placed.OrderID), nil)
+	if res.code() != apperror.CodeOrderNotCancellable {
+		t.Errorf("cancel delivered order: status %d code %q, want %s", res.status, res.code(), apperror.CodeOrderNotCancellable)
+	}
+}
+
+func TestCheckoutRejectsMoreThanInStock(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	product := h.createProduct(admin, "Teh Melati", 25000, 3)
+
+	first := h.loginAsCustomer("Budi")
+	second := h.loginAsCustomer("Ayu")
+	for _, customer := range []session{first, second} {
+		h.do(customer, http.MethodPost, "/protected/cart/add", map[string]interface{}{
+			"product_id": product.ID,
+			"quantity":   2,
+		}).expect(t, http.StatusCreated, nil)
+	}
+
+	checkout := handlers.CheckoutRequest{ShippingAddress: jakarta, PaymentMethod: "cod"}
+	h.do(first, http.MethodPost, "/protected/checkout", checkout).expect(t, http.StatusCreated, nil)
+
+	res := h.do(second, http.MethodPost, "/protected/checkout", checkout)
+	if res.status != http.StatusBadRequest || res.code() != apperror.CodeInsufficientStock {
+		t.Fatalf("second checkout: status %d code %q, want 400 %s", res.status, res.code(), apperror.CodeInsufficientStock)
+	}
+
+	var history []models.Order
+	h.do(second, http.MethodGet, "/protected/checkout/history", nil).expect(t, http.StatusOK, &history)
+	if len(history) != 0 {
+		t.Errorf("rejected checkout left %d orders", len(history))
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	product := h.createProduct(admin, "Gula Aren", 15000, 10)
+
+	owner := h.loginAsCustomer("Rina")
+	other := h.loginAsCustomer("Joko")
+	h.do(owner, http.MethodPost, "/protected/cart/add", map[string]interface{}{
+		"product_id": product.ID,
+		"quantity":   1,
+	}).expect(t, http.StatusCreated, nil)
+	var placed handlers.CheckoutResponse
+	h.do(owner, http.MethodPost, "/protected/checkout", handlers.CheckoutRequest{
+		ShippingAddress: jakarta,
+		PaymentMethod:   "cod",
+	}).expect(t, http.StatusCreated, &placed)
+
+	tests := []struct {
+		name   string
+		as     session
+		method string
+		path   string
+		want   int
+	}{
+		{"anonymous cart", session{}, http.MethodGet, "/protected/cart", http.StatusUnauthorized},
+		{"bad token", session{token: "not-a-jwt"}, http.MethodGet, "/protected/cart", http.StatusUnauthorized},
+		{"customer on admin route", owner, http.MethodGet, "/protected/admin/orders", http.StatusForbidden},
+		{"someone else's order", other, http.MethodGet, fmt.Sprintf("/protected/checkout/orders/%d", placed.OrderID), http.StatusNotFound},
+		{"own order", owner, http.MethodGet, fmt.Sprintf("/protected/checkout/orders/%d", placed.OrderID), http.StatusOK},
+		{"admin sees any order", admin, http.MethodGet, fmt.Sprintf("/protected/admin/orders/%d", placed.OrderID), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.do(tt.as, tt.method, tt.path, nil).expect(t, tt.want, nil)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/models"
+)
+
+// TestInvoices pays two orders, checks they are numbered in turn, and
+// downloads their invoices and packing slips singly and in bulk
+func TestInvoices(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	product := h.createProduct(admin, "Kopi Arabika", 85000, 5)
+	customer := h.loginAsCustomer("Sari")
+	first := h.placeOrder(customer, product, 2)
+	second := h.placeOrder(customer, product, 1)
+
+	invoicePath := fmt.Sprintf("/protected/checkout/orders/%d/invoice", first.OrderID)
+	res := h.do(customer, http.MethodGet, invoicePath, nil)
+	if res.status != http.StatusConflict || res.code() != apperror.CodeInvoiceNotIssued {
+		t.Fatalf("invoice before payment: status %d code %q, want 409 %s", res.status, res.code(), apperror.CodeInvoiceNotIssued)
+	}
+
+	var numbers []string
+	for _, id := range []uint{first.OrderID, second.OrderID} {
+		var paid models.Order
+		h.do(admin, http.MethodPut, fmt.Sprintf("/protected/admin/orders/%d/payment", id),
+			handlers.UpdatePaymentStatusRequest{PaymentStatus: "paid"}).expect(t, http.StatusOK, &paid)
+		if paid.InvoiceNumber == nil || paid.InvoicedAt == nil {
+			t.Fatalf("paid order %d has no invoice", id)
+		}
+		numbers = append(numbers, *paid.InvoiceNumber)
+	}
+	year := numbers[0][4:8]
+	if numbers[0] != "INV-"+year+"-000001" || numbers[1] != "INV-"+year+"-000002" {
+		t.Errorf("invoice numbers = %v, want the first two of %s", numbers, year)
+	}
+
+	pdf := func(res response, filename string) {
+		t.Helper()
+		res.expect(t, http.StatusOK, nil)
+		if got := res.header.Get("Content-Type"); got != "application/pdf" {
+			t.Errorf("content type = %q, want application/pdf", got)
+		}
+		if got := res.header.Get("Content-Disposition"); !strings.Contains(got, filename) {
+			t.Errorf("content disposition = %q, want %s", got, filename)
+		}
+		if !bytes.HasPrefix(res.body, []byte("%PDF-")) {
+			t.Errorf("body is not a PDF: %.20q", res.body)
+		}
+	}
+	pdf(h.do(customer, http.MethodGet, invoicePath, nil), numbers[0]+".pdf")
+	other := h.loginAsCustomer("Joko")
+	if res := h.do(other, http.MethodGet, invoicePath, nil); res.status != http.StatusNotFound {
+		t.Errorf("another customer downloaded the invoice: status %d", res.status)
+	}
+
+	adminPath := fmt.Sprintf("/protected/admin/orders/%d", second.OrderID)
+	pdf(h.do(admin, http.MethodGet, adminPath+"/invoice", nil), numbers[1]+".pdf")
+	pdf(h.do(admin, http.MethodGet, adminPath+"/packing-slip", nil), "packing-slip-"+second.OrderNumber+".pdf")
+
+	ids := fmt.Sprintf("?ids=%d,%d", first.OrderID, second.OrderID)
+	pdf(h.do(admin, http.MethodGet, "/protected/admin/orders/invoices"+ids, nil), "invoices.pdf")
+	pdf(h.do(admin, http.MethodGet, "/protected/admin/orders/packing-slips"+ids, nil), "packing-slips.pdf")
+	if res := h.do(admin, http.MethodGet, "/protected/admin/orders/invoices?ids=1,x", nil); res.status != http.StatusBadRequest {
+		t.Errorf("bad ids: status %d, want 400", res.status)
+	}
+	if res := h.do(customer, http.MethodGet, "/protected/admin/orders/invoices"+ids, nil); res.status != http.StatusForbidden {
+		t.Errorf("customer printed in bulk: status %d, want 403", res.status)
+	}
+}
@@ -0,0 +1,106 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/models"
+	"ecommerce-backend/services/orders"
+)
+
+// TestDraftOrder has an admin take an order over the phone, edit it while it
+// is a draft and after it is placed, and checks stock and history follow
+func TestDraftOrder(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	coffee := h.createProduct(admin, "Kopi Arabika", 85000, 5)
+	tea := h.createProduct(admin, "Teh Melati", 25000, 2)
+	customer := h.loginAsCustomer("Sari")
+
+	var draft models.Order
+	h.do(admin, http.MethodPost, "/protected/admin/orders", handlers.CreateDraftOrderRequest{
+		UserID:          customer.user.ID,
+		Items:           []handlers.OrderLineRequest{{ProductID: coffee.ID, Quantity: 2}},
+		ShippingAddress: jakarta,
+		PaymentMethod:   "cod",
+	}).expect(t, http.StatusCreated, &draft)
+	if draft.Status != orders.StatusDraft || draft.TotalAmount != 170000+17000+10000 {
+		t.Fatalf("draft = status %q total %d, want a draft of 197000", draft.Status, draft.TotalAmount)
+	}
+
+	adminPath := fmt.Sprintf("/protected/admin/orders/%d", draft.ID)
+	customerPath := fmt.Sprintf("/protected/checkout/orders/%d", draft.ID)
+	if res := h.do(customer, http.MethodGet, customerPath, nil); res.status != http.StatusNotFound {
+		t.Errorf("customer read the draft: status %d", res.status)
+	}
+
+	edit := func(req handlers.EditOrderRequest) response {
+		t.Helper()
+		etag := h.do(admin, http.MethodGet, adminPath, nil).expect(t, http.StatusOK, nil).header.Get("ETag")
+		return h.do(admin, http.MethodPut, adminPath, req, "If-Match", etag)
+	}
+	stock := func(product models.Product) int {
+		t.Helper()
+		var current models.Product
+		h.do(admin, http.MethodGet, fmt.Sprintf("/protected/admin/products/%d", product.ID), nil).
+			expect(t, http.StatusOK, &current)
+		return current.Stock
+	}
+
+	// Editing a draft reprices it without touching stock
+	edit(handlers.EditOrderRequest{Items: []handlers.OrderLineRequest{
+		{ProductID: coffee.ID, Quantity: 3},
+		{ProductID: tea.ID, Quantity: 1},
+	}}).expect(t, http.StatusOK, &draft)
+	if draft.TotalAmount != 280000+28000+10000 || stock(coffee) != 5 {
+		t.Errorf("edited draft total %d with %d coffee in stock, want 318000 and 5", draft.TotalAmount, stock(coffee))
+	}
+
+	etag := h.do(admin, http.MethodGet, adminPath, nil).expect(t, http.StatusOK, nil).header.Get("ETag")
+	var order models.Order
+	h.do(admin, http.MethodPut, adminPath+"/place", nil, "If-Match", etag).expect(t, http.StatusOK, &order)
+	if order.Status != orders.StatusPending || stock(coffee) != 2 || stock(tea) != 1 {
+		t.Fatalf("placed order is %q with %d coffee and %d tea in stock, want pending with 2 and 1",
+			order.Status, stock(coffee), stock(tea))
+	}
+
+	// Edits to a pending order take and return the difference
+	res := edit(handlers.EditOrderRequest{Items: []handlers.OrderLineRequest{{ProductID: tea.ID, Quantity: 3}}})
+	if res.status != http.StatusBadRequest || res.code() != apperror.CodeInsufficientStock {
+		t.Errorf("ordering more tea than is left: status %d code %q, want 400 %s", res.status, res.code(), apperror.CodeInsufficientStock)
+	}
+	address := models.Address{Address: "Jl. Braga 12", City: "Bandung", Province: "Jawa Barat", PostalCode: "40111"}
+	edit(handlers.EditOrderRequest{
+		Items:           []handlers.OrderLineRequest{{ProductID: tea.ID, Quantity: 2}},
+		ShippingAddress: &address,
+	}).expect(t, http.StatusOK, &order)
+	if order.TotalAmount != 50000+5000+10000 || stock(coffee) != 5 || stock(tea) != 0 {
+		t.Errorf("edited order total %d with %d coffee and %d tea in stock, want 65000 with 5 and 0",
+			order.TotalAmount, stock(coffee), stock(tea))
+	}
+	if order.ShippingAddress != "Jl. Braga 12, Bandung, Jawa Barat, 40111" {
+		t.Errorf("shipping address = %q", order.ShippingAddress)
+	}
+
+	h.do(admin, http.MethodPut, adminPath+"/payment", handlers.UpdatePaymentStatusRequest{PaymentStatus: "paid"}).
+		expect(t, http.StatusOK, nil)
+	res = edit(handlers.EditOrderRequest{Items: []handlers.OrderLineRequest{{ProductID: tea.ID, Quantity: 1}}})
+	if res.status != http.StatusConflict || res.code() != apperror.CodeOrderNotEditable {
+		t.Errorf("editing the items of a paid order: status %d code %q, want 409 %s", res.status, res.code(), apperror.CodeOrderNotEditable)
+	}
+
+	var timeline []orders.TimelineEntry
+	h.do(customer, http.MethodGet, customerPath+"/timeline", nil).expect(t, http.StatusOK, &timeline)
+	want := []string{orders.EventDraftCreated, orders.EventEdited, orders.EventPlaced, orders.EventEdited}
+	if len(timeline) != len(want) {
+		t.Fatalf("timeline = %+v, want %v", timeline, want)
+	}
+	for i := range want {
+		if timeline[i].Event != want[i] {
+			t.Errorf("timeline entry %d = %s, want %s", i, timeline[i].Event, want[i])
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package integration
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+)
+
+// TestExportOrders downloads the admin order list as CSV, a row per order
+// and per item, and as XLSX
+func TestExportOrders(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	coffee := h.createProduct(admin, "Kopi Arabika", 85000, 10)
+	customer := h.loginAsCustomer("Sari")
+	first := h.placeOrder(customer, coffee, 2)
+	second := h.placeOrder(customer, coffee, 1)
+
+	export := func(query string) [][]string {
+		t.Helper()
+		res := h.do(admin, http.MethodGet, "/protected/admin/orders?"+query, nil).expect(t, http.StatusOK, nil)
+		if got := res.header.Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+			t.Errorf("content type = %q, want text/csv", got)
+		}
+		records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(res.body, []byte("\uFEFF")))).ReadAll()
+		if err != nil {
+			t.Fatalf("export is not CSV: %v", err)
+		}
+		return records
+	}
+
+	today := time.Now().Format(time.DateOnly)
+	records := export("export=csv&from=" + today + "&to=" + today)
+	if len(records) != 3 || records[1][0] != first.OrderNumber || records[2][0] != second.OrderNumber {
+		t.Errorf("order rows = %q, want both orders oldest first", records)
+	}
+	records = export("export=csv&rows=items&search=" + second.OrderNumber)
+	if len(records) != 2 || records[1][0] != second.OrderNumber || records[1][8] != "Kopi Arabika" {
+		t.Errorf("item rows = %q, want the second order's coffee", records)
+	}
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(time.DateOnly)
+	if records := export("export=csv&from=" + tomorrow); len(records) != 1 {
+		t.Errorf("export of tomorrow's orders = %q, want just the header", records)
+	}
+
+	res := h.do(admin, http.MethodGet, "/protected/admin/orders?export=xlsx", nil).expect(t, http.StatusOK, nil)
+	if _, err := zip.NewReader(bytes.NewReader(res.body), int64(len(res.body))); err != nil {
+		t.Errorf("XLSX export is not a zip: %v", err)
+	}
+
+	for _, query := range []string{"export=pdf", "export=csv&rows=shipments", "export=csv&from=yesterday"} {
+		res := h.do(admin, http.MethodGet, "/protected/admin/orders?"+query, nil)
+		if res.status != http.StatusBadRequest || res.code() != apperror.CodeInvalidRequest {
+			t.Errorf("%s: status %d code %q, want 400 %s", query, res.status, res.code(), apperror.CodeInvalidRequest)
+		}
+	}
+}
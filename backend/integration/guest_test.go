@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/models"
+)
+
+// TestGuestCheckout orders without an account, follows the emailed lookup
+// link, and claims the order with an account through the emailed claim link
+func TestGuestCheckout(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	coffee := h.createProduct(admin, "Kopi Arabika", 85000, 10)
+
+	checkout := func(email string) response {
+		t.Helper()
+		return h.do(session{}, http.MethodPost, "/guest/checkout", handlers.GuestCheckoutRequest{
+			Email:           email,
+			FirstName:       "Dewi",
+			Items:           []handlers.GuestCartItem{{ProductID: coffee.ID, Quantity: 2}},
+			ShippingAddress: jakarta,
+			PaymentMethod:   "cod",
+		})
+	}
+
+	var placed handlers.GuestCheckoutResponse
+	checkout("dewi@example.com").expect(t, http.StatusCreated, &placed)
+	if placed.LookupToken == "" || placed.TotalAmount != 197000 {
+		t.Fatalf("guest checkout = %+v, want a lookup token and a total of 197000", placed)
+	}
+
+	sent := h.mail.Sent()
+	if len(sent) != 1 || sent[0].To != "dewi@example.com" {
+		t.Fatalf("sent %+v, want the lookup link emailed to the guest", sent)
+	}
+	if !strings.Contains(sent[0].Body, "/orders/lookup?token="+url.QueryEscape(placed.LookupToken)) {
+		t.Errorf("email body %q has no lookup link", sent[0].Body)
+	}
+
+	var order models.Order
+	res := h.do(session{}, http.MethodGet, "/guest/orders?token="+url.QueryEscape(placed.LookupToken), nil).
+		expect(t, http.StatusOK, &order)
+	if order.ID != placed.OrderID || len(order.OrderItems) != 1 || res.header.Get("Cache-Control") != "no-store" {
+		t.Errorf("looked up order %d with %d items, want order %d uncached", order.ID, len(order.OrderItems), placed.OrderID)
+	}
+	if res := h.do(session{}, http.MethodGet, "/guest/orders?token=nope", nil); res.status != http.StatusNotFound {
+		t.Errorf("lookup with a wrong token: status %d, want 404", res.status)
+	}
+
+	// Accounts sign in to check out, and guests cannot sign in
+	h.loginAsCustomer("Sari")
+	if res := checkout("sari@example.com"); res.status != http.StatusConflict || res.code() != apperror.CodeEmailTaken {
+		t.Errorf("guest checkout with an account's email: status %d code %q, want 409 %s",
+			res.status, res.code(), apperror.CodeEmailTaken)
+	}
+	if res := h.do(session{}, http.MethodPost, "/auth/login", handlers.LoginRequest{Email: "dewi@example.com", Password: "x"}); res.status != http.StatusUnauthorized {
+		t.Errorf("guest login: status %d, want 401", res.status)
+	}
+
+	// Registering with the guest's email does not hand over their order
+	register := handlers.RegisterRequest{FirstName: "Dewi", LastName: "Lestari", Email: "dewi@example.com", Password: "customer123"}
+	if res := h.do(session{}, http.MethodPost, "/auth/register", register); res.status != http.StatusConflict || res.code() != apperror.CodeEmailTaken {
+		t.Errorf("register with a guest's email: status %d code %q, want 409 %s", res.status, res.code(), apperror.CodeEmailTaken)
+	}
+
+	// The emailed claim link does
+	h.do(session{}, http.MethodPost, "/auth/claim/request", handlers.ClaimRequest{Email: "dewi@example.com"}).
+		expect(t, http.StatusAccepted, nil)
+	sent = h.mail.Sent()
+	if len(sent) != 2 || sent[1].To != "dewi@example.com" {
+		t.Fatalf("sent %+v, want a claim link emailed to the guest", sent)
+	}
+	_, link, _ := strings.Cut(sent[1].Body, "/register/claim?")
+	query, _ := url.ParseQuery(strings.Fields(link + " ")[0])
+	claim := handlers.ClaimAccountRequest{Token: query.Get("token"), FirstName: "Dewi", LastName: "Lestari", Password: "customer123"}
+
+	var claimed handlers.AuthResponse
+	h.do(session{}, http.MethodPost, "/auth/claim", claim).expect(t, http.StatusCreated, &claimed)
+	if claimed.User.IsGuest || claimed.User.ID != order.UserID {
+		t.Errorf("claimed user = %+v, want guest %d turned into an account", claimed.User, order.UserID)
+	}
+	if res := h.do(session{}, http.MethodPost, "/auth/claim", claim); res.status != http.StatusBadRequest || res.code() != apperror.CodeInvalidToken {
+		t.Errorf("reused claim link: status %d code %q, want 400 %s", res.status, res.code(), apperror.CodeInvalidToken)
+	}
+
+	dewi := h.login("dewi@example.com", "customer123")
+	var history []models.Order
+	h.do(dewi, http.MethodGet, "/protected/checkout/history", nil).expect(t, http.StatusOK, &history)
+	if len(history) != 1 || history[0].ID != placed.OrderID {
+		t.Errorf("history after registering = %d orders, want the guest order %d", len(history), placed.OrderID)
+	}
+}
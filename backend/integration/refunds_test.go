@@ -0,0 +1,114 @@
+package integration
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/models"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/services/refunds"
+)
+
+// TestOrderRefunds refunds a paid order in three steps: one item by bank
+// transfer, an amount through the provider after it first declines, and
+// whatever is left
+func TestOrderRefunds(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	product := h.createProduct(admin, "Kopi Arabika", 85000, 5)
+	customer := h.loginAsCustomer("Sari")
+	placed := h.placeOrder(customer, product, 2)
+
+	adminPath := fmt.Sprintf("/protected/admin/orders/%d", placed.OrderID)
+	var order models.Order
+	h.do(admin, http.MethodGet, adminPath, nil).expect(t, http.StatusOK, &order)
+	item := order.OrderItems[0]
+
+	request := handlers.CreateRefundRequest{
+		Items:         []handlers.RefundItemRequest{{OrderItemID: item.ID, Quantity: 1}},
+		Reason:        "Salah varian",
+		Method:        refunds.MethodManualBank,
+		BankReference: "BCA-7781203",
+	}
+	res := h.do(admin, http.MethodPost, adminPath+"/refunds", request)
+	if res.status != http.StatusConflict || res.code() != apperror.CodeRefundNotAllowed {
+		t.Fatalf("refund before payment: status %d code %q, want 409 %s", res.status, res.code(), apperror.CodeRefundNotAllowed)
+	}
+	h.do(admin, http.MethodPut, adminPath+"/payment", handlers.UpdatePaymentStatusRequest{PaymentStatus: "paid"}).
+		expect(t, http.StatusOK, nil)
+
+	// One of two items carries half of the shipping and tax
+	var bank models.Refund
+	h.do(admin, http.MethodPost, adminPath+"/refunds", request).expect(t, http.StatusCreated, &bank)
+	if bank.Amount != 85000+5000+8500 || bank.Status != refunds.StatusSucceeded || bank.BankReference != "BCA-7781203" {
+		t.Errorf("bank refund = %+v, want 98500 succeeded with its reference", bank)
+	}
+
+	// A declined refund is kept as failed and changes nothing
+	h.payments.Err = errors.New("merchant balance too low")
+	amount := int64(50000)
+	byProvider := handlers.CreateRefundRequest{Amount: &amount, Reason: "Kompensasi keterlambatan"}
+	res = h.do(admin, http.MethodPost, adminPath+"/refunds", byProvider)
+	if res.status != http.StatusBadGateway || res.code() != apperror.CodeRefundFailed {
+		t.Fatalf("declined refund: status %d code %q, want 502 %s", res.status, res.code(), apperror.CodeRefundFailed)
+	}
+	h.payments.Err = nil
+	var provider models.Refund
+	h.do(admin, http.MethodPost, adminPath+"/refunds", byProvider).expect(t, http.StatusCreated, &provider)
+	if provider.Method != refunds.MethodProvider || provider.ProviderRef == "" {
+		t.Errorf("provider refund = %+v, want the provider's reference", provider)
+	}
+
+	res = h.do(admin, http.MethodPost, adminPath+"/refunds", request)
+	if res.status != http.StatusConflict || res.code() != apperror.CodeRefundExceedsPaid {
+		t.Errorf("refunding more than is left: status %d code %q, want 409 %s", res.status, res.code(), apperror.CodeRefundExceedsPaid)
+	}
+
+	var customerView models.Order
+	h.do(customer, http.MethodGet, fmt.Sprintf("/protected/checkout/orders/%d", placed.OrderID), nil).
+		expect(t, http.StatusOK, &customerView)
+	if customerView.PaymentStatus != refunds.PaymentPartiallyRefunded || customerView.NetPaid != order.TotalAmount-98500-50000 {
+		t.Errorf("order payment %q net %d, want partially_refunded net %d",
+			customerView.PaymentStatus, customerView.NetPaid, order.TotalAmount-98500-50000)
+	}
+
+	var rest models.Refund
+	h.do(admin, http.MethodPost, adminPath+"/refunds", handlers.CreateRefundRequest{Reason: "Pesanan dibatalkan"}).
+		expect(t, http.StatusCreated, &rest)
+	if rest.Amount != order.TotalAmount-98500-50000 {
+		t.Errorf("final refund = %d, want the remaining %d", rest.Amount, order.TotalAmount-98500-50000)
+	}
+	h.do(admin, http.MethodGet, adminPath, nil).expect(t, http.StatusOK, &order)
+	if order.PaymentStatus != refunds.PaymentRefunded || order.NetPaid != 0 || order.RefundedAmount != order.TotalAmount {
+		t.Errorf("order payment %q refunded %d net %d, want refunded in full", order.PaymentStatus, order.RefundedAmount, order.NetPaid)
+	}
+
+	var list []models.Refund
+	h.do(admin, http.MethodGet, adminPath+"/refunds", nil).expect(t, http.StatusOK, &list)
+	if len(list) != 4 || list[1].Status != refunds.StatusFailed {
+		t.Errorf("order refunds = %+v, want 4 with the declined one failed", list)
+	}
+	var page struct {
+		Refunds []models.Refund `json:"refunds"`
+	}
+	h.do(admin, http.MethodGet, "/protected/admin/refunds?method=manual_bank", nil).expect(t, http.StatusOK, &page)
+	if len(page.Refunds) != 1 || page.Refunds[0].ID != bank.ID {
+		t.Errorf("manual bank refunds = %+v, want the one", page.Refunds)
+	}
+
+	var timeline []orders.TimelineEntry
+	h.do(admin, http.MethodGet, adminPath+"/timeline", nil).expect(t, http.StatusOK, &timeline)
+	var refundEvents int
+	for _, entry := range timeline {
+		if entry.Event == orders.EventRefunded {
+			refundEvents++
+		}
+	}
+	if refundEvents != 3 {
+		t.Errorf("timeline = %+v, want 3 refunds", timeline)
+	}
+}
@@ -0,0 +1,132 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/services/refunds"
+	"ecommerce-backend/services/returns"
+	"ecommerce-backend/services/shipping"
+)
+
+// TestReturnFlow delivers an order, has the customer return one of its two
+// items and takes the return through approval, receipt and refund
+func TestReturnFlow(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	product := h.createProduct(admin, "Kopi Arabika", 85000, 5)
+	customer := h.loginAsCustomer("Sari")
+	placed := h.placeOrder(customer, product, 2)
+
+	customerPath := fmt.Sprintf("/protected/checkout/orders/%d", placed.OrderID)
+	adminPath := fmt.Sprintf("/protected/admin/orders/%d", placed.OrderID)
+
+	var order models.Order
+	h.do(customer, http.MethodGet, customerPath, nil).expect(t, http.StatusOK, &order)
+	item := order.OrderItems[0]
+	request := handlers.ReturnRequest{
+		Reason: "Kemasan bocor",
+		Photos: []string{"https://example.com/returns/bocor.jpg"},
+		Items:  []handlers.ReturnItemRequest{{OrderItemID: item.ID, Quantity: 1}},
+	}
+
+	// Nothing can be returned before it arrives
+	res := h.do(customer, http.MethodPost, customerPath+"/returns", request)
+	if res.status != http.StatusConflict || res.code() != apperror.CodeReturnNotAllowed {
+		t.Fatalf("return before delivery: status %d code %q, want 409 %s", res.status, res.code(), apperror.CodeReturnNotAllowed)
+	}
+
+	var shipped shipping.Result
+	h.do(admin, http.MethodPost, adminPath+"/shipments", handlers.CreateShipmentRequest{Carrier: "jne"}).
+		expect(t, http.StatusCreated, &shipped)
+	h.do(admin, http.MethodPut, fmt.Sprintf("%s/shipments/%d/delivered", adminPath, shipped.Shipment.ID), nil).
+		expect(t, http.StatusOK, nil)
+	h.do(admin, http.MethodPut, adminPath+"/payment", handlers.UpdatePaymentStatusRequest{PaymentStatus: "paid"}).
+		expect(t, http.StatusOK, nil)
+
+	var ret models.Return
+	h.do(customer, http.MethodPost, customerPath+"/returns", request).expect(t, http.StatusCreated, &ret)
+	if ret.Status != returns.StatusRequested || ret.RMANumber == "" || len(ret.Photos) != 1 {
+		t.Fatalf("return = %+v, want a request with an RMA number and a photo", ret)
+	}
+
+	// Only the second item is left to return
+	request.Items[0].Quantity = 2
+	res = h.do(customer, http.MethodPost, customerPath+"/returns", request)
+	if res.status != http.StatusConflict || res.code() != apperror.CodeReturnExceedsOrder {
+		t.Errorf("returning too much: status %d code %q, want 409 %s", res.status, res.code(), apperror.CodeReturnExceedsOrder)
+	}
+
+	var mine []models.Return
+	h.do(customer, http.MethodGet, "/protected/checkout/returns", nil).expect(t, http.StatusOK, &mine)
+	if len(mine) != 1 || mine[0].ID != ret.ID {
+		t.Errorf("customer's returns = %+v, want the one request", mine)
+	}
+	other := h.loginAsCustomer("Joko")
+	if res := h.do(other, http.MethodGet, fmt.Sprintf("/protected/checkout/returns/%d", ret.ID), nil); res.status != http.StatusNotFound {
+		t.Errorf("another customer read the return: status %d", res.status)
+	}
+
+	returnPath := fmt.Sprintf("/protected/admin/returns/%d", ret.ID)
+	h.do(admin, http.MethodPut, returnPath+"/approve", handlers.ReturnDecisionRequest{Note: "Silakan kirim kembali"}).
+		expect(t, http.StatusOK, &ret)
+	h.do(admin, http.MethodPut, returnPath+"/receive", handlers.ReceiveReturnRequest{
+		Items: []handlers.ReturnDispositionRequest{{ReturnItemID: ret.Items[0].ID, Disposition: returns.DispositionRestock}},
+	}).expect(t, http.StatusOK, &ret)
+	if ret.Status != returns.StatusReceived {
+		t.Fatalf("status = %q after receipt, want received", ret.Status)
+	}
+
+	var restocked models.Product
+	h.do(admin, http.MethodGet, fmt.Sprintf("/protected/admin/products/%d", product.ID), nil).expect(t, http.StatusOK, &restocked)
+	if restocked.Stock != 4 {
+		t.Errorf("stock = %d after restocking 1 of 2 sold from 5, want 4", restocked.Stock)
+	}
+
+	// One of two items refunds half of the shipping and tax too
+	const refunded = 85000 + 5000 + 8500
+	h.do(admin, http.MethodPut, returnPath+"/refund", nil).expect(t, http.StatusOK, &ret)
+	if ret.Status != returns.StatusRefunded || ret.RefundAmount != refunded {
+		t.Errorf("refunded return = %+v, want %d refunded", ret, refunded)
+	}
+	if sent := h.payments.Refunds(); len(sent) != 1 || sent[0].Amount != refunded {
+		t.Errorf("payment provider was asked for %+v, want one refund of %d", sent, refunded)
+	}
+
+	h.do(customer, http.MethodGet, customerPath, nil).expect(t, http.StatusOK, &order)
+	if order.PaymentStatus != refunds.PaymentPartiallyRefunded || len(order.Returns) != 1 || len(order.Refunds) != 1 {
+		t.Errorf("order payment %q with %d returns and %d refunds, want partially_refunded with 1 of each",
+			order.PaymentStatus, len(order.Returns), len(order.Refunds))
+	}
+	if order.NetPaid != order.TotalAmount-refunded {
+		t.Errorf("net paid = %d, want %d", order.NetPaid, order.TotalAmount-refunded)
+	}
+
+	var timeline []orders.TimelineEntry
+	h.do(customer, http.MethodGet, customerPath+"/timeline", nil).expect(t, http.StatusOK, &timeline)
+	want := []string{
+		orders.EventPlaced, orders.EventShipped, orders.EventDelivered, orders.EventReturnRequested,
+		orders.EventReturnApproved, orders.EventReturnReceived, orders.EventReturnRefunded,
+	}
+	if len(timeline) != len(want) {
+		t.Fatalf("timeline = %+v, want %v", timeline, want)
+	}
+	for i := range want {
+		if timeline[i].Event != want[i] {
+			t.Errorf("timeline entry %d = %s, want %s", i, timeline[i].Event, want[i])
+		}
+	}
+
+	var stats repository.OrderStats
+	h.do(admin, http.MethodGet, "/protected/admin/orders/stats", nil).expect(t, http.StatusOK, &stats)
+	if stats.TotalRevenue != order.TotalAmount-refunded || stats.RefundedAmount != refunded {
+		t.Errorf("revenue %d with %d refunded, want %d net of the refund",
+			stats.TotalRevenue, stats.RefundedAmount, order.TotalAmount-refunded)
+	}
+}
@@ -0,0 +1,48 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ecommerce-backend/models"
+)
+
+// TestOrderItemSnapshots renames and then deletes a product that was
+// ordered, and checks the order still shows it as it was bought
+func TestOrderItemSnapshots(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	product := h.createProduct(admin, "Kopi Arabika", 85000, 5)
+	customer := h.loginAsCustomer("Sari")
+	placed := h.placeOrder(customer, product, 2)
+
+	productPath := fmt.Sprintf("/protected/admin/products/%d", product.ID)
+	etag := h.do(admin, http.MethodGet, productPath, nil).expect(t, http.StatusOK, nil).header.Get("ETag")
+	h.do(admin, http.MethodPut, productPath, map[string]interface{}{"name": "Kopi Robusta", "price": 60000}, "If-Match", etag).
+		expect(t, http.StatusOK, nil)
+	h.do(admin, http.MethodDelete, productPath, nil).expect(t, http.StatusOK, nil)
+
+	check := func(who string, item models.OrderItem) {
+		t.Helper()
+		if item.ProductName != "Kopi Arabika" || item.UnitPrice != 85000 || item.TaxPercent != 10 || item.TaxAmount != 17000 {
+			t.Errorf("%s sees item %+v, want 2 x Kopi Arabika at 85000 with 10%% tax", who, item)
+		}
+		if item.Product != nil {
+			t.Errorf("%s sees the live product %+v on the item", who, item.Product)
+		}
+	}
+
+	var order models.Order
+	h.do(customer, http.MethodGet, fmt.Sprintf("/protected/checkout/orders/%d", placed.OrderID), nil).
+		expect(t, http.StatusOK, &order)
+	check("customer", order.OrderItems[0])
+
+	var history []models.Order
+	h.do(customer, http.MethodGet, "/protected/checkout/history", nil).expect(t, http.StatusOK, &history)
+	check("history", history[0].OrderItems[0])
+
+	h.do(admin, http.MethodGet, fmt.Sprintf("/protected/admin/orders/%d", placed.OrderID), nil).
+		expect(t, http.StatusOK, &order)
+	check("admin", order.OrderItems[0])
+}
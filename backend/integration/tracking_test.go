@@ -0,0 +1,115 @@
+package integration
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/carriers"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/models"
+	"ecommerce-backend/services/shipping"
+)
+
+// TestShipmentTracking ships an order with the fake JNE, lets the poller
+// pick up its first scans and has the carrier push the delivery
+func TestShipmentTracking(t *testing.T) {
+	h := newHarness(t)
+	admin := h.loginAsAdmin()
+	product := h.createProduct(admin, "Teh Melati", 25000, 5)
+	customer := h.loginAsCustomer("Budi")
+	placed := h.placeOrder(customer, product, 1)
+
+	orderPath := fmt.Sprintf("/protected/admin/orders/%d", placed.OrderID)
+	var shipped shipping.Result
+	h.do(admin, http.MethodPost, orderPath+"/shipments", handlers.CreateShipmentRequest{
+		Carrier:        "jne",
+		TrackingNumber: "JNE0012345678",
+	}).expect(t, http.StatusCreated, &shipped)
+
+	pickedUp := time.Now().Add(time.Hour).Truncate(time.Second)
+	h.carrier.Set("JNE0012345678",
+		carriers.Event{Status: carriers.StatusPickedUp, Description: "SHIPMENT RECEIVED", Location: "JAKARTA", OccurredAt: pickedUp},
+		carriers.Event{Status: carriers.StatusInTransit, Description: "ARRIVED AT HUB", Location: "BANDUNG", OccurredAt: pickedUp.Add(8 * time.Hour)},
+	)
+	tracked, err := h.svc.Tracking.Poll(context.Background())
+	if err != nil || tracked != 1 {
+		t.Fatalf("poll = %d, %v; want 1 shipment tracked", tracked, err)
+	}
+
+	customerPath := fmt.Sprintf("/protected/checkout/orders/%d", placed.OrderID)
+	var order models.Order
+	h.do(customer, http.MethodGet, customerPath, nil).expect(t, http.StatusOK, &order)
+	if len(order.Shipments) != 1 || len(order.Shipments[0].Events) != 2 {
+		t.Fatalf("customer sees %+v, want one shipment with 2 tracking events", order.Shipments)
+	}
+	if first := order.Shipments[0].Events[0]; first.Status != carriers.StatusPickedUp || first.Location != "JAKARTA" {
+		t.Errorf("first event = %+v, want the pickup in Jakarta", first)
+	}
+
+	// The carrier pushes the delivery
+	webhook := handlers.TrackingWebhookRequest{
+		TrackingNumber: "JNE0012345678",
+		Events: []handlers.TrackingEventRequest{{
+			Status:      carriers.StatusDelivered,
+			Description: "DELIVERED TO [BUDI]",
+			Location:    "BANDUNG",
+			OccurredAt:  pickedUp.Add(26 * time.Hour),
+		}},
+	}
+	res := h.do(session{}, http.MethodPost, "/webhooks/carriers/jne", webhook, "X-Signature", "sha256=deadbeef")
+	if res.status != http.StatusUnauthorized || res.code() != apperror.CodeInvalidSignature {
+		t.Errorf("badly signed webhook: status %d code %q, want 401 %s", res.status, res.code(), apperror.CodeInvalidSignature)
+	}
+
+	// Signatures are bound to the carrier and expire
+	if res := h.do(session{}, http.MethodPost, "/webhooks/carriers/sicepat", webhook, h.sign("jne", webhook, time.Now())...); res.status != http.StatusUnauthorized {
+		t.Errorf("webhook signed for another carrier: status %d, want 401", res.status)
+	}
+	if res := h.do(session{}, http.MethodPost, "/webhooks/carriers/jne", webhook, h.sign("jne", webhook, time.Now().Add(-time.Hour))...); res.status != http.StatusUnauthorized {
+		t.Errorf("webhook signed an hour ago: status %d, want 401", res.status)
+	}
+
+	var received handlers.TrackingWebhookResponse
+	h.do(session{}, http.MethodPost, "/webhooks/carriers/jne", webhook, h.sign("jne", webhook, time.Now())...).
+		expect(t, http.StatusOK, &received)
+	if received.Recorded != 1 {
+		t.Errorf("webhook recorded %d events, want 1", received.Recorded)
+	}
+
+	h.do(customer, http.MethodGet, customerPath, nil).expect(t, http.StatusOK, &order)
+	if order.Status != "delivered" || len(order.Shipments[0].Events) != 3 || order.Shipments[0].DeliveredAt == nil {
+		t.Errorf("after delivery: status %q, %d events, delivered at %v; want delivered with 3 events",
+			order.Status, len(order.Shipments[0].Events), order.Shipments[0].DeliveredAt)
+	}
+
+	// A tracking number nobody shipped with
+	webhook.TrackingNumber = "JNE9999999999"
+	res = h.do(session{}, http.MethodPost, "/webhooks/carriers/jne", webhook, h.sign("jne", webhook, time.Now())...)
+	if res.status != http.StatusNotFound || res.code() != apperror.CodeShipmentNotFound {
+		t.Errorf("unknown tracking number: status %d code %q, want 404 %s", res.status, res.code(), apperror.CodeShipmentNotFound)
+	}
+}
+
+// sign returns the signature headers of a webhook for carrier signed at at,
+// with body as harness.do will send it
+func (h *harness) sign(carrier string, body interface{}, at time.Time) []string {
+	h.t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		h.t.Fatalf("encode webhook body: %v", err)
+	}
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(h.cfg.Carriers.WebhookSecret))
+	mac.Write([]byte(carrier + "." + timestamp + "."))
+	mac.Write(data)
+	return []string{"X-Signature", "sha256=" + hex.EncodeToString(mac.Sum(nil)), "X-Signature-Timestamp", timestamp}
+}
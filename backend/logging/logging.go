@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"ecommerce-backend/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of any attribute whose key names personal or
+// secret data
+const Redacted = "[REDACTED]"
+
+// defaultRedactedKeys are always redacted; LogConfig.RedactKeys adds more
+var defaultRedactedKeys = []string{
+	"password", "token", "authorization", "secret", "api_key",
+	"email", "phone", "first_name", "last_name",
+	"street", "address", "shipping_address", "billing_address",
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the request ID, which every log
+// record written with that context will include
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID stored in ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Setup installs the configured slog handler as the default logger. The
+// standard library log package is routed through it as well.
+func Setup(cfg config.LogConfig) {
+	slog.SetDefault(slog.New(newHandler(cfg)))
+}
+
+func newHandler(cfg config.LogConfig) slog.Handler {
+	redacted := make(map[string]bool)
+	keys := append([]string{}, defaultRedactedKeys...)
+	keys = append(keys, cfg.RedactKeys...)
+	for _, key := range keys {
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			redacted[key] = true
+		}
+	}
+
+	opts := &slog.HandlerOptions{
+		Level: ParseLevel(cfg.Level),
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if redacted[strings.ToLower(a.Key)] {
+				return slog.String(a.Key, Redacted)
+			}
+			return a
+		},
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "text") {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	}
+	return contextHandler{handler}
+}
+
+// ParseLevel maps a level name to a slog.Level, defaulting to info
+func ParseLevel(name string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// contextHandler adds the request ID and the trace and span IDs from the
+// record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
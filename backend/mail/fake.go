@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// Fake is an in-memory sender for tests. It accepts every message unless Err
+// is set, and remembers what it sent.
+type Fake struct {
+	mu   sync.Mutex
+	sent []Message
+	// Err, when set, is returned by every Send call
+	Err error
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Send(ctx context.Context, m Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+// Sent returns the messages sent so far
+func (f *Fake) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
@@ -0,0 +1,40 @@
+// Package mail sends the emails customers get from the store, over SMTP when
+// a server is configured and to the log otherwise.
+package mail
+
+import (
+	"context"
+	"log/slog"
+
+	"ecommerce-backend/config"
+)
+
+// Message is a plain text email to one recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// New returns an SMTP sender when a host is configured, and otherwise one
+// that logs each message so development setups can still follow its links
+func New(cfg config.MailConfig) Sender {
+	if cfg.Host == "" {
+		return Log{}
+	}
+	return NewSMTP(cfg)
+}
+
+// Log writes messages to the log instead of sending them
+type Log struct{}
+
+func (Log) Send(ctx context.Context, m Message) error {
+	slog.InfoContext(ctx, "Email not sent, no SMTP server is configured",
+		"to", m.To, "subject", m.Subject, "body", m.Body)
+	return nil
+}
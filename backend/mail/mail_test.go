@@ -0,0 +1,154 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/config"
+)
+
+// session is what the test SMTP server was told
+type session struct {
+	auth string
+	from string
+	to   string
+	data string
+}
+
+// serve answers one SMTP conversation on a local port and reports it once
+// the client quits
+func serve(t *testing.T) (string, <-chan session) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	done := make(chan session, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		var s session
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+			case "EHLO":
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				s.auth = line
+				reply("235 Authenticated")
+			case "MAIL":
+				s.from = line
+				reply("250 OK")
+			case "RCPT":
+				s.to = line
+				reply("250 OK")
+			case "DATA":
+				reply("354 Go ahead")
+				var b strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					b.WriteString(line)
+				}
+				s.data = b.String()
+				reply("250 Queued")
+			case "QUIT":
+				reply("221 Bye")
+				done <- s
+				return
+			default:
+				reply("502 Unknown command")
+			}
+		}
+	}()
+	return l.Addr().String(), done
+}
+
+func TestSMTP(t *testing.T) {
+	addr, done := serve(t)
+	host, port, _ := net.SplitHostPort(addr)
+	s := NewSMTP(config.MailConfig{
+		Host:     host,
+		Port:     port,
+		Username: "toko",
+		Password: "rahasia",
+		From:     "no-reply@toko.example.com",
+		FromName: "Toko Kopi",
+		Timeout:  5 * time.Second,
+	})
+	s.now = func() time.Time { return time.Date(2024, 3, 9, 10, 30, 0, 0, time.UTC) }
+
+	err := s.Send(context.Background(), Message{
+		To:      "sari@example.com",
+		Subject: "Pesanan ORD-20240309-0001 — terima kasih",
+		Body:    "Lihat pesanan Anda:\nhttps://toko.example.com/orders/lookup?token=abc=def\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := <-done
+
+	if got.auth != "AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00toko\x00rahasia")) {
+		t.Errorf("auth = %q", got.auth)
+	}
+	if got.from != "MAIL FROM:<no-reply@toko.example.com>" || got.to != "RCPT TO:<sari@example.com>" {
+		t.Errorf("envelope = %q, %q", got.from, got.to)
+	}
+
+	msg, err := netmail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec mime.WordDecoder
+	subject, _ := dec.DecodeHeader(msg.Header.Get("Subject"))
+	from, _ := dec.DecodeHeader(msg.Header.Get("From"))
+	if subject != "Pesanan ORD-20240309-0001 — terima kasih" || from != "Toko Kopi <no-reply@toko.example.com>" {
+		t.Errorf("subject %q from %q", subject, from)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if want := "Lihat pesanan Anda:\r\nhttps://toko.example.com/orders/lookup?token=abc=def\r\n"; string(body) != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestSMTPRejectsHeaderInjection(t *testing.T) {
+	s := NewSMTP(config.MailConfig{Host: "127.0.0.1", Port: "25", From: "no-reply@toko.example.com", Timeout: time.Second})
+	err := s.Send(context.Background(), Message{To: "sari@example.com\r\nBcc: all@example.com", Subject: "Hi"})
+	if err == nil {
+		t.Fatal("message with a line break in its recipient was sent")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, ok := New(config.MailConfig{}).(Log); !ok {
+		t.Error("sender without a host does not log")
+	}
+	if _, ok := New(config.MailConfig{Host: "smtp.example.com"}).(*SMTP); !ok {
+		t.Error("sender with a host does not use SMTP")
+	}
+}
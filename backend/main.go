@@ -1,20 +1,35 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	_ "ecommerce-backend/docs"
 
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
 
+	"ecommerce-backend/cache"
+	"ecommerce-backend/carriers"
 	"ecommerce-backend/config"
 	"ecommerce-backend/database"
-	"ecommerce-backend/middleware"
-	"ecommerce-backend/routes"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/mail"
+	"ecommerce-backend/payments"
+	"ecommerce-backend/seed"
+	"ecommerce-backend/server"
+	"ecommerce-backend/services"
+	"ecommerce-backend/services/bulk"
+	"ecommerce-backend/services/tracking"
+	"ecommerce-backend/tracing"
 )
 
 // @title E-Commerce API
@@ -36,82 +51,142 @@ import (
 // @in header
 // @name Authorization
 func main() {
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	migrate := flag.Bool("migrate", false, "apply pending database migrations (and seed demo data outside production) before starting")
+	flag.Parse()
+
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
 	}
 
+	// Load and validate configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *printConfig {
+		fmt.Print(cfg)
+		return
+	}
+
+	// Configure structured logging before anything else logs
+	logging.Setup(cfg.Log)
+	slog.Info("Configuration loaded", "env", cfg.Env, "config", cfg.Redacted())
+	if cfg.UsesDefaultSecrets() {
+		slog.Warn("Using default development secrets; set JWT_SECRET and DB_PASSWORD before deploying")
+	}
+
+	// Tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, cfg.Env)
+	if err != nil {
+		log.Fatal("Failed to set up tracing:", err)
+	}
+
 	// Initialize database
-	database.ConnectDB()
-	database.ConnectRedis()
-
-	// Seed initial data
-	database.SeedData()
-
-	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		ErrorHandler: middleware.ErrorHandler,
-	})
-
-	// Middleware
-	app.Use(recover.New())
-	app.Use(logger.New())
-
-	// CORS configuration
-	app.Use(cors.New(cors.Config{
-		AllowOrigins:     config.GetString("CORS_ORIGINS", "http://localhost:5173,http://localhost:5174"),
-		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
-		ExposeHeaders:    "Content-Length",
-		AllowCredentials: true,
-	}))
-
-	// Health check
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status":  "ok",
-			"message": "E-Commerce API is running",
-		})
-	})
-
-	// Root route
-	app.Get("/", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"message": "E-Commerce API v1.0",
-			"version": "1.0",
-			"endpoints": fiber.Map{
-				"health": "/health",
-				"api":    "/api/v1",
-				"docs":   "/swagger/index.html",
-			},
-		})
-	})
-
-	// Swagger documentation - serve static files
-	app.Static("/swagger", "./docs")
-
-	// API routes
-	api := app.Group("/api/v1")
-
-	// Public routes
-	routes.PublicRoutes(api)
-
-	// Protected routes
-	protected := api.Group("/protected")
-	protected.Use(middleware.JWTProtected())
-	routes.ProtectedRoutes(protected)
-
-	// Admin routes
-	admin := protected.Group("/admin")
-	admin.Use(middleware.AdminProtected())
-	routes.AdminRoutes(admin)
+	database.ConnectDB(cfg.Database)
+	database.ConnectRedis(cfg.Redis)
+	cache.SetEnabled(cfg.Cache.Enabled)
+
+	// Schema changes only run on boot when asked for; otherwise use cmd/migrate.
+	// Demo data comes with known passwords, so it is never seeded in
+	// production; use cmd/seed -profile minimal with an admin password there.
+	if *migrate || cfg.Database.AutoMigrate {
+		if err := database.Migrate(context.Background()); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+		if cfg.Env == config.EnvProduction {
+			slog.Info("Skipping demo seed data in production")
+		} else if err := seed.Run(context.Background(), database.DB, seed.Options{Profile: "demo", Seed: 1, Env: cfg.Env}); err != nil {
+			log.Fatal("Failed to seed database:", err)
+		}
+	}
+
+	// Create Fiber app with every route mounted
+	if cfg.Mail.Host == "" {
+		slog.Warn("No SMTP server configured; customer emails are written to the log")
+	}
+	trackers := carriers.New(cfg.Carriers)
+	svc := services.New(database.DB, cfg, trackers, payments.New(cfg.Payments), mail.New(cfg.Mail), handlers.InvalidateProduct)
+	app := server.New(cfg, handlers.New(cfg, svc))
+
+	// Poll carriers for open shipments in the background. Shutdown waits
+	// for workers so they never run against closed connections.
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	defer stopPolling()
+	var workers sync.WaitGroup
+	if cfg.Carriers.PollInterval > 0 && len(trackers) > 0 {
+		slog.Info("Tracking shipments", "carriers", trackers.Codes(), "interval", cfg.Carriers.PollInterval)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			tracking.Run(pollCtx, svc.Tracking, cfg.Carriers.PollInterval)
+		}()
+	}
+
+	// Work through bulk order jobs in the background. A job cut short by
+	// shutdown goes back in the queue.
+	if cfg.Jobs.PollInterval > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			bulk.Run(pollCtx, svc.Bulk, cfg.Jobs.PollInterval)
+		}()
+	}
 
 	// Start server
-	port := config.GetString("PORT", "8080")
-	log.Printf("Server starting on port %s", port)
-	log.Printf("Swagger documentation available at http://localhost:%s/swagger/index.html", port)
+	port := cfg.Server.Port
+	slog.Info("Server starting", "port", port,
+		"swagger", "http://localhost:"+port+"/swagger/index.html")
+
+	go func() {
+		if err := app.Listen(":" + port); err != nil {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// Wait for SIGINT/SIGTERM, then shut down gracefully
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	sig := <-quit
+
+	// Fail readiness first and give load balancers time to notice before
+	// the listener closes
+	slog.Info("Shutting down", "signal", sig.String())
+	handlers.MarkShuttingDown()
+	time.Sleep(cfg.Server.ShutdownDrainDelay)
+
+	// Stop accepting connections and wait for in-flight requests
+	stopPolling()
+	if err := app.ShutdownWithTimeout(cfg.Server.ShutdownTimeout); err != nil {
+		slog.Error("Server shutdown did not complete", "error", err)
+	}
+
+	// Let background workers finish the order they are on before Redis and
+	// the database close
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(cfg.Server.ShutdownTimeout):
+		slog.Error("Background workers did not stop in time", "timeout", cfg.Server.ShutdownTimeout)
+	}
 
-	if err := app.Listen(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	if err := database.CloseRedis(); err != nil {
+		slog.Error("Failed to close Redis", "error", err)
+	}
+	if err := database.CloseDB(); err != nil {
+		slog.Error("Failed to close database", "error", err)
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Server stopped")
 }
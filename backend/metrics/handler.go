@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler serves the Prometheus exposition format. When token is not empty
+// scrapers must send it as a bearer token, since revenue and order volume
+// are not public.
+func Handler(token string) fiber.Handler {
+	serve := adaptor.HTTPHandler(promhttp.Handler())
+	return func(c *fiber.Ctx) error {
+		if token != "" {
+			expected := []byte("Bearer " + token)
+			if subtle.ConstantTimeCompare([]byte(c.Get(fiber.HeaderAuthorization)), expected) != 1 {
+				return fiber.ErrUnauthorized
+			}
+		}
+		return serve(c)
+	}
+}
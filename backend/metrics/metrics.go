@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace prefixes every metric this service exports
+const namespace = "ecommerce"
+
+// HTTP metrics. route is the matched route pattern, e.g.
+// /api/v1/products/:id, never the raw path, so label cardinality stays bounded.
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route", "status"})
+
+	HTTPInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+)
+
+// Business metrics
+var (
+	OrdersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_created_total",
+		Help:      "Orders placed through checkout.",
+	})
+
+	CheckoutFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checkout_failures_total",
+		Help:      "Failed checkouts by reason, which is the API error code.",
+	}, []string{"reason"})
+
+	Revenue = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Total amount of orders placed, in the smallest currency unit.",
+	})
+
+	CartAdditions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cart_additions_total",
+		Help:      "Products added to carts, by source (cart or guest_merge).",
+	}, []string{"source"})
+
+	CarrierTracking = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "carrier_tracking_total",
+		Help:      "Shipments tracked with their carrier, by carrier and result (ok, unknown or error).",
+	}, []string{"carrier", "result"})
+
+	Refunds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refunds_total",
+		Help:      "Refunds by method (provider or manual_bank) and outcome (succeeded or failed).",
+	}, []string{"method", "status"})
+
+	OrderExports = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "order_exports_total",
+		Help:      "Streamed order exports by format (csv or xlsx) and outcome (ok, cancelled or failed).",
+	}, []string{"format", "outcome"})
+
+	OrderExportDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "order_export_duration_seconds",
+		Help:      "Time spent streaming an order export, by outcome.",
+		Buckets:   []float64{.1, .5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"outcome"})
+)
+
+// RecordOrder counts a placed order and its value
+func RecordOrder(totalAmount int64) {
+	OrdersCreated.Inc()
+	Revenue.Add(float64(totalAmount))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"ecommerce-backend/apperror"
 	"ecommerce-backend/config"
 	"ecommerce-backend/database"
 	"ecommerce-backend/models"
@@ -13,21 +14,17 @@ import (
 )
 
 // JWTProtected middleware for JWT authentication
-func JWTProtected() fiber.Handler {
+func JWTProtected(cfg config.JWTConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Authorization header required",
-			})
+			return apperror.Unauthorized(apperror.CodeUnauthorized, "Authorization header required")
 		}
 
 		// Check Bearer token format
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid authorization header format",
-			})
+			return apperror.Unauthorized(apperror.CodeUnauthorized, "Invalid authorization header format")
 		}
 
 		// Parse and validate token
@@ -35,36 +32,28 @@ func JWTProtected() fiber.Handler {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(config.GetString("JWT_SECRET", "your-super-secret-jwt-key")), nil
+			return []byte(cfg.Secret), nil
 		})
 
 		if err != nil || !token.Valid {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid or expired token",
-			})
+			return apperror.Unauthorized(apperror.CodeInvalidToken, "Invalid or expired token")
 		}
 
 		// Extract user claims
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token claims",
-			})
+			return apperror.Unauthorized(apperror.CodeInvalidToken, "Invalid token claims")
 		}
 
 		// Get user from database
 		userID := uint(claims["user_id"].(float64))
 		var user models.User
-		if err := database.DB.First(&user, userID).Error; err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "User not found",
-			})
+		if err := database.DB.WithContext(c.UserContext()).First(&user, userID).Error; err != nil {
+			return apperror.Unauthorized(apperror.CodeInvalidToken, "User not found")
 		}
 
 		if !user.IsActive {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "User account is inactive",
-			})
+			return apperror.Unauthorized(apperror.CodeAccountInactive, "User account is inactive")
 		}
 
 		// Store user in context
@@ -78,9 +67,7 @@ func AdminProtected() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		user := c.Locals("user").(models.User)
 		if user.Role != "admin" {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Admin access required",
-			})
+			return apperror.Forbidden("Admin access required")
 		}
 		return c.Next()
 	}
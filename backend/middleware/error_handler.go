@@ -1,16 +1,48 @@
 package middleware
 
 import (
+	"errors"
+	"log/slog"
+
+	"ecommerce-backend/apperror"
+
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// ErrorHandler provides centralized error handling for the Fiber application
+// ErrorHandler provides centralized error handling for the Fiber application.
+// Every error is rendered as an application/problem+json document; anything
+// that is not an *apperror.Error is logged and reported as a generic 500 so
+// internal messages never reach the client.
 func ErrorHandler(c *fiber.Ctx, err error) error {
-	code := fiber.StatusInternalServerError
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			appErr = apperror.FromStatus(fiberErr.Code, fiberErr.Message)
+		} else {
+			appErr = apperror.Internal("An unexpected error occurred", err)
+		}
+	}
+
+	if appErr.Status >= fiber.StatusInternalServerError {
+		slog.ErrorContext(c.UserContext(), "Request failed",
+			"method", c.Method(), "path", c.Path(), "error", appErr)
+		trace.SpanFromContext(c.UserContext()).RecordError(appErr)
+	}
+
+	requestID, _ := c.Locals("requestid").(string)
+	return c.Status(appErr.Status).JSON(appErr.ProblemBody(c.OriginalURL(), requestID), apperror.ContentType)
+}
+
+// renderError hands err to the app's error handler straight away. Middleware
+// that reports on the response calls it after c.Next() so it sees the status
+// the client will get instead of the status before the error was rendered.
+func renderError(c *fiber.Ctx, err error) {
+	if err == nil {
+		return
+	}
+	if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+		_ = c.SendStatus(fiber.StatusInternalServerError)
 	}
-	return c.Status(code).JSON(fiber.Map{
-		"error": err.Error(),
-	})
 }
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"ecommerce-backend/apperror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   apperror.Code
+		wantDetail string
+	}{
+		{
+			name:       "application error",
+			err:        apperror.NotFound(apperror.CodeOrderNotFound, "Order not found"),
+			wantStatus: fiber.StatusNotFound,
+			wantCode:   apperror.CodeOrderNotFound,
+			wantDetail: "Order not found",
+		},
+		{
+			name:       "wrapped application error",
+			err:        fmt.Errorf("loading order: %w", apperror.Conflict(apperror.CodeVersionConflict, "Resource has been modified by someone else")),
+			wantStatus: fiber.StatusConflict,
+			wantCode:   apperror.CodeVersionConflict,
+			wantDetail: "Resource has been modified by someone else",
+		},
+		{
+			name:       "fiber error",
+			err:        fiber.NewError(fiber.StatusMethodNotAllowed, "Method Not Allowed"),
+			wantStatus: fiber.StatusMethodNotAllowed,
+			wantCode:   apperror.CodeMethodNotAllowed,
+			wantDetail: "Method Not Allowed",
+		},
+		{
+			name:       "unknown error",
+			err:        errors.New("pq: password authentication failed for user shop"),
+			wantStatus: fiber.StatusInternalServerError,
+			wantCode:   apperror.CodeInternal,
+			wantDetail: "An unexpected error occurred",
+		},
+		{
+			name:       "internal error",
+			err:        apperror.Internal("Failed to get order", errors.New("pq: connection refused")),
+			wantStatus: fiber.StatusInternalServerError,
+			wantCode:   apperror.CodeInternal,
+			wantDetail: "Failed to get order",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Use(func(c *fiber.Ctx) error {
+				c.Locals("requestid", "req-1")
+				return c.Next()
+			})
+			app.Get("/orders/:id", func(c *fiber.Ctx) error {
+				return tt.err
+			})
+
+			res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/orders/9?expand=items", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if ct := res.Header.Get(fiber.HeaderContentType); ct != apperror.ContentType {
+				t.Errorf("Content-Type = %s, want %s", ct, apperror.ContentType)
+			}
+
+			raw, _ := io.ReadAll(res.Body)
+			var body map[string]interface{}
+			if err := json.Unmarshal(raw, &body); err != nil {
+				t.Fatalf("body %s: %v", raw, err)
+			}
+			if body["code"] != string(tt.wantCode) || body["detail"] != tt.wantDetail || body["status"] != float64(tt.wantStatus) {
+				t.Errorf("body = %v, want %s %q", body, tt.wantCode, tt.wantDetail)
+			}
+			if body["instance"] != "/orders/9?expand=items" || body["request_id"] != "req-1" {
+				t.Errorf("instance %v and request_id %v, want the request's", body["instance"], body["request_id"])
+			}
+		})
+	}
+}
+
+func TestErrorHandlerUnknownRoute(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/nope", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(res.Body)
+	var body map[string]interface{}
+	_ = json.Unmarshal(raw, &body)
+	if res.StatusCode != fiber.StatusNotFound || body["code"] != string(apperror.CodeNotFound) {
+		t.Errorf("status %d with body %v, want a 404 problem", res.StatusCode, body)
+	}
+	if _, ok := body["request_id"]; ok {
+		t.Error("request_id sent without a request ID")
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"ecommerce-backend/logging"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// HeaderRequestID carries the request ID in both directions
+const HeaderRequestID = "X-Request-ID"
+
+// validRequestID limits which client-supplied IDs are trusted, so arbitrary
+// text cannot be injected into logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID assigns every request an ID, reusing a well-formed X-Request-ID
+// from the client. The ID is echoed in the response, stored in Locals under
+// "requestid" and put on the user context so handlers and GORM queries that
+// use c.UserContext() log it.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(HeaderRequestID)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(HeaderRequestID, id)
+		c.Locals("requestid", id)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), id))
+		return c.Next()
+	}
+}
+
+// RequestLogger writes one structured access log line per request. Errors
+// are rendered by the app's error handler first so the logged status is the
+// one the client received.
+func RequestLogger() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		renderError(c, c.Next())
+
+		status := c.Response().StatusCode()
+		// Reading a streamed body would pull all of it into memory, so its
+		// size is left unknown
+		size := -1
+		if !c.Response().IsBodyStream() {
+			size = len(c.Response().Body())
+		}
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		slog.Log(c.UserContext(), level, "Request completed",
+			"method", c.Method(),
+			"path", c.Path(),
+			"route", c.Route().Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", size,
+			"ip", c.IP(),
+		)
+		return nil
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"ecommerce-backend/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics records request count and latency per route pattern and status.
+// Errors are rendered first so the recorded status is the one sent to the
+// client.
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		metrics.HTTPInFlight.Inc()
+		defer metrics.HTTPInFlight.Dec()
+
+		renderError(c, c.Next())
+
+		status := strconv.Itoa(c.Response().StatusCode())
+		route := c.Route().Path
+		metrics.HTTPRequests.WithLabelValues(c.Method(), route, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Method(), route, status).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
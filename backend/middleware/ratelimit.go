@@ -0,0 +1,264 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyFunc extracts the identity a rate limit is counted against
+type KeyFunc func(c *fiber.Ctx) string
+
+// RateLimitPolicy describes how many requests an identity may make per window
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	KeyBy  KeyFunc
+}
+
+// KeyByIP counts requests per client IP
+func KeyByIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
+
+// KeyByUser counts requests per authenticated user, falling back to the client IP
+func KeyByUser(c *fiber.Ctx) string {
+	if user, ok := c.Locals("user").(models.User); ok && user.ID != 0 {
+		return "user:" + strconv.FormatUint(uint64(user.ID), 10)
+	}
+	return KeyByIP(c)
+}
+
+// KeyByAPIKey counts requests per X-API-Key header when it is one of keys,
+// and per client IP otherwise, so that made-up keys cannot dodge the limit
+func KeyByAPIKey(keys []string) KeyFunc {
+	// Buckets are named by a digest so that keys never end up in Redis
+	known := make(map[string]string, len(keys))
+	for _, key := range keys {
+		sum := sha256.Sum256([]byte(key))
+		known[key] = "apikey:" + hex.EncodeToString(sum[:8])
+	}
+	return func(c *fiber.Ctx) string {
+		if bucket, ok := known[c.Get("X-API-Key")]; ok {
+			return bucket
+		}
+		return KeyByIP(c)
+	}
+}
+
+// keyFunc returns the KeyFunc for a config's by value
+func keyFunc(cfg config.RateLimitConfig, by string) KeyFunc {
+	switch by {
+	case "user":
+		return KeyByUser
+	case "api_key":
+		return KeyByAPIKey(cfg.APIKeys)
+	default:
+		return KeyByIP
+	}
+}
+
+// RateLimitFromConfig returns the limiter for a route group, or a no-op when
+// rate limiting is disabled. rate ("10/1m") and by (ip, user or api_key)
+// come from a config that config.Load has already validated.
+func RateLimitFromConfig(cfg config.RateLimitConfig, name, rate, by string) fiber.Handler {
+	if !cfg.Enabled {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	limit, window, err := config.ParseRate(rate)
+	if err != nil {
+		panic(fmt.Sprintf("rate limit %s: %v", name, err))
+	}
+	return RateLimit(RateLimitPolicy{Name: name, Limit: limit, Window: window, KeyBy: keyFunc(cfg, by)})
+}
+
+// RateLimit enforces a sliding-window limit shared across replicas through
+// Redis, and falls back to a per-process limiter while Redis is unreachable
+func RateLimit(policy RateLimitPolicy) fiber.Handler {
+	if policy.KeyBy == nil {
+		policy.KeyBy = KeyByIP
+	}
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Window.Seconds()))
+
+	return func(c *fiber.Ctx) error {
+		key := "ratelimit:" + policy.Name + ":" + policy.KeyBy(c)
+		now := time.Now()
+
+		result, err := redisAllow(c.UserContext(), key, policy, now)
+		if err != nil {
+			reportRedisFallback(err)
+			result = fallbackLimiter.allow(key, policy, now)
+		} else {
+			reportRedisRecovered()
+		}
+
+		remaining := policy.Limit - result.count
+		if remaining < 0 {
+			remaining = 0
+		}
+		resetSeconds := int((result.reset + time.Second - 1) / time.Second)
+
+		c.Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(resetSeconds))
+		c.Set("RateLimit-Policy", policyHeader)
+
+		if !result.allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(resetSeconds))
+			return apperror.New(fiber.StatusTooManyRequests, apperror.CodeRateLimited,
+				"Too many requests, please try again later")
+		}
+
+		return c.Next()
+	}
+}
+
+type rateLimitResult struct {
+	allowed bool
+	count   int
+	reset   time.Duration
+}
+
+// slidingWindowScript keeps one sorted-set member per accepted request,
+// scored by its timestamp in milliseconds
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+func redisAllow(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (rateLimitResult, error) {
+	if database.RedisClient == nil {
+		return rateLimitResult{}, fmt.Errorf("redis client not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	values, err := slidingWindowScript.Run(ctx, database.RedisClient, []string{key},
+		now.UnixMilli(), policy.Window.Milliseconds(), policy.Limit, uuid.NewString()).Int64Slice()
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	if len(values) != 3 {
+		return rateLimitResult{}, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
+
+	return rateLimitResult{
+		allowed: values[0] == 1,
+		count:   int(values[1]),
+		reset:   time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+var redisDegraded atomic.Bool
+
+func reportRedisFallback(err error) {
+	if redisDegraded.CompareAndSwap(false, true) {
+		slog.Warn("Rate limiter falling back to in-memory store", "error", err)
+	}
+}
+
+func reportRedisRecovered() {
+	if redisDegraded.CompareAndSwap(true, false) {
+		slog.Info("Rate limiter using Redis again")
+	}
+}
+
+// memoryLimiter is a per-process sliding log used while Redis is down
+type memoryLimiter struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	hits   []time.Time
+	window time.Duration
+}
+
+var fallbackLimiter = &memoryLimiter{entries: make(map[string]*memoryEntry)}
+
+func (m *memoryLimiter) allow(key string, policy RateLimitPolicy, now time.Time) rateLimitResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > time.Minute {
+		m.sweep(now)
+	}
+
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &memoryEntry{window: policy.Window}
+		m.entries[key] = entry
+	}
+	entry.prune(now)
+
+	allowed := len(entry.hits) < policy.Limit
+	if allowed {
+		entry.hits = append(entry.hits, now)
+	}
+
+	reset := policy.Window
+	if len(entry.hits) > 0 {
+		reset = entry.hits[0].Add(policy.Window).Sub(now)
+	}
+
+	return rateLimitResult{allowed: allowed, count: len(entry.hits), reset: reset}
+}
+
+func (m *memoryLimiter) sweep(now time.Time) {
+	for key, entry := range m.entries {
+		entry.prune(now)
+		if len(entry.hits) == 0 {
+			delete(m.entries, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+func (e *memoryEntry) prune(now time.Time) {
+	cutoff := now.Add(-e.window)
+	i := 0
+	for i < len(e.hits) && !e.hits[i].After(cutoff) {
+		i++
+	}
+	e.hits = e.hits[i:]
+}
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// useRedis points database.RedisClient at a fresh miniredis for the test,
+// and empties the in-memory fallback
+func useRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	fallbackLimiter = &memoryLimiter{entries: make(map[string]*memoryEntry)}
+	mr := miniredis.RunT(t)
+	previous := database.RedisClient
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		database.RedisClient.Close()
+		database.RedisClient = previous
+	})
+	return mr
+}
+
+// allowFunc is one of the two limiter stores, called at now
+type allowFunc func(t *testing.T, key string, policy RateLimitPolicy, now time.Time) rateLimitResult
+
+var stores = []struct {
+	name  string
+	allow allowFunc
+}{
+	{
+		name: "redis",
+		allow: func(t *testing.T, key string, policy RateLimitPolicy, now time.Time) rateLimitResult {
+			result, err := redisAllow(context.Background(), key, policy, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return result
+		},
+	},
+	{
+		name: "memory",
+		allow: func(t *testing.T, key string, policy RateLimitPolicy, now time.Time) rateLimitResult {
+			return fallbackLimiter.allow(key, policy, now)
+		},
+	},
+}
+
+func TestSlidingWindow(t *testing.T) {
+	policy := RateLimitPolicy{Name: "window", Limit: 2, Window: time.Minute}
+	start := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+
+	// Each step is a request made at the offset from start
+	steps := []struct {
+		at          time.Duration
+		wantAllowed bool
+		wantCount   int
+		wantReset   time.Duration
+	}{
+		{at: 0, wantAllowed: true, wantCount: 1, wantReset: time.Minute},
+		{at: 20 * time.Second, wantAllowed: true, wantCount: 2, wantReset: 40 * time.Second},
+		{at: 50 * time.Second, wantAllowed: false, wantCount: 2, wantReset: 10 * time.Second},
+		// The first request has left the window, the second has not
+		{at: 61 * time.Second, wantAllowed: true, wantCount: 2, wantReset: 19 * time.Second},
+		{at: 62 * time.Second, wantAllowed: false, wantCount: 2, wantReset: 18 * time.Second},
+		// Both remaining requests have left the window
+		{at: 3 * time.Minute, wantAllowed: true, wantCount: 1, wantReset: time.Minute},
+	}
+	for _, store := range stores {
+		t.Run(store.name, func(t *testing.T) {
+			useRedis(t)
+			key := "ratelimit:test:" + store.name + ":" + t.Name()
+			for _, step := range steps {
+				got := store.allow(t, key, policy, start.Add(step.at))
+				if got.allowed != step.wantAllowed || got.count != step.wantCount || got.reset != step.wantReset {
+					t.Errorf("at %v: allowed %v count %d reset %v, want %v %d %v",
+						step.at, got.allowed, got.count, got.reset, step.wantAllowed, step.wantCount, step.wantReset)
+				}
+			}
+		})
+	}
+}
+
+// limitedApp serves GET / behind policy
+func limitedApp(policy RateLimitPolicy) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		if id, err := strconv.Atoi(c.Get("X-Test-User")); err == nil {
+			c.Locals("user", models.User{ID: uint(id)})
+		}
+		return c.Next()
+	})
+	app.Get("/", RateLimit(policy), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	return app
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		// redisDown closes Redis before the requests, so the in-memory
+		// fallback has to count them
+		redisDown bool
+	}{
+		{name: "redis"},
+		{name: "redis unreachable", redisDown: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := useRedis(t)
+			if tt.redisDown {
+				mr.Close()
+			}
+			app := limitedApp(RateLimitPolicy{Name: "limit-" + tt.name, Limit: 2, Window: time.Minute})
+
+			wantRemaining := []string{"1", "0", "0"}
+			for i, want := range wantRemaining {
+				res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := res.Header.Get("RateLimit-Remaining"); got != want {
+					t.Errorf("request %d: RateLimit-Remaining = %s, want %s", i+1, got, want)
+				}
+				if res.Header.Get("RateLimit-Limit") != "2" || res.Header.Get("RateLimit-Policy") != "2;w=60" {
+					t.Errorf("request %d: limit headers %v", i+1, res.Header)
+				}
+				blocked := i == len(wantRemaining)-1
+				if blocked != (res.StatusCode == fiber.StatusTooManyRequests) {
+					t.Errorf("request %d: status %d", i+1, res.StatusCode)
+				}
+				if blocked && res.Header.Get(fiber.HeaderRetryAfter) == "" {
+					t.Error("blocked request has no Retry-After")
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	cfg := config.RateLimitConfig{APIKeys: []string{"partner-key"}}
+
+	tests := []struct {
+		name    string
+		by      string
+		headers [2]map[string]string
+		// wantShared is whether the two requests count against one bucket
+		wantShared bool
+	}{
+		{
+			name:       "ip",
+			by:         "ip",
+			headers:    [2]map[string]string{{"X-Test-User": "1"}, {"X-Test-User": "2"}},
+			wantShared: true,
+		},
+		{
+			name:    "user",
+			by:      "user",
+			headers: [2]map[string]string{{"X-Test-User": "1"}, {"X-Test-User": "2"}},
+		},
+		{
+			name:       "anonymous users share the IP",
+			by:         "user",
+			headers:    [2]map[string]string{{}, {}},
+			wantShared: true,
+		},
+		{
+			name:    "known api key",
+			by:      "api_key",
+			headers: [2]map[string]string{{"X-API-Key": "partner-key"}, {}},
+		},
+		{
+			// Made-up keys cannot get a fresh bucket each
+			name:       "unknown api keys count per IP",
+			by:         "api_key",
+			headers:    [2]map[string]string{{"X-API-Key": "made-up-1"}, {"X-API-Key": "made-up-2"}},
+			wantShared: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRedis(t)
+			app := limitedApp(RateLimitPolicy{Name: "keys", Limit: 1, Window: time.Minute, KeyBy: keyFunc(cfg, tt.by)})
+
+			var statuses []int
+			for _, headers := range tt.headers {
+				req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+				for k, v := range headers {
+					req.Header.Set(k, v)
+				}
+				res, err := app.Test(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				statuses = append(statuses, res.StatusCode)
+			}
+			shared := statuses[1] == fiber.StatusTooManyRequests
+			if statuses[0] != fiber.StatusOK || shared != tt.wantShared {
+				t.Errorf("statuses = %v, want shared bucket %v", statuses, tt.wantShared)
+			}
+		})
+	}
+}
+
+func TestRateLimitFromConfigDisabled(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", RateLimitFromConfig(config.RateLimitConfig{Enabled: false}, "off", "1/1m", "ip"), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	for i := 0; i < 3; i++ {
+		res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != fiber.StatusOK || res.Header.Get("RateLimit-Limit") != "" {
+			t.Fatalf("request %d: status %d with limit %q, want unlimited", i+1, res.StatusCode, res.Header.Get("RateLimit-Limit"))
+		}
+	}
+}
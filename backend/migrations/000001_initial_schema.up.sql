@@ -0,0 +1,146 @@
+-- Baseline schema, matching what GORM AutoMigrate created for the models.
+-- Everything is guarded with IF NOT EXISTS so databases created before
+-- versioned migrations can adopt this version. Columns added to the models
+-- after the first AutoMigrate release are also added with ALTER TABLE, since
+-- CREATE TABLE IF NOT EXISTS leaves an existing table as it is.
+
+CREATE TABLE IF NOT EXISTS users (
+    id         BIGSERIAL PRIMARY KEY,
+    first_name TEXT NOT NULL,
+    last_name  TEXT NOT NULL,
+    email      TEXT NOT NULL,
+    password   TEXT NOT NULL,
+    phone      TEXT,
+    role       TEXT DEFAULT 'user',
+    is_active  BOOLEAN DEFAULT TRUE,
+    version    BIGINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS categories (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    description TEXT,
+    image       TEXT,
+    is_active   BOOLEAN DEFAULT TRUE,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    deleted_at  TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name ON categories (name);
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories (deleted_at);
+
+CREATE TABLE IF NOT EXISTS products (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    description TEXT,
+    price       BIGINT NOT NULL,
+    stock       BIGINT DEFAULT 0,
+    category_id BIGINT NOT NULL,
+    image       TEXT,
+    images      TEXT[],
+    sku         TEXT,
+    weight      DECIMAL,
+    dimensions  TEXT,
+    is_active   BOOLEAN DEFAULT TRUE,
+    version     BIGINT NOT NULL DEFAULT 1,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    deleted_at  TIMESTAMPTZ,
+    CONSTRAINT fk_categories_products FOREIGN KEY (category_id) REFERENCES categories (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products (sku);
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS carts (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL,
+    is_active  BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    CONSTRAINT fk_users_carts FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_carts_deleted_at ON carts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    id         BIGSERIAL PRIMARY KEY,
+    cart_id    BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    quantity   BIGINT NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    CONSTRAINT fk_carts_cart_items FOREIGN KEY (cart_id) REFERENCES carts (id),
+    CONSTRAINT fk_products_cart_items FOREIGN KEY (product_id) REFERENCES products (id)
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id               BIGSERIAL PRIMARY KEY,
+    user_id          BIGINT NOT NULL,
+    order_number     TEXT NOT NULL,
+    status           TEXT DEFAULT 'pending',
+    subtotal         BIGINT,
+    tax              BIGINT,
+    shipping_cost    BIGINT,
+    total_amount     BIGINT,
+    payment_method   TEXT,
+    payment_status   TEXT DEFAULT 'unpaid',
+    shipping_address TEXT,
+    tracking_number  TEXT,
+    notes            TEXT,
+    version          BIGINT NOT NULL DEFAULT 1,
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ,
+    deleted_at       TIMESTAMPTZ,
+    CONSTRAINT fk_users_orders FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_order_number ON orders (order_number);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    BIGINT NOT NULL,
+    product_id  BIGINT NOT NULL,
+    quantity    BIGINT NOT NULL,
+    unit_price  BIGINT,
+    total_price BIGINT,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    CONSTRAINT fk_orders_order_items FOREIGN KEY (order_id) REFERENCES orders (id),
+    CONSTRAINT fk_products_order_items FOREIGN KEY (product_id) REFERENCES products (id)
+);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     BIGINT NOT NULL,
+    product_id  BIGINT NOT NULL,
+    rating      BIGINT NOT NULL,
+    comment     TEXT,
+    is_verified BOOLEAN DEFAULT FALSE,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    CONSTRAINT chk_reviews_rating CHECK (rating >= 1 AND rating <= 5),
+    CONSTRAINT fk_users_reviews FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_products_reviews FOREIGN KEY (product_id) REFERENCES products (id)
+);
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     BIGINT NOT NULL,
+    type        TEXT,
+    address     TEXT NOT NULL,
+    city        TEXT NOT NULL,
+    province    TEXT NOT NULL,
+    postal_code TEXT NOT NULL,
+    is_default  BOOLEAN DEFAULT FALSE,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    CONSTRAINT fk_users_addresses FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS shipments (
+    id              BIGSERIAL PRIMARY KEY,
+    order_id        BIGINT NOT NULL,
+    carrier         TEXT NOT NULL,
+    service         TEXT,
+    tracking_number TEXT,
+    shipped_at      TIMESTAMPTZ NOT NULL,
+    delivered_at    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ,
+    CONSTRAINT fk_orders_shipments FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+CREATE INDEX IF NOT EXISTS idx_shipments_order_id ON shipments (order_id);
+
+CREATE TABLE IF NOT EXISTS shipment_items (
+    id            BIGSERIAL PRIMARY KEY,
+    shipment_id   BIGINT NOT NULL,
+    order_item_id BIGINT NOT NULL,
+    quantity      BIGINT NOT NULL,
+    created_at    TIMESTAMPTZ,
+    CONSTRAINT chk_shipment_items_quantity CHECK (quantity > 0),
+    CONSTRAINT fk_shipments_items FOREIGN KEY (shipment_id) REFERENCES shipments (id),
+    CONSTRAINT fk_order_items_shipment_items FOREIGN KEY (order_item_id) REFERENCES order_items (id)
+);
+CREATE INDEX IF NOT EXISTS idx_shipment_items_shipment_id ON shipment_items (shipment_id);
+
+-- Orders already marked shipped or delivered become a single shipment
+-- holding every item, so their status still follows from their shipments
+INSERT INTO shipments (order_id, carrier, service, tracking_number, shipped_at, delivered_at, created_at, updated_at)
+SELECT id, 'unknown', '', COALESCE(tracking_number, ''), COALESCE(updated_at, created_at, NOW()),
+       CASE WHEN status = 'delivered' THEN COALESCE(updated_at, created_at, NOW()) END, NOW(), NOW()
+FROM orders
+WHERE status IN ('shipped', 'delivered') AND deleted_at IS NULL;
+
+INSERT INTO shipment_items (shipment_id, order_item_id, quantity, created_at)
+SELECT s.id, oi.id, oi.quantity, NOW()
+FROM shipments s
+JOIN order_items oi ON oi.order_id = s.order_id;
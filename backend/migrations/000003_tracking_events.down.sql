@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS tracking_events;
+DROP INDEX IF EXISTS idx_shipments_tracking;
+DROP INDEX IF EXISTS idx_shipments_open;
+ALTER TABLE shipments DROP COLUMN IF EXISTS tracked_at;
@@ -0,0 +1,19 @@
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS tracked_at TIMESTAMPTZ;
+
+-- Open shipments are polled least recently tracked first
+CREATE INDEX IF NOT EXISTS idx_shipments_open ON shipments (tracked_at NULLS FIRST, id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_shipments_tracking ON shipments (carrier, tracking_number);
+
+CREATE TABLE IF NOT EXISTS tracking_events (
+    id          BIGSERIAL PRIMARY KEY,
+    shipment_id BIGINT NOT NULL,
+    status      TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    location    TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ,
+    CONSTRAINT fk_shipments_events FOREIGN KEY (shipment_id) REFERENCES shipments (id)
+);
+
+-- Carriers return the whole history on every poll; each event is stored once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tracking_events_unique ON tracking_events (shipment_id, occurred_at, status, description);
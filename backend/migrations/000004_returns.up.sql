@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS returns (
+    id            BIGSERIAL PRIMARY KEY,
+    order_id      BIGINT NOT NULL,
+    user_id       BIGINT NOT NULL,
+    rma_number    TEXT NOT NULL,
+    status        TEXT NOT NULL DEFAULT 'requested',
+    reason        TEXT NOT NULL,
+    admin_note    TEXT,
+    refund_amount BIGINT NOT NULL DEFAULT 0,
+    approved_at   TIMESTAMPTZ,
+    rejected_at   TIMESTAMPTZ,
+    received_at   TIMESTAMPTZ,
+    refunded_at   TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ,
+    CONSTRAINT fk_orders_returns FOREIGN KEY (order_id) REFERENCES orders (id),
+    CONSTRAINT fk_users_returns FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_returns_rma_number ON returns (rma_number);
+CREATE INDEX IF NOT EXISTS idx_returns_order_id ON returns (order_id);
+CREATE INDEX IF NOT EXISTS idx_returns_user_id ON returns (user_id);
+CREATE INDEX IF NOT EXISTS idx_returns_status ON returns (status);
+
+CREATE TABLE IF NOT EXISTS return_items (
+    id            BIGSERIAL PRIMARY KEY,
+    return_id     BIGINT NOT NULL,
+    order_item_id BIGINT NOT NULL,
+    quantity      BIGINT NOT NULL,
+    disposition   TEXT,
+    created_at    TIMESTAMPTZ,
+    CONSTRAINT chk_return_items_quantity CHECK (quantity > 0),
+    CONSTRAINT fk_returns_items FOREIGN KEY (return_id) REFERENCES returns (id),
+    CONSTRAINT fk_order_items_return_items FOREIGN KEY (order_item_id) REFERENCES order_items (id)
+);
+CREATE INDEX IF NOT EXISTS idx_return_items_return_id ON return_items (return_id);
+
+CREATE TABLE IF NOT EXISTS return_photos (
+    id         BIGSERIAL PRIMARY KEY,
+    return_id  BIGINT NOT NULL,
+    url        TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    CONSTRAINT fk_returns_photos FOREIGN KEY (return_id) REFERENCES returns (id)
+);
+CREATE INDEX IF NOT EXISTS idx_return_photos_return_id ON return_photos (return_id);
@@ -0,0 +1,57 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded_amount BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id              BIGSERIAL PRIMARY KEY,
+    order_id        BIGINT NOT NULL,
+    return_id       BIGINT,
+    amount          BIGINT NOT NULL,
+    items_amount    BIGINT NOT NULL DEFAULT 0,
+    shipping_amount BIGINT NOT NULL DEFAULT 0,
+    tax_amount      BIGINT NOT NULL DEFAULT 0,
+    reason          TEXT,
+    method          TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'pending',
+    provider        TEXT,
+    provider_ref    TEXT,
+    bank_reference  TEXT,
+    failure_reason  TEXT,
+    created_by      BIGINT,
+    refunded_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ,
+    CONSTRAINT chk_refunds_amount CHECK (amount > 0),
+    CONSTRAINT fk_orders_refunds FOREIGN KEY (order_id) REFERENCES orders (id),
+    CONSTRAINT fk_returns_refunds FOREIGN KEY (return_id) REFERENCES returns (id),
+    CONSTRAINT fk_users_refunds FOREIGN KEY (created_by) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds (order_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_return_id ON refunds (return_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_status ON refunds (status);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+    id            BIGSERIAL PRIMARY KEY,
+    refund_id     BIGINT NOT NULL,
+    order_item_id BIGINT NOT NULL,
+    quantity      BIGINT NOT NULL,
+    amount        BIGINT NOT NULL,
+    CONSTRAINT chk_refund_items_quantity CHECK (quantity > 0),
+    CONSTRAINT fk_refunds_items FOREIGN KEY (refund_id) REFERENCES refunds (id),
+    CONSTRAINT fk_order_items_refund_items FOREIGN KEY (order_item_id) REFERENCES order_items (id)
+);
+CREATE INDEX IF NOT EXISTS idx_refund_items_refund_id ON refund_items (refund_id);
+
+-- Returns refunded before refunds had their own table were paid back by hand
+INSERT INTO refunds (order_id, return_id, amount, items_amount, reason, method, status, refunded_at, created_at, updated_at)
+SELECT order_id, id, refund_amount, refund_amount, reason, 'manual_bank', 'succeeded', refunded_at, refunded_at, refunded_at
+FROM returns
+WHERE status = 'refunded' AND refund_amount > 0;
+
+INSERT INTO refund_items (refund_id, order_item_id, quantity, amount)
+SELECT refunds.id, return_items.order_item_id, return_items.quantity, return_items.quantity * order_items.unit_price
+FROM refunds
+JOIN return_items ON return_items.return_id = refunds.return_id
+JOIN order_items ON order_items.id = return_items.order_item_id;
+
+UPDATE orders SET refunded_amount = totals.amount
+FROM (SELECT order_id, SUM(amount) AS amount FROM refunds WHERE status = 'succeeded' GROUP BY order_id) AS totals
+WHERE totals.order_id = orders.id;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS invoice_sequences;
+DROP INDEX IF EXISTS idx_orders_invoice_number;
+ALTER TABLE orders DROP COLUMN IF EXISTS invoiced_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS invoice_number;
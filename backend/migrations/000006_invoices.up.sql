@@ -0,0 +1,28 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS invoice_number TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS invoiced_at TIMESTAMPTZ;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_invoice_number ON orders (invoice_number);
+
+-- One counter per year; taking a number updates the year's row, which stays
+-- locked until the transaction that took it ends, so numbers have no gaps
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+    year        INT PRIMARY KEY,
+    last_number BIGINT NOT NULL
+);
+
+-- Orders paid before invoices existed are numbered in the order they were placed
+UPDATE orders SET invoice_number = numbered.invoice_number, invoiced_at = numbered.created_at
+FROM (
+    SELECT id, created_at,
+           'INV-' || EXTRACT(YEAR FROM created_at)::INT || '-' ||
+           LPAD((ROW_NUMBER() OVER (PARTITION BY EXTRACT(YEAR FROM created_at) ORDER BY created_at, id))::TEXT, 6, '0')
+               AS invoice_number
+    FROM orders
+    WHERE payment_status IN ('paid', 'partially_refunded', 'refunded') AND invoice_number IS NULL
+) AS numbered
+WHERE numbered.id = orders.id;
+
+INSERT INTO invoice_sequences (year, last_number)
+SELECT EXTRACT(YEAR FROM invoiced_at)::INT, COUNT(*)
+FROM orders
+WHERE invoice_number IS NOT NULL
+GROUP BY 1;
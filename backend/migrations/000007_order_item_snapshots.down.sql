@@ -0,0 +1,7 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax_percent;
+ALTER TABLE order_items DROP COLUMN IF EXISTS weight;
+ALTER TABLE order_items DROP COLUMN IF EXISTS options;
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_image;
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_sku;
+ALTER TABLE order_items DROP COLUMN IF EXISTS product_name;
@@ -0,0 +1,19 @@
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_sku TEXT;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS product_image TEXT;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS options JSONB;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS weight DECIMAL;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_percent BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_amount BIGINT NOT NULL DEFAULT 0;
+
+-- Existing items can only be frozen as their products are today, deleted
+-- products included; every order so far was taxed at 10%
+UPDATE order_items
+SET product_name = products.name,
+    product_sku = products.sku,
+    product_image = products.image,
+    weight = products.weight,
+    tax_percent = 10,
+    tax_amount = order_items.total_price * 10 / 100
+FROM products
+WHERE products.id = order_items.product_id;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS order_changes (
+    id           BIGSERIAL PRIMARY KEY,
+    order_id     BIGINT NOT NULL,
+    action       TEXT NOT NULL,
+    summary      TEXT,
+    total_before BIGINT NOT NULL DEFAULT 0,
+    total_after  BIGINT NOT NULL DEFAULT 0,
+    created_by   BIGINT,
+    created_at   TIMESTAMPTZ,
+    CONSTRAINT fk_orders_changes FOREIGN KEY (order_id) REFERENCES orders (id),
+    CONSTRAINT fk_users_order_changes FOREIGN KEY (created_by) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_order_changes_order_id ON order_changes (order_id);
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS bulk_jobs (
+    id            BIGSERIAL PRIMARY KEY,
+    action        TEXT NOT NULL,
+    status        TEXT NOT NULL DEFAULT 'queued',
+    carrier       TEXT,
+    service       TEXT,
+    total         BIGINT NOT NULL DEFAULT 0,
+    processed     BIGINT NOT NULL DEFAULT 0,
+    succeeded     BIGINT NOT NULL DEFAULT 0,
+    skipped       BIGINT NOT NULL DEFAULT 0,
+    failed        BIGINT NOT NULL DEFAULT 0,
+    error         TEXT,
+    document_name TEXT,
+    document      BYTEA,
+    created_by    BIGINT,
+    started_at    TIMESTAMPTZ,
+    finished_at   TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ,
+    CONSTRAINT fk_users_bulk_jobs FOREIGN KEY (created_by) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_bulk_jobs_status ON bulk_jobs (status);
+
+CREATE TABLE IF NOT EXISTS bulk_job_results (
+    id           BIGSERIAL PRIMARY KEY,
+    job_id       BIGINT NOT NULL,
+    order_id     BIGINT NOT NULL,
+    order_number TEXT,
+    status       TEXT NOT NULL DEFAULT 'pending',
+    code         TEXT,
+    message      TEXT,
+    shipment_id  BIGINT,
+    processed_at TIMESTAMPTZ,
+    CONSTRAINT fk_bulk_jobs_results FOREIGN KEY (job_id) REFERENCES bulk_jobs (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_bulk_job_results_job_id ON bulk_job_results (job_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_orders_lookup_token_hash;
+ALTER TABLE orders DROP COLUMN IF EXISTS lookup_token_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS is_guest;
@@ -0,0 +1,8 @@
+-- Guests are customers without a password, created when they check out
+-- without an account; registering with their email turns them into one
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_guest BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Guest orders are looked up with a secret emailed to the customer, of which
+-- only the SHA-256 hash is kept
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS lookup_token_hash TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_lookup_token_hash ON orders (lookup_token_hash);
@@ -0,0 +1 @@
+-- The version columns belong to version 1 and are dropped with it
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_claim_token_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS claim_expires_at;
+ALTER TABLE users DROP COLUMN IF EXISTS claim_token_hash;
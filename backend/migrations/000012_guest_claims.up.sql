@@ -0,0 +1,5 @@
+-- Guests turn into accounts through a single-use link emailed to them, which
+-- proves they own the address; only the SHA-256 hash of its token is kept
+ALTER TABLE users ADD COLUMN IF NOT EXISTS claim_token_hash TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS claim_expires_at TIMESTAMPTZ;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_claim_token_hash ON users (claim_token_hash);
@@ -0,0 +1,3 @@
+-- Users are looked up by email ignoring case, so the same address typed in
+-- another case finds the same customer
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
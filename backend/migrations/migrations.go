@@ -0,0 +1,12 @@
+// Package migrations holds the versioned SQL migrations for the database
+// schema. Each version is a pair of files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql; they are embedded
+// into the binary and applied by database.Migrator.
+package migrations
+
+import "embed"
+
+// FS contains every migration file
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// BulkJob is an action an admin asked to run on many orders at once. It runs
+// in the background one order at a time, keeping count of its progress and a
+// result for each order.
+type BulkJob struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Action       string     `json:"action" gorm:"not null"`                      // process, ship, packing_slips, cancel
+	Status       string     `json:"status" gorm:"not null;default:queued;index"` // queued, running, completed, failed
+	Carrier      string     `json:"carrier,omitempty"`
+	Service      string     `json:"service,omitempty"`
+	Total        int        `json:"total" gorm:"not null;default:0"`
+	Processed    int        `json:"processed" gorm:"not null;default:0"`
+	Succeeded    int        `json:"succeeded" gorm:"not null;default:0"`
+	Skipped      int        `json:"skipped" gorm:"not null;default:0"`
+	Failed       int        `json:"failed" gorm:"not null;default:0"`
+	Error        string     `json:"error,omitempty"`         // why the job as a whole failed
+	DocumentName string     `json:"document_name,omitempty"` // set once the job has produced a document
+	Document     []byte     `json:"-"`
+	CreatedBy    *uint      `json:"created_by"`
+	StartedAt    *time.Time `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Relationships
+	Results []BulkJobResult `json:"results,omitempty" gorm:"foreignKey:JobID"`
+}
+
+// BulkJobResult is what a bulk job did to one order
+type BulkJobResult struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	JobID       uint       `json:"job_id" gorm:"not null;index"`
+	OrderID     uint       `json:"order_id" gorm:"not null"`
+	OrderNumber string     `json:"order_number"`
+	Status      string     `json:"status" gorm:"not null;default:pending"` // pending, succeeded, skipped, failed
+	Code        string     `json:"code,omitempty"`
+	Message     string     `json:"message,omitempty"`
+	ShipmentID  *uint      `json:"shipment_id,omitempty"`
+	ProcessedAt *time.Time `json:"processed_at"`
+}
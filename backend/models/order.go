@@ -10,36 +10,81 @@ type Order struct {
 	ID              uint           `json:"id" gorm:"primaryKey"`
 	UserID          uint           `json:"user_id" gorm:"not null"`
 	OrderNumber     string         `json:"order_number" gorm:"uniqueIndex;not null"`
-	Status          string         `json:"status" gorm:"default:pending"` // pending, processing, shipped, delivered, cancelled
+	Status          string         `json:"status" gorm:"default:pending"` // draft, pending, processing, partially_shipped, shipped, delivered, cancelled
 	Subtotal        int64          `json:"subtotal"`
 	Tax             int64          `json:"tax"`
 	ShippingCost    int64          `json:"shipping_cost"`
 	TotalAmount     int64          `json:"total_amount"`
+	RefundedAmount  int64          `json:"refunded_amount" gorm:"not null;default:0"`
+	NetPaid         int64          `json:"net_paid" gorm:"-"`
 	PaymentMethod   string         `json:"payment_method"`
-	PaymentStatus   string         `json:"payment_status" gorm:"default:unpaid"` // unpaid, paid, failed, refunded
+	PaymentStatus   string         `json:"payment_status" gorm:"default:unpaid"` // unpaid, paid, failed, partially_refunded, refunded
+	InvoiceNumber   *string        `json:"invoice_number" gorm:"uniqueIndex"`    // issued when the order is first paid
+	InvoicedAt      *time.Time     `json:"invoiced_at"`
 	ShippingAddress string         `json:"shipping_address"`
 	TrackingNumber  string         `json:"tracking_number"`
 	Notes           string         `json:"notes"`
+	LookupTokenHash *string        `json:"-" gorm:"uniqueIndex"` // SHA-256 of the secret a guest looks the order up with
+	Version         uint           `json:"version" gorm:"not null;default:1"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	User       User        `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	OrderItems []OrderItem `json:"order_items,omitempty" gorm:"foreignKey:OrderID"`
+	User       User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	OrderItems []OrderItem   `json:"order_items,omitempty" gorm:"foreignKey:OrderID"`
+	Shipments  []Shipment    `json:"shipments,omitempty" gorm:"foreignKey:OrderID"`
+	Returns    []Return      `json:"returns,omitempty" gorm:"foreignKey:OrderID"`
+	Refunds    []Refund      `json:"refunds,omitempty" gorm:"foreignKey:OrderID"`
+	Changes    []OrderChange `json:"changes,omitempty" gorm:"foreignKey:OrderID"`
 }
 
+// AfterFind fills in NetPaid: what the customer paid less what was refunded,
+// or nothing while the order is unpaid
+func (o *Order) AfterFind(tx *gorm.DB) error {
+	switch o.PaymentStatus {
+	case "paid", "partially_refunded", "refunded":
+		o.NetPaid = o.TotalAmount - o.RefundedAmount
+	default:
+		o.NetPaid = 0
+	}
+	return nil
+}
+
+// OrderItem is a line of an order. The product's details are copied onto it
+// when the order is placed, so the order reads the same however the product
+// is changed or deleted later.
 type OrderItem struct {
-	ID         uint      `json:"id" gorm:"primaryKey"`
-	OrderID    uint      `json:"order_id" gorm:"not null"`
-	ProductID  uint      `json:"product_id" gorm:"not null"`
-	Quantity   int       `json:"quantity" gorm:"not null"`
-	UnitPrice  int64     `json:"unit_price"`
-	TotalPrice int64     `json:"total_price"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID           uint              `json:"id" gorm:"primaryKey"`
+	OrderID      uint              `json:"order_id" gorm:"not null"`
+	ProductID    uint              `json:"product_id" gorm:"not null"`
+	ProductName  string            `json:"product_name" gorm:"not null;default:''"`
+	ProductSKU   string            `json:"product_sku"`
+	ProductImage string            `json:"product_image"`
+	Options      map[string]string `json:"options,omitempty" gorm:"serializer:json;type:jsonb"` // variant options chosen, e.g. size
+	Weight       float64           `json:"weight"`
+	Quantity     int               `json:"quantity" gorm:"not null"`
+	UnitPrice    int64             `json:"unit_price"`
+	TotalPrice   int64             `json:"total_price"`
+	TaxPercent   int64             `json:"tax_percent" gorm:"not null;default:0"`
+	TaxAmount    int64             `json:"tax_amount" gorm:"not null;default:0"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
 
 	// Relationships
-	Order   Order   `json:"order,omitempty" gorm:"foreignKey:OrderID"`
-	Product Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Order   Order    `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Product *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+}
+
+// OrderChange records an admin creating an order as a draft, editing it or
+// placing it, with what the order's total was before and after
+type OrderChange struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	OrderID     uint      `json:"order_id" gorm:"not null;index"`
+	Action      string    `json:"action" gorm:"not null"` // created, edited, placed
+	Summary     string    `json:"summary"`
+	TotalBefore int64     `json:"total_before"`
+	TotalAfter  int64     `json:"total_after"`
+	CreatedBy   *uint     `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
 }
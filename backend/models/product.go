@@ -8,7 +8,7 @@ import (
 
 type Category struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
-	Name        string         `json:"name" gorm:"uniqueIndex;not null"`
+	Name        string         `json:"name" gorm:"uniqueIndex;not null" validate:"required"`
 	Description string         `json:"description"`
 	Image       string         `json:"image"`
 	IsActive    bool           `json:"is_active" gorm:"default:true"`
@@ -22,26 +22,27 @@ type Category struct {
 
 type Product struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
-	Name        string         `json:"name" gorm:"not null"`
+	Name        string         `json:"name" gorm:"not null" validate:"required"`
 	Description string         `json:"description"`
-	Price       int64          `json:"price" gorm:"not null"`
-	Stock       int            `json:"stock" gorm:"default:0"`
-	CategoryID  uint           `json:"category_id" gorm:"not null"`
+	Price       int64          `json:"price" gorm:"not null" validate:"gt=0"`
+	Stock       int            `json:"stock" gorm:"default:0" validate:"gte=0"`
+	CategoryID  uint           `json:"category_id" gorm:"not null" validate:"required"`
 	Image       string         `json:"image"`
 	Images      []string       `json:"images" gorm:"type:text[]"`
 	SKU         string         `json:"sku" gorm:"uniqueIndex"`
 	Weight      float64        `json:"weight"`
 	Dimensions  string         `json:"dimensions"`
 	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	Version     uint           `json:"version" gorm:"not null;default:1"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	Category    Category     `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
-	CartItems   []CartItem   `json:"cart_items,omitempty" gorm:"foreignKey:ProductID"`
-	OrderItems  []OrderItem  `json:"order_items,omitempty" gorm:"foreignKey:ProductID"`
-	Reviews     []Review     `json:"reviews,omitempty" gorm:"foreignKey:ProductID"`
+	Category   Category    `json:"category,omitempty" gorm:"foreignKey:CategoryID" validate:"-"`
+	CartItems  []CartItem  `json:"cart_items,omitempty" gorm:"foreignKey:ProductID"`
+	OrderItems []OrderItem `json:"order_items,omitempty" gorm:"foreignKey:ProductID"`
+	Reviews    []Review    `json:"reviews,omitempty" gorm:"foreignKey:ProductID"`
 }
 
 type Review struct {
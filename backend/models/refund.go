@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Refund pays back part or all of an order, either through the payment
+// provider or as a manual bank transfer. Amount is split into what the items,
+// shipping and tax each contributed.
+type Refund struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	OrderID        uint       `json:"order_id" gorm:"not null;index"`
+	ReturnID       *uint      `json:"return_id" gorm:"index"`
+	Amount         int64      `json:"amount" gorm:"not null"`
+	ItemsAmount    int64      `json:"items_amount" gorm:"not null"`
+	ShippingAmount int64      `json:"shipping_amount" gorm:"not null"`
+	TaxAmount      int64      `json:"tax_amount" gorm:"not null"`
+	Reason         string     `json:"reason"`
+	Method         string     `json:"method" gorm:"not null"`                 // provider, manual_bank
+	Status         string     `json:"status" gorm:"not null;default:pending"` // pending, succeeded, failed
+	Provider       string     `json:"provider"`
+	ProviderRef    string     `json:"provider_ref"`
+	BankReference  string     `json:"bank_reference"`
+	FailureReason  string     `json:"failure_reason"`
+	CreatedBy      *uint      `json:"created_by"`
+	RefundedAt     *time.Time `json:"refunded_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relationships
+	Items []RefundItem `json:"items,omitempty" gorm:"foreignKey:RefundID"`
+}
+
+// RefundItem is the quantity of an order item a refund pays back, and what
+// it was bought for
+type RefundItem struct {
+	ID          uint  `json:"id" gorm:"primaryKey"`
+	RefundID    uint  `json:"refund_id" gorm:"not null;index"`
+	OrderItemID uint  `json:"order_item_id" gorm:"not null"`
+	Quantity    int   `json:"quantity" gorm:"not null"`
+	Amount      int64 `json:"amount" gorm:"not null"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Return is a customer's request to send back some of a delivered order's
+// items, identified to both sides by its RMA number
+type Return struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	OrderID      uint       `json:"order_id" gorm:"not null;index"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	RMANumber    string     `json:"rma_number" gorm:"uniqueIndex;not null"`
+	Status       string     `json:"status" gorm:"not null;default:requested"` // requested, approved, rejected, received, refunded
+	Reason       string     `json:"reason" gorm:"not null"`
+	AdminNote    string     `json:"admin_note"`
+	RefundAmount int64      `json:"refund_amount"`
+	ApprovedAt   *time.Time `json:"approved_at"`
+	RejectedAt   *time.Time `json:"rejected_at"`
+	ReceivedAt   *time.Time `json:"received_at"`
+	RefundedAt   *time.Time `json:"refunded_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Relationships
+	Items  []ReturnItem  `json:"items,omitempty" gorm:"foreignKey:ReturnID"`
+	Photos []ReturnPhoto `json:"photos,omitempty" gorm:"foreignKey:ReturnID"`
+}
+
+// ReturnItem is the quantity of an order item being returned. Disposition
+// says what happened to the goods once received: restock or write_off.
+type ReturnItem struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ReturnID    uint      `json:"return_id" gorm:"not null;index"`
+	OrderItemID uint      `json:"order_item_id" gorm:"not null"`
+	Quantity    int       `json:"quantity" gorm:"not null"`
+	Disposition string    `json:"disposition"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ReturnPhoto is a picture of the goods the customer attached to a return
+type ReturnPhoto struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ReturnID  uint      `json:"return_id" gorm:"not null;index"`
+	URL       string    `json:"url" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
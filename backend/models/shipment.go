@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Shipment is one parcel handed to a carrier. It carries some quantity of
+// one or more of the order's items; an order is fully shipped once its
+// shipments cover every item.
+type Shipment struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	OrderID        uint       `json:"order_id" gorm:"not null;index"`
+	Carrier        string     `json:"carrier" gorm:"not null"`
+	Service        string     `json:"service"`
+	TrackingNumber string     `json:"tracking_number"`
+	ShippedAt      time.Time  `json:"shipped_at" gorm:"not null"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	// TrackedAt is when the carrier was last asked about the shipment
+	TrackedAt *time.Time `json:"tracked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	// Relationships
+	Items  []ShipmentItem  `json:"items,omitempty" gorm:"foreignKey:ShipmentID"`
+	Events []TrackingEvent `json:"events,omitempty" gorm:"foreignKey:ShipmentID"`
+}
+
+// ShipmentItem is the quantity of an order item packed in a shipment
+type ShipmentItem struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ShipmentID  uint      `json:"shipment_id" gorm:"not null;index"`
+	OrderItemID uint      `json:"order_item_id" gorm:"not null"`
+	Quantity    int       `json:"quantity" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TrackingEvent is one scan or status change of a shipment reported by its
+// carrier. Status is one of the normalised statuses of package carriers.
+type TrackingEvent struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ShipmentID  uint      `json:"shipment_id" gorm:"not null;index"`
+	Status      string    `json:"status" gorm:"not null"`
+	Description string    `json:"description" gorm:"not null;default:''"`
+	Location    string    `json:"location" gorm:"not null;default:''"`
+	OccurredAt  time.Time `json:"occurred_at" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+}
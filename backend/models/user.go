@@ -7,17 +7,21 @@ import (
 )
 
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	FirstName string         `json:"first_name" gorm:"not null"`
-	LastName  string         `json:"last_name" gorm:"not null"`
-	Email     string         `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"not null"`
-	Phone     string         `json:"phone"`
-	Role      string         `json:"role" gorm:"default:user"`
-	IsActive  bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	FirstName      string         `json:"first_name" gorm:"not null"`
+	LastName       string         `json:"last_name" gorm:"not null"`
+	Email          string         `json:"email" gorm:"uniqueIndex;not null"`
+	Password       string         `json:"-" gorm:"not null"`
+	Phone          string         `json:"phone"`
+	Role           string         `json:"role" gorm:"default:user"`
+	IsActive       bool           `json:"is_active" gorm:"default:true"`
+	IsGuest        bool           `json:"is_guest" gorm:"not null;default:false"` // checked out without an account; cannot sign in
+	ClaimTokenHash *string        `json:"-" gorm:"uniqueIndex"`                   // hash of the link a guest was emailed to register with
+	ClaimExpiresAt *time.Time     `json:"-"`                                      // when that link stops working
+	Version        uint           `json:"version" gorm:"not null;default:1"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
 	Addresses []Address `json:"addresses,omitempty" gorm:"foreignKey:UserID"`
@@ -27,16 +31,16 @@ type User struct {
 }
 
 type Address struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	UserID    uint      `json:"user_id" gorm:"not null"`
-	Type      string    `json:"type"` // home, work, other
-	Address   string    `json:"address" gorm:"not null"`
-	City      string    `json:"city" gorm:"not null"`
-	Province  string    `json:"province" gorm:"not null"`
-	PostalCode string   `json:"postal_code" gorm:"not null"`
-	IsDefault bool      `json:"is_default" gorm:"default:false"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"not null"`
+	Type       string    `json:"type" validate:"omitempty,oneof=home work other"`
+	Address    string    `json:"address" gorm:"not null" validate:"required"`
+	City       string    `json:"city" gorm:"not null" validate:"required"`
+	Province   string    `json:"province" gorm:"not null" validate:"required"`
+	PostalCode string    `json:"postal_code" gorm:"not null" validate:"required"`
+	IsDefault  bool      `json:"is_default" gorm:"default:false"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
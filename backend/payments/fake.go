@@ -0,0 +1,39 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Fake is an in-memory provider for tests. It accepts every refund unless
+// Err is set, and remembers what it was asked to pay.
+type Fake struct {
+	mu      sync.Mutex
+	refunds []RefundRequest
+	// Err, when set, is returned by every Refund call
+	Err error
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Code() string { return "fake" }
+
+func (f *Fake) Refund(ctx context.Context, r RefundRequest) (RefundResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return RefundResult{}, f.Err
+	}
+	f.refunds = append(f.refunds, r)
+	return RefundResult{Reference: fmt.Sprintf("fake-%d", len(f.refunds))}, nil
+}
+
+// Refunds returns the refunds accepted so far
+func (f *Fake) Refunds() []RefundRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]RefundRequest(nil), f.refunds...)
+}
@@ -0,0 +1,77 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ecommerce-backend/config"
+)
+
+// Midtrans refunds transactions through the Midtrans Core API
+type Midtrans struct {
+	cfg    config.MidtransConfig
+	client *http.Client
+}
+
+func NewMidtrans(cfg config.MidtransConfig, client *http.Client) *Midtrans {
+	return &Midtrans{cfg: cfg, client: client}
+}
+
+func (m *Midtrans) Code() string { return "midtrans" }
+
+type midtransRefund struct {
+	RefundKey string `json:"refund_key"`
+	Amount    int64  `json:"amount"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// midtransResponse carries the outcome in status_code even when the HTTP
+// status is 200
+type midtransResponse struct {
+	StatusCode         string      `json:"status_code"`
+	StatusMessage      string      `json:"status_message"`
+	RefundChargebackID json.Number `json:"refund_chargeback_id"`
+}
+
+func (m *Midtrans) Refund(ctx context.Context, r RefundRequest) (RefundResult, error) {
+	body, err := json.Marshal(midtransRefund{RefundKey: r.Key, Amount: r.Amount, Reason: r.Reason})
+	if err != nil {
+		return RefundResult{}, err
+	}
+	endpoint := strings.TrimRight(m.cfg.BaseURL, "/") + "/v2/" + url.PathEscape(r.OrderNumber) + "/refund"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return RefundResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(m.cfg.ServerKey, "")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return RefundResult{}, fmt.Errorf("midtrans: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return RefundResult{}, fmt.Errorf("midtrans: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return RefundResult{}, fmt.Errorf("midtrans: HTTP %d", resp.StatusCode)
+	}
+
+	var res midtransResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return RefundResult{}, fmt.Errorf("midtrans: decode response: %w", err)
+	}
+	if res.StatusCode != "200" {
+		return RefundResult{}, fmt.Errorf("midtrans: %s %s", res.StatusCode, res.StatusMessage)
+	}
+	return RefundResult{Reference: res.RefundChargebackID.String()}, nil
+}
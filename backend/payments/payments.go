@@ -0,0 +1,45 @@
+// Package payments sends refunds to the payment provider that took the
+// order's payment. The provider finds the original transaction by the order
+// number.
+package payments
+
+import (
+	"context"
+	"net/http"
+
+	"ecommerce-backend/config"
+)
+
+// RefundRequest asks the provider to pay back part or all of an order
+type RefundRequest struct {
+	OrderNumber string
+	// Key identifies the refund to the provider, so a retried request is
+	// not paid out twice
+	Key    string
+	Amount int64
+	Reason string
+}
+
+// RefundResult is the provider's record of a refund it accepted
+type RefundResult struct {
+	// Reference is the provider's ID for the refund
+	Reference string
+}
+
+// Provider executes refunds with a payment provider
+type Provider interface {
+	// Code names the provider on stored refunds, e.g. "midtrans"
+	Code() string
+	// Refund pays back r.Amount of the order's payment, failing when the
+	// provider declines
+	Refund(ctx context.Context, r RefundRequest) (RefundResult, error)
+}
+
+// New returns the provider whose credentials are configured, or nil when
+// there is none
+func New(cfg config.PaymentsConfig) Provider {
+	if cfg.Midtrans.ServerKey == "" {
+		return nil
+	}
+	return NewMidtrans(cfg.Midtrans, &http.Client{Timeout: cfg.Timeout})
+}
@@ -0,0 +1,82 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ecommerce-backend/config"
+)
+
+func serve(t *testing.T, body string, check func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMidtransRefund(t *testing.T) {
+	srv := serve(t, `{
+		"status_code": "200",
+		"status_message": "Success, refund request is approved",
+		"order_id": "ORD-20240309-abcd1234",
+		"refund_chargeback_id": 4671,
+		"refund_amount": "85000.00",
+		"refund_key": "REF-7"
+	}`, func(r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v2/ORD-20240309-abcd1234/refund" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if user, _, ok := r.BasicAuth(); !ok || user != "SB-Mid-server-rahasia" {
+			t.Errorf("basic auth user = %q", user)
+		}
+		var body midtransRefund
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.RefundKey != "REF-7" || body.Amount != 85000 || body.Reason != "Barang rusak" {
+			t.Errorf("body = %+v", body)
+		}
+	})
+	m := NewMidtrans(config.MidtransConfig{BaseURL: srv.URL, ServerKey: "SB-Mid-server-rahasia"}, srv.Client())
+
+	res, err := m.Refund(context.Background(), RefundRequest{
+		OrderNumber: "ORD-20240309-abcd1234",
+		Key:         "REF-7",
+		Amount:      85000,
+		Reason:      "Barang rusak",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Reference != "4671" {
+		t.Errorf("reference = %q, want the chargeback ID", res.Reference)
+	}
+}
+
+func TestMidtransDeclined(t *testing.T) {
+	srv := serve(t, `{"status_code": "412", "status_message": "Transaction status cannot be updated"}`, nil)
+	m := NewMidtrans(config.MidtransConfig{BaseURL: srv.URL, ServerKey: "x"}, srv.Client())
+	if _, err := m.Refund(context.Background(), RefundRequest{OrderNumber: "ORD-1", Key: "REF-1", Amount: 1}); err == nil {
+		t.Error("a declined refund succeeded")
+	}
+}
+
+func TestNewNeedsCredentials(t *testing.T) {
+	cfg := config.Default().Payments
+	if New(cfg) != nil {
+		t.Error("built a provider without credentials")
+	}
+	cfg.Midtrans.ServerKey = "SB-Mid-server-rahasia"
+	if p := New(cfg); p == nil || p.Code() != "midtrans" {
+		t.Errorf("New() = %v, want midtrans", p)
+	}
+}
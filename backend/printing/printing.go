@@ -0,0 +1,372 @@
+// Package printing lays out the PDFs printed from orders: invoices for
+// customers and finance, and packing slips for the warehouse. Each order
+// starts on a new page, so any number of them can be printed as one file.
+package printing
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// A4 portrait, in millimetres
+const (
+	margin = 15.0
+	width  = 180.0 // between the margins
+	line   = 5.0
+)
+
+const dateLayout = "2 Jan 2006"
+
+// Invoices writes the invoices of orders to w. Each order needs its invoice
+// number, customer and items loaded.
+func Invoices(w io.Writer, store config.StoreConfig, orders []models.Order) error {
+	d := newDocument(store, "Invoice")
+	for _, order := range orders {
+		d.invoice(order)
+	}
+	return d.output(w)
+}
+
+// PackingSlips writes the packing slips of orders to w. Each order needs its
+// customer and items loaded.
+func PackingSlips(w io.Writer, store config.StoreConfig, orders []models.Order) error {
+	d := newDocument(store, "Packing slip")
+	for _, order := range orders {
+		d.packingSlip(order)
+	}
+	return d.output(w)
+}
+
+type document struct {
+	pdf   *fpdf.Fpdf
+	store config.StoreConfig
+	// tr re-encodes UTF-8 text for the PDF's built-in fonts
+	tr func(string) string
+}
+
+func newDocument(store config.StoreConfig, title string) *document {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+	pdf.SetTitle(title, true)
+	pdf.SetAuthor(store.Name, true)
+	pdf.SetCreator(store.Name, true)
+	return &document{pdf: pdf, store: store, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+}
+
+func (d *document) output(w io.Writer) error {
+	if d.pdf.PageNo() == 0 {
+		d.pdf.AddPage()
+	}
+	return d.pdf.Output(w)
+}
+
+func (d *document) invoice(order models.Order) {
+	d.pdf.AddPage()
+	d.letterhead("INVOICE")
+	d.details(
+		[2]string{"Invoice No.", deref(order.InvoiceNumber)},
+		[2]string{"Invoice Date", formatDate(order.InvoicedAt)},
+		[2]string{"Order No.", order.OrderNumber},
+		[2]string{"Order Date", order.CreatedAt.Format(dateLayout)},
+		[2]string{"Payment", label(order.PaymentMethod) + " (" + label(order.PaymentStatus) + ")"},
+	)
+	d.parties(
+		"Bill To", []string{customerName(order.User), order.User.Email, order.User.Phone},
+		"Ship To", []string{order.ShippingAddress},
+	)
+
+	columns := []column{
+		{title: "#", width: 10, align: "C"},
+		{title: "Item", width: 86, align: "L"},
+		{title: "Qty", width: 16, align: "R"},
+		{title: "Unit Price", width: 34, align: "R"},
+		{title: "Amount", width: 34, align: "R"},
+	}
+	d.tableHeader(columns)
+	for i, item := range order.OrderItems {
+		d.tableRow(columns, strconv.Itoa(i+1), itemName(item), strconv.Itoa(item.Quantity),
+			money(item.UnitPrice), money(item.TotalPrice))
+	}
+
+	d.pdf.Ln(2)
+	d.total("Subtotal", order.Subtotal, false)
+	d.total("Shipping", order.ShippingCost, false)
+	d.total("Tax", order.Tax, false)
+	d.total("Total", order.TotalAmount, true)
+	if order.RefundedAmount > 0 {
+		d.total("Refunded", -order.RefundedAmount, false)
+		d.total("Net Paid", order.TotalAmount-order.RefundedAmount, true)
+	}
+
+	d.pdf.Ln(10)
+	d.pdf.SetFont("Helvetica", "I", 8)
+	d.pdf.SetTextColor(100, 100, 100)
+	d.pdf.MultiCell(width, 4, d.tr("This invoice was issued electronically and is valid without a signature."), "", "L", false)
+	d.pdf.SetTextColor(0, 0, 0)
+}
+
+func (d *document) packingSlip(order models.Order) {
+	d.pdf.AddPage()
+	d.letterhead("PACKING SLIP")
+	quantity := 0
+	for _, item := range order.OrderItems {
+		quantity += item.Quantity
+	}
+	d.details(
+		[2]string{"Order No.", order.OrderNumber},
+		[2]string{"Order Date", order.CreatedAt.Format(dateLayout)},
+		[2]string{"Items", strconv.Itoa(quantity)},
+	)
+	d.parties(
+		"Ship To", []string{customerName(order.User), order.User.Phone, order.ShippingAddress},
+		"", nil,
+	)
+
+	columns := []column{
+		{width: 10, box: true},
+		{title: "Item", width: 100, align: "L"},
+		{title: "SKU", width: 45, align: "L"},
+		{title: "Qty", width: 25, align: "R"},
+	}
+	d.tableHeader(columns)
+	for _, item := range order.OrderItems {
+		d.tableRow(columns, "", itemName(item), item.ProductSKU, strconv.Itoa(item.Quantity))
+	}
+
+	if order.Notes != "" {
+		d.pdf.Ln(6)
+		d.pdf.SetFont("Helvetica", "B", 9)
+		d.pdf.CellFormat(width, line, "Notes", "", 1, "L", false, 0, "")
+		d.pdf.SetFont("Helvetica", "", 9)
+		d.pdf.MultiCell(width, line, d.tr(order.Notes), "", "L", false)
+	}
+
+	d.pdf.Ln(14)
+	d.pdf.SetFont("Helvetica", "", 9)
+	for _, who := range []string{"Packed by", "Checked by"} {
+		d.pdf.CellFormat(20, line, who, "", 0, "L", false, 0, "")
+		d.pdf.CellFormat(60, line, "", "B", 0, "L", false, 0, "")
+		d.pdf.CellFormat(10, line, "", "", 0, "L", false, 0, "")
+	}
+	d.pdf.Ln(line)
+}
+
+// letterhead prints the store's details on the left and the document's
+// title on the right
+func (d *document) letterhead(title string) {
+	top := d.pdf.GetY()
+	d.pdf.SetFont("Helvetica", "B", 20)
+	d.pdf.CellFormat(width, 10, title, "", 0, "R", false, 0, "")
+
+	d.pdf.SetXY(margin, top)
+	d.pdf.SetFont("Helvetica", "B", 14)
+	d.pdf.CellFormat(110, 8, d.tr(d.store.Name), "", 1, "L", false, 0, "")
+	d.pdf.SetFont("Helvetica", "", 9)
+	if d.store.Address != "" {
+		d.pdf.MultiCell(110, 4.5, d.tr(d.store.Address), "", "L", false)
+	}
+	if contact := join(" | ", d.store.Email, d.store.Phone); contact != "" {
+		d.pdf.CellFormat(110, 4.5, d.tr(contact), "", 1, "L", false, 0, "")
+	}
+	if d.store.TaxID != "" {
+		d.pdf.CellFormat(110, 4.5, d.tr("NPWP: "+d.store.TaxID), "", 1, "L", false, 0, "")
+	}
+
+	d.pdf.Ln(3)
+	y := d.pdf.GetY()
+	d.pdf.SetDrawColor(180, 180, 180)
+	d.pdf.Line(margin, y, margin+width, y)
+	d.pdf.SetDrawColor(0, 0, 0)
+	d.pdf.Ln(5)
+}
+
+// details prints label and value pairs one per line
+func (d *document) details(pairs ...[2]string) {
+	for _, pair := range pairs {
+		d.pdf.SetFont("Helvetica", "B", 9)
+		d.pdf.CellFormat(30, line, d.tr(pair[0]), "", 0, "L", false, 0, "")
+		d.pdf.SetFont("Helvetica", "", 9)
+		d.pdf.CellFormat(width-30, line, d.tr(pair[1]), "", 1, "L", false, 0, "")
+	}
+	d.pdf.Ln(4)
+}
+
+// parties prints two blocks of address lines side by side. Empty lines are
+// skipped.
+func (d *document) parties(leftTitle string, left []string, rightTitle string, right []string) {
+	const column = width / 2
+	top := d.pdf.GetY()
+	bottom := top
+	for i, block := range []struct {
+		title string
+		lines []string
+	}{{leftTitle, left}, {rightTitle, right}} {
+		if block.title == "" {
+			continue
+		}
+		d.pdf.SetXY(margin+float64(i)*column, top)
+		d.pdf.SetFont("Helvetica", "B", 9)
+		d.pdf.CellFormat(column, line, d.tr(block.title), "", 2, "L", false, 0, "")
+		d.pdf.SetFont("Helvetica", "", 9)
+		for _, text := range block.lines {
+			if text = strings.TrimSpace(text); text != "" {
+				d.pdf.MultiCell(column-5, 4.5, d.tr(text), "", "L", false)
+				d.pdf.SetX(margin + float64(i)*column)
+			}
+		}
+		bottom = max(bottom, d.pdf.GetY())
+	}
+	d.pdf.SetXY(margin, bottom)
+	d.pdf.Ln(6)
+}
+
+type column struct {
+	title string
+	width float64
+	align string
+	// box draws a check box for ticking the row off instead of text
+	box bool
+}
+
+func (d *document) tableHeader(columns []column) {
+	d.pdf.SetFont("Helvetica", "B", 9)
+	d.pdf.SetFillColor(235, 235, 235)
+	for _, c := range columns {
+		d.pdf.CellFormat(c.width, 7, d.tr(c.title), "TB", 0, c.align, true, 0, "")
+	}
+	d.pdf.Ln(7)
+}
+
+// tableRow prints one row, wrapping long cells. A row that would not fit on
+// the page moves to the next one under a repeated header.
+func (d *document) tableRow(columns []column, cells ...string) {
+	d.pdf.SetFont("Helvetica", "", 9)
+	wrapped := make([][][]byte, len(columns))
+	rows := 1
+	for i, c := range columns {
+		if c.box {
+			continue
+		}
+		// Split the encoded bytes: the built-in fonts are not UTF-8
+		wrapped[i] = d.pdf.SplitLines([]byte(d.tr(cells[i])), c.width-2)
+		rows = max(rows, len(wrapped[i]))
+	}
+	height := float64(rows)*line + 2
+
+	_, pageHeight := d.pdf.GetPageSize()
+	if d.pdf.GetY()+height > pageHeight-margin {
+		d.pdf.AddPage()
+		d.tableHeader(columns)
+		d.pdf.SetFont("Helvetica", "", 9)
+	}
+
+	top := d.pdf.GetY()
+	x := margin
+	for i, c := range columns {
+		if c.box {
+			d.pdf.Rect(x+(c.width-4)/2, top+1.5, 4, 4, "D")
+		}
+		for j, text := range wrapped[i] {
+			d.pdf.SetXY(x, top+1+float64(j)*line)
+			d.pdf.CellFormat(c.width, line, string(text), "", 0, c.align, false, 0, "")
+		}
+		x += c.width
+	}
+	d.pdf.SetDrawColor(200, 200, 200)
+	d.pdf.Line(margin, top+height, margin+width, top+height)
+	d.pdf.SetDrawColor(0, 0, 0)
+	d.pdf.SetXY(margin, top+height)
+}
+
+// total prints one line of the totals, right-aligned under the table
+func (d *document) total(title string, amount int64, bold bool) {
+	style, border := "", ""
+	if bold {
+		style, border = "B", "T"
+	}
+	d.pdf.SetFont("Helvetica", style, 9)
+	d.pdf.SetX(margin + width - 80)
+	d.pdf.CellFormat(46, 6, d.tr(title), border, 0, "L", false, 0, "")
+	d.pdf.CellFormat(34, 6, d.tr(money(amount)), border, 1, "R", false, 0, "")
+}
+
+func customerName(u models.User) string {
+	return join(" ", u.FirstName, u.LastName)
+}
+
+// itemName is the name the item was ordered under, with its variant options
+func itemName(item models.OrderItem) string {
+	name := item.ProductName
+	if name == "" {
+		name = "Product #" + strconv.FormatUint(uint64(item.ProductID), 10)
+	}
+	if len(item.Options) == 0 {
+		return name
+	}
+	options := make([]string, 0, len(item.Options))
+	for option, value := range item.Options {
+		options = append(options, option+": "+value)
+	}
+	sort.Strings(options)
+	return name + " (" + strings.Join(options, ", ") + ")"
+}
+
+// money formats rupiah with dots between thousands, e.g. Rp 1.250.000
+func money(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(digit)
+	}
+	return sign + "Rp " + b.String()
+}
+
+// label turns a stored value such as bank_transfer into Bank transfer
+func label(value string) string {
+	if value == "" {
+		return "-"
+	}
+	value = strings.ReplaceAll(value, "_", " ")
+	return strings.ToUpper(value[:1]) + value[1:]
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(dateLayout)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "-"
+	}
+	return *s
+}
+
+// join joins the non-empty parts with sep
+func join(sep string, parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
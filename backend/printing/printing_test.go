@@ -0,0 +1,117 @@
+package printing
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+)
+
+func TestMoney(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{0, "Rp 0"},
+		{500, "Rp 500"},
+		{85000, "Rp 85.000"},
+		{1250000, "Rp 1.250.000"},
+		{-98500, "-Rp 98.500"},
+	}
+	for _, tt := range tests {
+		if got := money(tt.amount); got != tt.want {
+			t.Errorf("money(%d) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func testOrder(number string, items int) models.Order {
+	invoice := "INV-2024-000001"
+	paid := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+	order := models.Order{
+		OrderNumber:     number,
+		InvoiceNumber:   &invoice,
+		InvoicedAt:      &paid,
+		PaymentMethod:   "bank_transfer",
+		PaymentStatus:   "partially_refunded",
+		ShippingAddress: "Jl. Merdeka No. 10, Bandung 40111",
+		Notes:           "Tolong dibungkus rapi",
+		RefundedAmount:  98500,
+		CreatedAt:       paid,
+		User:            models.User{FirstName: "Sari", LastName: "Wulandari", Email: "sari@example.com"},
+	}
+	for i := 0; i < items; i++ {
+		order.OrderItems = append(order.OrderItems, models.OrderItem{
+			ProductID: uint(i + 1), Quantity: 2, UnitPrice: 85000, TotalPrice: 170000,
+			ProductName: fmt.Sprintf("Kopi Arabika Gayo – Biji Sangrai %d", i+1), ProductSKU: fmt.Sprintf("KOP-%03d", i+1),
+			Options: map[string]string{"Gilingan": "Kasar", "Berat": "250 g"},
+		})
+		order.Subtotal += 170000
+	}
+	order.Tax = order.Subtotal / 10
+	order.ShippingCost = 10000
+	order.TotalAmount = order.Subtotal + order.Tax + order.ShippingCost
+	return order
+}
+
+func TestItemName(t *testing.T) {
+	tests := []struct {
+		item models.OrderItem
+		want string
+	}{
+		{models.OrderItem{ProductID: 7, ProductName: "Kopi Arabika"}, "Kopi Arabika"},
+		{models.OrderItem{ProductID: 7, ProductName: "Kaos Polos", Options: map[string]string{"Warna": "Navy", "Ukuran": "L"}},
+			"Kaos Polos (Ukuran: L, Warna: Navy)"},
+		{models.OrderItem{ProductID: 7}, "Product #7"},
+	}
+	for _, tt := range tests {
+		if got := itemName(tt.item); got != tt.want {
+			t.Errorf("itemName(%+v) = %q, want %q", tt.item, got, tt.want)
+		}
+	}
+}
+
+// pages counts the pages of a rendered PDF
+func pages(pdf []byte) int {
+	return bytes.Count(pdf, []byte("<</Type /Page\n"))
+}
+
+func TestDocuments(t *testing.T) {
+	store := config.StoreConfig{Name: "Toko Kopi", Address: "Jl. Braga 1, Bandung", Email: "halo@tokokopi.id", TaxID: "01.234.567.8-901.000"}
+	tests := []struct {
+		name   string
+		render func(*bytes.Buffer, config.StoreConfig, []models.Order) error
+	}{
+		{"invoices", func(b *bytes.Buffer, s config.StoreConfig, o []models.Order) error { return Invoices(b, s, o) }},
+		{"packing slips", func(b *bytes.Buffer, s config.StoreConfig, o []models.Order) error { return PackingSlips(b, s, o) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var one, many, long bytes.Buffer
+			if err := tt.render(&one, store, []models.Order{testOrder("A", 2)}); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(one.Bytes(), []byte("%PDF-")) || pages(one.Bytes()) != 1 {
+				t.Errorf("one order gave %d pages, want a PDF of 1", pages(one.Bytes()))
+			}
+
+			orders := []models.Order{testOrder("A", 2), testOrder("B", 1), testOrder("C", 3)}
+			if err := tt.render(&many, store, orders); err != nil {
+				t.Fatal(err)
+			}
+			if got := pages(many.Bytes()); got != len(orders) {
+				t.Errorf("%d orders gave %d pages, want a page each", len(orders), got)
+			}
+
+			if err := tt.render(&long, store, []models.Order{testOrder("D", 60)}); err != nil {
+				t.Fatal(err)
+			}
+			if got := pages(long.Bytes()); got < 2 {
+				t.Errorf("60 items fit on %d page, want them to run on", got)
+			}
+		})
+	}
+}
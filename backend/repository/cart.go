@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+
+	"ecommerce-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// CartRepository stores carts and their items. A user has at most one active
+// cart; checked-out carts are deactivated and kept.
+type CartRepository interface {
+	// GetActive returns the user's active cart without its items
+	GetActive(ctx context.Context, userID uint) (models.Cart, error)
+	// GetActiveWithItems returns the user's active cart with its items and
+	// their products
+	GetActiveWithItems(ctx context.Context, userID uint) (models.Cart, error)
+	Create(ctx context.Context, cart *models.Cart) error
+	Deactivate(ctx context.Context, cartID uint) error
+
+	// GetItem returns a cart item with its cart and product
+	GetItem(ctx context.Context, itemID uint) (models.CartItem, error)
+	// FindItem returns the item for productID in the cart
+	FindItem(ctx context.Context, cartID, productID uint) (models.CartItem, error)
+	CreateItem(ctx context.Context, item *models.CartItem) error
+	UpdateItemQuantity(ctx context.Context, itemID uint, quantity int) error
+	DeleteItem(ctx context.Context, itemID uint) error
+	// ClearItems deletes every item in the cart
+	ClearItems(ctx context.Context, cartID uint) error
+}
+
+type gormCarts struct {
+	db *gorm.DB
+}
+
+// NewCartRepository returns a CartRepository backed by db
+func NewCartRepository(db *gorm.DB) CartRepository {
+	return &gormCarts{db: db}
+}
+
+func (r *gormCarts) GetActive(ctx context.Context, userID uint) (models.Cart, error) {
+	var cart models.Cart
+	err := conn(ctx, r.db).Where("user_id = ? AND is_active = ?", userID, true).First(&cart).Error
+	return cart, translate(err)
+}
+
+func (r *gormCarts) GetActiveWithItems(ctx context.Context, userID uint) (models.Cart, error) {
+	var cart models.Cart
+	err := conn(ctx, r.db).Preload("CartItems.Product").
+		Where("user_id = ? AND is_active = ?", userID, true).First(&cart).Error
+	return cart, translate(err)
+}
+
+func (r *gormCarts) Create(ctx context.Context, cart *models.Cart) error {
+	return translate(conn(ctx, r.db).Create(cart).Error)
+}
+
+func (r *gormCarts) Deactivate(ctx context.Context, cartID uint) error {
+	return translate(conn(ctx, r.db).Model(&models.Cart{}).Where("id = ?", cartID).
+		Update("is_active", false).Error)
+}
+
+func (r *gormCarts) GetItem(ctx context.Context, itemID uint) (models.CartItem, error) {
+	var item models.CartItem
+	err := conn(ctx, r.db).Preload("Cart").Preload("Product").First(&item, itemID).Error
+	return item, translate(err)
+}
+
+func (r *gormCarts) FindItem(ctx context.Context, cartID, productID uint) (models.CartItem, error) {
+	var item models.CartItem
+	err := conn(ctx, r.db).Where("cart_id = ? AND product_id = ?", cartID, productID).First(&item).Error
+	return item, translate(err)
+}
+
+func (r *gormCarts) CreateItem(ctx context.Context, item *models.CartItem) error {
+	return translate(conn(ctx, r.db).Omit("Cart", "Product").Create(item).Error)
+}
+
+func (r *gormCarts) UpdateItemQuantity(ctx context.Context, itemID uint, quantity int) error {
+	return translate(conn(ctx, r.db).Model(&models.CartItem{}).Where("id = ?", itemID).
+		Update("quantity", quantity).Error)
+}
+
+func (r *gormCarts) DeleteItem(ctx context.Context, itemID uint) error {
+	return translate(conn(ctx, r.db).Delete(&models.CartItem{}, itemID).Error)
+}
+
+func (r *gormCarts) ClearItems(ctx context.Context, cartID uint) error {
+	return translate(conn(ctx, r.db).Where("cart_id = ?", cartID).Delete(&models.CartItem{}).Error)
+}
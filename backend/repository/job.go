@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobRepository stores bulk jobs and their results. Jobs are read without
+// the document they produce, which is only loaded by Document.
+type JobRepository interface {
+	// List returns one page of jobs, newest first, without their results,
+	// and the total number of jobs
+	List(ctx context.Context, page Page) ([]models.BulkJob, int64, error)
+	// Get returns the job with its results in the order they were queued
+	Get(ctx context.Context, id uint) (models.BulkJob, error)
+	// Document returns the job with the document it produced
+	Document(ctx context.Context, id uint) (models.BulkJob, error)
+	// Create inserts the job with its Results
+	Create(ctx context.Context, job *models.BulkJob) error
+	// Claim marks the oldest queued job running and returns it. A running
+	// job that has not made progress since stale is claimed again, as its
+	// worker is taken to have stopped. Jobs another worker is claiming are
+	// skipped; ErrNotFound means there is nothing to claim.
+	Claim(ctx context.Context, stale time.Time) (models.BulkJob, error)
+	// PendingResults returns up to limit of the job's results that are still
+	// pending, in the order they were queued
+	PendingResults(ctx context.Context, jobID uint, limit int) ([]models.BulkJobResult, error)
+	// Record stores the outcome of a result and counts it towards its job's
+	// progress
+	Record(ctx context.Context, result models.BulkJobResult) error
+	// Update applies changes to the job
+	Update(ctx context.Context, id uint, changes map[string]interface{}) error
+}
+
+type gormJobs struct {
+	db *gorm.DB
+}
+
+// NewJobRepository returns a JobRepository backed by db
+func NewJobRepository(db *gorm.DB) JobRepository {
+	return &gormJobs{db: db}
+}
+
+func (r *gormJobs) List(ctx context.Context, page Page) ([]models.BulkJob, int64, error) {
+	query := conn(ctx, r.db).Model(&models.BulkJob{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+
+	var jobs []models.BulkJob
+	err := query.Omit("document").Order("created_at DESC, id DESC").
+		Offset(page.Offset()).Limit(page.Limit).Find(&jobs).Error
+	if err != nil {
+		return nil, 0, translate(err)
+	}
+	return jobs, total, nil
+}
+
+func (r *gormJobs) Get(ctx context.Context, id uint) (models.BulkJob, error) {
+	var job models.BulkJob
+	err := conn(ctx, r.db).Omit("document").
+		Preload("Results", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&job, id).Error
+	return job, translate(err)
+}
+
+func (r *gormJobs) Document(ctx context.Context, id uint) (models.BulkJob, error) {
+	var job models.BulkJob
+	err := conn(ctx, r.db).First(&job, id).Error
+	return job, translate(err)
+}
+
+func (r *gormJobs) Create(ctx context.Context, job *models.BulkJob) error {
+	db := conn(ctx, r.db)
+	if err := db.Omit(clause.Associations).Create(job).Error; err != nil {
+		return translate(err)
+	}
+	if len(job.Results) == 0 {
+		return nil
+	}
+	for i := range job.Results {
+		job.Results[i].JobID = job.ID
+	}
+	return translate(db.CreateInBatches(&job.Results, 500).Error)
+}
+
+func (r *gormJobs) Claim(ctx context.Context, stale time.Time) (models.BulkJob, error) {
+	var job models.BulkJob
+	now := time.Now()
+	err := conn(ctx, r.db).Raw(`
+		UPDATE bulk_jobs
+		SET status = 'running', started_at = COALESCE(started_at, ?), updated_at = ?
+		WHERE id = (
+			SELECT id FROM bulk_jobs
+			WHERE status = 'queued' OR (status = 'running' AND updated_at < ?)
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, action, status, carrier, service, total, processed, succeeded, skipped, failed,
+			created_by, started_at, created_at, updated_at`, now, now, stale).
+		Scan(&job).Error
+	if err != nil {
+		return models.BulkJob{}, translate(err)
+	}
+	if job.ID == 0 {
+		return models.BulkJob{}, ErrNotFound
+	}
+	return job, nil
+}
+
+func (r *gormJobs) PendingResults(ctx context.Context, jobID uint, limit int) ([]models.BulkJobResult, error) {
+	var results []models.BulkJobResult
+	err := conn(ctx, r.db).Where("job_id = ? AND status = 'pending'", jobID).
+		Order("id").Limit(limit).Find(&results).Error
+	return results, translate(err)
+}
+
+func (r *gormJobs) Record(ctx context.Context, result models.BulkJobResult) error {
+	db := conn(ctx, r.db)
+	err := db.Model(&models.BulkJobResult{}).Where("id = ?", result.ID).Updates(map[string]interface{}{
+		"order_number": result.OrderNumber,
+		"status":       result.Status,
+		"code":         result.Code,
+		"message":      result.Message,
+		"shipment_id":  result.ShipmentID,
+		"processed_at": result.ProcessedAt,
+	}).Error
+	if err != nil {
+		return translate(err)
+	}
+
+	// Each status counts in the job column of the same name
+	counter := result.Status
+	return translate(db.Model(&models.BulkJob{}).Where("id = ?", result.JobID).Updates(map[string]interface{}{
+		"processed": gorm.Expr("processed + 1"),
+		counter:     gorm.Expr(counter + " + 1"),
+	}).Error)
+}
+
+func (r *gormJobs) Update(ctx context.Context, id uint, changes map[string]interface{}) error {
+	return translate(conn(ctx, r.db).Model(&models.BulkJob{}).Where("id = ?", id).Updates(changes).Error)
+}
@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderFilter narrows an order listing. Zero values do not filter.
+type OrderFilter struct {
+	// Search matches the order number or shipping address
+	Search        string
+	Status        string
+	PaymentStatus string
+	// From and To bound when orders were placed: at or after From and
+	// before To
+	From time.Time
+	To   time.Time
+}
+
+// OrderStats are the order counts and revenue shown on the admin dashboard.
+// Revenue only counts delivered orders, net of what was refunded on them.
+type OrderStats struct {
+	TotalOrders            int64 `json:"total_orders"`
+	PendingOrders          int64 `json:"pending_orders"`
+	ProcessingOrders       int64 `json:"processing_orders"`
+	PartiallyShippedOrders int64 `json:"partially_shipped_orders"`
+	ShippedOrders          int64 `json:"shipped_orders"`
+	DeliveredOrders        int64 `json:"delivered_orders"`
+	CancelledOrders        int64 `json:"cancelled_orders"`
+	TotalRevenue           int64 `json:"total_revenue"`
+	RefundedAmount         int64 `json:"refunded_amount"`
+	TodayOrders            int64 `json:"today_orders"`
+	TodayRevenue           int64 `json:"today_revenue"`
+}
+
+// OrderRepository stores orders and their items
+type OrderRepository interface {
+	// List returns one page of orders, newest first, with their customer
+	// and items, and the total number of matches
+	List(ctx context.Context, filter OrderFilter, page Page) ([]models.Order, int64, error)
+	// ListIDs returns the IDs of up to limit orders matching filter, newest
+	// first
+	ListIDs(ctx context.Context, filter OrderFilter, limit int) ([]uint, error)
+	// Each calls fn with the orders matching filter, oldest first, up to
+	// batch at a time, with their customer and items. It stops at the first
+	// error fn returns, and returns it.
+	Each(ctx context.Context, filter OrderFilter, batch int, fn func([]models.Order) error) error
+	// ListByUser returns the user's orders, newest first, with their items.
+	// Drafts are left out until they are placed.
+	ListByUser(ctx context.Context, userID uint) ([]models.Order, error)
+	// Get returns the order with its customer, items, shipments, returns,
+	// refunds and changes
+	Get(ctx context.Context, id uint) (models.Order, error)
+	// GetForUser returns the order with its items, shipments, returns,
+	// refunds and changes if it belongs to userID and is not a draft
+	GetForUser(ctx context.Context, id, userID uint) (models.Order, error)
+	// GetByLookupToken returns the order whose lookup token hashes to hash,
+	// with the same details as GetForUser
+	GetByLookupToken(ctx context.Context, hash string) (models.Order, error)
+	// GetMany returns the orders with their customer and items, in the order
+	// of ids. Missing orders are left out.
+	GetMany(ctx context.Context, ids []uint) ([]models.Order, error)
+	// Lock holds the order's row until the surrounding transaction ends, so
+	// changes derived from its current state are made one at a time
+	Lock(ctx context.Context, id uint) error
+	// Create inserts the order and its OrderItems
+	Create(ctx context.Context, order *models.Order) error
+	// Update applies changes while the order is still at version expected
+	// and reports whether it was
+	Update(ctx context.Context, id, expected uint, changes map[string]interface{}) (bool, error)
+	// Patch applies changes whatever the order's version, and bumps it
+	Patch(ctx context.Context, id uint, changes map[string]interface{}) error
+	// UpdateIfStatus applies changes while the order is still in status and
+	// reports whether it was
+	UpdateIfStatus(ctx context.Context, id uint, status string, changes map[string]interface{}) (bool, error)
+	// ReplaceItems makes items the order's lines: items with an ID are
+	// updated, those without are inserted and given one, and the order's
+	// other lines are deleted
+	ReplaceItems(ctx context.Context, orderID uint, items []models.OrderItem) error
+	// AddChange records a change to an order
+	AddChange(ctx context.Context, change *models.OrderChange) error
+	// NextInvoiceNumber takes the next number in year's invoice sequence.
+	// The year's counter stays locked until the surrounding transaction
+	// ends, so a number is only used up if the transaction commits.
+	NextInvoiceNumber(ctx context.Context, year int) (int64, error)
+	// Stats counts orders by status, with today's figures covering orders
+	// placed at or after since. Drafts are not counted.
+	Stats(ctx context.Context, since time.Time) (OrderStats, error)
+}
+
+// statusDraft is the status of an order an admin has not placed yet, which
+// its customer does not see
+const statusDraft = "draft"
+
+type gormOrders struct {
+	db *gorm.DB
+}
+
+// NewOrderRepository returns an OrderRepository backed by db
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &gormOrders{db: db}
+}
+
+func (r *gormOrders) List(ctx context.Context, filter OrderFilter, page Page) ([]models.Order, int64, error) {
+	query := filterOrders(conn(ctx, r.db).Model(&models.Order{}), filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+
+	var orders []models.Order
+	err := query.Preload("User").Preload("OrderItems").
+		Order("created_at DESC").Offset(page.Offset()).Limit(page.Limit).
+		Find(&orders).Error
+	if err != nil {
+		return nil, 0, translate(err)
+	}
+	return orders, total, nil
+}
+
+func (r *gormOrders) ListIDs(ctx context.Context, filter OrderFilter, limit int) ([]uint, error) {
+	var ids []uint
+	err := filterOrders(conn(ctx, r.db).Model(&models.Order{}), filter).
+		Order("created_at DESC, id DESC").Limit(limit).Pluck("id", &ids).Error
+	return ids, translate(err)
+}
+
+func (r *gormOrders) Each(ctx context.Context, filter OrderFilter, batch int, fn func([]models.Order) error) error {
+	// Each batch starts after the last ID of the one before, so orders
+	// placed meanwhile neither shift nor repeat rows
+	var last uint
+	for {
+		var orders []models.Order
+		err := filterOrders(conn(ctx, r.db).Model(&models.Order{}), filter).
+			Where("id > ?", last).Preload("User").
+			Preload("OrderItems", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+			Order("id").Limit(batch).Find(&orders).Error
+		if err != nil {
+			return translate(err)
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+		if err := fn(orders); err != nil {
+			return err
+		}
+		if len(orders) < batch {
+			return nil
+		}
+		last = orders[len(orders)-1].ID
+	}
+}
+
+// filterOrders narrows query to the orders matching filter
+func filterOrders(query *gorm.DB, filter OrderFilter) *gorm.DB {
+	if filter.Search != "" {
+		query = query.Where("order_number ILIKE ? OR shipping_address ILIKE ?",
+			like(filter.Search), like(filter.Search))
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.PaymentStatus != "" {
+		query = query.Where("payment_status = ?", filter.PaymentStatus)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	return query
+}
+
+func (r *gormOrders) ListByUser(ctx context.Context, userID uint) ([]models.Order, error) {
+	var orders []models.Order
+	err := conn(ctx, r.db).Preload("OrderItems").
+		Where("user_id = ? AND status <> ?", userID, statusDraft).Order("created_at DESC").Find(&orders).Error
+	return orders, translate(err)
+}
+
+func (r *gormOrders) Get(ctx context.Context, id uint) (models.Order, error) {
+	var order models.Order
+	err := conn(ctx, r.db).Preload("User").Preload("OrderItems").
+		Preload("Shipments", orderedShipments).Preload("Shipments.Items").
+		Preload("Shipments.Events", orderedEvents).
+		Preload("Returns", orderedReturns).Preload("Returns.Items").
+		Preload("Refunds", orderedRefunds).Preload("Refunds.Items").
+		Preload("Changes", orderedChanges).First(&order, id).Error
+	return order, translate(err)
+}
+
+func (r *gormOrders) GetForUser(ctx context.Context, id, userID uint) (models.Order, error) {
+	var order models.Order
+	err := conn(ctx, r.db).Preload("OrderItems").
+		Preload("Shipments", orderedShipments).Preload("Shipments.Items").
+		Preload("Shipments.Events", orderedEvents).
+		Preload("Returns", orderedReturns).Preload("Returns.Items").
+		Preload("Refunds", orderedRefunds).Preload("Refunds.Items").
+		Preload("Changes", orderedChanges).
+		Where("id = ? AND user_id = ? AND status <> ?", id, userID, statusDraft).First(&order).Error
+	return order, translate(err)
+}
+
+func (r *gormOrders) GetByLookupToken(ctx context.Context, hash string) (models.Order, error) {
+	var order models.Order
+	err := conn(ctx, r.db).Preload("OrderItems").
+		Preload("Shipments", orderedShipments).Preload("Shipments.Items").
+		Preload("Shipments.Events", orderedEvents).
+		Preload("Returns", orderedReturns).Preload("Returns.Items").
+		Preload("Refunds", orderedRefunds).Preload("Refunds.Items").
+		Preload("Changes", orderedChanges).
+		Where("lookup_token_hash = ? AND status <> ?", hash, statusDraft).First(&order).Error
+	return order, translate(err)
+}
+
+func (r *gormOrders) GetMany(ctx context.Context, ids []uint) ([]models.Order, error) {
+	var orders []models.Order
+	err := conn(ctx, r.db).Preload("User").Preload("OrderItems").
+		Where("id IN ?", ids).Find(&orders).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	byID := make(map[uint]models.Order, len(orders))
+	for _, order := range orders {
+		byID[order.ID] = order
+	}
+	out := make([]models.Order, 0, len(orders))
+	for _, id := range ids {
+		if order, ok := byID[id]; ok {
+			out = append(out, order)
+		}
+	}
+	return out, nil
+}
+
+func (r *gormOrders) Lock(ctx context.Context, id uint) error {
+	var order models.Order
+	err := conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").First(&order, id).Error
+	return translate(err)
+}
+
+// orderedShipments lists preloaded shipments in the order they left
+func orderedShipments(db *gorm.DB) *gorm.DB {
+	return db.Order("shipped_at, id")
+}
+
+// orderedReturns lists preloaded returns in the order they were requested
+func orderedReturns(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at, id")
+}
+
+// orderedRefunds lists preloaded refunds in the order they were made
+func orderedRefunds(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at, id")
+}
+
+// orderedChanges lists preloaded order changes in the order they were made
+func orderedChanges(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at, id")
+}
+
+// orderedEvents lists preloaded tracking events oldest first
+func orderedEvents(db *gorm.DB) *gorm.DB {
+	return db.Order("occurred_at, id")
+}
+
+func (r *gormOrders) Create(ctx context.Context, order *models.Order) error {
+	db := conn(ctx, r.db)
+	if err := db.Omit(clause.Associations).Create(order).Error; err != nil {
+		return translate(err)
+	}
+	if len(order.OrderItems) == 0 {
+		return nil
+	}
+	for i := range order.OrderItems {
+		order.OrderItems[i].OrderID = order.ID
+	}
+	return translate(db.Omit(clause.Associations).Create(&order.OrderItems).Error)
+}
+
+func (r *gormOrders) Update(ctx context.Context, id, expected uint, changes map[string]interface{}) (bool, error) {
+	return versionedUpdate(conn(ctx, r.db), &models.Order{}, id, expected, changes)
+}
+
+func (r *gormOrders) Patch(ctx context.Context, id uint, changes map[string]interface{}) error {
+	changes["version"] = gorm.Expr("version + 1")
+	return translate(conn(ctx, r.db).Model(&models.Order{}).Where("id = ?", id).Updates(changes).Error)
+}
+
+func (r *gormOrders) UpdateIfStatus(ctx context.Context, id uint, status string, changes map[string]interface{}) (bool, error) {
+	changes["version"] = gorm.Expr("version + 1")
+	result := conn(ctx, r.db).Model(&models.Order{}).Where("id = ? AND status = ?", id, status).Updates(changes)
+	if result.Error != nil {
+		return false, translate(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *gormOrders) ReplaceItems(ctx context.Context, orderID uint, items []models.OrderItem) error {
+	db := conn(ctx, r.db)
+	keep := make([]uint, 0, len(items))
+	for i := range items {
+		items[i].OrderID = orderID
+		if items[i].ID == 0 {
+			continue
+		}
+		keep = append(keep, items[i].ID)
+		err := db.Model(&models.OrderItem{}).Where("id = ? AND order_id = ?", items[i].ID, orderID).
+			Updates(map[string]interface{}{
+				"quantity":    items[i].Quantity,
+				"total_price": items[i].TotalPrice,
+				"tax_amount":  items[i].TaxAmount,
+			}).Error
+		if err != nil {
+			return translate(err)
+		}
+	}
+
+	remove := db.Where("order_id = ?", orderID)
+	if len(keep) > 0 {
+		remove = remove.Where("id NOT IN ?", keep)
+	}
+	if err := remove.Delete(&models.OrderItem{}).Error; err != nil {
+		return translate(err)
+	}
+
+	for i := range items {
+		if items[i].ID != 0 {
+			continue
+		}
+		if err := db.Omit(clause.Associations).Create(&items[i]).Error; err != nil {
+			return translate(err)
+		}
+	}
+	return nil
+}
+
+func (r *gormOrders) AddChange(ctx context.Context, change *models.OrderChange) error {
+	return translate(conn(ctx, r.db).Create(change).Error)
+}
+
+func (r *gormOrders) NextInvoiceNumber(ctx context.Context, year int) (int64, error) {
+	var next int64
+	err := conn(ctx, r.db).Raw(`
+		INSERT INTO invoice_sequences (year, last_number) VALUES (?, 1)
+		ON CONFLICT (year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number`, year).Scan(&next).Error
+	return next, translate(err)
+}
+
+func (r *gormOrders) Stats(ctx context.Context, since time.Time) (OrderStats, error) {
+	var stats OrderStats
+	err := conn(ctx, r.db).Model(&models.Order{}).
+		Select(`
+		COUNT(*) AS total_orders,
+		COUNT(*) FILTER (WHERE status = 'pending') AS pending_orders,
+		COUNT(*) FILTER (WHERE status = 'processing') AS processing_orders,
+		COUNT(*) FILTER (WHERE status = 'partially_shipped') AS partially_shipped_orders,
+		COUNT(*) FILTER (WHERE status = 'shipped') AS shipped_orders,
+		COUNT(*) FILTER (WHERE status = 'delivered') AS delivered_orders,
+		COUNT(*) FILTER (WHERE status = 'cancelled') AS cancelled_orders,
+		COALESCE(SUM(total_amount - refunded_amount) FILTER (WHERE status = 'delivered'), 0) AS total_revenue,
+		COALESCE(SUM(refunded_amount) FILTER (WHERE status = 'delivered'), 0) AS refunded_amount,
+		COUNT(*) FILTER (WHERE created_at >= ?) AS today_orders,
+		COALESCE(SUM(total_amount - refunded_amount) FILTER (WHERE status = 'delivered' AND created_at >= ?), 0) AS today_revenue`,
+			since, since).
+		Where("status <> ?", statusDraft).
+		Scan(&stats).Error
+	return stats, translate(err)
+}
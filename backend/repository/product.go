@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+
+	"ecommerce-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ProductFilter narrows a product listing. Zero values do not filter.
+type ProductFilter struct {
+	Category string
+	Search   string
+	MinPrice *int64
+	MaxPrice *int64
+	// IncludeInactive also returns inactive and soft-deleted products
+	IncludeInactive bool
+}
+
+// ProductRepository stores products
+type ProductRepository interface {
+	// List returns one page of products with their category, and the total
+	// number of matches
+	List(ctx context.Context, filter ProductFilter, page Page) ([]models.Product, int64, error)
+	// Get returns the product with its category
+	Get(ctx context.Context, id uint) (models.Product, error)
+	// GetWithReviews returns the product with its category and reviews
+	GetWithReviews(ctx context.Context, id uint) (models.Product, error)
+	// GetMany returns the products with the given IDs, in any order
+	GetMany(ctx context.Context, ids []uint) ([]models.Product, error)
+	Create(ctx context.Context, product *models.Product) error
+	// Update applies changes while the product is still at version expected
+	// and reports whether it was
+	Update(ctx context.Context, id, expected uint, changes map[string]interface{}) (bool, error)
+	Delete(ctx context.Context, id uint) error
+	// DecrementStock takes quantity units from stock if at least that many
+	// are left, and reports whether it did
+	DecrementStock(ctx context.Context, id uint, quantity int) (bool, error)
+	// IncrementStock returns quantity units to stock
+	IncrementStock(ctx context.Context, id uint, quantity int) error
+}
+
+// CategoryRepository stores categories
+type CategoryRepository interface {
+	ListActive(ctx context.Context) ([]models.Category, error)
+	Get(ctx context.Context, id uint) (models.Category, error)
+	Create(ctx context.Context, category *models.Category) error
+	Save(ctx context.Context, category *models.Category) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type gormProducts struct {
+	db *gorm.DB
+}
+
+// NewProductRepository returns a ProductRepository backed by db
+func NewProductRepository(db *gorm.DB) ProductRepository {
+	return &gormProducts{db: db}
+}
+
+func (r *gormProducts) List(ctx context.Context, filter ProductFilter, page Page) ([]models.Product, int64, error) {
+	query := conn(ctx, r.db).Model(&models.Product{}).Preload("Category")
+	if filter.IncludeInactive {
+		query = query.Unscoped()
+	} else {
+		query = query.Where("products.is_active = ?", true)
+	}
+	if filter.Category != "" {
+		query = query.Joins("JOIN categories ON products.category_id = categories.id").
+			Where("categories.name ILIKE ?", like(filter.Category))
+	}
+	if filter.Search != "" {
+		query = query.Where("products.name ILIKE ? OR products.description ILIKE ?",
+			like(filter.Search), like(filter.Search))
+	}
+	if filter.MinPrice != nil {
+		query = query.Where("products.price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		query = query.Where("products.price <= ?", *filter.MaxPrice)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+
+	var products []models.Product
+	if err := query.Offset(page.Offset()).Limit(page.Limit).Find(&products).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+	return products, total, nil
+}
+
+func (r *gormProducts) Get(ctx context.Context, id uint) (models.Product, error) {
+	var product models.Product
+	err := conn(ctx, r.db).Preload("Category").First(&product, id).Error
+	return product, translate(err)
+}
+
+func (r *gormProducts) GetWithReviews(ctx context.Context, id uint) (models.Product, error) {
+	var product models.Product
+	err := conn(ctx, r.db).Preload("Category").Preload("Reviews.User").First(&product, id).Error
+	return product, translate(err)
+}
+
+func (r *gormProducts) GetMany(ctx context.Context, ids []uint) ([]models.Product, error) {
+	var products []models.Product
+	if len(ids) == 0 {
+		return products, nil
+	}
+	err := conn(ctx, r.db).Where("id IN ?", ids).Find(&products).Error
+	return products, translate(err)
+}
+
+func (r *gormProducts) Create(ctx context.Context, product *models.Product) error {
+	return translate(conn(ctx, r.db).Create(product).Error)
+}
+
+func (r *gormProducts) Update(ctx context.Context, id, expected uint, changes map[string]interface{}) (bool, error) {
+	return versionedUpdate(conn(ctx, r.db), &models.Product{}, id, expected, changes)
+}
+
+func (r *gormProducts) Delete(ctx context.Context, id uint) error {
+	return translate(conn(ctx, r.db).Delete(&models.Product{}, id).Error)
+}
+
+func (r *gormProducts) DecrementStock(ctx context.Context, id uint, quantity int) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.Product{}).
+		Where("id = ? AND stock >= ?", id, quantity).
+		Updates(map[string]interface{}{
+			"stock":   gorm.Expr("stock - ?", quantity),
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return false, translate(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *gormProducts) IncrementStock(ctx context.Context, id uint, quantity int) error {
+	return translate(conn(ctx, r.db).Model(&models.Product{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"stock":   gorm.Expr("stock + ?", quantity),
+			"version": gorm.Expr("version + 1"),
+		}).Error)
+}
+
+type gormCategories struct {
+	db *gorm.DB
+}
+
+// NewCategoryRepository returns a CategoryRepository backed by db
+func NewCategoryRepository(db *gorm.DB) CategoryRepository {
+	return &gormCategories{db: db}
+}
+
+func (r *gormCategories) ListActive(ctx context.Context) ([]models.Category, error) {
+	var categories []models.Category
+	err := conn(ctx, r.db).Where("is_active = ?", true).Find(&categories).Error
+	return categories, translate(err)
+}
+
+func (r *gormCategories) Get(ctx context.Context, id uint) (models.Category, error) {
+	var category models.Category
+	err := conn(ctx, r.db).First(&category, id).Error
+	return category, translate(err)
+}
+
+func (r *gormCategories) Create(ctx context.Context, category *models.Category) error {
+	return translate(conn(ctx, r.db).Create(category).Error)
+}
+
+func (r *gormCategories) Save(ctx context.Context, category *models.Category) error {
+	return translate(conn(ctx, r.db).Save(category).Error)
+}
+
+func (r *gormCategories) Delete(ctx context.Context, id uint) error {
+	return translate(conn(ctx, r.db).Delete(&models.Category{}, id).Error)
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+
+	"ecommerce-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundFilter narrows a refund listing. Zero values do not filter.
+type RefundFilter struct {
+	Status  string
+	Method  string
+	OrderID uint
+}
+
+// RefundRepository stores refunds with their items
+type RefundRepository interface {
+	// List returns one page of refunds, newest first, with their items, and
+	// the total number of matches
+	List(ctx context.Context, filter RefundFilter, page Page) ([]models.Refund, int64, error)
+	// ListByOrder returns the order's refunds, oldest first, with their
+	// items
+	ListByOrder(ctx context.Context, orderID uint) ([]models.Refund, error)
+	// Get returns the refund with its items
+	Get(ctx context.Context, id uint) (models.Refund, error)
+	// Create inserts the refund with its Items
+	Create(ctx context.Context, refund *models.Refund) error
+	// UpdateIfStatus applies changes while the refund is still in status
+	// and reports whether it was
+	UpdateIfStatus(ctx context.Context, id uint, status string, changes map[string]interface{}) (bool, error)
+}
+
+type gormRefunds struct {
+	db *gorm.DB
+}
+
+// NewRefundRepository returns a RefundRepository backed by db
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &gormRefunds{db: db}
+}
+
+func (r *gormRefunds) List(ctx context.Context, filter RefundFilter, page Page) ([]models.Refund, int64, error) {
+	query := conn(ctx, r.db).Model(&models.Refund{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Method != "" {
+		query = query.Where("method = ?", filter.Method)
+	}
+	if filter.OrderID != 0 {
+		query = query.Where("order_id = ?", filter.OrderID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+
+	var refunds []models.Refund
+	err := query.Preload("Items").
+		Order("created_at DESC, id DESC").Offset(page.Offset()).Limit(page.Limit).
+		Find(&refunds).Error
+	if err != nil {
+		return nil, 0, translate(err)
+	}
+	return refunds, total, nil
+}
+
+func (r *gormRefunds) ListByOrder(ctx context.Context, orderID uint) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := conn(ctx, r.db).Preload("Items").
+		Where("order_id = ?", orderID).Order("created_at, id").Find(&refunds).Error
+	return refunds, translate(err)
+}
+
+func (r *gormRefunds) Get(ctx context.Context, id uint) (models.Refund, error) {
+	var refund models.Refund
+	err := conn(ctx, r.db).Preload("Items").First(&refund, id).Error
+	return refund, translate(err)
+}
+
+func (r *gormRefunds) Create(ctx context.Context, refund *models.Refund) error {
+	db := conn(ctx, r.db)
+	if err := db.Omit(clause.Associations).Create(refund).Error; err != nil {
+		return translate(err)
+	}
+	if len(refund.Items) == 0 {
+		return nil
+	}
+	for i := range refund.Items {
+		refund.Items[i].RefundID = refund.ID
+	}
+	return translate(db.Create(&refund.Items).Error)
+}
+
+func (r *gormRefunds) UpdateIfStatus(ctx context.Context, id uint, status string, changes map[string]interface{}) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.Refund{}).Where("id = ? AND status = ?", id, status).Updates(changes)
+	if result.Error != nil {
+		return false, translate(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
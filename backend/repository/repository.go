@@ -0,0 +1,130 @@
+// Package repository is the persistence layer. Each repository is an
+// interface the services depend on, with a GORM implementation backed by
+// Postgres. Repositories translate GORM and driver errors into ErrNotFound
+// and ErrDuplicate so callers never see them.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a write violates a unique constraint
+	ErrDuplicate = errors.New("duplicate record")
+)
+
+// uniqueViolation is the Postgres SQLSTATE for a unique constraint failure
+const uniqueViolation = "23505"
+
+// Page selects one page of a list. Page is 1-based.
+type Page struct {
+	Page  int
+	Limit int
+}
+
+// Paging limits applied by NewPage
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// NewPage returns the page selected by the page and limit query parameters,
+// starting from the first page and capping its size at MaxPageSize
+func NewPage(page, limit int) Page {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	return Page{Page: page, Limit: limit}
+}
+
+// TotalPages returns the number of pages needed for total rows
+func (p Page) TotalPages(total int64) int64 {
+	return (total + int64(p.Limit) - 1) / int64(p.Limit)
+}
+
+// Offset returns the number of rows before the page
+func (p Page) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+// Transactor runs fn in a database transaction. Repository calls made with
+// the context passed to fn join the transaction; it commits when fn returns
+// nil and rolls back otherwise.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// GormTransactor implements Transactor with a GORM transaction stored in the
+// context
+type GormTransactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) *GormTransactor {
+	return &GormTransactor{db: db}
+}
+
+func (t *GormTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	// Nested calls reuse the outer transaction
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction carried by ctx, or db bound to ctx so query
+// logs and spans belong to the request
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return db.WithContext(ctx)
+}
+
+// translate maps GORM and driver errors to the package's sentinel errors
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return errors.Join(ErrDuplicate, err)
+	}
+	return err
+}
+
+// versionedUpdate writes changes to the row identified by id only while its
+// version still equals expected, and bumps the version in the same statement.
+// It reports false when another writer got there first.
+func versionedUpdate(db *gorm.DB, model interface{}, id, expected uint, changes map[string]interface{}) (bool, error) {
+	changes["version"] = gorm.Expr("version + 1")
+	result := db.Model(model).Where("id = ? AND version = ?", id, expected).Updates(changes)
+	if result.Error != nil {
+		return false, translate(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// like wraps a search term for ILIKE
+func like(term string) string {
+	return "%" + term + "%"
+}
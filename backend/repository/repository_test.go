@@ -0,0 +1,45 @@
+package repository
+
+import "testing"
+
+func TestNewPage(t *testing.T) {
+	tests := []struct {
+		name        string
+		page, limit int
+		want        Page
+		offset      int
+	}{
+		{"defaults", 0, 0, Page{Page: 1, Limit: DefaultPageSize}, 0},
+		{"negative", -3, -1, Page{Page: 1, Limit: DefaultPageSize}, 0},
+		{"third page", 3, 20, Page{Page: 3, Limit: 20}, 40},
+		{"capped", 2, 1000, Page{Page: 2, Limit: MaxPageSize}, MaxPageSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewPage(tt.page, tt.limit)
+			if got != tt.want {
+				t.Fatalf("NewPage(%d, %d) = %+v, want %+v", tt.page, tt.limit, got, tt.want)
+			}
+			if got.Offset() != tt.offset {
+				t.Errorf("Offset() = %d, want %d", got.Offset(), tt.offset)
+			}
+		})
+	}
+}
+
+func TestPageTotalPages(t *testing.T) {
+	tests := []struct {
+		total int64
+		want  int64
+	}{
+		{0, 0},
+		{1, 1},
+		{10, 1},
+		{11, 2},
+	}
+	for _, tt := range tests {
+		if got := (Page{Page: 1, Limit: 10}).TotalPages(tt.total); got != tt.want {
+			t.Errorf("TotalPages(%d) = %d, want %d", tt.total, got, tt.want)
+		}
+	}
+}
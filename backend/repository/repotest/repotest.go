@@ -0,0 +1,1474 @@
+// Package repotest provides in-memory repositories for unit testing the
+// services. They keep just enough behaviour to exercise business rules:
+// version checks, unique constraints and stock arithmetic. Set Err on any of
+// them to make every call fail.
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+)
+
+var (
+	_ repository.Transactor         = Transactor{}
+	_ repository.ProductRepository  = (*Products)(nil)
+	_ repository.CategoryRepository = (*Categories)(nil)
+	_ repository.CartRepository     = (*Carts)(nil)
+	_ repository.OrderRepository    = (*Orders)(nil)
+	_ repository.UserRepository     = (*Users)(nil)
+	_ repository.AddressRepository  = (*Addresses)(nil)
+	_ repository.ShipmentRepository = (*Shipments)(nil)
+	_ repository.ReturnRepository   = (*Returns)(nil)
+	_ repository.RefundRepository   = (*Refunds)(nil)
+	_ repository.JobRepository      = (*Jobs)(nil)
+)
+
+// Code returns the apperror code carried by err, or "" if there is none
+func Code(err error) apperror.Code {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return ""
+}
+
+// Transactor runs fn straight away. Changes made before a failure are not
+// rolled back, so tests assert on state only after successful calls.
+type Transactor struct{}
+
+func (Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// Products is an in-memory ProductRepository
+type Products struct {
+	Rows   map[uint]models.Product
+	Err    error
+	nextID uint
+}
+
+func NewProducts(rows ...models.Product) *Products {
+	p := &Products{Rows: make(map[uint]models.Product)}
+	for _, row := range rows {
+		p.put(row)
+	}
+	return p
+}
+
+func (p *Products) put(row models.Product) models.Product {
+	if row.ID == 0 {
+		row.ID = p.nextID + 1
+	}
+	if row.ID > p.nextID {
+		p.nextID = row.ID
+	}
+	if row.Version == 0 {
+		row.Version = 1
+	}
+	p.Rows[row.ID] = row
+	return row
+}
+
+func (p *Products) List(ctx context.Context, filter repository.ProductFilter, page repository.Page) ([]models.Product, int64, error) {
+	if p.Err != nil {
+		return nil, 0, p.Err
+	}
+	var out []models.Product
+	for _, id := range sortedIDs(p.Rows) {
+		row := p.Rows[id]
+		switch {
+		case !filter.IncludeInactive && !row.IsActive:
+		case filter.Search != "" && !strings.Contains(strings.ToLower(row.Name), strings.ToLower(filter.Search)):
+		case filter.MinPrice != nil && row.Price < *filter.MinPrice:
+		case filter.MaxPrice != nil && row.Price > *filter.MaxPrice:
+		default:
+			out = append(out, row)
+		}
+	}
+	return paginate(out, page), int64(len(out)), nil
+}
+
+func (p *Products) Get(ctx context.Context, id uint) (models.Product, error) {
+	if p.Err != nil {
+		return models.Product{}, p.Err
+	}
+	row, ok := p.Rows[id]
+	if !ok {
+		return models.Product{}, repository.ErrNotFound
+	}
+	return row, nil
+}
+
+func (p *Products) GetWithReviews(ctx context.Context, id uint) (models.Product, error) {
+	return p.Get(ctx, id)
+}
+
+func (p *Products) GetMany(ctx context.Context, ids []uint) ([]models.Product, error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	var out []models.Product
+	for _, id := range ids {
+		if row, ok := p.Rows[id]; ok {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+func (p *Products) Create(ctx context.Context, product *models.Product) error {
+	if p.Err != nil {
+		return p.Err
+	}
+	for _, row := range p.Rows {
+		if product.SKU != "" && row.SKU == product.SKU {
+			return repository.ErrDuplicate
+		}
+	}
+	*product = p.put(*product)
+	return nil
+}
+
+func (p *Products) Update(ctx context.Context, id, expected uint, changes map[string]interface{}) (bool, error) {
+	if p.Err != nil {
+		return false, p.Err
+	}
+	row, ok := p.Rows[id]
+	if !ok || row.Version != expected {
+		return false, nil
+	}
+	apply(&row, changes)
+	row.Version++
+	p.Rows[id] = row
+	return true, nil
+}
+
+func (p *Products) Delete(ctx context.Context, id uint) error {
+	if p.Err != nil {
+		return p.Err
+	}
+	delete(p.Rows, id)
+	return nil
+}
+
+func (p *Products) DecrementStock(ctx context.Context, id uint, quantity int) (bool, error) {
+	if p.Err != nil {
+		return false, p.Err
+	}
+	row, ok := p.Rows[id]
+	if !ok || row.Stock < quantity {
+		return false, nil
+	}
+	row.Stock -= quantity
+	row.Version++
+	p.Rows[id] = row
+	return true, nil
+}
+
+func (p *Products) IncrementStock(ctx context.Context, id uint, quantity int) error {
+	if p.Err != nil {
+		return p.Err
+	}
+	if row, ok := p.Rows[id]; ok {
+		row.Stock += quantity
+		row.Version++
+		p.Rows[id] = row
+	}
+	return nil
+}
+
+// Categories is an in-memory CategoryRepository with unique names
+type Categories struct {
+	Rows   map[uint]models.Category
+	Err    error
+	nextID uint
+}
+
+func NewCategories(rows ...models.Category) *Categories {
+	c := &Categories{Rows: make(map[uint]models.Category)}
+	for _, row := range rows {
+		c.Rows[row.ID] = row
+		if row.ID > c.nextID {
+			c.nextID = row.ID
+		}
+	}
+	return c
+}
+
+func (c *Categories) ListActive(ctx context.Context) ([]models.Category, error) {
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	var out []models.Category
+	for _, id := range sortedIDs(c.Rows) {
+		if c.Rows[id].IsActive {
+			out = append(out, c.Rows[id])
+		}
+	}
+	return out, nil
+}
+
+func (c *Categories) Get(ctx context.Context, id uint) (models.Category, error) {
+	if c.Err != nil {
+		return models.Category{}, c.Err
+	}
+	row, ok := c.Rows[id]
+	if !ok {
+		return models.Category{}, repository.ErrNotFound
+	}
+	return row, nil
+}
+
+func (c *Categories) Create(ctx context.Context, category *models.Category) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	if c.nameTaken(category.Name, 0) {
+		return repository.ErrDuplicate
+	}
+	c.nextID++
+	category.ID = c.nextID
+	c.Rows[category.ID] = *category
+	return nil
+}
+
+func (c *Categories) Save(ctx context.Context, category *models.Category) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	if c.nameTaken(category.Name, category.ID) {
+		return repository.ErrDuplicate
+	}
+	c.Rows[category.ID] = *category
+	return nil
+}
+
+func (c *Categories) Delete(ctx context.Context, id uint) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	delete(c.Rows, id)
+	return nil
+}
+
+func (c *Categories) nameTaken(name string, except uint) bool {
+	for id, row := range c.Rows {
+		if id != except && row.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Carts is an in-memory CartRepository. Items are joined to products from
+// Products when read.
+type Carts struct {
+	Carts    map[uint]models.Cart
+	Items    map[uint]models.CartItem
+	Products *Products
+	Err      error
+
+	nextCart, nextItem uint
+}
+
+func NewCarts(products *Products) *Carts {
+	return &Carts{
+		Carts:    make(map[uint]models.Cart),
+		Items:    make(map[uint]models.CartItem),
+		Products: products,
+	}
+}
+
+// Fill gives the user an active cart holding items, and returns it
+func (c *Carts) Fill(userID uint, items ...models.CartItem) models.Cart {
+	cart := models.Cart{UserID: userID, IsActive: true}
+	_ = c.Create(context.Background(), &cart)
+	for _, item := range items {
+		item.CartID = cart.ID
+		_ = c.CreateItem(context.Background(), &item)
+	}
+	return cart
+}
+
+// ItemsIn returns the items in a cart
+func (c *Carts) ItemsIn(cartID uint) []models.CartItem {
+	var out []models.CartItem
+	for _, id := range sortedIDs(c.Items) {
+		item := c.Items[id]
+		if item.CartID == cartID {
+			if product, ok := c.Products.Rows[item.ProductID]; ok {
+				item.Product = product
+			}
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (c *Carts) GetActive(ctx context.Context, userID uint) (models.Cart, error) {
+	if c.Err != nil {
+		return models.Cart{}, c.Err
+	}
+	for _, id := range sortedIDs(c.Carts) {
+		if cart := c.Carts[id]; cart.UserID == userID && cart.IsActive {
+			return cart, nil
+		}
+	}
+	return models.Cart{}, repository.ErrNotFound
+}
+
+func (c *Carts) GetActiveWithItems(ctx context.Context, userID uint) (models.Cart, error) {
+	cart, err := c.GetActive(ctx, userID)
+	if err != nil {
+		return cart, err
+	}
+	cart.CartItems = c.ItemsIn(cart.ID)
+	return cart, nil
+}
+
+func (c *Carts) Create(ctx context.Context, cart *models.Cart) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	c.nextCart++
+	cart.ID = c.nextCart
+	c.Carts[cart.ID] = *cart
+	return nil
+}
+
+func (c *Carts) Deactivate(ctx context.Context, cartID uint) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	cart := c.Carts[cartID]
+	cart.IsActive = false
+	c.Carts[cartID] = cart
+	return nil
+}
+
+func (c *Carts) GetItem(ctx context.Context, itemID uint) (models.CartItem, error) {
+	if c.Err != nil {
+		return models.CartItem{}, c.Err
+	}
+	item, ok := c.Items[itemID]
+	if !ok {
+		return models.CartItem{}, repository.ErrNotFound
+	}
+	item.Cart = c.Carts[item.CartID]
+	item.Product = c.Products.Rows[item.ProductID]
+	return item, nil
+}
+
+func (c *Carts) FindItem(ctx context.Context, cartID, productID uint) (models.CartItem, error) {
+	if c.Err != nil {
+		return models.CartItem{}, c.Err
+	}
+	for _, id := range sortedIDs(c.Items) {
+		if item := c.Items[id]; item.CartID == cartID && item.ProductID == productID {
+			return item, nil
+		}
+	}
+	return models.CartItem{}, repository.ErrNotFound
+}
+
+func (c *Carts) CreateItem(ctx context.Context, item *models.CartItem) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	c.nextItem++
+	item.ID = c.nextItem
+	c.Items[item.ID] = *item
+	return nil
+}
+
+func (c *Carts) UpdateItemQuantity(ctx context.Context, itemID uint, quantity int) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	item := c.Items[itemID]
+	item.Quantity = quantity
+	c.Items[itemID] = item
+	return nil
+}
+
+func (c *Carts) DeleteItem(ctx context.Context, itemID uint) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	delete(c.Items, itemID)
+	return nil
+}
+
+func (c *Carts) ClearItems(ctx context.Context, cartID uint) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	for id, item := range c.Items {
+		if item.CartID == cartID {
+			delete(c.Items, id)
+		}
+	}
+	return nil
+}
+
+// Orders is an in-memory OrderRepository. Items and changes are kept on
+// their order's row.
+type Orders struct {
+	Rows         map[uint]models.Order
+	Err          error
+	nextID       uint
+	nextItemID   uint
+	nextChangeID uint
+	// Invoices is the last invoice number taken in each year
+	Invoices map[int]int64
+}
+
+func NewOrders(rows ...models.Order) *Orders {
+	o := &Orders{Rows: make(map[uint]models.Order), Invoices: make(map[int]int64)}
+	for _, row := range rows {
+		_ = o.Create(context.Background(), &row)
+	}
+	return o
+}
+
+func (o *Orders) List(ctx context.Context, filter repository.OrderFilter, page repository.Page) ([]models.Order, int64, error) {
+	if o.Err != nil {
+		return nil, 0, o.Err
+	}
+	out := o.filter(filter)
+	return paginate(out, page), int64(len(out)), nil
+}
+
+func (o *Orders) ListIDs(ctx context.Context, filter repository.OrderFilter, limit int) ([]uint, error) {
+	if o.Err != nil {
+		return nil, o.Err
+	}
+	var ids []uint
+	for _, row := range o.filter(filter) {
+		if len(ids) == limit {
+			break
+		}
+		ids = append(ids, row.ID)
+	}
+	return ids, nil
+}
+
+func (o *Orders) Each(ctx context.Context, filter repository.OrderFilter, batch int, fn func([]models.Order) error) error {
+	if o.Err != nil {
+		return o.Err
+	}
+	matching := o.filter(filter)
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID < matching[j].ID })
+	for start := 0; start < len(matching); start += batch {
+		if err := fn(matching[start:min(start+batch, len(matching))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filter returns the orders matching filter, newest first
+func (o *Orders) filter(filter repository.OrderFilter) []models.Order {
+	var out []models.Order
+	for _, row := range o.newestFirst() {
+		switch {
+		case filter.Status != "" && row.Status != filter.Status:
+		case filter.PaymentStatus != "" && row.PaymentStatus != filter.PaymentStatus:
+		case filter.Search != "" && !strings.Contains(row.OrderNumber, filter.Search) && !strings.Contains(row.ShippingAddress, filter.Search):
+		case !filter.From.IsZero() && row.CreatedAt.Before(filter.From):
+		case !filter.To.IsZero() && !row.CreatedAt.Before(filter.To):
+		default:
+			out = append(out, row)
+		}
+	}
+	return out
+}
+
+func (o *Orders) ListByUser(ctx context.Context, userID uint) ([]models.Order, error) {
+	if o.Err != nil {
+		return nil, o.Err
+	}
+	var out []models.Order
+	for _, row := range o.newestFirst() {
+		if row.UserID == userID && row.Status != "draft" {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+func (o *Orders) Get(ctx context.Context, id uint) (models.Order, error) {
+	if o.Err != nil {
+		return models.Order{}, o.Err
+	}
+	row, ok := o.Rows[id]
+	if !ok {
+		return models.Order{}, repository.ErrNotFound
+	}
+	return row, nil
+}
+
+func (o *Orders) GetForUser(ctx context.Context, id, userID uint) (models.Order, error) {
+	row, err := o.Get(ctx, id)
+	if err == nil && (row.UserID != userID || row.Status == "draft") {
+		return models.Order{}, repository.ErrNotFound
+	}
+	return row, err
+}
+
+func (o *Orders) GetByLookupToken(ctx context.Context, hash string) (models.Order, error) {
+	if o.Err != nil {
+		return models.Order{}, o.Err
+	}
+	for _, row := range o.Rows {
+		if row.LookupTokenHash != nil && *row.LookupTokenHash == hash && row.Status != "draft" {
+			return row, nil
+		}
+	}
+	return models.Order{}, repository.ErrNotFound
+}
+
+func (o *Orders) GetMany(ctx context.Context, ids []uint) ([]models.Order, error) {
+	if o.Err != nil {
+		return nil, o.Err
+	}
+	var out []models.Order
+	for _, id := range ids {
+		if row, ok := o.Rows[id]; ok {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+func (o *Orders) Create(ctx context.Context, order *models.Order) error {
+	if o.Err != nil {
+		return o.Err
+	}
+	for _, row := range o.Rows {
+		if row.OrderNumber == order.OrderNumber {
+			return repository.ErrDuplicate
+		}
+	}
+	o.nextID++
+	order.ID = o.nextID
+	if order.Version == 0 {
+		order.Version = 1
+	}
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = time.Now()
+	}
+	for i := range order.OrderItems {
+		order.OrderItems[i].OrderID = order.ID
+		if order.OrderItems[i].ID == 0 {
+			o.nextItemID++
+			order.OrderItems[i].ID = o.nextItemID
+		} else {
+			o.nextItemID = max(o.nextItemID, order.OrderItems[i].ID)
+		}
+	}
+	o.Rows[order.ID] = *order
+	return nil
+}
+
+func (o *Orders) Update(ctx context.Context, id, expected uint, changes map[string]interface{}) (bool, error) {
+	if o.Err != nil {
+		return false, o.Err
+	}
+	row, ok := o.Rows[id]
+	if !ok || row.Version != expected {
+		return false, nil
+	}
+	apply(&row, changes)
+	row.Version++
+	o.Rows[id] = row
+	return true, nil
+}
+
+func (o *Orders) Patch(ctx context.Context, id uint, changes map[string]interface{}) error {
+	if o.Err != nil {
+		return o.Err
+	}
+	row := o.Rows[id]
+	apply(&row, changes)
+	row.Version++
+	o.Rows[id] = row
+	return nil
+}
+
+func (o *Orders) Lock(ctx context.Context, id uint) error {
+	if o.Err != nil {
+		return o.Err
+	}
+	if _, ok := o.Rows[id]; !ok {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (o *Orders) UpdateIfStatus(ctx context.Context, id uint, status string, changes map[string]interface{}) (bool, error) {
+	if o.Err != nil {
+		return false, o.Err
+	}
+	row, ok := o.Rows[id]
+	if !ok || row.Status != status {
+		return false, nil
+	}
+	apply(&row, changes)
+	row.Version++
+	o.Rows[id] = row
+	return true, nil
+}
+
+func (o *Orders) ReplaceItems(ctx context.Context, orderID uint, items []models.OrderItem) error {
+	if o.Err != nil {
+		return o.Err
+	}
+	row, ok := o.Rows[orderID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for i := range items {
+		items[i].OrderID = orderID
+		if items[i].ID == 0 {
+			o.nextItemID++
+			items[i].ID = o.nextItemID
+		}
+	}
+	row.OrderItems = append([]models.OrderItem(nil), items...)
+	o.Rows[orderID] = row
+	return nil
+}
+
+func (o *Orders) AddChange(ctx context.Context, change *models.OrderChange) error {
+	if o.Err != nil {
+		return o.Err
+	}
+	row, ok := o.Rows[change.OrderID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	o.nextChangeID++
+	change.ID = o.nextChangeID
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = time.Now()
+	}
+	row.Changes = append(row.Changes, *change)
+	o.Rows[change.OrderID] = row
+	return nil
+}
+
+func (o *Orders) NextInvoiceNumber(ctx context.Context, year int) (int64, error) {
+	if o.Err != nil {
+		return 0, o.Err
+	}
+	o.Invoices[year]++
+	return o.Invoices[year], nil
+}
+
+func (o *Orders) Stats(ctx context.Context, since time.Time) (repository.OrderStats, error) {
+	if o.Err != nil {
+		return repository.OrderStats{}, o.Err
+	}
+	var s repository.OrderStats
+	for _, row := range o.Rows {
+		if row.Status == "draft" {
+			continue
+		}
+		today := !row.CreatedAt.Before(since)
+		s.TotalOrders++
+		if today {
+			s.TodayOrders++
+		}
+		switch row.Status {
+		case "pending":
+			s.PendingOrders++
+		case "processing":
+			s.ProcessingOrders++
+		case "partially_shipped":
+			s.PartiallyShippedOrders++
+		case "shipped":
+			s.ShippedOrders++
+		case "delivered":
+			s.DeliveredOrders++
+			s.TotalRevenue += row.TotalAmount - row.RefundedAmount
+			s.RefundedAmount += row.RefundedAmount
+			if today {
+				s.TodayRevenue += row.TotalAmount - row.RefundedAmount
+			}
+		case "cancelled":
+			s.CancelledOrders++
+		}
+	}
+	return s, nil
+}
+
+func (o *Orders) newestFirst() []models.Order {
+	out := make([]models.Order, 0, len(o.Rows))
+	for _, row := range o.Rows {
+		out = append(out, row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out
+}
+
+// Shipments is an in-memory ShipmentRepository. Tracking events are kept on
+// their shipment's row.
+type Shipments struct {
+	Rows        map[uint]models.Shipment
+	Err         error
+	nextID      uint
+	nextItemID  uint
+	nextEventID uint
+}
+
+func NewShipments(rows ...models.Shipment) *Shipments {
+	s := &Shipments{Rows: make(map[uint]models.Shipment)}
+	for _, row := range rows {
+		_ = s.Create(context.Background(), &row)
+	}
+	return s
+}
+
+func (s *Shipments) ListByOrder(ctx context.Context, orderID uint) ([]models.Shipment, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	var out []models.Shipment
+	for _, id := range sortedIDs(s.Rows) {
+		if row := s.Rows[id]; row.OrderID == orderID {
+			out = append(out, row)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ShippedAt.Before(out[j].ShippedAt) })
+	return out, nil
+}
+
+func (s *Shipments) Get(ctx context.Context, orderID, id uint) (models.Shipment, error) {
+	if s.Err != nil {
+		return models.Shipment{}, s.Err
+	}
+	row, ok := s.Rows[id]
+	if !ok || row.OrderID != orderID {
+		return models.Shipment{}, repository.ErrNotFound
+	}
+	return row, nil
+}
+
+func (s *Shipments) Create(ctx context.Context, shipment *models.Shipment) error {
+	if s.Err != nil {
+		return s.Err
+	}
+	s.nextID++
+	shipment.ID = s.nextID
+	for i := range shipment.Items {
+		s.nextItemID++
+		shipment.Items[i].ID = s.nextItemID
+		shipment.Items[i].ShipmentID = shipment.ID
+	}
+	s.Rows[shipment.ID] = *shipment
+	return nil
+}
+
+func (s *Shipments) MarkDelivered(ctx context.Context, id uint, at time.Time) (bool, error) {
+	if s.Err != nil {
+		return false, s.Err
+	}
+	row, ok := s.Rows[id]
+	if !ok || row.DeliveredAt != nil {
+		return false, nil
+	}
+	row.DeliveredAt = &at
+	s.Rows[id] = row
+	return true, nil
+}
+
+func (s *Shipments) ListOpen(ctx context.Context, carriers []string, before time.Time, limit int) ([]models.Shipment, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	wanted := make(map[string]bool, len(carriers))
+	for _, c := range carriers {
+		wanted[c] = true
+	}
+	var out []models.Shipment
+	for _, id := range sortedIDs(s.Rows) {
+		row := s.Rows[id]
+		if row.DeliveredAt != nil || row.TrackingNumber == "" || !wanted[row.Carrier] {
+			continue
+		}
+		if row.TrackedAt != nil && !row.TrackedAt.Before(before) {
+			continue
+		}
+		out = append(out, row)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].TrackedAt, out[j].TrackedAt
+		return a == nil && b != nil || a != nil && b != nil && a.Before(*b)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *Shipments) FindByTracking(ctx context.Context, carrier, trackingNumber string) (models.Shipment, error) {
+	if s.Err != nil {
+		return models.Shipment{}, s.Err
+	}
+	var found *models.Shipment
+	for _, id := range sortedIDs(s.Rows) {
+		row := s.Rows[id]
+		if row.Carrier == carrier && row.TrackingNumber == trackingNumber &&
+			(found == nil || !row.ShippedAt.Before(found.ShippedAt)) {
+			found = &row
+		}
+	}
+	if found == nil {
+		return models.Shipment{}, repository.ErrNotFound
+	}
+	return *found, nil
+}
+
+func (s *Shipments) MarkTracked(ctx context.Context, id uint, at time.Time) error {
+	if s.Err != nil {
+		return s.Err
+	}
+	row, ok := s.Rows[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	row.TrackedAt = &at
+	s.Rows[id] = row
+	return nil
+}
+
+func (s *Shipments) AddEvents(ctx context.Context, events []models.TrackingEvent) (int, error) {
+	if s.Err != nil {
+		return 0, s.Err
+	}
+	added := 0
+	for _, event := range events {
+		row, ok := s.Rows[event.ShipmentID]
+		if !ok {
+			return added, repository.ErrNotFound
+		}
+		duplicate := false
+		for _, e := range row.Events {
+			if e.OccurredAt.Equal(event.OccurredAt) && e.Status == event.Status && e.Description == event.Description {
+				duplicate = true
+			}
+		}
+		if duplicate {
+			continue
+		}
+		s.nextEventID++
+		event.ID = s.nextEventID
+		row.Events = append(row.Events, event)
+		s.Rows[row.ID] = row
+		added++
+	}
+	return added, nil
+}
+
+// Returns is an in-memory ReturnRepository. Items and photos are kept on
+// their return's row.
+type Returns struct {
+	Rows       map[uint]models.Return
+	Err        error
+	nextID     uint
+	nextItemID uint
+}
+
+func NewReturns(rows ...models.Return) *Returns {
+	r := &Returns{Rows: make(map[uint]models.Return)}
+	for _, row := range rows {
+		_ = r.Create(context.Background(), &row)
+	}
+	return r
+}
+
+func (r *Returns) List(ctx context.Context, filter repository.ReturnFilter, page repository.Page) ([]models.Return, int64, error) {
+	if r.Err != nil {
+		return nil, 0, r.Err
+	}
+	var out []models.Return
+	ids := sortedIDs(r.Rows)
+	for i := len(ids) - 1; i >= 0; i-- {
+		row := r.Rows[ids[i]]
+		switch {
+		case filter.Status != "" && row.Status != filter.Status:
+		case filter.OrderID != 0 && row.OrderID != filter.OrderID:
+		default:
+			out = append(out, row)
+		}
+	}
+	return paginate(out, page), int64(len(out)), nil
+}
+
+func (r *Returns) ListByUser(ctx context.Context, userID uint) ([]models.Return, error) {
+	out, _, err := r.List(ctx, repository.ReturnFilter{}, repository.Page{Page: 1, Limit: len(r.Rows) + 1})
+	var mine []models.Return
+	for _, row := range out {
+		if row.UserID == userID {
+			mine = append(mine, row)
+		}
+	}
+	return mine, err
+}
+
+func (r *Returns) ListByOrder(ctx context.Context, orderID uint) ([]models.Return, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	var out []models.Return
+	for _, id := range sortedIDs(r.Rows) {
+		if row := r.Rows[id]; row.OrderID == orderID {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+func (r *Returns) Get(ctx context.Context, id uint) (models.Return, error) {
+	if r.Err != nil {
+		return models.Return{}, r.Err
+	}
+	row, ok := r.Rows[id]
+	if !ok {
+		return models.Return{}, repository.ErrNotFound
+	}
+	return row, nil
+}
+
+func (r *Returns) GetForUser(ctx context.Context, id, userID uint) (models.Return, error) {
+	row, err := r.Get(ctx, id)
+	if err == nil && row.UserID != userID {
+		return models.Return{}, repository.ErrNotFound
+	}
+	return row, err
+}
+
+func (r *Returns) Create(ctx context.Context, ret *models.Return) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	for _, row := range r.Rows {
+		if row.RMANumber == ret.RMANumber {
+			return repository.ErrDuplicate
+		}
+	}
+	r.nextID++
+	ret.ID = r.nextID
+	if ret.Status == "" {
+		ret.Status = "requested"
+	}
+	if ret.CreatedAt.IsZero() {
+		ret.CreatedAt = time.Now()
+	}
+	for i := range ret.Items {
+		r.nextItemID++
+		ret.Items[i].ID = r.nextItemID
+		ret.Items[i].ReturnID = ret.ID
+	}
+	for i := range ret.Photos {
+		ret.Photos[i].ID = uint(i + 1)
+		ret.Photos[i].ReturnID = ret.ID
+	}
+	r.Rows[ret.ID] = *ret
+	return nil
+}
+
+func (r *Returns) UpdateIfStatus(ctx context.Context, id uint, status string, changes map[string]interface{}) (bool, error) {
+	if r.Err != nil {
+		return false, r.Err
+	}
+	row, ok := r.Rows[id]
+	if !ok || row.Status != status {
+		return false, nil
+	}
+	apply(&row, changes)
+	r.Rows[id] = row
+	return true, nil
+}
+
+func (r *Returns) SetDisposition(ctx context.Context, itemID uint, disposition string) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	for id, row := range r.Rows {
+		for i := range row.Items {
+			if row.Items[i].ID == itemID {
+				row.Items[i].Disposition = disposition
+				r.Rows[id] = row
+				return nil
+			}
+		}
+	}
+	return repository.ErrNotFound
+}
+
+// Refunds is an in-memory RefundRepository. Items are kept on their
+// refund's row.
+type Refunds struct {
+	Rows       map[uint]models.Refund
+	Err        error
+	nextID     uint
+	nextItemID uint
+}
+
+func NewRefunds(rows ...models.Refund) *Refunds {
+	r := &Refunds{Rows: make(map[uint]models.Refund)}
+	for _, row := range rows {
+		_ = r.Create(context.Background(), &row)
+	}
+	return r
+}
+
+func (r *Refunds) List(ctx context.Context, filter repository.RefundFilter, page repository.Page) ([]models.Refund, int64, error) {
+	if r.Err != nil {
+		return nil, 0, r.Err
+	}
+	var out []models.Refund
+	ids := sortedIDs(r.Rows)
+	for i := len(ids) - 1; i >= 0; i-- {
+		row := r.Rows[ids[i]]
+		switch {
+		case filter.Status != "" && row.Status != filter.Status:
+		case filter.Method != "" && row.Method != filter.Method:
+		case filter.OrderID != 0 && row.OrderID != filter.OrderID:
+		default:
+			out = append(out, row)
+		}
+	}
+	return paginate(out, page), int64(len(out)), nil
+}
+
+func (r *Refunds) ListByOrder(ctx context.Context, orderID uint) ([]models.Refund, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	var out []models.Refund
+	for _, id := range sortedIDs(r.Rows) {
+		if row := r.Rows[id]; row.OrderID == orderID {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+func (r *Refunds) Get(ctx context.Context, id uint) (models.Refund, error) {
+	if r.Err != nil {
+		return models.Refund{}, r.Err
+	}
+	row, ok := r.Rows[id]
+	if !ok {
+		return models.Refund{}, repository.ErrNotFound
+	}
+	return row, nil
+}
+
+func (r *Refunds) Create(ctx context.Context, refund *models.Refund) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.nextID++
+	refund.ID = r.nextID
+	if refund.Status == "" {
+		refund.Status = "pending"
+	}
+	if refund.CreatedAt.IsZero() {
+		refund.CreatedAt = time.Now()
+	}
+	for i := range refund.Items {
+		r.nextItemID++
+		refund.Items[i].ID = r.nextItemID
+		refund.Items[i].RefundID = refund.ID
+	}
+	r.Rows[refund.ID] = *refund
+	return nil
+}
+
+func (r *Refunds) UpdateIfStatus(ctx context.Context, id uint, status string, changes map[string]interface{}) (bool, error) {
+	if r.Err != nil {
+		return false, r.Err
+	}
+	row, ok := r.Rows[id]
+	if !ok || row.Status != status {
+		return false, nil
+	}
+	apply(&row, changes)
+	r.Rows[id] = row
+	return true, nil
+}
+
+// Users is an in-memory UserRepository with unique emails
+type Users struct {
+	Rows   map[uint]models.User
+	Err    error
+	nextID uint
+}
+
+func NewUsers(rows ...models.User) *Users {
+	u := &Users{Rows: make(map[uint]models.User)}
+	for _, row := range rows {
+		_ = u.Create(context.Background(), &row)
+	}
+	return u
+}
+
+func (u *Users) List(ctx context.Context, filter repository.UserFilter, page repository.Page) ([]models.User, int64, error) {
+	if u.Err != nil {
+		return nil, 0, u.Err
+	}
+	var out []models.User
+	for _, id := range sortedIDs(u.Rows) {
+		row := u.Rows[id]
+		switch {
+		case filter.Role != "" && row.Role != filter.Role:
+		case filter.IsActive != nil && row.IsActive != *filter.IsActive:
+		case filter.Search != "" && !strings.Contains(row.Email, filter.Search):
+		default:
+			out = append(out, row)
+		}
+	}
+	return paginate(out, page), int64(len(out)), nil
+}
+
+func (u *Users) Get(ctx context.Context, id uint) (models.User, error) {
+	if u.Err != nil {
+		return models.User{}, u.Err
+	}
+	row, ok := u.Rows[id]
+	if !ok {
+		return models.User{}, repository.ErrNotFound
+	}
+	return row, nil
+}
+
+func (u *Users) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	if u.Err != nil {
+		return models.User{}, u.Err
+	}
+	for _, row := range u.Rows {
+		if strings.EqualFold(row.Email, email) {
+			return row, nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (u *Users) GetByClaimToken(ctx context.Context, hash string) (models.User, error) {
+	if u.Err != nil {
+		return models.User{}, u.Err
+	}
+	for _, row := range u.Rows {
+		if row.IsGuest && row.ClaimTokenHash != nil && *row.ClaimTokenHash == hash {
+			return row, nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (u *Users) Create(ctx context.Context, user *models.User) error {
+	if u.Err != nil {
+		return u.Err
+	}
+	if u.emailTaken(user.Email, 0) {
+		return repository.ErrDuplicate
+	}
+	u.nextID++
+	user.ID = u.nextID
+	if user.Version == 0 {
+		user.Version = 1
+	}
+	u.Rows[user.ID] = *user
+	return nil
+}
+
+func (u *Users) CreateIfNew(ctx context.Context, user *models.User) (bool, error) {
+	err := u.Create(ctx, user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (u *Users) Update(ctx context.Context, id, expected uint, changes map[string]interface{}) (bool, error) {
+	if u.Err != nil {
+		return false, u.Err
+	}
+	row, ok := u.Rows[id]
+	if !ok || row.Version != expected {
+		return false, nil
+	}
+	if email, ok := changes["email"].(string); ok && u.emailTaken(email, id) {
+		return false, repository.ErrDuplicate
+	}
+	apply(&row, changes)
+	// Password and the claim token are hidden from JSON, so apply cannot
+	// see them
+	if password, ok := changes["password"].(string); ok {
+		row.Password = password
+	}
+	if hash, ok := changes["claim_token_hash"]; ok {
+		row.ClaimTokenHash, _ = hash.(*string)
+	}
+	if expires, ok := changes["claim_expires_at"]; ok {
+		row.ClaimExpiresAt, _ = expires.(*time.Time)
+	}
+	row.Version++
+	u.Rows[id] = row
+	return true, nil
+}
+
+func (u *Users) Delete(ctx context.Context, id uint) error {
+	if u.Err != nil {
+		return u.Err
+	}
+	delete(u.Rows, id)
+	return nil
+}
+
+func (u *Users) emailTaken(email string, except uint) bool {
+	for id, row := range u.Rows {
+		if id != except && row.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+// Addresses is an in-memory AddressRepository
+type Addresses struct {
+	Rows []models.Address
+	Err  error
+}
+
+func (a *Addresses) Create(ctx context.Context, address *models.Address) error {
+	if a.Err != nil {
+		return a.Err
+	}
+	address.ID = uint(len(a.Rows) + 1)
+	a.Rows = append(a.Rows, *address)
+	return nil
+}
+
+// Jobs is an in-memory JobRepository. Results are kept on their job's row.
+type Jobs struct {
+	Rows         map[uint]models.BulkJob
+	Err          error
+	nextID       uint
+	nextResultID uint
+}
+
+func NewJobs(rows ...models.BulkJob) *Jobs {
+	j := &Jobs{Rows: make(map[uint]models.BulkJob)}
+	for _, row := range rows {
+		_ = j.Create(context.Background(), &row)
+	}
+	return j
+}
+
+func (j *Jobs) List(ctx context.Context, page repository.Page) ([]models.BulkJob, int64, error) {
+	if j.Err != nil {
+		return nil, 0, j.Err
+	}
+	var out []models.BulkJob
+	ids := sortedIDs(j.Rows)
+	for i := len(ids) - 1; i >= 0; i-- {
+		row := j.Rows[ids[i]]
+		row.Results, row.Document = nil, nil
+		out = append(out, row)
+	}
+	return paginate(out, page), int64(len(out)), nil
+}
+
+func (j *Jobs) Get(ctx context.Context, id uint) (models.BulkJob, error) {
+	row, err := j.Document(ctx, id)
+	row.Document = nil
+	return row, err
+}
+
+func (j *Jobs) Document(ctx context.Context, id uint) (models.BulkJob, error) {
+	if j.Err != nil {
+		return models.BulkJob{}, j.Err
+	}
+	row, ok := j.Rows[id]
+	if !ok {
+		return models.BulkJob{}, repository.ErrNotFound
+	}
+	row.Results = append([]models.BulkJobResult(nil), row.Results...)
+	return row, nil
+}
+
+func (j *Jobs) Create(ctx context.Context, job *models.BulkJob) error {
+	if j.Err != nil {
+		return j.Err
+	}
+	j.nextID++
+	job.ID = j.nextID
+	if job.Status == "" {
+		job.Status = "queued"
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	job.UpdatedAt = job.CreatedAt
+	for i := range job.Results {
+		j.nextResultID++
+		job.Results[i].ID = j.nextResultID
+		job.Results[i].JobID = job.ID
+		if job.Results[i].Status == "" {
+			job.Results[i].Status = "pending"
+		}
+	}
+	row := *job
+	row.Results = append([]models.BulkJobResult(nil), job.Results...)
+	j.Rows[job.ID] = row
+	return nil
+}
+
+func (j *Jobs) Claim(ctx context.Context, stale time.Time) (models.BulkJob, error) {
+	if j.Err != nil {
+		return models.BulkJob{}, j.Err
+	}
+	for _, id := range sortedIDs(j.Rows) {
+		row := j.Rows[id]
+		if row.Status != "queued" && (row.Status != "running" || !row.UpdatedAt.Before(stale)) {
+			continue
+		}
+		now := time.Now()
+		row.Status = "running"
+		if row.StartedAt == nil {
+			row.StartedAt = &now
+		}
+		row.UpdatedAt = now
+		j.Rows[id] = row
+		row.Results, row.Document = nil, nil
+		return row, nil
+	}
+	return models.BulkJob{}, repository.ErrNotFound
+}
+
+func (j *Jobs) PendingResults(ctx context.Context, jobID uint, limit int) ([]models.BulkJobResult, error) {
+	if j.Err != nil {
+		return nil, j.Err
+	}
+	var out []models.BulkJobResult
+	for _, result := range j.Rows[jobID].Results {
+		if len(out) == limit {
+			break
+		}
+		if result.Status == "pending" {
+			out = append(out, result)
+		}
+	}
+	return out, nil
+}
+
+func (j *Jobs) Record(ctx context.Context, result models.BulkJobResult) error {
+	if j.Err != nil {
+		return j.Err
+	}
+	row, ok := j.Rows[result.JobID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for i := range row.Results {
+		if row.Results[i].ID == result.ID {
+			row.Results[i] = result
+		}
+	}
+	row.Processed++
+	switch result.Status {
+	case "succeeded":
+		row.Succeeded++
+	case "skipped":
+		row.Skipped++
+	case "failed":
+		row.Failed++
+	}
+	row.UpdatedAt = time.Now()
+	j.Rows[row.ID] = row
+	return nil
+}
+
+func (j *Jobs) Update(ctx context.Context, id uint, changes map[string]interface{}) error {
+	if j.Err != nil {
+		return j.Err
+	}
+	row, ok := j.Rows[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	apply(&row, changes)
+	// The document is not serialised, so apply cannot find its column
+	if document, ok := changes["document"].([]byte); ok {
+		row.Document = document
+	}
+	row.UpdatedAt = time.Now()
+	j.Rows[id] = row
+	return nil
+}
+
+// apply sets the fields of dst whose JSON names, which match the column
+// names, appear in changes. Expressions such as the version bump are left to
+// the caller.
+func apply(dst interface{}, changes map[string]interface{}) {
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		value, ok := changes[name]
+		if !ok || name == "version" {
+			continue
+		}
+		field := v.Field(i)
+		rv := reflect.ValueOf(value)
+		if rv.Type().AssignableTo(field.Type()) {
+			field.Set(rv)
+		} else if rv.Type().ConvertibleTo(field.Type()) {
+			field.Set(rv.Convert(field.Type()))
+		} else {
+			// Fall back to a JSON round trip for values such as []string
+			encoded, _ := json.Marshal(value)
+			_ = json.Unmarshal(encoded, field.Addr().Interface())
+		}
+	}
+}
+
+func paginate[T any](rows []T, page repository.Page) []T {
+	start := page.Offset()
+	if start >= len(rows) {
+		return nil
+	}
+	end := start + page.Limit
+	if end > len(rows) {
+		end = len(rows)
+	}
+	return rows[start:end]
+}
+
+func sortedIDs[T any](rows map[uint]T) []uint {
+	ids := make([]uint, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+
+	"ecommerce-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReturnFilter narrows a return listing. Zero values do not filter.
+type ReturnFilter struct {
+	Status  string
+	OrderID uint
+}
+
+// ReturnRepository stores returns with their items and photos
+type ReturnRepository interface {
+	// List returns one page of returns, newest first, with their items and
+	// photos, and the total number of matches
+	List(ctx context.Context, filter ReturnFilter, page Page) ([]models.Return, int64, error)
+	// ListByUser returns the user's returns, newest first, with their items
+	// and photos
+	ListByUser(ctx context.Context, userID uint) ([]models.Return, error)
+	// ListByOrder returns the order's returns, oldest first, with their
+	// items
+	ListByOrder(ctx context.Context, orderID uint) ([]models.Return, error)
+	// Get returns the return with its items and photos
+	Get(ctx context.Context, id uint) (models.Return, error)
+	// GetForUser returns the return with its items and photos if it
+	// belongs to userID
+	GetForUser(ctx context.Context, id, userID uint) (models.Return, error)
+	// Create inserts the return with its Items and Photos
+	Create(ctx context.Context, ret *models.Return) error
+	// UpdateIfStatus applies changes while the return is still in status
+	// and reports whether it was
+	UpdateIfStatus(ctx context.Context, id uint, status string, changes map[string]interface{}) (bool, error)
+	// SetDisposition records what happened to a returned item
+	SetDisposition(ctx context.Context, itemID uint, disposition string) error
+}
+
+type gormReturns struct {
+	db *gorm.DB
+}
+
+// NewReturnRepository returns a ReturnRepository backed by db
+func NewReturnRepository(db *gorm.DB) ReturnRepository {
+	return &gormReturns{db: db}
+}
+
+func (r *gormReturns) List(ctx context.Context, filter ReturnFilter, page Page) ([]models.Return, int64, error) {
+	query := conn(ctx, r.db).Model(&models.Return{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.OrderID != 0 {
+		query = query.Where("order_id = ?", filter.OrderID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+
+	var returns []models.Return
+	err := query.Preload("Items").Preload("Photos").
+		Order("created_at DESC, id DESC").Offset(page.Offset()).Limit(page.Limit).
+		Find(&returns).Error
+	if err != nil {
+		return nil, 0, translate(err)
+	}
+	return returns, total, nil
+}
+
+func (r *gormReturns) ListByUser(ctx context.Context, userID uint) ([]models.Return, error) {
+	var returns []models.Return
+	err := conn(ctx, r.db).Preload("Items").Preload("Photos").
+		Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&returns).Error
+	return returns, translate(err)
+}
+
+func (r *gormReturns) ListByOrder(ctx context.Context, orderID uint) ([]models.Return, error) {
+	var returns []models.Return
+	err := conn(ctx, r.db).Preload("Items").
+		Where("order_id = ?", orderID).Order("created_at, id").Find(&returns).Error
+	return returns, translate(err)
+}
+
+func (r *gormReturns) Get(ctx context.Context, id uint) (models.Return, error) {
+	var ret models.Return
+	err := conn(ctx, r.db).Preload("Items").Preload("Photos").First(&ret, id).Error
+	return ret, translate(err)
+}
+
+func (r *gormReturns) GetForUser(ctx context.Context, id, userID uint) (models.Return, error) {
+	var ret models.Return
+	err := conn(ctx, r.db).Preload("Items").Preload("Photos").
+		Where("id = ? AND user_id = ?", id, userID).First(&ret).Error
+	return ret, translate(err)
+}
+
+func (r *gormReturns) Create(ctx context.Context, ret *models.Return) error {
+	db := conn(ctx, r.db)
+	if err := db.Omit(clause.Associations).Create(ret).Error; err != nil {
+		return translate(err)
+	}
+	for i := range ret.Items {
+		ret.Items[i].ReturnID = ret.ID
+	}
+	for i := range ret.Photos {
+		ret.Photos[i].ReturnID = ret.ID
+	}
+	if len(ret.Items) > 0 {
+		if err := db.Create(&ret.Items).Error; err != nil {
+			return translate(err)
+		}
+	}
+	if len(ret.Photos) > 0 {
+		if err := db.Create(&ret.Photos).Error; err != nil {
+			return translate(err)
+		}
+	}
+	return nil
+}
+
+func (r *gormReturns) UpdateIfStatus(ctx context.Context, id uint, status string, changes map[string]interface{}) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.Return{}).Where("id = ? AND status = ?", id, status).Updates(changes)
+	if result.Error != nil {
+		return false, translate(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *gormReturns) SetDisposition(ctx context.Context, itemID uint, disposition string) error {
+	err := conn(ctx, r.db).Model(&models.ReturnItem{}).Where("id = ?", itemID).
+		Update("disposition", disposition).Error
+	return translate(err)
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ecommerce-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShipmentRepository stores shipments and the order items they carry
+type ShipmentRepository interface {
+	// ListByOrder returns the order's shipments with their items, oldest
+	// first
+	ListByOrder(ctx context.Context, orderID uint) ([]models.Shipment, error)
+	// Get returns the shipment with its items if it belongs to orderID
+	Get(ctx context.Context, orderID, id uint) (models.Shipment, error)
+	// Create inserts the shipment and its Items
+	Create(ctx context.Context, shipment *models.Shipment) error
+	// MarkDelivered records the delivery time unless one is already set, and
+	// reports whether it did
+	MarkDelivered(ctx context.Context, id uint, at time.Time) (bool, error)
+	// ListOpen returns up to limit undelivered shipments with a tracking
+	// number from the given carriers that were not tracked since before,
+	// least recently tracked first
+	ListOpen(ctx context.Context, carriers []string, before time.Time, limit int) ([]models.Shipment, error)
+	// FindByTracking returns the latest shipment with the carrier's tracking
+	// number
+	FindByTracking(ctx context.Context, carrier, trackingNumber string) (models.Shipment, error)
+	// MarkTracked records when the carrier was last asked about the shipment
+	MarkTracked(ctx context.Context, id uint, at time.Time) error
+	// AddEvents stores the tracking events not stored already and returns
+	// how many were new
+	AddEvents(ctx context.Context, events []models.TrackingEvent) (int, error)
+}
+
+type gormShipments struct {
+	db *gorm.DB
+}
+
+// NewShipmentRepository returns a ShipmentRepository backed by db
+func NewShipmentRepository(db *gorm.DB) ShipmentRepository {
+	return &gormShipments{db: db}
+}
+
+func (r *gormShipments) ListByOrder(ctx context.Context, orderID uint) ([]models.Shipment, error) {
+	var shipments []models.Shipment
+	err := conn(ctx, r.db).Preload("Items").Preload("Events", orderedEvents).
+		Where("order_id = ?", orderID).Order("shipped_at, id").Find(&shipments).Error
+	return shipments, translate(err)
+}
+
+func (r *gormShipments) Get(ctx context.Context, orderID, id uint) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := conn(ctx, r.db).Preload("Items").Preload("Events", orderedEvents).
+		Where("id = ? AND order_id = ?", id, orderID).First(&shipment).Error
+	return shipment, translate(err)
+}
+
+func (r *gormShipments) Create(ctx context.Context, shipment *models.Shipment) error {
+	db := conn(ctx, r.db)
+	if err := db.Omit(clause.Associations).Create(shipment).Error; err != nil {
+		return translate(err)
+	}
+	if len(shipment.Items) == 0 {
+		return nil
+	}
+	for i := range shipment.Items {
+		shipment.Items[i].ShipmentID = shipment.ID
+	}
+	return translate(db.Create(&shipment.Items).Error)
+}
+
+func (r *gormShipments) MarkDelivered(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := conn(ctx, r.db).Model(&models.Shipment{}).
+		Where("id = ? AND delivered_at IS NULL", id).Update("delivered_at", at)
+	if result.Error != nil {
+		return false, translate(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *gormShipments) ListOpen(ctx context.Context, carriers []string, before time.Time, limit int) ([]models.Shipment, error) {
+	var shipments []models.Shipment
+	if len(carriers) == 0 {
+		return shipments, nil
+	}
+	err := conn(ctx, r.db).
+		Where("delivered_at IS NULL AND tracking_number <> '' AND carrier IN ?", carriers).
+		Where("tracked_at IS NULL OR tracked_at < ?", before).
+		Order("tracked_at NULLS FIRST, id").Limit(limit).Find(&shipments).Error
+	return shipments, translate(err)
+}
+
+func (r *gormShipments) FindByTracking(ctx context.Context, carrier, trackingNumber string) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := conn(ctx, r.db).
+		Where("carrier = ? AND tracking_number = ?", carrier, trackingNumber).
+		Order("shipped_at DESC, id DESC").First(&shipment).Error
+	return shipment, translate(err)
+}
+
+func (r *gormShipments) MarkTracked(ctx context.Context, id uint, at time.Time) error {
+	err := conn(ctx, r.db).Model(&models.Shipment{}).Where("id = ?", id).
+		UpdateColumn("tracked_at", at).Error
+	return translate(err)
+}
+
+func (r *gormShipments) AddEvents(ctx context.Context, events []models.TrackingEvent) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+	result := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(&events)
+	return int(result.RowsAffected), translate(result.Error)
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+
+	"ecommerce-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserFilter narrows a user listing. Zero values do not filter.
+type UserFilter struct {
+	// Search matches the first name, last name or email
+	Search   string
+	Role     string
+	IsActive *bool
+}
+
+// UserRepository stores user accounts. Admin lookups include soft-deleted
+// users; GetByEmail, used for sign-in, does not.
+type UserRepository interface {
+	List(ctx context.Context, filter UserFilter, page Page) ([]models.User, int64, error)
+	Get(ctx context.Context, id uint) (models.User, error)
+	// GetByEmail returns the user with email, ignoring case
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	// GetByClaimToken returns the guest whose claim token hashes to hash
+	GetByClaimToken(ctx context.Context, hash string) (models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	// CreateIfNew creates user unless their email is taken, and reports
+	// whether it did. Unlike Create, a taken email does not abort the
+	// transaction it runs in.
+	CreateIfNew(ctx context.Context, user *models.User) (bool, error)
+	// Update applies changes while the user is still at version expected
+	// and reports whether it was
+	Update(ctx context.Context, id, expected uint, changes map[string]interface{}) (bool, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+// AddressRepository stores customer addresses
+type AddressRepository interface {
+	Create(ctx context.Context, address *models.Address) error
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+// NewUserRepository returns a UserRepository backed by db
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUsers{db: db}
+}
+
+func (r *gormUsers) List(ctx context.Context, filter UserFilter, page Page) ([]models.User, int64, error) {
+	query := conn(ctx, r.db).Model(&models.User{}).Unscoped()
+	if filter.Search != "" {
+		query = query.Where("first_name ILIKE ? OR last_name ILIKE ? OR email ILIKE ?",
+			like(filter.Search), like(filter.Search), like(filter.Search))
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.IsActive != nil {
+		query = query.Where("is_active = ?", *filter.IsActive)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+
+	var users []models.User
+	if err := query.Offset(page.Offset()).Limit(page.Limit).Find(&users).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+	return users, total, nil
+}
+
+func (r *gormUsers) Get(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := conn(ctx, r.db).Unscoped().First(&user, id).Error
+	return user, translate(err)
+}
+
+func (r *gormUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	var user models.User
+	err := conn(ctx, r.db).Where("lower(email) = lower(?)", email).First(&user).Error
+	return user, translate(err)
+}
+
+func (r *gormUsers) GetByClaimToken(ctx context.Context, hash string) (models.User, error) {
+	var user models.User
+	err := conn(ctx, r.db).Where("claim_token_hash = ? AND is_guest", hash).First(&user).Error
+	return user, translate(err)
+}
+
+func (r *gormUsers) Create(ctx context.Context, user *models.User) error {
+	return translate(conn(ctx, r.db).Create(user).Error)
+}
+
+func (r *gormUsers) CreateIfNew(ctx context.Context, user *models.User) (bool, error) {
+	result := conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(user)
+	return result.RowsAffected == 1, translate(result.Error)
+}
+
+func (r *gormUsers) Update(ctx context.Context, id, expected uint, changes map[string]interface{}) (bool, error) {
+	return versionedUpdate(conn(ctx, r.db).Unscoped(), &models.User{}, id, expected, changes)
+}
+
+func (r *gormUsers) Delete(ctx context.Context, id uint) error {
+	return translate(conn(ctx, r.db).Delete(&models.User{}, id).Error)
+}
+
+type gormAddresses struct {
+	db *gorm.DB
+}
+
+// NewAddressRepository returns an AddressRepository backed by db
+func NewAddressRepository(db *gorm.DB) AddressRepository {
+	return &gormAddresses{db: db}
+}
+
+func (r *gormAddresses) Create(ctx context.Context, address *models.Address) error {
+	return translate(conn(ctx, r.db).Omit("User").Create(address).Error)
+}
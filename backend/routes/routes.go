@@ -1,79 +1,148 @@
 package routes
 
 import (
+	"ecommerce-backend/config"
 	"ecommerce-backend/handlers"
+	"ecommerce-backend/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // PublicRoutes handles public routes (no authentication required)
-func PublicRoutes(app fiber.Router) {
+func PublicRoutes(app fiber.Router, h *handlers.Handler, cfg *config.Config) {
+	limits := cfg.RateLimit
+	authLimit := middleware.RateLimitFromConfig(limits, "auth", limits.Auth, limits.AuthBy)
+	catalogLimit := middleware.RateLimitFromConfig(limits, "catalog", limits.Catalog, limits.CatalogBy)
+	guestLimit := middleware.RateLimitFromConfig(limits, "guest", limits.Guest, limits.GuestBy)
+
 	// Authentication routes
-	auth := app.Group("/auth")
-	auth.Post("/register", handlers.Register)
-	auth.Post("/login", handlers.Login)
+	auth := app.Group("/auth", authLimit)
+	auth.Post("/register", h.Register)
+	auth.Post("/login", h.Login)
+	auth.Post("/claim/request", h.RequestClaim)
+	auth.Post("/claim", h.ClaimAccount)
 
 	// Product routes (public access)
-	products := app.Group("/products")
-	products.Get("/", handlers.GetProducts)
-	products.Get("/:id", handlers.GetProduct)
+	products := app.Group("/products", catalogLimit)
+	products.Get("/", h.GetProducts)
+	products.Get("/:id", h.GetProduct)
 
 	// Category routes
-	app.Get("/categories", handlers.GetCategories)
+	app.Get("/categories", catalogLimit, h.GetCategories)
+
+	// Guest checkout, and looking up guest orders with their emailed token
+	guest := app.Group("/guest", guestLimit)
+	guest.Post("/checkout", h.GuestCheckout)
+	guest.Get("/orders", h.LookupGuestOrder)
+
+	// Carrier webhooks, authenticated by their signature
+	app.Post("/webhooks/carriers/:carrier", h.CarrierWebhook)
 }
 
 // ProtectedRoutes handles authenticated routes
-func ProtectedRoutes(app fiber.Router) {
+func ProtectedRoutes(app fiber.Router, h *handlers.Handler, cfg *config.Config) {
+	limits := cfg.RateLimit
+	app.Use(middleware.RateLimitFromConfig(limits, "protected", limits.Protected, limits.ProtectedBy))
+
 	// Auth routes (authenticated)
 	auth := app.Group("/auth")
-	auth.Get("/profile", handlers.GetProfile)
+	auth.Get("/profile", h.GetProfile)
 
 	// Cart routes
 	cart := app.Group("/cart")
-	cart.Get("/", handlers.GetCart)
-	cart.Get("/summary", handlers.GetCartSummary)
-	cart.Post("/add", handlers.AddToCart)
-	cart.Put("/items/:id", handlers.UpdateCartItem)
-	cart.Delete("/items/:id", handlers.RemoveFromCart)
-	cart.Delete("/clear", handlers.ClearCart)
+	cart.Get("/", h.GetCart)
+	cart.Get("/summary", h.GetCartSummary)
+	cart.Post("/add", h.AddToCart)
+	cart.Put("/items/:id", h.UpdateCartItem)
+	cart.Delete("/items/:id", h.RemoveFromCart)
+	cart.Delete("/clear", h.ClearCart)
 
 	// Checkout routes
 	checkout := app.Group("/checkout")
-	checkout.Post("/", handlers.Checkout)
-	checkout.Get("/history", handlers.GetOrderHistory)
-	checkout.Get("/orders/:id", handlers.GetOrderDetails)
-	checkout.Put("/orders/:id/cancel", handlers.CancelOrder)
+	checkout.Post("/", h.Checkout)
+	checkout.Get("/history", h.GetOrderHistory)
+	checkout.Get("/orders/:id", h.GetOrderDetails)
+	checkout.Put("/orders/:id/cancel", h.CancelOrder)
+	checkout.Get("/orders/:id/timeline", h.GetOrderTimeline)
+	checkout.Get("/orders/:id/invoice", h.GetOrderInvoice)
+	checkout.Post("/orders/:id/returns", h.RequestReturn)
+	checkout.Get("/returns", h.GetMyReturns)
+	checkout.Get("/returns/:id", h.GetMyReturn)
 }
 
 // AdminRoutes handles admin-only routes
-func AdminRoutes(app fiber.Router) {
+func AdminRoutes(app fiber.Router, h *handlers.Handler) {
 	// Dashboard
 	dashboard := app.Group("/dashboard")
-	dashboard.Get("/stats", handlers.GetDashboardStats)
-	dashboard.Get("/recent-orders", handlers.GetRecentOrders)
-	dashboard.Get("/top-products", handlers.GetTopProducts)
-	dashboard.Get("/sales-chart", handlers.GetSalesChart)
+	dashboard.Get("/stats", h.GetDashboardStats)
+	dashboard.Get("/recent-orders", h.GetRecentOrders)
+	dashboard.Get("/top-products", h.GetTopProducts)
+	dashboard.Get("/sales-chart", h.GetSalesChart)
+	dashboard.Get("/cache-stats", h.GetCacheStats)
 
 	// Product management
 	products := app.Group("/products")
-	products.Get("/", handlers.GetAdminProducts)
-	products.Post("/", handlers.CreateProduct)
-	products.Put("/:id", handlers.UpdateProduct)
-	products.Delete("/:id", handlers.DeleteProduct)
+	products.Get("/", h.GetAdminProducts)
+	products.Get("/:id", h.GetAdminProduct)
+	products.Post("/", h.CreateProduct)
+	products.Put("/:id", h.UpdateProduct)
+	products.Patch("/:id", h.UpdateProduct)
+	products.Delete("/:id", h.DeleteProduct)
+
+	// Category management
+	categories := app.Group("/categories")
+	categories.Post("/", h.CreateCategory)
+	categories.Put("/:id", h.UpdateCategory)
+	categories.Delete("/:id", h.DeleteCategory)
 
 	// User management
 	users := app.Group("/users")
-	users.Get("/", handlers.GetAdminUsers)
-	users.Get("/:id", handlers.GetAdminUser)
-	users.Post("/", handlers.CreateUser)
-	users.Put("/:id", handlers.UpdateUser)
-	users.Delete("/:id", handlers.DeleteUser)
+	users.Get("/", h.GetAdminUsers)
+	users.Get("/:id", h.GetAdminUser)
+	users.Post("/", h.CreateUser)
+	users.Put("/:id", h.UpdateUser)
+	users.Patch("/:id", h.UpdateUser)
+	users.Delete("/:id", h.DeleteUser)
 
 	// Order management
 	orders := app.Group("/orders")
-	orders.Get("/", handlers.GetAdminOrders)
-	orders.Get("/stats", handlers.GetOrderStats)
-	orders.Get("/:id", handlers.GetAdminOrder)
-	orders.Put("/:id/status", handlers.UpdateOrderStatus)
-	orders.Put("/:id/payment", handlers.UpdatePaymentStatus)
+	orders.Get("/", h.GetAdminOrders)
+	orders.Post("/", h.CreateDraftOrder)
+	orders.Get("/stats", h.GetOrderStats)
+	orders.Get("/invoices", h.PrintInvoices)
+	orders.Get("/packing-slips", h.PrintPackingSlips)
+	orders.Post("/bulk", h.StartBulkOrderJob)
+	orders.Get("/:id", h.GetAdminOrder)
+	orders.Put("/:id", h.EditOrder)
+	orders.Put("/:id/place", h.PlaceDraftOrder)
+	orders.Put("/:id/status", h.UpdateOrderStatus)
+	orders.Put("/:id/payment", h.UpdatePaymentStatus)
+	orders.Get("/:id/shipments", h.GetOrderShipments)
+	orders.Post("/:id/shipments", h.CreateShipment)
+	orders.Put("/:id/shipments/:shipmentId/delivered", h.MarkShipmentDelivered)
+	orders.Get("/:id/timeline", h.GetAdminOrderTimeline)
+	orders.Get("/:id/invoice", h.GetAdminOrderInvoice)
+	orders.Get("/:id/packing-slip", h.GetOrderPackingSlip)
+	orders.Get("/:id/refunds", h.GetOrderRefunds)
+	orders.Post("/:id/refunds", h.CreateRefund)
+
+	// Return management
+	returns := app.Group("/returns")
+	returns.Get("/", h.GetAdminReturns)
+	returns.Get("/:id", h.GetAdminReturn)
+	returns.Put("/:id/approve", h.ApproveReturn)
+	returns.Put("/:id/reject", h.RejectReturn)
+	returns.Put("/:id/receive", h.ReceiveReturn)
+	returns.Put("/:id/refund", h.RefundReturn)
+
+	// Refunds
+	refunds := app.Group("/refunds")
+	refunds.Get("/", h.GetAdminRefunds)
+	refunds.Get("/:id", h.GetAdminRefund)
+
+	// Bulk jobs
+	jobs := app.Group("/jobs")
+	jobs.Get("/", h.GetBulkJobs)
+	jobs.Get("/:id", h.GetBulkJob)
+	jobs.Get("/:id/document", h.GetBulkJobDocument)
 }
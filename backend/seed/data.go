@@ -0,0 +1,108 @@
+package seed
+
+type categorySpec struct {
+	Name        string
+	Description string
+	// MinPrice and MaxPrice bound generated product prices, in rupiah
+	MinPrice int64
+	MaxPrice int64
+	Nouns    []string
+}
+
+// baseCategories are created by every profile
+var baseCategories = []categorySpec{
+	{"Electronics", "Electronic devices and gadgets", 150000, 25000000,
+		[]string{"Laptop", "Headphones", "Smart Watch", "Tablet", "Speaker", "Power Bank", "Keyboard", "Monitor", "Webcam", "Router"}},
+	{"Clothing", "Fashion and apparel", 50000, 1500000,
+		[]string{"T-Shirt", "Jacket", "Jeans", "Batik Shirt", "Hoodie", "Dress", "Polo Shirt", "Cardigan", "Sarong", "Chinos"}},
+	{"Books", "Books and literature", 40000, 600000,
+		[]string{"Novel", "Cookbook", "Programming Guide", "Biography", "Comic", "Atlas", "Dictionary", "Poetry Collection", "Textbook", "Travel Guide"}},
+	{"Home & Garden", "Home improvement and garden supplies", 30000, 5000000,
+		[]string{"Garden Tool Set", "Lamp", "Cushion", "Plant Pot", "Rice Cooker", "Blender", "Curtain", "Shelf", "Hose", "Cookware Set"}},
+	{"Sports", "Sports equipment and accessories", 40000, 4000000,
+		[]string{"Running Shoes", "Yoga Mat", "Badminton Racket", "Football", "Dumbbell Set", "Cycling Helmet", "Jump Rope", "Water Bottle", "Gym Bag", "Futsal Shoes"}},
+}
+
+// extraCategories are added by the load-test profile
+var extraCategories = []categorySpec{
+	{"Beauty", "Skincare, makeup and personal care", 25000, 1200000,
+		[]string{"Face Serum", "Sunscreen", "Lipstick", "Moisturizer", "Shampoo", "Perfume", "Face Wash", "Hair Oil"}},
+	{"Toys", "Toys and games for all ages", 30000, 2000000,
+		[]string{"Building Blocks", "Puzzle", "Board Game", "Action Figure", "Doll", "RC Car", "Plush Toy", "Kite"}},
+	{"Groceries", "Pantry staples and snacks", 5000, 300000,
+		[]string{"Coffee Beans", "Sambal", "Instant Noodles", "Rice 5kg", "Tea", "Palm Sugar", "Crackers", "Cooking Oil"}},
+	{"Automotive", "Car and motorcycle accessories", 25000, 3500000,
+		[]string{"Helmet", "Car Vacuum", "Phone Mount", "Motor Oil", "Seat Cover", "Tire Inflator", "Dash Cam", "Rain Cover"}},
+	{"Health", "Health and wellness products", 15000, 2500000,
+		[]string{"Vitamin C", "Thermometer", "Blood Pressure Monitor", "Face Mask", "Herbal Jamu", "First Aid Kit", "Massage Gun", "Protein Powder"}},
+	{"Office", "Stationery and office supplies", 5000, 4000000,
+		[]string{"Notebook", "Ballpoint Pens", "Desk Organizer", "Office Chair", "Stapler", "Whiteboard", "Printer Paper", "Desk Lamp"}},
+	{"Baby", "Baby care and nursery", 20000, 3000000,
+		[]string{"Diapers", "Baby Carrier", "Feeding Bottle", "Stroller", "Baby Lotion", "Crib Sheet", "Teether", "Baby Monitor"}},
+	{"Pets", "Pet food and accessories", 15000, 1500000,
+		[]string{"Cat Food", "Dog Leash", "Pet Bed", "Litter Box", "Aquarium Filter", "Bird Seed", "Chew Toy", "Pet Shampoo"}},
+	{"Music", "Instruments and audio gear", 50000, 15000000,
+		[]string{"Acoustic Guitar", "Ukulele", "Keyboard Piano", "Drum Sticks", "Microphone", "Guitar Strings", "Angklung", "Audio Interface"}},
+	{"Jewelry", "Jewelry and accessories", 50000, 10000000,
+		[]string{"Silver Ring", "Necklace", "Bracelet", "Earrings", "Wristwatch", "Brooch", "Anklet", "Cufflinks"}},
+}
+
+var productAdjectives = []string{
+	"Classic", "Premium", "Compact", "Ultra", "Eco", "Pro", "Deluxe", "Essential",
+	"Smart", "Lite", "Max", "Vintage", "Modern", "Travel", "Signature", "Everyday",
+}
+
+var productBrands = []string{
+	"Nusantara", "Garuda", "Merapi", "Bromo", "Komodo", "Rinjani", "Lombok",
+	"Toba", "Cendana", "Mahoni", "Kenari", "Jatayu", "Sakura", "Nimbus",
+}
+
+var firstNames = []string{
+	"Adi", "Agus", "Andi", "Ayu", "Bayu", "Budi", "Citra", "Dewi", "Dian", "Eka",
+	"Fajar", "Fitri", "Gilang", "Hana", "Hendra", "Indah", "Intan", "Joko", "Kartika", "Lestari",
+	"Maya", "Nanda", "Nur", "Putri", "Rani", "Reza", "Rizki", "Sari", "Siti", "Taufik",
+	"Teguh", "Tri", "Wahyu", "Wati", "Yoga", "Yusuf", "Zahra", "Arif", "Bima", "Laras",
+}
+
+var lastNames = []string{
+	"Pratama", "Saputra", "Wijaya", "Hidayat", "Santoso", "Kusuma", "Nugroho", "Lestari",
+	"Setiawan", "Wibowo", "Susanto", "Halim", "Gunawan", "Siregar", "Nasution", "Simanjuntak",
+	"Harahap", "Putri", "Rahmawati", "Permana", "Sihombing", "Tanjung", "Suryadi", "Utomo",
+}
+
+var emailDomains = []string{"example.com", "example.net", "example.org", "mail.example.com"}
+
+type city struct {
+	Name       string
+	Province   string
+	PostalCode string
+}
+
+var cities = []city{
+	{"Jakarta Selatan", "DKI Jakarta", "12"},
+	{"Jakarta Barat", "DKI Jakarta", "11"},
+	{"Bandung", "Jawa Barat", "40"},
+	{"Bekasi", "Jawa Barat", "17"},
+	{"Bogor", "Jawa Barat", "16"},
+	{"Surabaya", "Jawa Timur", "60"},
+	{"Malang", "Jawa Timur", "65"},
+	{"Semarang", "Jawa Tengah", "50"},
+	{"Yogyakarta", "DI Yogyakarta", "55"},
+	{"Denpasar", "Bali", "80"},
+	{"Medan", "Sumatera Utara", "20"},
+	{"Palembang", "Sumatera Selatan", "30"},
+	{"Makassar", "Sulawesi Selatan", "90"},
+	{"Balikpapan", "Kalimantan Timur", "76"},
+	{"Tangerang", "Banten", "15"},
+}
+
+var streets = []string{
+	"Jl. Sudirman", "Jl. Thamrin", "Jl. Gatot Subroto", "Jl. Diponegoro", "Jl. Ahmad Yani",
+	"Jl. Pahlawan", "Jl. Merdeka", "Jl. Gajah Mada", "Jl. Hayam Wuruk", "Jl. Kartini",
+	"Jl. Pemuda", "Jl. Veteran", "Jl. Asia Afrika", "Jl. Malioboro", "Jl. Dago",
+}
+
+var paymentMethods = []string{"bank_transfer", "bank_transfer", "cod"}
+
+// carriers are the couriers generated shipments are handed to
+var carriers = []string{"jne", "jne", "jnt", "sicepat"}
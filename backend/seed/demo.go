@@ -0,0 +1,256 @@
+package seed
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"ecommerce-backend/models"
+)
+
+type demoProduct struct {
+	models.Product
+	Category string
+}
+
+var demoCustomers = []models.User{
+	{FirstName: "John", LastName: "Doe", Email: "john.doe@example.com", Phone: "+62812345678"},
+	{FirstName: "Jane", LastName: "Smith", Email: "jane.smith@example.com", Phone: "+62887654321"},
+	{FirstName: "Budi", LastName: "Santoso", Email: "budi.santoso@example.com", Phone: "+62813579246"},
+}
+
+var demoProducts = []demoProduct{
+	{models.Product{Name: "Laptop Pro 15", Description: "High-performance laptop with 16GB RAM and 512GB SSD", Price: 15999000, Stock: 50,
+		Image: "https://images.pexels.com/photos/205421/pexels-photo-205421.jpeg?w=300&h=300&fit=crop&fm=webp&q=70", SKU: "LAPTOP-PRO-15"}, "Electronics"},
+	{models.Product{Name: "Wireless Headphones", Description: "Premium noise-cancelling wireless headphones", Price: 2499000, Stock: 100,
+		Image: "https://images.pexels.com/photos/610945/pexels-photo-610945.jpeg?w=300&h=300&fit=crop&fm=webp&q=70", SKU: "HEADPHONE-WL-001"}, "Electronics"},
+	{models.Product{Name: "Smart Watch Ultra", Description: "Advanced fitness tracking and health monitoring smartwatch", Price: 4999000, Stock: 75,
+		Image: "https://images.pexels.com/photos/437037/pexels-photo-437037.jpeg?w=300&h=300&fit=crop&fm=webp&q=70", SKU: "WATCH-ULTRA-001"}, "Electronics"},
+	{models.Product{Name: "Designer T-Shirt", Description: "Premium cotton designer t-shirt", Price: 299000, Stock: 200,
+		Image: "https://images.pexels.com/photos/8532616/pexels-photo-8532616.jpeg?w=300&h=300&fit=crop&fm=webp&q=70", SKU: "TSHIRT-DSG-001"}, "Clothing"},
+	{models.Product{Name: "Running Shoes", Description: "Professional running shoes with advanced cushioning", Price: 1299000, Stock: 150,
+		Image: "https://images.pexels.com/photos/1124466/pexels-photo-1124466.jpeg?w=300&h=300&fit=crop&fm=webp&q=70", SKU: "SHOES-RUN-001"}, "Sports"},
+	{models.Product{Name: "Programming Book", Description: "Complete guide to modern programming", Price: 450000, Stock: 80,
+		Image: "https://images.pexels.com/photos/1181671/pexels-photo-1181671.jpeg?w=300&h=300&fit=crop&fm=webp&q=70", SKU: "BOOK-PROG-001"}, "Books"},
+	{models.Product{Name: "Garden Tool Set", Description: "Complete garden tool set with 10 essential tools", Price: 899000, Stock: 60,
+		Image: "https://images.pexels.com/photos/5529583/pexels-photo-5529583.jpeg?w=300&h=300&fit=crop&fm=webp&q=70", SKU: "TOOLS-GDN-001"}, "Home & Garden"},
+	{models.Product{Name: "Yoga Mat Premium", Description: "Extra thick yoga mat with carrying strap", Price: 350000, Stock: 120,
+		Image: "https://images.pexels.com/photos/4327014/pexels-photo-4327014.jpeg?w=300&h=300&fit=crop&fm=webp&q=70", SKU: "YOGA-MAT-001"}, "Sports"},
+}
+
+// demoOrders reference customers by email and products by SKU
+var demoOrders = []struct {
+	Email    string
+	Age      time.Duration
+	Status   string
+	Payment  string
+	Paid     bool
+	Tracking string
+	Notes    string
+	Items    map[string]int
+}{
+	{"john.doe@example.com", 31 * time.Hour, "delivered", "bank_transfer", true, "TRK123456789", "Delivered successfully",
+		map[string]int{"LAPTOP-PRO-15": 1}},
+	{"jane.smith@example.com", 14*time.Hour + 30*time.Minute, "shipped", "cod", false, "TRK987654321", "Leave at the front desk",
+		map[string]int{"HEADPHONE-WL-001": 1, "YOGA-MAT-001": 1}},
+	{"budi.santoso@example.com", 9*time.Hour + 45*time.Minute, "pending", "bank_transfer", false, "", "",
+		map[string]int{"TOOLS-GDN-001": 1}},
+}
+
+// seedDemo creates the minimal profile plus a few customers, products and
+// orders to click around in
+func seedDemo(s *seeder) error {
+	if err := seedMinimal(s); err != nil {
+		return err
+	}
+
+	categories, err := s.categories(baseCategories)
+	if err != nil {
+		return err
+	}
+	categoryIDs := make(map[string]uint, len(categories))
+	for _, c := range categories {
+		categoryIDs[c.Name] = c.ID
+	}
+
+	customers := make(map[string]models.User, len(demoCustomers))
+	for _, spec := range demoCustomers {
+		user := spec
+		user.Password = customerPasswordHash
+		user.Role = "user"
+		user.IsActive = true
+		user.CreatedAt = s.opts.Until.AddDate(0, -1, 0)
+		if err := s.db.Where(models.User{Email: spec.Email}).FirstOrCreate(&user).Error; err != nil {
+			return fmt.Errorf("create user %s: %w", spec.Email, err)
+		}
+		customers[user.Email] = user
+	}
+
+	products := make(map[string]models.Product, len(demoProducts))
+	for _, spec := range demoProducts {
+		product := spec.Product
+		product.CategoryID = categoryIDs[spec.Category]
+		product.IsActive = true
+		if err := s.db.Where(models.Product{SKU: spec.SKU}).FirstOrCreate(&product).Error; err != nil {
+			return fmt.Errorf("create product %s: %w", spec.SKU, err)
+		}
+		products[product.SKU] = product
+	}
+
+	created := 0
+	for i, spec := range demoOrders {
+		placedAt := s.opts.Until.Add(-spec.Age)
+		orderNumber := fmt.Sprintf("ORD-%s-DEMO%04d", placedAt.Format("20060102"), i+1)
+
+		var existing int64
+		if err := s.db.Model(&models.Order{}).Where("order_number = ?", orderNumber).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			continue
+		}
+
+		var items []models.OrderItem
+		var subtotal int64
+		for _, sku := range sortedKeys(spec.Items) {
+			product := products[sku]
+			quantity := spec.Items[sku]
+			items = append(items, newOrderItem(product, quantity, placedAt))
+			subtotal += product.Price * int64(quantity)
+		}
+
+		customer := customers[spec.Email]
+		order := newOrder(customer.ID, orderNumber, subtotal, placedAt)
+		order.Status = spec.Status
+		order.PaymentMethod = spec.Payment
+		if spec.Paid {
+			order.PaymentStatus = "paid"
+		}
+		order.TrackingNumber = spec.Tracking
+		order.Notes = spec.Notes
+		order.ShippingAddress = formatAddress(s.address(customer.ID))
+		order.OrderItems = items
+
+		if err := s.db.Create(&order).Error; err != nil {
+			return fmt.Errorf("create order %s: %w", orderNumber, err)
+		}
+		if shipment, ok := shipmentFor(order, "jne", order.UpdatedAt); ok {
+			if err := s.db.Create(&shipment).Error; err != nil {
+				return fmt.Errorf("create shipment for %s: %w", orderNumber, err)
+			}
+		}
+		created++
+	}
+	if created > 0 {
+		slog.InfoContext(s.ctx, "Demo orders created", "count", created)
+	}
+	return s.issueInvoices()
+}
+
+// shipmentFor returns a single shipment carrying every item of an order
+// that is shipped or delivered, so its status follows from its shipments.
+// A delivered order's shipment arrived at its last update.
+func shipmentFor(order models.Order, carrier string, shippedAt time.Time) (models.Shipment, bool) {
+	if order.Status != "shipped" && order.Status != "delivered" {
+		return models.Shipment{}, false
+	}
+	shipment := models.Shipment{
+		OrderID:        order.ID,
+		Carrier:        carrier,
+		Service:        "REG",
+		TrackingNumber: order.TrackingNumber,
+		ShippedAt:      shippedAt,
+		CreatedAt:      shippedAt,
+		UpdatedAt:      order.UpdatedAt,
+	}
+	if order.Status == "delivered" {
+		deliveredAt := order.UpdatedAt
+		shipment.DeliveredAt = &deliveredAt
+	}
+	for _, item := range order.OrderItems {
+		shipment.Items = append(shipment.Items, models.ShipmentItem{
+			OrderItemID: item.ID,
+			Quantity:    item.Quantity,
+			CreatedAt:   shippedAt,
+		})
+	}
+	return shipment, true
+}
+
+// newOrder returns an order with totals computed the way checkout does:
+// 10% tax and a flat 10,000 shipping fee
+func newOrder(userID uint, orderNumber string, subtotal int64, placedAt time.Time) models.Order {
+	tax := subtotal * 10 / 100
+	shipping := int64(10000)
+	return models.Order{
+		UserID:        userID,
+		OrderNumber:   orderNumber,
+		Status:        "pending",
+		Subtotal:      subtotal,
+		Tax:           tax,
+		ShippingCost:  shipping,
+		TotalAmount:   subtotal + tax + shipping,
+		PaymentStatus: "unpaid",
+		CreatedAt:     placedAt,
+		UpdatedAt:     placedAt,
+	}
+}
+
+// newOrderItem returns quantity of product as ordered at placedAt, with the
+// product's details frozen on it and 10% tax
+func newOrderItem(product models.Product, quantity int, placedAt time.Time) models.OrderItem {
+	total := product.Price * int64(quantity)
+	return models.OrderItem{
+		ProductID:    product.ID,
+		ProductName:  product.Name,
+		ProductSKU:   product.SKU,
+		ProductImage: product.Image,
+		Weight:       product.Weight,
+		Quantity:     quantity,
+		UnitPrice:    product.Price,
+		TotalPrice:   total,
+		TaxPercent:   10,
+		TaxAmount:    total * 10 / 100,
+		CreatedAt:    placedAt,
+		UpdatedAt:    placedAt,
+	}
+}
+
+// issueInvoices numbers the invoices of paid orders that have none, in the
+// order they were last updated, after the numbers already issued each year.
+// Seeded orders are paid by their last update, so that is when they are
+// invoiced.
+func (s *seeder) issueInvoices() error {
+	numbered := s.db.Exec(`
+		WITH unissued AS (
+			SELECT id, updated_at, EXTRACT(YEAR FROM updated_at)::INT AS year,
+			       ROW_NUMBER() OVER (PARTITION BY EXTRACT(YEAR FROM updated_at) ORDER BY updated_at, id) AS n
+			FROM orders
+			WHERE payment_status = 'paid' AND invoice_number IS NULL
+		)
+		UPDATE orders
+		SET invoice_number = 'INV-' || unissued.year || '-' || LPAD((unissued.n + COALESCE(seq.last_number, 0))::TEXT, 6, '0'),
+		    invoiced_at = unissued.updated_at
+		FROM unissued LEFT JOIN invoice_sequences seq ON seq.year = unissued.year
+		WHERE orders.id = unissued.id`)
+	if numbered.Error != nil {
+		return fmt.Errorf("issue invoices: %w", numbered.Error)
+	}
+	if numbered.RowsAffected == 0 {
+		return nil
+	}
+
+	// Numbers run without gaps, so each year's last number is its count
+	err := s.db.Exec(`
+		INSERT INTO invoice_sequences (year, last_number)
+		SELECT SUBSTRING(invoice_number FROM 5 FOR 4)::INT, COUNT(*)
+		FROM orders
+		WHERE invoice_number IS NOT NULL
+		GROUP BY 1
+		ON CONFLICT (year) DO UPDATE SET last_number = EXCLUDED.last_number`).Error
+	if err != nil {
+		return fmt.Errorf("update invoice sequences: %w", err)
+	}
+	slog.InfoContext(s.ctx, "Invoices issued", "count", numbered.RowsAffected)
+	return nil
+}
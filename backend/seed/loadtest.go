@@ -0,0 +1,299 @@
+package seed
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"ecommerce-backend/models"
+)
+
+// batchSize is the number of rows per INSERT for bulk profiles
+const batchSize = 500
+
+// loadTestSKUPrefix marks generated products, and lets a second run detect
+// that the data is already there
+const loadTestSKUPrefix = "LT-"
+
+// seedLoadTest creates the minimal profile plus opts.Users customers with an
+// address each, opts.Products products across every category, active carts
+// for about a third of the customers, and opts.Orders orders spread over the
+// last opts.Months months
+func seedLoadTest(s *seeder) error {
+	if err := seedMinimal(s); err != nil {
+		return err
+	}
+
+	var existing int64
+	if err := s.db.Model(&models.Product{}).Where("sku LIKE ?", loadTestSKUPrefix+"%").Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		slog.InfoContext(s.ctx, "Load-test data already present, skipping", "products", existing)
+		return nil
+	}
+
+	specs := append(append([]categorySpec{}, baseCategories...), extraCategories...)
+	categories, err := s.categories(specs)
+	if err != nil {
+		return err
+	}
+
+	from := s.opts.Until.AddDate(0, -s.opts.Months, 0)
+
+	users, err := s.loadTestUsers(from)
+	if err != nil {
+		return err
+	}
+	products, err := s.loadTestProducts(specs, categories, from)
+	if err != nil {
+		return err
+	}
+	if err := s.loadTestCarts(users, products); err != nil {
+		return err
+	}
+	if err := s.loadTestOrders(users, products, from); err != nil {
+		return err
+	}
+	return s.issueInvoices()
+}
+
+func (s *seeder) loadTestUsers(from time.Time) ([]models.User, error) {
+	users := make([]models.User, s.opts.Users)
+	for i := range users {
+		first, last := pick(s.rng, firstNames), pick(s.rng, lastNames)
+		// Accounts predate the order history by up to a month
+		joined := s.between(from.AddDate(0, -1, 0), s.opts.Until)
+		users[i] = models.User{
+			FirstName: first,
+			LastName:  last,
+			Email:     fmt.Sprintf("%s.%s.%d@%s", strings.ToLower(first), strings.ToLower(last), i+1, pick(s.rng, emailDomains)),
+			Password:  customerPasswordHash,
+			Phone:     fmt.Sprintf("+628%02d%07d", 11+s.rng.Intn(89), s.rng.Intn(10000000)),
+			Role:      "user",
+			IsActive:  true,
+			CreatedAt: joined,
+			UpdatedAt: joined,
+		}
+	}
+	if err := s.db.CreateInBatches(&users, batchSize).Error; err != nil {
+		return nil, fmt.Errorf("create users: %w", err)
+	}
+
+	addresses := make([]models.Address, len(users))
+	for i, user := range users {
+		addresses[i] = s.address(user.ID)
+		addresses[i].IsDefault = true
+		addresses[i].CreatedAt = user.CreatedAt
+		addresses[i].UpdatedAt = user.CreatedAt
+	}
+	if err := s.db.CreateInBatches(&addresses, batchSize).Error; err != nil {
+		return nil, fmt.Errorf("create addresses: %w", err)
+	}
+
+	slog.InfoContext(s.ctx, "Users created", "count", len(users))
+	return users, nil
+}
+
+func (s *seeder) loadTestProducts(specs []categorySpec, categories []models.Category, from time.Time) ([]models.Product, error) {
+	products := make([]models.Product, s.opts.Products)
+	for i := range products {
+		c := s.rng.Intn(len(specs))
+		spec := specs[c]
+		noun := pick(s.rng, spec.Nouns)
+		// Round to the nearest thousand rupiah, the way shops price things
+		price := (spec.MinPrice + s.rng.Int63n(spec.MaxPrice-spec.MinPrice)) / 1000 * 1000
+		if price == 0 {
+			price = 1000
+		}
+		added := s.between(from.AddDate(0, -1, 0), from)
+		products[i] = models.Product{
+			Name:        fmt.Sprintf("%s %s %s", pick(s.rng, productBrands), pick(s.rng, productAdjectives), noun),
+			Description: fmt.Sprintf("%s %s for everyday use.", pick(s.rng, productAdjectives), strings.ToLower(noun)),
+			Price:       price,
+			Stock:       s.rng.Intn(500),
+			CategoryID:  categories[c].ID,
+			SKU:         fmt.Sprintf("%s%06d", loadTestSKUPrefix, i+1),
+			Weight:      float64(1+s.rng.Intn(50)) / 10,
+			IsActive:    true,
+			CreatedAt:   added,
+			UpdatedAt:   added,
+		}
+	}
+	if err := s.db.CreateInBatches(&products, batchSize).Error; err != nil {
+		return nil, fmt.Errorf("create products: %w", err)
+	}
+	slog.InfoContext(s.ctx, "Products created", "count", len(products))
+	return products, nil
+}
+
+func (s *seeder) loadTestCarts(users []models.User, products []models.Product) error {
+	var carts []models.Cart
+	var contents [][]models.CartItem
+	for _, user := range users {
+		if s.rng.Intn(3) != 0 {
+			continue
+		}
+		updated := s.between(user.CreatedAt, s.opts.Until)
+		carts = append(carts, models.Cart{UserID: user.ID, IsActive: true, CreatedAt: updated, UpdatedAt: updated})
+
+		basket := s.basket(products)
+		var items []models.CartItem
+		for _, productID := range sortedKeys(basket) {
+			items = append(items, models.CartItem{ProductID: productID, Quantity: basket[productID], CreatedAt: updated, UpdatedAt: updated})
+		}
+		contents = append(contents, items)
+	}
+	if len(carts) == 0 {
+		return nil
+	}
+	if err := s.db.CreateInBatches(&carts, batchSize).Error; err != nil {
+		return fmt.Errorf("create carts: %w", err)
+	}
+
+	var items []models.CartItem
+	for i, cart := range carts {
+		for _, item := range contents[i] {
+			item.CartID = cart.ID
+			items = append(items, item)
+		}
+	}
+	if err := s.db.CreateInBatches(&items, batchSize).Error; err != nil {
+		return fmt.Errorf("create cart items: %w", err)
+	}
+	slog.InfoContext(s.ctx, "Carts created", "count", len(carts), "items", len(items))
+	return nil
+}
+
+func (s *seeder) loadTestOrders(users []models.User, products []models.Product, from time.Time) error {
+	byID := make(map[uint]models.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	orders := make([]models.Order, s.opts.Orders)
+	baskets := make([]map[uint]int, s.opts.Orders)
+	for i := range orders {
+		user := users[s.rng.Intn(len(users))]
+		start := from
+		if user.CreatedAt.After(start) {
+			start = user.CreatedAt
+		}
+		placedAt := s.between(start, s.opts.Until)
+
+		basket := s.basket(products)
+		var subtotal int64
+		for productID, quantity := range basket {
+			subtotal += byID[productID].Price * int64(quantity)
+		}
+
+		order := newOrder(user.ID, fmt.Sprintf("ORD-%s-LT%07d", placedAt.Format("20060102"), i+1), subtotal, placedAt)
+		order.PaymentMethod = pick(s.rng, paymentMethods)
+		order.ShippingAddress = formatAddress(s.address(user.ID))
+		s.progress(&order, s.opts.Until.Sub(placedAt))
+
+		orders[i] = order
+		baskets[i] = basket
+	}
+	if err := s.db.CreateInBatches(&orders, batchSize).Error; err != nil {
+		return fmt.Errorf("create orders: %w", err)
+	}
+
+	items := make([]models.OrderItem, 0, len(orders)*2)
+	for i, order := range orders {
+		for _, productID := range sortedKeys(baskets[i]) {
+			item := newOrderItem(byID[productID], baskets[i][productID], order.CreatedAt)
+			item.OrderID = order.ID
+			items = append(items, item)
+		}
+	}
+	if err := s.db.CreateInBatches(&items, batchSize).Error; err != nil {
+		return fmt.Errorf("create order items: %w", err)
+	}
+
+	itemsByOrder := make(map[uint][]models.OrderItem, len(orders))
+	for _, item := range items {
+		itemsByOrder[item.OrderID] = append(itemsByOrder[item.OrderID], item)
+	}
+	var shipments []models.Shipment
+	for _, order := range orders {
+		order.OrderItems = itemsByOrder[order.ID]
+		shippedAt := order.UpdatedAt
+		if order.Status == "delivered" {
+			shippedAt = s.between(order.CreatedAt, order.UpdatedAt)
+		}
+		if shipment, ok := shipmentFor(order, pick(s.rng, carriers), shippedAt); ok {
+			shipments = append(shipments, shipment)
+		}
+	}
+	if len(shipments) > 0 {
+		if err := s.db.CreateInBatches(&shipments, batchSize).Error; err != nil {
+			return fmt.Errorf("create shipments: %w", err)
+		}
+	}
+	slog.InfoContext(s.ctx, "Orders created", "count", len(orders), "items", len(items), "shipments", len(shipments))
+	return nil
+}
+
+// progress moves an order along its lifecycle according to its age: old
+// orders are mostly delivered, recent ones still pending or in transit, and
+// a few are cancelled along the way
+func (s *seeder) progress(order *models.Order, age time.Duration) {
+	const day = 24 * time.Hour
+
+	if s.rng.Intn(100) < 6 {
+		order.Status = "cancelled"
+		order.UpdatedAt = order.CreatedAt.Add(time.Duration(1+s.rng.Intn(48)) * time.Hour)
+		return
+	}
+
+	switch {
+	case age < 2*time.Hour:
+		return
+	case age < day:
+		order.Status = "processing"
+	case age < 5*day:
+		order.Status = "shipped"
+	default:
+		order.Status = "delivered"
+	}
+
+	// Transfers are paid before processing; cash on delivery on delivery
+	if order.PaymentMethod != "cod" || order.Status == "delivered" {
+		order.PaymentStatus = "paid"
+	}
+	if order.Status == "shipped" || order.Status == "delivered" {
+		order.TrackingNumber = fmt.Sprintf("TRK%09d", s.rng.Intn(1000000000))
+	}
+	order.UpdatedAt = s.between(order.CreatedAt, order.CreatedAt.Add(age))
+}
+
+// basket returns one to four distinct products with small quantities
+func (s *seeder) basket(products []models.Product) map[uint]int {
+	n := 1 + s.rng.Intn(4)
+	basket := make(map[uint]int, n)
+	for len(basket) < n && len(basket) < len(products) {
+		product := products[s.rng.Intn(len(products))]
+		basket[product.ID] = 1 + s.rng.Intn(3)
+	}
+	return basket
+}
+
+// address returns a random Indonesian street address for userID
+func (s *seeder) address(userID uint) models.Address {
+	c := pick(s.rng, cities)
+	return models.Address{
+		UserID:     userID,
+		Type:       pick(s.rng, []string{"home", "home", "work", "other"}),
+		Address:    fmt.Sprintf("%s No. %d", pick(s.rng, streets), 1+s.rng.Intn(250)),
+		City:       c.Name,
+		Province:   c.Province,
+		PostalCode: fmt.Sprintf("%s%03d", c.PostalCode, s.rng.Intn(1000)),
+	}
+}
+
+// formatAddress matches the single-line format checkout stores on orders
+func formatAddress(a models.Address) string {
+	return fmt.Sprintf("%s, %s, %s, %s", a.Address, a.City, a.Province, a.PostalCode)
+}
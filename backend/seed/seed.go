@@ -0,0 +1,261 @@
+// Package seed fills the database with named data profiles:
+//
+//   - minimal: an admin account and the base categories
+//   - demo: minimal plus a handful of customers, products and orders
+//   - load-test: minimal plus thousands of generated customers, products,
+//     carts and months of orders
+//
+// Everything generated comes from a math/rand source seeded by Options.Seed,
+// with dates counted back from Options.Until, so the same options always
+// produce the same rows.
+package seed
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"sort"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Development credentials used when none are given. They are refused in
+// production.
+const (
+	DefaultAdminEmail    = "admin@ecommerce.com"
+	DefaultAdminPassword = "admin123"
+)
+
+// customerPasswordHash is the bcrypt hash of "password", shared by every
+// seeded customer so large profiles do not spend minutes hashing
+const customerPasswordHash = "$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi"
+
+// minAdminPasswordLength applies to admin passwords given for production
+const minAdminPasswordLength = 12
+
+// ErrNotAllowedInProduction is returned for profiles that create accounts
+// with known passwords, and for a missing or weak admin password, when the
+// environment is production
+var ErrNotAllowedInProduction = errors.New("not allowed in production")
+
+// Options controls what a profile creates. Zero sizes fall back to the
+// profile's defaults.
+type Options struct {
+	Profile string
+	Seed    int64
+	Env     string
+	// Until is the end of the generated order history; it defaults to the
+	// start of the current UTC day
+	Until time.Time
+
+	AdminEmail    string
+	AdminPassword string
+
+	Users    int
+	Products int
+	Orders   int
+	Months   int
+}
+
+type profile struct {
+	description string
+	// production reports whether the profile may run in production
+	production bool
+	defaults   Options
+	run        func(s *seeder) error
+}
+
+var profiles = map[string]profile{
+	"minimal": {
+		description: "admin account and base categories",
+		production:  true,
+		run:         seedMinimal,
+	},
+	"demo": {
+		description: "minimal plus sample customers, products and orders",
+		run:         seedDemo,
+	},
+	"load-test": {
+		description: "minimal plus generated customers, products, carts and months of orders",
+		defaults:    Options{Users: 5000, Products: 1000, Orders: 50000, Months: 6},
+		run:         seedLoadTest,
+	},
+}
+
+// Profiles returns the profile names with their descriptions
+func Profiles() map[string]string {
+	out := make(map[string]string, len(profiles))
+	for name, p := range profiles {
+		out[name] = p.description
+	}
+	return out
+}
+
+// ProfileNames returns the profile names in alphabetical order
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// seeder carries the state shared by the steps of one run
+type seeder struct {
+	ctx  context.Context
+	db   *gorm.DB
+	rng  *rand.Rand
+	opts Options
+}
+
+// Validate checks that the profile exists and, in production, that it
+// creates no default credentials
+func (o Options) Validate() error {
+	p, ok := profiles[o.Profile]
+	if !ok {
+		return fmt.Errorf("unknown seed profile %q (want one of %v)", o.Profile, ProfileNames())
+	}
+	if o.Env != config.EnvProduction {
+		return nil
+	}
+	if !p.production {
+		return fmt.Errorf("profile %q creates accounts with known passwords: %w", o.Profile, ErrNotAllowedInProduction)
+	}
+	if o.AdminPassword == DefaultAdminPassword || len(o.AdminPassword) < minAdminPasswordLength {
+		return fmt.Errorf("an admin password of at least %d characters is required: %w", minAdminPasswordLength, ErrNotAllowedInProduction)
+	}
+	return nil
+}
+
+// Run seeds db with the named profile inside a single transaction. Steps
+// skip data that is already present, so running a profile twice is safe.
+func Run(ctx context.Context, db *gorm.DB, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	p := profiles[opts.Profile]
+
+	if opts.AdminEmail == "" {
+		opts.AdminEmail = DefaultAdminEmail
+	}
+	if opts.AdminPassword == "" {
+		opts.AdminPassword = DefaultAdminPassword
+	}
+	if opts.Until.IsZero() {
+		opts.Until = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	if opts.Users <= 0 {
+		opts.Users = p.defaults.Users
+	}
+	if opts.Products <= 0 {
+		opts.Products = p.defaults.Products
+	}
+	if opts.Orders <= 0 {
+		opts.Orders = p.defaults.Orders
+	}
+	if opts.Months <= 0 {
+		opts.Months = p.defaults.Months
+	}
+
+	start := time.Now()
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return p.run(&seeder{
+			ctx:  ctx,
+			db:   tx,
+			rng:  rand.New(rand.NewSource(opts.Seed)),
+			opts: opts,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("seed %s: %w", opts.Profile, err)
+	}
+	slog.InfoContext(ctx, "Seeding completed", "profile", opts.Profile, "seed", opts.Seed,
+		"duration_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// seedMinimal creates the admin account and the base categories
+func seedMinimal(s *seeder) error {
+	var adminCount int64
+	if err := s.db.Model(&models.User{}).Where("role = ?", "admin").Count(&adminCount).Error; err != nil {
+		return err
+	}
+	if adminCount == 0 {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(s.opts.AdminPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		admin := models.User{
+			FirstName: "Admin",
+			LastName:  "User",
+			Email:     s.opts.AdminEmail,
+			Password:  string(hashedPassword),
+			Role:      "admin",
+			IsActive:  true,
+		}
+		if err := s.db.Create(&admin).Error; err != nil {
+			return fmt.Errorf("create admin: %w", err)
+		}
+		slog.InfoContext(s.ctx, "Admin user created", "user_id", admin.ID)
+	}
+
+	_, err := s.categories(baseCategories)
+	return err
+}
+
+// categories returns the categories with the given names, creating any that
+// do not exist yet
+func (s *seeder) categories(list []categorySpec) ([]models.Category, error) {
+	out := make([]models.Category, 0, len(list))
+	created := 0
+	for _, spec := range list {
+		category := models.Category{Name: spec.Name}
+		result := s.db.Where(models.Category{Name: spec.Name}).
+			Attrs(models.Category{Description: spec.Description, IsActive: true}).
+			FirstOrCreate(&category)
+		if result.Error != nil {
+			return nil, fmt.Errorf("create category %q: %w", spec.Name, result.Error)
+		}
+		created += int(result.RowsAffected)
+		out = append(out, category)
+	}
+	if created > 0 {
+		slog.InfoContext(s.ctx, "Categories created", "count", created)
+	}
+	return out, nil
+}
+
+// between returns a random time in [from, to)
+func (s *seeder) between(from, to time.Time) time.Time {
+	span := to.Sub(from)
+	if span <= 0 {
+		return from
+	}
+	return from.Add(time.Duration(s.rng.Int63n(int64(span))))
+}
+
+// sortedKeys returns the keys of m in ascending order, so iterating a map
+// does not make the output depend on Go's map ordering
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// pick returns a random element of list
+func pick[T any](rng *rand.Rand, list []T) T {
+	return list[rng.Intn(len(list))]
+}
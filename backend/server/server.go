@@ -0,0 +1,101 @@
+// Package server assembles the Fiber application: middleware, health and
+// metrics endpoints, and the API route groups. main and the integration
+// tests both build the app through New so they serve the same routes.
+package server
+
+import (
+	"ecommerce-backend/config"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/metrics"
+	"ecommerce-backend/middleware"
+	"ecommerce-backend/routes"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+// New returns the application with every route mounted. It expects the
+// database and Redis connections to be open already.
+func New(cfg *config.Config, h *handlers.Handler) *fiber.App {
+	app := fiber.New(appConfig(cfg))
+
+	// Middleware
+	app.Use(middleware.RequestID())
+	app.Use(middleware.RequestLogger())
+	app.Use(middleware.Metrics())
+	app.Use(middleware.Tracing())
+	app.Use(recover.New())
+
+	// CORS configuration
+	app.Use(cors.New(cors.Config{
+		AllowOrigins:     cfg.Server.CORSOrigins,
+		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Request-ID,Traceparent,Tracestate,If-Match,If-None-Match,If-Modified-Since",
+		ExposeHeaders:    "Content-Length,ETag,Last-Modified,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After,X-Request-ID",
+		AllowCredentials: true,
+	}))
+
+	// Health check
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status":  "ok",
+			"message": "E-Commerce API is running",
+		})
+	})
+	app.Get("/health/live", h.Liveness)
+	app.Get("/health/ready", h.Readiness)
+
+	// Prometheus metrics
+	app.Get("/metrics", metrics.Handler(cfg.Metrics.Token))
+
+	// Root route
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"message": "E-Commerce API v1.0",
+			"version": "1.0",
+			"endpoints": fiber.Map{
+				"health":  "/health",
+				"live":    "/health/live",
+				"ready":   "/health/ready",
+				"metrics": "/metrics",
+				"api":     "/api/v1",
+				"docs":    "/swagger/index.html",
+			},
+		})
+	})
+
+	// Swagger documentation - serve static files
+	app.Static("/swagger", "./docs")
+
+	// API routes
+	api := app.Group("/api/v1")
+
+	// Public routes
+	routes.PublicRoutes(api, h, cfg)
+
+	// Protected routes
+	protected := api.Group("/protected")
+	protected.Use(middleware.JWTProtected(cfg.JWT))
+	routes.ProtectedRoutes(protected, h, cfg)
+
+	// Admin routes
+	admin := protected.Group("/admin")
+	admin.Use(middleware.AdminProtected())
+	routes.AdminRoutes(admin, h)
+
+	return app
+}
+
+// appConfig returns the Fiber settings for cfg. The client IP, which rate
+// limits are counted per, is only taken from the proxy header when the
+// request comes from a trusted proxy, so clients cannot pick their own.
+func appConfig(cfg *config.Config) fiber.Config {
+	return fiber.Config{
+		ErrorHandler:            middleware.ErrorHandler,
+		ProxyHeader:             cfg.RateLimit.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.RateLimit.TrustedProxies,
+		EnableIPValidation:      true,
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/middleware"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRateLimitClientIP(t *testing.T) {
+	// Requests made through app.Test come from 0.0.0.0
+	tests := []struct {
+		name    string
+		trusted []string
+		wantOK  int
+	}{
+		{name: "trusted proxy forwards separate clients", trusted: []string{"0.0.0.0"}, wantOK: 2},
+		{name: "trusted proxy range", trusted: []string{"0.0.0.0/8"}, wantOK: 2},
+		{name: "untrusted peer shares one bucket", trusted: nil, wantOK: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			database.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() {
+				database.RedisClient.Close()
+				database.RedisClient = nil
+			})
+
+			cfg := config.Default()
+			cfg.RateLimit.TrustedProxies = tt.trusted
+
+			app := fiber.New(appConfig(&cfg))
+			app.Get("/", middleware.RateLimit(middleware.RateLimitPolicy{
+				Name: "test", Limit: 1, Window: time.Minute, KeyBy: middleware.KeyByIP,
+			}), func(c *fiber.Ctx) error {
+				return c.SendString(c.IP())
+			})
+
+			ok := 0
+			for _, ip := range []string{"203.0.113.7", "198.51.100.23"} {
+				req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+				req.Header.Set(fiber.HeaderXForwardedFor, ip)
+				res, err := app.Test(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.StatusCode == fiber.StatusOK {
+					ok++
+				}
+			}
+			if ok != tt.wantOK {
+				t.Errorf("%d of 2 clients allowed, want %d", ok, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+// Package bulk runs an action on many orders at once as a background job:
+// marking them processing, shipping them, printing their packing slips or
+// cancelling them. A job works through its orders one at a time through the
+// same services a single change goes through, and keeps a result for each.
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/carriers"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/printing"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/documents"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/services/shipping"
+)
+
+// Actions a job can run
+const (
+	ActionProcess      = "process"
+	ActionShip         = "ship"
+	ActionPackingSlips = "packing_slips"
+	ActionCancel       = "cancel"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Result statuses. An order is skipped when it is already where the action
+// would take it.
+const (
+	ResultPending   = "pending"
+	ResultSucceeded = "succeeded"
+	ResultSkipped   = "skipped"
+	ResultFailed    = "failed"
+)
+
+// Request is an admin's bulk action. It applies to OrderIDs when given,
+// otherwise to the orders matching Filter. Carrier and Service are the
+// shipments' for ActionShip.
+type Request struct {
+	Action   string
+	OrderIDs []uint
+	Filter   *repository.OrderFilter
+	Carrier  string
+	Service  string
+}
+
+// BulkService queues bulk jobs and works through them
+type BulkService interface {
+	// Start queues a job running r on its orders on behalf of adminID
+	Start(ctx context.Context, adminID uint, r Request) (models.BulkJob, error)
+	// Get returns a job with its progress and a result for each order
+	Get(ctx context.Context, id uint) (models.BulkJob, error)
+	// List returns one page of jobs, newest first, and the total number
+	List(ctx context.Context, page repository.Page) ([]models.BulkJob, int64, error)
+	// Document returns the document a finished job produced
+	Document(ctx context.Context, id uint) (documents.Document, error)
+	// Work claims the next queued job, runs it to the end and returns how
+	// many orders it processed. It returns 0 when there is nothing to do.
+	Work(ctx context.Context) (int, error)
+}
+
+// staleAfter is how long a running job can go without progress before
+// another worker takes it over
+const staleAfter = 5 * time.Minute
+
+// batch is how many pending results are read at a time
+const batch = 50
+
+type service struct {
+	tx           repository.Transactor
+	jobs         repository.JobRepository
+	orders       repository.OrderRepository
+	orderService orders.OrderService
+	shipping     shipping.ShippingService
+	store        config.StoreConfig
+	// onProductChanged is told about every product whose stock a job moved
+	onProductChanged func(ctx context.Context, productID uint)
+
+	maxOrders int
+	now       func() time.Time
+}
+
+// NewService returns a BulkService that changes orders through orderService
+// and shippingService, printing store's details on packing slips. A job can
+// cover at most maxOrders orders. onProductChanged, which may be nil, is
+// called for each product a cancelled order put back in stock.
+func NewService(tx repository.Transactor, jobs repository.JobRepository, orderRepo repository.OrderRepository,
+	orderService orders.OrderService, shippingService shipping.ShippingService, store config.StoreConfig, maxOrders int,
+	onProductChanged func(ctx context.Context, productID uint)) BulkService {
+	if onProductChanged == nil {
+		onProductChanged = func(context.Context, uint) {}
+	}
+	return &service{
+		tx:               tx,
+		jobs:             jobs,
+		orders:           orderRepo,
+		orderService:     orderService,
+		shipping:         shippingService,
+		store:            store,
+		onProductChanged: onProductChanged,
+		maxOrders:        maxOrders,
+		now:              time.Now,
+	}
+}
+
+// Run works through queued jobs, looking for new ones every interval, until
+// ctx is done
+func Run(ctx context.Context, s BulkService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		processed, err := s.Work(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Bulk job failed", "error", err)
+		} else if processed > 0 {
+			slog.InfoContext(ctx, "Ran bulk job", "orders", processed)
+			// There may be more jobs waiting
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *service) Start(ctx context.Context, adminID uint, r Request) (models.BulkJob, error) {
+	switch r.Action {
+	case ActionProcess, ActionPackingSlips, ActionCancel:
+	case ActionShip:
+		if strings.TrimSpace(r.Carrier) == "" {
+			return models.BulkJob{}, apperror.BadRequest(apperror.CodeInvalidRequest, "Shipping needs a carrier")
+		}
+		if _, ok := carriers.Normalize(r.Carrier); !ok {
+			return models.BulkJob{}, shipping.UnknownCarrier(r.Carrier)
+		}
+	default:
+		return models.BulkJob{}, apperror.BadRequest(apperror.CodeInvalidRequest, "Unknown bulk action").
+			With("action", r.Action)
+	}
+
+	ids, err := s.orderIDs(ctx, r)
+	if err != nil {
+		return models.BulkJob{}, err
+	}
+
+	job := models.BulkJob{
+		Action:    r.Action,
+		Status:    StatusQueued,
+		Total:     len(ids),
+		CreatedBy: &adminID,
+		Results:   make([]models.BulkJobResult, len(ids)),
+	}
+	if r.Action == ActionShip {
+		job.Carrier, _ = carriers.Normalize(r.Carrier)
+		job.Service = strings.TrimSpace(r.Service)
+	}
+	for i, id := range ids {
+		job.Results[i] = models.BulkJobResult{OrderID: id, Status: ResultPending}
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.jobs.Create(ctx, &job)
+	})
+	if err != nil {
+		return models.BulkJob{}, apperror.Internal("Failed to queue bulk job", err)
+	}
+	return s.Get(ctx, job.ID)
+}
+
+// orderIDs returns the IDs of the orders r applies to, each once
+func (s *service) orderIDs(ctx context.Context, r Request) ([]uint, error) {
+	var ids []uint
+	if len(r.OrderIDs) > 0 {
+		seen := make(map[uint]bool, len(r.OrderIDs))
+		for _, id := range r.OrderIDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	} else if r.Filter != nil {
+		// One more than allowed tells a filter that matches too many apart
+		var err error
+		ids, err = s.orders.ListIDs(ctx, *r.Filter, s.maxOrders+1)
+		if err != nil {
+			return nil, apperror.Internal("Failed to list orders", err)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "No orders selected")
+	}
+	if len(ids) > s.maxOrders {
+		return nil, apperror.BadRequest(apperror.CodeInvalidRequest,
+			fmt.Sprintf("At most %d orders can be changed at once", s.maxOrders)).
+			With("max_orders", s.maxOrders)
+	}
+	return ids, nil
+}
+
+func (s *service) Get(ctx context.Context, id uint) (models.BulkJob, error) {
+	job, err := s.jobs.Get(ctx, id)
+	return job, jobError(err)
+}
+
+func (s *service) List(ctx context.Context, page repository.Page) ([]models.BulkJob, int64, error) {
+	jobs, total, err := s.jobs.List(ctx, page)
+	if err != nil {
+		return nil, 0, apperror.Internal("Failed to list bulk jobs", err)
+	}
+	return jobs, total, nil
+}
+
+func (s *service) Document(ctx context.Context, id uint) (documents.Document, error) {
+	job, err := s.jobs.Document(ctx, id)
+	if err != nil {
+		return documents.Document{}, jobError(err)
+	}
+	if job.DocumentName == "" {
+		return documents.Document{}, apperror.NotFound(apperror.CodeNotFound, "This job has no document").
+			With("job_status", job.Status)
+	}
+	return documents.Document{Filename: job.DocumentName, Content: job.Document}, nil
+}
+
+func (s *service) Work(ctx context.Context) (int, error) {
+	job, err := s.jobs.Claim(ctx, s.now().Add(-staleAfter))
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, apperror.Internal("Failed to claim bulk job", err)
+	}
+
+	processed := 0
+	for {
+		pending, err := s.jobs.PendingResults(ctx, job.ID, batch)
+		if err != nil {
+			// The job stays running and is taken over once it goes stale
+			return processed, apperror.Internal("Failed to get bulk job results", err)
+		}
+		if len(pending) == 0 {
+			break
+		}
+		for _, result := range pending {
+			if ctx.Err() != nil {
+				// Leave the rest for the next worker
+				err := s.jobs.Update(context.WithoutCancel(ctx), job.ID, map[string]interface{}{"status": StatusQueued})
+				if err != nil {
+					return processed, apperror.Internal("Failed to requeue bulk job", err)
+				}
+				return processed, nil
+			}
+			if err := s.jobs.Record(ctx, s.apply(ctx, job, result)); err != nil {
+				return processed, apperror.Internal("Failed to record bulk job result", err)
+			}
+			processed++
+		}
+	}
+	return processed, s.finish(ctx, job)
+}
+
+// apply runs the job's action on the result's order and returns the result
+// filled in with what happened
+func (s *service) apply(ctx context.Context, job models.BulkJob, result models.BulkJobResult) models.BulkJobResult {
+	status, err := s.run(ctx, job, &result)
+	if err != nil {
+		status = ResultFailed
+		var appErr *apperror.Error
+		if !errors.As(err, &appErr) {
+			appErr = apperror.Internal("Failed to change order", err)
+		}
+		result.Code = string(appErr.Code)
+		result.Message = appErr.Detail
+		if appErr.Status >= 500 {
+			slog.ErrorContext(ctx, "Bulk job failed on an order",
+				"job_id", job.ID, "order_id", result.OrderID, "error", err)
+		}
+	}
+	now := s.now()
+	result.Status = status
+	result.ProcessedAt = &now
+	return result
+}
+
+// run runs the job's action on the result's order and returns the result
+// status. Anything it learns about the order, like its number, goes on
+// result.
+func (s *service) run(ctx context.Context, job models.BulkJob, result *models.BulkJobResult) (string, error) {
+	order, err := s.orderService.Get(ctx, result.OrderID)
+	if err != nil {
+		return "", err
+	}
+	result.OrderNumber = order.OrderNumber
+
+	switch job.Action {
+	case ActionProcess:
+		switch order.Status {
+		case orders.StatusProcessing:
+			return ResultSkipped, nil
+		case orders.StatusPending:
+			return s.updateStatus(ctx, order, orders.StatusProcessing)
+		}
+		return "", apperror.Conflict(apperror.CodeInvalidTransition, "Only pending orders can be marked processing").
+			With("order_status", order.Status)
+
+	case ActionShip:
+		shipped, err := s.shipping.Create(ctx, order.ID, shipping.Request{Carrier: job.Carrier, Service: job.Service})
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) && appErr.Code == apperror.CodeShipmentExceedsOrder {
+			result.Message = appErr.Detail
+			return ResultSkipped, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		result.ShipmentID = &shipped.Shipment.ID
+		return ResultSucceeded, nil
+
+	case ActionPackingSlips:
+		// The slips are printed together once every order is checked
+		if order.Status == orders.StatusDraft {
+			return "", apperror.Conflict(apperror.CodeInvalidTransition, "A draft order has to be placed before it ships").
+				With("order_status", order.Status)
+		}
+		return ResultSucceeded, nil
+
+	case ActionCancel:
+		switch order.Status {
+		case orders.StatusCancelled:
+			return ResultSkipped, nil
+		case orders.StatusDraft, orders.StatusPending, orders.StatusProcessing:
+			status, err := s.updateStatus(ctx, order, orders.StatusCancelled)
+			if err != nil {
+				return "", err
+			}
+			// Cancelling put the items back in stock
+			for _, item := range order.OrderItems {
+				s.onProductChanged(ctx, item.ProductID)
+			}
+			return status, nil
+		}
+		return "", apperror.Conflict(apperror.CodeInvalidTransition,
+			"Only draft, pending and processing orders can be cancelled in bulk").
+			With("order_status", order.Status)
+	}
+	return "", apperror.BadRequest(apperror.CodeInvalidRequest, "Unknown bulk action").With("action", job.Action)
+}
+
+// updateStatus moves the order to status as an admin would from the order
+// page, provided nobody has changed it since it was read
+func (s *service) updateStatus(ctx context.Context, order models.Order, status string) (string, error) {
+	current, updated, err := s.orderService.UpdateStatus(ctx, order.ID, order.Version, orders.StatusUpdate{Status: status})
+	if err != nil {
+		return "", err
+	}
+	if !updated {
+		return "", apperror.Conflict(apperror.CodeVersionConflict, "The order changed while the job was running").
+			With("order_status", current.Status)
+	}
+	return ResultSucceeded, nil
+}
+
+// finish completes the job, printing the packing slips of the orders it
+// checked first for ActionPackingSlips
+func (s *service) finish(ctx context.Context, job models.BulkJob) error {
+	now := s.now()
+	changes := map[string]interface{}{"status": StatusCompleted, "finished_at": &now}
+	var failure error
+	if job.Action == ActionPackingSlips {
+		name, content, err := s.packingSlips(ctx, job.ID)
+		if err != nil {
+			failure = err
+			changes["status"] = StatusFailed
+			changes["error"] = "Failed to print packing slips"
+		} else if content != nil {
+			changes["document_name"] = name
+			changes["document"] = content
+		}
+	}
+	if err := s.jobs.Update(ctx, job.ID, changes); err != nil {
+		return apperror.Internal("Failed to finish bulk job", err)
+	}
+	return failure
+}
+
+// packingSlips renders the packing slips of the orders the job succeeded
+// on, in the order they were queued. It returns no content when there are
+// none.
+func (s *service) packingSlips(ctx context.Context, jobID uint) (string, []byte, error) {
+	job, err := s.jobs.Get(ctx, jobID)
+	if err != nil {
+		return "", nil, apperror.Internal("Failed to get bulk job", err)
+	}
+	var ids []uint
+	for _, result := range job.Results {
+		if result.Status == ResultSucceeded {
+			ids = append(ids, result.OrderID)
+		}
+	}
+	if len(ids) == 0 {
+		return "", nil, nil
+	}
+
+	var all []models.Order
+	for start := 0; start < len(ids); start += documents.MaxBatch {
+		chunk, err := s.orders.GetMany(ctx, ids[start:min(start+documents.MaxBatch, len(ids))])
+		if err != nil {
+			return "", nil, apperror.Internal("Failed to get orders", err)
+		}
+		all = append(all, chunk...)
+	}
+	var buf bytes.Buffer
+	if err := printing.PackingSlips(&buf, s.store, all); err != nil {
+		return "", nil, apperror.Internal("Failed to render packing slips", err)
+	}
+	return fmt.Sprintf("packing-slips-job-%d.pdf", jobID), buf.Bytes(), nil
+}
+
+func jobError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperror.NotFound(apperror.CodeJobNotFound, "Bulk job not found")
+	}
+	if err != nil {
+		return apperror.Internal("Failed to get bulk job", err)
+	}
+	return nil
+}
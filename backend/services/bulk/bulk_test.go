@@ -0,0 +1,282 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/repository/repotest"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/services/shipping"
+)
+
+var now = time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+// newTestService returns a service over a pending, a processing, a cancelled
+// and a draft order, each with 2 of product 1, which has 10 in stock. Jobs
+// can cover at most 5 orders.
+func newTestService() (*service, *repotest.Jobs, *repotest.Orders, *repotest.Products, *repotest.Shipments) {
+	order := func(number, status string) models.Order {
+		return models.Order{
+			UserID:      1,
+			OrderNumber: number,
+			Status:      status,
+			CreatedAt:   now.Add(-time.Hour),
+			OrderItems: []models.OrderItem{{ProductID: 1, ProductName: "Kopi Arabika", ProductSKU: "KOP-001",
+				Quantity: 2, UnitPrice: 85000, TotalPrice: 170000}},
+		}
+	}
+	orderRepo := repotest.NewOrders(
+		order("ORD-20240310-0001", orders.StatusPending),
+		order("ORD-20240310-0002", orders.StatusProcessing),
+		order("ORD-20240310-0003", orders.StatusCancelled),
+		order("ORD-20240310-0004", orders.StatusDraft),
+	)
+	products := repotest.NewProducts(models.Product{Name: "Kopi Arabika", Stock: 10, IsActive: true})
+	shipments := repotest.NewShipments()
+	jobs := repotest.NewJobs()
+
+	tx := repotest.Transactor{}
+	orderService := orders.NewService(tx, orderRepo, products, repotest.NewUsers())
+	shippingService := shipping.NewService(tx, orderRepo, shipments)
+	s := NewService(tx, jobs, orderRepo, orderService, shippingService, config.StoreConfig{Name: "Toko Kopi"}, 5, nil).(*service)
+	s.now = func() time.Time { return now }
+	return s, jobs, orderRepo, products, shipments
+}
+
+func TestStart(t *testing.T) {
+	pending := &repository.OrderFilter{Status: orders.StatusPending}
+	tests := []struct {
+		name      string
+		request   Request
+		wantCode  apperror.Code
+		wantOrder []uint
+	}{
+		{
+			name:      "order IDs, each once",
+			request:   Request{Action: ActionProcess, OrderIDs: []uint{2, 1, 2}},
+			wantOrder: []uint{2, 1},
+		},
+		{
+			name:      "filter",
+			request:   Request{Action: ActionCancel, Filter: pending},
+			wantOrder: []uint{1},
+		},
+		{
+			name:      "filter matching everything",
+			request:   Request{Action: ActionPackingSlips, Filter: &repository.OrderFilter{}},
+			wantOrder: []uint{4, 3, 2, 1},
+		},
+		{
+			name:     "nothing selected",
+			request:  Request{Action: ActionProcess, Filter: &repository.OrderFilter{Status: orders.StatusShipped}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "too many orders",
+			request:  Request{Action: ActionProcess, OrderIDs: []uint{1, 2, 3, 4, 5, 6}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "shipping without a carrier",
+			request:  Request{Action: ActionShip, OrderIDs: []uint{1}, Carrier: " "},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "unknown carrier",
+			request:  Request{Action: ActionShip, OrderIDs: []uint{1}, Carrier: "kurir"},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "unknown action",
+			request:  Request{Action: "refund", OrderIDs: []uint{1}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _, _, _ := newTestService()
+
+			job, err := s.Start(context.Background(), 9, tt.request)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("Start() error = %v, want code %q", err, tt.wantCode)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+			if job.Status != StatusQueued || job.Total != len(tt.wantOrder) || job.CreatedBy == nil || *job.CreatedBy != 9 {
+				t.Errorf("job = %+v, want %d orders queued by admin 9", job, len(tt.wantOrder))
+			}
+			if len(job.Results) != len(tt.wantOrder) {
+				t.Fatalf("results = %+v, want orders %v", job.Results, tt.wantOrder)
+			}
+			for i, id := range tt.wantOrder {
+				if got := job.Results[i]; got.OrderID != id || got.Status != ResultPending {
+					t.Errorf("result %d = order %d %s, want order %d pending", i, got.OrderID, got.Status, id)
+				}
+			}
+		})
+	}
+}
+
+func TestWork(t *testing.T) {
+	all := []uint{1, 2, 3, 4, 99}
+	tests := []struct {
+		name    string
+		request Request
+		// want maps each order to its result status, and code to the failure
+		// code of failed ones
+		want      map[uint]string
+		wantCodes map[uint]apperror.Code
+		// wantStatus maps orders to the status the job leaves them in
+		wantStatus map[uint]string
+		wantStock  int
+	}{
+		{
+			name:       "process",
+			request:    Request{Action: ActionProcess, OrderIDs: all},
+			want:       map[uint]string{1: ResultSucceeded, 2: ResultSkipped, 3: ResultFailed, 4: ResultFailed, 99: ResultFailed},
+			wantCodes:  map[uint]apperror.Code{3: apperror.CodeInvalidTransition, 4: apperror.CodeInvalidTransition, 99: apperror.CodeOrderNotFound},
+			wantStatus: map[uint]string{1: orders.StatusProcessing, 2: orders.StatusProcessing},
+			wantStock:  10,
+		},
+		{
+			name:       "ship",
+			request:    Request{Action: ActionShip, OrderIDs: []uint{1, 2, 2, 3, 4}, Carrier: "jne", Service: "REG"},
+			want:       map[uint]string{1: ResultSucceeded, 2: ResultSucceeded, 3: ResultFailed, 4: ResultFailed},
+			wantCodes:  map[uint]apperror.Code{3: apperror.CodeInvalidTransition, 4: apperror.CodeInvalidTransition},
+			wantStatus: map[uint]string{1: orders.StatusShipped, 2: orders.StatusShipped},
+			wantStock:  10,
+		},
+		{
+			name:       "cancel",
+			request:    Request{Action: ActionCancel, OrderIDs: all},
+			want:       map[uint]string{1: ResultSucceeded, 2: ResultSucceeded, 3: ResultSkipped, 4: ResultSucceeded, 99: ResultFailed},
+			wantCodes:  map[uint]apperror.Code{99: apperror.CodeOrderNotFound},
+			wantStatus: map[uint]string{1: orders.StatusCancelled, 2: orders.StatusCancelled, 4: orders.StatusCancelled},
+			// The draft held no stock to put back
+			wantStock: 14,
+		},
+		{
+			name:      "packing slips",
+			request:   Request{Action: ActionPackingSlips, OrderIDs: all},
+			want:      map[uint]string{1: ResultSucceeded, 2: ResultSucceeded, 3: ResultSucceeded, 4: ResultFailed, 99: ResultFailed},
+			wantCodes: map[uint]apperror.Code{4: apperror.CodeInvalidTransition, 99: apperror.CodeOrderNotFound},
+			wantStock: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, orderRepo, products, _ := newTestService()
+			ctx := context.Background()
+			changed := map[uint]bool{}
+			s.onProductChanged = func(_ context.Context, id uint) { changed[id] = true }
+
+			started, err := s.Start(ctx, 9, tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			processed, err := s.Work(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if processed != started.Total {
+				t.Errorf("Work() processed %d orders, want %d", processed, started.Total)
+			}
+
+			job, err := s.Get(ctx, started.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts := map[string]int{}
+			for _, result := range job.Results {
+				counts[result.Status]++
+				if result.Status != tt.want[result.OrderID] || apperror.Code(result.Code) != tt.wantCodes[result.OrderID] {
+					t.Errorf("order %d: %s %q, want %s %q", result.OrderID, result.Status, result.Code,
+						tt.want[result.OrderID], tt.wantCodes[result.OrderID])
+				}
+				if result.ProcessedAt == nil {
+					t.Errorf("order %d has no processed time", result.OrderID)
+				}
+				if tt.request.Action == ActionShip && result.Status == ResultSucceeded && result.ShipmentID == nil {
+					t.Errorf("order %d was shipped without a shipment", result.OrderID)
+				}
+			}
+			if job.Status != StatusCompleted || job.FinishedAt == nil || job.Processed != job.Total ||
+				job.Succeeded != counts[ResultSucceeded] || job.Skipped != counts[ResultSkipped] || job.Failed != counts[ResultFailed] {
+				t.Errorf("job = %+v, want completed with counts %v", job, counts)
+			}
+
+			for id, status := range tt.wantStatus {
+				if got := orderRepo.Rows[id].Status; got != status {
+					t.Errorf("order %d is %s, want %s", id, got, status)
+				}
+			}
+			if got := products.Rows[1].Stock; got != tt.wantStock {
+				t.Errorf("stock = %d, want %d", got, tt.wantStock)
+			}
+			// Restocked products drop out of the catalog cache
+			if restocked := tt.wantStock != 10; changed[1] != restocked {
+				t.Errorf("product 1 changed = %v, want %v", changed[1], restocked)
+			}
+
+			doc, err := s.Document(ctx, job.ID)
+			if tt.request.Action != ActionPackingSlips {
+				if repotest.Code(err) != apperror.CodeNotFound {
+					t.Errorf("Document() error = %v, want NOT_FOUND", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Filename != "packing-slips-job-1.pdf" || !bytes.HasPrefix(doc.Content, []byte("%PDF-")) {
+				t.Errorf("document = %s of %d bytes, want packing-slips-job-1.pdf", doc.Filename, len(doc.Content))
+			}
+		})
+	}
+}
+
+func TestWorkResumes(t *testing.T) {
+	s, jobs, _, _, _ := newTestService()
+	ctx := context.Background()
+
+	if processed, err := s.Work(ctx); processed != 0 || err != nil {
+		t.Fatalf("Work() with nothing queued = %d, %v", processed, err)
+	}
+
+	job, err := s.Start(ctx, 9, Request{Action: ActionProcess, OrderIDs: []uint{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A worker stopped before it started goes back in the queue
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	if processed, err := s.Work(stopped); processed != 0 || err != nil {
+		t.Fatalf("Work() after shutdown = %d, %v", processed, err)
+	}
+	if got := jobs.Rows[job.ID].Status; got != StatusQueued {
+		t.Fatalf("job status after shutdown = %s, want queued", got)
+	}
+
+	// A job left running is only taken over once it goes stale
+	if _, err := jobs.Claim(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if processed, _ := s.Work(ctx); processed != 0 {
+		t.Fatalf("Work() took over a running job: %d orders", processed)
+	}
+	s.now = func() time.Time { return time.Now().Add(staleAfter + time.Minute) }
+	if processed, err := s.Work(ctx); processed != 2 || err != nil {
+		t.Fatalf("Work() on a stale job = %d, %v, want 2 orders", processed, err)
+	}
+	if got := jobs.Rows[job.ID].Status; got != StatusCompleted {
+		t.Errorf("job status = %s, want completed", got)
+	}
+}
@@ -0,0 +1,325 @@
+// Package cart manages shopping carts and prices their contents
+package cart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+)
+
+// Pricing applied to every cart and order
+const (
+	// TaxPercent is the VAT charged on the subtotal
+	TaxPercent = 10
+	// ShippingCost is the flat shipping fee for a non-empty cart
+	ShippingCost int64 = 10000
+)
+
+// Totals is the price breakdown of a set of cart items
+type Totals struct {
+	Subtotal   int64 `json:"subtotal"`
+	Tax        int64 `json:"tax"`
+	Shipping   int64 `json:"shipping"`
+	Total      int64 `json:"total"`
+	TotalItems int   `json:"total_items"`
+}
+
+// PriceItems totals items at their products' current prices. An empty cart
+// costs nothing, shipping included.
+func PriceItems(items []models.CartItem) Totals {
+	var t Totals
+	for _, item := range items {
+		t.Subtotal += item.Product.Price * int64(item.Quantity)
+		t.TotalItems += item.Quantity
+	}
+	return t.withCharges()
+}
+
+// PriceOrderItems totals the lines of an order at the prices they were
+// ordered at, charging tax and shipping the way PriceItems does for a cart
+func PriceOrderItems(items []models.OrderItem) Totals {
+	var t Totals
+	for _, item := range items {
+		t.Subtotal += item.TotalPrice
+		t.TotalItems += item.Quantity
+	}
+	return t.withCharges()
+}
+
+// withCharges adds tax and shipping to a subtotal, unless there is nothing
+// to charge them on
+func (t Totals) withCharges() Totals {
+	if t.TotalItems == 0 {
+		return t
+	}
+	t.Tax = t.Subtotal * TaxPercent / 100
+	t.Shipping = ShippingCost
+	t.Total = t.Subtotal + t.Tax + t.Shipping
+	return t
+}
+
+// OrderItem prices quantity of product for an order and copies the product's
+// details onto it as they are now
+func OrderItem(product models.Product, quantity int) models.OrderItem {
+	total := product.Price * int64(quantity)
+	return models.OrderItem{
+		ProductID:    product.ID,
+		ProductName:  product.Name,
+		ProductSKU:   product.SKU,
+		ProductImage: product.Image,
+		Weight:       product.Weight,
+		Quantity:     quantity,
+		UnitPrice:    product.Price,
+		TotalPrice:   total,
+		TaxPercent:   TaxPercent,
+		TaxAmount:    total * TaxPercent / 100,
+	}
+}
+
+// Summary is a cart's items together with their totals
+type Summary struct {
+	Totals
+	Items []models.CartItem `json:"cart_items"`
+}
+
+// GuestItem is a line of a cart built before signing in
+type GuestItem struct {
+	ProductID uint
+	Quantity  int
+}
+
+// CartService manages the signed-in user's active cart
+type CartService interface {
+	// Get returns the active cart with its items, creating an empty one if
+	// the user has none
+	Get(ctx context.Context, userID uint) (models.Cart, error)
+	// Add puts quantity of a product in the cart, adding to the quantity
+	// already there. created reports whether a new line was added.
+	Add(ctx context.Context, userID, productID uint, quantity int) (item models.CartItem, created bool, err error)
+	UpdateItem(ctx context.Context, userID, itemID uint, quantity int) (models.CartItem, error)
+	RemoveItem(ctx context.Context, userID, itemID uint) error
+	Summary(ctx context.Context, userID uint) (Summary, error)
+	Clear(ctx context.Context, userID uint) error
+	// MergeGuest adds a guest cart to the user's cart and returns how many
+	// lines were merged. Lines for unknown products, or that would exceed
+	// the stock, are skipped.
+	MergeGuest(ctx context.Context, userID uint, items []GuestItem) (int, error)
+}
+
+type service struct {
+	tx       repository.Transactor
+	carts    repository.CartRepository
+	products repository.ProductRepository
+}
+
+// NewService returns a CartService backed by the given repositories
+func NewService(tx repository.Transactor, carts repository.CartRepository, products repository.ProductRepository) CartService {
+	return &service{tx: tx, carts: carts, products: products}
+}
+
+func (s *service) Get(ctx context.Context, userID uint) (models.Cart, error) {
+	cart, err := s.carts.GetActiveWithItems(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		cart = models.Cart{UserID: userID, IsActive: true}
+		if err := s.carts.Create(ctx, &cart); err != nil {
+			return models.Cart{}, apperror.Internal("Failed to create cart", err)
+		}
+		cart.CartItems = []models.CartItem{}
+		return cart, nil
+	}
+	if err != nil {
+		return models.Cart{}, apperror.Internal("Failed to get cart", err)
+	}
+	return cart, nil
+}
+
+func (s *service) Add(ctx context.Context, userID, productID uint, quantity int) (models.CartItem, bool, error) {
+	var item models.CartItem
+	created := false
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		product, err := s.products.Get(ctx, productID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.NotFound(apperror.CodeProductNotFound, "Product not found")
+		}
+		if err != nil {
+			return apperror.Internal("Failed to fetch product", err)
+		}
+
+		cart, err := s.activeCart(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		item, err = s.carts.FindItem(ctx, cart.ID, productID)
+		switch {
+		case err == nil:
+			if err := checkStock(product, item.Quantity+quantity); err != nil {
+				return err
+			}
+			item.Quantity += quantity
+			if err := s.carts.UpdateItemQuantity(ctx, item.ID, item.Quantity); err != nil {
+				return apperror.Internal("Failed to update cart item", err)
+			}
+		case errors.Is(err, repository.ErrNotFound):
+			if err := checkStock(product, quantity); err != nil {
+				return err
+			}
+			item = models.CartItem{CartID: cart.ID, ProductID: productID, Quantity: quantity}
+			if err := s.carts.CreateItem(ctx, &item); err != nil {
+				return apperror.Internal("Failed to add item to cart", err)
+			}
+			created = true
+		default:
+			return apperror.Internal("Failed to get cart item", err)
+		}
+		return nil
+	})
+	return item, created, err
+}
+
+func (s *service) UpdateItem(ctx context.Context, userID, itemID uint, quantity int) (models.CartItem, error) {
+	item, err := s.ownItem(ctx, userID, itemID)
+	if err != nil {
+		return models.CartItem{}, err
+	}
+	if err := checkStock(item.Product, quantity); err != nil {
+		return models.CartItem{}, err
+	}
+	if err := s.carts.UpdateItemQuantity(ctx, item.ID, quantity); err != nil {
+		return models.CartItem{}, apperror.Internal("Failed to update cart item", err)
+	}
+	item.Quantity = quantity
+	return item, nil
+}
+
+func (s *service) RemoveItem(ctx context.Context, userID, itemID uint) error {
+	item, err := s.ownItem(ctx, userID, itemID)
+	if err != nil {
+		return err
+	}
+	if err := s.carts.DeleteItem(ctx, item.ID); err != nil {
+		return apperror.Internal("Failed to remove cart item", err)
+	}
+	return nil
+}
+
+func (s *service) Summary(ctx context.Context, userID uint) (Summary, error) {
+	cart, err := s.carts.GetActiveWithItems(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return Summary{Items: []models.CartItem{}}, nil
+	}
+	if err != nil {
+		return Summary{}, apperror.Internal("Failed to get cart", err)
+	}
+	return Summary{Totals: PriceItems(cart.CartItems), Items: cart.CartItems}, nil
+}
+
+func (s *service) Clear(ctx context.Context, userID uint) error {
+	cart, err := s.carts.GetActive(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperror.NotFound(apperror.CodeCartNotFound, "Cart not found")
+	}
+	if err != nil {
+		return apperror.Internal("Failed to get cart", err)
+	}
+	if err := s.carts.ClearItems(ctx, cart.ID); err != nil {
+		return apperror.Internal("Failed to clear cart", err)
+	}
+	return nil
+}
+
+func (s *service) MergeGuest(ctx context.Context, userID uint, items []GuestItem) (int, error) {
+	merged := 0
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		cart, err := s.activeCart(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, guest := range items {
+			if guest.Quantity < 1 {
+				continue
+			}
+			product, err := s.products.Get(ctx, guest.ProductID)
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get product %d: %w", guest.ProductID, err)
+			}
+
+			item, err := s.carts.FindItem(ctx, cart.ID, guest.ProductID)
+			switch {
+			case err == nil:
+				quantity := item.Quantity + guest.Quantity
+				if quantity > product.Stock {
+					continue
+				}
+				if err := s.carts.UpdateItemQuantity(ctx, item.ID, quantity); err != nil {
+					return fmt.Errorf("failed to update cart item: %w", err)
+				}
+			case errors.Is(err, repository.ErrNotFound):
+				if guest.Quantity > product.Stock {
+					continue
+				}
+				item = models.CartItem{CartID: cart.ID, ProductID: guest.ProductID, Quantity: guest.Quantity}
+				if err := s.carts.CreateItem(ctx, &item); err != nil {
+					return fmt.Errorf("failed to create cart item: %w", err)
+				}
+			default:
+				return fmt.Errorf("failed to get cart item: %w", err)
+			}
+			merged++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return merged, nil
+}
+
+// activeCart returns the user's active cart, creating it if needed
+func (s *service) activeCart(ctx context.Context, userID uint) (models.Cart, error) {
+	cart, err := s.carts.GetActive(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		cart = models.Cart{UserID: userID, IsActive: true}
+		if err := s.carts.Create(ctx, &cart); err != nil {
+			return models.Cart{}, apperror.Internal("Failed to create cart", err)
+		}
+		return cart, nil
+	}
+	if err != nil {
+		return models.Cart{}, apperror.Internal("Failed to get cart", err)
+	}
+	return cart, nil
+}
+
+// ownItem returns the cart item if it is in one of the user's carts
+func (s *service) ownItem(ctx context.Context, userID, itemID uint) (models.CartItem, error) {
+	item, err := s.carts.GetItem(ctx, itemID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.CartItem{}, apperror.NotFound(apperror.CodeCartItemNotFound, "Cart item not found")
+	}
+	if err != nil {
+		return models.CartItem{}, apperror.Internal("Failed to get cart item", err)
+	}
+	if item.Cart.UserID != userID {
+		return models.CartItem{}, apperror.Forbidden("Access denied")
+	}
+	return item, nil
+}
+
+// checkStock fails with INSUFFICIENT_STOCK unless quantity units of product
+// are in stock
+func checkStock(product models.Product, quantity int) error {
+	if product.Stock < quantity {
+		return apperror.BadRequest(apperror.CodeInsufficientStock, "Insufficient stock").
+			With("product_id", product.ID).
+			With("available", product.Stock)
+	}
+	return nil
+}
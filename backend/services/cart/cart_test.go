@@ -0,0 +1,305 @@
+package cart
+
+import (
+	"context"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository/repotest"
+)
+
+const (
+	alice uint = 1
+	bob   uint = 2
+)
+
+func newTestService() (*service, *repotest.Carts) {
+	products := repotest.NewProducts(
+		models.Product{ID: 1, Name: "Kopi Arabika", Price: 85000, Stock: 5, IsActive: true},
+		models.Product{ID: 2, Name: "Teh Melati", Price: 25000, Stock: 1, IsActive: true},
+	)
+	carts := repotest.NewCarts(products)
+	return NewService(repotest.Transactor{}, carts, products).(*service), carts
+}
+
+func TestPriceItems(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []models.CartItem
+		want  Totals
+	}{
+		{
+			name: "empty cart is free",
+			want: Totals{},
+		},
+		{
+			name: "single line",
+			items: []models.CartItem{
+				{Quantity: 2, Product: models.Product{Price: 50000}},
+			},
+			want: Totals{Subtotal: 100000, Tax: 10000, Shipping: ShippingCost, Total: 120000, TotalItems: 2},
+		},
+		{
+			name: "several lines",
+			items: []models.CartItem{
+				{Quantity: 1, Product: models.Product{Price: 85000}},
+				{Quantity: 3, Product: models.Product{Price: 25000}},
+			},
+			want: Totals{Subtotal: 160000, Tax: 16000, Shipping: ShippingCost, Total: 186000, TotalItems: 4},
+		},
+		{
+			name: "tax rounds down",
+			items: []models.CartItem{
+				{Quantity: 1, Product: models.Product{Price: 999}},
+			},
+			want: Totals{Subtotal: 999, Tax: 99, Shipping: ShippingCost, Total: 11098, TotalItems: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PriceItems(tt.items); got != tt.want {
+				t.Errorf("PriceItems() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceOrderItems(t *testing.T) {
+	// An order is priced like the cart it came from, at the prices it was
+	// ordered at rather than the products' prices now
+	cartItems := []models.CartItem{
+		{Quantity: 1, Product: models.Product{ID: 1, Price: 85000}},
+		{Quantity: 3, Product: models.Product{ID: 2, Price: 25000}},
+	}
+	orderItems := make([]models.OrderItem, len(cartItems))
+	for i, item := range cartItems {
+		orderItems[i] = OrderItem(item.Product, item.Quantity)
+		cartItems[i].Product.Price *= 2
+	}
+	want := Totals{Subtotal: 160000, Tax: 16000, Shipping: ShippingCost, Total: 186000, TotalItems: 4}
+	if got := PriceOrderItems(orderItems); got != want {
+		t.Errorf("PriceOrderItems() = %+v, want %+v", got, want)
+	}
+	if got := PriceOrderItems(nil); got != (Totals{}) {
+		t.Errorf("PriceOrderItems(nil) = %+v, want nothing to pay", got)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name        string
+		inCart      []models.CartItem
+		productID   uint
+		quantity    int
+		wantCode    apperror.Code
+		wantCreated bool
+		wantQty     int
+	}{
+		{
+			name:        "new line",
+			productID:   1,
+			quantity:    2,
+			wantCreated: true,
+			wantQty:     2,
+		},
+		{
+			name:      "adds to existing line",
+			inCart:    []models.CartItem{{ProductID: 1, Quantity: 2}},
+			productID: 1,
+			quantity:  3,
+			wantQty:   5,
+		},
+		{
+			name:      "more than in stock",
+			productID: 2,
+			quantity:  2,
+			wantCode:  apperror.CodeInsufficientStock,
+		},
+		{
+			name:      "existing line would exceed stock",
+			inCart:    []models.CartItem{{ProductID: 1, Quantity: 4}},
+			productID: 1,
+			quantity:  2,
+			wantCode:  apperror.CodeInsufficientStock,
+		},
+		{
+			name:      "unknown product",
+			productID: 99,
+			quantity:  1,
+			wantCode:  apperror.CodeProductNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, carts := newTestService()
+			if tt.inCart != nil {
+				carts.Fill(alice, tt.inCart...)
+			}
+
+			item, created, err := s.Add(context.Background(), alice, tt.productID, tt.quantity)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+			if created != tt.wantCreated {
+				t.Errorf("created = %v, want %v", created, tt.wantCreated)
+			}
+			if item.Quantity != tt.wantQty || carts.Items[item.ID].Quantity != tt.wantQty {
+				t.Errorf("quantity = %d (stored %d), want %d", item.Quantity, carts.Items[item.ID].Quantity, tt.wantQty)
+			}
+		})
+	}
+}
+
+func TestUpdateAndRemoveItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   uint
+		itemID   uint
+		quantity int
+		remove   bool
+		wantCode apperror.Code
+	}{
+		{name: "update own item", userID: alice, itemID: 1, quantity: 4},
+		{name: "update beyond stock", userID: alice, itemID: 1, quantity: 6, wantCode: apperror.CodeInsufficientStock},
+		{name: "update someone else's item", userID: bob, itemID: 1, quantity: 1, wantCode: apperror.CodeForbidden},
+		{name: "update unknown item", userID: alice, itemID: 99, quantity: 1, wantCode: apperror.CodeCartItemNotFound},
+		{name: "remove own item", userID: alice, itemID: 1, remove: true},
+		{name: "remove someone else's item", userID: bob, itemID: 1, remove: true, wantCode: apperror.CodeForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, carts := newTestService()
+			carts.Fill(alice, models.CartItem{ProductID: 1, Quantity: 1})
+
+			var err error
+			if tt.remove {
+				err = s.RemoveItem(context.Background(), tt.userID, tt.itemID)
+			} else {
+				_, err = s.UpdateItem(context.Background(), tt.userID, tt.itemID, tt.quantity)
+			}
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+
+			item, exists := carts.Items[1]
+			switch {
+			case tt.wantCode != "" && (!exists || item.Quantity != 1):
+				t.Error("a rejected change modified the item")
+			case tt.wantCode == "" && tt.remove && exists:
+				t.Error("item was not removed")
+			case tt.wantCode == "" && !tt.remove && item.Quantity != tt.quantity:
+				t.Errorf("quantity = %d, want %d", item.Quantity, tt.quantity)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	s, carts := newTestService()
+
+	empty, err := s.Summary(context.Background(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Total != 0 || empty.Items == nil {
+		t.Errorf("summary without a cart = %+v, want zero totals and no items", empty)
+	}
+
+	carts.Fill(alice, models.CartItem{ProductID: 1, Quantity: 2})
+	summary, err := s.Summary(context.Background(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := PriceItems(summary.Items); summary.Totals != want || summary.Total != 197000 {
+		t.Errorf("totals = %+v, want %+v with total 197000", summary.Totals, want)
+	}
+}
+
+func TestClear(t *testing.T) {
+	s, carts := newTestService()
+	if code := repotest.Code(s.Clear(context.Background(), alice)); code != apperror.CodeCartNotFound {
+		t.Errorf("clearing without a cart: code = %q, want %q", code, apperror.CodeCartNotFound)
+	}
+
+	cart := carts.Fill(alice, models.CartItem{ProductID: 1, Quantity: 1}, models.CartItem{ProductID: 2, Quantity: 1})
+	if err := s.Clear(context.Background(), alice); err != nil {
+		t.Fatal(err)
+	}
+	if items := carts.ItemsIn(cart.ID); len(items) != 0 {
+		t.Errorf("cart still holds %d items", len(items))
+	}
+}
+
+func TestMergeGuest(t *testing.T) {
+	tests := []struct {
+		name       string
+		inCart     []models.CartItem
+		guest      []GuestItem
+		wantMerged int
+		wantQty    map[uint]int
+	}{
+		{
+			name:       "into empty cart",
+			guest:      []GuestItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}},
+			wantMerged: 2,
+			wantQty:    map[uint]int{1: 2, 2: 1},
+		},
+		{
+			name:       "adds to existing line",
+			inCart:     []models.CartItem{{ProductID: 1, Quantity: 1}},
+			guest:      []GuestItem{{ProductID: 1, Quantity: 2}},
+			wantMerged: 1,
+			wantQty:    map[uint]int{1: 3},
+		},
+		{
+			name:       "skips lines over stock",
+			inCart:     []models.CartItem{{ProductID: 1, Quantity: 4}},
+			guest:      []GuestItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 3}},
+			wantMerged: 0,
+			wantQty:    map[uint]int{1: 4},
+		},
+		{
+			name:       "skips unknown products and bad quantities",
+			guest:      []GuestItem{{ProductID: 99, Quantity: 1}, {ProductID: 1, Quantity: 0}, {ProductID: 2, Quantity: 1}},
+			wantMerged: 1,
+			wantQty:    map[uint]int{2: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, carts := newTestService()
+			if tt.inCart != nil {
+				carts.Fill(alice, tt.inCart...)
+			}
+
+			merged, err := s.MergeGuest(context.Background(), alice, tt.guest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if merged != tt.wantMerged {
+				t.Errorf("merged = %d, want %d", merged, tt.wantMerged)
+			}
+
+			cart, err := carts.GetActiveWithItems(context.Background(), alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[uint]int)
+			for _, item := range cart.CartItems {
+				got[item.ProductID] = item.Quantity
+			}
+			if len(got) != len(tt.wantQty) {
+				t.Fatalf("cart = %v, want %v", got, tt.wantQty)
+			}
+			for productID, qty := range tt.wantQty {
+				if got[productID] != qty {
+					t.Errorf("product %d quantity = %d, want %d", productID, got[productID], qty)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Package catalog manages products and categories
+package catalog
+
+import (
+	"context"
+	"errors"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+)
+
+// ProductQuery selects a page of products. IncludeInactive is for admins: it
+// also returns inactive and soft-deleted products.
+type ProductQuery struct {
+	Page            repository.Page
+	Category        string
+	Search          string
+	MinPrice        *int64
+	MaxPrice        *int64
+	IncludeInactive bool
+}
+
+// CatalogService is the product catalog as the storefront and the admin
+// panel see it
+type CatalogService interface {
+	ListProducts(ctx context.Context, q ProductQuery) ([]models.Product, int64, error)
+	// GetProduct returns a product with its category and reviews
+	GetProduct(ctx context.Context, id uint) (models.Product, error)
+	// GetAdminProduct returns a product with its category
+	GetAdminProduct(ctx context.Context, id uint) (models.Product, error)
+	CreateProduct(ctx context.Context, product *models.Product) error
+	// UpdateProduct applies changes if the product is still at version
+	// expected. It returns the product as it is afterwards, and false when
+	// someone else changed it first.
+	UpdateProduct(ctx context.Context, id, expected uint, changes map[string]interface{}) (models.Product, bool, error)
+	DeleteProduct(ctx context.Context, id uint) error
+
+	ListCategories(ctx context.Context) ([]models.Category, error)
+	GetCategory(ctx context.Context, id uint) (models.Category, error)
+	CreateCategory(ctx context.Context, category *models.Category) error
+	UpdateCategory(ctx context.Context, category *models.Category) error
+	DeleteCategory(ctx context.Context, id uint) error
+}
+
+type service struct {
+	products   repository.ProductRepository
+	categories repository.CategoryRepository
+}
+
+// NewService returns a CatalogService backed by the given repositories
+func NewService(products repository.ProductRepository, categories repository.CategoryRepository) CatalogService {
+	return &service{products: products, categories: categories}
+}
+
+func (s *service) ListProducts(ctx context.Context, q ProductQuery) ([]models.Product, int64, error) {
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return nil, 0, apperror.BadRequest(apperror.CodeInvalidRequest, "min_price must not exceed max_price")
+	}
+	filter := repository.ProductFilter{
+		Category:        q.Category,
+		Search:          q.Search,
+		MinPrice:        q.MinPrice,
+		MaxPrice:        q.MaxPrice,
+		IncludeInactive: q.IncludeInactive,
+	}
+	products, total, err := s.products.List(ctx, filter, q.Page)
+	if err != nil {
+		return nil, 0, apperror.Internal("Failed to fetch products", err)
+	}
+	return products, total, nil
+}
+
+func (s *service) GetProduct(ctx context.Context, id uint) (models.Product, error) {
+	product, err := s.products.GetWithReviews(ctx, id)
+	return product, productError(err, "Failed to fetch product")
+}
+
+func (s *service) GetAdminProduct(ctx context.Context, id uint) (models.Product, error) {
+	product, err := s.products.Get(ctx, id)
+	return product, productError(err, "Failed to fetch product")
+}
+
+func (s *service) CreateProduct(ctx context.Context, product *models.Product) error {
+	product.Version = 1
+	if err := s.products.Create(ctx, product); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return apperror.Conflict(apperror.CodeSKUTaken, "Product with this SKU already exists")
+		}
+		return apperror.Internal("Failed to create product", err)
+	}
+	return nil
+}
+
+func (s *service) UpdateProduct(ctx context.Context, id, expected uint, changes map[string]interface{}) (models.Product, bool, error) {
+	updated, err := s.products.Update(ctx, id, expected, changes)
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return models.Product{}, false, apperror.Conflict(apperror.CodeSKUTaken, "Product with this SKU already exists")
+		}
+		return models.Product{}, false, apperror.Internal("Failed to update product", err)
+	}
+	product, err := s.products.Get(ctx, id)
+	if err != nil {
+		return models.Product{}, false, productError(err, "Failed to fetch updated product")
+	}
+	return product, updated, nil
+}
+
+func (s *service) DeleteProduct(ctx context.Context, id uint) error {
+	if _, err := s.products.Get(ctx, id); err != nil {
+		return productError(err, "Failed to fetch product")
+	}
+	if err := s.products.Delete(ctx, id); err != nil {
+		return apperror.Internal("Failed to delete product", err)
+	}
+	return nil
+}
+
+func (s *service) ListCategories(ctx context.Context) ([]models.Category, error) {
+	categories, err := s.categories.ListActive(ctx)
+	if err != nil {
+		return nil, apperror.Internal("Failed to fetch categories", err)
+	}
+	return categories, nil
+}
+
+func (s *service) GetCategory(ctx context.Context, id uint) (models.Category, error) {
+	category, err := s.categories.Get(ctx, id)
+	return category, categoryError(err, "Failed to fetch category")
+}
+
+func (s *service) CreateCategory(ctx context.Context, category *models.Category) error {
+	if err := s.categories.Create(ctx, category); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return apperror.Conflict(apperror.CodeCategoryExists, "Category with this name already exists")
+		}
+		return apperror.Internal("Failed to create category", err)
+	}
+	return nil
+}
+
+func (s *service) UpdateCategory(ctx context.Context, category *models.Category) error {
+	if err := s.categories.Save(ctx, category); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return apperror.Conflict(apperror.CodeCategoryExists, "Category with this name already exists")
+		}
+		return apperror.Internal("Failed to update category", err)
+	}
+	return nil
+}
+
+func (s *service) DeleteCategory(ctx context.Context, id uint) error {
+	if _, err := s.categories.Get(ctx, id); err != nil {
+		return categoryError(err, "Failed to fetch category")
+	}
+	if err := s.categories.Delete(ctx, id); err != nil {
+		return apperror.Internal("Failed to delete category", err)
+	}
+	return nil
+}
+
+// productError maps a repository error to PRODUCT_NOT_FOUND or an internal
+// error described by detail
+func productError(err error, detail string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		return apperror.NotFound(apperror.CodeProductNotFound, "Product not found")
+	default:
+		return apperror.Internal(detail, err)
+	}
+}
+
+func categoryError(err error, detail string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		return apperror.NotFound(apperror.CodeCategoryNotFound, "Category not found")
+	default:
+		return apperror.Internal(detail, err)
+	}
+}
@@ -0,0 +1,242 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/repository/repotest"
+)
+
+func newTestService() (*service, *repotest.Products, *repotest.Categories) {
+	products := repotest.NewProducts(
+		models.Product{ID: 1, Name: "Kopi Arabika", Price: 85000, Stock: 10, SKU: "KOPI-1", IsActive: true},
+		models.Product{ID: 2, Name: "Teh Melati", Price: 25000, Stock: 5, SKU: "TEH-1", IsActive: true},
+		models.Product{ID: 3, Name: "Kopi Robusta", Price: 60000, Stock: 0, SKU: "KOPI-2", IsActive: false},
+	)
+	categories := repotest.NewCategories(
+		models.Category{ID: 1, Name: "Beverages", IsActive: true},
+		models.Category{ID: 2, Name: "Archive", IsActive: false},
+	)
+	return NewService(products, categories).(*service), products, categories
+}
+
+func price(v int64) *int64 { return &v }
+
+func TestListProducts(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     ProductQuery
+		wantIDs   []uint
+		wantTotal int64
+		wantCode  apperror.Code
+	}{
+		{
+			name:      "active only",
+			query:     ProductQuery{Page: repository.NewPage(1, 10)},
+			wantIDs:   []uint{1, 2},
+			wantTotal: 2,
+		},
+		{
+			name:      "admin sees inactive",
+			query:     ProductQuery{Page: repository.NewPage(1, 10), IncludeInactive: true},
+			wantIDs:   []uint{1, 2, 3},
+			wantTotal: 3,
+		},
+		{
+			name:      "search and price range",
+			query:     ProductQuery{Page: repository.NewPage(1, 10), Search: "kopi", MinPrice: price(50000), MaxPrice: price(90000), IncludeInactive: true},
+			wantIDs:   []uint{1, 3},
+			wantTotal: 2,
+		},
+		{
+			name:      "second page",
+			query:     ProductQuery{Page: repository.NewPage(2, 1)},
+			wantIDs:   []uint{2},
+			wantTotal: 2,
+		},
+		{
+			name:     "inverted price range",
+			query:    ProductQuery{Page: repository.NewPage(1, 10), MinPrice: price(90000), MaxPrice: price(10000)},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService()
+			products, total, err := s.ListProducts(context.Background(), tt.query)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+			if len(products) != len(tt.wantIDs) {
+				t.Fatalf("got %d products, want %d", len(products), len(tt.wantIDs))
+			}
+			for i, id := range tt.wantIDs {
+				if products[i].ID != id {
+					t.Errorf("products[%d].ID = %d, want %d", i, products[i].ID, id)
+				}
+			}
+		})
+	}
+}
+
+func TestProductErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     func(s *service, products *repotest.Products) error
+		wantCode apperror.Code
+	}{
+		{
+			name: "get unknown product",
+			call: func(s *service, _ *repotest.Products) error {
+				_, err := s.GetProduct(context.Background(), 99)
+				return err
+			},
+			wantCode: apperror.CodeProductNotFound,
+		},
+		{
+			name: "create with taken SKU",
+			call: func(s *service, _ *repotest.Products) error {
+				return s.CreateProduct(context.Background(), &models.Product{Name: "Copy", Price: 1000, SKU: "KOPI-1"})
+			},
+			wantCode: apperror.CodeSKUTaken,
+		},
+		{
+			name: "delete unknown product",
+			call: func(s *service, _ *repotest.Products) error {
+				return s.DeleteProduct(context.Background(), 99)
+			},
+			wantCode: apperror.CodeProductNotFound,
+		},
+		{
+			name: "database failure",
+			call: func(s *service, products *repotest.Products) error {
+				products.Err = errors.New("connection reset")
+				_, err := s.GetAdminProduct(context.Background(), 1)
+				return err
+			},
+			wantCode: apperror.CodeInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, products, _ := newTestService()
+			if code := repotest.Code(tt.call(s, products)); code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCreateProductStartsAtVersionOne(t *testing.T) {
+	s, _, _ := newTestService()
+	product := models.Product{Name: "Gula Aren", Price: 15000, Version: 7}
+	if err := s.CreateProduct(context.Background(), &product); err != nil {
+		t.Fatal(err)
+	}
+	if product.Version != 1 {
+		t.Errorf("Version = %d, want 1", product.Version)
+	}
+}
+
+func TestUpdateProduct(t *testing.T) {
+	tests := []struct {
+		name        string
+		expected    uint
+		wantUpdated bool
+		wantPrice   int64
+		wantVersion uint
+	}{
+		{"current version", 1, true, 90000, 2},
+		{"stale version", 0, false, 85000, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService()
+			product, updated, err := s.UpdateProduct(context.Background(), 1, tt.expected,
+				map[string]interface{}{"price": int64(90000)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("updated = %v, want %v", updated, tt.wantUpdated)
+			}
+			if product.Price != tt.wantPrice || product.Version != tt.wantVersion {
+				t.Errorf("product = price %d version %d, want price %d version %d",
+					product.Price, product.Version, tt.wantPrice, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestCategories(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     func(s *service) error
+		wantCode apperror.Code
+	}{
+		{
+			name: "create new",
+			call: func(s *service) error {
+				return s.CreateCategory(context.Background(), &models.Category{Name: "Snacks"})
+			},
+		},
+		{
+			name: "create duplicate",
+			call: func(s *service) error {
+				return s.CreateCategory(context.Background(), &models.Category{Name: "Beverages"})
+			},
+			wantCode: apperror.CodeCategoryExists,
+		},
+		{
+			name: "rename onto another",
+			call: func(s *service) error {
+				return s.UpdateCategory(context.Background(), &models.Category{ID: 2, Name: "Beverages"})
+			},
+			wantCode: apperror.CodeCategoryExists,
+		},
+		{
+			name: "get unknown",
+			call: func(s *service) error {
+				_, err := s.GetCategory(context.Background(), 99)
+				return err
+			},
+			wantCode: apperror.CodeCategoryNotFound,
+		},
+		{
+			name: "delete unknown",
+			call: func(s *service) error {
+				return s.DeleteCategory(context.Background(), 99)
+			},
+			wantCode: apperror.CodeCategoryNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService()
+			if code := repotest.Code(tt.call(s)); code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestListCategoriesActiveOnly(t *testing.T) {
+	s, _, _ := newTestService()
+	categories, err := s.ListCategories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 1 || categories[0].Name != "Beverages" {
+		t.Errorf("categories = %+v, want only Beverages", categories)
+	}
+}
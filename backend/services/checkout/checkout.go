@@ -0,0 +1,364 @@
+// Package checkout turns a user's cart into an order, or a guest's cart into
+// an order they can look up with an emailed link
+package checkout
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/mail"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/cart"
+	"ecommerce-backend/services/users"
+
+	"github.com/google/uuid"
+)
+
+// Request is what the customer submits at checkout
+type Request struct {
+	UserID          uint
+	ShippingAddress models.Address
+	PaymentMethod   string
+	Notes           string
+}
+
+// GuestRequest is what a shopper without an account submits at checkout.
+// Their cart only lives in the browser, so it comes with the request.
+type GuestRequest struct {
+	Email           string
+	FirstName       string
+	LastName        string
+	Phone           string
+	Items           []cart.GuestItem
+	ShippingAddress models.Address
+	PaymentMethod   string
+	Notes           string
+}
+
+// CheckoutService places orders
+type CheckoutService interface {
+	// PlaceOrder creates a pending order from the user's active cart,
+	// takes the ordered quantities out of stock and starts a new empty
+	// cart. Nothing changes unless every step succeeds.
+	PlaceOrder(ctx context.Context, req Request) (models.Order, error)
+	// PlaceGuestOrder creates a pending order for the guest's items, linked
+	// to the guest customer with their email, who is created with their
+	// first order. It returns the order and the secret token it can be
+	// looked up with, and emails the customer a link carrying the token.
+	// An email that belongs to an account has to sign in instead.
+	PlaceGuestOrder(ctx context.Context, req GuestRequest) (order models.Order, token string, err error)
+}
+
+type service struct {
+	tx        repository.Transactor
+	carts     repository.CartRepository
+	products  repository.ProductRepository
+	orders    repository.OrderRepository
+	addresses repository.AddressRepository
+	users     repository.UserRepository
+	mailer    mail.Sender
+	store     config.StoreConfig
+
+	// orderNumber returns the number for an order placed at the given time
+	orderNumber func(time.Time) string
+	now         func() time.Time
+}
+
+// NewService returns a CheckoutService backed by the given repositories.
+// Guests are emailed through mailer, with links to store's storefront.
+func NewService(tx repository.Transactor, carts repository.CartRepository, products repository.ProductRepository,
+	orders repository.OrderRepository, addresses repository.AddressRepository, users repository.UserRepository,
+	mailer mail.Sender, store config.StoreConfig) CheckoutService {
+	return &service{
+		tx:          tx,
+		carts:       carts,
+		products:    products,
+		orders:      orders,
+		addresses:   addresses,
+		users:       users,
+		mailer:      mailer,
+		store:       store,
+		orderNumber: OrderNumber,
+		now:         time.Now,
+	}
+}
+
+func (s *service) PlaceOrder(ctx context.Context, req Request) (models.Order, error) {
+	var order models.Order
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		active, err := s.carts.GetActiveWithItems(ctx, req.UserID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return apperror.Internal("Failed to get cart items", err)
+		}
+		items := active.CartItems
+		if len(items) == 0 {
+			return apperror.BadRequest(apperror.CodeCartEmpty, "Cart is empty")
+		}
+
+		for _, item := range items {
+			if item.Product.ID == 0 {
+				return apperror.Internal("Product not found for cart item",
+					fmt.Errorf("cart item %d references missing product %d", item.ID, item.ProductID))
+			}
+		}
+
+		order = models.Order{
+			UserID:        req.UserID,
+			PaymentMethod: req.PaymentMethod,
+			Notes:         req.Notes,
+		}
+		if err := s.create(ctx, &order, items, req.ShippingAddress); err != nil {
+			return err
+		}
+
+		// Retire the checked-out cart and start a fresh one
+		if err := s.carts.ClearItems(ctx, active.ID); err != nil {
+			return apperror.Internal("Failed to clear cart", err)
+		}
+		if err := s.carts.Deactivate(ctx, active.ID); err != nil {
+			return apperror.Internal("Failed to deactivate cart", err)
+		}
+		if err := s.carts.Create(ctx, &models.Cart{UserID: req.UserID, IsActive: true}); err != nil {
+			return apperror.Internal("Failed to create cart", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	return order, nil
+}
+
+func (s *service) PlaceGuestOrder(ctx context.Context, req GuestRequest) (models.Order, string, error) {
+	req.Email = users.NormalizeEmail(req.Email)
+	token, hash, err := NewLookupToken()
+	if err != nil {
+		return models.Order{}, "", apperror.Internal("Failed to create order lookup token", err)
+	}
+
+	var order models.Order
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		items, err := s.guestItems(ctx, req.Items)
+		if err != nil {
+			return err
+		}
+		customer, err := s.guest(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		order = models.Order{
+			UserID:          customer.ID,
+			PaymentMethod:   req.PaymentMethod,
+			Notes:           req.Notes,
+			LookupTokenHash: &hash,
+		}
+		return s.create(ctx, &order, items, req.ShippingAddress)
+	})
+	if err != nil {
+		return models.Order{}, "", err
+	}
+
+	// The order stands even if the email does not go out; the customer
+	// also gets the token in the response
+	if err := s.mailer.Send(ctx, s.confirmation(req.Email, order, token)); err != nil {
+		slog.ErrorContext(ctx, "Failed to email order lookup link", "order_id", order.ID, "error", err)
+	}
+	return order, token, nil
+}
+
+// create takes the items out of stock and saves the order, shipped to
+// address, with the items priced as their products are now. The caller sets
+// who the order is for and how it is paid.
+func (s *service) create(ctx context.Context, order *models.Order, items []models.CartItem, address models.Address) error {
+	for _, item := range items {
+		if err := s.takeStock(ctx, item); err != nil {
+			return err
+		}
+	}
+
+	address.ID = 0
+	address.UserID = order.UserID
+	if err := s.addresses.Create(ctx, &address); err != nil {
+		return apperror.Internal("Failed to save shipping address", err)
+	}
+
+	order.OrderNumber = s.orderNumber(s.now())
+	order.Status = "pending"
+	order.PaymentStatus = "unpaid"
+	order.ShippingAddress = FormatAddress(address)
+	order.Version = 1
+	order.OrderItems = make([]models.OrderItem, len(items))
+	for i, item := range items {
+		order.OrderItems[i] = cart.OrderItem(item.Product, item.Quantity)
+	}
+	SetTotals(order)
+	if err := s.orders.Create(ctx, order); err != nil {
+		return apperror.Internal("Failed to create order", err)
+	}
+	return nil
+}
+
+// guestItems loads the products in a guest's cart, adding up lines for the
+// same product. Products that are gone or no longer sold fail the checkout,
+// so the guest is not charged for a cart other than the one they saw.
+func (s *service) guestItems(ctx context.Context, lines []cart.GuestItem) ([]models.CartItem, error) {
+	var items []models.CartItem
+	seen := make(map[uint]int)
+	for _, line := range lines {
+		if line.Quantity < 1 {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Quantity must be at least 1").
+				With("product_id", line.ProductID)
+		}
+		if i, ok := seen[line.ProductID]; ok {
+			items[i].Quantity += line.Quantity
+			continue
+		}
+
+		product, err := s.products.Get(ctx, line.ProductID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && !product.IsActive) {
+			return nil, apperror.BadRequest(apperror.CodeProductNotFound, "Product is no longer available").
+				With("product_id", line.ProductID)
+		}
+		if err != nil {
+			return nil, apperror.Internal("Failed to get product", err)
+		}
+		seen[line.ProductID] = len(items)
+		items = append(items, models.CartItem{ProductID: product.ID, Quantity: line.Quantity, Product: product})
+	}
+	if len(items) == 0 {
+		return nil, apperror.BadRequest(apperror.CodeCartEmpty, "Cart is empty")
+	}
+	return items, nil
+}
+
+// guest returns the guest customer with the request's email, creating them
+// on their first order
+func (s *service) guest(ctx context.Context, req GuestRequest) (models.User, error) {
+	customer, err := s.users.GetByEmail(ctx, req.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		customer = models.User{
+			FirstName: req.FirstName,
+			LastName:  req.LastName,
+			Email:     req.Email,
+			Phone:     req.Phone,
+			Role:      users.RoleUser,
+			IsActive:  true,
+			IsGuest:   true,
+		}
+		created, err := s.users.CreateIfNew(ctx, &customer)
+		if err != nil {
+			return models.User{}, apperror.Internal("Failed to create guest customer", err)
+		}
+		if created {
+			return customer, nil
+		}
+		// A concurrent first checkout, or a sign-up, took the email first
+		customer, err = s.users.GetByEmail(ctx, req.Email)
+		if err != nil {
+			return models.User{}, apperror.Internal("Failed to look up customer", err)
+		}
+	} else if err != nil {
+		return models.User{}, apperror.Internal("Failed to look up customer", err)
+	}
+
+	if !customer.IsGuest {
+		return models.User{}, apperror.Conflict(apperror.CodeEmailTaken,
+			"An account already uses this email; sign in to check out")
+	}
+	return customer, nil
+}
+
+// confirmation is the email a guest gets for their order, with the link to
+// look it up
+func (s *service) confirmation(to string, order models.Order, token string) mail.Message {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Thank you for your order %s.\n\n", order.OrderNumber)
+	fmt.Fprintf(&b, "Follow its progress at any time here:\n%s\n\n", LookupURL(s.store.URL, token))
+	b.WriteString("Anyone with this link can see your order, so please keep it to yourself. ")
+	b.WriteString("To find all your orders in one place, create an account with this email address; ")
+	b.WriteString("we will email you a link to confirm it is yours.\n\n")
+	b.WriteString(s.store.Name + "\n")
+	return mail.Message{
+		To:      to,
+		Subject: fmt.Sprintf("Your order %s at %s", order.OrderNumber, s.store.Name),
+		Body:    b.String(),
+	}
+}
+
+// takeStock reserves the item's quantity, failing with INSUFFICIENT_STOCK
+// and the units left when another order got there first
+func (s *service) takeStock(ctx context.Context, item models.CartItem) error {
+	ok, err := s.products.DecrementStock(ctx, item.ProductID, item.Quantity)
+	if err != nil {
+		return apperror.Internal("Failed to update stock", err)
+	}
+	if ok {
+		return nil
+	}
+
+	available := item.Product.Stock
+	if current, err := s.products.Get(ctx, item.ProductID); err == nil {
+		available = current.Stock
+	}
+	return apperror.BadRequest(apperror.CodeInsufficientStock,
+		fmt.Sprintf("Only %d of %q left in stock", available, item.Product.Name)).
+		With("product_id", item.ProductID).
+		With("available", available)
+}
+
+// SetTotals prices the order's items and sets its subtotal, tax, shipping
+// and total to match. Every order is priced this way, whether it is placed at
+// checkout or drafted and edited by an admin.
+func SetTotals(order *models.Order) {
+	totals := cart.PriceOrderItems(order.OrderItems)
+	order.Subtotal = totals.Subtotal
+	order.Tax = totals.Tax
+	order.ShippingCost = totals.Shipping
+	order.TotalAmount = totals.Total
+}
+
+// FormatAddress renders an address on the single line stored with orders
+func FormatAddress(a models.Address) string {
+	return fmt.Sprintf("%s, %s, %s, %s", a.Address, a.City, a.Province, a.PostalCode)
+}
+
+// OrderNumber returns a new, random number for an order created at the
+// given time, e.g. ORD-20240309-1a2b3c4d
+func OrderNumber(at time.Time) string {
+	return fmt.Sprintf("ORD-%s-%s", at.Format("20060102"), uuid.New().String()[:8])
+}
+
+// NewLookupToken returns a random token for looking up a guest order, and
+// the hash stored with the order in its place
+func NewLookupToken() (token, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(secret)
+	return token, HashLookupToken(token), nil
+}
+
+// HashLookupToken returns the hash an order's lookup token is stored as
+func HashLookupToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// LookupURL is the storefront page at base that shows the order with token
+func LookupURL(base, token string) string {
+	return strings.TrimRight(base, "/") + "/orders/lookup?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,323 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/mail"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/repository/repotest"
+	"ecommerce-backend/services/cart"
+)
+
+const customer uint = 7
+
+type fixture struct {
+	service   *service
+	products  *repotest.Products
+	carts     *repotest.Carts
+	orders    *repotest.Orders
+	addresses *repotest.Addresses
+	users     *repotest.Users
+	mail      *mail.Fake
+}
+
+func newFixture() fixture {
+	products := repotest.NewProducts(
+		models.Product{ID: 1, Name: "Kopi Arabika", Price: 85000, Stock: 5, IsActive: true},
+		models.Product{ID: 2, Name: "Teh Melati", Price: 25000, Stock: 2, IsActive: true},
+		models.Product{ID: 3, Name: "Kopi Luwak", Price: 450000, Stock: 4, IsActive: false},
+	)
+	f := fixture{
+		products:  products,
+		carts:     repotest.NewCarts(products),
+		orders:    repotest.NewOrders(),
+		addresses: &repotest.Addresses{},
+		users:     repotest.NewUsers(models.User{Email: "budi@example.com", Role: "user", IsActive: true}),
+		mail:      mail.NewFake(),
+	}
+	store := config.StoreConfig{Name: "Toko Kopi", URL: "https://toko.example.com/"}
+	f.service = NewService(repotest.Transactor{}, f.carts, f.products, f.orders, f.addresses, f.users, f.mail, store).(*service)
+	f.service.now = func() time.Time { return time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC) }
+	f.service.orderNumber = func(at time.Time) string { return "ORD-" + at.Format("20060102") + "-TEST" }
+	return f
+}
+
+func request() Request {
+	return Request{
+		UserID: customer,
+		ShippingAddress: models.Address{
+			Address:    "Jl. Sudirman No. 1",
+			City:       "Jakarta",
+			Province:   "DKI Jakarta",
+			PostalCode: "10220",
+		},
+		PaymentMethod: "bank_transfer",
+		Notes:         "Leave at the door",
+	}
+}
+
+func TestPlaceOrderRejects(t *testing.T) {
+	tests := []struct {
+		name          string
+		cart          []models.CartItem
+		noCart        bool
+		wantCode      apperror.Code
+		wantAvailable int
+	}{
+		{name: "no cart", noCart: true, wantCode: apperror.CodeCartEmpty},
+		{name: "empty cart", wantCode: apperror.CodeCartEmpty},
+		{
+			name:          "more than in stock",
+			cart:          []models.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 3}},
+			wantCode:      apperror.CodeInsufficientStock,
+			wantAvailable: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			if !tt.noCart {
+				f.carts.Fill(customer, tt.cart...)
+			}
+
+			_, err := f.service.PlaceOrder(context.Background(), request())
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantAvailable > 0 {
+				var appErr *apperror.Error
+				if !errors.As(err, &appErr) || appErr.Extra["available"] != tt.wantAvailable || appErr.Extra["product_id"] != uint(2) {
+					t.Errorf("extra = %v, want product_id 2 with %d available", appErr.Extra, tt.wantAvailable)
+				}
+			}
+			if len(f.orders.Rows) != 0 {
+				t.Error("an order was created")
+			}
+		})
+	}
+}
+
+func TestPlaceOrder(t *testing.T) {
+	f := newFixture()
+	cart := f.carts.Fill(customer,
+		models.CartItem{ProductID: 1, Quantity: 2},
+		models.CartItem{ProductID: 2, Quantity: 2},
+	)
+
+	order, err := f.service.PlaceOrder(context.Background(), request())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 x 85000 + 2 x 25000 = 220000, plus 10% tax and flat shipping
+	checks := []struct {
+		field     string
+		got, want interface{}
+	}{
+		{"order number", order.OrderNumber, "ORD-20240309-TEST"},
+		{"status", order.Status, "pending"},
+		{"payment status", order.PaymentStatus, "unpaid"},
+		{"subtotal", order.Subtotal, int64(220000)},
+		{"tax", order.Tax, int64(22000)},
+		{"shipping", order.ShippingCost, int64(10000)},
+		{"total", order.TotalAmount, int64(252000)},
+		{"shipping address", order.ShippingAddress, "Jl. Sudirman No. 1, Jakarta, DKI Jakarta, 10220"},
+		{"items", len(order.OrderItems), 2},
+		{"first item total", order.OrderItems[0].TotalPrice, int64(170000)},
+		{"first item name", order.OrderItems[0].ProductName, "Kopi Arabika"},
+		{"first item tax", order.OrderItems[0].TaxAmount, int64(17000)},
+		{"first item tax percent", order.OrderItems[0].TaxPercent, int64(10)},
+		{"stock left of 1", f.products.Rows[1].Stock, 3},
+		{"stock left of 2", f.products.Rows[2].Stock, 0},
+		{"saved addresses", len(f.addresses.Rows), 1},
+		{"address owner", f.addresses.Rows[0].UserID, customer},
+		{"old cart active", f.carts.Carts[cart.ID].IsActive, false},
+		{"old cart items", len(f.carts.ItemsIn(cart.ID)), 0},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.field, c.got, c.want)
+		}
+	}
+
+	fresh, err := f.carts.GetActive(context.Background(), customer)
+	if err != nil || fresh.ID == cart.ID {
+		t.Errorf("no new active cart after checkout (got %+v, %v)", fresh, err)
+	}
+}
+
+func guestRequest(email string, items ...cart.GuestItem) GuestRequest {
+	r := request()
+	return GuestRequest{
+		Email:           email,
+		FirstName:       "Sari",
+		LastName:        "Wulandari",
+		Items:           items,
+		ShippingAddress: r.ShippingAddress,
+		PaymentMethod:   r.PaymentMethod,
+	}
+}
+
+func TestPlaceGuestOrderRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      GuestRequest
+		wantCode apperror.Code
+	}{
+		{name: "no items", req: guestRequest("sari@example.com"), wantCode: apperror.CodeCartEmpty},
+		{
+			name:     "unknown product",
+			req:      guestRequest("sari@example.com", cart.GuestItem{ProductID: 9, Quantity: 1}),
+			wantCode: apperror.CodeProductNotFound,
+		},
+		{
+			name:     "product no longer sold",
+			req:      guestRequest("sari@example.com", cart.GuestItem{ProductID: 3, Quantity: 1}),
+			wantCode: apperror.CodeProductNotFound,
+		},
+		{
+			name:     "zero quantity",
+			req:      guestRequest("sari@example.com", cart.GuestItem{ProductID: 1, Quantity: 0}),
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			// Two lines of the same tea add up to more than the 2 in stock
+			name: "more than in stock",
+			req: guestRequest("sari@example.com",
+				cart.GuestItem{ProductID: 2, Quantity: 2}, cart.GuestItem{ProductID: 2, Quantity: 1}),
+			wantCode: apperror.CodeInsufficientStock,
+		},
+		{
+			name:     "email of an account",
+			req:      guestRequest("budi@example.com", cart.GuestItem{ProductID: 1, Quantity: 1}),
+			wantCode: apperror.CodeEmailTaken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+
+			_, _, err := f.service.PlaceGuestOrder(context.Background(), tt.req)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if len(f.orders.Rows) != 0 || len(f.mail.Sent()) != 0 {
+				t.Error("an order was created or emailed")
+			}
+		})
+	}
+}
+
+func TestPlaceGuestOrder(t *testing.T) {
+	f := newFixture()
+	ctx := context.Background()
+	req := guestRequest("sari@example.com", cart.GuestItem{ProductID: 1, Quantity: 1}, cart.GuestItem{ProductID: 1, Quantity: 1})
+
+	order, token, err := f.service.PlaceGuestOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest, err := f.users.GetByEmail(ctx, "sari@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !guest.IsGuest || guest.FirstName != "Sari" || order.UserID != guest.ID {
+		t.Errorf("order is for user %d, want new guest %+v", order.UserID, guest)
+	}
+	if len(order.OrderItems) != 1 || order.OrderItems[0].Quantity != 2 || order.TotalAmount != 197000 {
+		t.Errorf("order = %+v, want one line of 2 coffees totalling 197000", order)
+	}
+	if f.products.Rows[1].Stock != 3 {
+		t.Errorf("stock left = %d, want 3", f.products.Rows[1].Stock)
+	}
+
+	// Only the token's hash is stored, and it finds the order
+	if token == "" || order.LookupTokenHash == nil || *order.LookupTokenHash == token {
+		t.Fatalf("token %q stored as %v", token, order.LookupTokenHash)
+	}
+	if found, err := f.orders.GetByLookupToken(ctx, HashLookupToken(token)); err != nil || found.ID != order.ID {
+		t.Errorf("lookup by token = %d, %v; want order %d", found.ID, err, order.ID)
+	}
+
+	sent := f.mail.Sent()
+	if len(sent) != 1 || sent[0].To != "sari@example.com" {
+		t.Fatalf("sent %+v, want one email to the guest", sent)
+	}
+	link := "https://toko.example.com/orders/lookup?token=" + token
+	if !strings.Contains(sent[0].Body, link) || !strings.Contains(sent[0].Subject, order.OrderNumber) {
+		t.Errorf("email = %+v, want order number and link %s", sent[0], link)
+	}
+
+	// A second order by the same guest, still placed when the email fails
+	f.mail.Err = errors.New("smtp down")
+	f.service.orderNumber = func(time.Time) string { return "ORD-20240309-TEST2" }
+	// The email is matched whatever its case and surrounding space
+	second, other, err := f.service.PlaceGuestOrder(ctx, guestRequest(" Sari@Example.COM ", cart.GuestItem{ProductID: 2, Quantity: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.UserID != guest.ID || len(f.users.Rows) != 2 || other == token {
+		t.Errorf("second order is for user %d with %d users, want guest %d reused with a new token",
+			second.UserID, len(f.users.Rows), guest.ID)
+	}
+}
+
+// racingUsers creates rival right after a lookup of their email misses, as
+// a concurrent checkout or sign-up with the same email would
+type racingUsers struct {
+	*repotest.Users
+	rival *models.User
+}
+
+func (u *racingUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	user, err := u.Users.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) && u.rival != nil {
+		_ = u.Users.Create(ctx, u.rival)
+		u.rival = nil
+	}
+	return user, err
+}
+
+func TestPlaceGuestOrderConcurrentFirstOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		rival    models.User
+		wantCode apperror.Code
+	}{
+		{name: "another first checkout", rival: models.User{Email: "sari@example.com", IsGuest: true}},
+		{name: "a sign-up", rival: models.User{Email: "sari@example.com"}, wantCode: apperror.CodeEmailTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			rival := tt.rival
+			f.service.users = &racingUsers{Users: f.users, rival: &rival}
+
+			order, _, err := f.service.PlaceGuestOrder(context.Background(),
+				guestRequest("sari@example.com", cart.GuestItem{ProductID: 1, Quantity: 1}))
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode == "" && (rival.ID == 0 || order.UserID != rival.ID) {
+				t.Errorf("order is for user %d, want the guest %d created meanwhile", order.UserID, rival.ID)
+			}
+			if len(f.users.Rows) != 2 {
+				t.Errorf("%d users, want the rival added and no other", len(f.users.Rows))
+			}
+		})
+	}
+}
+
+func TestFormatAddress(t *testing.T) {
+	got := FormatAddress(models.Address{Address: "Jl. Braga 5", City: "Bandung", Province: "Jawa Barat", PostalCode: "40111"})
+	if want := "Jl. Braga 5, Bandung, Jawa Barat, 40111"; got != want {
+		t.Errorf("FormatAddress() = %q, want %q", got, want)
+	}
+}
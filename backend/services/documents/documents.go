@@ -0,0 +1,145 @@
+// Package documents renders the printable documents of orders: invoices,
+// which customers and admins can download once an order is paid, and packing
+// slips for the warehouse
+package documents
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/printing"
+	"ecommerce-backend/repository"
+)
+
+// MaxBatch is the most orders printed in one document
+const MaxBatch = 100
+
+// Document is a rendered PDF and the name to download it as
+type Document struct {
+	Filename string
+	Content  []byte
+}
+
+// DocumentService renders order documents
+type DocumentService interface {
+	// InvoiceForUser renders the invoice of one of the customer's orders
+	InvoiceForUser(ctx context.Context, userID, orderID uint) (Document, error)
+	// Invoices renders the invoices of the orders, in the order given, as
+	// one document. Every order must have been issued an invoice.
+	Invoices(ctx context.Context, orderIDs []uint) (Document, error)
+	// PackingSlips renders the packing slips of the orders, in the order
+	// given, as one document
+	PackingSlips(ctx context.Context, orderIDs []uint) (Document, error)
+}
+
+type service struct {
+	orders repository.OrderRepository
+	store  config.StoreConfig
+}
+
+// NewService returns a DocumentService that prints store's details on the
+// orders it reads from orders
+func NewService(orders repository.OrderRepository, store config.StoreConfig) DocumentService {
+	return &service{orders: orders, store: store}
+}
+
+func (s *service) InvoiceForUser(ctx context.Context, userID, orderID uint) (Document, error) {
+	order, err := s.orders.Get(ctx, orderID)
+	if errors.Is(err, repository.ErrNotFound) || err == nil && order.UserID != userID {
+		return Document{}, apperror.NotFound(apperror.CodeOrderNotFound, "Order not found")
+	}
+	if err != nil {
+		return Document{}, apperror.Internal("Failed to get order", err)
+	}
+	return s.invoices([]models.Order{order})
+}
+
+func (s *service) Invoices(ctx context.Context, orderIDs []uint) (Document, error) {
+	orders, err := s.load(ctx, orderIDs)
+	if err != nil {
+		return Document{}, err
+	}
+	return s.invoices(orders)
+}
+
+func (s *service) PackingSlips(ctx context.Context, orderIDs []uint) (Document, error) {
+	orders, err := s.load(ctx, orderIDs)
+	if err != nil {
+		return Document{}, err
+	}
+	filename := "packing-slips.pdf"
+	if len(orders) == 1 {
+		filename = fmt.Sprintf("packing-slip-%s.pdf", orders[0].OrderNumber)
+	}
+	var buf bytes.Buffer
+	if err := printing.PackingSlips(&buf, s.store, orders); err != nil {
+		return Document{}, apperror.Internal("Failed to render packing slips", err)
+	}
+	return Document{Filename: filename, Content: buf.Bytes()}, nil
+}
+
+func (s *service) invoices(orders []models.Order) (Document, error) {
+	var unissued []uint
+	for _, order := range orders {
+		if order.InvoiceNumber == nil {
+			unissued = append(unissued, order.ID)
+		}
+	}
+	if len(unissued) > 0 {
+		return Document{}, apperror.Conflict(apperror.CodeInvoiceNotIssued,
+			"Invoices are issued once an order is paid").With("order_ids", unissued)
+	}
+
+	filename := "invoices.pdf"
+	if len(orders) == 1 {
+		filename = *orders[0].InvoiceNumber + ".pdf"
+	}
+	var buf bytes.Buffer
+	if err := printing.Invoices(&buf, s.store, orders); err != nil {
+		return Document{}, apperror.Internal("Failed to render invoices", err)
+	}
+	return Document{Filename: filename, Content: buf.Bytes()}, nil
+}
+
+// load returns the orders once each, in the order asked for
+func (s *service) load(ctx context.Context, ids []uint) ([]models.Order, error) {
+	seen := make(map[uint]bool, len(ids))
+	var unique []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "No orders to print")
+	}
+	if len(unique) > MaxBatch {
+		return nil, apperror.BadRequest(apperror.CodeInvalidRequest,
+			fmt.Sprintf("At most %d orders can be printed at once", MaxBatch))
+	}
+
+	orders, err := s.orders.GetMany(ctx, unique)
+	if err != nil {
+		return nil, apperror.Internal("Failed to get orders", err)
+	}
+	if len(orders) < len(unique) {
+		found := make(map[uint]bool, len(orders))
+		for _, order := range orders {
+			found[order.ID] = true
+		}
+		var missing []uint
+		for _, id := range unique {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+		return nil, apperror.NotFound(apperror.CodeOrderNotFound, "Order not found").With("order_ids", missing)
+	}
+	return orders, nil
+}
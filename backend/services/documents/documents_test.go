@@ -0,0 +1,82 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository/repotest"
+)
+
+func newTestService() *service {
+	invoice := "INV-2024-000001"
+	items := []models.OrderItem{{ProductID: 1, ProductName: "Kopi Arabika", ProductSKU: "KOP-001",
+		Quantity: 1, UnitPrice: 85000, TotalPrice: 85000}}
+	orders := repotest.NewOrders(
+		models.Order{UserID: 1, OrderNumber: "ORD-20240309-0001", PaymentStatus: "paid", InvoiceNumber: &invoice, OrderItems: items},
+		models.Order{UserID: 1, OrderNumber: "ORD-20240309-0002", PaymentStatus: "unpaid", OrderItems: items},
+		models.Order{UserID: 2, OrderNumber: "ORD-20240309-0003", PaymentStatus: "unpaid", OrderItems: items},
+	)
+	return NewService(orders, config.StoreConfig{Name: "Toko Kopi"}).(*service)
+}
+
+func TestInvoiceForUser(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	doc, err := s.InvoiceForUser(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Filename != "INV-2024-000001.pdf" || !bytes.HasPrefix(doc.Content, []byte("%PDF-")) {
+		t.Errorf("invoice = %s of %d bytes, want INV-2024-000001.pdf", doc.Filename, len(doc.Content))
+	}
+
+	if _, err := s.InvoiceForUser(ctx, 1, 2); repotest.Code(err) != apperror.CodeInvoiceNotIssued {
+		t.Errorf("unpaid order: %v, want INVOICE_NOT_ISSUED", err)
+	}
+	if _, err := s.InvoiceForUser(ctx, 2, 1); repotest.Code(err) != apperror.CodeOrderNotFound {
+		t.Errorf("another customer's order: %v, want ORDER_NOT_FOUND", err)
+	}
+}
+
+func TestBatches(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+	tooMany := make([]uint, MaxBatch+1)
+	for i := range tooMany {
+		tooMany[i] = uint(i + 1)
+	}
+
+	tests := []struct {
+		name         string
+		ids          []uint
+		invoices     apperror.Code
+		packingSlips apperror.Code
+		filename     string
+	}{
+		{name: "one paid order", ids: []uint{1}, filename: "packing-slip-ORD-20240309-0001.pdf"},
+		{name: "repeated", ids: []uint{1, 1}, filename: "packing-slip-ORD-20240309-0001.pdf"},
+		{name: "unpaid among them", ids: []uint{1, 2, 3}, invoices: apperror.CodeInvoiceNotIssued, filename: "packing-slips.pdf"},
+		{name: "missing", ids: []uint{1, 99}, invoices: apperror.CodeOrderNotFound, packingSlips: apperror.CodeOrderNotFound},
+		{name: "none", invoices: apperror.CodeInvalidRequest, packingSlips: apperror.CodeInvalidRequest},
+		{name: "too many", ids: tooMany, invoices: apperror.CodeInvalidRequest, packingSlips: apperror.CodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Invoices(ctx, tt.ids); repotest.Code(err) != tt.invoices {
+				t.Errorf("invoices: %v, want code %q", err, tt.invoices)
+			}
+			doc, err := s.PackingSlips(ctx, tt.ids)
+			if code := repotest.Code(err); code != tt.packingSlips {
+				t.Fatalf("packing slips: %v, want code %q", err, tt.packingSlips)
+			}
+			if err == nil && (doc.Filename != tt.filename || !bytes.HasPrefix(doc.Content, []byte("%PDF-"))) {
+				t.Errorf("packing slips = %s of %d bytes, want %s", doc.Filename, len(doc.Content), tt.filename)
+			}
+		})
+	}
+}
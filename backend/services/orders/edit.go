@@ -0,0 +1,361 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/cart"
+	"ecommerce-backend/services/checkout"
+)
+
+// Actions recorded in an order's changes
+const (
+	ChangeCreated = "created"
+	ChangeEdited  = "edited"
+	ChangePlaced  = "placed"
+)
+
+// Editable reports whether an admin may still edit an order in status
+func Editable(status string) bool {
+	return status == StatusDraft || status == StatusPending
+}
+
+// Line is a quantity of a product on an order an admin drafts or edits
+type Line struct {
+	ProductID uint
+	Quantity  int
+}
+
+// Draft is an order an admin starts for a customer, e.g. one taken over the
+// phone
+type Draft struct {
+	UserID          uint
+	Lines           []Line
+	ShippingAddress models.Address
+	PaymentMethod   string
+	Notes           string
+}
+
+// Edit is an admin's change to a draft or pending order. Nil fields are left
+// as they are. Lines, when set, become the order's lines: products already on
+// the order keep the price they were ordered at, and new ones are added at
+// their current price.
+type Edit struct {
+	Lines           []Line
+	ShippingAddress *models.Address
+	PaymentMethod   *string
+	Notes           *string
+}
+
+func (s *service) CreateDraft(ctx context.Context, adminID uint, d Draft) (models.Order, error) {
+	var id uint
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.users.Get(ctx, d.UserID); errors.Is(err, repository.ErrNotFound) {
+			return apperror.NotFound(apperror.CodeUserNotFound, "Customer not found")
+		} else if err != nil {
+			return apperror.Internal("Failed to get customer", err)
+		}
+		items, err := s.lineItems(ctx, nil, d.Lines)
+		if err != nil {
+			return err
+		}
+
+		order := models.Order{
+			UserID:          d.UserID,
+			OrderNumber:     checkout.OrderNumber(s.now()),
+			Status:          StatusDraft,
+			PaymentMethod:   d.PaymentMethod,
+			PaymentStatus:   "unpaid",
+			ShippingAddress: checkout.FormatAddress(d.ShippingAddress),
+			Notes:           d.Notes,
+			Version:         1,
+			OrderItems:      items,
+		}
+		checkout.SetTotals(&order)
+		if err := s.orders.Create(ctx, &order); err != nil {
+			return apperror.Internal("Failed to create order", err)
+		}
+		id = order.ID
+		return s.record(ctx, adminID, order.ID, ChangeCreated, describeItems(nil, items), 0, order.TotalAmount)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	order, err := s.orders.Get(ctx, id)
+	return order, orderError(err, "Failed to fetch created order")
+}
+
+func (s *service) Edit(ctx context.Context, adminID, id, expected uint, e Edit) (models.Order, bool, error) {
+	updated := false
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orders.Lock(ctx, id); err != nil {
+			return orderError(err, "Failed to get order")
+		}
+		order, err := s.orders.Get(ctx, id)
+		if err != nil {
+			return orderError(err, "Failed to get order")
+		}
+		if order.Version != expected {
+			return nil
+		}
+		if !Editable(order.Status) {
+			return apperror.Conflict(apperror.CodeOrderNotEditable, "Only draft and pending orders can be edited").
+				With("order_status", order.Status)
+		}
+
+		changes := map[string]interface{}{}
+		var summary []string
+		total := order.TotalAmount
+		if e.Lines != nil {
+			items, err := s.lineItems(ctx, order.OrderItems, e.Lines)
+			if err != nil {
+				return err
+			}
+			if described := describeItems(order.OrderItems, items); described != "" {
+				if order.PaymentStatus != "unpaid" {
+					return apperror.Conflict(apperror.CodeOrderNotEditable, "The items of a paid order cannot be changed").
+						With("payment_status", order.PaymentStatus)
+				}
+				// Drafts hold no stock until they are placed
+				if order.Status == StatusPending {
+					if err := s.moveStock(ctx, order.OrderItems, items); err != nil {
+						return err
+					}
+				}
+				if err := s.orders.ReplaceItems(ctx, order.ID, items); err != nil {
+					return apperror.Internal("Failed to update order items", err)
+				}
+
+				repriced := models.Order{OrderItems: items}
+				checkout.SetTotals(&repriced)
+				changes["subtotal"] = repriced.Subtotal
+				changes["tax"] = repriced.Tax
+				changes["shipping_cost"] = repriced.ShippingCost
+				changes["total_amount"] = repriced.TotalAmount
+				total = repriced.TotalAmount
+				summary = append(summary, described)
+			}
+		}
+		if e.ShippingAddress != nil {
+			if address := checkout.FormatAddress(*e.ShippingAddress); address != order.ShippingAddress {
+				changes["shipping_address"] = address
+				summary = append(summary, "Shipping address changed to "+address)
+			}
+		}
+		if e.PaymentMethod != nil && *e.PaymentMethod != order.PaymentMethod {
+			changes["payment_method"] = *e.PaymentMethod
+			summary = append(summary, "Payment method changed to "+*e.PaymentMethod)
+		}
+		if e.Notes != nil && *e.Notes != order.Notes {
+			changes["notes"] = *e.Notes
+			summary = append(summary, "Notes changed")
+		}
+
+		updated = true
+		if len(summary) == 0 {
+			return nil
+		}
+		if err := s.orders.Patch(ctx, order.ID, changes); err != nil {
+			return apperror.Internal("Failed to update order", err)
+		}
+		return s.record(ctx, adminID, order.ID, ChangeEdited, strings.Join(summary, "; "), order.TotalAmount, total)
+	})
+	if err != nil {
+		return models.Order{}, false, err
+	}
+
+	order, err := s.orders.Get(ctx, id)
+	if err != nil {
+		return models.Order{}, false, orderError(err, "Failed to fetch updated order")
+	}
+	return order, updated, nil
+}
+
+func (s *service) PlaceDraft(ctx context.Context, adminID, id, expected uint) (models.Order, bool, error) {
+	updated := false
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orders.Lock(ctx, id); err != nil {
+			return orderError(err, "Failed to get order")
+		}
+		order, err := s.orders.Get(ctx, id)
+		if err != nil {
+			return orderError(err, "Failed to get order")
+		}
+		if order.Version != expected {
+			return nil
+		}
+		if order.Status != StatusDraft {
+			return apperror.Conflict(apperror.CodeInvalidTransition, "Only draft orders can be placed").
+				With("order_status", order.Status)
+		}
+
+		for _, item := range order.OrderItems {
+			if err := s.takeStock(ctx, item, item.Quantity); err != nil {
+				return err
+			}
+		}
+		if err := s.orders.Patch(ctx, order.ID, map[string]interface{}{"status": StatusPending}); err != nil {
+			return apperror.Internal("Failed to place order", err)
+		}
+		updated = true
+		return s.record(ctx, adminID, order.ID, ChangePlaced, "", order.TotalAmount, order.TotalAmount)
+	})
+	if err != nil {
+		return models.Order{}, false, err
+	}
+
+	order, err := s.orders.Get(ctx, id)
+	if err != nil {
+		return models.Order{}, false, orderError(err, "Failed to fetch placed order")
+	}
+	return order, updated, nil
+}
+
+// lineItems turns lines into order items. Products already among current
+// keep their item, and price, at the new quantity; other products must be
+// on sale and are added as they are now.
+func (s *service) lineItems(ctx context.Context, current []models.OrderItem, lines []Line) ([]models.OrderItem, error) {
+	if len(lines) == 0 {
+		return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "An order needs at least one item")
+	}
+	byProduct := make(map[uint]models.OrderItem, len(current))
+	for _, item := range current {
+		byProduct[item.ProductID] = item
+	}
+
+	seen := make(map[uint]bool, len(lines))
+	items := make([]models.OrderItem, 0, len(lines))
+	for _, line := range lines {
+		if line.Quantity < 1 {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Quantities must be at least 1").
+				With("product_id", line.ProductID)
+		}
+		if seen[line.ProductID] {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Each product can only be listed once").
+				With("product_id", line.ProductID)
+		}
+		seen[line.ProductID] = true
+
+		if item, ok := byProduct[line.ProductID]; ok {
+			item.Quantity = line.Quantity
+			item.TotalPrice = item.UnitPrice * int64(line.Quantity)
+			item.TaxAmount = item.TotalPrice * item.TaxPercent / 100
+			items = append(items, item)
+			continue
+		}
+		product, err := s.products.Get(ctx, line.ProductID)
+		if errors.Is(err, repository.ErrNotFound) || err == nil && !product.IsActive {
+			return nil, apperror.NotFound(apperror.CodeProductNotFound, "Product not found").
+				With("product_id", line.ProductID)
+		}
+		if err != nil {
+			return nil, apperror.Internal("Failed to get product", err)
+		}
+		items = append(items, cart.OrderItem(product, line.Quantity))
+	}
+	return items, nil
+}
+
+// moveStock takes or returns the difference between the quantities of each
+// product in before and after
+func (s *service) moveStock(ctx context.Context, before, after []models.OrderItem) error {
+	was := make(map[uint]int, len(before))
+	for _, item := range before {
+		was[item.ProductID] = item.Quantity
+	}
+	for _, item := range after {
+		delta := item.Quantity - was[item.ProductID]
+		delete(was, item.ProductID)
+		switch {
+		case delta > 0:
+			if err := s.takeStock(ctx, item, delta); err != nil {
+				return err
+			}
+		case delta < 0:
+			if err := s.products.IncrementStock(ctx, item.ProductID, -delta); err != nil {
+				return apperror.Internal("Failed to update stock", err)
+			}
+		}
+	}
+	for _, item := range before {
+		if quantity, removed := was[item.ProductID]; removed {
+			if err := s.products.IncrementStock(ctx, item.ProductID, quantity); err != nil {
+				return apperror.Internal("Failed to update stock", err)
+			}
+		}
+	}
+	return nil
+}
+
+// takeStock reserves quantity of the item's product, failing with
+// INSUFFICIENT_STOCK and the units left when there are not enough
+func (s *service) takeStock(ctx context.Context, item models.OrderItem, quantity int) error {
+	ok, err := s.products.DecrementStock(ctx, item.ProductID, quantity)
+	if err != nil {
+		return apperror.Internal("Failed to update stock", err)
+	}
+	if ok {
+		return nil
+	}
+
+	available := 0
+	if current, err := s.products.Get(ctx, item.ProductID); err == nil {
+		available = current.Stock
+	}
+	return apperror.BadRequest(apperror.CodeInsufficientStock,
+		fmt.Sprintf("Only %d of %q left in stock", available, item.ProductName)).
+		With("product_id", item.ProductID).
+		With("available", available)
+}
+
+// record adds a change adminID made to the order to its history
+func (s *service) record(ctx context.Context, adminID, orderID uint, action, summary string, before, after int64) error {
+	change := models.OrderChange{
+		OrderID:     orderID,
+		Action:      action,
+		Summary:     summary,
+		TotalBefore: before,
+		TotalAfter:  after,
+		CreatedBy:   &adminID,
+		CreatedAt:   s.now(),
+	}
+	if err := s.orders.AddChange(ctx, &change); err != nil {
+		return apperror.Internal("Failed to record order change", err)
+	}
+	return nil
+}
+
+// describeItems summarises how the items went from before to after, e.g.
+// "Kopi Arabika 2 → 3, added 1 × Teh Melati, removed Gula Aren". It is empty
+// when nothing changed.
+func describeItems(before, after []models.OrderItem) string {
+	was := make(map[uint]int, len(before))
+	for _, item := range before {
+		was[item.ProductID] = item.Quantity
+	}
+	var parts []string
+	for _, item := range after {
+		quantity, ok := was[item.ProductID]
+		delete(was, item.ProductID)
+		switch {
+		case !ok && before == nil:
+			parts = append(parts, fmt.Sprintf("%d × %s", item.Quantity, item.ProductName))
+		case !ok:
+			parts = append(parts, fmt.Sprintf("added %d × %s", item.Quantity, item.ProductName))
+		case quantity != item.Quantity:
+			parts = append(parts, fmt.Sprintf("%s %d → %d", item.ProductName, quantity, item.Quantity))
+		}
+	}
+	for _, item := range before {
+		if _, removed := was[item.ProductID]; removed {
+			parts = append(parts, "removed "+item.ProductName)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
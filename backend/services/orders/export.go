@@ -0,0 +1,151 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/spreadsheet"
+)
+
+// Export layouts: a row per order, or a row per order item
+const (
+	ExportOrders = "orders"
+	ExportItems  = "items"
+)
+
+// exportBatch is how many orders are read at a time while exporting
+const exportBatch = 500
+
+// ExportQuery selects the orders to export and how to lay them out
+type ExportQuery struct {
+	Filter repository.OrderFilter
+	Format spreadsheet.Format
+	Layout string
+}
+
+// Check reports whether q can be exported, failing with 400 when it cannot
+func (q ExportQuery) Check() error {
+	if !q.Format.Valid() {
+		return apperror.BadRequest(apperror.CodeInvalidRequest, "Export format must be csv or xlsx").
+			With("format", q.Format)
+	}
+	if q.Layout != ExportOrders && q.Layout != ExportItems {
+		return apperror.BadRequest(apperror.CodeInvalidRequest, "Export rows must be orders or items").
+			With("rows", q.Layout)
+	}
+	if !q.Filter.From.IsZero() && !q.Filter.To.IsZero() && !q.Filter.From.Before(q.Filter.To) {
+		return apperror.BadRequest(apperror.CodeInvalidRequest, "The date range ends before it starts")
+	}
+	return nil
+}
+
+var (
+	orderColumns = []string{
+		"Order Number", "Placed At", "Status", "Payment Status", "Payment Method", "Invoice Number", "Invoiced At",
+		"Customer", "Email", "Items", "Subtotal", "Tax", "Shipping", "Total", "Refunded", "Net Paid",
+		"Shipping Address", "Tracking Number",
+	}
+	itemColumns = []string{
+		"Order Number", "Placed At", "Status", "Payment Status", "Invoice Number", "Customer", "Email",
+		"SKU", "Product", "Options", "Quantity", "Unit Price", "Line Total", "Tax %", "Tax",
+	}
+)
+
+func (s *service) Export(ctx context.Context, q ExportQuery, w io.Writer) error {
+	if err := q.Check(); err != nil {
+		return err
+	}
+	columns := orderColumns
+	if q.Layout == ExportItems {
+		columns = itemColumns
+	}
+	sheet, err := spreadsheet.New(q.Format, w, columns)
+	if err != nil {
+		return fmt.Errorf("start export: %w", err)
+	}
+
+	flusher, _ := w.(interface{ Flush() error })
+	err = s.orders.Each(ctx, q.Filter, exportBatch, func(orders []models.Order) error {
+		// A download the client gave up on stops at the next batch
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, order := range orders {
+			var err error
+			if q.Layout == ExportItems {
+				err = writeItemRows(sheet, order)
+			} else {
+				err = sheet.WriteRow(orderRow(order))
+			}
+			if err != nil {
+				return fmt.Errorf("write order %s: %w", order.OrderNumber, err)
+			}
+		}
+		if flusher != nil {
+			if err := flusher.Flush(); err != nil {
+				return fmt.Errorf("send export: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return sheet.Close()
+}
+
+func orderRow(order models.Order) []interface{} {
+	quantity := 0
+	for _, item := range order.OrderItems {
+		quantity += item.Quantity
+	}
+	return []interface{}{
+		order.OrderNumber, order.CreatedAt, order.Status, order.PaymentStatus, order.PaymentMethod,
+		optional(order.InvoiceNumber), order.InvoicedAt,
+		customerName(order.User), order.User.Email, quantity,
+		order.Subtotal, order.Tax, order.ShippingCost, order.TotalAmount, order.RefundedAmount, order.NetPaid,
+		order.ShippingAddress, order.TrackingNumber,
+	}
+}
+
+func writeItemRows(sheet spreadsheet.Writer, order models.Order) error {
+	for _, item := range order.OrderItems {
+		err := sheet.WriteRow([]interface{}{
+			order.OrderNumber, order.CreatedAt, order.Status, order.PaymentStatus, optional(order.InvoiceNumber),
+			customerName(order.User), order.User.Email,
+			item.ProductSKU, item.ProductName, options(item.Options), item.Quantity,
+			item.UnitPrice, item.TotalPrice, item.TaxPercent, item.TaxAmount,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func customerName(u models.User) string {
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+func optional(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+// options lists variant options as "Berat: 250 g, Gilingan: Kasar"
+func options(chosen map[string]string) string {
+	list := make([]string, 0, len(chosen))
+	for option, value := range chosen {
+		list = append(list, option+": "+value)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
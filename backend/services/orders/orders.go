@@ -0,0 +1,328 @@
+// Package orders manages orders once they are placed: what customers can see
+// and cancel, and how admins move them through fulfilment
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/checkout"
+)
+
+// Order statuses, in the order an order normally moves through them. Once
+// an order has shipments, partially shipped, shipped and delivered follow
+// from them rather than being set by hand. Only orders an admin drafts start
+// as drafts; they hold no stock and their customer does not see them until
+// they are placed.
+const (
+	StatusDraft            = "draft"
+	StatusPending          = "pending"
+	StatusProcessing       = "processing"
+	StatusPartiallyShipped = "partially_shipped"
+	StatusShipped          = "shipped"
+	StatusDelivered        = "delivered"
+	StatusCancelled        = "cancelled"
+)
+
+// PaymentPaid is the payment status that issues an order's invoice
+const PaymentPaid = "paid"
+
+// Payment statuses set by refunds, which admins cannot set or replace
+const (
+	paymentPartiallyRefunded = "partially_refunded"
+	paymentRefunded          = "refunded"
+)
+
+// InvoiceNumber formats the nth invoice issued in year, e.g. INV-2024-000123.
+// Numbers restart each year and run without gaps.
+func InvoiceNumber(year int, n int64) string {
+	return fmt.Sprintf("INV-%d-%06d", year, n)
+}
+
+// Cancellable reports whether a customer may still cancel an order in
+// status. Once an order is being processed only an admin can cancel it.
+func Cancellable(status string) bool {
+	return status == StatusPending
+}
+
+// Query selects a page of orders for the admin list
+type Query struct {
+	Page   repository.Page
+	Filter repository.OrderFilter
+}
+
+// StatusUpdate is an admin's change to an order's fulfilment. Nil fields are
+// left as they are.
+type StatusUpdate struct {
+	Status         string
+	TrackingNumber *string
+	Notes          *string
+}
+
+// OrderService reads and updates placed orders
+type OrderService interface {
+	// ListForUser returns the customer's orders, newest first
+	ListForUser(ctx context.Context, userID uint) ([]models.Order, error)
+	// GetForUser returns one of the customer's orders
+	GetForUser(ctx context.Context, userID, id uint) (models.Order, error)
+	// Lookup returns the order a guest's lookup token opens
+	Lookup(ctx context.Context, token string) (models.Order, error)
+	// Cancel cancels one of the customer's orders while it is still pending
+	// and puts its items back in stock
+	Cancel(ctx context.Context, userID, id uint) (models.Order, error)
+
+	List(ctx context.Context, q Query) ([]models.Order, int64, error)
+	Get(ctx context.Context, id uint) (models.Order, error)
+	// UpdateStatus applies u if the order is still at version expected. It
+	// returns the order as it is afterwards, and false when someone else
+	// changed it first. Cancelling restocks the items; a cancelled order
+	// cannot be reopened, and the status of an order with shipments cannot
+	// be changed by hand.
+	UpdateStatus(ctx context.Context, id, expected uint, u StatusUpdate) (models.Order, bool, error)
+	UpdatePaymentStatus(ctx context.Context, id uint, status string) (models.Order, error)
+
+	// CreateDraft starts an order for a customer on behalf of adminID,
+	// priced like a checkout. It holds no stock until it is placed.
+	CreateDraft(ctx context.Context, adminID uint, d Draft) (models.Order, error)
+	// Edit applies e to a draft or pending order if it is still at version
+	// expected, reprices it and records the change as made by adminID. It
+	// returns the order as it is afterwards, and false when someone else
+	// changed it first. Items of a pending order take or return the
+	// difference in stock, and cannot change once the order is paid.
+	Edit(ctx context.Context, adminID, id, expected uint, e Edit) (models.Order, bool, error)
+	// PlaceDraft takes a draft order's items out of stock and makes it
+	// pending, if it is still at version expected
+	PlaceDraft(ctx context.Context, adminID, id, expected uint) (models.Order, bool, error)
+	Stats(ctx context.Context) (repository.OrderStats, error)
+	// Export writes every order matching q's filter to w, oldest first, in
+	// q's format and layout. Orders are read a batch at a time, so exports
+	// of any size take little memory; w is flushed after each batch when it
+	// has a Flush method, and the export stops once ctx is done.
+	Export(ctx context.Context, q ExportQuery, w io.Writer) error
+}
+
+type service struct {
+	tx       repository.Transactor
+	orders   repository.OrderRepository
+	products repository.ProductRepository
+	users    repository.UserRepository
+
+	now func() time.Time
+}
+
+// NewService returns an OrderService backed by the given repositories
+func NewService(tx repository.Transactor, orders repository.OrderRepository, products repository.ProductRepository,
+	users repository.UserRepository) OrderService {
+	return &service{tx: tx, orders: orders, products: products, users: users, now: time.Now}
+}
+
+func (s *service) ListForUser(ctx context.Context, userID uint) ([]models.Order, error) {
+	orders, err := s.orders.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, apperror.Internal("Failed to get orders", err)
+	}
+	return orders, nil
+}
+
+func (s *service) GetForUser(ctx context.Context, userID, id uint) (models.Order, error) {
+	order, err := s.orders.GetForUser(ctx, id, userID)
+	return order, orderError(err, "Failed to get order")
+}
+
+func (s *service) Lookup(ctx context.Context, token string) (models.Order, error) {
+	if token == "" {
+		return models.Order{}, apperror.NotFound(apperror.CodeOrderNotFound, "Order not found")
+	}
+	order, err := s.orders.GetByLookupToken(ctx, checkout.HashLookupToken(token))
+	return order, orderError(err, "Failed to get order")
+}
+
+func (s *service) Cancel(ctx context.Context, userID, id uint) (models.Order, error) {
+	var order models.Order
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = s.orders.GetForUser(ctx, id, userID)
+		if err != nil {
+			return orderError(err, "Failed to get order")
+		}
+		if !Cancellable(order.Status) {
+			return apperror.Conflict(apperror.CodeOrderNotCancellable, "Only pending orders can be cancelled").
+				With("order_status", order.Status)
+		}
+
+		// Cancel unless an admin moved it on meanwhile
+		cancelled, err := s.orders.UpdateIfStatus(ctx, order.ID, order.Status,
+			map[string]interface{}{"status": StatusCancelled})
+		if err != nil {
+			return apperror.Internal("Failed to cancel order", err)
+		}
+		if !cancelled {
+			return apperror.Conflict(apperror.CodeOrderNotCancellable, "Order was updated before it could be cancelled")
+		}
+		if err := s.restock(ctx, order); err != nil {
+			return err
+		}
+		order.Status = StatusCancelled
+		order.Version++
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	return order, nil
+}
+
+func (s *service) List(ctx context.Context, q Query) ([]models.Order, int64, error) {
+	orders, total, err := s.orders.List(ctx, q.Filter, q.Page)
+	if err != nil {
+		return nil, 0, apperror.Internal("Failed to fetch orders", err)
+	}
+	return orders, total, nil
+}
+
+func (s *service) Get(ctx context.Context, id uint) (models.Order, error) {
+	order, err := s.orders.Get(ctx, id)
+	return order, orderError(err, "Failed to get order")
+}
+
+func (s *service) UpdateStatus(ctx context.Context, id, expected uint, u StatusUpdate) (models.Order, bool, error) {
+	updated := false
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		order, err := s.orders.Get(ctx, id)
+		if err != nil {
+			return orderError(err, "Failed to get order")
+		}
+		if order.Status == StatusCancelled && u.Status != StatusCancelled {
+			return apperror.Conflict(apperror.CodeInvalidTransition, "A cancelled order cannot be reopened").
+				With("order_status", order.Status)
+		}
+		if order.Status == StatusDraft && u.Status != StatusCancelled {
+			return apperror.Conflict(apperror.CodeInvalidTransition, "A draft order has to be placed first").
+				With("order_status", order.Status)
+		}
+		if u.Status != order.Status && (len(order.Shipments) > 0 || u.Status == StatusPartiallyShipped) {
+			return apperror.Conflict(apperror.CodeInvalidTransition,
+				"The status of an order with shipments follows its shipments").
+				With("order_status", order.Status)
+		}
+
+		// Leave tracking number and notes alone unless sent
+		changes := map[string]interface{}{"status": u.Status}
+		if u.TrackingNumber != nil {
+			changes["tracking_number"] = *u.TrackingNumber
+		}
+		if u.Notes != nil {
+			changes["notes"] = *u.Notes
+		}
+		updated, err = s.orders.Update(ctx, order.ID, expected, changes)
+		if err != nil {
+			return apperror.Internal("Failed to update order", err)
+		}
+
+		// The update only matched if order was the version it replaced, so
+		// order.Status is the status it had before. Drafts never took stock.
+		if updated && u.Status == StatusCancelled && order.Status != StatusCancelled && order.Status != StatusDraft {
+			return s.restock(ctx, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, false, err
+	}
+
+	order, err := s.orders.Get(ctx, id)
+	if err != nil {
+		return models.Order{}, false, orderError(err, "Failed to fetch updated order")
+	}
+	return order, updated, nil
+}
+
+func (s *service) UpdatePaymentStatus(ctx context.Context, id uint, status string) (models.Order, error) {
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orders.Lock(ctx, id); err != nil {
+			return orderError(err, "Failed to get order")
+		}
+		order, err := s.orders.Get(ctx, id)
+		if err != nil {
+			return orderError(err, "Failed to get order")
+		}
+
+		// Once money has gone back, only refunds change the payment status,
+		// so that it stays in step with the refunded amount
+		if order.RefundedAmount > 0 || order.PaymentStatus == paymentPartiallyRefunded || order.PaymentStatus == paymentRefunded {
+			return apperror.Conflict(apperror.CodeInvalidTransition, "The payment status of a refunded order is set by its refunds").
+				With("payment_status", order.PaymentStatus)
+		}
+
+		// Drafts and cancelled orders are not sales, so they must not use up
+		// an invoice number
+		if status == PaymentPaid && (order.Status == StatusDraft || order.Status == StatusCancelled) {
+			return apperror.Conflict(apperror.CodeInvalidTransition, "Only placed orders that are not cancelled can be paid").
+				With("order_status", order.Status)
+		}
+
+		changes := map[string]interface{}{"payment_status": status}
+		// The invoice is issued the first time the order is paid and kept if
+		// the payment is later undone
+		if status == PaymentPaid && order.InvoiceNumber == nil {
+			now := s.now()
+			next, err := s.orders.NextInvoiceNumber(ctx, now.Year())
+			if err != nil {
+				return apperror.Internal("Failed to issue invoice number", err)
+			}
+			changes["invoice_number"] = InvoiceNumber(now.Year(), next)
+			changes["invoiced_at"] = now
+		}
+		if err := s.orders.Patch(ctx, order.ID, changes); err != nil {
+			return apperror.Internal("Failed to update payment status", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	order, err := s.orders.Get(ctx, id)
+	if err != nil {
+		return models.Order{}, orderError(err, "Failed to fetch updated order")
+	}
+	return order, nil
+}
+
+func (s *service) Stats(ctx context.Context) (repository.OrderStats, error) {
+	now := s.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	stats, err := s.orders.Stats(ctx, today)
+	if err != nil {
+		return repository.OrderStats{}, apperror.Internal("Failed to get order statistics", err)
+	}
+	return stats, nil
+}
+
+// restock puts the order's items back in stock
+func (s *service) restock(ctx context.Context, order models.Order) error {
+	for _, item := range order.OrderItems {
+		if err := s.products.IncrementStock(ctx, item.ProductID, item.Quantity); err != nil {
+			return apperror.Internal("Failed to restock order items",
+				fmt.Errorf("order %d, product %d: %w", order.ID, item.ProductID, err))
+		}
+	}
+	return nil
+}
+
+func orderError(err error, detail string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		return apperror.NotFound(apperror.CodeOrderNotFound, "Order not found")
+	default:
+		return apperror.Internal(detail, err)
+	}
+}
@@ -0,0 +1,763 @@
+package orders
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/repository/repotest"
+	"ecommerce-backend/services/checkout"
+	"ecommerce-backend/spreadsheet"
+)
+
+const (
+	alice uint = 1
+	bob   uint = 2
+)
+
+func newTestService(status string) (*service, *repotest.Orders, *repotest.Products) {
+	products := repotest.NewProducts(
+		models.Product{ID: 1, Name: "Kopi Arabika", Price: 85000, Stock: 3, IsActive: true},
+		models.Product{ID: 2, Name: "Teh Melati", Price: 25000, Stock: 0, IsActive: true},
+		models.Product{ID: 3, Name: "Gula Aren", Price: 15000, Stock: 10, IsActive: true},
+		models.Product{ID: 4, Name: "Kopi Robusta", Price: 60000, Stock: 10, IsActive: false},
+	)
+	orders := repotest.NewOrders(models.Order{
+		UserID:        alice,
+		OrderNumber:   "ORD-20240309-0001",
+		Status:        status,
+		PaymentStatus: "unpaid",
+		TotalAmount:   205000,
+		OrderItems: []models.OrderItem{
+			{ProductID: 1, ProductName: "Kopi Arabika", Quantity: 2, UnitPrice: 85000},
+			{ProductID: 2, ProductName: "Teh Melati", Quantity: 1, UnitPrice: 25000},
+		},
+	})
+	users := repotest.NewUsers(
+		models.User{FirstName: "Alice", Email: "alice@example.com"},
+		models.User{FirstName: "Bob", Email: "bob@example.com"},
+	)
+	return NewService(repotest.Transactor{}, orders, products, users).(*service), orders, products
+}
+
+func TestCancellable(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{StatusPending, true},
+		{StatusProcessing, false},
+		{StatusShipped, false},
+		{StatusDelivered, false},
+		{StatusCancelled, false},
+	}
+	for _, tt := range tests {
+		if got := Cancellable(tt.status); got != tt.want {
+			t.Errorf("Cancellable(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	s, orders, _ := newTestService(StatusPending)
+	token, hash, err := checkout.NewLookupToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := orders.Rows[1]
+	order.LookupTokenHash = &hash
+	orders.Rows[1] = order
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode apperror.Code
+	}{
+		{name: "token", token: token},
+		{name: "hash in place of the token", token: hash, wantCode: apperror.CodeOrderNotFound},
+		{name: "no token", wantCode: apperror.CodeOrderNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Lookup(context.Background(), tt.token)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode == "" && got.ID != order.ID {
+				t.Errorf("found order %d, want %d", got.ID, order.ID)
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		userID      uint
+		wantCode    apperror.Code
+		wantRestock bool
+	}{
+		{name: "pending order", status: StatusPending, userID: alice, wantRestock: true},
+		{name: "already processing", status: StatusProcessing, userID: alice, wantCode: apperror.CodeOrderNotCancellable},
+		{name: "already cancelled", status: StatusCancelled, userID: alice, wantCode: apperror.CodeOrderNotCancellable},
+		{name: "someone else's order", status: StatusPending, userID: bob, wantCode: apperror.CodeOrderNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, orders, products := newTestService(tt.status)
+
+			order, err := s.Cancel(context.Background(), tt.userID, 1)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+
+			wantStock := map[uint]int{1: 3, 2: 0}
+			if tt.wantRestock {
+				wantStock = map[uint]int{1: 5, 2: 1}
+				if order.Status != StatusCancelled || orders.Rows[1].Status != StatusCancelled {
+					t.Errorf("status = %q (stored %q), want cancelled", order.Status, orders.Rows[1].Status)
+				}
+			} else if orders.Rows[1].Status != tt.status {
+				t.Errorf("status changed to %q", orders.Rows[1].Status)
+			}
+			for id, want := range wantStock {
+				if got := products.Rows[id].Stock; got != want {
+					t.Errorf("product %d stock = %d, want %d", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	tracking := "JNE123456789"
+	tests := []struct {
+		name        string
+		from        string
+		to          string
+		expected    uint
+		wantCode    apperror.Code
+		wantUpdated bool
+		wantStock   int
+	}{
+		{name: "ship", from: StatusProcessing, to: StatusShipped, expected: 1, wantUpdated: true, wantStock: 3},
+		{name: "admin cancels processing order", from: StatusProcessing, to: StatusCancelled, expected: 1, wantUpdated: true, wantStock: 5},
+		{name: "cancel again", from: StatusCancelled, to: StatusCancelled, expected: 1, wantUpdated: true, wantStock: 3},
+		{name: "reopen cancelled", from: StatusCancelled, to: StatusProcessing, expected: 1, wantCode: apperror.CodeInvalidTransition, wantStock: 3},
+		{name: "stale version", from: StatusProcessing, to: StatusCancelled, expected: 0, wantStock: 3},
+		{name: "process a draft", from: StatusDraft, to: StatusProcessing, expected: 1, wantCode: apperror.CodeInvalidTransition, wantStock: 3},
+		{name: "discard a draft", from: StatusDraft, to: StatusCancelled, expected: 1, wantUpdated: true, wantStock: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, products := newTestService(tt.from)
+
+			order, updated, err := s.UpdateStatus(context.Background(), 1, tt.expected,
+				StatusUpdate{Status: tt.to, TrackingNumber: &tracking})
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("updated = %v, want %v", updated, tt.wantUpdated)
+			}
+			if tt.wantUpdated && (order.Status != tt.to || order.TrackingNumber != tracking || order.Version != 2) {
+				t.Errorf("order = status %q tracking %q version %d, want %q %q 2",
+					order.Status, order.TrackingNumber, order.Version, tt.to, tracking)
+			}
+			if got := products.Rows[1].Stock; got != tt.wantStock {
+				t.Errorf("stock = %d, want %d", got, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusFollowsShipments(t *testing.T) {
+	s, orders, _ := newTestService(StatusPartiallyShipped)
+	order := orders.Rows[1]
+	order.Shipments = []models.Shipment{{ID: 1, OrderID: 1, Carrier: "jne"}}
+	orders.Rows[1] = order
+
+	_, _, err := s.UpdateStatus(context.Background(), 1, 1, StatusUpdate{Status: StatusShipped})
+	if code := repotest.Code(err); code != apperror.CodeInvalidTransition {
+		t.Errorf("order with shipments: code = %q, want %q", code, apperror.CodeInvalidTransition)
+	}
+
+	s, _, _ = newTestService(StatusProcessing)
+	_, _, err = s.UpdateStatus(context.Background(), 1, 1, StatusUpdate{Status: StatusPartiallyShipped})
+	if code := repotest.Code(err); code != apperror.CodeInvalidTransition {
+		t.Errorf("partially shipped by hand: code = %q, want %q", code, apperror.CodeInvalidTransition)
+	}
+}
+
+func TestUpdatePaymentStatus(t *testing.T) {
+	s, _, _ := newTestService(StatusPending)
+
+	order, err := s.UpdatePaymentStatus(context.Background(), 1, "paid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.PaymentStatus != "paid" || order.Version != 2 {
+		t.Errorf("order = payment %q version %d, want paid 2", order.PaymentStatus, order.Version)
+	}
+
+	_, err = s.UpdatePaymentStatus(context.Background(), 99, "paid")
+	if code := repotest.Code(err); code != apperror.CodeOrderNotFound {
+		t.Errorf("unknown order: code = %q, want %q", code, apperror.CodeOrderNotFound)
+	}
+}
+
+func TestUpdatePaymentStatusUnplacedOrders(t *testing.T) {
+	for _, status := range []string{StatusDraft, StatusCancelled} {
+		t.Run(status, func(t *testing.T) {
+			s, orders, _ := newTestService(status)
+
+			_, err := s.UpdatePaymentStatus(context.Background(), 1, "paid")
+			if code := repotest.Code(err); code != apperror.CodeInvalidTransition {
+				t.Fatalf("code = %q, want %q", code, apperror.CodeInvalidTransition)
+			}
+			order, _ := orders.Get(context.Background(), 1)
+			if order.PaymentStatus != "unpaid" || order.InvoiceNumber != nil {
+				t.Errorf("order = payment %q invoice %v, want unpaid and no invoice", order.PaymentStatus, order.InvoiceNumber)
+			}
+
+			// The next paid order still gets the first number of the year
+			_ = orders.Create(context.Background(), &models.Order{UserID: bob, OrderNumber: "ORD-20240309-0002", Status: StatusPending})
+			paid, err := s.UpdatePaymentStatus(context.Background(), 2, "paid")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if paid.InvoiceNumber == nil || !strings.HasSuffix(*paid.InvoiceNumber, "-000001") {
+				t.Errorf("next invoice = %v, want number 000001", paid.InvoiceNumber)
+			}
+		})
+	}
+}
+
+func TestUpdatePaymentStatusRefundedOrders(t *testing.T) {
+	tests := []struct {
+		name     string
+		payment  string
+		refunded int64
+	}{
+		{name: "partially refunded", payment: "partially_refunded", refunded: 50000},
+		{name: "refunded", payment: "refunded", refunded: 205000},
+		{name: "refund recorded on a paid order", payment: "paid", refunded: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, orders, _ := newTestService(StatusDelivered)
+			ctx := context.Background()
+			_ = orders.Patch(ctx, 1, map[string]interface{}{"payment_status": tt.payment, "refunded_amount": tt.refunded})
+
+			for _, status := range []string{"unpaid", "paid", "failed"} {
+				_, err := s.UpdatePaymentStatus(ctx, 1, status)
+				if code := repotest.Code(err); code != apperror.CodeInvalidTransition {
+					t.Errorf("setting %s: code = %q, want %q", status, code, apperror.CodeInvalidTransition)
+				}
+			}
+			if order, _ := orders.Get(ctx, 1); order.PaymentStatus != tt.payment {
+				t.Errorf("payment status = %q, want %q kept", order.PaymentStatus, tt.payment)
+			}
+		})
+	}
+}
+
+func TestInvoiceNumbers(t *testing.T) {
+	s, orders, _ := newTestService(StatusPending)
+	_ = orders.Create(context.Background(), &models.Order{UserID: bob, OrderNumber: "ORD-20240309-0002"})
+	_ = orders.Create(context.Background(), &models.Order{UserID: bob, OrderNumber: "ORD-20240309-0003"})
+	ctx := context.Background()
+	newYearsEve := time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return newYearsEve }
+
+	pay := func(id uint, status string) models.Order {
+		t.Helper()
+		order, err := s.UpdatePaymentStatus(ctx, id, status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return order
+	}
+	invoice := func(order models.Order) string {
+		if order.InvoiceNumber == nil {
+			return ""
+		}
+		return *order.InvoiceNumber
+	}
+
+	if got := pay(2, "failed"); got.InvoiceNumber != nil {
+		t.Errorf("failed payment issued invoice %s", *got.InvoiceNumber)
+	}
+	first := pay(1, "paid")
+	if invoice(first) != "INV-2024-000001" || first.InvoicedAt == nil || !first.InvoicedAt.Equal(newYearsEve) {
+		t.Errorf("first invoice = %q at %v, want INV-2024-000001 at %v", invoice(first), first.InvoicedAt, newYearsEve)
+	}
+
+	// Paying again keeps the invoice and does not use up a number
+	pay(1, "unpaid")
+	if got := pay(1, "paid"); invoice(got) != "INV-2024-000001" {
+		t.Errorf("repaid order has invoice %q, want it kept", invoice(got))
+	}
+	if got := pay(2, "paid"); invoice(got) != "INV-2024-000002" {
+		t.Errorf("second invoice = %q, want INV-2024-000002", invoice(got))
+	}
+
+	s.now = func() time.Time { return newYearsEve.Add(2 * time.Hour) }
+	if got := pay(3, "paid"); invoice(got) != "INV-2025-000001" {
+		t.Errorf("first invoice of the year = %q, want INV-2025-000001", invoice(got))
+	}
+}
+
+func TestCreateDraft(t *testing.T) {
+	address := models.Address{Address: "Jl. Braga 12", City: "Bandung", Province: "Jawa Barat", PostalCode: "40111"}
+	tests := []struct {
+		name     string
+		draft    Draft
+		wantCode apperror.Code
+	}{
+		{name: "phone order", draft: Draft{UserID: bob, Lines: []Line{{1, 2}, {2, 1}}}},
+		{name: "unknown customer", draft: Draft{UserID: 99, Lines: []Line{{1, 1}}}, wantCode: apperror.CodeUserNotFound},
+		{name: "product off sale", draft: Draft{UserID: bob, Lines: []Line{{4, 1}}}, wantCode: apperror.CodeProductNotFound},
+		{name: "unknown product", draft: Draft{UserID: bob, Lines: []Line{{99, 1}}}, wantCode: apperror.CodeProductNotFound},
+		{name: "no items", draft: Draft{UserID: bob}, wantCode: apperror.CodeInvalidRequest},
+		{name: "product twice", draft: Draft{UserID: bob, Lines: []Line{{1, 1}, {1, 2}}}, wantCode: apperror.CodeInvalidRequest},
+		{name: "no quantity", draft: Draft{UserID: bob, Lines: []Line{{1, 0}}}, wantCode: apperror.CodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, orders, products := newTestService(StatusPending)
+			ctx := context.Background()
+			tt.draft.ShippingAddress = address
+			tt.draft.PaymentMethod = "cod"
+
+			order, err := s.CreateDraft(ctx, 9, tt.draft)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != "" {
+				if len(orders.Rows) != 1 {
+					t.Errorf("a failed draft was stored")
+				}
+				return
+			}
+
+			// Priced like a checkout: 195000 of items, 10% tax and flat shipping
+			if order.Status != StatusDraft || order.Subtotal != 195000 || order.Tax != 19500 ||
+				order.ShippingCost != 10000 || order.TotalAmount != 224500 {
+				t.Errorf("draft = status %q subtotal %d tax %d shipping %d total %d, want a draft of 224500",
+					order.Status, order.Subtotal, order.Tax, order.ShippingCost, order.TotalAmount)
+			}
+			if order.ShippingAddress != "Jl. Braga 12, Bandung, Jawa Barat, 40111" || order.OrderItems[0].ProductName != "Kopi Arabika" {
+				t.Errorf("draft = %+v, want the address and product details filled in", order)
+			}
+			if len(order.Changes) != 1 || order.Changes[0].Action != ChangeCreated || order.Changes[0].TotalAfter != 224500 ||
+				order.Changes[0].CreatedBy == nil || *order.Changes[0].CreatedBy != 9 {
+				t.Errorf("changes = %+v, want its creation by admin 9", order.Changes)
+			}
+			if products.Rows[1].Stock != 3 || products.Rows[2].Stock != 0 {
+				t.Errorf("a draft took stock")
+			}
+
+			// The customer does not see it yet
+			if mine, _ := s.ListForUser(ctx, bob); len(mine) != 0 {
+				t.Errorf("customer lists %d orders, want the draft hidden", len(mine))
+			}
+			if _, err := s.GetForUser(ctx, bob, order.ID); repotest.Code(err) != apperror.CodeOrderNotFound {
+				t.Errorf("customer read the draft: %v", err)
+			}
+		})
+	}
+}
+
+func TestEdit(t *testing.T) {
+	paid := "paid"
+	address := models.Address{Address: "Jl. Dago 5", City: "Bandung", Province: "Jawa Barat", PostalCode: "40135"}
+	tests := []struct {
+		name        string
+		status      string
+		payment     string
+		expected    uint
+		edit        Edit
+		wantCode    apperror.Code
+		wantUpdated bool
+		wantTotal   int64
+		wantSummary string
+		wantStock   map[uint]int
+	}{
+		{
+			name:        "more of an item takes stock",
+			status:      StatusPending,
+			edit:        Edit{Lines: []Line{{1, 3}, {2, 1}}},
+			wantUpdated: true,
+			wantTotal:   280000 + 28000 + 10000,
+			wantSummary: "Kopi Arabika 2 → 3",
+			wantStock:   map[uint]int{1: 2, 2: 0, 3: 10},
+		},
+		{
+			name:        "removed items go back in stock",
+			status:      StatusPending,
+			edit:        Edit{Lines: []Line{{1, 2}, {3, 4}}},
+			wantUpdated: true,
+			wantTotal:   230000 + 23000 + 10000,
+			wantSummary: "added 4 × Gula Aren, removed Teh Melati",
+			wantStock:   map[uint]int{1: 3, 2: 1, 3: 6},
+		},
+		{
+			name:        "drafts hold no stock",
+			status:      StatusDraft,
+			edit:        Edit{Lines: []Line{{1, 5}}},
+			wantUpdated: true,
+			wantTotal:   425000 + 42500 + 10000,
+			wantSummary: "Kopi Arabika 2 → 5, removed Teh Melati",
+			wantStock:   map[uint]int{1: 3, 2: 0, 3: 10},
+		},
+		{
+			name:     "more than is in stock",
+			status:   StatusPending,
+			edit:     Edit{Lines: []Line{{1, 6}, {2, 1}}},
+			wantCode: apperror.CodeInsufficientStock,
+		},
+		{
+			name:        "address of a paid order",
+			status:      StatusPending,
+			payment:     paid,
+			edit:        Edit{ShippingAddress: &address, Notes: &paid},
+			wantUpdated: true,
+			wantTotal:   205000,
+			wantSummary: "Shipping address changed to Jl. Dago 5, Bandung, Jawa Barat, 40135; Notes changed",
+			wantStock:   map[uint]int{1: 3, 2: 0, 3: 10},
+		},
+		{
+			name:     "items of a paid order",
+			status:   StatusPending,
+			payment:  paid,
+			edit:     Edit{Lines: []Line{{1, 1}}},
+			wantCode: apperror.CodeOrderNotEditable,
+		},
+		{
+			name:     "already processing",
+			status:   StatusProcessing,
+			edit:     Edit{Notes: &paid},
+			wantCode: apperror.CodeOrderNotEditable,
+		},
+		{
+			name:      "stale version",
+			status:    StatusPending,
+			expected:  2,
+			edit:      Edit{Lines: []Line{{1, 1}}},
+			wantStock: map[uint]int{1: 3, 2: 0, 3: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, orders, products := newTestService(tt.status)
+			if tt.payment != "" {
+				order := orders.Rows[1]
+				order.PaymentStatus = tt.payment
+				orders.Rows[1] = order
+			}
+			if tt.expected == 0 {
+				tt.expected = 1
+			}
+
+			order, updated, err := s.Edit(context.Background(), 9, 1, tt.expected, tt.edit)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("updated = %v, want %v", updated, tt.wantUpdated)
+			}
+			for id, want := range tt.wantStock {
+				if got := products.Rows[id].Stock; got != want {
+					t.Errorf("product %d stock = %d, want %d", id, got, want)
+				}
+			}
+			if !tt.wantUpdated {
+				if order.Version != 1 || len(order.Changes) != 0 {
+					t.Errorf("stale edit changed the order: %+v", order)
+				}
+				return
+			}
+
+			if order.TotalAmount != tt.wantTotal || order.Version != 2 {
+				t.Errorf("order = total %d version %d, want %d at version 2", order.TotalAmount, order.Version, tt.wantTotal)
+			}
+			if len(order.Changes) != 1 {
+				t.Fatalf("changes = %+v, want the edit recorded", order.Changes)
+			}
+			change := order.Changes[0]
+			if change.Action != ChangeEdited || change.Summary != tt.wantSummary ||
+				change.TotalBefore != 205000 || change.TotalAfter != tt.wantTotal {
+				t.Errorf("change = %+v, want %q from 205000 to %d", change, tt.wantSummary, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestPlaceDraft(t *testing.T) {
+	s, orders, products := newTestService(StatusPending)
+	ctx := context.Background()
+	clock := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+
+	draft, err := s.CreateDraft(ctx, 9, Draft{UserID: bob, Lines: []Line{{1, 2}, {2, 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.PlaceDraft(ctx, 9, draft.ID, draft.Version); repotest.Code(err) != apperror.CodeInsufficientStock {
+		t.Fatalf("placing a draft of a sold out product: %v, want INSUFFICIENT_STOCK", err)
+	}
+
+	// The fake transactor keeps what was taken before the failure
+	products.Rows[1] = models.Product{ID: 1, Name: "Kopi Arabika", Price: 85000, Stock: 3, IsActive: true}
+	draft, _, err = s.Edit(ctx, 9, draft.ID, draft.Version, Edit{Lines: []Line{{1, 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, updated, _ := s.PlaceDraft(ctx, 9, draft.ID, draft.Version-1); updated {
+		t.Errorf("placed a stale version")
+	}
+	placed, updated, err := s.PlaceDraft(ctx, 9, draft.ID, draft.Version)
+	if err != nil || !updated {
+		t.Fatalf("place = %v, %v", updated, err)
+	}
+	if placed.Status != StatusPending || products.Rows[1].Stock != 1 {
+		t.Errorf("placed order is %q with %d left in stock, want pending with 1", placed.Status, products.Rows[1].Stock)
+	}
+	if mine, _ := s.ListForUser(ctx, bob); len(mine) != 1 {
+		t.Errorf("customer lists %d orders, want the placed draft", len(mine))
+	}
+	if _, _, err := s.PlaceDraft(ctx, 9, placed.ID, placed.Version); repotest.Code(err) != apperror.CodeInvalidTransition {
+		t.Errorf("placing twice: %v, want INVALID_STATUS_TRANSITION", err)
+	}
+
+	got := Timeline(orders.Rows[placed.ID])
+	want := []string{EventDraftCreated, EventEdited, EventPlaced}
+	if len(got) != len(want) {
+		t.Fatalf("timeline = %+v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Event != want[i] {
+			t.Errorf("entry %d = %s, want %s", i, got[i].Event, want[i])
+		}
+	}
+	if got[1].Detail != "removed Teh Melati" {
+		t.Errorf("edit entry = %+v, want what was changed", got[1])
+	}
+}
+
+func TestStatsCountsTodayFromMidnight(t *testing.T) {
+	now := time.Date(2024, 3, 9, 15, 0, 0, 0, time.UTC)
+	orders := repotest.NewOrders(
+		models.Order{OrderNumber: "A", Status: StatusDelivered, TotalAmount: 100, CreatedAt: now.Add(-time.Hour)},
+		models.Order{OrderNumber: "B", Status: StatusPending, TotalAmount: 200, CreatedAt: now.Add(-14 * time.Hour)},
+		models.Order{OrderNumber: "C", Status: StatusDelivered, TotalAmount: 400, CreatedAt: now.Add(-16 * time.Hour)},
+		models.Order{OrderNumber: "D", Status: StatusCancelled, TotalAmount: 800, CreatedAt: now.AddDate(0, 0, -3)},
+	)
+	s := NewService(repotest.Transactor{}, orders, repotest.NewProducts(), repotest.NewUsers()).(*service)
+	s.now = func() time.Time { return now }
+
+	stats, err := s.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		field     string
+		got, want int64
+	}{
+		{"total orders", stats.TotalOrders, 4},
+		{"pending", stats.PendingOrders, 1},
+		{"delivered", stats.DeliveredOrders, 2},
+		{"cancelled", stats.CancelledOrders, 1},
+		{"revenue", stats.TotalRevenue, 500},
+		{"today orders", stats.TodayOrders, 2},
+		{"today revenue", stats.TodayRevenue, 100},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.field, c.got, c.want)
+		}
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	placed := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+	at := func(hours int) *time.Time {
+		t := placed.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+	returnID := uint(7)
+	order := models.Order{
+		OrderNumber: "ORD-20240309-0001",
+		Status:      StatusDelivered,
+		CreatedAt:   placed,
+		Shipments: []models.Shipment{
+			{ID: 1, Carrier: "jne", TrackingNumber: "JNE0012345678", ShippedAt: *at(24), DeliveredAt: at(72)},
+			{ID: 2, Carrier: "sicepat", ShippedAt: *at(30), DeliveredAt: at(50)},
+		},
+		Returns: []models.Return{
+			{ID: 7, RMANumber: "RMA-20240313-0001", CreatedAt: *at(80), ApprovedAt: at(81), ReceivedAt: at(120), RefundedAt: at(121)},
+		},
+		Refunds: []models.Refund{
+			{ID: 3, ReturnID: &returnID, Status: "succeeded", RefundedAt: at(121)},
+			{ID: 4, Amount: 10000, Method: "manual_bank", Status: "succeeded", RefundedAt: at(130)},
+			{ID: 5, Amount: 10000, Method: "provider", Status: "failed"},
+		},
+	}
+
+	got := Timeline(order)
+	want := []string{
+		EventPlaced, EventShipped, EventShipped, EventDelivered, EventDelivered,
+		EventReturnRequested, EventReturnApproved, EventReturnReceived, EventReturnRefunded, EventRefunded,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Event != want[i] {
+			t.Errorf("entry %d = %s, want %s", i, got[i].Event, want[i])
+		}
+	}
+	if got[3].ShipmentID == nil || *got[3].ShipmentID != 2 {
+		t.Errorf("first delivery = %+v, want shipment 2 which arrived first", got[3])
+	}
+	if got[1].Detail != "jne JNE0012345678" || got[2].Detail != "sicepat" {
+		t.Errorf("shipment details = %q, %q", got[1].Detail, got[2].Detail)
+	}
+	if got[8].ReturnID == nil || *got[8].ReturnID != 7 || got[8].Detail != "RMA-20240313-0001" {
+		t.Errorf("refund entry = %+v, want return 7 by its RMA number", got[8])
+	}
+	if got[9].RefundID == nil || *got[9].RefundID != 4 || got[9].Detail != "10000 by manual_bank" {
+		t.Errorf("refund entry = %+v, want the manual refund outside the return", got[9])
+	}
+
+	order.Status = StatusCancelled
+	order.UpdatedAt = placed.Add(time.Hour)
+	order.Shipments, order.Returns, order.Refunds = nil, nil, nil
+	if got := Timeline(order); len(got) != 2 || got[1].Event != EventCancelled {
+		t.Errorf("cancelled order timeline = %+v, want placed then cancelled", got)
+	}
+}
+
+func TestExport(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 10, 0, 0, 0, time.UTC) }
+	sari := models.User{FirstName: "Sari", LastName: "Wulandari", Email: "sari@example.com"}
+	invoice := "INV-2024-000001"
+	order := func(number string, placed time.Time, status string, items ...models.OrderItem) models.Order {
+		return models.Order{UserID: alice, User: sari, OrderNumber: number, Status: status, PaymentStatus: "unpaid",
+			CreatedAt: placed, OrderItems: items}
+	}
+	coffee := models.OrderItem{ProductID: 1, ProductSKU: "KOP-001", ProductName: "Kopi Arabika", Quantity: 2,
+		UnitPrice: 85000, TotalPrice: 170000, TaxPercent: 11, TaxAmount: 18700,
+		Options: map[string]string{"Gilingan": "Kasar", "Berat": "250 g"}}
+	tea := models.OrderItem{ProductID: 2, ProductSKU: "TEH-001", ProductName: "Teh Melati", Quantity: 1,
+		UnitPrice: 25000, TotalPrice: 25000}
+
+	paid := order("ORD-20240301-0001", day(1), StatusDelivered, coffee, tea)
+	paid.PaymentStatus, paid.InvoiceNumber, paid.TotalAmount = PaymentPaid, &invoice, 205000
+	orderRepo := repotest.NewOrders(
+		paid,
+		order("ORD-20240305-0001", day(5), StatusPending, tea),
+		order("ORD-20240309-0001", day(9), StatusCancelled, coffee),
+	)
+	s := NewService(repotest.Transactor{}, orderRepo, repotest.NewProducts(), repotest.NewUsers()).(*service)
+
+	tests := []struct {
+		name     string
+		query    ExportQuery
+		wantCode apperror.Code
+		// want is the first cells of each row after the header
+		want [][]string
+	}{
+		{
+			name:  "a row per order",
+			query: ExportQuery{Format: spreadsheet.CSV, Layout: ExportOrders},
+			want: [][]string{
+				{"ORD-20240301-0001", "2024-03-01 10:00:00", "delivered", "paid", "", "INV-2024-000001"},
+				{"ORD-20240305-0001", "2024-03-05 10:00:00", "pending", "unpaid", "", ""},
+				{"ORD-20240309-0001", "2024-03-09 10:00:00", "cancelled", "unpaid", "", ""},
+			},
+		},
+		{
+			name: "a row per item within dates",
+			query: ExportQuery{Format: spreadsheet.CSV, Layout: ExportItems,
+				Filter: repository.OrderFilter{From: day(1), To: day(6)}},
+			want: [][]string{
+				{"ORD-20240301-0001", "2024-03-01 10:00:00", "delivered", "paid", "INV-2024-000001",
+					"Sari Wulandari", "sari@example.com", "KOP-001", "Kopi Arabika", "Berat: 250 g, Gilingan: Kasar", "2", "85000", "170000", "11", "18700"},
+				{"ORD-20240301-0001", "2024-03-01 10:00:00", "delivered", "paid", "INV-2024-000001",
+					"Sari Wulandari", "sari@example.com", "TEH-001", "Teh Melati", "", "1", "25000", "25000", "0", "0"},
+				{"ORD-20240305-0001", "2024-03-05 10:00:00", "pending", "unpaid", "",
+					"Sari Wulandari", "sari@example.com", "TEH-001"},
+			},
+		},
+		{
+			name:  "filtered by status",
+			query: ExportQuery{Format: spreadsheet.CSV, Layout: ExportOrders, Filter: repository.OrderFilter{Status: StatusCancelled}},
+			want:  [][]string{{"ORD-20240309-0001"}},
+		},
+		{
+			name:     "unknown format",
+			query:    ExportQuery{Format: "pdf", Layout: ExportOrders},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "unknown layout",
+			query:    ExportQuery{Format: spreadsheet.XLSX, Layout: "shipments"},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "dates the wrong way round",
+			query:    ExportQuery{Format: spreadsheet.CSV, Layout: ExportOrders, Filter: repository.OrderFilter{From: day(6), To: day(1)}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := s.Export(context.Background(), tt.query, &buf)
+			if code := repotest.Code(err); code != tt.wantCode || tt.wantCode == "" && err != nil {
+				t.Fatalf("Export() error = %v, want code %q", err, tt.wantCode)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+
+			records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\uFEFF"))).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(tt.want)+1 {
+				t.Fatalf("export has %d rows, want a header and %d: %q", len(records), len(tt.want), records)
+			}
+			for i, want := range tt.want {
+				got := records[i+1][:len(want)]
+				if strings.Join(got, "|") != strings.Join(want, "|") {
+					t.Errorf("row %d = %q, want %q", i+1, got, want)
+				}
+			}
+		})
+	}
+
+	// A client that went away stops the export
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	if err := s.Export(ctx, ExportQuery{Format: spreadsheet.CSV, Layout: ExportOrders}, &buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("Export() after cancel = %v, want context.Canceled", err)
+	}
+}
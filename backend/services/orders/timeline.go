@@ -0,0 +1,106 @@
+package orders
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"ecommerce-backend/models"
+)
+
+// Timeline events
+const (
+	EventDraftCreated    = "draft_created"
+	EventEdited          = "edited"
+	EventPlaced          = "placed"
+	EventCancelled       = "cancelled"
+	EventShipped         = "shipped"
+	EventDelivered       = "delivered"
+	EventReturnRequested = "return_requested"
+	EventReturnApproved  = "return_approved"
+	EventReturnRejected  = "return_rejected"
+	EventReturnReceived  = "return_received"
+	EventReturnRefunded  = "return_refunded"
+	EventRefunded        = "refunded"
+)
+
+// TimelineEntry is one thing that happened to an order
+type TimelineEntry struct {
+	At         time.Time `json:"at"`
+	Event      string    `json:"event"`
+	Detail     string    `json:"detail,omitempty"`
+	ShipmentID *uint     `json:"shipment_id,omitempty"`
+	ReturnID   *uint     `json:"return_id,omitempty"`
+	RefundID   *uint     `json:"refund_id,omitempty"`
+}
+
+// Timeline lists what happened to the order, oldest first: when it was
+// drafted, edited, placed or cancelled, when each shipment left and arrived,
+// and how each of its returns progressed, and when money was refunded outside
+// a return. It needs the order's shipments, returns, refunds and changes.
+func Timeline(order models.Order) []TimelineEntry {
+	// An order drafted by an admin is placed when the draft is, if ever
+	var entries []TimelineEntry
+	placedAt, placed := order.CreatedAt, true
+	for _, change := range order.Changes {
+		switch change.Action {
+		case ChangeCreated:
+			entries = append(entries, TimelineEntry{At: change.CreatedAt, Event: EventDraftCreated, Detail: order.OrderNumber})
+			placed = false
+		case ChangeEdited:
+			entries = append(entries, TimelineEntry{At: change.CreatedAt, Event: EventEdited, Detail: change.Summary})
+		case ChangePlaced:
+			placedAt, placed = change.CreatedAt, true
+		}
+	}
+	if placed {
+		entries = append(entries, TimelineEntry{At: placedAt, Event: EventPlaced, Detail: order.OrderNumber})
+	}
+	if order.Status == StatusCancelled {
+		entries = append(entries, TimelineEntry{At: order.UpdatedAt, Event: EventCancelled})
+	}
+
+	for _, shipment := range order.Shipments {
+		id := shipment.ID
+		detail := shipment.Carrier
+		if shipment.TrackingNumber != "" {
+			detail = fmt.Sprintf("%s %s", shipment.Carrier, shipment.TrackingNumber)
+		}
+		entries = append(entries, TimelineEntry{At: shipment.ShippedAt, Event: EventShipped, Detail: detail, ShipmentID: &id})
+		if shipment.DeliveredAt != nil {
+			entries = append(entries, TimelineEntry{At: *shipment.DeliveredAt, Event: EventDelivered, Detail: detail, ShipmentID: &id})
+		}
+	}
+
+	for _, ret := range order.Returns {
+		id := ret.ID
+		entries = append(entries, TimelineEntry{At: ret.CreatedAt, Event: EventReturnRequested, Detail: ret.RMANumber, ReturnID: &id})
+		steps := []struct {
+			at    *time.Time
+			event string
+		}{
+			{ret.ApprovedAt, EventReturnApproved},
+			{ret.RejectedAt, EventReturnRejected},
+			{ret.ReceivedAt, EventReturnReceived},
+			{ret.RefundedAt, EventReturnRefunded},
+		}
+		for _, step := range steps {
+			if step.at != nil {
+				entries = append(entries, TimelineEntry{At: *step.at, Event: step.event, Detail: ret.RMANumber, ReturnID: &id})
+			}
+		}
+	}
+
+	// Refunds of returns already show as return_refunded
+	for _, refund := range order.Refunds {
+		if refund.ReturnID != nil || refund.Status != "succeeded" || refund.RefundedAt == nil {
+			continue
+		}
+		id := refund.ID
+		detail := fmt.Sprintf("%d by %s", refund.Amount, refund.Method)
+		entries = append(entries, TimelineEntry{At: *refund.RefundedAt, Event: EventRefunded, Detail: detail, RefundID: &id})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+	return entries
+}
@@ -0,0 +1,502 @@
+// Package refunds pays back paid orders, in full or in part, through the
+// payment provider or as manual bank transfers. A refund is either for
+// quantities of the order's items, with their share of shipping and tax, or
+// for an amount split across items, shipping and tax in the proportions
+// still left to refund.
+package refunds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/metrics"
+	"ecommerce-backend/models"
+	"ecommerce-backend/payments"
+	"ecommerce-backend/repository"
+)
+
+// How a refund is paid out
+const (
+	MethodProvider   = "provider"
+	MethodManualBank = "manual_bank"
+)
+
+// Refund statuses. Provider refunds are pending until the provider answers;
+// manual bank refunds are recorded once the transfer was made, so they
+// succeed straight away.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Payment statuses of a refunded order. Only paid orders can be refunded.
+const (
+	PaymentPaid              = "paid"
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+)
+
+// Line is a quantity of one order item to refund
+type Line struct {
+	OrderItemID uint
+	Quantity    int
+}
+
+// Request describes a refund. With Lines it pays back those items and their
+// share of shipping and tax; Amount then caps it at a lower figure. With
+// only Amount it pays back that much of the order, and with neither it pays
+// back everything not yet refunded.
+type Request struct {
+	Lines  []Line
+	Amount *int64
+	Reason string
+	// Method is provider or manual_bank. Empty uses the provider when one is
+	// configured.
+	Method string
+	// BankReference identifies a manual bank transfer
+	BankReference string
+	// ReturnID links the refund to the return it pays back
+	ReturnID  *uint
+	CreatedBy *uint
+}
+
+// Query selects a page of refunds for the admin list
+type Query struct {
+	Page   repository.Page
+	Filter repository.RefundFilter
+}
+
+// Breakdown is what a refund pays back for items, shipping and tax
+type Breakdown struct {
+	Items    int64
+	Shipping int64
+	Tax      int64
+}
+
+func (b Breakdown) Total() int64 {
+	return b.Items + b.Shipping + b.Tax
+}
+
+// scale splits amount, at most b's total, across b's parts in proportion.
+// Rounding cumulatively keeps the parts summing to amount exactly.
+func (b Breakdown) scale(amount int64) Breakdown {
+	total := b.Total()
+	if total == 0 {
+		return Breakdown{}
+	}
+	items := amount * b.Items / total
+	shipping := amount*(b.Items+b.Shipping)/total - items
+	return Breakdown{Items: items, Shipping: shipping, Tax: amount - items - shipping}
+}
+
+// RefundService pays back orders
+type RefundService interface {
+	// Create refunds part or all of a paid order and marks it partially or
+	// fully refunded. A provider refund is stored as pending before the
+	// provider is asked, and kept as failed if the provider declines.
+	Create(ctx context.Context, orderID uint, r Request) (models.Refund, error)
+	// ListByOrder returns the order's refunds, oldest first
+	ListByOrder(ctx context.Context, orderID uint) ([]models.Refund, error)
+	List(ctx context.Context, q Query) ([]models.Refund, int64, error)
+	Get(ctx context.Context, id uint) (models.Refund, error)
+}
+
+type service struct {
+	tx       repository.Transactor
+	orders   repository.OrderRepository
+	refunds  repository.RefundRepository
+	provider payments.Provider
+
+	now func() time.Time
+}
+
+// NewService returns a RefundService backed by the given repositories.
+// provider may be nil, in which case only manual bank refunds are possible.
+func NewService(tx repository.Transactor, orders repository.OrderRepository, refunds repository.RefundRepository,
+	provider payments.Provider) RefundService {
+	return &service{
+		tx:       tx,
+		orders:   orders,
+		refunds:  refunds,
+		provider: provider,
+		now:      time.Now,
+	}
+}
+
+func (s *service) Create(ctx context.Context, orderID uint, r Request) (models.Refund, error) {
+	method, err := s.method(r.Method)
+	if err != nil {
+		return models.Refund{}, err
+	}
+	if r.Amount != nil && *r.Amount <= 0 {
+		return models.Refund{}, apperror.BadRequest(apperror.CodeInvalidRequest, "The amount must be positive")
+	}
+
+	var refund models.Refund
+	var orderNumber string
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orders.Lock(ctx, orderID); err != nil {
+			return orderError(err)
+		}
+		order, err := s.orders.Get(ctx, orderID)
+		if err != nil {
+			return orderError(err)
+		}
+		if order.PaymentStatus != PaymentPaid && order.PaymentStatus != PaymentPartiallyRefunded {
+			return apperror.Conflict(apperror.CodeRefundNotAllowed, "Only paid orders can be refunded").
+				With("payment_status", order.PaymentStatus)
+		}
+
+		previous, err := s.refunds.ListByOrder(ctx, order.ID)
+		if err != nil {
+			return apperror.Internal("Failed to get refunds", err)
+		}
+		if r.ReturnID != nil {
+			for _, p := range previous {
+				if p.ReturnID != nil && *p.ReturnID == *r.ReturnID && p.Status != StatusFailed {
+					return apperror.Conflict(apperror.CodeRefundNotAllowed, "This return has already been refunded").
+						With("refund_id", p.ID)
+				}
+			}
+		}
+		parts, items, err := plan(order, previous, r)
+		if err != nil {
+			return err
+		}
+
+		refund = models.Refund{
+			OrderID:        order.ID,
+			ReturnID:       r.ReturnID,
+			Amount:         parts.Total(),
+			ItemsAmount:    parts.Items,
+			ShippingAmount: parts.Shipping,
+			TaxAmount:      parts.Tax,
+			Reason:         strings.TrimSpace(r.Reason),
+			Method:         method,
+			Status:         StatusPending,
+			CreatedBy:      r.CreatedBy,
+			Items:          items,
+		}
+		if method == MethodManualBank {
+			now := s.now()
+			refund.Status = StatusSucceeded
+			refund.BankReference = strings.TrimSpace(r.BankReference)
+			refund.RefundedAt = &now
+		} else {
+			refund.Provider = s.provider.Code()
+		}
+		if err := s.refunds.Create(ctx, &refund); err != nil {
+			return apperror.Internal("Failed to create refund", err)
+		}
+		orderNumber = order.OrderNumber
+
+		if refund.Status == StatusSucceeded {
+			return s.settle(ctx, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Refund{}, err
+	}
+	if method == MethodManualBank {
+		metrics.Refunds.WithLabelValues(method, StatusSucceeded).Inc()
+		return refund, nil
+	}
+
+	// The provider is called outside the transaction, so the order is not
+	// held locked while it answers
+	result, refundErr := s.provider.Refund(ctx, payments.RefundRequest{
+		OrderNumber: orderNumber,
+		Key:         fmt.Sprintf("%s-refund-%d", orderNumber, refund.ID),
+		Amount:      refund.Amount,
+		Reason:      refund.Reason,
+	})
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		changes := map[string]interface{}{"status": StatusFailed}
+		if refundErr != nil {
+			changes["failure_reason"] = refundErr.Error()
+		} else {
+			changes["status"] = StatusSucceeded
+			changes["provider_ref"] = result.Reference
+			changes["refunded_at"] = s.now()
+		}
+		updated, err := s.refunds.UpdateIfStatus(ctx, refund.ID, StatusPending, changes)
+		if err != nil {
+			return apperror.Internal("Failed to update refund", err)
+		}
+		if !updated {
+			return apperror.Conflict(apperror.CodeInvalidTransition, "Refund was updated meanwhile")
+		}
+		if refundErr != nil {
+			return nil
+		}
+
+		if err := s.orders.Lock(ctx, orderID); err != nil {
+			return orderError(err)
+		}
+		order, err := s.orders.Get(ctx, orderID)
+		if err != nil {
+			return orderError(err)
+		}
+		return s.settle(ctx, order)
+	})
+	if err != nil {
+		// The provider may have paid out already, so the refund needs a look
+		slog.ErrorContext(ctx, "Failed to record refund outcome", "refund_id", refund.ID, "error", err)
+		return models.Refund{}, err
+	}
+
+	if refundErr != nil {
+		metrics.Refunds.WithLabelValues(method, StatusFailed).Inc()
+		slog.WarnContext(ctx, "Payment provider declined refund",
+			"refund_id", refund.ID, "provider", refund.Provider, "error", refundErr)
+		return models.Refund{}, apperror.New(http.StatusBadGateway, apperror.CodeRefundFailed,
+			"The payment provider did not accept the refund").
+			With("refund_id", refund.ID).
+			Wrap(refundErr)
+	}
+	metrics.Refunds.WithLabelValues(method, StatusSucceeded).Inc()
+	return s.Get(ctx, refund.ID)
+}
+
+func (s *service) ListByOrder(ctx context.Context, orderID uint) ([]models.Refund, error) {
+	if _, err := s.orders.Get(ctx, orderID); err != nil {
+		return nil, orderError(err)
+	}
+	refunds, err := s.refunds.ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, apperror.Internal("Failed to get refunds", err)
+	}
+	return refunds, nil
+}
+
+func (s *service) List(ctx context.Context, q Query) ([]models.Refund, int64, error) {
+	refunds, total, err := s.refunds.List(ctx, q.Filter, q.Page)
+	if err != nil {
+		return nil, 0, apperror.Internal("Failed to fetch refunds", err)
+	}
+	return refunds, total, nil
+}
+
+func (s *service) Get(ctx context.Context, id uint) (models.Refund, error) {
+	refund, err := s.refunds.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Refund{}, apperror.NotFound(apperror.CodeRefundNotFound, "Refund not found")
+		}
+		return models.Refund{}, apperror.Internal("Failed to get refund", err)
+	}
+	return refund, nil
+}
+
+// method resolves the requested payout method against the configured
+// provider
+func (s *service) method(method string) (string, error) {
+	switch method {
+	case "":
+		if s.provider != nil {
+			return MethodProvider, nil
+		}
+		return MethodManualBank, nil
+	case MethodProvider:
+		if s.provider == nil {
+			return "", apperror.Conflict(apperror.CodeRefundNotAllowed,
+				"No payment provider is configured; record the refund as a manual bank transfer")
+		}
+		return method, nil
+	case MethodManualBank:
+		return method, nil
+	}
+	return "", apperror.BadRequest(apperror.CodeInvalidRequest, "Method must be provider or manual_bank")
+}
+
+// settle stores what the order's succeeded refunds add up to, and marks it
+// refunded once they cover its total
+func (s *service) settle(ctx context.Context, order models.Order) error {
+	refunds, err := s.refunds.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return apperror.Internal("Failed to get refunds", err)
+	}
+	var refunded int64
+	for _, refund := range refunds {
+		if refund.Status == StatusSucceeded {
+			refunded += refund.Amount
+		}
+	}
+	status := PaymentPartiallyRefunded
+	if refunded >= order.TotalAmount {
+		status = PaymentRefunded
+	}
+	err = s.orders.Patch(ctx, order.ID, map[string]interface{}{
+		"refunded_amount": refunded,
+		"payment_status":  status,
+	})
+	if err != nil {
+		return apperror.Internal("Failed to update payment status", err)
+	}
+	return nil
+}
+
+// plan works out what r pays back of the order, given its earlier refunds.
+// Pending refunds count as paid back, so two refunds made at once cannot
+// both spend the same balance.
+func plan(order models.Order, previous []models.Refund, r Request) (Breakdown, []models.RefundItem, error) {
+	var refunded Breakdown
+	refundedQty := make(map[uint]int)
+	for _, p := range previous {
+		if p.Status == StatusFailed {
+			continue
+		}
+		refunded.Items += p.ItemsAmount
+		refunded.Shipping += p.ShippingAmount
+		refunded.Tax += p.TaxAmount
+		for _, item := range p.Items {
+			refundedQty[item.OrderItemID] += item.Quantity
+		}
+	}
+	left := Breakdown{
+		Items:    max(order.Subtotal-refunded.Items, 0),
+		Shipping: max(order.ShippingCost-refunded.Shipping, 0),
+		Tax:      max(order.Tax-refunded.Tax, 0),
+	}
+	if left.Total() == 0 {
+		return Breakdown{}, nil, apperror.Conflict(apperror.CodeRefundExceedsPaid, "The order has been refunded in full")
+	}
+
+	var parts Breakdown
+	var items []models.RefundItem
+	switch {
+	case len(r.Lines) > 0:
+		var err error
+		items, err = pack(order.OrderItems, refundedQty, r.Lines)
+		if err != nil {
+			return Breakdown{}, nil, err
+		}
+		parts = share(order, refundedQty, items, left)
+		if r.Amount != nil {
+			if *r.Amount > parts.Total() {
+				code := apperror.CodeRefundExceedsItems
+				if r.ReturnID != nil {
+					code = apperror.CodeRefundExceedsReturn
+				}
+				return Breakdown{}, nil, apperror.Conflict(code,
+					fmt.Sprintf("At most %d can be refunded for these items", parts.Total())).
+					With("max_amount", parts.Total())
+			}
+			parts = parts.scale(*r.Amount)
+		}
+	case r.Amount != nil:
+		if *r.Amount > left.Total() {
+			return Breakdown{}, nil, apperror.Conflict(apperror.CodeRefundExceedsPaid,
+				fmt.Sprintf("Only %d of the order is left to refund", left.Total())).
+				With("remaining", left.Total())
+		}
+		parts = left.scale(*r.Amount)
+	default:
+		parts = left
+	}
+
+	if parts.Items > left.Items || parts.Total() > left.Total() {
+		return Breakdown{}, nil, apperror.Conflict(apperror.CodeRefundExceedsPaid,
+			fmt.Sprintf("Only %d of the order is left to refund", left.Total())).
+			With("remaining", left.Total())
+	}
+	if parts.Total() <= 0 {
+		return Breakdown{}, nil, apperror.BadRequest(apperror.CodeInvalidRequest, "The refund comes to nothing")
+	}
+	return parts, items, nil
+}
+
+// share prices refunded items with their part of shipping and tax, in
+// proportion to their share of the subtotal and no more than is left of
+// either. Once the last items are refunded, whatever shipping and tax is left
+// goes with them, so rounding never strands a remainder.
+func share(order models.Order, refundedQty map[uint]int, items []models.RefundItem, left Breakdown) Breakdown {
+	var parts Breakdown
+	refunding := make(map[uint]int, len(items))
+	for _, item := range items {
+		parts.Items += item.Amount
+		refunding[item.OrderItemID] = item.Quantity
+	}
+
+	last := true
+	for _, item := range order.OrderItems {
+		if refundedQty[item.ID]+refunding[item.ID] < item.Quantity {
+			last = false
+			break
+		}
+	}
+	switch {
+	case last:
+		parts.Shipping = left.Shipping
+		parts.Tax = left.Tax
+	case order.Subtotal > 0:
+		parts.Shipping = order.ShippingCost * parts.Items / order.Subtotal
+		parts.Tax = order.Tax * parts.Items / order.Subtotal
+	}
+	parts.Shipping = min(parts.Shipping, left.Shipping)
+	parts.Tax = min(parts.Tax, left.Tax)
+	return parts
+}
+
+// pack turns the requested lines into refund items priced at what they were
+// bought for, checking that each names an item of the order and stays
+// within what was ordered and not already refunded
+func pack(orderItems []models.OrderItem, refunded map[uint]int, lines []Line) ([]models.RefundItem, error) {
+	remaining := make(map[uint]int, len(orderItems))
+	prices := make(map[uint]int64, len(orderItems))
+	for _, item := range orderItems {
+		remaining[item.ID] = item.Quantity - refunded[item.ID]
+		prices[item.ID] = item.UnitPrice
+	}
+
+	// Merge repeated lines so each item is checked against its total
+	quantities := make(map[uint]int, len(lines))
+	var order []uint
+	for _, line := range lines {
+		left, ok := remaining[line.OrderItemID]
+		if !ok {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest,
+				fmt.Sprintf("Order item %d is not part of this order", line.OrderItemID)).
+				With("order_item_id", line.OrderItemID)
+		}
+		if line.Quantity < 1 {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Quantities must be at least 1").
+				With("order_item_id", line.OrderItemID)
+		}
+		if _, seen := quantities[line.OrderItemID]; !seen {
+			order = append(order, line.OrderItemID)
+		}
+		quantities[line.OrderItemID] += line.Quantity
+		if quantities[line.OrderItemID] > left {
+			return nil, apperror.Conflict(apperror.CodeRefundExceedsItems,
+				fmt.Sprintf("Only %d of order item %d can still be refunded", left, line.OrderItemID)).
+				With("order_item_id", line.OrderItemID).
+				With("remaining", left)
+		}
+	}
+
+	packed := make([]models.RefundItem, 0, len(order))
+	for _, id := range order {
+		packed = append(packed, models.RefundItem{
+			OrderItemID: id,
+			Quantity:    quantities[id],
+			Amount:      prices[id] * int64(quantities[id]),
+		})
+	}
+	return packed, nil
+}
+
+func orderError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperror.NotFound(apperror.CodeOrderNotFound, "Order not found")
+	}
+	return apperror.Internal("Failed to get order", err)
+}
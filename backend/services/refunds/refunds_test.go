@@ -0,0 +1,266 @@
+package refunds
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/payments"
+	"ecommerce-backend/repository/repotest"
+)
+
+// newTestService returns a service over a paid order of 2 of item 11 at
+// 85000 and 1 of item 12 at 25000: 195000 of items, 10000 of shipping and
+// 19500 of tax, with the given refunds already made
+func newTestService(provider payments.Provider, existing ...models.Refund) (*service, *repotest.Orders, *repotest.Refunds) {
+	orderRepo := repotest.NewOrders(models.Order{
+		UserID:        1,
+		OrderNumber:   "ORD-20240309-0001",
+		Status:        "delivered",
+		PaymentStatus: PaymentPaid,
+		Subtotal:      195000,
+		Tax:           19500,
+		ShippingCost:  10000,
+		TotalAmount:   224500,
+		OrderItems: []models.OrderItem{
+			{ID: 11, ProductID: 1, Quantity: 2, UnitPrice: 85000},
+			{ID: 12, ProductID: 2, Quantity: 1, UnitPrice: 25000},
+		},
+	})
+	refundRepo := repotest.NewRefunds(existing...)
+	return NewService(repotest.Transactor{}, orderRepo, refundRepo, provider).(*service), orderRepo, refundRepo
+}
+
+func amount(n int64) *int64 { return &n }
+
+func TestCreate(t *testing.T) {
+	returnID := uint(7)
+	tests := []struct {
+		name        string
+		payment     string
+		existing    []models.Refund
+		request     Request
+		want        Breakdown
+		wantPayment string
+		wantCode    apperror.Code
+	}{
+		{
+			name:        "items with their share of shipping and tax",
+			request:     Request{Lines: []Line{{11, 1}}},
+			want:        Breakdown{Items: 85000, Shipping: 4358, Tax: 8500},
+			wantPayment: PaymentPartiallyRefunded,
+		},
+		{
+			name:        "items capped at an amount",
+			request:     Request{Lines: []Line{{11, 1}}, Amount: amount(50000)},
+			want:        Breakdown{Items: 43430, Shipping: 2226, Tax: 4344},
+			wantPayment: PaymentPartiallyRefunded,
+		},
+		{
+			name:        "an amount of the order",
+			request:     Request{Amount: amount(100000)},
+			want:        Breakdown{Items: 86859, Shipping: 4455, Tax: 8686},
+			wantPayment: PaymentPartiallyRefunded,
+		},
+		{
+			name:        "everything",
+			want:        Breakdown{Items: 195000, Shipping: 10000, Tax: 19500},
+			wantPayment: PaymentRefunded,
+		},
+		{
+			name:    "the last items take what is left of shipping and tax",
+			payment: PaymentPartiallyRefunded,
+			existing: []models.Refund{{
+				OrderID: 1, Amount: 97858, ItemsAmount: 85000, ShippingAmount: 4358, TaxAmount: 8500,
+				Method: MethodManualBank, Status: StatusSucceeded,
+				Items: []models.RefundItem{{OrderItemID: 11, Quantity: 1, Amount: 85000}},
+			}},
+			request:     Request{Lines: []Line{{11, 1}, {12, 1}}},
+			want:        Breakdown{Items: 110000, Shipping: 5642, Tax: 11000},
+			wantPayment: PaymentRefunded,
+		},
+		{
+			name:    "failed refunds do not count",
+			payment: PaymentPartiallyRefunded,
+			existing: []models.Refund{{
+				OrderID: 1, Amount: 224500, ItemsAmount: 195000, ShippingAmount: 10000, TaxAmount: 19500,
+				Method: MethodProvider, Status: StatusFailed,
+			}},
+			request:     Request{Amount: amount(224500)},
+			want:        Breakdown{Items: 195000, Shipping: 10000, Tax: 19500},
+			wantPayment: PaymentRefunded,
+		},
+		{
+			name: "pending refunds count",
+			existing: []models.Refund{{
+				OrderID: 1, Amount: 200000, ItemsAmount: 173719, ShippingAmount: 8909, TaxAmount: 17372,
+				Method: MethodProvider, Status: StatusPending,
+			}},
+			request:  Request{Amount: amount(24501)},
+			wantCode: apperror.CodeRefundExceedsPaid,
+		},
+		{
+			name:    "refunded in full",
+			payment: PaymentPartiallyRefunded,
+			existing: []models.Refund{{
+				OrderID: 1, Amount: 224500, ItemsAmount: 195000, ShippingAmount: 10000, TaxAmount: 19500,
+				Method: MethodManualBank, Status: StatusSucceeded,
+			}},
+			wantCode: apperror.CodeRefundExceedsPaid,
+		},
+		{
+			name:     "more than the items come to",
+			request:  Request{Lines: []Line{{12, 1}}, Amount: amount(40000)},
+			wantCode: apperror.CodeRefundExceedsItems,
+		},
+		{
+			name:     "more of the return than it comes to",
+			request:  Request{Lines: []Line{{12, 1}}, Amount: amount(40000), ReturnID: &returnID},
+			wantCode: apperror.CodeRefundExceedsReturn,
+		},
+		{
+			name: "return already refunded",
+			existing: []models.Refund{{
+				OrderID: 1, ReturnID: &returnID, Amount: 28808, ItemsAmount: 25000, ShippingAmount: 1282, TaxAmount: 2500,
+				Method: MethodManualBank, Status: StatusSucceeded,
+			}},
+			request:  Request{Lines: []Line{{12, 1}}, ReturnID: &returnID},
+			wantCode: apperror.CodeRefundNotAllowed,
+		},
+		{
+			name:     "more than was ordered",
+			request:  Request{Lines: []Line{{11, 1}, {11, 2}}},
+			wantCode: apperror.CodeRefundExceedsItems,
+		},
+		{
+			name:     "item of another order",
+			request:  Request{Lines: []Line{{99, 1}}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "no amount",
+			request:  Request{Amount: amount(0)},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "unpaid",
+			payment:  "unpaid",
+			request:  Request{Amount: amount(1000)},
+			wantCode: apperror.CodeRefundNotAllowed,
+		},
+		{
+			name:     "no provider configured",
+			request:  Request{Amount: amount(1000), Method: MethodProvider},
+			wantCode: apperror.CodeRefundNotAllowed,
+		},
+		{
+			name:     "unknown method",
+			request:  Request{Amount: amount(1000), Method: "cash"},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, orderRepo, refundRepo := newTestService(nil, tt.existing...)
+			if tt.payment != "" {
+				order := orderRepo.Rows[1]
+				order.PaymentStatus = tt.payment
+				orderRepo.Rows[1] = order
+			}
+
+			refund, err := s.Create(context.Background(), 1, tt.request)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != "" {
+				if len(refundRepo.Rows) != len(tt.existing) {
+					t.Errorf("a failed refund was stored")
+				}
+				return
+			}
+
+			got := Breakdown{Items: refund.ItemsAmount, Shipping: refund.ShippingAmount, Tax: refund.TaxAmount}
+			if got != tt.want || refund.Amount != tt.want.Total() {
+				t.Errorf("refund of %d = %+v, want %+v", refund.Amount, got, tt.want)
+			}
+			if refund.Method != MethodManualBank || refund.Status != StatusSucceeded || refund.RefundedAt == nil {
+				t.Errorf("refund = %+v, want a succeeded manual bank refund", refund)
+			}
+			if (len(tt.request.Lines) > 0) != (len(refund.Items) > 0) {
+				t.Errorf("items = %+v for lines %+v", refund.Items, tt.request.Lines)
+			}
+
+			order := orderRepo.Rows[1]
+			var refunded int64
+			for _, r := range refundRepo.Rows {
+				if r.Status == StatusSucceeded {
+					refunded += r.Amount
+				}
+			}
+			if order.PaymentStatus != tt.wantPayment || order.RefundedAmount != refunded {
+				t.Errorf("order payment %q with %d refunded, want %q with %d",
+					order.PaymentStatus, order.RefundedAmount, tt.wantPayment, refunded)
+			}
+		})
+	}
+}
+
+func TestCreateThroughProvider(t *testing.T) {
+	provider := payments.NewFake()
+	s, orderRepo, _ := newTestService(provider)
+	ctx := context.Background()
+
+	refund, err := s.Create(ctx, 1, Request{Lines: []Line{{12, 1}}, Reason: "Barang rusak"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Method != MethodProvider || refund.Status != StatusSucceeded || refund.Provider != "fake" || refund.ProviderRef != "fake-1" {
+		t.Errorf("refund = %+v, want one the provider paid", refund)
+	}
+	sent := provider.Refunds()
+	if len(sent) != 1 || sent[0].OrderNumber != "ORD-20240309-0001" || sent[0].Amount != refund.Amount || sent[0].Key == "" {
+		t.Errorf("provider was asked for %+v", sent)
+	}
+	if got := orderRepo.Rows[1].RefundedAmount; got != refund.Amount {
+		t.Errorf("refunded amount = %d, want %d", got, refund.Amount)
+	}
+
+	provider.Err = errors.New("insufficient merchant balance")
+	_, err = s.Create(ctx, 1, Request{Amount: amount(5000)})
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) || appErr.Code != apperror.CodeRefundFailed || appErr.Status != http.StatusBadGateway {
+		t.Fatalf("declined refund: %v, want 502 REFUND_FAILED", err)
+	}
+	failed, err := s.Get(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.Status != StatusFailed || failed.FailureReason == "" {
+		t.Errorf("declined refund = %+v, want it kept as failed", failed)
+	}
+	if got := orderRepo.Rows[1].RefundedAmount; got != refund.Amount {
+		t.Errorf("refunded amount = %d after a declined refund, want %d", got, refund.Amount)
+	}
+
+	// The declined amount is free to refund again
+	provider.Err = nil
+	if _, err := s.Create(ctx, 1, Request{Amount: amount(224500 - refund.Amount)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := orderRepo.Rows[1].PaymentStatus; got != PaymentRefunded {
+		t.Errorf("payment status = %q, want refunded", got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	s, _, _ := newTestService(nil)
+	if _, err := s.Get(context.Background(), 1); repotest.Code(err) != apperror.CodeRefundNotFound {
+		t.Errorf("missing refund: %v, want REFUND_NOT_FOUND", err)
+	}
+	if _, err := s.ListByOrder(context.Background(), 99); repotest.Code(err) != apperror.CodeOrderNotFound {
+		t.Errorf("refunds of a missing order: %v, want ORDER_NOT_FOUND", err)
+	}
+}
@@ -0,0 +1,444 @@
+// Package returns handles returns of delivered orders under an RMA (return
+// merchandise authorisation) number. A customer requests a return of some of
+// an order's items within the return window; an admin approves or rejects
+// it, receives the goods, restocks or writes them off, and refunds the
+// customer through the refunds service.
+package returns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/services/refunds"
+
+	"github.com/google/uuid"
+)
+
+// Return statuses. A requested return is approved or rejected; an approved
+// one is received and then refunded.
+const (
+	StatusRequested = "requested"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusReceived  = "received"
+	StatusRefunded  = "refunded"
+)
+
+// What happens to received goods
+const (
+	DispositionRestock  = "restock"
+	DispositionWriteOff = "write_off"
+)
+
+// Line is a quantity of one order item to return
+type Line struct {
+	OrderItemID uint
+	Quantity    int
+}
+
+// Request is a customer's return request
+type Request struct {
+	Reason string
+	// Photos are URLs of pictures of the goods
+	Photos []string
+	Lines  []Line
+}
+
+// Query selects a page of returns for the admin list
+type Query struct {
+	Page   repository.Page
+	Filter repository.ReturnFilter
+}
+
+// ReturnService runs the return workflow
+type ReturnService interface {
+	// Request opens a return of the customer's delivered order, within the
+	// return window and for no more than was ordered and not yet returned
+	Request(ctx context.Context, userID, orderID uint, r Request) (models.Return, error)
+	// ListForUser returns the customer's returns, newest first
+	ListForUser(ctx context.Context, userID uint) ([]models.Return, error)
+	// GetForUser returns one of the customer's returns
+	GetForUser(ctx context.Context, userID, id uint) (models.Return, error)
+
+	List(ctx context.Context, q Query) ([]models.Return, int64, error)
+	Get(ctx context.Context, id uint) (models.Return, error)
+	// Approve accepts a requested return, so the customer can send the
+	// goods back
+	Approve(ctx context.Context, id uint, note string) (models.Return, error)
+	// Reject turns down a requested return
+	Reject(ctx context.Context, id uint, note string) (models.Return, error)
+	// Receive records that an approved return's goods arrived, and what
+	// happened to each returned item, by return item ID. Items without a
+	// disposition are restocked.
+	Receive(ctx context.Context, id uint, dispositions map[uint]string) (models.Return, error)
+	// Refund pays back a received return: by default its items with their
+	// share of shipping and tax, or r.Amount when set. The return's items
+	// take the place of any Lines in r.
+	Refund(ctx context.Context, id uint, r refunds.Request) (models.Return, error)
+}
+
+type service struct {
+	tx        repository.Transactor
+	orders    repository.OrderRepository
+	returns   repository.ReturnRepository
+	products  repository.ProductRepository
+	refunds   refunds.RefundService
+	window    time.Duration
+	maxPhotos int
+
+	now func() time.Time
+}
+
+// NewService returns a ReturnService backed by the given repositories,
+// paying returns back through refunder. Returns can be requested for window
+// after delivery, with up to maxPhotos photos.
+func NewService(tx repository.Transactor, orders repository.OrderRepository, returns repository.ReturnRepository,
+	products repository.ProductRepository, refunder refunds.RefundService, window time.Duration, maxPhotos int) ReturnService {
+	return &service{
+		tx:        tx,
+		orders:    orders,
+		returns:   returns,
+		products:  products,
+		refunds:   refunder,
+		window:    window,
+		maxPhotos: maxPhotos,
+		now:       time.Now,
+	}
+}
+
+// DeliveredAt returns when the order's last shipment arrived. Orders
+// delivered without shipments count from their last update.
+func DeliveredAt(order models.Order) time.Time {
+	var at time.Time
+	for _, shipment := range order.Shipments {
+		if shipment.DeliveredAt != nil && shipment.DeliveredAt.After(at) {
+			at = *shipment.DeliveredAt
+		}
+	}
+	if at.IsZero() {
+		return order.UpdatedAt
+	}
+	return at
+}
+
+// Returned totals the quantity of each order item, by ID, across returns
+// that were not rejected
+func Returned(returns []models.Return) map[uint]int {
+	returned := make(map[uint]int)
+	for _, ret := range returns {
+		if ret.Status == StatusRejected {
+			continue
+		}
+		for _, item := range ret.Items {
+			returned[item.OrderItemID] += item.Quantity
+		}
+	}
+	return returned
+}
+
+func (s *service) Request(ctx context.Context, userID, orderID uint, r Request) (models.Return, error) {
+	reason := strings.TrimSpace(r.Reason)
+	if reason == "" {
+		return models.Return{}, apperror.BadRequest(apperror.CodeInvalidRequest, "A reason is required")
+	}
+	if len(r.Photos) > s.maxPhotos {
+		return models.Return{}, apperror.BadRequest(apperror.CodeInvalidRequest,
+			fmt.Sprintf("At most %d photos can be attached", s.maxPhotos)).
+			With("max_photos", s.maxPhotos)
+	}
+
+	var ret models.Return
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orders.Lock(ctx, orderID); err != nil {
+			return orderError(err)
+		}
+		order, err := s.orders.GetForUser(ctx, orderID, userID)
+		if err != nil {
+			return orderError(err)
+		}
+		if order.Status != orders.StatusDelivered {
+			return apperror.Conflict(apperror.CodeReturnNotAllowed, "Only delivered orders can be returned").
+				With("order_status", order.Status)
+		}
+		now := s.now()
+		if deadline := DeliveredAt(order).Add(s.window); now.After(deadline) {
+			return apperror.Conflict(apperror.CodeReturnWindowClosed, "The return window for this order has closed").
+				With("deadline", deadline)
+		}
+
+		existing, err := s.returns.ListByOrder(ctx, order.ID)
+		if err != nil {
+			return apperror.Internal("Failed to get returns", err)
+		}
+		items, err := pack(order.OrderItems, Returned(existing), r.Lines)
+		if err != nil {
+			return err
+		}
+
+		ret = models.Return{
+			OrderID:   order.ID,
+			UserID:    userID,
+			RMANumber: rmaNumber(now),
+			Status:    StatusRequested,
+			Reason:    reason,
+			Items:     items,
+		}
+		for _, url := range r.Photos {
+			ret.Photos = append(ret.Photos, models.ReturnPhoto{URL: strings.TrimSpace(url)})
+		}
+		if err := s.returns.Create(ctx, &ret); err != nil {
+			return apperror.Internal("Failed to create return", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Return{}, err
+	}
+	return ret, nil
+}
+
+func (s *service) ListForUser(ctx context.Context, userID uint) ([]models.Return, error) {
+	returns, err := s.returns.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, apperror.Internal("Failed to get returns", err)
+	}
+	return returns, nil
+}
+
+func (s *service) GetForUser(ctx context.Context, userID, id uint) (models.Return, error) {
+	ret, err := s.returns.GetForUser(ctx, id, userID)
+	if err != nil {
+		return models.Return{}, returnError(err)
+	}
+	return ret, nil
+}
+
+func (s *service) List(ctx context.Context, q Query) ([]models.Return, int64, error) {
+	returns, total, err := s.returns.List(ctx, q.Filter, q.Page)
+	if err != nil {
+		return nil, 0, apperror.Internal("Failed to fetch returns", err)
+	}
+	return returns, total, nil
+}
+
+func (s *service) Get(ctx context.Context, id uint) (models.Return, error) {
+	ret, err := s.returns.Get(ctx, id)
+	if err != nil {
+		return models.Return{}, returnError(err)
+	}
+	return ret, nil
+}
+
+func (s *service) Approve(ctx context.Context, id uint, note string) (models.Return, error) {
+	return s.transition(ctx, id, StatusRequested, StatusApproved, map[string]interface{}{
+		"admin_note":  strings.TrimSpace(note),
+		"approved_at": s.now(),
+	})
+}
+
+func (s *service) Reject(ctx context.Context, id uint, note string) (models.Return, error) {
+	return s.transition(ctx, id, StatusRequested, StatusRejected, map[string]interface{}{
+		"admin_note":  strings.TrimSpace(note),
+		"rejected_at": s.now(),
+	})
+}
+
+func (s *service) Receive(ctx context.Context, id uint, dispositions map[uint]string) (models.Return, error) {
+	var ret models.Return
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.returns.Get(ctx, id)
+		if err != nil {
+			return returnError(err)
+		}
+		items := make(map[uint]bool, len(current.Items))
+		for _, item := range current.Items {
+			items[item.ID] = true
+		}
+		for itemID, disposition := range dispositions {
+			if !items[itemID] {
+				return apperror.BadRequest(apperror.CodeInvalidRequest,
+					fmt.Sprintf("Return item %d is not part of this return", itemID)).
+					With("return_item_id", itemID)
+			}
+			if disposition != DispositionRestock && disposition != DispositionWriteOff {
+				return apperror.BadRequest(apperror.CodeInvalidRequest, "Disposition must be restock or write_off").
+					With("return_item_id", itemID)
+			}
+		}
+
+		ret, err = s.transition(ctx, id, StatusApproved, StatusReceived, map[string]interface{}{
+			"received_at": s.now(),
+		})
+		if err != nil {
+			return err
+		}
+		order, err := s.orders.Get(ctx, ret.OrderID)
+		if err != nil {
+			return orderError(err)
+		}
+		products := make(map[uint]uint, len(order.OrderItems))
+		for _, item := range order.OrderItems {
+			products[item.ID] = item.ProductID
+		}
+
+		for i, item := range ret.Items {
+			disposition := dispositions[item.ID]
+			if disposition == "" {
+				disposition = DispositionRestock
+			}
+			if err := s.returns.SetDisposition(ctx, item.ID, disposition); err != nil {
+				return apperror.Internal("Failed to record disposition", err)
+			}
+			ret.Items[i].Disposition = disposition
+			if disposition != DispositionRestock {
+				continue
+			}
+			if err := s.products.IncrementStock(ctx, products[item.OrderItemID], item.Quantity); err != nil {
+				return apperror.Internal("Failed to restock returned items",
+					fmt.Errorf("return %d, order item %d: %w", ret.ID, item.OrderItemID, err))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Return{}, err
+	}
+	return ret, nil
+}
+
+func (s *service) Refund(ctx context.Context, id uint, r refunds.Request) (models.Return, error) {
+	current, err := s.returns.Get(ctx, id)
+	if err != nil {
+		return models.Return{}, returnError(err)
+	}
+	if current.Status != StatusReceived {
+		return models.Return{}, apperror.Conflict(apperror.CodeInvalidTransition,
+			fmt.Sprintf("Only %s returns can be %s", StatusReceived, StatusRefunded)).
+			With("return_status", current.Status)
+	}
+
+	// The refund is made outside a transaction so that, once the provider
+	// paid it, it is kept even if the return changed meanwhile. The refunds
+	// service will not pay the same return twice.
+	r.ReturnID = &current.ID
+	r.Lines = nil
+	for _, item := range current.Items {
+		r.Lines = append(r.Lines, refunds.Line{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+	if strings.TrimSpace(r.Reason) == "" {
+		r.Reason = "Return " + current.RMANumber
+	}
+	refund, err := s.refunds.Create(ctx, current.OrderID, r)
+	if err != nil {
+		return models.Return{}, err
+	}
+
+	refundedAt := s.now()
+	if refund.RefundedAt != nil {
+		refundedAt = *refund.RefundedAt
+	}
+	return s.transition(ctx, id, StatusReceived, StatusRefunded, map[string]interface{}{
+		"refund_amount": refund.Amount,
+		"refunded_at":   refundedAt,
+	})
+}
+
+// transition moves the return from status from to status to, with changes,
+// and returns it afterwards
+func (s *service) transition(ctx context.Context, id uint, from, to string, changes map[string]interface{}) (models.Return, error) {
+	ret, err := s.returns.Get(ctx, id)
+	if err != nil {
+		return models.Return{}, returnError(err)
+	}
+	if ret.Status != from {
+		return models.Return{}, apperror.Conflict(apperror.CodeInvalidTransition,
+			fmt.Sprintf("Only %s returns can be %s", from, to)).
+			With("return_status", ret.Status)
+	}
+
+	changes["status"] = to
+	updated, err := s.returns.UpdateIfStatus(ctx, id, from, changes)
+	if err != nil {
+		return models.Return{}, apperror.Internal("Failed to update return", err)
+	}
+	if !updated {
+		return models.Return{}, apperror.Conflict(apperror.CodeInvalidTransition, "Return was updated meanwhile")
+	}
+
+	ret, err = s.returns.Get(ctx, id)
+	if err != nil {
+		return models.Return{}, returnError(err)
+	}
+	return ret, nil
+}
+
+// pack turns the requested lines into return items, checking that each
+// names an item of the order and stays within what was ordered and not
+// already returned
+func pack(items []models.OrderItem, returned map[uint]int, lines []Line) ([]models.ReturnItem, error) {
+	if len(lines) == 0 {
+		return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Choose at least one item to return")
+	}
+	remaining := make(map[uint]int, len(items))
+	for _, item := range items {
+		remaining[item.ID] = item.Quantity - returned[item.ID]
+	}
+
+	// Merge repeated lines so each item is checked against its total
+	quantities := make(map[uint]int, len(lines))
+	var order []uint
+	for _, line := range lines {
+		left, ok := remaining[line.OrderItemID]
+		if !ok {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest,
+				fmt.Sprintf("Order item %d is not part of this order", line.OrderItemID)).
+				With("order_item_id", line.OrderItemID)
+		}
+		if line.Quantity < 1 {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Quantities must be at least 1").
+				With("order_item_id", line.OrderItemID)
+		}
+		if _, seen := quantities[line.OrderItemID]; !seen {
+			order = append(order, line.OrderItemID)
+		}
+		quantities[line.OrderItemID] += line.Quantity
+		if quantities[line.OrderItemID] > left {
+			return nil, apperror.Conflict(apperror.CodeReturnExceedsOrder,
+				fmt.Sprintf("Only %d of order item %d can still be returned", left, line.OrderItemID)).
+				With("order_item_id", line.OrderItemID).
+				With("remaining", left)
+		}
+	}
+
+	packed := make([]models.ReturnItem, 0, len(order))
+	for _, id := range order {
+		packed = append(packed, models.ReturnItem{OrderItemID: id, Quantity: quantities[id]})
+	}
+	return packed, nil
+}
+
+// rmaNumber identifies a return to the customer and the warehouse
+func rmaNumber(at time.Time) string {
+	return fmt.Sprintf("RMA-%s-%s", at.Format("20060102"), uuid.New().String()[:8])
+}
+
+func returnError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperror.NotFound(apperror.CodeReturnNotFound, "Return not found")
+	}
+	return apperror.Internal("Failed to get return", err)
+}
+
+func orderError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperror.NotFound(apperror.CodeOrderNotFound, "Order not found")
+	}
+	return apperror.Internal("Failed to get order", err)
+}
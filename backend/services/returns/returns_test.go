@@ -0,0 +1,282 @@
+package returns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository/repotest"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/services/refunds"
+)
+
+const (
+	alice uint = 1
+	bob   uint = 2
+)
+
+var deliveredAt = time.Date(2024, 3, 12, 14, 0, 0, 0, time.UTC)
+
+// newTestService returns a service over alice's delivered and paid order of
+// 2 of item 11 (product 1 at 85000) and 1 of item 12 (product 2 at 25000),
+// two days after delivery, with the given returns already made. Refunds are
+// manual bank transfers.
+func newTestService(existing ...models.Return) (*service, *repotest.Orders, *repotest.Returns, *repotest.Products) {
+	products := repotest.NewProducts(
+		models.Product{ID: 1, Name: "Kopi Arabika", Price: 85000, Stock: 3, IsActive: true},
+		models.Product{ID: 2, Name: "Teh Melati", Price: 25000, Stock: 0, IsActive: true},
+	)
+	orderRepo := repotest.NewOrders(models.Order{
+		UserID:        alice,
+		OrderNumber:   "ORD-20240309-0001",
+		Status:        orders.StatusDelivered,
+		PaymentStatus: refunds.PaymentPaid,
+		Subtotal:      195000,
+		Tax:           19500,
+		ShippingCost:  10000,
+		TotalAmount:   224500,
+		OrderItems: []models.OrderItem{
+			{ID: 11, ProductID: 1, Quantity: 2, UnitPrice: 85000},
+			{ID: 12, ProductID: 2, Quantity: 1, UnitPrice: 25000},
+		},
+		Shipments: []models.Shipment{{ID: 1, OrderID: 1, DeliveredAt: &deliveredAt}},
+	})
+	returnRepo := repotest.NewReturns(existing...)
+	refunder := refunds.NewService(repotest.Transactor{}, orderRepo, repotest.NewRefunds(), nil)
+	s := NewService(repotest.Transactor{}, orderRepo, returnRepo, products, refunder, 7*24*time.Hour, 2).(*service)
+	s.now = func() time.Time { return deliveredAt.Add(48 * time.Hour) }
+	return s, orderRepo, returnRepo, products
+}
+
+func existingReturn(status string, items ...models.ReturnItem) models.Return {
+	return models.Return{OrderID: 1, UserID: alice, RMANumber: "RMA-" + status, Status: status, Reason: "Rusak", Items: items}
+}
+
+func TestRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		userID   uint
+		after    time.Duration
+		existing []models.Return
+		request  Request
+		wantCode apperror.Code
+	}{
+		{
+			name:    "some items",
+			request: Request{Reason: "Kemasan rusak", Photos: []string{"https://example.com/1.jpg"}, Lines: []Line{{11, 1}, {12, 1}}},
+		},
+		{
+			name:    "repeated lines within what was ordered",
+			request: Request{Reason: "Salah kirim", Lines: []Line{{11, 1}, {11, 1}}},
+		},
+		{
+			name:     "rejected returns do not count",
+			existing: []models.Return{existingReturn(StatusRejected, models.ReturnItem{OrderItemID: 11, Quantity: 2})},
+			request:  Request{Reason: "Salah kirim", Lines: []Line{{11, 2}}},
+		},
+		{
+			name:     "another customer's order",
+			userID:   bob,
+			request:  Request{Reason: "Rusak", Lines: []Line{{11, 1}}},
+			wantCode: apperror.CodeOrderNotFound,
+		},
+		{
+			name:     "not delivered",
+			status:   orders.StatusShipped,
+			request:  Request{Reason: "Rusak", Lines: []Line{{11, 1}}},
+			wantCode: apperror.CodeReturnNotAllowed,
+		},
+		{
+			name:     "window closed",
+			after:    8 * 24 * time.Hour,
+			request:  Request{Reason: "Rusak", Lines: []Line{{11, 1}}},
+			wantCode: apperror.CodeReturnWindowClosed,
+		},
+		{
+			name:     "more than ordered",
+			request:  Request{Reason: "Rusak", Lines: []Line{{11, 2}, {11, 1}}},
+			wantCode: apperror.CodeReturnExceedsOrder,
+		},
+		{
+			name:     "already being returned",
+			existing: []models.Return{existingReturn(StatusApproved, models.ReturnItem{OrderItemID: 12, Quantity: 1})},
+			request:  Request{Reason: "Rusak", Lines: []Line{{12, 1}}},
+			wantCode: apperror.CodeReturnExceedsOrder,
+		},
+		{
+			name:     "item of another order",
+			request:  Request{Reason: "Rusak", Lines: []Line{{99, 1}}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "no items",
+			request:  Request{Reason: "Rusak"},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "no reason",
+			request:  Request{Reason: "  ", Lines: []Line{{11, 1}}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name: "too many photos",
+			request: Request{Reason: "Rusak", Lines: []Line{{11, 1}}, Photos: []string{
+				"https://example.com/1.jpg", "https://example.com/2.jpg", "https://example.com/3.jpg",
+			}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, orderRepo, returnRepo, _ := newTestService(tt.existing...)
+			if tt.status != "" {
+				order := orderRepo.Rows[1]
+				order.Status = tt.status
+				orderRepo.Rows[1] = order
+			}
+			if tt.after != 0 {
+				s.now = func() time.Time { return deliveredAt.Add(tt.after) }
+			}
+			userID := tt.userID
+			if userID == 0 {
+				userID = alice
+			}
+
+			ret, err := s.Request(context.Background(), userID, 1, tt.request)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != "" {
+				if len(returnRepo.Rows) != len(tt.existing) {
+					t.Errorf("a failed request stored a return")
+				}
+				return
+			}
+			if ret.ID == 0 || ret.Status != StatusRequested || ret.RMANumber == "" {
+				t.Errorf("return = %+v, want a stored request with an RMA number", ret)
+			}
+			if len(ret.Photos) != len(tt.request.Photos) {
+				t.Errorf("stored %d photos, want %d", len(ret.Photos), len(tt.request.Photos))
+			}
+			if len(ret.Items) == 0 || ret.Items[0].OrderItemID == 0 {
+				t.Errorf("items = %+v", ret.Items)
+			}
+		})
+	}
+}
+
+func TestDeliveredAtWithoutShipments(t *testing.T) {
+	updated := deliveredAt.Add(time.Hour)
+	if got := DeliveredAt(models.Order{UpdatedAt: updated}); !got.Equal(updated) {
+		t.Errorf("DeliveredAt() = %v, want the last update %v", got, updated)
+	}
+}
+
+func TestWorkflow(t *testing.T) {
+	s, orderRepo, returnRepo, products := newTestService()
+	ctx := context.Background()
+
+	ret, err := s.Request(ctx, alice, 1, Request{Reason: "Kemasan rusak", Lines: []Line{{11, 1}, {12, 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Receive(ctx, ret.ID, nil); repotest.Code(err) != apperror.CodeInvalidTransition {
+		t.Errorf("receiving a requested return: %v, want INVALID_STATUS_TRANSITION", err)
+	}
+
+	ret, err = s.Approve(ctx, ret.ID, " Silakan kirim kembali ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Status != StatusApproved || ret.ApprovedAt == nil || ret.AdminNote != "Silakan kirim kembali" {
+		t.Errorf("approved return = %+v", ret)
+	}
+	if _, err := s.Reject(ctx, ret.ID, ""); repotest.Code(err) != apperror.CodeInvalidTransition {
+		t.Errorf("rejecting an approved return: %v, want INVALID_STATUS_TRANSITION", err)
+	}
+	if _, err := s.Refund(ctx, ret.ID, refunds.Request{}); repotest.Code(err) != apperror.CodeInvalidTransition {
+		t.Errorf("refunding goods not yet received: %v, want INVALID_STATUS_TRANSITION", err)
+	}
+
+	// Item 11 arrived broken and is written off; item 12 goes back in stock
+	writeOff := map[uint]string{ret.Items[0].ID: DispositionWriteOff}
+	if _, err := s.Receive(ctx, ret.ID, map[uint]string{99: DispositionRestock}); repotest.Code(err) != apperror.CodeInvalidRequest {
+		t.Errorf("receiving an unknown item: %v, want INVALID_REQUEST", err)
+	}
+	ret, err = s.Receive(ctx, ret.ID, writeOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Status != StatusReceived || ret.ReceivedAt == nil {
+		t.Errorf("received return = %+v", ret)
+	}
+	if got := returnRepo.Rows[ret.ID].Items; got[0].Disposition != DispositionWriteOff || got[1].Disposition != DispositionRestock {
+		t.Errorf("dispositions = %q, %q; want write_off, restock", got[0].Disposition, got[1].Disposition)
+	}
+	if products.Rows[1].Stock != 3 || products.Rows[2].Stock != 1 {
+		t.Errorf("stock = %d, %d; want only the restocked item back", products.Rows[1].Stock, products.Rows[2].Stock)
+	}
+
+	// The items cost 110000, with 5641 of shipping and 11000 of tax
+	tooMuch := int64(126642)
+	if _, err := s.Refund(ctx, ret.ID, refunds.Request{Amount: &tooMuch}); repotest.Code(err) != apperror.CodeRefundExceedsReturn {
+		t.Errorf("refunding more than the items cost: %v, want REFUND_EXCEEDS_RETURN", err)
+	}
+	ret, err = s.Refund(ctx, ret.ID, refunds.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Status != StatusRefunded || ret.RefundedAt == nil || ret.RefundAmount != 126641 {
+		t.Errorf("refunded return = %+v, want 126641 refunded", ret)
+	}
+	if got := orderRepo.Rows[1]; got.PaymentStatus != refunds.PaymentPartiallyRefunded || got.RefundedAmount != 126641 {
+		t.Errorf("order payment %q with %d refunded, want partially_refunded with 126641", got.PaymentStatus, got.RefundedAmount)
+	}
+	if _, err := s.Refund(ctx, ret.ID, refunds.Request{}); repotest.Code(err) != apperror.CodeInvalidTransition {
+		t.Errorf("refunding a return twice: %v, want INVALID_STATUS_TRANSITION", err)
+	}
+
+	// Returning the rest refunds the order in full, with what is left of
+	// shipping and tax
+	rest, err := s.Request(ctx, alice, 1, Request{Reason: "Tidak sesuai", Lines: []Line{{11, 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Approve(ctx, rest.ID, "")
+	s.Receive(ctx, rest.ID, nil)
+	rest, err = s.Refund(ctx, rest.ID, refunds.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest.RefundAmount != 97859 {
+		t.Errorf("refund = %d, want 85000 with 4359 of shipping and 8500 of tax", rest.RefundAmount)
+	}
+	if got := orderRepo.Rows[1]; got.PaymentStatus != refunds.PaymentRefunded || got.RefundedAmount != got.TotalAmount {
+		t.Errorf("order payment %q with %d refunded, want refunded in full", got.PaymentStatus, got.RefundedAmount)
+	}
+}
+
+func TestRefundNeedsPayment(t *testing.T) {
+	received := existingReturn(StatusReceived, models.ReturnItem{OrderItemID: 12, Quantity: 1})
+	s, orderRepo, _, _ := newTestService(received)
+	order := orderRepo.Rows[1]
+	order.PaymentStatus = "unpaid"
+	orderRepo.Rows[1] = order
+
+	if _, err := s.Refund(context.Background(), 1, refunds.Request{}); repotest.Code(err) != apperror.CodeRefundNotAllowed {
+		t.Errorf("refunding an unpaid order: %v, want REFUND_NOT_ALLOWED", err)
+	}
+}
+
+func TestGetForUser(t *testing.T) {
+	s, _, _, _ := newTestService(existingReturn(StatusRequested, models.ReturnItem{OrderItemID: 11, Quantity: 1}))
+	if _, err := s.GetForUser(context.Background(), alice, 1); err != nil {
+		t.Errorf("owner: %v", err)
+	}
+	if _, err := s.GetForUser(context.Background(), bob, 1); repotest.Code(err) != apperror.CodeReturnNotFound {
+		t.Errorf("another customer: %v, want RETURN_NOT_FOUND", err)
+	}
+}
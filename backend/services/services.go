@@ -0,0 +1,79 @@
+// Package services wires the business services to their repositories. The
+// services themselves live in the subpackages.
+package services
+
+import (
+	"context"
+
+	"ecommerce-backend/carriers"
+	"ecommerce-backend/config"
+	"ecommerce-backend/mail"
+	"ecommerce-backend/payments"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/bulk"
+	"ecommerce-backend/services/cart"
+	"ecommerce-backend/services/catalog"
+	"ecommerce-backend/services/checkout"
+	"ecommerce-backend/services/documents"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/services/refunds"
+	"ecommerce-backend/services/returns"
+	"ecommerce-backend/services/shipping"
+	"ecommerce-backend/services/tracking"
+	"ecommerce-backend/services/users"
+
+	"gorm.io/gorm"
+)
+
+// Services is everything the HTTP handlers depend on
+type Services struct {
+	Catalog   catalog.CatalogService
+	Cart      cart.CartService
+	Checkout  checkout.CheckoutService
+	Orders    orders.OrderService
+	Documents documents.DocumentService
+	Shipping  shipping.ShippingService
+	Returns   returns.ReturnService
+	Refunds   refunds.RefundService
+	Tracking  tracking.TrackingService
+	Bulk      bulk.BulkService
+	Users     users.UserService
+}
+
+// New builds the services on GORM repositories backed by db, configured by
+// cfg. Shipments are tracked with the carriers in trackers, refunds are paid
+// through provider, which may be nil when none is configured, and customer
+// emails are sent with mailer. Background jobs call onProductChanged for
+// each product whose stock they move, to invalidate caches.
+func New(db *gorm.DB, cfg *config.Config, trackers carriers.Registry, provider payments.Provider, mailer mail.Sender,
+	onProductChanged func(ctx context.Context, productID uint)) Services {
+	tx := repository.NewTransactor(db)
+	products := repository.NewProductRepository(db)
+	categories := repository.NewCategoryRepository(db)
+	carts := repository.NewCartRepository(db)
+	orderRepo := repository.NewOrderRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	addresses := repository.NewAddressRepository(db)
+	shipments := repository.NewShipmentRepository(db)
+	returnRepo := repository.NewReturnRepository(db)
+	refundRepo := repository.NewRefundRepository(db)
+	jobs := repository.NewJobRepository(db)
+
+	shippingService := shipping.NewService(tx, orderRepo, shipments)
+	refundService := refunds.NewService(tx, orderRepo, refundRepo, provider)
+	orderService := orders.NewService(tx, orderRepo, products, userRepo)
+
+	return Services{
+		Catalog:   catalog.NewService(products, categories),
+		Cart:      cart.NewService(tx, carts, products),
+		Checkout:  checkout.NewService(tx, carts, products, orderRepo, addresses, userRepo, mailer, cfg.Store),
+		Orders:    orderService,
+		Documents: documents.NewService(orderRepo, cfg.Store),
+		Shipping:  shippingService,
+		Returns:   returns.NewService(tx, orderRepo, returnRepo, products, refundService, cfg.Returns.Window, cfg.Returns.MaxPhotos),
+		Refunds:   refundService,
+		Tracking:  tracking.NewService(tx, shipments, shippingService, trackers, cfg.Carriers.PollInterval, cfg.Carriers.PollBatch),
+		Bulk:      bulk.NewService(tx, jobs, orderRepo, orderService, shippingService, cfg.Store, cfg.Jobs.MaxOrders, onProductChanged),
+		Users:     users.NewService(userRepo, mailer, cfg.Store),
+	}
+}
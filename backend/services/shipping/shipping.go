@@ -0,0 +1,301 @@
+// Package shipping records the shipments an order leaves in and derives the
+// order's fulfilment status from them: an order is partially shipped until
+// its shipments carry every item, shipped once they do, and delivered when
+// every shipment has arrived
+package shipping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/carriers"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/orders"
+)
+
+// Line is a quantity of one order item to put in a shipment
+type Line struct {
+	OrderItemID uint
+	Quantity    int
+}
+
+// Request describes a new shipment. A zero ShippedAt means now; no Lines
+// means everything not yet shipped.
+type Request struct {
+	Carrier        string
+	Service        string
+	TrackingNumber string
+	ShippedAt      time.Time
+	Lines          []Line
+}
+
+// Result is a shipment together with the order status it led to
+type Result struct {
+	Shipment    models.Shipment `json:"shipment"`
+	OrderStatus string          `json:"order_status"`
+}
+
+// ShippingService creates shipments and records their delivery
+type ShippingService interface {
+	// List returns the order's shipments, oldest first
+	List(ctx context.Context, orderID uint) ([]models.Shipment, error)
+	// Create ships the requested quantities of the order's items and
+	// updates the order's status
+	Create(ctx context.Context, orderID uint, r Request) (Result, error)
+	// MarkDelivered records that the shipment arrived at at, or now when at
+	// is zero, and updates the order's status. Marking a delivered shipment
+	// again changes nothing.
+	MarkDelivered(ctx context.Context, orderID, shipmentID uint, at time.Time) (Result, error)
+}
+
+type service struct {
+	tx        repository.Transactor
+	orders    repository.OrderRepository
+	shipments repository.ShipmentRepository
+
+	now func() time.Time
+}
+
+// NewService returns a ShippingService backed by the given repositories
+func NewService(tx repository.Transactor, orders repository.OrderRepository, shipments repository.ShipmentRepository) ShippingService {
+	return &service{tx: tx, orders: orders, shipments: shipments, now: time.Now}
+}
+
+// Shipped totals the quantity of each order item, by ID, across shipments
+func Shipped(shipments []models.Shipment) map[uint]int {
+	shipped := make(map[uint]int)
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			shipped[item.OrderItemID] += item.Quantity
+		}
+	}
+	return shipped
+}
+
+// Status derives the status of an order with the given items from its
+// shipments. Without shipments the order keeps its current status.
+func Status(current string, items []models.OrderItem, shipments []models.Shipment) string {
+	if len(shipments) == 0 {
+		return current
+	}
+	shipped := Shipped(shipments)
+	for _, item := range items {
+		if shipped[item.ID] < item.Quantity {
+			return orders.StatusPartiallyShipped
+		}
+	}
+	for _, shipment := range shipments {
+		if shipment.DeliveredAt == nil {
+			return orders.StatusShipped
+		}
+	}
+	return orders.StatusDelivered
+}
+
+func (s *service) List(ctx context.Context, orderID uint) ([]models.Shipment, error) {
+	if _, err := s.orders.Get(ctx, orderID); err != nil {
+		return nil, orderError(err)
+	}
+	shipments, err := s.shipments.ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, apperror.Internal("Failed to get shipments", err)
+	}
+	return shipments, nil
+}
+
+func (s *service) Create(ctx context.Context, orderID uint, r Request) (Result, error) {
+	// Shipments are tracked and matched by the lowercase carrier code
+	carrier, ok := carriers.Normalize(r.Carrier)
+	if !ok {
+		return Result{}, UnknownCarrier(r.Carrier)
+	}
+
+	var result Result
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		order, existing, err := s.lockOrder(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.Status == orders.StatusCancelled {
+			return apperror.Conflict(apperror.CodeInvalidTransition, "A cancelled order cannot be shipped").
+				With("order_status", order.Status)
+		}
+		if order.Status == orders.StatusDraft {
+			return apperror.Conflict(apperror.CodeInvalidTransition, "A draft order has to be placed before it ships").
+				With("order_status", order.Status)
+		}
+
+		items, err := pack(order.OrderItems, Shipped(existing), r.Lines)
+		if err != nil {
+			return err
+		}
+
+		shippedAt := r.ShippedAt
+		if shippedAt.IsZero() {
+			shippedAt = s.now()
+		}
+		shipment := models.Shipment{
+			OrderID:        order.ID,
+			Carrier:        carrier,
+			Service:        strings.TrimSpace(r.Service),
+			TrackingNumber: strings.TrimSpace(r.TrackingNumber),
+			ShippedAt:      shippedAt,
+			Items:          items,
+		}
+		if err := s.shipments.Create(ctx, &shipment); err != nil {
+			return apperror.Internal("Failed to create shipment", err)
+		}
+
+		// The order's own tracking number shows the latest parcel
+		status := Status(order.Status, order.OrderItems, append(existing, shipment))
+		changes := map[string]interface{}{"status": status}
+		if shipment.TrackingNumber != "" {
+			changes["tracking_number"] = shipment.TrackingNumber
+		}
+		if err := s.orders.Patch(ctx, order.ID, changes); err != nil {
+			return apperror.Internal("Failed to update order status", err)
+		}
+
+		result = Result{Shipment: shipment, OrderStatus: status}
+		return nil
+	})
+	return result, err
+}
+
+func (s *service) MarkDelivered(ctx context.Context, orderID, shipmentID uint, at time.Time) (Result, error) {
+	var result Result
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		order, existing, err := s.lockOrder(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		shipment, err := s.shipments.Get(ctx, order.ID, shipmentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperror.NotFound(apperror.CodeShipmentNotFound, "Shipment not found")
+		}
+		if err != nil {
+			return apperror.Internal("Failed to get shipment", err)
+		}
+		if shipment.DeliveredAt != nil {
+			result = Result{Shipment: shipment, OrderStatus: order.Status}
+			return nil
+		}
+
+		if at.IsZero() {
+			at = s.now()
+		}
+		if at.Before(shipment.ShippedAt) {
+			return apperror.BadRequest(apperror.CodeInvalidRequest, "A shipment cannot be delivered before it was shipped").
+				With("shipped_at", shipment.ShippedAt)
+		}
+		if _, err := s.shipments.MarkDelivered(ctx, shipment.ID, at); err != nil {
+			return apperror.Internal("Failed to mark shipment delivered", err)
+		}
+		shipment.DeliveredAt = &at
+
+		for i := range existing {
+			if existing[i].ID == shipment.ID {
+				existing[i] = shipment
+			}
+		}
+		status := Status(order.Status, order.OrderItems, existing)
+		if status != order.Status {
+			if err := s.orders.Patch(ctx, order.ID, map[string]interface{}{"status": status}); err != nil {
+				return apperror.Internal("Failed to update order status", err)
+			}
+		}
+
+		result = Result{Shipment: shipment, OrderStatus: status}
+		return nil
+	})
+	return result, err
+}
+
+// lockOrder locks the order for the rest of the transaction and returns it
+// with its shipments so far
+func (s *service) lockOrder(ctx context.Context, orderID uint) (models.Order, []models.Shipment, error) {
+	if err := s.orders.Lock(ctx, orderID); err != nil {
+		return models.Order{}, nil, orderError(err)
+	}
+	order, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		return models.Order{}, nil, orderError(err)
+	}
+	shipments, err := s.shipments.ListByOrder(ctx, orderID)
+	if err != nil {
+		return models.Order{}, nil, apperror.Internal("Failed to get shipments", err)
+	}
+	return order, shipments, nil
+}
+
+// pack turns the requested lines into shipment items, checking that each
+// names an item of the order and stays within what is left to ship. No
+// lines means everything left.
+func pack(items []models.OrderItem, shipped map[uint]int, lines []Line) ([]models.ShipmentItem, error) {
+	remaining := make(map[uint]int, len(items))
+	for _, item := range items {
+		remaining[item.ID] = item.Quantity - shipped[item.ID]
+	}
+
+	if len(lines) == 0 {
+		for _, item := range items {
+			if remaining[item.ID] > 0 {
+				lines = append(lines, Line{OrderItemID: item.ID, Quantity: remaining[item.ID]})
+			}
+		}
+		if len(lines) == 0 {
+			return nil, apperror.Conflict(apperror.CodeShipmentExceedsOrder, "Every item of this order has already been shipped")
+		}
+	}
+
+	// Merge repeated lines so each item is checked against its total
+	quantities := make(map[uint]int, len(lines))
+	var order []uint
+	for _, line := range lines {
+		left, ok := remaining[line.OrderItemID]
+		if !ok {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest,
+				fmt.Sprintf("Order item %d is not part of this order", line.OrderItemID)).
+				With("order_item_id", line.OrderItemID)
+		}
+		if line.Quantity < 1 {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Quantities must be at least 1").
+				With("order_item_id", line.OrderItemID)
+		}
+		if _, seen := quantities[line.OrderItemID]; !seen {
+			order = append(order, line.OrderItemID)
+		}
+		quantities[line.OrderItemID] += line.Quantity
+		if quantities[line.OrderItemID] > left {
+			return nil, apperror.Conflict(apperror.CodeShipmentExceedsOrder,
+				fmt.Sprintf("Only %d of order item %d are left to ship", left, line.OrderItemID)).
+				With("order_item_id", line.OrderItemID).
+				With("remaining", left)
+		}
+	}
+
+	packed := make([]models.ShipmentItem, 0, len(order))
+	for _, id := range order {
+		packed = append(packed, models.ShipmentItem{OrderItemID: id, Quantity: quantities[id]})
+	}
+	return packed, nil
+}
+
+// UnknownCarrier reports a carrier code that is not one of carriers.Codes
+func UnknownCarrier(carrier string) error {
+	return apperror.BadRequest(apperror.CodeInvalidRequest,
+		"Unknown carrier; use one of "+strings.Join(carriers.Codes, ", ")).
+		With("carrier", carrier)
+}
+
+func orderError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperror.NotFound(apperror.CodeOrderNotFound, "Order not found")
+	}
+	return apperror.Internal("Failed to get order", err)
+}
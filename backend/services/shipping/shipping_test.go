@@ -0,0 +1,290 @@
+package shipping
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository/repotest"
+	"ecommerce-backend/services/orders"
+)
+
+var shippedAt = time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+// newTestService returns a service over one processing order with 3 of
+// item 11 and 1 of item 12, and the given shipments already made
+func newTestService(status string, shipments ...models.Shipment) (*service, *repotest.Orders, *repotest.Shipments) {
+	orderRepo := repotest.NewOrders(models.Order{
+		UserID:      1,
+		OrderNumber: "ORD-20240309-0001",
+		Status:      status,
+		OrderItems: []models.OrderItem{
+			{ID: 11, ProductID: 1, Quantity: 3},
+			{ID: 12, ProductID: 2, Quantity: 1},
+		},
+	})
+	shipmentRepo := repotest.NewShipments(shipments...)
+	s := NewService(repotest.Transactor{}, orderRepo, shipmentRepo).(*service)
+	s.now = func() time.Time { return shippedAt.Add(48 * time.Hour) }
+	return s, orderRepo, shipmentRepo
+}
+
+func shipment(delivered bool, items ...models.ShipmentItem) models.Shipment {
+	s := models.Shipment{OrderID: 1, Carrier: "jne", ShippedAt: shippedAt, Items: items}
+	if delivered {
+		at := shippedAt.Add(24 * time.Hour)
+		s.DeliveredAt = &at
+	}
+	return s
+}
+
+func TestStatus(t *testing.T) {
+	items := []models.OrderItem{{ID: 11, Quantity: 3}, {ID: 12, Quantity: 1}}
+	tests := []struct {
+		name      string
+		shipments []models.Shipment
+		want      string
+	}{
+		{name: "nothing shipped", want: orders.StatusProcessing},
+		{
+			name:      "some items",
+			shipments: []models.Shipment{shipment(false, models.ShipmentItem{OrderItemID: 12, Quantity: 1})},
+			want:      orders.StatusPartiallyShipped,
+		},
+		{
+			name:      "part of an item",
+			shipments: []models.Shipment{shipment(true, models.ShipmentItem{OrderItemID: 11, Quantity: 2}, models.ShipmentItem{OrderItemID: 12, Quantity: 1})},
+			want:      orders.StatusPartiallyShipped,
+		},
+		{
+			name: "everything, one still in transit",
+			shipments: []models.Shipment{
+				shipment(true, models.ShipmentItem{OrderItemID: 11, Quantity: 2}),
+				shipment(false, models.ShipmentItem{OrderItemID: 11, Quantity: 1}, models.ShipmentItem{OrderItemID: 12, Quantity: 1}),
+			},
+			want: orders.StatusShipped,
+		},
+		{
+			name: "everything delivered",
+			shipments: []models.Shipment{
+				shipment(true, models.ShipmentItem{OrderItemID: 11, Quantity: 2}),
+				shipment(true, models.ShipmentItem{OrderItemID: 11, Quantity: 1}, models.ShipmentItem{OrderItemID: 12, Quantity: 1}),
+			},
+			want: orders.StatusDelivered,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Status(orders.StatusProcessing, items, tt.shipments); got != tt.want {
+				t.Errorf("Status() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		existing   []models.Shipment
+		lines      []Line
+		wantCode   apperror.Code
+		wantStatus string
+		wantItems  map[uint]int
+	}{
+		{
+			name:       "everything",
+			status:     orders.StatusProcessing,
+			wantStatus: orders.StatusShipped,
+			wantItems:  map[uint]int{11: 3, 12: 1},
+		},
+		{
+			name:       "part of the order",
+			status:     orders.StatusPending,
+			lines:      []Line{{OrderItemID: 11, Quantity: 1}, {OrderItemID: 11, Quantity: 1}},
+			wantStatus: orders.StatusPartiallyShipped,
+			wantItems:  map[uint]int{11: 2},
+		},
+		{
+			name:       "the rest",
+			status:     orders.StatusPartiallyShipped,
+			existing:   []models.Shipment{shipment(false, models.ShipmentItem{OrderItemID: 11, Quantity: 2})},
+			wantStatus: orders.StatusShipped,
+			wantItems:  map[uint]int{11: 1, 12: 1},
+		},
+		{
+			name:     "more than left",
+			status:   orders.StatusPartiallyShipped,
+			existing: []models.Shipment{shipment(false, models.ShipmentItem{OrderItemID: 11, Quantity: 2})},
+			lines:    []Line{{OrderItemID: 11, Quantity: 2}},
+			wantCode: apperror.CodeShipmentExceedsOrder,
+		},
+		{
+			name:     "nothing left",
+			status:   orders.StatusShipped,
+			existing: []models.Shipment{shipment(false, models.ShipmentItem{OrderItemID: 11, Quantity: 3}, models.ShipmentItem{OrderItemID: 12, Quantity: 1})},
+			wantCode: apperror.CodeShipmentExceedsOrder,
+		},
+		{
+			name:     "item of another order",
+			status:   orders.StatusProcessing,
+			lines:    []Line{{OrderItemID: 99, Quantity: 1}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "zero quantity",
+			status:   orders.StatusProcessing,
+			lines:    []Line{{OrderItemID: 11, Quantity: 0}},
+			wantCode: apperror.CodeInvalidRequest,
+		},
+		{
+			name:     "cancelled order",
+			status:   orders.StatusCancelled,
+			wantCode: apperror.CodeInvalidTransition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, orderRepo, shipmentRepo := newTestService(tt.status, tt.existing...)
+
+			result, err := s.Create(context.Background(), 1, Request{
+				Carrier:        " JNE ",
+				Service:        "REG",
+				TrackingNumber: "JNE0012345678",
+				Lines:          tt.lines,
+			})
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != "" {
+				if len(shipmentRepo.Rows) != len(tt.existing) {
+					t.Error("a rejected shipment was stored")
+				}
+				return
+			}
+
+			if result.OrderStatus != tt.wantStatus || orderRepo.Rows[1].Status != tt.wantStatus {
+				t.Errorf("order status = %q (stored %q), want %q", result.OrderStatus, orderRepo.Rows[1].Status, tt.wantStatus)
+			}
+			if orderRepo.Rows[1].TrackingNumber != "JNE0012345678" {
+				t.Errorf("order tracking number = %q, want the new shipment's", orderRepo.Rows[1].TrackingNumber)
+			}
+			got := Shipped([]models.Shipment{result.Shipment})
+			if len(got) != len(tt.wantItems) {
+				t.Fatalf("shipped %v, want %v", got, tt.wantItems)
+			}
+			for id, qty := range tt.wantItems {
+				if got[id] != qty {
+					t.Errorf("item %d: shipped %d, want %d", id, got[id], qty)
+				}
+			}
+			if result.Shipment.Carrier != "jne" || !result.Shipment.ShippedAt.Equal(s.now()) {
+				t.Errorf("shipment = carrier %q shipped %v, want jne at now", result.Shipment.Carrier, result.Shipment.ShippedAt)
+			}
+		})
+	}
+}
+
+func TestCreateUnknownOrder(t *testing.T) {
+	s, _, _ := newTestService(orders.StatusProcessing)
+	_, err := s.Create(context.Background(), 99, Request{Carrier: "jne"})
+	if code := repotest.Code(err); code != apperror.CodeOrderNotFound {
+		t.Errorf("error code = %q, want %q", code, apperror.CodeOrderNotFound)
+	}
+}
+
+func TestCreateUnknownCarrier(t *testing.T) {
+	s, _, shipmentRepo := newTestService(orders.StatusProcessing)
+	_, err := s.Create(context.Background(), 1, Request{Carrier: "kurir"})
+	if code := repotest.Code(err); code != apperror.CodeInvalidRequest || len(shipmentRepo.Rows) != 0 {
+		t.Errorf("error code = %q with %d shipments, want %q and none stored", code, len(shipmentRepo.Rows), apperror.CodeInvalidRequest)
+	}
+}
+
+func TestMarkDelivered(t *testing.T) {
+	everything := []models.Shipment{
+		shipment(false, models.ShipmentItem{OrderItemID: 11, Quantity: 3}),
+		shipment(true, models.ShipmentItem{OrderItemID: 12, Quantity: 1}),
+	}
+	tests := []struct {
+		name       string
+		status     string
+		existing   []models.Shipment
+		orderID    uint
+		shipmentID uint
+		at         time.Time
+		wantCode   apperror.Code
+		wantStatus string
+	}{
+		{
+			name:       "last parcel arrives",
+			status:     orders.StatusShipped,
+			existing:   everything,
+			orderID:    1,
+			shipmentID: 1,
+			wantStatus: orders.StatusDelivered,
+		},
+		{
+			name:       "parcel of a partly shipped order",
+			status:     orders.StatusPartiallyShipped,
+			existing:   everything[:1],
+			orderID:    1,
+			shipmentID: 1,
+			wantStatus: orders.StatusPartiallyShipped,
+		},
+		{
+			name:       "already delivered",
+			status:     orders.StatusShipped,
+			existing:   everything,
+			orderID:    1,
+			shipmentID: 2,
+			wantStatus: orders.StatusShipped,
+		},
+		{
+			name:       "before it left",
+			status:     orders.StatusShipped,
+			existing:   everything,
+			orderID:    1,
+			shipmentID: 1,
+			at:         shippedAt.Add(-time.Hour),
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "unknown shipment",
+			status:     orders.StatusShipped,
+			existing:   everything,
+			orderID:    1,
+			shipmentID: 9,
+			wantCode:   apperror.CodeShipmentNotFound,
+		},
+		{
+			name:       "unknown order",
+			status:     orders.StatusShipped,
+			existing:   everything,
+			orderID:    9,
+			shipmentID: 1,
+			wantCode:   apperror.CodeOrderNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, orderRepo, shipmentRepo := newTestService(tt.status, tt.existing...)
+
+			result, err := s.MarkDelivered(context.Background(), tt.orderID, tt.shipmentID, tt.at)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+			if result.OrderStatus != tt.wantStatus || orderRepo.Rows[1].Status != tt.wantStatus {
+				t.Errorf("order status = %q (stored %q), want %q", result.OrderStatus, orderRepo.Rows[1].Status, tt.wantStatus)
+			}
+			if shipmentRepo.Rows[tt.shipmentID].DeliveredAt == nil || result.Shipment.DeliveredAt == nil {
+				t.Error("shipment is not marked delivered")
+			}
+		})
+	}
+}
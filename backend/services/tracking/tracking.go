@@ -0,0 +1,176 @@
+// Package tracking keeps shipments' tracking events up to date. It polls the
+// carriers of open shipments and accepts events carriers push, stores each
+// event once, and marks a shipment delivered through the shipping service
+// when its carrier reports the delivery, so the order status follows.
+package tracking
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/carriers"
+	"ecommerce-backend/metrics"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services/shipping"
+)
+
+// TrackingService records carrier tracking events for shipments
+type TrackingService interface {
+	// Poll tracks up to one batch of open shipments that were not tracked
+	// within the poll interval and returns how many it tracked. A carrier
+	// failing for one shipment does not stop the rest.
+	Poll(ctx context.Context) (int, error)
+	// Receive records events a carrier pushed for one of its tracking
+	// numbers and returns how many were new
+	Receive(ctx context.Context, carrier, trackingNumber string, events []carriers.Event) (int, error)
+}
+
+type service struct {
+	tx        repository.Transactor
+	shipments repository.ShipmentRepository
+	shipping  shipping.ShippingService
+	trackers  carriers.Registry
+
+	interval time.Duration
+	batch    int
+	now      func() time.Time
+}
+
+// NewService returns a TrackingService that polls the carriers in trackers,
+// tracking each open shipment at most once per interval and batch shipments
+// per poll
+func NewService(tx repository.Transactor, shipments repository.ShipmentRepository, shippingService shipping.ShippingService,
+	trackers carriers.Registry, interval time.Duration, batch int) TrackingService {
+	return &service{
+		tx:        tx,
+		shipments: shipments,
+		shipping:  shippingService,
+		trackers:  trackers,
+		interval:  interval,
+		batch:     batch,
+		now:       time.Now,
+	}
+}
+
+// Run polls every interval until ctx is done. It polls once straight away.
+func Run(ctx context.Context, s TrackingService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		tracked, err := s.Poll(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Shipment tracking poll failed", "error", err)
+		} else if tracked > 0 {
+			slog.InfoContext(ctx, "Tracked shipments", "count", tracked)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *service) Poll(ctx context.Context) (int, error) {
+	now := s.now()
+	open, err := s.shipments.ListOpen(ctx, s.trackers.Codes(), now.Add(-s.interval), s.batch)
+	if err != nil {
+		return 0, apperror.Internal("Failed to list open shipments", err)
+	}
+
+	tracked := 0
+	for _, shipment := range open {
+		if ctx.Err() != nil {
+			break
+		}
+		events, err := s.trackers[shipment.Carrier].Track(ctx, shipment.TrackingNumber)
+
+		// A failed shipment still counts as tracked so it waits its turn
+		// instead of blocking the front of the queue
+		if err := s.shipments.MarkTracked(ctx, shipment.ID, now); err != nil {
+			return tracked, apperror.Internal("Failed to mark shipment tracked", err)
+		}
+		switch {
+		case errors.Is(err, carriers.ErrUnknownTrackingNumber):
+			metrics.CarrierTracking.WithLabelValues(shipment.Carrier, "unknown").Inc()
+			slog.WarnContext(ctx, "Carrier does not know tracking number",
+				"carrier", shipment.Carrier, "tracking_number", shipment.TrackingNumber, "shipment_id", shipment.ID)
+			continue
+		case err != nil:
+			metrics.CarrierTracking.WithLabelValues(shipment.Carrier, "error").Inc()
+			slog.WarnContext(ctx, "Failed to track shipment",
+				"carrier", shipment.Carrier, "shipment_id", shipment.ID, "error", err)
+			continue
+		}
+		metrics.CarrierTracking.WithLabelValues(shipment.Carrier, "ok").Inc()
+
+		if _, err := s.record(ctx, shipment, events); err != nil {
+			slog.ErrorContext(ctx, "Failed to record tracking events", "shipment_id", shipment.ID, "error", err)
+			continue
+		}
+		tracked++
+	}
+	return tracked, nil
+}
+
+func (s *service) Receive(ctx context.Context, carrier, trackingNumber string, events []carriers.Event) (int, error) {
+	code, _ := carriers.Normalize(carrier)
+	shipment, err := s.shipments.FindByTracking(ctx, code, strings.TrimSpace(trackingNumber))
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, apperror.NotFound(apperror.CodeShipmentNotFound, "No shipment has this tracking number").
+			With("carrier", carrier).
+			With("tracking_number", trackingNumber)
+	}
+	if err != nil {
+		return 0, apperror.Internal("Failed to find shipment", err)
+	}
+	return s.record(ctx, shipment, events)
+}
+
+// record stores the shipment's new events and, on the first delivered event,
+// marks the shipment delivered at that time
+func (s *service) record(ctx context.Context, shipment models.Shipment, events []carriers.Event) (int, error) {
+	rows := make([]models.TrackingEvent, 0, len(events))
+	var delivered *time.Time
+	for _, e := range events {
+		rows = append(rows, models.TrackingEvent{
+			ShipmentID:  shipment.ID,
+			Status:      e.Status,
+			Description: e.Description,
+			Location:    e.Location,
+			OccurredAt:  e.OccurredAt,
+		})
+		if e.Status == carriers.StatusDelivered && (delivered == nil || e.OccurredAt.Before(*delivered)) {
+			at := e.OccurredAt
+			delivered = &at
+		}
+	}
+
+	var added int
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		n, err := s.shipments.AddEvents(ctx, rows)
+		if err != nil {
+			return apperror.Internal("Failed to store tracking events", err)
+		}
+		added = n
+
+		if delivered == nil || shipment.DeliveredAt != nil {
+			return nil
+		}
+		// Shipments recorded late can have shipped_at after the carrier's
+		// delivery scan; deliver no earlier than shipped
+		at := *delivered
+		if at.Before(shipment.ShippedAt) {
+			at = shipment.ShippedAt
+		}
+		_, err = s.shipping.MarkDelivered(ctx, shipment.OrderID, shipment.ID, at)
+		return err
+	})
+	return added, err
+}
@@ -0,0 +1,218 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/carriers"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository/repotest"
+	"ecommerce-backend/services/orders"
+	"ecommerce-backend/services/shipping"
+)
+
+var (
+	shippedAt = time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	now       = shippedAt.Add(48 * time.Hour)
+)
+
+// newTestService returns a service over one shipped order of 2 of item 11,
+// sent as shipment 1 with JNE, tracked by a fake JNE
+func newTestService(shipments ...models.Shipment) (*service, *carriers.Fake, *repotest.Orders, *repotest.Shipments) {
+	orderRepo := repotest.NewOrders(models.Order{
+		UserID:      1,
+		OrderNumber: "ORD-20240309-0001",
+		Status:      orders.StatusShipped,
+		OrderItems:  []models.OrderItem{{ID: 11, ProductID: 1, Quantity: 2}},
+	})
+	if len(shipments) == 0 {
+		shipments = []models.Shipment{parcel("jne", "JNE0012345678")}
+	}
+	shipmentRepo := repotest.NewShipments(shipments...)
+	jne := carriers.NewFake("jne")
+	tx := repotest.Transactor{}
+	s := NewService(tx, shipmentRepo, shipping.NewService(tx, orderRepo, shipmentRepo),
+		carriers.NewRegistry(jne), time.Hour, 10).(*service)
+	s.now = func() time.Time { return now }
+	return s, jne, orderRepo, shipmentRepo
+}
+
+func parcel(carrier, trackingNumber string) models.Shipment {
+	return models.Shipment{
+		OrderID:        1,
+		Carrier:        carrier,
+		TrackingNumber: trackingNumber,
+		ShippedAt:      shippedAt,
+		Items:          []models.ShipmentItem{{OrderItemID: 11, Quantity: 2}},
+	}
+}
+
+func event(status string, after time.Duration) carriers.Event {
+	return carriers.Event{Status: status, Description: status, Location: "JAKARTA", OccurredAt: shippedAt.Add(after)}
+}
+
+func TestPollRecordsEventsAndDelivers(t *testing.T) {
+	s, jne, orderRepo, shipmentRepo := newTestService()
+	jne.Set("JNE0012345678",
+		event(carriers.StatusPickedUp, time.Hour),
+		event(carriers.StatusInTransit, 5*time.Hour),
+	)
+
+	tracked, err := s.Poll(context.Background())
+	if err != nil || tracked != 1 {
+		t.Fatalf("Poll() = %d, %v; want 1 tracked", tracked, err)
+	}
+	if got := len(shipmentRepo.Rows[1].Events); got != 2 {
+		t.Fatalf("stored %d events, want 2", got)
+	}
+	if orderRepo.Rows[1].Status != orders.StatusShipped {
+		t.Errorf("order status = %q before delivery, want shipped", orderRepo.Rows[1].Status)
+	}
+
+	// Tracked within the interval, so the next poll leaves it alone
+	if tracked, _ := s.Poll(context.Background()); tracked != 0 {
+		t.Errorf("second poll tracked %d shipments, want 0", tracked)
+	}
+
+	// The whole history comes back with the delivery appended
+	jne.Set("JNE0012345678",
+		event(carriers.StatusPickedUp, time.Hour),
+		event(carriers.StatusInTransit, 5*time.Hour),
+		event(carriers.StatusDelivered, 26*time.Hour),
+	)
+	s.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if tracked, err := s.Poll(context.Background()); err != nil || tracked != 1 {
+		t.Fatalf("third poll = %d, %v; want 1 tracked", tracked, err)
+	}
+
+	shipment := shipmentRepo.Rows[1]
+	if len(shipment.Events) != 3 {
+		t.Errorf("stored %d events, want 3 without duplicates", len(shipment.Events))
+	}
+	if shipment.DeliveredAt == nil || !shipment.DeliveredAt.Equal(shippedAt.Add(26*time.Hour)) {
+		t.Errorf("delivered at %v, want the delivered event's time", shipment.DeliveredAt)
+	}
+	if orderRepo.Rows[1].Status != orders.StatusDelivered {
+		t.Errorf("order status = %q, want delivered", orderRepo.Rows[1].Status)
+	}
+
+	// Delivered shipments are not polled again
+	s.now = func() time.Time { return now.Add(24 * time.Hour) }
+	if tracked, _ := s.Poll(context.Background()); tracked != 0 || jne.Calls("JNE0012345678") != 2 {
+		t.Errorf("polled a delivered shipment")
+	}
+}
+
+func TestPollSkipsWhatItCannotTrack(t *testing.T) {
+	s, jne, _, shipmentRepo := newTestService(
+		parcel("jne", "JNE-FAILS"),
+		parcel("sicepat", "SCP000987654"),
+		parcel("jne", ""),
+		parcel("jne", "JNE-UNKNOWN"),
+		parcel("jne", "JNE-OK"),
+	)
+	jne.Set("JNE-OK", event(carriers.StatusInTransit, time.Hour))
+	// Only JNE-FAILS errors; the fake errs for every call, so track it
+	// alone first
+	jne.Err = errors.New("connection reset")
+	s.batch = 1
+	if tracked, err := s.Poll(context.Background()); err != nil || tracked != 0 {
+		t.Fatalf("failing poll = %d, %v; want 0 tracked and no error", tracked, err)
+	}
+	if shipmentRepo.Rows[1].TrackedAt == nil {
+		t.Error("a failed shipment was not marked tracked, so it would block the queue")
+	}
+
+	jne.Err = nil
+	s.batch = 10
+	tracked, err := s.Poll(context.Background())
+	if err != nil || tracked != 1 {
+		t.Fatalf("Poll() = %d, %v; want only JNE-OK tracked", tracked, err)
+	}
+	if jne.Calls("JNE-UNKNOWN") != 1 || shipmentRepo.Rows[4].TrackedAt == nil {
+		t.Error("unknown tracking number was not asked about once and marked tracked")
+	}
+	if shipmentRepo.Rows[2].TrackedAt != nil || shipmentRepo.Rows[3].TrackedAt != nil {
+		t.Error("tracked a shipment of an unconfigured carrier or without a tracking number")
+	}
+}
+
+func TestReceive(t *testing.T) {
+	tests := []struct {
+		name          string
+		carrier       string
+		tracking      string
+		events        []carriers.Event
+		wantCode      apperror.Code
+		wantRecorded  int
+		wantDelivered time.Time
+	}{
+		{
+			name:         "progress",
+			carrier:      "jne",
+			tracking:     "JNE0012345678",
+			events:       []carriers.Event{event(carriers.StatusInTransit, time.Hour), event(carriers.StatusInTransit, time.Hour)},
+			wantRecorded: 1,
+		},
+		{
+			name:          "delivery",
+			carrier:       "JNE",
+			tracking:      " JNE0012345678 ",
+			events:        []carriers.Event{event(carriers.StatusDelivered, 30*time.Hour)},
+			wantRecorded:  1,
+			wantDelivered: shippedAt.Add(30 * time.Hour),
+		},
+		{
+			name:          "delivery scanned before the shipment was recorded",
+			carrier:       "jne",
+			tracking:      "JNE0012345678",
+			events:        []carriers.Event{event(carriers.StatusDelivered, -time.Hour)},
+			wantRecorded:  1,
+			wantDelivered: shippedAt,
+		},
+		{
+			name:     "unknown tracking number",
+			carrier:  "jne",
+			tracking: "JNE9999999999",
+			events:   []carriers.Event{event(carriers.StatusInTransit, time.Hour)},
+			wantCode: apperror.CodeShipmentNotFound,
+		},
+		{
+			name:     "another carrier's number",
+			carrier:  "sicepat",
+			tracking: "JNE0012345678",
+			events:   []carriers.Event{event(carriers.StatusInTransit, time.Hour)},
+			wantCode: apperror.CodeShipmentNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, orderRepo, shipmentRepo := newTestService()
+
+			recorded, err := s.Receive(context.Background(), tt.carrier, tt.tracking, tt.events)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if recorded != tt.wantRecorded {
+				t.Errorf("recorded %d events, want %d", recorded, tt.wantRecorded)
+			}
+
+			shipment := shipmentRepo.Rows[1]
+			if tt.wantDelivered.IsZero() {
+				if shipment.DeliveredAt != nil {
+					t.Errorf("shipment delivered at %v, want not delivered", shipment.DeliveredAt)
+				}
+				return
+			}
+			if shipment.DeliveredAt == nil || !shipment.DeliveredAt.Equal(tt.wantDelivered) {
+				t.Errorf("delivered at %v, want %v", shipment.DeliveredAt, tt.wantDelivered)
+			}
+			if orderRepo.Rows[1].Status != orders.StatusDelivered {
+				t.Errorf("order status = %q, want delivered", orderRepo.Rows[1].Status)
+			}
+		})
+	}
+}
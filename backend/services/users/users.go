@@ -0,0 +1,342 @@
+// Package users manages customer and admin accounts
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/mail"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Roles a user can have
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// ClaimTTL is how long the link a guest is emailed to register with works
+const ClaimTTL = 24 * time.Hour
+
+// Account holds the details for a new account
+type Account struct {
+	FirstName string
+	LastName  string
+	Email     string
+	Password  string
+	Phone     string
+	Role      string
+	IsActive  bool
+}
+
+// Query selects a page of users for the admin list
+type Query struct {
+	Page   repository.Page
+	Filter repository.UserFilter
+}
+
+// UserService signs users up and in, and lets admins manage accounts.
+// Returned users never carry their password hash.
+type UserService interface {
+	// Register creates an active customer account. The email of a guest who
+	// checked out stays taken until the guest proves they own it through
+	// RequestClaim and Claim.
+	Register(ctx context.Context, a Account) (models.User, error)
+	// RequestClaim emails the guest with this email a single-use link to
+	// register with. It does nothing for other emails, so that it does not
+	// tell who has ordered.
+	RequestClaim(ctx context.Context, email string) error
+	// Claim turns the guest whose claim link carries token into an account
+	// with a's names, phone and password, keeping their email and orders
+	Claim(ctx context.Context, token string, a Account) (models.User, error)
+	// Authenticate returns the active user with the given credentials
+	Authenticate(ctx context.Context, email, password string) (models.User, error)
+
+	List(ctx context.Context, q Query) ([]models.User, int64, error)
+	Get(ctx context.Context, id uint) (models.User, error)
+	Create(ctx context.Context, a Account) (models.User, error)
+	// Update applies changes, and a new password unless it is empty, if the
+	// user is still at version expected. It returns the user as they are
+	// afterwards, and false when someone else changed them first.
+	Update(ctx context.Context, id, expected uint, changes map[string]interface{}, password string) (models.User, bool, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+type service struct {
+	users  repository.UserRepository
+	mailer mail.Sender
+	store  config.StoreConfig
+	// cost is the bcrypt work factor for new password hashes
+	cost int
+	now  func() time.Time
+}
+
+// NewService returns a UserService backed by users, which emails guests'
+// claim links to store's storefront with mailer
+func NewService(users repository.UserRepository, mailer mail.Sender, store config.StoreConfig) UserService {
+	return &service{users: users, mailer: mailer, store: store, cost: bcrypt.DefaultCost, now: time.Now}
+}
+
+// NormalizeEmail trims and lowercases email, so that an address typed in
+// different case belongs to one customer
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (s *service) Register(ctx context.Context, a Account) (models.User, error) {
+	a.Email = NormalizeEmail(a.Email)
+	existing, err := s.users.GetByEmail(ctx, a.Email)
+	switch {
+	case err == nil && existing.IsGuest:
+		// Anyone can type a guest's email, so only the emailed link claims it
+		return models.User{}, apperror.Conflict(apperror.CodeEmailTaken,
+			"This email has guest orders; request a link by email to create your account with them").
+			With("guest", true)
+	case err == nil:
+		return models.User{}, apperror.Conflict(apperror.CodeEmailTaken, "Email already registered")
+	case !errors.Is(err, repository.ErrNotFound):
+		return models.User{}, apperror.Internal("Failed to check email", err)
+	}
+	a.Role = RoleUser
+	a.IsActive = true
+	return s.Create(ctx, a)
+}
+
+func (s *service) RequestClaim(ctx context.Context, email string) error {
+	guest, err := s.users.GetByEmail(ctx, NormalizeEmail(email))
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !guest.IsGuest) {
+		return nil
+	}
+	if err != nil {
+		return apperror.Internal("Failed to look up customer", err)
+	}
+
+	token, hash, err := newClaimToken()
+	if err != nil {
+		return apperror.Internal("Failed to create claim token", err)
+	}
+	// A new link replaces any earlier one
+	expires := s.now().Add(ClaimTTL)
+	updated, err := s.users.Update(ctx, guest.ID, guest.Version, map[string]interface{}{
+		"claim_token_hash": &hash,
+		"claim_expires_at": &expires,
+	})
+	if err != nil {
+		return apperror.Internal("Failed to store claim token", err)
+	}
+	if !updated {
+		return apperror.Conflict(apperror.CodeVersionConflict, "Account changed meanwhile; please try again")
+	}
+
+	// Failing to send is only logged, like for any other email, so the
+	// answer does not depend on whether the email has guest orders
+	if err := s.mailer.Send(ctx, s.claimMessage(guest.Email, token)); err != nil {
+		slog.ErrorContext(ctx, "Failed to email guest claim link", "user_id", guest.ID, "error", err)
+	}
+	return nil
+}
+
+func (s *service) Claim(ctx context.Context, token string, a Account) (models.User, error) {
+	invalid := apperror.BadRequest(apperror.CodeInvalidToken, "This link is invalid or has expired; please request a new one")
+	if token == "" {
+		return models.User{}, invalid
+	}
+	guest, err := s.users.GetByClaimToken(ctx, hashClaimToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.User{}, invalid
+	}
+	if err != nil {
+		return models.User{}, apperror.Internal("Failed to look up claim token", err)
+	}
+	if guest.ClaimExpiresAt == nil || !s.now().Before(*guest.ClaimExpiresAt) {
+		return models.User{}, invalid
+	}
+
+	// The orders they placed as a guest are already theirs, so they come
+	// with the account. Clearing the token makes the link single-use.
+	user, updated, err := s.Update(ctx, guest.ID, guest.Version, map[string]interface{}{
+		"first_name":       a.FirstName,
+		"last_name":        a.LastName,
+		"phone":            a.Phone,
+		"role":             RoleUser,
+		"is_active":        true,
+		"is_guest":         false,
+		"claim_token_hash": nil,
+		"claim_expires_at": nil,
+	}, a.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	if !updated {
+		return models.User{}, apperror.Conflict(apperror.CodeVersionConflict, "Account changed while registering; please try again")
+	}
+	return user, nil
+}
+
+// claimMessage is the email with the link a guest registers with
+func (s *service) claimMessage(to, token string) mail.Message {
+	var b strings.Builder
+	b.WriteString("Someone, hopefully you, asked to create an account for the orders placed with this email address.\n\n")
+	fmt.Fprintf(&b, "Create your account here within %d hours:\n%s\n\n", int(ClaimTTL.Hours()), ClaimURL(s.store.URL, token))
+	b.WriteString("If it was not you, ignore this email and nothing will change.\n\n")
+	b.WriteString(s.store.Name + "\n")
+	return mail.Message{
+		To:      to,
+		Subject: "Create your account at " + s.store.Name,
+		Body:    b.String(),
+	}
+}
+
+// newClaimToken returns a random token for a guest's claim link, and the
+// hash stored in its place
+func newClaimToken() (token, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(secret)
+	return token, hashClaimToken(token), nil
+}
+
+func hashClaimToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClaimURL is the storefront page at base where a guest registers with token
+func ClaimURL(base, token string) string {
+	return strings.TrimRight(base, "/") + "/register/claim?token=" + url.QueryEscape(token)
+}
+
+func (s *service) Authenticate(ctx context.Context, email, password string) (models.User, error) {
+	user, err := s.users.GetByEmail(ctx, NormalizeEmail(email))
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.User{}, apperror.Unauthorized(apperror.CodeInvalidCredentials, "Invalid credentials")
+	}
+	if err != nil {
+		return models.User{}, apperror.Internal("Failed to look up user", err)
+	}
+	if user.IsGuest {
+		return models.User{}, apperror.Unauthorized(apperror.CodeInvalidCredentials, "Invalid credentials")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return models.User{}, apperror.Unauthorized(apperror.CodeInvalidCredentials, "Invalid credentials")
+	}
+	if !user.IsActive {
+		return models.User{}, apperror.Unauthorized(apperror.CodeAccountInactive, "Account is inactive")
+	}
+	user.Password = ""
+	return user, nil
+}
+
+func (s *service) List(ctx context.Context, q Query) ([]models.User, int64, error) {
+	users, total, err := s.users.List(ctx, q.Filter, q.Page)
+	if err != nil {
+		return nil, 0, apperror.Internal("Failed to fetch users", err)
+	}
+	return users, total, nil
+}
+
+func (s *service) Get(ctx context.Context, id uint) (models.User, error) {
+	user, err := s.users.Get(ctx, id)
+	if err != nil {
+		return models.User{}, userError(err, "Failed to fetch user")
+	}
+	user.Password = ""
+	return user, nil
+}
+
+func (s *service) Create(ctx context.Context, a Account) (models.User, error) {
+	hash, err := s.hash(a.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	user := models.User{
+		FirstName: a.FirstName,
+		LastName:  a.LastName,
+		Email:     NormalizeEmail(a.Email),
+		Password:  hash,
+		Phone:     a.Phone,
+		Role:      a.Role,
+		IsActive:  a.IsActive,
+	}
+	if err := s.users.Create(ctx, &user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return models.User{}, apperror.Conflict(apperror.CodeEmailTaken, "User with this email already exists")
+		}
+		return models.User{}, apperror.Internal("Failed to create user", err)
+	}
+	user.Password = ""
+	return user, nil
+}
+
+func (s *service) Update(ctx context.Context, id, expected uint, changes map[string]interface{}, password string) (models.User, bool, error) {
+	if password != "" {
+		hash, err := s.hash(password)
+		if err != nil {
+			return models.User{}, false, err
+		}
+		changes["password"] = hash
+	}
+	if email, ok := changes["email"].(string); ok {
+		changes["email"] = NormalizeEmail(email)
+	}
+
+	updated, err := s.users.Update(ctx, id, expected, changes)
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return models.User{}, false, apperror.Conflict(apperror.CodeEmailTaken, "User with this email already exists")
+		}
+		return models.User{}, false, apperror.Internal("Failed to update user", err)
+	}
+
+	user, err := s.users.Get(ctx, id)
+	if err != nil {
+		return models.User{}, false, userError(err, "Failed to fetch updated user")
+	}
+	user.Password = ""
+	return user, updated, nil
+}
+
+func (s *service) Delete(ctx context.Context, id uint) error {
+	if _, err := s.users.Get(ctx, id); err != nil {
+		return userError(err, "Failed to fetch user")
+	}
+	if err := s.users.Delete(ctx, id); err != nil {
+		return apperror.Internal("Failed to delete user", err)
+	}
+	return nil
+}
+
+func (s *service) hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	if err != nil {
+		return "", apperror.Internal("Failed to hash password", err)
+	}
+	return string(hash), nil
+}
+
+func userError(err error, detail string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		return apperror.NotFound(apperror.CodeUserNotFound, "User not found")
+	default:
+		return apperror.Internal(detail, err)
+	}
+}
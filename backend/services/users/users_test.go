@@ -0,0 +1,253 @@
+package users
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ecommerce-backend/apperror"
+	"ecommerce-backend/config"
+	"ecommerce-backend/mail"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository/repotest"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var now = time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*service, *repotest.Users) {
+	s, users, _ := newTestServiceWithMail(t)
+	return s, users
+}
+
+func newTestServiceWithMail(t *testing.T) (*service, *repotest.Users, *mail.Fake) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := repotest.NewUsers(
+		models.User{FirstName: "Siti", LastName: "Rahma", Email: "siti@example.com", Password: string(hash), Role: RoleUser, IsActive: true},
+		models.User{FirstName: "Agus", LastName: "Salim", Email: "agus@example.com", Password: string(hash), Role: RoleUser, IsActive: false},
+		models.User{FirstName: "Dewi", Email: "dewi@example.com", Role: RoleUser, IsActive: true, IsGuest: true},
+	)
+	mailer := mail.NewFake()
+	s := NewService(users, mailer, config.StoreConfig{Name: "Toko Kopi", URL: "https://toko.example.com"}).(*service)
+	s.cost = bcrypt.MinCost
+	s.now = func() time.Time { return now }
+	return s, users, mailer
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name     string
+		account  Account
+		wantCode apperror.Code
+	}{
+		{
+			name:    "new customer",
+			account: Account{FirstName: "Budi", LastName: "Santoso", Email: " Budi@Example.com ", Password: "hunter22", Role: RoleAdmin},
+		},
+		{
+			// Only the emailed claim link proves the guest's email is theirs
+			name:     "guest who checked out",
+			account:  Account{FirstName: "Dewi", LastName: "Lestari", Email: "dewi@example.com", Password: "hunter22", Phone: "0812"},
+			wantCode: apperror.CodeEmailTaken,
+		},
+		{
+			name:     "email taken",
+			account:  Account{FirstName: "Siti", LastName: "Lain", Email: "siti@example.com", Password: "hunter22"},
+			wantCode: apperror.CodeEmailTaken,
+		},
+		{
+			name:     "email taken in another case",
+			account:  Account{FirstName: "Siti", LastName: "Lain", Email: "SITI@example.com", Password: "hunter22"},
+			wantCode: apperror.CodeEmailTaken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, users := newTestService(t)
+
+			user, err := s.Register(context.Background(), tt.account)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+			// Sign-up never grants admin, whatever the request says
+			if user.Role != RoleUser || !user.IsActive {
+				t.Errorf("user = role %q active %v, want an active customer", user.Role, user.IsActive)
+			}
+			if user.Password != "" {
+				t.Error("returned user carries the password hash")
+			}
+			if user.IsGuest || user.LastName != tt.account.LastName {
+				t.Errorf("user = %+v, want an account with the given details", user)
+			}
+			stored := users.Rows[user.ID]
+			if stored.Email != "budi@example.com" {
+				t.Errorf("stored email = %q, want it trimmed and lowercased", stored.Email)
+			}
+			if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte(tt.account.Password)) != nil {
+				t.Error("stored password is not a hash of the given one")
+			}
+		})
+	}
+}
+
+func TestClaim(t *testing.T) {
+	s, users, mailer := newTestServiceWithMail(t)
+	ctx := context.Background()
+
+	// Accounts and unknown emails get nothing, and cannot tell
+	for _, email := range []string{"siti@example.com", "nobody@example.com"} {
+		if err := s.RequestClaim(ctx, email); err != nil {
+			t.Errorf("RequestClaim(%s) = %v", email, err)
+		}
+	}
+	if sent := mailer.Sent(); len(sent) != 0 {
+		t.Fatalf("sent %+v to emails without guest orders", sent)
+	}
+
+	if err := s.RequestClaim(ctx, " Dewi@Example.com"); err != nil {
+		t.Fatal(err)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != "dewi@example.com" {
+		t.Fatalf("sent %+v, want a claim link for the guest", sent)
+	}
+	token := claimToken(t, sent[0].Body)
+	if stored := users.Rows[3].ClaimTokenHash; stored == nil || *stored == token {
+		t.Errorf("stored claim token = %v, want its hash", stored)
+	}
+
+	account := Account{FirstName: "Dewi", LastName: "Lestari", Password: "hunter22", Phone: "0812"}
+	if _, err := s.Claim(ctx, "forged", account); repotest.Code(err) != apperror.CodeInvalidToken {
+		t.Errorf("Claim with a forged token: err = %v, want INVALID_TOKEN", err)
+	}
+
+	// The guest's row, and with it their orders, becomes the account
+	user, err := s.Claim(ctx, token, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 3 || user.IsGuest || user.LastName != "Lestari" || user.Email != "dewi@example.com" {
+		t.Errorf("user = %+v, want guest 3 turned into an account", user)
+	}
+	stored := users.Rows[3]
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("hunter22")) != nil || stored.ClaimTokenHash != nil {
+		t.Error("claim did not set the password and clear the token")
+	}
+	if _, err := s.Claim(ctx, token, account); repotest.Code(err) != apperror.CodeInvalidToken {
+		t.Errorf("second Claim with the same link: err = %v, want INVALID_TOKEN", err)
+	}
+}
+
+func TestClaimExpired(t *testing.T) {
+	s, _, mailer := newTestServiceWithMail(t)
+	ctx := context.Background()
+	if err := s.RequestClaim(ctx, "dewi@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now.Add(ClaimTTL) }
+
+	_, err := s.Claim(ctx, claimToken(t, mailer.Sent()[0].Body), Account{FirstName: "Dewi", Password: "hunter22"})
+	if repotest.Code(err) != apperror.CodeInvalidToken {
+		t.Errorf("Claim after %v: err = %v, want INVALID_TOKEN", ClaimTTL, err)
+	}
+}
+
+// claimToken returns the token of the claim link in an email body
+func claimToken(t *testing.T, body string) string {
+	t.Helper()
+	_, link, ok := strings.Cut(body, "https://toko.example.com/register/claim?")
+	if !ok {
+		t.Fatalf("email body %q has no claim link", body)
+	}
+	query, err := url.ParseQuery(strings.Fields(link)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return query.Get("token")
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantCode apperror.Code
+	}{
+		{name: "valid", email: "siti@example.com", password: "secret123"},
+		{name: "email in another case", email: " Siti@Example.com ", password: "secret123"},
+		{name: "wrong password", email: "siti@example.com", password: "nope", wantCode: apperror.CodeInvalidCredentials},
+		{name: "unknown email", email: "nobody@example.com", password: "secret123", wantCode: apperror.CodeInvalidCredentials},
+		{name: "guest", email: "dewi@example.com", password: "", wantCode: apperror.CodeInvalidCredentials},
+		{name: "inactive account", email: "agus@example.com", password: "secret123", wantCode: apperror.CodeAccountInactive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t)
+
+			user, err := s.Authenticate(context.Background(), tt.email, tt.password)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode == "" && (user.Email != "siti@example.com" || user.Password != "") {
+				t.Errorf("user = %q with password %q, want siti@example.com without password", user.Email, user.Password)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		expected    uint
+		changes     map[string]interface{}
+		password    string
+		wantCode    apperror.Code
+		wantUpdated bool
+	}{
+		{name: "rename", expected: 1, changes: map[string]interface{}{"first_name": "Sri"}, wantUpdated: true},
+		{name: "new password", expected: 1, changes: map[string]interface{}{}, password: "changed99", wantUpdated: true},
+		{name: "stale version", expected: 3, changes: map[string]interface{}{"first_name": "Sri"}},
+		{name: "email taken", expected: 1, changes: map[string]interface{}{"email": "agus@example.com"}, wantCode: apperror.CodeEmailTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, users := newTestService(t)
+
+			user, updated, err := s.Update(context.Background(), 1, tt.expected, tt.changes, tt.password)
+			if code := repotest.Code(err); code != tt.wantCode {
+				t.Fatalf("error code = %q, want %q (err: %v)", code, tt.wantCode, err)
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("updated = %v, want %v", updated, tt.wantUpdated)
+			}
+			if user.Password != "" {
+				t.Error("returned user carries the password hash")
+			}
+			if tt.password != "" {
+				if bcrypt.CompareHashAndPassword([]byte(users.Rows[1].Password), []byte(tt.password)) != nil {
+					t.Error("password was not changed")
+				}
+			}
+		})
+	}
+}
+
+func TestGetAndDeleteUnknown(t *testing.T) {
+	s, _ := newTestService(t)
+	if _, err := s.Get(context.Background(), 99); repotest.Code(err) != apperror.CodeUserNotFound {
+		t.Errorf("Get: err = %v, want USER_NOT_FOUND", err)
+	}
+	if err := s.Delete(context.Background(), 99); repotest.Code(err) != apperror.CodeUserNotFound {
+		t.Errorf("Delete: err = %v, want USER_NOT_FOUND", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package spreadsheet writes tables row by row as CSV or XLSX. Rows go
+// straight to the underlying writer, so a table of any length is written
+// without holding it in memory.
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a file format a table can be written in
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// ContentType is the MIME type of files in the format
+func (f Format) ContentType() string {
+	if f == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Valid reports whether f is a format New can write
+func (f Format) Valid() bool {
+	return f == CSV || f == XLSX
+}
+
+// Writer writes the rows of a table. Cells are strings, integers, floats,
+// times, nil pointers to times or nil; nil cells are left empty. The file is
+// only complete once Close returns.
+type Writer interface {
+	WriteRow(cells []interface{}) error
+	Close() error
+}
+
+// New returns a Writer for a table in format, which starts by writing header
+// as the first row
+func New(format Format, w io.Writer, header []string) (Writer, error) {
+	switch format {
+	case CSV:
+		// A byte order mark makes spreadsheet programs read the file as UTF-8
+		if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+			return nil, err
+		}
+		c := &csvWriter{w: csv.NewWriter(w)}
+		if err := c.WriteRow(stringCells(header)); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case XLSX:
+		x, err := newXLSX(w)
+		if err != nil {
+			return nil, err
+		}
+		if err := x.writeRow(stringCells(header), styleHeader); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	return nil, fmt.Errorf("spreadsheet: unknown format %q", format)
+}
+
+func stringCells(values []string) []interface{} {
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	return cells
+}
+
+// dateLayout is how times are written where the format has no date type
+const dateLayout = "2006-01-02 15:04:05"
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvWriter) WriteRow(cells []interface{}) error {
+	c.record = c.record[:0]
+	for _, cell := range cells {
+		c.record = append(c.record, csvValue(cell))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func csvValue(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		// Text a spreadsheet program would run as a formula is quoted
+		// with an apostrophe, as the text may come from customers
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(dateLayout)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(dateLayout)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,138 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	placed = time.Date(2024, 3, 9, 10, 30, 0, 0, time.UTC)
+	rows   = [][]interface{}{
+		{"ORD-20240309-0001", placed, int64(85000), 2, "=HYPERLINK(\"x\")"},
+		{"Kopi <Arabika> & Teh", (*time.Time)(nil), int64(-98500), nil, "Jl. Merdeka\nBandung"},
+	}
+)
+
+func write(t *testing.T, format Format) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := New(format, &buf, []string{"Order", "Placed", "Total", "Items", "Address"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	out := write(t, CSV)
+	bom := []byte("\uFEFF")
+	if !bytes.HasPrefix(out, bom) {
+		t.Errorf("CSV does not start with a byte order mark")
+	}
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(out, bom))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Order", "Placed", "Total", "Items", "Address"},
+		{"ORD-20240309-0001", "2024-03-09 10:30:00", "85000", "2", "'=HYPERLINK(\"x\")"},
+		{"Kopi <Arabika> & Teh", "", "-98500", "", "Jl. Merdeka\nBandung"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("CSV has %d records, want %d: %q", len(records), len(want), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+// sheet is the part of a worksheet the test reads back
+type sheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string `xml:"r,attr"`
+			S      int    `xml:"s,attr"`
+			T      string `xml:"t,attr"`
+			V      string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSX(t *testing.T) {
+	out := write(t, XLSX)
+	z, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := map[string][]byte{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = content
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if err := xml.Unmarshal(parts[name], new(struct{})); err != nil {
+			t.Errorf("%s is not XML: %v", name, err)
+		}
+	}
+
+	var got sheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Rows) != 3 {
+		t.Fatalf("sheet has %d rows, want 3", len(got.Rows))
+	}
+	header, first, second := got.Rows[0], got.Rows[1], got.Rows[2]
+	if header.Cells[0].Inline != "Order" || header.Cells[0].S != styleHeader {
+		t.Errorf("header cell = %+v, want bold Order", header.Cells[0])
+	}
+	// 9 March 2024 is day 45360; 10:30 is 0.4375 of a day
+	if c := first.Cells[1]; c.R != "B2" || c.V != "45360.4375" || c.S != styleDate {
+		t.Errorf("date cell = %+v, want B2 45360.4375 as a date", c)
+	}
+	if c := first.Cells[2]; c.T != "" || c.V != "85000" {
+		t.Errorf("number cell = %+v, want 85000", c)
+	}
+	// Inline strings are never formulas
+	if c := first.Cells[4]; c.T != "inlineStr" || c.Inline != "=HYPERLINK(\"x\")" {
+		t.Errorf("string cell = %+v", c)
+	}
+	if len(second.Cells) != 3 || second.Cells[0].Inline != "Kopi <Arabika> & Teh" || second.Cells[2].R != "E3" {
+		t.Errorf("row with empty cells = %+v", second.Cells)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := column(i); got != want {
+			t.Errorf("column(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City       string `json:"city" validate:"required"`
+	PostalCode string `json:"postal_code" validate:"omitempty,numeric,len=5"`
+}
+
+type item struct {
+	ProductID uint `json:"product_id" validate:"gt=0"`
+	Quantity  int  `json:"quantity" validate:"gte=1,lte=99"`
+}
+
+type orderRequest struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Password string   `json:"password,omitempty" validate:"omitempty,min=6,max=72"`
+	Status   string   `json:"status" validate:"omitempty,oneof=pending paid"`
+	Items    []item   `json:"items" validate:"min=1,dive"`
+	Address  address  `json:"shipping_address"`
+	Proxies  []string `validate:"dive,ip|cidr"`
+}
+
+func valid() orderRequest {
+	return orderRequest{
+		Email:   "siti@example.com",
+		Items:   []item{{ProductID: 1, Quantity: 2}},
+		Address: address{City: "Bandung"},
+	}
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *orderRequest)
+		want   FieldErrors
+	}{
+		{name: "valid", change: func(r *orderRequest) {}},
+		{
+			name:   "required",
+			change: func(r *orderRequest) { r.Email = "" },
+			want:   FieldErrors{"email": "is required"},
+		},
+		{
+			name:   "email",
+			change: func(r *orderRequest) { r.Email = "siti" },
+			want:   FieldErrors{"email": "must be a valid email address"},
+		},
+		{
+			name:   "string lengths",
+			change: func(r *orderRequest) { r.Password = "abc" },
+			want:   FieldErrors{"password": "must be at least 6 characters long"},
+		},
+		{
+			name:   "string too long",
+			change: func(r *orderRequest) { r.Password = strings.Repeat("a", 73) },
+			want:   FieldErrors{"password": "must be at most 72 characters long"},
+		},
+		{
+			name:   "oneof",
+			change: func(r *orderRequest) { r.Status = "lost" },
+			want:   FieldErrors{"status": "must be one of: pending, paid"},
+		},
+		{
+			name:   "slice length",
+			change: func(r *orderRequest) { r.Items = nil },
+			want:   FieldErrors{"items": "must be at least 1"},
+		},
+		{
+			name:   "paths into slices",
+			change: func(r *orderRequest) { r.Items = append(r.Items, item{ProductID: 0, Quantity: 100}) },
+			want: FieldErrors{
+				"items[1].product_id": "must be greater than 0",
+				"items[1].quantity":   "must be less than or equal to 99",
+			},
+		},
+		{
+			name:   "paths into nested structs",
+			change: func(r *orderRequest) { r.Address = address{PostalCode: "40l15"} },
+			want: FieldErrors{
+				"shipping_address.city":        "is required",
+				"shipping_address.postal_code": "must be numeric",
+			},
+		},
+		{
+			name:   "unnamed rule",
+			change: func(r *orderRequest) { r.Address.PostalCode = "401" },
+			want:   FieldErrors{"shipping_address.postal_code": `failed the "len" rule`},
+		},
+		{
+			name:   "ip or cidr",
+			change: func(r *orderRequest) { r.Proxies = []string{"10.0.0.0/8", "proxy"} },
+			want:   FieldErrors{"Proxies[1]": "must be an IP address or CIDR range"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.change(&r)
+			err := Struct(&r)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Struct() = %v, want nil", err)
+				}
+				return
+			}
+
+			var got FieldErrors
+			if !errors.As(err, &got) {
+				t.Fatalf("Struct() = %v, want FieldErrors", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Struct() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
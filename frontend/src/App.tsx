@@ -11,6 +11,8 @@ import OrderPage from './pages/OrderPage';
 import OrdersPage from './pages/OrdersPage';
 import CheckoutPage from './pages/CheckoutPage';
 import OrderSuccessPage from './pages/OrderSuccessPage';
+import OrderLookupPage from './pages/OrderLookupPage';
+import ClaimPage from './pages/ClaimPage';
 import DemoCartPage from './pages/DemoCartPage';
 // Admin Pages
 import { AdminLoginPage, AdminDashboard, AdminProducts, AdminOrders, AdminUsers, AdminAnalytics, AdminSettings } from './pages/admin';
@@ -34,9 +36,11 @@ function App() {
                     <Route path="/demo-cart" element={<DemoCartPage />} />
                     <Route path="/login" element={<LoginPage />} />
                     <Route path="/register" element={<RegisterPage />} />
+                    <Route path="/register/claim" element={<ClaimPage />} />
                     <Route path="/checkout" element={<CheckoutPage />} />
                     <Route path="/order-success" element={<OrderSuccessPage />} />
                     <Route path="/orders" element={<OrdersPage />} />
+                    <Route path="/orders/lookup" element={<OrderLookupPage />} />
                     <Route path="/orders/:id" element={<OrderPage />} />
                   </Routes>
                 </main>
@@ -16,6 +16,7 @@ interface AuthContextType {
   user: User | null;
   login: (email: string, password: string) => Promise<boolean>;
   register: (userData: any) => Promise<boolean>;
+  claim: (data: any) => Promise<boolean>;
   logout: () => void;
   isAuthenticated: boolean;
   isAdmin: boolean;
@@ -106,28 +107,34 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
     }
   };
 
+  const signIn = (authData: AuthResponse) => {
+    const user: User = {
+      id: authData.user.id,
+      email: authData.user.email,
+      first_name: authData.user.first_name,
+      last_name: authData.user.last_name,
+      role: authData.user.role as 'admin' | 'user',
+      is_active: authData.user.is_active,
+    };
+
+    setUser(user);
+    localStorage.setItem('token', authData.token);
+    localStorage.setItem('user', JSON.stringify(user));
+  };
+
+  // Errors are rethrown so the page can tell an email with guest orders,
+  // which has to be claimed by email, from other failures
   const register = async (userData: any): Promise<boolean> => {
-    try {
-      const response = await authAPI.register(userData);
-      const authData: AuthResponse = response.data;
-      
-      const user: User = {
-        id: authData.user.id,
-        email: authData.user.email,
-        first_name: authData.user.first_name,
-        last_name: authData.user.last_name,
-        role: authData.user.role as 'admin' | 'user',
-        is_active: authData.user.is_active,
-      };
-      
-      setUser(user);
-      localStorage.setItem('token', authData.token);
-      localStorage.setItem('user', JSON.stringify(user));
-      return true;
-    } catch (error) {
-      console.error('Registration failed:', error);
-      return false;
-    }
+    const response = await authAPI.register(userData);
+    signIn(response.data);
+    return true;
+  };
+
+  // claim creates the account for a guest's orders from the emailed link
+  const claim = async (data: any): Promise<boolean> => {
+    const response = await authAPI.claim(data);
+    signIn(response.data);
+    return true;
   };
 
   const logout = () => {
@@ -140,7 +147,7 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
   const isAdmin = user?.role === 'admin';
 
   return (
-    <AuthContext.Provider value={{ user, login, register, logout, isAuthenticated, isAdmin, loading }}>
+    <AuthContext.Provider value={{ user, login, register, claim, logout, isAuthenticated, isAdmin, loading }}>
       {children}
     </AuthContext.Provider>
   );
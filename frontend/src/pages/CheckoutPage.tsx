@@ -6,7 +6,7 @@ import { useCart } from '../context/CartContext';
 
 import { useAuth } from '../context/AuthContext';
 
-import { checkoutAPI } from '../services/api';
+import { checkoutAPI, guestAPI } from '../services/api';
 
 
 
@@ -42,6 +42,14 @@ const CheckoutPage: React.FC = () => {
 
   const [paymentMethod, setPaymentMethod] = useState('cod');
 
+  // Contact details of a shopper checking out without an account
+  const [guest, setGuest] = useState({
+    first_name: '',
+    last_name: '',
+    email: '',
+    phone: '',
+  });
+
 
 
   const formatPrice = (price: number) => {
@@ -71,9 +79,8 @@ const CheckoutPage: React.FC = () => {
 
 
   React.useEffect(() => {
-    if (!isAuthenticated) {
-      navigate('/login', { state: { from: { pathname: '/checkout' } } });
-    } else {
+    // Shoppers without an account check out as guests
+    if (isAuthenticated) {
       // Check if we just came from login (cart merge might be in progress)
       const from = location.state?.from?.pathname;
       if (from === '/login') {
@@ -104,6 +111,13 @@ const CheckoutPage: React.FC = () => {
 
 
 
+  const handleGuestChange = (e: React.ChangeEvent<HTMLInputElement>) => {
+    setGuest({
+      ...guest,
+      [e.target.name]: e.target.value,
+    });
+  };
+
   const handleSubmit = async (e: React.FormEvent) => {
 
     e.preventDefault();
@@ -154,6 +168,12 @@ const CheckoutPage: React.FC = () => {
       return;
     }
 
+    if (!isAuthenticated && (!guest.first_name || !guest.email)) {
+      setError('Please enter your name and email address');
+      setLoading(false);
+      return;
+    }
+
 
 
     try {
@@ -182,7 +202,17 @@ const CheckoutPage: React.FC = () => {
 
 
 
-      const response = await checkoutAPI.checkout(orderData);
+      // Guests send the cart along, since it only lives in this browser
+      const response = isAuthenticated
+        ? await checkoutAPI.checkout(orderData)
+        : await guestAPI.checkout({
+            ...orderData,
+            ...guest,
+            items: items.map((item) => ({
+              product_id: item.product?.id ?? item.product_id,
+              quantity: item.quantity,
+            })),
+          });
 
       
 
@@ -206,7 +236,9 @@ const CheckoutPage: React.FC = () => {
         navigate('/order-success', { 
           state: { 
             order: response.data,
-            orderNumber: response.data.order_number 
+            orderNumber: response.data.order_number,
+            lookupToken: response.data.lookup_token,
+            email: isAuthenticated ? user?.email : guest.email,
           } 
         });
       } else {
@@ -217,8 +249,15 @@ const CheckoutPage: React.FC = () => {
       console.error('Checkout error:', error);
       
       // Handle different types of errors
-      if (error.response?.status === 400 && error.response?.data?.error === 'Cart is empty') {
+      if (error.response?.data?.code === 'EMAIL_TAKEN') {
+        setError('An account already uses this email address. Please sign in to check out.');
+      } else if (error.response?.data?.code === 'PRODUCT_NOT_FOUND') {
+        setError('A product in your cart is no longer available. Please update your cart.');
+      } else if (error.response?.data?.code === 'CART_EMPTY') {
         setError('Your cart is empty. Please add items before checkout.');
+      } else if (error.response?.data?.code === 'INSUFFICIENT_STOCK') {
+        const available = error.response.data.available;
+        setError(`Some items in your cart are no longer available in that quantity${available !== undefined ? ` (only ${available} left)` : ''}. Please update your cart.`);
       } else if (error.response?.status === 401) {
         setError('Your session has expired. Please log in again.');
         // Redirect to login after a short delay
@@ -226,7 +265,7 @@ const CheckoutPage: React.FC = () => {
           navigate('/login');
         }, 2000);
       } else {
-        setError(error.response?.data?.error || 'Failed to create order. Please try again.');
+        setError(error.response?.data?.detail || 'Failed to create order. Please try again.');
       }
     } finally {
 
@@ -272,6 +311,7 @@ const CheckoutPage: React.FC = () => {
 
               <h2 className="text-xl font-bold text-gray-900 mb-4">Contact Information</h2>
 
+              {isAuthenticated ? (
               <div className="space-y-4">
 
                 <div className="grid grid-cols-2 gap-4">
@@ -345,6 +385,78 @@ const CheckoutPage: React.FC = () => {
                 </div>
 
               </div>
+              ) : (
+              <div className="space-y-4">
+                <p className="text-sm text-gray-600">
+                  Checking out as a guest. We'll email you a link to follow your order.{' '}
+                  <RouterLink
+                    to="/login"
+                    state={{ from: { pathname: '/checkout' } }}
+                    className="text-blue-600 hover:text-blue-700 font-medium"
+                  >
+                    Have an account? Sign in
+                  </RouterLink>
+                </p>
+                <div className="grid grid-cols-2 gap-4">
+                  <div>
+                    <label htmlFor="first_name" className="block text-sm font-medium text-gray-700 mb-1">
+                      First Name *
+                    </label>
+                    <input
+                      type="text"
+                      id="first_name"
+                      name="first_name"
+                      required
+                      value={guest.first_name}
+                      onChange={handleGuestChange}
+                      className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                    />
+                  </div>
+                  <div>
+                    <label htmlFor="last_name" className="block text-sm font-medium text-gray-700 mb-1">
+                      Last Name
+                    </label>
+                    <input
+                      type="text"
+                      id="last_name"
+                      name="last_name"
+                      value={guest.last_name}
+                      onChange={handleGuestChange}
+                      className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                    />
+                  </div>
+                </div>
+                <div className="grid grid-cols-2 gap-4">
+                  <div>
+                    <label htmlFor="email" className="block text-sm font-medium text-gray-700 mb-1">
+                      Email *
+                    </label>
+                    <input
+                      type="email"
+                      id="email"
+                      name="email"
+                      required
+                      value={guest.email}
+                      onChange={handleGuestChange}
+                      className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                    />
+                  </div>
+                  <div>
+                    <label htmlFor="phone" className="block text-sm font-medium text-gray-700 mb-1">
+                      Phone
+                    </label>
+                    <input
+                      type="tel"
+                      id="phone"
+                      name="phone"
+                      value={guest.phone}
+                      onChange={handleGuestChange}
+                      className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                    />
+                  </div>
+                </div>
+              </div>
+              )}
 
             </div>
 
@@ -697,7 +809,7 @@ const CheckoutPage: React.FC = () => {
 
                 onClick={handleSubmit}
 
-                disabled={loading || !shippingAddress.address || !shippingAddress.city || !shippingAddress.province || !shippingAddress.postal_code}
+                disabled={loading || !shippingAddress.address || !shippingAddress.city || !shippingAddress.province || !shippingAddress.postal_code || (!isAuthenticated && (!guest.first_name || !guest.email))}
 
                 className="w-full mt-6 py-3 bg-blue-600 text-white rounded-lg font-semibold hover:bg-blue-700 transition-colors disabled:opacity-50 disabled:cursor-not-allowed"
 
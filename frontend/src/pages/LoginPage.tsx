@@ -36,7 +36,7 @@ const LoginPage: React.FC = () => {
         setError('Invalid email or password');
       }
     } catch (error: any) {
-      setError(error.response?.data?.error || 'Login failed. Please try again.');
+      setError(error.response?.data?.detail || 'Login failed. Please try again.');
     } finally {
       setLoading(false);
     }
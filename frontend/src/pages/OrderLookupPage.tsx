@@ -0,0 +1,170 @@
+import React, { useEffect, useState } from 'react';
+import { Link as RouterLink, useSearchParams } from 'react-router-dom';
+import { guestAPI } from '../services/api';
+import type { Order } from '../types';
+
+// OrderLookupPage shows a guest order from the link emailed when it was
+// placed; the token in the link is all it takes to see the order
+const OrderLookupPage: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+
+  const [order, setOrder] = useState<Order | null>(null);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    const fetchOrder = async () => {
+      if (!token) {
+        setError('This order link is incomplete. Please use the link from your email.');
+        setLoading(false);
+        return;
+      }
+      try {
+        setLoading(true);
+        const response = await guestAPI.lookupOrder(token);
+        setOrder(response.data);
+      } catch (error: any) {
+        console.error('Failed to look up order:', error);
+        setError(error.response?.status === 404
+          ? 'We could not find an order for this link. Please check that you copied all of it.'
+          : 'Failed to load your order. Please try again.');
+      } finally {
+        setLoading(false);
+      }
+    };
+    fetchOrder();
+  }, [token]);
+
+  const formatPrice = (price: number) => {
+    return new Intl.NumberFormat('id-ID', {
+      style: 'currency',
+      currency: 'IDR',
+      minimumFractionDigits: 0,
+    }).format(price);
+  };
+
+  const formatDate = (dateString: string) => {
+    return new Date(dateString).toLocaleDateString('id-ID', {
+      year: 'numeric',
+      month: 'long',
+      day: 'numeric',
+      hour: '2-digit',
+      minute: '2-digit',
+    });
+  };
+
+  const formatStatus = (status: string) => {
+    const text = status.replace(/_/g, ' ');
+    return text.charAt(0).toUpperCase() + text.slice(1);
+  };
+
+  if (loading) {
+    return (
+      <div className="min-h-screen bg-gray-50 flex items-center justify-center">
+        <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-blue-600"></div>
+      </div>
+    );
+  }
+
+  if (error || !order) {
+    return (
+      <div className="min-h-screen bg-gray-50 py-12">
+        <div className="max-w-xl mx-auto px-4 text-center">
+          <h1 className="text-2xl font-bold text-gray-900 mb-4">Order not found</h1>
+          <p className="text-gray-600 mb-6">{error}</p>
+          <RouterLink
+            to="/"
+            className="inline-block px-6 py-3 bg-blue-600 text-white rounded-lg font-semibold hover:bg-blue-700 transition-colors"
+          >
+            Continue Shopping
+          </RouterLink>
+        </div>
+      </div>
+    );
+  }
+
+  return (
+    <div className="min-h-screen bg-gray-50 py-12">
+      <div className="max-w-4xl mx-auto px-4 sm:px-6 lg:px-8 space-y-6">
+        <div className="bg-white rounded-lg shadow-sm border border-gray-200 p-6">
+          <div className="flex flex-wrap items-center justify-between gap-4">
+            <div>
+              <h1 className="text-2xl font-bold text-gray-900">Order {order.order_number}</h1>
+              <p className="text-sm text-gray-500">Placed on {formatDate(order.created_at)}</p>
+            </div>
+            <div className="flex gap-2">
+              <span className="px-3 py-1 rounded-full text-sm font-medium bg-blue-100 text-blue-800">
+                {formatStatus(order.status)}
+              </span>
+              <span className="px-3 py-1 rounded-full text-sm font-medium bg-gray-100 text-gray-800">
+                {formatStatus(order.payment_status)}
+              </span>
+            </div>
+          </div>
+          <div className="mt-4">
+            <h2 className="text-sm font-medium text-gray-500">Shipping Address</h2>
+            <p className="text-gray-900">{order.shipping_address}</p>
+          </div>
+        </div>
+
+        <div className="bg-white rounded-lg shadow-sm border border-gray-200 p-6">
+          <h2 className="text-lg font-semibold text-gray-900 mb-4">Items</h2>
+          <div className="divide-y">
+            {order.order_items?.map((item) => (
+              <div key={item.id} className="flex justify-between py-3">
+                <div>
+                  <p className="font-medium text-gray-900">{item.product_name}</p>
+                  {item.options && Object.keys(item.options).length > 0 && (
+                    <p className="text-sm text-gray-500">
+                      {Object.entries(item.options).map(([option, value]) => `${option}: ${value}`).join(', ')}
+                    </p>
+                  )}
+                  <p className="text-sm text-gray-500">Qty: {item.quantity}</p>
+                </div>
+                <p className="font-medium text-gray-900">{formatPrice(item.total_price)}</p>
+              </div>
+            ))}
+          </div>
+          <div className="border-t pt-4 mt-2 space-y-2 text-sm text-gray-600">
+            <div className="flex justify-between"><span>Subtotal</span><span>{formatPrice(order.subtotal)}</span></div>
+            <div className="flex justify-between"><span>Shipping</span><span>{formatPrice(order.shipping_cost)}</span></div>
+            <div className="flex justify-between"><span>Tax</span><span>{formatPrice(order.tax)}</span></div>
+            <div className="flex justify-between text-lg font-bold text-gray-900 pt-2 border-t">
+              <span>Total</span><span>{formatPrice(order.total_amount)}</span>
+            </div>
+          </div>
+        </div>
+
+        {order.shipments && order.shipments.length > 0 && (
+          <div className="bg-white rounded-lg shadow-sm border border-gray-200 p-6">
+            <h2 className="text-lg font-semibold text-gray-900 mb-4">Shipments</h2>
+            <div className="space-y-3">
+              {order.shipments.map((shipment) => (
+                <div key={shipment.id} className="text-sm">
+                  <p className="font-medium text-gray-900">
+                    {shipment.carrier.toUpperCase()} {shipment.service} · {shipment.tracking_number}
+                  </p>
+                  <p className="text-gray-500">
+                    {shipment.delivered_at
+                      ? `Delivered ${formatDate(shipment.delivered_at)}`
+                      : `Shipped ${formatDate(shipment.shipped_at)}`}
+                  </p>
+                </div>
+              ))}
+            </div>
+          </div>
+        )}
+
+        <div className="bg-blue-50 rounded-lg p-6 text-sm text-blue-900">
+          <RouterLink to="/register" className="font-semibold text-blue-700 hover:text-blue-800">
+            Create an account
+          </RouterLink>{' '}
+          with the email address you ordered with and we'll email you a link to keep all your orders in one place.
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default OrderLookupPage;
@@ -1,8 +1,8 @@
 import React, { useState, useEffect } from 'react';
 import { useParams, Link as RouterLink, useNavigate } from 'react-router-dom';
 import { useAuth } from '../context/AuthContext';
-import { checkoutAPI } from '../services/api';
-import type { Order, OrderItem } from '../types';
+import { checkoutAPI, saveDownload } from '../services/api';
+import type { Order, OrderItem, Return } from '../types';
 
 const OrderPage: React.FC = () => {
   const { id } = useParams<{ id: string }>();
@@ -13,6 +13,12 @@ const OrderPage: React.FC = () => {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
   const [cancelling, setCancelling] = useState(false);
+  const [downloading, setDownloading] = useState(false);
+  const [showReturnForm, setShowReturnForm] = useState(false);
+  const [returnReason, setReturnReason] = useState('');
+  const [returnPhotos, setReturnPhotos] = useState('');
+  const [returnQuantities, setReturnQuantities] = useState<Record<number, number>>({});
+  const [requestingReturn, setRequestingReturn] = useState(false);
 
   const formatPrice = (price: number) => {
     return new Intl.NumberFormat('id-ID', {
@@ -62,6 +68,8 @@ const OrderPage: React.FC = () => {
         return 'bg-yellow-100 text-yellow-800';
       case 'processing':
         return 'bg-blue-100 text-blue-800';
+      case 'partially_shipped':
+        return 'bg-indigo-100 text-indigo-800';
       case 'shipped':
         return 'bg-purple-100 text-purple-800';
       case 'delivered':
@@ -73,6 +81,11 @@ const OrderPage: React.FC = () => {
     }
   };
 
+  const formatStatus = (status: string) => {
+    const text = status.replace(/_/g, ' ');
+    return text.charAt(0).toUpperCase() + text.slice(1);
+  };
+
   const getPaymentStatusColor = (status: string) => {
     switch (status.toLowerCase()) {
       case 'pending':
@@ -81,6 +94,7 @@ const OrderPage: React.FC = () => {
         return 'bg-green-100 text-green-800';
       case 'failed':
         return 'bg-red-100 text-red-800';
+      case 'partially_refunded':
       case 'refunded':
         return 'bg-orange-100 text-orange-800';
       default:
@@ -88,6 +102,58 @@ const OrderPage: React.FC = () => {
     }
   };
 
+  const getReturnStatusColor = (status: Return['status']) => {
+    switch (status) {
+      case 'requested':
+        return 'text-yellow-700';
+      case 'approved':
+      case 'received':
+        return 'text-blue-700';
+      case 'refunded':
+        return 'text-green-700';
+      case 'rejected':
+        return 'text-red-700';
+      default:
+        return 'text-gray-700';
+    }
+  };
+
+  const handleRequestReturn = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!order) return;
+
+    const items = Object.entries(returnQuantities)
+      .filter(([, quantity]) => quantity > 0)
+      .map(([orderItemId, quantity]) => ({ order_item_id: Number(orderItemId), quantity }));
+    if (items.length === 0) {
+      alert('Choose at least one item to return.');
+      return;
+    }
+    const photos = returnPhotos.split('\n').map((url) => url.trim()).filter(Boolean);
+
+    try {
+      setRequestingReturn(true);
+      const response = await checkoutAPI.requestReturn(order.id, { reason: returnReason, photos, items });
+      const created: Return = response.data;
+      setOrder({ ...order, returns: [...(order.returns ?? []), created] });
+      setShowReturnForm(false);
+      setReturnReason('');
+      setReturnPhotos('');
+      setReturnQuantities({});
+      alert(`Return requested. Your RMA number is ${created.rma_number}.`);
+    } catch (error: any) {
+      console.error('Failed to request return:', error);
+      const messages: Record<string, string> = {
+        RETURN_WINDOW_CLOSED: 'The return window for this order has closed.',
+        RETURN_EXCEEDS_ORDER: 'You cannot return more than you ordered.',
+        RETURN_NOT_ALLOWED: 'Only delivered orders can be returned.',
+      };
+      alert(messages[error.response?.data?.code] || error.response?.data?.detail || 'Failed to request return. Please try again.');
+    } finally {
+      setRequestingReturn(false);
+    }
+  };
+
   const getPaymentMethodLabel = (method: string) => {
     switch (method.toLowerCase()) {
       case 'cod':
@@ -99,6 +165,20 @@ const OrderPage: React.FC = () => {
     }
   };
 
+  const handleDownloadInvoice = async () => {
+    if (!order) return;
+    try {
+      setDownloading(true);
+      const response = await checkoutAPI.getInvoice(order.id);
+      saveDownload(response, `${order.invoice_number}.pdf`);
+    } catch (error) {
+      console.error('Failed to download invoice:', error);
+      alert('Failed to download invoice. Please try again.');
+    } finally {
+      setDownloading(false);
+    }
+  };
+
   const handleCancelOrder = async () => {
     if (!order) return;
     
@@ -123,7 +203,9 @@ const OrderPage: React.FC = () => {
       
     } catch (error: any) {
       console.error('Failed to cancel order:', error);
-      const errorMessage = error.response?.data?.error || 'Failed to cancel order. Please try again.';
+      const errorMessage = error.response?.data?.code === 'ORDER_NOT_CANCELLABLE'
+        ? 'This order can no longer be cancelled.'
+        : error.response?.data?.detail || 'Failed to cancel order. Please try again.';
       alert(errorMessage);
     } finally {
       setCancelling(false);
@@ -148,7 +230,7 @@ const OrderPage: React.FC = () => {
         setOrder(response.data);
       } catch (error: any) {
         console.error('Failed to fetch order:', error);
-        setError(error.response?.data?.error || 'Failed to load order details');
+        setError(error.response?.data?.detail || 'Failed to load order details');
       } finally {
         setLoading(false);
       }
@@ -229,7 +311,7 @@ const OrderPage: React.FC = () => {
                   {/* Primary Order Status */}
                   <div className="flex flex-col items-end">
                     <span className={`inline-flex items-center px-3 py-1 rounded-full text-sm font-medium ${getStatusColor(order.status)}`}>
-                      {order.status.charAt(0).toUpperCase() + order.status.slice(1)}
+                      {formatStatus(order.status)}
                     </span>
                     <span className="text-xs text-gray-500 mt-1">Order Status</span>
                   </div>
@@ -238,7 +320,7 @@ const OrderPage: React.FC = () => {
                   {order.payment_status.toLowerCase() !== order.status.toLowerCase() && (
                     <div className="flex flex-col items-end">
                       <span className={`inline-flex items-center px-3 py-1 rounded-full text-sm font-medium ${getPaymentStatusColor(order.payment_status)}`}>
-                        {order.payment_status.charAt(0).toUpperCase() + order.payment_status.slice(1)}
+                        {formatStatus(order.payment_status)}
                       </span>
                       <span className="text-xs text-gray-500 mt-1">Payment</span>
                     </div>
@@ -280,17 +362,19 @@ const OrderPage: React.FC = () => {
                 {order.order_items?.map((item: OrderItem) => (
                   <div key={item.id} className="flex items-center space-x-4 p-4 border border-gray-200 rounded-lg">
                     <img
-                      src={getImageUrl(item.product?.image)}
-                      alt={item.product?.name}
+                      src={getImageUrl(item.product_image)}
+                      alt={item.product_name}
                       className="w-20 h-20 object-cover rounded-lg"
                       onError={handleImageError}
                     />
                     <div className="flex-1 min-w-0">
-                      <h4 className="font-medium text-gray-900 truncate">{item.product?.name}</h4>
-                      <p className="text-sm text-gray-500 truncate">
-                        {item.product?.description?.substring(0, 100)}...
-                      </p>
-                      <p className="text-sm text-gray-500">SKU: {item.product?.sku || 'N/A'}</p>
+                      <h4 className="font-medium text-gray-900 truncate">{item.product_name}</h4>
+                      {item.options && Object.keys(item.options).length > 0 && (
+                        <p className="text-sm text-gray-500 truncate">
+                          {Object.entries(item.options).map(([option, value]) => `${option}: ${value}`).join(', ')}
+                        </p>
+                      )}
+                      <p className="text-sm text-gray-500">SKU: {item.product_sku || 'N/A'}</p>
                     </div>
                     <div className="text-right">
                       <p className="text-sm text-gray-500">Quantity: {item.quantity}</p>
@@ -328,8 +412,140 @@ const OrderPage: React.FC = () => {
                     </div>
                   </div>
                 )}
+
+                {order.shipments && order.shipments.length > 0 && (
+                  <div className="border-t border-gray-200 pt-3 space-y-3">
+                    <p className="font-medium text-gray-900">Shipments</p>
+                    {order.shipments.map((shipment, index) => (
+                      <div key={shipment.id} className="rounded-md bg-gray-50 p-3 text-sm">
+                        <div className="flex justify-between">
+                          <span className="font-medium text-gray-900">
+                            Parcel {index + 1} · {shipment.carrier.toUpperCase()}{shipment.service && ` ${shipment.service}`}
+                          </span>
+                          <span className={shipment.delivered_at ? 'text-green-700' : 'text-purple-700'}>
+                            {shipment.delivered_at ? `Delivered ${formatDate(shipment.delivered_at)}` : 'In transit'}
+                          </span>
+                        </div>
+                        <p className="text-gray-600">Shipped {formatDate(shipment.shipped_at)}</p>
+                        {shipment.tracking_number && (
+                          <p className="text-gray-600">Tracking: {shipment.tracking_number}</p>
+                        )}
+                        <ul className="mt-1 text-gray-600">
+                          {shipment.items?.map((item) => {
+                            const orderItem = order.order_items?.find((oi) => oi.id === item.order_item_id);
+                            return (
+                              <li key={item.id}>
+                                {item.quantity} × {orderItem?.product_name ?? `Item #${item.order_item_id}`}
+                              </li>
+                            );
+                          })}
+                        </ul>
+                        {shipment.events && shipment.events.length > 0 && (
+                          <ol className="mt-3 border-l-2 border-gray-200 pl-4 space-y-2">
+                            {[...shipment.events].reverse().map((event) => (
+                              <li key={event.id}>
+                                <p className={event.status === 'exception' ? 'text-red-700' : 'text-gray-900'}>
+                                  {formatStatus(event.status)}{event.location && ` · ${event.location}`}
+                                </p>
+                                {event.description && <p className="text-gray-600">{event.description}</p>}
+                                <p className="text-xs text-gray-500">{formatDate(event.occurred_at)}</p>
+                              </li>
+                            ))}
+                          </ol>
+                        )}
+                      </div>
+                    ))}
+                  </div>
+                )}
               </div>
             </div>
+
+            {((order.returns && order.returns.length > 0) || order.status === 'delivered') && (
+              <div className="bg-white rounded-lg shadow-sm border border-gray-200 p-6">
+                <div className="flex items-center justify-between mb-4">
+                  <h3 className="text-lg font-bold text-gray-900">Returns</h3>
+                  {order.status === 'delivered' && !showReturnForm && (
+                    <button
+                      onClick={() => setShowReturnForm(true)}
+                      className="text-sm font-medium text-blue-600 hover:text-blue-700"
+                    >
+                      Request a return
+                    </button>
+                  )}
+                </div>
+
+                {order.returns?.map((ret) => (
+                  <div key={ret.id} className="rounded-md bg-gray-50 p-3 text-sm mb-3">
+                    <div className="flex justify-between">
+                      <span className="font-medium text-gray-900">{ret.rma_number}</span>
+                      <span className={getReturnStatusColor(ret.status)}>{formatStatus(ret.status)}</span>
+                    </div>
+                    <p className="text-gray-600">Requested {formatDate(ret.created_at)} · {ret.reason}</p>
+                    <ul className="mt-1 text-gray-600">
+                      {ret.items?.map((item) => {
+                        const orderItem = order.order_items?.find((oi) => oi.id === item.order_item_id);
+                        return (
+                          <li key={item.id}>
+                            {item.quantity} × {orderItem?.product_name ?? `Item #${item.order_item_id}`}
+                          </li>
+                        );
+                      })}
+                    </ul>
+                    {ret.admin_note && <p className="mt-1 text-gray-700">Note: {ret.admin_note}</p>}
+                    {ret.status === 'refunded' && (
+                      <p className="mt-1 text-green-700">Refunded {formatPrice(ret.refund_amount)}</p>
+                    )}
+                  </div>
+                ))}
+
+                {showReturnForm && (
+                  <form onSubmit={handleRequestReturn} className="space-y-3 text-sm">
+                    {order.order_items?.map((item: OrderItem) => (
+                      <div key={item.id} className="flex items-center justify-between">
+                        <span className="text-gray-900">{item.product_name || `Item #${item.id}`}</span>
+                        <input
+                          type="number"
+                          min={0}
+                          max={item.quantity}
+                          value={returnQuantities[item.id] ?? 0}
+                          onChange={(e) => setReturnQuantities({ ...returnQuantities, [item.id]: Number(e.target.value) })}
+                          className="w-20 border border-gray-300 rounded-md px-2 py-1"
+                        />
+                      </div>
+                    ))}
+                    <textarea
+                      required
+                      placeholder="Why are you returning these items?"
+                      value={returnReason}
+                      onChange={(e) => setReturnReason(e.target.value)}
+                      className="w-full border border-gray-300 rounded-md px-3 py-2"
+                    />
+                    <textarea
+                      placeholder="Photo URLs, one per line"
+                      value={returnPhotos}
+                      onChange={(e) => setReturnPhotos(e.target.value)}
+                      className="w-full border border-gray-300 rounded-md px-3 py-2"
+                    />
+                    <div className="flex gap-3">
+                      <button
+                        type="submit"
+                        disabled={requestingReturn}
+                        className="py-2 px-4 bg-blue-600 text-white rounded-lg font-medium hover:bg-blue-700 transition-colors disabled:opacity-50"
+                      >
+                        {requestingReturn ? 'Submitting...' : 'Submit return'}
+                      </button>
+                      <button
+                        type="button"
+                        onClick={() => setShowReturnForm(false)}
+                        className="py-2 px-4 bg-gray-100 text-gray-700 rounded-lg font-medium hover:bg-gray-200 transition-colors"
+                      >
+                        Cancel
+                      </button>
+                    </div>
+                  </form>
+                )}
+              </div>
+            )}
           </div>
 
           {/* Order Summary */}
@@ -356,6 +572,18 @@ const OrderPage: React.FC = () => {
                     <span>{formatPrice(order.total_amount)}</span>
                   </div>
                 </div>
+                {order.refunded_amount > 0 && (
+                  <>
+                    <div className="flex justify-between text-sm">
+                      <span className="text-gray-600">Refunded</span>
+                      <span className="font-medium text-green-600">-{formatPrice(order.refunded_amount)}</span>
+                    </div>
+                    <div className="flex justify-between text-sm font-bold text-gray-900">
+                      <span>Net Paid</span>
+                      <span>{formatPrice(order.net_paid)}</span>
+                    </div>
+                  </>
+                )}
               </div>
 
               {/* Payment Information */}
@@ -369,7 +597,7 @@ const OrderPage: React.FC = () => {
                   <div className="flex justify-between">
                     <span className="text-gray-600">Status</span>
                     <span className={`inline-flex items-center px-2 py-1 rounded-full text-xs font-medium ${getPaymentStatusColor(order.payment_status)}`}>
-                      {order.payment_status.charAt(0).toUpperCase() + order.payment_status.slice(1)}
+                      {formatStatus(order.payment_status)}
                     </span>
                   </div>
                 </div>
@@ -377,6 +605,15 @@ const OrderPage: React.FC = () => {
 
               {/* Actions */}
               <div className="mt-6 pt-6 border-t space-y-3">
+                {order.invoice_number && (
+                  <button
+                    onClick={handleDownloadInvoice}
+                    disabled={downloading}
+                    className="w-full py-2 px-4 bg-white border border-gray-300 text-gray-700 rounded-lg font-medium hover:bg-gray-50 transition-colors disabled:opacity-50 disabled:cursor-not-allowed"
+                  >
+                    {downloading ? 'Downloading...' : `Download Invoice ${order.invoice_number}`}
+                  </button>
+                )}
                 {order.status === 'pending' && (
                   <>
                     <button 
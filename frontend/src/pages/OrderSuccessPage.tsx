@@ -16,7 +16,7 @@ const OrderSuccessPage: React.FC = () => {
 
   const [isRefreshing, setIsRefreshing] = useState(false);
 
-  const orderData = location.state as { order: any; orderNumber: string } | null;
+  const orderData = location.state as { order: any; orderNumber: string; lookupToken?: string; email?: string } | null;
 
   // Refresh cart to ensure it's empty and ready for new shopping
   useEffect(() => {
@@ -89,7 +89,10 @@ const OrderSuccessPage: React.FC = () => {
 
 
 
-  const { order, orderNumber } = orderData;
+  const { order, orderNumber, lookupToken, email } = orderData;
+
+  // Guests follow their order through the link they were emailed
+  const lookupPath = lookupToken ? `/orders/lookup?token=${encodeURIComponent(lookupToken)}` : '';
 
 
 
@@ -151,6 +154,21 @@ const OrderSuccessPage: React.FC = () => {
 
 
 
+          {lookupToken && (
+            <div className="bg-blue-50 border border-blue-200 rounded-lg p-4 text-left mb-8 text-sm text-blue-900">
+              <p>
+                We've emailed a link to follow this order to <span className="font-semibold">{email}</span>.
+                Anyone with the link can see the order, so keep it to yourself.
+              </p>
+              <p className="mt-2">
+                <RouterLink to="/register" className="font-semibold text-blue-700 hover:text-blue-800">
+                  Create an account
+                </RouterLink>{' '}
+                with the same email address and we'll email you a link to keep all your orders in one place.
+              </p>
+            </div>
+          )}
+
           {/* Order Details Card */}
 
           <div className="bg-white rounded-lg shadow-sm border border-gray-200 p-8 text-left mb-8">
@@ -247,9 +265,9 @@ const OrderSuccessPage: React.FC = () => {
 
                       <img
 
-                        src={item.product?.image || '/placeholder-product.jpg'}
+                        src={item.product_image || '/placeholder-product.jpg'}
 
-                        alt={item.product?.name}
+                        alt={item.product_name}
 
                         className="w-16 h-16 object-cover rounded"
 
@@ -257,7 +275,7 @@ const OrderSuccessPage: React.FC = () => {
 
                       <div>
 
-                        <p className="font-medium text-gray-900">{item.product?.name}</p>
+                        <p className="font-medium text-gray-900">{item.product_name}</p>
 
                         <p className="text-sm text-gray-500">Qty: {item.quantity}</p>
 
@@ -355,13 +373,13 @@ const OrderSuccessPage: React.FC = () => {
 
             <RouterLink
 
-              to="/orders"
+              to={lookupPath || '/orders'}
 
               className="inline-block px-6 py-3 border border-gray-300 text-gray-700 rounded-lg font-semibold hover:bg-gray-50 transition-colors text-center"
 
             >
 
-              View My Orders
+              {lookupPath ? 'View Order' : 'View My Orders'}
 
             </RouterLink>
 
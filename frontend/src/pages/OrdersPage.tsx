@@ -28,12 +28,19 @@ const OrdersPage: React.FC = () => {
     });
   };
 
+  const formatStatus = (status: string) => {
+    const text = status.replace(/_/g, ' ');
+    return text.charAt(0).toUpperCase() + text.slice(1);
+  };
+
   const getStatusColor = (status: string) => {
     switch (status.toLowerCase()) {
       case 'pending':
         return 'bg-yellow-100 text-yellow-800';
       case 'processing':
         return 'bg-blue-100 text-blue-800';
+      case 'partially_shipped':
+        return 'bg-indigo-100 text-indigo-800';
       case 'shipped':
         return 'bg-purple-100 text-purple-800';
       case 'delivered':
@@ -73,7 +80,7 @@ const OrdersPage: React.FC = () => {
         setOrders(response.data);
       } catch (error: any) {
         console.error('Failed to fetch orders:', error);
-        setError(error.response?.data?.error || 'Failed to load orders');
+        setError(error.response?.data?.detail || 'Failed to load orders');
       } finally {
         setLoading(false);
       }
@@ -206,7 +213,7 @@ const OrdersPage: React.FC = () => {
                     </td>
                     <td className="px-6 py-4 whitespace-nowrap">
                       <span className={`inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium ${getStatusColor(order.status)}`}>
-                        {order.status.charAt(0).toUpperCase() + order.status.slice(1)}
+                        {formatStatus(order.status)}
                       </span>
                     </td>
                     <td className="px-6 py-4 whitespace-nowrap">
@@ -262,7 +269,7 @@ const OrdersPage: React.FC = () => {
                 </div>
                 <div className="flex flex-col gap-1">
                   <span className={`inline-flex items-center px-2 py-1 rounded-full text-xs font-medium ${getStatusColor(order.status)}`}>
-                    {order.status.charAt(0).toUpperCase() + order.status.slice(1)}
+                    {formatStatus(order.status)}
                   </span>
                   {/* Only show payment status if different from order status */}
                   {order.payment_status.toLowerCase() !== order.status.toLowerCase() && (
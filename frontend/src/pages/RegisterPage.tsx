@@ -1,6 +1,7 @@
 import React, { useState } from 'react';
 import { Link as RouterLink, useNavigate } from 'react-router-dom';
 import { useAuth } from '../context/AuthContext';
+import { authAPI } from '../services/api';
 
 const RegisterPage: React.FC = () => {
   const [formData, setFormData] = useState({
@@ -12,6 +13,9 @@ const RegisterPage: React.FC = () => {
   });
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState('');
+  // Set when the email has guest orders, which are claimed through an emailed link
+  const [guestEmail, setGuestEmail] = useState(false);
+  const [claimSent, setClaimSent] = useState(false);
   const { register } = useAuth();
   const navigate = useNavigate();
 
@@ -26,6 +30,8 @@ const RegisterPage: React.FC = () => {
     e.preventDefault();
     setLoading(true);
     setError('');
+    setGuestEmail(false);
+    setClaimSent(false);
 
     try {
       const success = await register(formData);
@@ -36,7 +42,25 @@ const RegisterPage: React.FC = () => {
         setError('Registration failed. Please try again.');
       }
     } catch (error: any) {
-      setError(error.response?.data?.error || 'Registration failed. Please try again.');
+      if (error.response?.status === 409 && error.response?.data?.guest) {
+        setGuestEmail(true);
+      } else {
+        setError(error.response?.data?.detail || 'Registration failed. Please try again.');
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleRequestClaim = async () => {
+    setLoading(true);
+    setError('');
+
+    try {
+      await authAPI.requestClaim(formData.email);
+      setClaimSent(true);
+    } catch (error: any) {
+      setError(error.response?.data?.detail || 'Could not send the link. Please try again.');
     } finally {
       setLoading(false);
     }
@@ -68,6 +92,29 @@ const RegisterPage: React.FC = () => {
               </div>
             )}
 
+            {guestEmail && !claimSent && (
+              <div className="bg-blue-50 border border-blue-200 text-blue-900 px-4 py-3 rounded text-sm">
+                <p>
+                  This email already has orders placed as a guest. We can email you a link to
+                  create your account and keep them.
+                </p>
+                <button
+                  type="button"
+                  onClick={handleRequestClaim}
+                  disabled={loading}
+                  className="mt-2 font-semibold text-blue-700 hover:text-blue-800 disabled:opacity-50"
+                >
+                  Email me a link
+                </button>
+              </div>
+            )}
+
+            {claimSent && (
+              <div className="bg-green-50 border border-green-200 text-green-700 px-4 py-3 rounded text-sm">
+                We've sent a link to {formData.email}. It works once and expires in 24 hours.
+              </div>
+            )}
+
             <div className="grid grid-cols-2 gap-4">
               <div>
                 <label htmlFor="first_name" className="block text-sm font-medium text-gray-700">
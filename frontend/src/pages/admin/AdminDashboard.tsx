@@ -31,7 +31,7 @@ const AdminDashboard: React.FC = () => {
       setSalesChart(chartRes.data);
       setError('');
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to fetch dashboard data');
+      setError(err.response?.data?.detail || 'Failed to fetch dashboard data');
     } finally {
       setLoading(false);
     }
@@ -1,6 +1,20 @@
 import React, { useState, useEffect } from 'react';
-import { adminAPI } from '../../services/api';
-import type { Order } from '../../types/api';
+import { adminAPI, saveDownload } from '../../services/api';
+import type { BulkJob, Order, Product } from '../../types/api';
+
+interface EditorLine {
+  product_id: string;
+  quantity: number;
+}
+
+const emptyAddress = { address: '', city: '', province: '', postal_code: '' };
+
+const bulkActionLabels: Record<BulkJob['action'], string> = {
+  process: 'Mark processing',
+  ship: 'Ship',
+  packing_slips: 'Packing slips',
+  cancel: 'Cancel',
+};
 
 const AdminOrders: React.FC = () => {
   const [orders, setOrders] = useState<Order[]>([]);
@@ -10,10 +24,17 @@ const AdminOrders: React.FC = () => {
   const [searchTerm, setSearchTerm] = useState('');
   const [selectedStatus, setSelectedStatus] = useState('All');
   const [selectedPaymentStatus, setSelectedPaymentStatus] = useState('All');
+  const [dateFrom, setDateFrom] = useState('');
+  const [dateTo, setDateTo] = useState('');
+  const [exportRows, setExportRows] = useState<'orders' | 'items'>('orders');
   const [currentPage, setCurrentPage] = useState(1);
   const [totalPages, setTotalPages] = useState(1);
   const [showOrderModal, setShowOrderModal] = useState(false);
   const [selectedOrder, setSelectedOrder] = useState<Order | null>(null);
+  const [selectedOrderETag, setSelectedOrderETag] = useState<string | undefined>();
+  const [checkedIds, setCheckedIds] = useState<number[]>([]);
+  const [printing, setPrinting] = useState(false);
+  const [bulkJob, setBulkJob] = useState<BulkJob | null>(null);
   const [showStatusModal, setShowStatusModal] = useState(false);
   const [showPaymentModal, setShowPaymentModal] = useState(false);
   const [statusFormData, setStatusFormData] = useState({
@@ -24,15 +45,56 @@ const AdminOrders: React.FC = () => {
   const [paymentFormData, setPaymentFormData] = useState({
     payment_status: ''
   });
+  // The order editor creates a draft when editorOrder is null, and edits it otherwise
+  const [showEditorModal, setShowEditorModal] = useState(false);
+  const [editorOrder, setEditorOrder] = useState<Order | null>(null);
+  const [editorETag, setEditorETag] = useState<string | undefined>();
+  const [products, setProducts] = useState<Product[]>([]);
+  const [editorFormData, setEditorFormData] = useState({
+    user_id: '',
+    items: [] as EditorLine[],
+    address: emptyAddress,
+    payment_method: 'bank_transfer',
+    notes: ''
+  });
 
-  const statuses = ['All', 'pending', 'processing', 'shipped', 'delivered', 'cancelled'];
-  const paymentStatuses = ['All', 'unpaid', 'paid', 'failed', 'refunded'];
+  const statuses = ['All', 'draft', 'pending', 'processing', 'partially_shipped', 'shipped', 'delivered', 'cancelled'];
+  const paymentStatuses = ['All', 'unpaid', 'paid', 'failed', 'partially_refunded', 'refunded'];
 
   // Fetch orders and stats on component mount and when filters change
   useEffect(() => {
     fetchOrders();
     fetchStats();
-  }, [currentPage, searchTerm, selectedStatus, selectedPaymentStatus]);
+  }, [currentPage, searchTerm, selectedStatus, selectedPaymentStatus, dateFrom, dateTo]);
+
+  // Follow a bulk job until it finishes, then refresh the list it changed
+  useEffect(() => {
+    if (!bulkJob || bulkJob.status === 'completed' || bulkJob.status === 'failed') return;
+    const timer = setTimeout(async () => {
+      try {
+        const response = await adminAPI.getJob(bulkJob.id);
+        setBulkJob(response.data);
+        if (response.data.status === 'completed' || response.data.status === 'failed') {
+          fetchOrders();
+        }
+      } catch (err: any) {
+        setError(err.response?.data?.detail || 'Failed to get bulk job progress');
+      }
+    }, 2000);
+    return () => clearTimeout(timer);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [bulkJob]);
+
+  // filterParams are the list filters as the API takes them
+  const filterParams = () => {
+    const params: any = {};
+    if (searchTerm) params.search = searchTerm;
+    if (selectedStatus !== 'All') params.status = selectedStatus;
+    if (selectedPaymentStatus !== 'All') params.payment_status = selectedPaymentStatus;
+    if (dateFrom) params.from = dateFrom;
+    if (dateTo) params.to = dateTo;
+    return params;
+  };
 
   const fetchOrders = async () => {
     try {
@@ -40,18 +102,15 @@ const AdminOrders: React.FC = () => {
       const params: any = {
         page: currentPage,
         limit: 10,
+        ...filterParams(),
       };
-      
-      if (searchTerm) params.search = searchTerm;
-      if (selectedStatus !== 'All') params.status = selectedStatus;
-      if (selectedPaymentStatus !== 'All') params.payment_status = selectedPaymentStatus;
 
       const response = await adminAPI.getOrders(params);
       setOrders(response.data.orders);
       setTotalPages(response.data.pagination.totalPages);
       setError('');
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to fetch orders');
+      setError(err.response?.data?.detail || 'Failed to fetch orders');
     } finally {
       setLoading(false);
     }
@@ -66,17 +125,86 @@ const AdminOrders: React.FC = () => {
     }
   };
 
+  const toggleChecked = (id: number) => {
+    setCheckedIds(ids => (ids.includes(id) ? ids.filter(other => other !== id) : [...ids, id]));
+  };
+
+  const toggleAllChecked = () => {
+    const pageIds = orders.map(order => order.id);
+    const allChecked = pageIds.every(id => checkedIds.includes(id));
+    setCheckedIds(ids => (allChecked
+      ? ids.filter(id => !pageIds.includes(id))
+      : [...ids, ...pageIds.filter(id => !ids.includes(id))]));
+  };
+
+  // Downloads come back as blobs, so their error problem has to be read first
+  // startBulkJob runs action on the checked orders, or on every order
+  // matching the filters when none are checked
+  const startBulkJob = async (action: BulkJob['action']) => {
+    const data: any = { action };
+    if (checkedIds.length > 0) {
+      data.order_ids = checkedIds;
+    } else {
+      data.filter = filterParams();
+    }
+    if (action === 'ship') {
+      const carrier = window.prompt('Carrier code (jne, jnt or sicepat)');
+      if (!carrier) return;
+      data.carrier = carrier;
+      data.service = window.prompt('Service (optional)') || '';
+    }
+    if (action === 'cancel' && !window.confirm('Cancel these orders and put their items back in stock?')) {
+      return;
+    }
+    try {
+      const response = await adminAPI.startBulkJob(data);
+      setBulkJob(response.data);
+      setCheckedIds([]);
+      setError('');
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'Failed to start bulk job');
+    }
+  };
+
+  const handleDownload = async (request: () => Promise<any>, fallbackName: string) => {
+    try {
+      setPrinting(true);
+      saveDownload(await request(), fallbackName);
+      setError('');
+    } catch (err: any) {
+      let detail = 'Failed to download document';
+      try {
+        detail = JSON.parse(await err.response.data.text()).detail || detail;
+      } catch {
+        // Not a problem document
+      }
+      setError(detail);
+    } finally {
+      setPrinting(false);
+    }
+  };
+
   const handleViewOrder = async (order: Order) => {
     try {
       const response = await adminAPI.getOrder(order.id);
       setSelectedOrder(response.data);
       setShowOrderModal(true);
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to fetch order details');
+      setError(err.response?.data?.detail || 'Failed to fetch order details');
     }
   };
 
-  const handleUpdateStatus = (order: Order) => {
+  const handleUpdateStatus = async (order: Order) => {
+    try {
+      // Load the current version so the save can be rejected if someone else edits it meanwhile
+      const response = await adminAPI.getOrder(order.id);
+      order = response.data;
+      setSelectedOrderETag(response.headers['etag']);
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'Failed to fetch order details');
+      return;
+    }
+
     setSelectedOrder(order);
     setStatusFormData({
       status: order.status,
@@ -92,12 +220,121 @@ const AdminOrders: React.FC = () => {
     if (!selectedOrder) return;
 
     try {
-      await adminAPI.updateOrderStatus(selectedOrder.id, statusFormData);
+      await adminAPI.updateOrderStatus(selectedOrder.id, statusFormData, selectedOrderETag);
       setShowStatusModal(false);
       fetchOrders();
       fetchStats();
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to update order status');
+      if (err.response?.status === 409 || err.response?.status === 412) {
+        setError('This order was changed by someone else. Reopen it to see the latest version.');
+        return;
+      }
+      setError(err.response?.data?.detail || 'Failed to update order status');
+    }
+  };
+
+  const loadProducts = async () => {
+    if (products.length > 0) return;
+    try {
+      const response = await adminAPI.getProducts({ limit: 100 });
+      setProducts(response.data.products);
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'Failed to fetch products');
+    }
+  };
+
+  const handleNewOrder = () => {
+    loadProducts();
+    setEditorOrder(null);
+    setEditorETag(undefined);
+    setEditorFormData({
+      user_id: '',
+      items: [{ product_id: '', quantity: 1 }],
+      address: emptyAddress,
+      payment_method: 'bank_transfer',
+      notes: ''
+    });
+    setShowEditorModal(true);
+  };
+
+  const handleEditOrder = async (order: Order) => {
+    try {
+      // Edits are rejected if someone else changed the order after it was loaded
+      const response = await adminAPI.getOrder(order.id);
+      order = response.data;
+      setEditorETag(response.headers['etag']);
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'Failed to fetch order details');
+      return;
+    }
+
+    loadProducts();
+    setEditorOrder(order);
+    setEditorFormData({
+      user_id: String(order.user_id),
+      items: (order.order_items || []).map(item => ({ product_id: String(item.product_id), quantity: item.quantity })),
+      address: emptyAddress,
+      payment_method: order.payment_method,
+      notes: order.notes || ''
+    });
+    setShowEditorModal(true);
+  };
+
+  const updateEditorLine = (index: number, line: Partial<EditorLine>) => {
+    setEditorFormData(prev => ({
+      ...prev,
+      items: prev.items.map((item, i) => (i === index ? { ...item, ...line } : item))
+    }));
+  };
+
+  const handleEditorSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+
+    const items = editorFormData.items
+      .filter(item => item.product_id)
+      .map(item => ({ product_id: Number(item.product_id), quantity: item.quantity }));
+    // The address is only sent when it was filled in, so an edit keeps the current one otherwise
+    const address = Object.values(editorFormData.address).every(Boolean) ? editorFormData.address : undefined;
+
+    try {
+      if (editorOrder) {
+        await adminAPI.editOrder(editorOrder.id, {
+          items,
+          shipping_address: address,
+          payment_method: editorFormData.payment_method,
+          notes: editorFormData.notes
+        }, editorETag);
+      } else {
+        await adminAPI.createDraftOrder({
+          user_id: Number(editorFormData.user_id),
+          items,
+          shipping_address: address,
+          payment_method: editorFormData.payment_method,
+          notes: editorFormData.notes
+        });
+      }
+      setShowEditorModal(false);
+      setError('');
+      fetchOrders();
+      fetchStats();
+    } catch (err: any) {
+      if ((err.response?.status === 409 && err.response?.data?.code === 'VERSION_CONFLICT') || err.response?.status === 412) {
+        setError('This order was changed by someone else. Reopen it to see the latest version.');
+        return;
+      }
+      setError(err.response?.data?.detail || 'Failed to save order');
+    }
+  };
+
+  const handlePlaceOrder = async (order: Order) => {
+    try {
+      const response = await adminAPI.getOrder(order.id);
+      await adminAPI.placeDraftOrder(order.id, response.headers['etag']);
+      setError('');
+      fetchOrders();
+      fetchStats();
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'Failed to place order');
     }
   };
 
@@ -120,7 +357,7 @@ const AdminOrders: React.FC = () => {
       fetchOrders();
       fetchStats();
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to update payment status');
+      setError(err.response?.data?.detail || 'Failed to update payment status');
     }
   };
 
@@ -128,7 +365,9 @@ const AdminOrders: React.FC = () => {
     switch (status) {
       case 'delivered': return 'bg-green-100 text-green-800';
       case 'processing': return 'bg-yellow-100 text-yellow-800';
+      case 'draft': return 'bg-purple-100 text-purple-800';
       case 'pending': return 'bg-gray-100 text-gray-800';
+      case 'partially_shipped': return 'bg-indigo-100 text-indigo-800';
       case 'shipped': return 'bg-blue-100 text-blue-800';
       case 'cancelled': return 'bg-red-100 text-red-800';
       default: return 'bg-gray-100 text-gray-800';
@@ -140,6 +379,7 @@ const AdminOrders: React.FC = () => {
       case 'paid': return 'bg-green-100 text-green-800';
       case 'unpaid': return 'bg-yellow-100 text-yellow-800';
       case 'failed': return 'bg-red-100 text-red-800';
+      case 'partially_refunded': return 'bg-orange-100 text-orange-800';
       case 'refunded': return 'bg-gray-100 text-gray-800';
       default: return 'bg-gray-100 text-gray-800';
     }
@@ -161,9 +401,36 @@ const AdminOrders: React.FC = () => {
           <h1 className="text-2xl font-bold text-gray-900">Orders</h1>
           <p className="text-gray-600">Manage customer orders and fulfillment</p>
         </div>
-        <button className="bg-indigo-600 text-white px-4 py-2 rounded-md hover:bg-indigo-700 transition-colors">
-          Export Orders
-        </button>
+        <div className="flex gap-3">
+          <button
+            onClick={handleNewOrder}
+            className="bg-white border border-indigo-600 text-indigo-600 px-4 py-2 rounded-md hover:bg-indigo-50 transition-colors"
+          >
+            New Order
+          </button>
+          <select
+            value={exportRows}
+            onChange={(e) => setExportRows(e.target.value as 'orders' | 'items')}
+            className="px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-indigo-500"
+          >
+            <option value="orders">Row per order</option>
+            <option value="items">Row per item</option>
+          </select>
+          <button
+            onClick={() => handleDownload(() => adminAPI.exportOrders({ ...filterParams(), export: 'csv', rows: exportRows }), 'orders.csv')}
+            disabled={printing}
+            className="bg-indigo-600 text-white px-4 py-2 rounded-md hover:bg-indigo-700 transition-colors disabled:opacity-50"
+          >
+            Export CSV
+          </button>
+          <button
+            onClick={() => handleDownload(() => adminAPI.exportOrders({ ...filterParams(), export: 'xlsx', rows: exportRows }), 'orders.xlsx')}
+            disabled={printing}
+            className="bg-indigo-600 text-white px-4 py-2 rounded-md hover:bg-indigo-700 transition-colors disabled:opacity-50"
+          >
+            Export XLSX
+          </button>
+        </div>
       </div>
 
       {/* Error Alert */}
@@ -215,7 +482,7 @@ const AdminOrders: React.FC = () => {
             >
               {statuses.map(status => (
                 <option key={status} value={status}>
-                  {status === 'All' ? 'All Status' : status.charAt(0).toUpperCase() + status.slice(1)}
+                  {status === 'All' ? 'All Status' : (status.charAt(0).toUpperCase() + status.slice(1)).replace(/_/g, ' ')}
                 </option>
               ))}
             </select>
@@ -226,20 +493,140 @@ const AdminOrders: React.FC = () => {
             >
               {paymentStatuses.map(status => (
                 <option key={status} value={status}>
-                  {status === 'All' ? 'All Payment' : status.charAt(0).toUpperCase() + status.slice(1)}
+                  {status === 'All' ? 'All Payment' : (status.charAt(0).toUpperCase() + status.slice(1)).replace('_', ' ')}
                 </option>
               ))}
             </select>
+            <input
+              type="date"
+              value={dateFrom}
+              onChange={(e) => { setDateFrom(e.target.value); setCurrentPage(1); }}
+              title="Placed from"
+              className="px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-indigo-500"
+            />
+            <input
+              type="date"
+              value={dateTo}
+              onChange={(e) => { setDateTo(e.target.value); setCurrentPage(1); }}
+              title="Placed until"
+              className="px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-indigo-500"
+            />
           </div>
         </div>
       </div>
 
+      {/* Bulk actions on every matching order */}
+      {checkedIds.length === 0 && (
+        <div className="flex items-center justify-end gap-2 text-sm">
+          <span className="text-gray-600">All matching orders:</span>
+          {(Object.keys(bulkActionLabels) as BulkJob['action'][]).map(action => (
+            <button
+              key={action}
+              onClick={() => startBulkJob(action)}
+              className="px-3 py-1.5 bg-white border border-gray-300 text-gray-700 rounded-md hover:bg-gray-50"
+            >
+              {bulkActionLabels[action]}
+            </button>
+          ))}
+        </div>
+      )}
+
+      {/* Bulk job progress */}
+      {bulkJob && (
+        <div className="bg-white border border-gray-200 rounded-lg px-4 py-3 space-y-2">
+          <div className="flex items-center justify-between">
+            <span className="text-sm font-medium text-gray-900">
+              {bulkActionLabels[bulkJob.action]} - job #{bulkJob.id} ({bulkJob.status})
+            </span>
+            <div className="space-x-2">
+              {bulkJob.document_name && (
+                <button
+                  onClick={() => handleDownload(() => adminAPI.getJobDocument(bulkJob.id), bulkJob.document_name!)}
+                  disabled={printing}
+                  className="px-3 py-1.5 text-sm bg-indigo-600 text-white rounded-md hover:bg-indigo-700 disabled:opacity-50"
+                >
+                  Download
+                </button>
+              )}
+              {(bulkJob.status === 'completed' || bulkJob.status === 'failed') && (
+                <button onClick={() => setBulkJob(null)} className="px-3 py-1.5 text-sm text-gray-600 hover:text-gray-900">
+                  Dismiss
+                </button>
+              )}
+            </div>
+          </div>
+          <div className="w-full bg-gray-200 rounded-full h-2">
+            <div
+              className="bg-indigo-600 h-2 rounded-full"
+              style={{ width: `${bulkJob.total ? (bulkJob.processed / bulkJob.total) * 100 : 0}%` }}
+            ></div>
+          </div>
+          <p className="text-sm text-gray-600">
+            {bulkJob.processed} of {bulkJob.total} processed: {bulkJob.succeeded} succeeded, {bulkJob.skipped} skipped, {bulkJob.failed} failed
+          </p>
+          {bulkJob.error && <p className="text-sm text-red-700">{bulkJob.error}</p>}
+          {bulkJob.results?.some(result => result.status === 'failed') && (
+            <ul className="text-sm text-red-700 list-disc pl-5">
+              {bulkJob.results.filter(result => result.status === 'failed').map(result => (
+                <li key={result.id}>{result.order_number || `Order #${result.order_id}`}: {result.message}</li>
+              ))}
+            </ul>
+          )}
+        </div>
+      )}
+
+      {/* Bulk printing */}
+      {checkedIds.length > 0 && (
+        <div className="flex items-center justify-between bg-indigo-50 border border-indigo-200 rounded-lg px-4 py-3 mb-4">
+          <span className="text-sm text-indigo-900">{checkedIds.length} orders selected</span>
+          <div className="space-x-2">
+            <button
+              onClick={() => handleDownload(() => adminAPI.printInvoices(checkedIds), 'invoices.pdf')}
+              disabled={printing}
+              className="px-3 py-1.5 text-sm bg-indigo-600 text-white rounded-md hover:bg-indigo-700 disabled:opacity-50"
+            >
+              Print Invoices
+            </button>
+            <button
+              onClick={() => handleDownload(() => adminAPI.printPackingSlips(checkedIds), 'packing-slips.pdf')}
+              disabled={printing}
+              className="px-3 py-1.5 text-sm bg-white border border-indigo-300 text-indigo-700 rounded-md hover:bg-indigo-100 disabled:opacity-50"
+            >
+              Print Packing Slips
+            </button>
+            {(['process', 'ship', 'cancel'] as BulkJob['action'][]).map(action => (
+              <button
+                key={action}
+                onClick={() => startBulkJob(action)}
+                className="px-3 py-1.5 text-sm bg-white border border-indigo-300 text-indigo-700 rounded-md hover:bg-indigo-100"
+              >
+                {bulkActionLabels[action]}
+              </button>
+            ))}
+            <button
+              onClick={() => setCheckedIds([])}
+              className="px-3 py-1.5 text-sm text-gray-600 hover:text-gray-900"
+            >
+              Clear
+            </button>
+          </div>
+        </div>
+      )}
+
       {/* Orders Table */}
       <div className="bg-white rounded-lg shadow-sm border border-gray-200">
         <div className="overflow-x-auto">
           <table className="min-w-full divide-y divide-gray-200">
             <thead className="bg-gray-50">
               <tr>
+                <th className="pl-6 py-3 text-left">
+                  <input
+                    type="checkbox"
+                    aria-label="Select all orders on this page"
+                    checked={orders.length > 0 && orders.every(order => checkedIds.includes(order.id))}
+                    onChange={toggleAllChecked}
+                  />
+                </th>
                 <th className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Order</th>
                 <th className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Customer</th>
                 <th className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Items</th>
@@ -253,6 +640,14 @@ const AdminOrders: React.FC = () => {
             <tbody className="bg-white divide-y divide-gray-200">
               {orders.map((order) => (
                 <tr key={order.id} className="hover:bg-gray-50 transition-colors">
+                  <td className="pl-6 py-4">
+                    <input
+                      type="checkbox"
+                      aria-label={`Select order ${order.order_number}`}
+                      checked={checkedIds.includes(order.id)}
+                      onChange={() => toggleChecked(order.id)}
+                    />
+                  </td>
                   <td className="px-6 py-4 whitespace-nowrap">
                     <div className="text-sm font-medium text-gray-900">{order.order_number}</div>
                     <div className="text-sm text-gray-500">ID: {order.id}</div>
@@ -295,6 +690,22 @@ const AdminOrders: React.FC = () => {
                     >
                       View
                     </button>
+                    {(order.status === 'draft' || order.status === 'pending') && (
+                      <button
+                        onClick={() => handleEditOrder(order)}
+                        className="text-indigo-600 hover:text-indigo-900 mr-3 transition-colors"
+                      >
+                        Edit
+                      </button>
+                    )}
+                    {order.status === 'draft' && (
+                      <button
+                        onClick={() => handlePlaceOrder(order)}
+                        className="text-green-600 hover:text-green-900 mr-3 transition-colors"
+                      >
+                        Place
+                      </button>
+                    )}
                     <button
                       onClick={() => handleUpdateStatus(order)}
                       className="text-gray-600 hover:text-gray-900 mr-3 transition-colors"
@@ -388,6 +799,25 @@ const AdminOrders: React.FC = () => {
                   <p><strong>Date:</strong> {new Date(selectedOrder.created_at).toLocaleDateString()}</p>
                   <p><strong>Payment Method:</strong> {selectedOrder.payment_method}</p>
                   <p><strong>Tracking Number:</strong> {selectedOrder.tracking_number || 'N/A'}</p>
+                  <p><strong>Invoice:</strong> {selectedOrder.invoice_number || 'Not issued'}</p>
+                </div>
+                <div className="mt-3 space-x-3 text-sm">
+                  {selectedOrder.invoice_number && (
+                    <button
+                      onClick={() => handleDownload(() => adminAPI.getInvoice(selectedOrder.id), `${selectedOrder.invoice_number}.pdf`)}
+                      disabled={printing}
+                      className="text-indigo-600 hover:text-indigo-900 disabled:opacity-50"
+                    >
+                      Download Invoice
+                    </button>
+                  )}
+                  <button
+                    onClick={() => handleDownload(() => adminAPI.getPackingSlip(selectedOrder.id), `packing-slip-${selectedOrder.order_number}.pdf`)}
+                    disabled={printing}
+                    className="text-indigo-600 hover:text-indigo-900 disabled:opacity-50"
+                  >
+                    Packing Slip
+                  </button>
                 </div>
               </div>
             </div>
@@ -408,7 +838,8 @@ const AdminOrders: React.FC = () => {
                     {selectedOrder.order_items?.map((item) => (
                       <tr key={item.id}>
                         <td className="px-4 py-2 text-sm text-gray-900">
-                          {item.product?.name}
+                          {item.product_name}
+                          {item.product_sku && <div className="text-xs text-gray-500">SKU: {item.product_sku}</div>}
                         </td>
                         <td className="px-4 py-2 text-sm text-gray-900">{item.quantity}</td>
                         <td className="px-4 py-2 text-sm text-gray-900">
@@ -436,6 +867,28 @@ const AdminOrders: React.FC = () => {
                 </div>
               </div>
             </div>
+
+            {selectedOrder.changes && selectedOrder.changes.length > 0 && (
+              <div className="mt-6 border-t pt-4">
+                <h3 className="text-lg font-semibold mb-3">History</h3>
+                <ul className="space-y-2 text-sm">
+                  {selectedOrder.changes.map(change => (
+                    <li key={change.id} className="flex justify-between gap-4">
+                      <span>
+                        <span className="font-medium capitalize">{change.action}</span>
+                        {change.summary && <span className="text-gray-600">: {change.summary}</span>}
+                      </span>
+                      <span className="text-gray-500 whitespace-nowrap">
+                        {change.total_before !== change.total_after && change.action === 'edited' && (
+                          <>Rp {change.total_before.toLocaleString('id-ID')} → Rp {change.total_after.toLocaleString('id-ID')} · </>
+                        )}
+                        {new Date(change.created_at).toLocaleString()}
+                      </span>
+                    </li>
+                  ))}
+                </ul>
+              </div>
+            )}
           </div>
         </div>
       )}
@@ -507,6 +960,149 @@ const AdminOrders: React.FC = () => {
           </div>
         </div>
       )}
+      {/* Order Editor Modal */}
+      {showEditorModal && (
+        <div className="fixed inset-0 bg-black bg-opacity-50 flex items-center justify-center z-50">
+          <div className="bg-white rounded-lg p-6 w-full max-w-2xl max-h-screen overflow-y-auto">
+            <h2 className="text-xl font-bold mb-1">
+              {editorOrder ? `Edit Order ${editorOrder.order_number}` : 'New Draft Order'}
+            </h2>
+            <p className="text-sm text-gray-500 mb-4">
+              {editorOrder?.status === 'pending'
+                ? 'Stock follows the changed quantities. Totals are recalculated when you save.'
+                : 'Drafts hold no stock and stay hidden from the customer until they are placed.'}
+            </p>
+
+            <form onSubmit={handleEditorSubmit} className="space-y-4">
+              {!editorOrder && (
+                <div>
+                  <label className="block text-sm font-medium text-gray-700 mb-1">Customer ID</label>
+                  <input
+                    type="number"
+                    min={1}
+                    value={editorFormData.user_id}
+                    onChange={(e) => setEditorFormData(prev => ({ ...prev, user_id: e.target.value }))}
+                    className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-indigo-500"
+                    required
+                  />
+                </div>
+              )}
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Items</label>
+                <div className="space-y-2">
+                  {editorFormData.items.map((item, index) => (
+                    <div key={index} className="flex gap-2">
+                      <select
+                        value={item.product_id}
+                        onChange={(e) => updateEditorLine(index, { product_id: e.target.value })}
+                        className="flex-1 px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-indigo-500"
+                        required
+                      >
+                        <option value="">Select a product</option>
+                        {editorOrder?.order_items
+                          ?.filter(ordered => !products.some(product => product.id === ordered.product_id))
+                          .map(ordered => (
+                            <option key={`ordered-${ordered.product_id}`} value={ordered.product_id}>
+                              {ordered.product_name}
+                            </option>
+                          ))}
+                        {products.map(product => (
+                          <option key={product.id} value={product.id}>
+                            {product.name} — Rp {product.price.toLocaleString('id-ID')} ({product.stock} in stock)
+                          </option>
+                        ))}
+                      </select>
+                      <input
+                        type="number"
+                        min={1}
+                        value={item.quantity}
+                        onChange={(e) => updateEditorLine(index, { quantity: Number(e.target.value) })}
+                        className="w-24 px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-indigo-500"
+                        required
+                      />
+                      <button
+                        type="button"
+                        onClick={() => setEditorFormData(prev => ({ ...prev, items: prev.items.filter((_, i) => i !== index) }))}
+                        disabled={editorFormData.items.length === 1}
+                        className="px-3 text-sm text-red-600 hover:text-red-800 disabled:opacity-50"
+                      >
+                        Remove
+                      </button>
+                    </div>
+                  ))}
+                </div>
+                <button
+                  type="button"
+                  onClick={() => setEditorFormData(prev => ({ ...prev, items: [...prev.items, { product_id: '', quantity: 1 }] }))}
+                  className="mt-2 text-sm text-indigo-600 hover:text-indigo-900"
+                >
+                  + Add item
+                </button>
+              </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Shipping Address</label>
+                {editorOrder && (
+                  <p className="text-sm text-gray-500 mb-2">Currently {editorOrder.shipping_address}. Fill in all fields to change it.</p>
+                )}
+                <div className="grid grid-cols-2 gap-2">
+                  {(['address', 'city', 'province', 'postal_code'] as const).map(field => (
+                    <input
+                      key={field}
+                      type="text"
+                      placeholder={(field.charAt(0).toUpperCase() + field.slice(1)).replace('_', ' ')}
+                      value={editorFormData.address[field]}
+                      onChange={(e) => setEditorFormData(prev => ({ ...prev, address: { ...prev.address, [field]: e.target.value } }))}
+                      className={`px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-indigo-500 ${field === 'address' ? 'col-span-2' : ''}`}
+                      required={!editorOrder}
+                    />
+                  ))}
+                </div>
+              </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Payment Method</label>
+                <select
+                  value={editorFormData.payment_method}
+                  onChange={(e) => setEditorFormData(prev => ({ ...prev, payment_method: e.target.value }))}
+                  className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-indigo-500"
+                >
+                  <option value="bank_transfer">Bank Transfer</option>
+                  <option value="cod">Cash on Delivery</option>
+                </select>
+              </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Notes</label>
+                <textarea
+                  value={editorFormData.notes}
+                  onChange={(e) => setEditorFormData(prev => ({ ...prev, notes: e.target.value }))}
+                  rows={2}
+                  className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-indigo-500"
+                />
+              </div>
+
+              <div className="flex justify-end gap-3 pt-4">
+                <button
+                  type="button"
+                  onClick={() => setShowEditorModal(false)}
+                  className="px-4 py-2 border border-gray-300 rounded-md text-gray-700 hover:bg-gray-50"
+                >
+                  Cancel
+                </button>
+                <button
+                  type="submit"
+                  className="px-4 py-2 bg-indigo-600 text-white rounded-md hover:bg-indigo-700"
+                >
+                  {editorOrder ? 'Save Changes' : 'Create Draft'}
+                </button>
+              </div>
+            </form>
+          </div>
+        </div>
+      )}
+
     {/* Update Payment Status Modal */}
       {showPaymentModal && selectedOrder && (
         <div className="fixed inset-0 bg-black bg-opacity-50 flex items-center justify-center z-50">
@@ -526,7 +1122,6 @@ const AdminOrders: React.FC = () => {
                   <option value="unpaid">Unpaid</option>
                   <option value="paid">Paid</option>
                   <option value="failed">Failed</option>
-                  <option value="refunded">Refunded</option>
                 </select>
               </div>
 
@@ -13,6 +13,7 @@ const AdminProducts: React.FC = () => {
   const [totalPages, setTotalPages] = useState(1);
   const [showModal, setShowModal] = useState(false);
   const [editingProduct, setEditingProduct] = useState<Product | null>(null);
+  const [editingETag, setEditingETag] = useState<string | undefined>();
   const [formData, setFormData] = useState({
     name: '',
     description: '',
@@ -48,7 +49,7 @@ const AdminProducts: React.FC = () => {
       setTotalPages(response.data.pagination.totalPages);
       setError('');
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to fetch products');
+      setError(err.response?.data?.detail || 'Failed to fetch products');
     } finally {
       setLoading(false);
     }
@@ -80,7 +81,17 @@ const AdminProducts: React.FC = () => {
     setShowModal(true);
   };
 
-  const handleEditProduct = (product: Product) => {
+  const handleEditProduct = async (product: Product) => {
+    try {
+      // Load the current version so the save can be rejected if someone else edits it meanwhile
+      const response = await adminAPI.getProduct(product.id);
+      product = response.data;
+      setEditingETag(response.headers['etag']);
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'Failed to fetch product');
+      return;
+    }
+
     setEditingProduct(product);
     setFormData({
       name: product.name,
@@ -104,7 +115,7 @@ const AdminProducts: React.FC = () => {
       await adminAPI.deleteProduct(id);
       fetchProducts();
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to delete product');
+      setError(err.response?.data?.detail || 'Failed to delete product');
     }
   };
 
@@ -121,7 +132,7 @@ const AdminProducts: React.FC = () => {
       };
 
       if (editingProduct) {
-        await adminAPI.updateProduct(editingProduct.id, productData);
+        await adminAPI.updateProduct(editingProduct.id, productData, editingETag);
       } else {
         await adminAPI.createProduct(productData);
       }
@@ -129,7 +140,11 @@ const AdminProducts: React.FC = () => {
       setShowModal(false);
       fetchProducts();
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to save product');
+      if (err.response?.status === 409 || err.response?.status === 412) {
+        setError('This product was changed by someone else. Reopen it to see the latest version.');
+        return;
+      }
+      setError(err.response?.data?.detail || 'Failed to save product');
     }
   };
 
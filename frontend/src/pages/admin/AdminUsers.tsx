@@ -13,6 +13,7 @@ const AdminUsers: React.FC = () => {
   const [totalPages, setTotalPages] = useState(1);
   const [showModal, setShowModal] = useState(false);
   const [editingUser, setEditingUser] = useState<User | null>(null);
+  const [editingETag, setEditingETag] = useState<string | undefined>();
   const [formData, setFormData] = useState({
     first_name: '',
     last_name: '',
@@ -47,7 +48,7 @@ const AdminUsers: React.FC = () => {
       setTotalPages(response.data.pagination.totalPages);
       setError('');
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to fetch users');
+      setError(err.response?.data?.detail || 'Failed to fetch users');
     } finally {
       setLoading(false);
     }
@@ -67,7 +68,17 @@ const AdminUsers: React.FC = () => {
     setShowModal(true);
   };
 
-  const handleEditUser = (user: User) => {
+  const handleEditUser = async (user: User) => {
+    try {
+      // Load the current version so the save can be rejected if someone else edits it meanwhile
+      const response = await adminAPI.getUser(user.id);
+      user = response.data;
+      setEditingETag(response.headers['etag']);
+    } catch (err: any) {
+      setError(err.response?.data?.detail || 'Failed to fetch user');
+      return;
+    }
+
     setEditingUser(user);
     setFormData({
       first_name: user.first_name,
@@ -88,7 +99,7 @@ const AdminUsers: React.FC = () => {
       await adminAPI.deleteUser(id);
       fetchUsers();
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to delete user');
+      setError(err.response?.data?.detail || 'Failed to delete user');
     }
   };
 
@@ -101,7 +112,7 @@ const AdminUsers: React.FC = () => {
       };
 
       if (editingUser) {
-        await adminAPI.updateUser(editingUser.id, userData);
+        await adminAPI.updateUser(editingUser.id, userData, editingETag);
       } else {
         await adminAPI.createUser(userData);
       }
@@ -109,7 +120,11 @@ const AdminUsers: React.FC = () => {
       setShowModal(false);
       fetchUsers();
     } catch (err: any) {
-      setError(err.response?.data?.error || 'Failed to save user');
+      if (err.response?.status === 409 || err.response?.status === 412) {
+        setError('This user was changed by someone else. Reopen it to see the latest version.');
+        return;
+      }
+      setError(err.response?.data?.detail || 'Failed to save user');
     }
   };
 
@@ -320,6 +335,11 @@ const AdminUsers: React.FC = () => {
                   </td>
                   <td className="px-6 py-4 whitespace-nowrap">
                     {getStatusBadge(user.is_active)}
+                    {user.is_guest && (
+                      <span className="ml-2 inline-flex px-2 py-1 text-xs font-semibold rounded-full bg-gray-100 text-gray-800">
+                        Guest
+                      </span>
+                    )}
                   </td>
                   <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-900">
                     {user.phone || 'N/A'}
@@ -53,6 +53,8 @@ export const authAPI = {
   register: (userData: any) => api.post('/auth/register', userData),
   login: (credentials: any) => api.post('/auth/login', credentials),
   getProfile: () => api.get('/auth/profile'),
+  requestClaim: (email: string) => api.post('/auth/claim/request', { email }),
+  claim: (data: any) => api.post('/auth/claim', data),
 };
 
 // Products API
@@ -80,14 +82,45 @@ export const ordersAPI = {
   updateOrderStatus: (id: number, status: string) => api.put(`/orders/${id}/status`, { status }),
 };
 
+// saveDownload hands a file response (requested with responseType 'blob') to
+// the browser as a download, named as the server suggests
+export const saveDownload = (response: { data: Blob; headers: any }, fallbackName: string) => {
+  const disposition: string = response.headers['content-disposition'] || '';
+  const match = disposition.match(/filename="?([^";]+)"?/);
+  const url = URL.createObjectURL(response.data);
+  const link = document.createElement('a');
+  link.href = url;
+  link.download = match ? match[1] : fallbackName;
+  document.body.appendChild(link);
+  link.click();
+  link.remove();
+  URL.revokeObjectURL(url);
+};
+
+const asFile = { responseType: 'blob' as const };
+
 // Checkout API
 export const checkoutAPI = {
   checkout: (data: any) => api.post('/protected/checkout', data),
   getOrderHistory: () => api.get('/protected/checkout/history'),
   getOrderDetails: (id: number) => api.get(`/protected/checkout/orders/${id}`),
   cancelOrder: (id: number) => api.put(`/protected/checkout/orders/${id}/cancel`),
+  getOrderTimeline: (id: number) => api.get(`/protected/checkout/orders/${id}/timeline`),
+  getInvoice: (id: number) => api.get(`/protected/checkout/orders/${id}/invoice`, asFile),
+  requestReturn: (orderId: number, data: any) => api.post(`/protected/checkout/orders/${orderId}/returns`, data),
+  getReturns: () => api.get('/protected/checkout/returns'),
+  getReturn: (id: number) => api.get(`/protected/checkout/returns/${id}`),
+};
+
+// Guest API, for shoppers without an account
+export const guestAPI = {
+  checkout: (data: any) => api.post('/guest/checkout', data),
+  lookupOrder: (token: string) => api.get('/guest/orders', { params: { token } }),
 };
 
+// Admin updates must echo the ETag of the version being edited
+const ifMatch = (etag?: string) => (etag ? { headers: { 'If-Match': etag } } : undefined);
+
 // Admin API
 export const adminAPI = {
   // Dashboard
@@ -98,23 +131,53 @@ export const adminAPI = {
   
   // Products
   getProducts: (params?: any) => api.get('/protected/admin/products', { params }),
+  getProduct: (id: number) => api.get(`/protected/admin/products/${id}`),
   createProduct: (data: any) => api.post('/protected/admin/products', data),
-  updateProduct: (id: number, data: any) => api.put(`/protected/admin/products/${id}`, data),
+  updateProduct: (id: number, data: any, etag?: string) => api.put(`/protected/admin/products/${id}`, data, ifMatch(etag)),
   deleteProduct: (id: number) => api.delete(`/protected/admin/products/${id}`),
   
   // Users
   getUsers: (params?: any) => api.get('/protected/admin/users', { params }),
   getUser: (id: number) => api.get(`/protected/admin/users/${id}`),
   createUser: (data: any) => api.post('/protected/admin/users', data),
-  updateUser: (id: number, data: any) => api.put(`/protected/admin/users/${id}`, data),
+  updateUser: (id: number, data: any, etag?: string) => api.put(`/protected/admin/users/${id}`, data, ifMatch(etag)),
   deleteUser: (id: number) => api.delete(`/protected/admin/users/${id}`),
   
   // Orders
   getOrders: (params?: any) => api.get('/protected/admin/orders', { params }),
+  exportOrders: (params: any) => api.get('/protected/admin/orders', { ...asFile, params }),
   getOrderStats: () => api.get('/protected/admin/orders/stats'),
   getOrder: (id: number) => api.get(`/protected/admin/orders/${id}`),
-  updateOrderStatus: (id: number, data: any) => api.put(`/protected/admin/orders/${id}/status`, data),
+  createDraftOrder: (data: any) => api.post('/protected/admin/orders', data),
+  editOrder: (id: number, data: any, etag?: string) => api.put(`/protected/admin/orders/${id}`, data, ifMatch(etag)),
+  placeDraftOrder: (id: number, etag?: string) => api.put(`/protected/admin/orders/${id}/place`, undefined, ifMatch(etag)),
+  updateOrderStatus: (id: number, data: any, etag?: string) => api.put(`/protected/admin/orders/${id}/status`, data, ifMatch(etag)),
   updatePaymentStatus: (id: number, data: any) => api.put(`/protected/admin/orders/${id}/payment`, data),
+  getOrderTimeline: (id: number) => api.get(`/protected/admin/orders/${id}/timeline`),
+  getInvoice: (id: number) => api.get(`/protected/admin/orders/${id}/invoice`, asFile),
+  getPackingSlip: (id: number) => api.get(`/protected/admin/orders/${id}/packing-slip`, asFile),
+  printInvoices: (ids: number[]) => api.get('/protected/admin/orders/invoices', { ...asFile, params: { ids: ids.join(',') } }),
+  printPackingSlips: (ids: number[]) => api.get('/protected/admin/orders/packing-slips', { ...asFile, params: { ids: ids.join(',') } }),
+  startBulkJob: (data: any) => api.post('/protected/admin/orders/bulk', data),
+
+  // Bulk jobs
+  getJobs: (params?: any) => api.get('/protected/admin/jobs', { params }),
+  getJob: (id: number) => api.get(`/protected/admin/jobs/${id}`),
+  getJobDocument: (id: number) => api.get(`/protected/admin/jobs/${id}/document`, asFile),
+
+  // Returns
+  getReturns: (params?: any) => api.get('/protected/admin/returns', { params }),
+  getReturn: (id: number) => api.get(`/protected/admin/returns/${id}`),
+  approveReturn: (id: number, data?: any) => api.put(`/protected/admin/returns/${id}/approve`, data),
+  rejectReturn: (id: number, data?: any) => api.put(`/protected/admin/returns/${id}/reject`, data),
+  receiveReturn: (id: number, data?: any) => api.put(`/protected/admin/returns/${id}/receive`, data),
+  refundReturn: (id: number, data?: any) => api.put(`/protected/admin/returns/${id}/refund`, data),
+
+  // Refunds
+  getOrderRefunds: (orderId: number) => api.get(`/protected/admin/orders/${orderId}/refunds`),
+  createRefund: (orderId: number, data: any) => api.post(`/protected/admin/orders/${orderId}/refunds`, data),
+  getRefunds: (params?: any) => api.get('/protected/admin/refunds', { params }),
+  getRefund: (id: number) => api.get(`/protected/admin/refunds/${id}`),
 };
 
 export default api;
@@ -6,6 +6,8 @@ export interface User {
   phone?: string;
   role: string;
   is_active: boolean;
+  is_guest?: boolean;
+  version: number;
   created_at: string;
   updated_at: string;
 }
@@ -33,6 +35,7 @@ export interface Product {
   weight?: number;
   dimensions?: string;
   is_active: boolean;
+  version: number;
   created_at: string;
   updated_at: string;
   category?: Category;
@@ -74,11 +77,20 @@ export interface OrderItem {
   id: number;
   order_id: number;
   product_id: number;
+  product_name: string;
+  product_sku?: string;
+  product_image?: string;
+  options?: Record<string, string>;
+  weight?: number;
   quantity: number;
   unit_price: number;
   total_price: number;
+  tax_percent: number;
+  tax_amount: number;
   created_at: string;
   updated_at: string;
+  // Only loaded where the live product is needed; orders render from the
+  // product_* fields frozen at purchase
   product?: Product;
 }
 
@@ -93,13 +105,95 @@ export interface Order {
   total_amount: number;
   payment_method: string;
   payment_status: string;
+  invoice_number?: string;
+  invoiced_at?: string;
   shipping_address: string;
   tracking_number?: string;
   notes?: string;
+  version: number;
   created_at: string;
   updated_at: string;
   user?: User;
   order_items?: OrderItem[];
+  shipments?: Shipment[];
+  changes?: OrderChange[];
+}
+
+export interface OrderChange {
+  id: number;
+  order_id: number;
+  action: 'created' | 'edited' | 'placed';
+  summary: string;
+  total_before: number;
+  total_after: number;
+  created_by?: number | null;
+  created_at: string;
+}
+
+export interface Shipment {
+  id: number;
+  order_id: number;
+  carrier: string;
+  service: string;
+  tracking_number: string;
+  shipped_at: string;
+  delivered_at?: string | null;
+  tracked_at?: string | null;
+  created_at: string;
+  updated_at: string;
+  items?: ShipmentItem[];
+  events?: TrackingEvent[];
+}
+
+export interface TrackingEvent {
+  id: number;
+  shipment_id: number;
+  status: 'picked_up' | 'in_transit' | 'out_for_delivery' | 'delivered' | 'exception';
+  description: string;
+  location: string;
+  occurred_at: string;
+  created_at: string;
+}
+
+export interface ShipmentItem {
+  id: number;
+  shipment_id: number;
+  order_item_id: number;
+  quantity: number;
+  created_at: string;
+}
+
+export interface BulkJob {
+  id: number;
+  action: 'process' | 'ship' | 'packing_slips' | 'cancel';
+  status: 'queued' | 'running' | 'completed' | 'failed';
+  carrier?: string;
+  service?: string;
+  total: number;
+  processed: number;
+  succeeded: number;
+  skipped: number;
+  failed: number;
+  error?: string;
+  document_name?: string;
+  created_by?: number | null;
+  started_at?: string | null;
+  finished_at?: string | null;
+  created_at: string;
+  updated_at: string;
+  results?: BulkJobResult[];
+}
+
+export interface BulkJobResult {
+  id: number;
+  job_id: number;
+  order_id: number;
+  order_number: string;
+  status: 'pending' | 'succeeded' | 'skipped' | 'failed';
+  code?: string;
+  message?: string;
+  shipment_id?: number;
+  processed_at?: string | null;
 }
 
 export interface Address {
@@ -126,6 +220,18 @@ export interface ApiResponse<T> {
   message?: string;
 }
 
+// RFC 7807 problem document returned for every API error
+export interface ProblemDetails {
+  type: string;
+  title: string;
+  status: number;
+  code: string;
+  detail?: string;
+  instance?: string;
+  request_id?: string;
+  errors?: Record<string, string>;
+}
+
 export interface PaginatedResponse<T> {
   data: T[];
   pagination: {
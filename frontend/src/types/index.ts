@@ -90,24 +90,147 @@ export interface Order {
   tax: number;
   shipping_cost: number;
   total_amount: number;
+  refunded_amount: number;
+  net_paid: number;
   payment_method: string;
   payment_status: string;
+  invoice_number?: string;
+  invoiced_at?: string;
   shipping_address: string;
   tracking_number?: string;
   notes?: string;
   created_at: string;
   updated_at: string;
   order_items?: OrderItem[];
+  shipments?: Shipment[];
+  returns?: Return[];
+  refunds?: Refund[];
+}
+
+export interface Shipment {
+  id: number;
+  order_id: number;
+  carrier: string;
+  service: string;
+  tracking_number: string;
+  shipped_at: string;
+  delivered_at?: string | null;
+  tracked_at?: string | null;
+  created_at: string;
+  updated_at: string;
+  items?: ShipmentItem[];
+  events?: TrackingEvent[];
+}
+
+export interface TrackingEvent {
+  id: number;
+  shipment_id: number;
+  status: 'picked_up' | 'in_transit' | 'out_for_delivery' | 'delivered' | 'exception';
+  description: string;
+  location: string;
+  occurred_at: string;
+  created_at: string;
+}
+
+export interface ShipmentItem {
+  id: number;
+  shipment_id: number;
+  order_item_id: number;
+  quantity: number;
+  created_at: string;
+}
+
+export interface Return {
+  id: number;
+  order_id: number;
+  user_id: number;
+  rma_number: string;
+  status: 'requested' | 'approved' | 'rejected' | 'received' | 'refunded';
+  reason: string;
+  admin_note: string;
+  refund_amount: number;
+  approved_at?: string | null;
+  rejected_at?: string | null;
+  received_at?: string | null;
+  refunded_at?: string | null;
+  created_at: string;
+  updated_at: string;
+  items?: ReturnItem[];
+  photos?: ReturnPhoto[];
+}
+
+export interface ReturnItem {
+  id: number;
+  return_id: number;
+  order_item_id: number;
+  quantity: number;
+  disposition: '' | 'restock' | 'write_off';
+  created_at: string;
+}
+
+export interface ReturnPhoto {
+  id: number;
+  return_id: number;
+  url: string;
+  created_at: string;
+}
+
+export interface Refund {
+  id: number;
+  order_id: number;
+  return_id?: number | null;
+  amount: number;
+  items_amount: number;
+  shipping_amount: number;
+  tax_amount: number;
+  reason: string;
+  method: 'provider' | 'manual_bank';
+  status: 'pending' | 'succeeded' | 'failed';
+  provider: string;
+  provider_ref: string;
+  bank_reference: string;
+  failure_reason: string;
+  created_by?: number | null;
+  refunded_at?: string | null;
+  created_at: string;
+  updated_at: string;
+  items?: RefundItem[];
+}
+
+export interface RefundItem {
+  id: number;
+  refund_id: number;
+  order_item_id: number;
+  quantity: number;
+  amount: number;
+}
+
+export interface TimelineEntry {
+  at: string;
+  event: string;
+  detail?: string;
+  shipment_id?: number;
+  return_id?: number;
+  refund_id?: number;
 }
 
 export interface OrderItem {
   id: number;
   order_id: number;
   product_id: number;
+  product_name: string;
+  product_sku?: string;
+  product_image?: string;
+  options?: Record<string, string>;
+  weight?: number;
   quantity: number;
   unit_price: number;
   total_price: number;
+  tax_percent: number;
+  tax_amount: number;
   created_at: string;
   updated_at: string;
+  // Only loaded where the live product is needed; orders render from the
+  // product_* fields frozen at purchase
   product?: Product;
 }